@@ -69,6 +69,37 @@ func (mh *mountHelper) BuildOptions() []string {
 	return parts
 }
 
+// PrintCheck prints the parsed mount options and the resulting command,
+// without executing anything, so that mount options can be validated.
+func (mh *mountHelper) PrintCheck(w io.Writer) {
+	recognized := "(none)"
+	if opts := mh.BuildOptions(); len(opts) > 0 {
+		recognized = strings.Join(opts, " ")
+	}
+
+	setuid := "(none)"
+	if mh.Setuid != "" {
+		setuid = mh.Setuid
+	}
+
+	ignored := "(none)"
+	if len(mh.Ignored) > 0 {
+		ignored = strings.Join(mh.Ignored, " ")
+	}
+
+	cmdArgs := mh.BuildCommand()
+	for i, arg := range cmdArgs {
+		cmdArgs[i] = shellescape.Quote(arg)
+	}
+
+	fmt.Fprintf(w, "Recognized: %s\n", recognized)
+	fmt.Fprintf(w, "Ignored: %s\n", ignored)
+	fmt.Fprintf(w, "Setuid: %s\n", setuid)
+	fmt.Fprintf(w, "Logfile: %s\n", mh.Logfile)
+	fmt.Fprintf(w, "Timeout: %s\n", mh.Timeout)
+	fmt.Fprintf(w, "Command: %s\n", strings.Join(cmdArgs, " "))
+}
+
 // Execute handles the execution of the ZipFUSE filesystem binary.
 // It handles setting up the environment, forking, and executing the
 // filesystem process - waiting for a mount success or failure signal.
@@ -26,6 +26,9 @@ Additional mount options to control mount helper behavior itself:
 Filesystem-specific options need to be adapted into this format:
   --webserver :8000 --strict-cache => webserver=:8000,strict_cache
 
+For validating mount options (printing the command, without mounting):
+  %s --check key[=value],key[=value],... [source mountpoint]
+
 Note that FUSE mount helper events are printed to standard error (stderr).
 Filesystem events are printed to %q (if it is writeable).`
 
@@ -24,6 +24,9 @@ Additional mount options to control mount helper behavior itself:
 Filesystem-specific options need to be adapted into this format:
   --webserver :8000 --strict-cache => webserver=:8000,strict_cache
 
+For validating mount options (printing the command, without mounting):
+  mount.zipfuse --check key[=value],key[=value],... [source mountpoint]
+
 Note that FUSE mount helper events are printed to standard error (stderr).
 Filesystem events are printed to "/var/log/zipfuse.log" (if it is writeable).
 */
@@ -33,6 +36,7 @@ package main
 import (
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -53,21 +57,88 @@ var (
 
 	// allowedKeys is a map of known arguments to the ZipFUSE program.
 	allowedKeys = map[string]struct{}{
-		"fd-cache-bypass":  {},
-		"force-unicode":    {},
-		"must-crc32":       {},
-		"strict-cache":     {},
-		"allow-other":      {},
-		"dry-run":          {},
-		"flatten-zips":     {},
-		"verbose":          {},
-		"fd-cache-ttl":     {},
-		"fd-cache-size":    {},
-		"fd-limit":         {},
-		"ring-buffer-size": {},
-		"stream-pool-size": {},
-		"stream-threshold": {},
-		"webserver":        {},
+		"allow-raw-name-lookup":         {},
+		"allow-xattr-control":           {},
+		"archive-marker":                {},
+		"archive-xattrs":                {},
+		"cache-dir-entries":             {},
+		"cache-file-content":            {},
+		"continue-on-error":             {},
+		"dedup-identical":               {},
+		"detect-sfx":                    {},
+		"direct-io":                     {},
+		"dir-sizes":                     {},
+		"expose-raw":                    {},
+		"fd-cache-bypass":               {},
+		"flat-deref-symlinks":           {},
+		"force-unicode":                 {},
+		"hide-hidden":                   {},
+		"metadata-only":                 {},
+		"must-crc32":                    {},
+		"no-preflight":                  {},
+		"nonempty":                      {},
+		"preserve-ownership":            {},
+		"preserve-permissions":          {},
+		"real-sort-dirs-first":          {},
+		"strict-cache":                  {},
+		"tail":                          {},
+		"verify-on-mount":               {},
+		"webserver-archives":            {},
+		"webserver-bundle-hash-paths":   {},
+		"webserver-readonly":            {},
+		"allow-other":                   {},
+		"dry-run":                       {},
+		"flatten-zips":                  {},
+		"verbose":                       {},
+		"breaker-cooldown":              {},
+		"breaker-window":                {},
+		"fd-cache-ttl":                  {},
+		"idle-timeout":                  {},
+		"log-repeat-window":             {},
+		"tail-window":                   {},
+		"walk-timeout":                  {},
+		"webserver-idle-timeout":        {},
+		"webserver-read-header-timeout": {},
+		"webserver-read-timeout":        {},
+		"webserver-write-timeout":       {},
+		"trace-sample":                  {},
+		"breaker-threshold":             {},
+		"fd-cache-size":                 {},
+		"fd-limit":                      {},
+		"log-keep":                      {},
+		"max-list-entries":              {},
+		"ring-buffer-size":              {},
+		"stream-retries":                {},
+		"walk-concurrency":              {},
+		"allowed-uids":                  {},
+		"dotfile-flat-style":            {},
+		"empty-archive-as":              {},
+		"entry":                         {},
+		"fixed-mtime":                   {},
+		"flatten-collisions":            {},
+		"fsname":                        {},
+		"json-log-file":                 {},
+		"log-file":                      {},
+		"log-max-size":                  {},
+		"max-in-memory":                 {},
+		"max-readahead":                 {},
+		"max-total-in-memory":           {},
+		"nested-conflicts":              {},
+		"only-ext":                      {},
+		"password-file":                 {},
+		"pin-glob":                      {},
+		"real-sort":                     {},
+		"signal-usr1":                   {},
+		"size-mode":                     {},
+		"stream-pool-size":              {},
+		"subtype":                       {},
+		"threshold-rules":               {},
+		"timestamp-tz":                  {},
+		"unicode-normalize":             {},
+		"webserver-archives-secret":     {},
+		"webserver-deny-ua":             {},
+		"stream-threshold":              {},
+		"webserver":                     {},
 	}
 )
 
@@ -79,6 +150,7 @@ type mountHelper struct {
 	Source     string
 	Mountpoint string
 	Options    map[string]string
+	Ignored    []string
 	Setuid     string
 	Logfile    string
 	Timeout    time.Duration
@@ -179,10 +251,15 @@ func (mh *mountHelper) parseOptions(args []string) error {
 
 				case ok:
 					mh.Options[key] = val
+
+				default:
+					mh.Ignored = append(mh.Ignored, key)
 				}
 			} else { // key
 				if _, ok := allowedKeys[opt]; ok {
 					mh.Options[opt] = ""
+				} else {
+					mh.Ignored = append(mh.Ignored, opt)
 				}
 			}
 		}
@@ -232,11 +309,38 @@ func (mh *mountHelper) deriveTypeFromSource() error {
 	return nil
 }
 
+// checkOptions parses an options string exactly as for mounting, but only
+// prints the recognized and ignored options and the resulting command.
+// Source and mountpoint are optional, with placeholders used otherwise.
+func checkOptions(w io.Writer, args []string) error {
+	source, mountpoint := "SOURCE", "MOUNTPOINT"
+	if len(args) >= 5 {
+		source, mountpoint = args[3], args[4]
+	}
+
+	mh, err := newMountHelper([]string{args[0], source, mountpoint, args[2]})
+	if err != nil {
+		return err
+	}
+
+	mh.PrintCheck(w)
+
+	return nil
+}
+
 func main() {
+	if len(os.Args) >= 3 && os.Args[1] == "--check" {
+		if err := checkOptions(os.Stdout, os.Args); err != nil {
+			fmt.Fprintf(os.Stderr, "mount.zipfuse error: %v\n", err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
 	if len(os.Args) < 3 {
 		progName := filepath.Base(os.Args[0])
 		fmt.Fprintf(os.Stderr, helpTextLong+"\n",
-			progName, Version, progName, progName, defaultLogfile)
+			progName, Version, progName, progName, progName, defaultLogfile)
 		os.Exit(1)
 	}
 
@@ -1,7 +1,9 @@
 package main
 
 import (
+	"bytes"
 	"slices"
+	"strings"
 	"testing"
 )
 
@@ -305,3 +307,65 @@ func Test_MountHelper_BuildCommand_Success(t *testing.T) {
 		})
 	}
 }
+
+// Expectation: The check should print recognized and ignored options and the command.
+func Test_checkOptions_Success(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+
+	err := checkOptions(&buf, []string{"mount.zipfuse", "--check", "allow_other,webserver=:8000,mtmo=20,bogus"})
+	if err != nil {
+		t.Fatalf("checkOptions() error = %v", err)
+	}
+
+	out := buf.String()
+	for _, want := range []string{
+		"Recognized: --allow-other --webserver :8000\n",
+		"Ignored: mtmo bogus\n",
+		"Setuid: (none)\n",
+		"Command: zipfuse SOURCE MOUNTPOINT --allow-other --webserver :8000\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("checkOptions() = %q\nwant to contain %q", out, want)
+		}
+	}
+}
+
+// Expectation: The check should use a given source and mountpoint (quoted).
+func Test_checkOptions_SourceMountpoint_Success(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+
+	err := checkOptions(&buf, []string{"mount.zipfuse", "--check", "setuid=alice,xbin=/opt/zipfuse", "/mnt/my zips", "/mnt/b"})
+	if err != nil {
+		t.Fatalf("checkOptions() error = %v", err)
+	}
+
+	out := buf.String()
+	for _, want := range []string{
+		"Recognized: (none)\n",
+		"Ignored: (none)\n",
+		"Setuid: alice\n",
+		"Command: /opt/zipfuse '/mnt/my zips' /mnt/b\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("checkOptions() = %q\nwant to contain %q", out, want)
+		}
+	}
+}
+
+// Expectation: The check should return an error for invalid options.
+func Test_checkOptions_Error(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+
+	if err := checkOptions(&buf, []string{"mount.zipfuse", "--check", "xtim=0"}); err == nil {
+		t.Fatal("checkOptions() expected error, got nil")
+	}
+	if buf.Len() != 0 {
+		t.Errorf("checkOptions() printed %q on error", buf.String())
+	}
+}
@@ -11,18 +11,138 @@ in-memory enumeration, chunked streaming and on-the-fly extraction - so that
 consumers remain entirely unaware of an archive being involved. It includes a
 HTTP webserver for a responsive diagnostics dashboard and runtime configurables.
 
+The source can also be a "http(s)://" URL of a single remote ZIP archive,
+which is read with HTTP range requests (without downloading it entirely).
+
 When mounted, the following OS signals are observed at runtime:
 - SIGTERM/SIGINT for gracefully unmounting the FS
-- SIGUSR1 for forcing a garbage collection run within Go
+- SIGUSR1 for forcing a garbage collection run within Go (or purging the FD cache)
 - SIGUSR2 for printing a stack trace to standard error (stderr)
 
 When enabled, the diagnostics dashboard exposes the following routes:
 - "/" for filesystem dashboard and event ring-buffer
 - "/gc" for forcing of a garbage collection (within Go)
 - "/reset" for resetting the filesystem metrics at runtime
+- "/pause" for pausing the opening of new archives (cached archives still serve; others fail with EAGAIN)
+- "/resume" for resuming the opening of new archives
+- "/metrics.bin" for the numeric metrics in a compact binary layout
+- "/healthz" for checking that the filesystem is serving requests (503 until ready after mounting, or after a fatal panic; reporting a pause)
+- "/errors.json" for listing archives that recently failed to open
+- "/open-zips.json" for listing archives currently held open by the file descriptor cache
+- "/changed?since=<time>" for listing files within archives modified after a RFC3339 time
+- "/support-bundle" for downloading the metrics, configuration, logs and runtime as a ZIP file (for bug reports)
+- "/archive?path=<path>" for downloading the backing file of an archive (only with --webserver-archives; signed with --webserver-archives-secret)
 - "/set/must-crc32/<bool>" for adapting forced integrity checking
+- "/set/dir-sizes/<bool>" for reporting the total sizes of directories within archives
 - "/set/fd-cache-bypass/<bool>" for bypassing the file descriptor cache
-- "/set/stream-threshold/<string>" for adapting of the streaming threshold`
+- "/set/stream-threshold/<string>" for adapting of the streaming threshold
+- "/cache/pin?glob=<glob>" for pinning matching archives in the file descriptor cache
+- "/cache/unpin?glob=<glob>" for unpinning such archives from the file descriptor cache
+
+The "/set/must-crc32" and "/set/stream-threshold" routes accept a "?glob=" query,
+overriding the setting only for matching archives (relative to the source directory).
+The value "unset" removes such an override again, falling back to the global setting.
+With --webserver-readonly, the "/gc", "/reset", "/set" and "/cache" routes are not served at all.
+The same applies only to a single address of a repeated --webserver if suffixed with "@readonly".`
+
+	helpTextTreeUse = "tree <source>"
+
+	helpTextTreeShort = "print the would-be filesystem as a tree (without mounting)"
+
+	helpTextTreeLong = `tree prints the would-be filesystem of the source as an indented tree of its
+directories and files (with their sizes) to standard output (stdout), without
+ever mounting it. The flags affecting the structure (e.g. --flatten-zips or
+--only-ext) are honored, so the tree is rendered as it would be mounted, and
+in the same (sorted) order as the directories would be listed when mounted.
+
+A source directory that is named "tree" needs to be given as "./tree" instead,
+when mounting it, as otherwise this subcommand would be invoked in its place.`
+
+	helpTextChangedUse = "changed <source> --since <time>"
+
+	helpTextChangedShort = "print the files within archives modified after a time (without mounting)"
+
+	helpTextChangedLong = `changed prints the files within the archives of the source that were modified
+after the RFC3339 time given with --since, as one JSON object (with the path,
+size and mtime) per line to standard output (stdout), without ever mounting it.
+Only the central directories of the archives are read, instead of walking into
+them, which makes it faster than a full walk for incremental indexing. The paths
+are those of the would-be filesystem, so the flags affecting the structure (e.g.
+--flatten-zips or --only-ext) are honored, as with the "tree" subcommand.
+
+The same is served on the "/changed?since=<time>" route of the dashboard.`
+
+	helpTextVerifyManifestUse = "verify-manifest <source> <sumsfile>"
+
+	helpTextVerifyManifestShort = "verify files within archives against a SHA-256 manifest (without mounting)"
+
+	helpTextVerifyManifestLong = `verify-manifest reads each file listed within the manifest (in the format of
+sha256sum(1), e.g. as created within the mounted filesystem) through the same
+paths as the mounted filesystem would, comparing its SHA-256 checksum against
+the listed one. Each file is printed as "OK", "FAILED" or "MISSING" (in the
+order of the manifest), exiting with an error if any file did not match.
+
+The paths are relative to the root of the would-be filesystem, so the flags
+affecting the structure (e.g. --flatten-zips) decide if these are resolved in
+the nested or the flat form. Up to --parallel files are read concurrently.`
+
+	helpTextCatUse = "cat <archive> <file>"
+
+	helpTextCatShort = "extract a file within an archive to stdout (without mounting)"
+
+	helpTextCatLong = `cat extracts a single file within the archive (a local ZIP archive or a remote
+"http(s)://" URL) to standard output (stdout), without ever mounting it. The
+file is streamed through the same readers as the mounted filesystem would, so
+that even huge files are never buffered in memory entirely. The file is given
+with its path within the archive, either as stored or as normalized.
+
+A part of the file can be extracted with --offset and --length (in bytes).
+An encrypted file (ZipCrypto) needs its password given with --password-file,
+--password-prompt or the ZIPFUSE_PASSWORD environment variable.
+The exit code is 2 if the archive cannot be opened, 3 if the file does not
+exist within the archive (or is a directory), and 1 for any other error.`
+
+	helpTextDiffUse = "diff <a> <b>"
+
+	helpTextDiffShort = "print the differences between two archive trees (without mounting)"
+
+	helpTextDiffLong = `diff compares the would-be filesystems of two sources (source directories, or
+single local or remote archives) by their paths, printing each path that was
+added, removed or changed (by type, size, CRC-32 or mtime) as a report to
+standard output (stdout), or as one JSON object per line with --json. Only the
+central directories of the archives are read, without extracting any content,
+exiting with an error if any path differs between both sides (e.g. backups).
+
+Both sides are compared in the same form, so the flags affecting the structure
+(e.g. --flatten-zips or --only-ext) are honored for both of them alike. Files
+which differ only by their modification times can be ignored with --ignore-mtime.`
+
+	helpTextSignLinkUse = "sign-link <path> --webserver-archives-secret <file>"
+
+	helpTextSignLinkShort = "print a signed link for downloading an archive from the dashboard"
+
+	helpTextSignLinkLong = `sign-link prints a link to the "/archive" route of the dashboard for the archive
+at the path (relative to the source directory), as signed with the secret read
+from the file given with --webserver-archives-secret, to standard output (stdout).
+It is valid only for that archive and only until it expires (see --expires).
+
+With --webserver-archives-secret also given when mounting, the dashboard serves
+archives only by such links, so that access can be given to a single archive
+(prefixed with the address of the dashboard) without exposing all of them.`
+
+	helpTextSupportBundleUse = "support-bundle <address>"
+
+	helpTextSupportBundleShort = "download a support bundle (for bug reports) from the dashboard"
+
+	helpTextSupportBundleLong = `support-bundle downloads the support bundle of a mounted filesystem from the
+"/support-bundle" route of its dashboard at the address (e.g. 127.0.0.1:8000 or
+http://host:8000), which is a ZIP file of its metrics, configuration (with any
+secrets redacted), recent logs, runtime and the failed and open archives, to be
+attached to bug reports. It is written to the file given with --output, or else
+to the file named by the dashboard (within the current working directory).
+
+With --hash-paths (or --webserver-bundle-hash-paths given when mounting), the
+paths of all archives are replaced by their hashes (also within the logs).`
 
 	helpErrOptionsArg = `You have invoked this program with an "-o" flag, which is not supported.
 Most likely you tried mounting as "fuse.zipfuse" using mount(8) or fstab?
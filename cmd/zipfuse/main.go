@@ -7,29 +7,65 @@ in-memory enumeration, chunked streaming and on-the-fly extraction - so that
 consumers remain entirely unaware of an archive being involved. It includes a
 HTTP webserver for a responsive diagnostics dashboard and runtime configurables.
 
+The source can also be a "http(s)://" URL of a single remote ZIP archive,
+which is read with HTTP range requests (without downloading it entirely).
+With a single archive as source (local or remote), --entry serves only one
+of its files as the whole mount, with the mountpoint being a regular file.
+
+The "tree" subcommand prints the would-be filesystem as a text tree instead
+of mounting it, which is useful for previewing the effects of flags. The
+"changed" subcommand prints the files within archives modified after a time,
+and the "verify-manifest" subcommand verifies files against SHA-256 checksums.
+The "cat" subcommand extracts a single file within an archive to stdout,
+and the "diff" subcommand compares two sources (or archives) by their paths.
+The "support-bundle" subcommand downloads a support bundle from the dashboard.
+
 The following signals are observed and handled by the filesystem:
   - SIGTERM or SIGINT (CTRL+C) gracefully unmounts the filesystem
-  - SIGUSR1 forces a garbage collection (within Go)
+  - SIGUSR1 forces a garbage collection (within Go), or purges the FD cache
   - SIGUSR2 dumps a diagnostic stacktrace to standard error (stderr)
 
 When enabled, the diagnostics server exposes the following routes over HTTP:
   - "/" for filesystem dashboard and event ring-buffer
   - "/gc" for forcing of a garbage collection (within Go)
   - "/reset" for resetting the filesystem metrics at runtime
+  - "/pause" for pausing the opening of new archives (cached archives still serve; others fail with EAGAIN)
+  - "/resume" for resuming the opening of new archives
+  - "/metrics.bin" for the numeric metrics in a compact binary layout
+  - "/healthz" for checking that the filesystem is serving requests (503 until ready after mounting, or after a fatal panic; reporting a pause)
+  - "/errors.json" for listing archives that recently failed to open
+  - "/open-zips.json" for listing archives currently held open by the file descriptor cache
+  - "/changed?since=<time>" for listing files within archives modified after a RFC3339 time
+  - "/support-bundle" for downloading the metrics, configuration, logs and runtime as a ZIP file (for bug reports)
+  - "/archive?path=<path>" for downloading the backing file of an archive (only with --webserver-archives; signed with --webserver-archives-secret)
   - "/set/must-crc32/<bool>" for adapting forced integrity checking
+  - "/set/dir-sizes/<bool>" for reporting the total sizes of directories within archives
   - "/set/fd-cache-bypass/<bool>" for bypassing the file descriptor cache
   - "/set/stream-threshold/<string>" for adapting of the streaming threshold
+  - "/cache/pin?glob=<glob>" for pinning matching archives in the file descriptor cache
+  - "/cache/unpin?glob=<glob>" for unpinning such archives from the file descriptor cache
+
+The "/set/must-crc32" and "/set/stream-threshold" routes accept a "?glob=" query,
+overriding the setting only for matching archives (relative to the source directory).
+The value "unset" removes such an override again, falling back to the global setting.
+With --webserver-readonly, the "/gc", "/reset", "/set" and "/cache" routes are not served at all.
+The same applies only to a single address of a repeated --webserver if suffixed with "@readonly".
 */
 package main
 
 import (
+	"bufio"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"os"
-	"runtime/debug"
+	"path/filepath"
+	"regexp"
 	"strconv"
+	"strings"
 	"sync"
 	"syscall"
 	"time"
@@ -48,6 +84,24 @@ const (
 	signalMountSuccess   byte = 0
 	signalMountFailed    byte = 1
 	signalDelimiter      byte = '\n'
+
+	signalUSR1GC         = "gc"          // --signal-usr1
+	signalUSR1PurgeCache = "purge-cache" // --signal-usr1
+
+	passwordEnv = "ZIPFUSE_PASSWORD" // fallback password of all archives
+
+	supportBundleTimeout = 60 * time.Second // "support-bundle" subcommand
+
+	readyTimeout    = 30 * time.Second      // readiness self-test (overall)
+	readyMinBackoff = 10 * time.Millisecond // readiness self-test (first retry)
+	readyMaxBackoff = 1 * time.Second       // readiness self-test (any retry)
+
+	minMaxReadahead uint64 = 4 * 1024         // 4KiB
+	maxMaxReadahead uint64 = 16 * 1024 * 1024 // 16MiB
+
+	exitFailure           = 1 // any other error
+	exitArchiveUnreadable = 2 // "cat" subcommand
+	exitEntryNotFound     = 3 // "cat" subcommand
 )
 
 var (
@@ -60,31 +114,177 @@ var (
 	// errInvalidArgument is for an invalid CLI argument/value provided.
 	errInvalidArgument = errors.New("invalid argument")
 
+	// errPreflightFailed is for FUSE not being usable (before mounting).
+	errPreflightFailed = errors.New("preflight check failed")
+
 	// errPanicRecovered is for a goroutine panic that was recovered.
 	errPanicRecovered = errors.New("panic recovered")
+
+	// errUnreadableArchives is for archives that failed to open (on verification).
+	errUnreadableArchives = errors.New("unreadable archives")
+
+	// errManifestMismatch is for files not matching their manifest checksums.
+	errManifestMismatch = errors.New("manifest mismatch")
+
+	// errNotMounted is for the mountpoint not (yet) being served by a mount.
+	errNotMounted = errors.New("mountpoint is not mounted")
+
+	// errDiffFound is for archive trees differing (on comparison).
+	errDiffFound = errors.New("archive trees differ")
+
+	// errCatArchiveUnreadable is for an archive failing to open (on extraction).
+	errCatArchiveUnreadable = errors.New("cannot open archive")
+
+	// errCatEntryNotFound is for a file not existing within the archive (on extraction).
+	errCatEntryNotFound = errors.New("no such file in archive")
+
+	// errEmptySecret is for a secret file (e.g. --webserver-archives-secret) being empty.
+	errEmptySecret = errors.New("empty secret")
+
+	// errWalkFailures is for nodes that failed during a walk (--continue-on-error).
+	errWalkFailures = errors.New("walk failures")
+
+	// errBundleStatus is for the dashboard failing to serve a support bundle.
+	errBundleStatus = errors.New("unexpected dashboard status")
+
+	// exitCodeErrors are the sentinel errors which exit with a distinct code.
+	exitCodeErrors = []struct {
+		err  error
+		code int
+	}{
+		{errCatArchiveUnreadable, exitArchiveUnreadable},
+		{errCatEntryNotFound, exitEntryNotFound},
+	}
+
+	// treeFlags are the flags of the root command shared with the "tree"
+	// subcommand, being those which affect the structure of the filesystem.
+	treeFlags = []string{
+		"archive-marker",
+		"dedup-identical",
+		"detect-sfx",
+		"dotfile-flat-style",
+		"empty-archive-as",
+		"expose-raw",
+		"flat-deref-symlinks",
+		"flatten-collisions",
+		"flatten-zips",
+		"force-unicode",
+		"hide-hidden",
+		"max-list-entries",
+		"nested-conflicts",
+		"only-ext",
+		"real-sort",
+		"real-sort-dirs-first",
+		"size-mode",
+		"tail",
+		"tail-window",
+		"unicode-normalize",
+	}
 )
 
 // cliOptions describes all configurables of the command-line interface.
 type cliOptions struct {
 	allowOther         bool
+	allowRawNameLookup bool
+	allowXattrControl  bool
+	allowedUIDs        []uint32
+	allowedUIDsRaw     string
+	archiveMarker      bool
+	archiveXattrs      bool
+	archivesFrom       string
+	breakerCooldown    time.Duration
+	breakerThreshold   int
+	breakerWindow      time.Duration
+	cacheDirEntries    bool
+	cacheFileContent   bool
+	continueOnError    bool
+	dedupIdentical     bool
+	detectSFX          bool
+	directIO           bool
+	dirSizes           bool
+	dotfileStyle       filesystem.DotfileFlatStyle
+	dotfileStyleRaw    string
 	dryRun             bool
+	emptyArchiveAs     filesystem.EmptyArchiveMode
+	emptyArchiveAsRaw  string
+	entry              string
+	exposeRaw          bool
 	fdCacheBypass      bool
 	fdCacheSize        int
 	fdCacheTTL         time.Duration
 	fdLimit            int
+	fixedMtime         time.Time
+	fixedMtimeRaw      string
+	flatCollisions     filesystem.FlatCollisionStrategy
+	flatCollisionsRaw  string
+	flatDerefSymlinks  bool
 	flatMode           bool
 	forceUnicode       bool
+	fsName             string
 	fuseVerbose        bool
+	hideHidden         bool
+	idleTimeout        time.Duration
+	jsonLogFile        string
+	logFile            string
+	logKeep            int
+	logMaxSize         uint64
+	logMaxSizeRaw      string
+	logRepeatWindow    time.Duration
+	maxInMemory        uint64
+	maxInMemoryRaw     string
+	maxListEntries     int
+	maxReadahead       uint64
+	maxReadaheadRaw    string
+	maxTotalInMemory   uint64
+	maxTotalInMemRaw   string
+	metadataOnly       bool
 	mountDir           string
 	mustCRC32          bool
+	nestedConflicts    filesystem.NestedConflictStrategy
+	nestedConflictsRaw string
+	noPreflight        bool
+	nonEmpty           bool
+	onlyExt            []string
+	onlyExtRaw         string
+	passwordFile       string
+	passwordPrompt     bool
+	passwords          *filesystem.GlobPasswords
+	pinGlobs           []string
+	pinGlobsRaw        string
+	preserveOwnership  bool
+	preservePerms      bool
+	realSort           filesystem.RealSortOrder
+	realSortDirsFirst  bool
+	realSortRaw        string
 	ringBufferSize     int
+	signalUSR1         string
+	sizeMode           filesystem.SizeMode
+	sizeModeRaw        string
 	sourceDir          string
 	streamPoolSize     uint64
 	streamPoolSizeRaw  string
+	streamRetries      int
 	streamThreshold    uint64
 	streamThresholdRaw string
 	strictCache        bool
-	webserverAddr      string
+	subtype            string
+	tailMode           bool
+	tailWindow         time.Duration
+	thresholdRules     []filesystem.ThresholdRule
+	thresholdRulesFile string
+	timestampTZ        filesystem.TimestampZone
+	timestampTZRaw     string
+	traceSample        float64
+	unicodeNorm        filesystem.UnicodeNormalization
+	unicodeNormRaw     string
+	verifyOnMount      bool
+	walkConcurrency    int
+	walkTimeout        time.Duration
+	webserverAddrs     []string
+	webserverSecret    string
+	webserverDenyUA    string
+	webserverListeners []webserver.Listener
+	webserverOptions   webserver.ServeOptions
 }
 
 // rootCmd is the principal implementation of the command-line interface.
@@ -115,49 +315,729 @@ func rootCmd() *cobra.Command {
 		Long:    helpTextLong,
 		Version: Version,
 		Args:    cobra.ExactArgs(2),
-		RunE: func(_ *cobra.Command, args []string) error {
-			if opts.fdLimit <= opts.fdCacheSize {
-				return fmt.Errorf("%w: fd-limit cannot be <= fd-cache-size", errInvalidArgument)
-			}
-			opts.streamThreshold, err = humanize.ParseBytes(opts.streamThresholdRaw)
-			if err != nil {
-				return fmt.Errorf("%w: failed to parse --stream-threshold: %w", errInvalidArgument, err)
-			}
-			opts.streamPoolSize, err = humanize.ParseBytes(opts.streamPoolSizeRaw)
-			if err != nil {
-				return fmt.Errorf("%w: failed to parse --pool-buffer-size: %w", errInvalidArgument, err)
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := opts.parse(); err != nil {
+				return err
 			}
 			opts.sourceDir = args[0]
 			opts.mountDir = args[1]
+			opts.webserverOptions.Config = flagConfig(cmd.Flags())
 
 			return run(opts)
 		},
 	}
 	cmd.PersistentFlags().BoolP("version", "", false, "version for zipfuse") // removes -v shorthand
 
+	cmd.Flags().BoolVar(&opts.allowRawNameLookup, "allow-raw-name-lookup", false, "Allow looking up files within ZIPs by their raw (stored) name, if normalized differently")
+	cmd.Flags().BoolVar(&opts.allowXattrControl, "allow-xattr-control", false, "Allow refreshing a ZIP by writing the 'user.zipfuse.refresh' xattr on its directory")
+	cmd.Flags().BoolVar(&opts.archiveMarker, "archive-marker", false, "Present a synthetic '.archive-info' file (summary, archive mtime) within each ZIP (nested mode)")
+	cmd.Flags().BoolVar(&opts.archiveXattrs, "archive-xattrs", false, "Expose the backing ZIP and in-ZIP path of nodes as 'user.zipfuse.archive' and 'user.zipfuse.entry' xattrs")
+	cmd.Flags().BoolVar(&opts.cacheDirEntries, "cache-dir-entries", true, "Allow the kernel to keep listings of directories within ZIPs cached (unless --strict-cache)")
+	cmd.Flags().BoolVar(&opts.cacheFileContent, "cache-file-content", true, "Allow the kernel to keep contents of files within ZIPs cached (unless --strict-cache)")
+	cmd.Flags().BoolVar(&opts.continueOnError, "continue-on-error", false, "Continue walking (dry-run, verify-on-mount) past unreadable or hanging nodes, summarizing the failures")
+	cmd.Flags().BoolVar(&opts.dedupIdentical, "dedup-identical", false, "Only present the first of files within a ZIP having identical content (same CRC-32 and size)")
+	cmd.Flags().BoolVar(&opts.detectSFX, "detect-sfx", false, "Sniff files without a .zip extension for an appended ZIP (self-extracting), presenting these by their full name")
+	cmd.Flags().BoolVar(&opts.directIO, "direct-io", false, "Open ZIPs with O_DIRECT (where supported), so that reading them bypasses the page cache")
+	cmd.Flags().BoolVar(&opts.dirSizes, "dir-sizes", false, "Report the total size of contained files for directories within ZIPs (opens ZIPs on stat)")
+	cmd.Flags().BoolVar(&opts.exposeRaw, "expose-raw", false, "Present a synthetic '.raw' directory within each ZIP with the raw (compressed) bytes of its files")
 	cmd.Flags().BoolVar(&opts.fdCacheBypass, "fd-cache-bypass", false, "Bypass the FD cache; (re-)opens and closes file descriptors on every request")
+	cmd.Flags().BoolVar(&opts.flatDerefSymlinks, "flat-deref-symlinks", false, "Flat mode presents symlinks within ZIPs with the content of their targets (hiding those not within the ZIP)")
 	cmd.Flags().BoolVar(&opts.forceUnicode, "force-unicode", true, "Unicode (or generated) paths for ZIPs; disabling garbles non-compliant ZIPs")
+	cmd.Flags().BoolVar(&opts.hideHidden, "hide-hidden", false, "Do not present hidden directories and ZIPs (names starting with \".\") within the source directories")
+	cmd.Flags().BoolVar(&opts.metadataOnly, "metadata-only", false, "Only present files within ZIPs, never allowing them to be opened (no extraction)")
 	cmd.Flags().BoolVar(&opts.mustCRC32, "must-crc32", false, "Force integrity verification on non-compressed ZIP files also (at performance cost)")
+	cmd.Flags().BoolVar(&opts.noPreflight, "no-preflight", false, "Skip checking that FUSE is usable (device, permissions, fuse.conf) before mounting")
+	cmd.Flags().BoolVar(&opts.nonEmpty, "nonempty", false, "Allow mounting over a non-empty directory (hiding its contents while mounted)")
+	cmd.Flags().BoolVar(&opts.passwordPrompt, "password-prompt", false, "Prompt for a password (on the terminal) to decrypt ZipCrypto-encrypted files within all other ZIPs")
+	cmd.Flags().BoolVar(&opts.preserveOwnership, "preserve-ownership", false, "Report the owner UID/GID stored within ZIP files (if present) for their files")
+	cmd.Flags().BoolVar(&opts.preservePerms, "preserve-permissions", false, "Report the execute bits of the modes stored within ZIP files for their files (e.g. 0755 as 0555)")
+	cmd.Flags().BoolVar(&opts.realSortDirsFirst, "real-sort-dirs-first", false, "List directories before ZIPs within the source directories (each group ordered by --real-sort)")
+	cmd.Flags().BoolVar(&opts.tailMode, "tail", false, "Present ZIPs still being written (recently modified, but invalid) as empty directories")
 	cmd.Flags().BoolVar(&opts.strictCache, "strict-cache", false, "Do not treat ZIP files/contents as immutable (non-changing) for caching decisions")
+	cmd.Flags().BoolVar(&opts.verifyOnMount, "verify-on-mount", false, "Open all ZIPs before mounting, failing with a list of unreadable ones (slow for huge trees)")
+	cmd.Flags().BoolVar(&opts.webserverOptions.ServeArchives, "webserver-archives", false, "Serve the backing files of ZIPs as a whole on the diagnostics dashboard (\"/archive\")")
+	cmd.Flags().BoolVar(&opts.webserverOptions.BundleHashPaths, "webserver-bundle-hash-paths", false, "Contain only hashes of the paths of ZIPs within the support bundle of the dashboard (\"/support-bundle\")")
+	cmd.Flags().BoolVar(&opts.webserverOptions.ReadOnly, "webserver-readonly", false, "Serve the diagnostics dashboard without any routes that change runtime behavior")
 	cmd.Flags().BoolVarP(&opts.allowOther, "allow-other", "a", allowOther, "Allow other users to access the filesystem")
 	cmd.Flags().BoolVarP(&opts.dryRun, "dry-run", "d", false, "Do not mount, but print all would-be inodes and paths to standard output (stdout)")
 	cmd.Flags().BoolVarP(&opts.flatMode, "flatten-zips", "f", false, "Flatten ZIP-contained subdirectories and their files into one directory per ZIP")
 	cmd.Flags().BoolVarP(&opts.fuseVerbose, "verbose", "v", false, "Print all verbose FUSE communication and diagnostics to standard error (stderr)")
+	cmd.Flags().DurationVar(&opts.breakerCooldown, "breaker-cooldown", 30*time.Second, "Time to reject opening of consistently-failing ZIP files (once tripped)")
+	cmd.Flags().DurationVar(&opts.breakerWindow, "breaker-window", 60*time.Second, "Time window in which consecutive failures to open a ZIP file are counted")
 	cmd.Flags().DurationVar(&opts.fdCacheTTL, "fd-cache-ttl", 60*time.Second, "Time-to-live before FD cache evicts unused open file descriptors")
+	cmd.Flags().DurationVar(&opts.idleTimeout, "idle-timeout", 0, "Time without any requests after which the filesystem unmounts itself (0 to disable)")
+	cmd.Flags().DurationVar(&opts.logRepeatWindow, "log-repeat-window", 60*time.Second, "Window for logging identical events once, then as \"(repeated N times)\" (0 to disable)")
+	cmd.Flags().DurationVar(&opts.tailWindow, "tail-window", 5*time.Minute, "Time since last modification a ZIP is considered still being written (with --tail)")
+	cmd.Flags().DurationVar(&opts.walkTimeout, "walk-timeout", 0, "Time after which a hanging node (e.g. ZIP on stuck storage) is abandoned during walks (0 to disable)")
+	cmd.Flags().DurationVar(&opts.webserverOptions.IdleTimeout, "webserver-idle-timeout", 60*time.Second, "Time the diagnostics dashboard waits for the next request (keep-alive)")
+	cmd.Flags().DurationVar(&opts.webserverOptions.ReadHeaderTimeout, "webserver-read-header-timeout", 5*time.Second, "Time the diagnostics dashboard allows for reading request headers")
+	cmd.Flags().DurationVar(&opts.webserverOptions.ReadTimeout, "webserver-read-timeout", 10*time.Second, "Time the diagnostics dashboard allows for reading an entire request")
+	cmd.Flags().DurationVar(&opts.webserverOptions.WriteTimeout, "webserver-write-timeout", 30*time.Second, "Time the diagnostics dashboard allows for writing an entire response")
+	cmd.Flags().Float64Var(&opts.traceSample, "trace-sample", 0, "Fraction of ZIP operations to emit trace lines for (e.g. 0.01 for 1%; 0 to disable)")
+	cmd.Flags().IntVar(&opts.breakerThreshold, "breaker-threshold", 5, "Consecutive failures to open a ZIP file before rejecting it (0 to disable)")
 	cmd.Flags().IntVar(&opts.fdCacheSize, "fd-cache-size", cacheLimit, "Max number of open file descriptors in the FD cache (must be < fd-limit)")
 	cmd.Flags().IntVar(&opts.fdLimit, "fd-limit", fsLimit, "Limit of total open file descriptors (> fd-cache-size; beware OS limits)")
-	cmd.Flags().IntVar(&opts.ringBufferSize, "ring-buffer-size", 500, "Buffer lines for the event ring-buffer (displayed in diagnostics dashboard)")
+	cmd.Flags().IntVar(&opts.logKeep, "log-keep", 3, "Number of rotated log files to keep next to --log-file/--json-log-file (0 to only truncate)")
+	cmd.Flags().IntVar(&opts.maxListEntries, "max-list-entries", 0, "Truncate listings of directories within ZIPs after this many entries (0 to disable)")
+	cmd.Flags().IntVar(&opts.ringBufferSize, "ring-buffer-size", 500, "Buffer lines for the event ring-buffer (displayed in diagnostics dashboard; 0 to disable)")
+	cmd.Flags().IntVar(&opts.streamRetries, "stream-retries", 2, "Attempts to re-open a streamed file within a ZIP after a transient read error (0 to disable)")
+	cmd.Flags().IntVar(&opts.walkConcurrency, "walk-concurrency", 1, "Amount of ZIPs to walk (dry-run) or open (verify-on-mount) concurrently (bounded by fd-limit)")
+	cmd.Flags().StringVar(&opts.allowedUIDsRaw, "allowed-uids", "", "Only allow clients of these UIDs to access the filesystem (separated by \",\" or \":\"; e.g. 1000,1001)")
+	cmd.Flags().StringVar(&opts.archivesFrom, "archives-from", "", "Only dry-run these archives, read line by line from a file (or \"-\" for standard input)")
+	cmd.Flags().StringVar(&opts.dotfileStyleRaw, "dotfile-flat-style", "prefix", "Flat mode naming of dotfiles; \"prefix\" indexes as (1).gitignore, \"suffix\" as .gitignore(1)")
+	cmd.Flags().StringVar(&opts.emptyArchiveAsRaw, "empty-archive-as", "dir", "Present ZIPs without any entries as an empty \"dir\", \"hidden\" (not at all) or as a \"file\" (the ZIP itself)")
+	cmd.Flags().StringVar(&opts.entry, "entry", "", "Serve only this file within the single source archive as the whole mount (mountpoint being a file)")
+	cmd.Flags().StringVar(&opts.fixedMtimeRaw, "fixed-mtime", "", "Report this RFC3339 timestamp for all files and folders (instead of the real ones)")
+	cmd.Flags().StringVar(&opts.flatCollisionsRaw, "flatten-collisions", "index", "Flat mode naming; \"index\" suffixes all files, \"dir\" prepends parent directory on collision")
+	cmd.Flags().StringVar(&opts.fsName, "fsname", "zipfuse", "Name of the filesystem (mount source) shown by mount(8), for telling apart multiple mounts")
+	cmd.Flags().StringVar(&opts.jsonLogFile, "json-log-file", "", "Also write all events as JSON (one per line) to this file, rotating as with --log-file")
+	cmd.Flags().StringVar(&opts.logFile, "log-file", "", "Also write all events to this file, rotating it once exceeding --log-max-size")
+	cmd.Flags().StringVar(&opts.logMaxSizeRaw, "log-max-size", "10MiB", "Size cutoff for rotating the --log-file/--json-log-file (keeping --log-keep rotated files)")
+	cmd.Flags().StringVar(&opts.maxInMemoryRaw, "max-in-memory", "0", "Reject files within ZIPs larger than this to be loaded into RAM (EFBIG; 0 to disable)")
+	cmd.Flags().StringVar(&opts.maxTotalInMemRaw, "max-total-in-memory", "0", "Stream files within ZIPs instead, once those loaded into RAM exceed this in total (0 to disable)")
+	cmd.Flags().StringVar(&opts.maxReadaheadRaw, "max-readahead", "", "Max kernel readahead for files within ZIPs (4KiB to 16MiB; defaults to --stream-pool-size)")
+	cmd.Flags().StringVar(&opts.nestedConflictsRaw, "nested-conflicts", "dir", "Nested mode naming; \"dir\" or \"file\" wins if a name within a ZIP is both (malformed ZIPs)")
+	cmd.Flags().StringVar(&opts.onlyExtRaw, "only-ext", "", "Only present files within ZIPs with these extensions (separated by \",\" or \":\"; e.g. jpg,png,mp4)")
+	cmd.Flags().StringVar(&opts.passwordFile, "password-file", "", "Decrypt ZipCrypto-encrypted files within ZIPs with the passwords (per glob of ZIPs) of a JSON file")
+	cmd.Flags().StringVar(&opts.pinGlobsRaw, "pin-glob", "", "Never evict ZIPs matching these globs from the FD cache (separated by \",\" or \":\"; e.g. hot/*.zip)")
+	cmd.Flags().StringVar(&opts.realSortRaw, "real-sort", "name", "Order of directories and ZIPs within the source directories; \"name\", \"mtime\" or \"size\" (newest/largest first)")
+	cmd.Flags().StringVar(&opts.signalUSR1, "signal-usr1", "gc", "Action on receiving SIGUSR1; \"gc\" forces a garbage collection, \"purge-cache\" releases all ZIPs from the FD cache")
+	cmd.Flags().StringVar(&opts.sizeModeRaw, "size-mode", "uncompressed", "Report the \"uncompressed\" or \"compressed\" sizes of files within ZIPs (the latter reading with direct I/O)")
 	cmd.Flags().StringVar(&opts.streamPoolSizeRaw, "stream-pool-size", "128KiB", "Buffer size for the streamed read buffer pool (beware this multiplies)")
+	cmd.Flags().StringVar(&opts.subtype, "subtype", "", "Subtype of the filesystem shown by mount(8) as the type \"fuse.<subtype>\" (empty for \"fuse\")")
+	cmd.Flags().StringVar(&opts.thresholdRulesFile, "threshold-rules", "", "Decide RAM or streaming per file within ZIPs by the rules (extension/size) of a JSON file")
+	cmd.Flags().StringVar(&opts.timestampTZRaw, "timestamp-tz", "utc", "Interpret timestamps within ZIPs lacking a timezone (MS-DOS) as \"utc\" or \"local\" time of the host")
+	cmd.Flags().StringVar(&opts.unicodeNormRaw, "unicode-normalize", "none", "Normalize names within ZIPs into \"nfc\" or \"nfd\" form, for listings and lookups alike (or \"none\")")
+	cmd.Flags().StringVar(&opts.webserverSecret, "webserver-archives-secret", "", "Only serve ZIPs on \"/archive\" by links signed (see \"sign-link\") with the secret within this file")
+	cmd.Flags().StringVar(&opts.webserverDenyUA, "webserver-deny-ua", "", "Reject dashboard requests with a User-Agent matching this regular expression (403)")
 	cmd.Flags().StringVarP(&opts.streamThresholdRaw, "stream-threshold", "s", "1MiB", "Size cutoff for loading a file fully into RAM (streaming instead)")
-	cmd.Flags().StringVarP(&opts.webserverAddr, "webserver", "w", "", "Address to serve the diagnostics dashboard on (e.g. :8000; but disabled when empty)")
+	cmd.Flags().StringArrayVarP(&opts.webserverAddrs, "webserver", "w", nil, "Address to serve the diagnostics dashboard on (e.g. :8000 or 127.0.0.1:8000@readonly; repeatable)")
+
+	cmd.CompletionOptions.DisableDefaultCmd = true
+	cmd.AddCommand(treeCmd(&opts, cmd))
+	cmd.AddCommand(changedCmd(&opts, cmd))
+	cmd.AddCommand(verifyManifestCmd(&opts, cmd))
+	cmd.AddCommand(catCmd(&opts, cmd))
+	cmd.AddCommand(diffCmd(&opts, cmd))
+	cmd.AddCommand(signLinkCmd(cmd))
+	cmd.AddCommand(supportBundleCmd())
+
+	return cmd
+}
+
+// treeCmd is the implementation of the "tree" subcommand of the command-line
+// interface. It shares the flags that affect the presented structure with the
+// root command, so that the tree is rendered as the filesystem would be mounted.
+func treeCmd(opts *cliOptions, root *cobra.Command) *cobra.Command {
+	var depth int
+
+	cmd := &cobra.Command{
+		Use:   helpTextTreeUse,
+		Short: helpTextTreeShort,
+		Long:  helpTextTreeLong,
+		Args:  cobra.ExactArgs(1),
+		RunE: func(_ *cobra.Command, args []string) error {
+			if depth < 0 {
+				return fmt.Errorf("%w: --depth cannot be < 0", errInvalidArgument)
+			}
+			if err := opts.parse(); err != nil {
+				return err
+			}
+			opts.sourceDir = args[0]
+
+			return runTree(*opts, depth)
+		},
+	}
+
+	cmd.Flags().IntVar(&depth, "depth", 0, "Limit the tree to this many levels below the source (0 for unlimited)")
+	for _, name := range treeFlags {
+		cmd.Flags().AddFlag(root.Flags().Lookup(name))
+	}
+
+	return cmd
+}
+
+// changedCmd is the implementation of the "changed" subcommand of the
+// command-line interface. It shares the flags that affect the presented
+// structure with the root command, so that the paths are as they would be mounted.
+func changedCmd(opts *cliOptions, root *cobra.Command) *cobra.Command {
+	var sinceRaw string
+	var limit int
+	var timeout time.Duration
+
+	cmd := &cobra.Command{
+		Use:   helpTextChangedUse,
+		Short: helpTextChangedShort,
+		Long:  helpTextChangedLong,
+		Args:  cobra.ExactArgs(1),
+		RunE: func(_ *cobra.Command, args []string) error {
+			since, err := time.Parse(time.RFC3339, sinceRaw)
+			if err != nil {
+				return fmt.Errorf("%w: failed to parse --since: %w", errInvalidArgument, err)
+			}
+			if limit < 0 || timeout < 0 {
+				return fmt.Errorf("%w: --limit and --timeout cannot be < 0", errInvalidArgument)
+			}
+			if err := opts.parse(); err != nil {
+				return err
+			}
+			opts.sourceDir = args[0]
+
+			return runChanged(*opts, since, limit, timeout)
+		},
+	}
+
+	cmd.Flags().StringVar(&sinceRaw, "since", "", "Print only files modified after this time (RFC3339)")
+	cmd.Flags().IntVar(&limit, "limit", 0, "Stop after printing this many files (0 for unlimited)")
+	cmd.Flags().DurationVar(&timeout, "timeout", 0, "Stop reading the archives after this time (0 for unlimited)")
+	_ = cmd.MarkFlagRequired("since")
+
+	for _, name := range append([]string{"timestamp-tz"}, treeFlags...) {
+		cmd.Flags().AddFlag(root.Flags().Lookup(name))
+	}
+
+	return cmd
+}
+
+// verifyManifestCmd is the implementation of the "verify-manifest" subcommand
+// of the command-line interface. It shares the flags that affect the presented
+// structure with the root command, so that the paths are resolved as mounted,
+// and those deciding how files are read (e.g. --stream-threshold) also.
+func verifyManifestCmd(opts *cliOptions, root *cobra.Command) *cobra.Command {
+	var parallel int
+
+	cmd := &cobra.Command{
+		Use:   helpTextVerifyManifestUse,
+		Short: helpTextVerifyManifestShort,
+		Long:  helpTextVerifyManifestLong,
+		Args:  cobra.ExactArgs(2), //nolint:mnd
+		RunE: func(_ *cobra.Command, args []string) error {
+			if parallel < 1 {
+				return fmt.Errorf("%w: --parallel cannot be < 1", errInvalidArgument)
+			}
+			if err := opts.parse(); err != nil {
+				return err
+			}
+			opts.sourceDir = args[0]
+
+			return runVerifyManifest(*opts, args[1], parallel)
+		},
+	}
+
+	cmd.Flags().IntVar(&parallel, "parallel", 1, "Amount of files to read and hash concurrently")
+	for _, name := range append([]string{"must-crc32", "stream-threshold"}, treeFlags...) {
+		cmd.Flags().AddFlag(root.Flags().Lookup(name))
+	}
+
+	return cmd
+}
+
+// catCmd is the "cat" subcommand, extracting a file within an archive to stdout.
+//
+//nolint:mnd
+func catCmd(opts *cliOptions, root *cobra.Command) *cobra.Command {
+	var offset int64
+	var length int64
+
+	cmd := &cobra.Command{
+		Use:   helpTextCatUse,
+		Short: helpTextCatShort,
+		Long:  helpTextCatLong,
+		Args:  cobra.ExactArgs(2),
+		RunE: func(_ *cobra.Command, args []string) error {
+			if offset < 0 {
+				return fmt.Errorf("%w: --offset cannot be < 0", errInvalidArgument)
+			}
+			if err := opts.parse(); err != nil {
+				return err
+			}
+			opts.sourceDir = args[0]
+
+			return runCat(*opts, args[1], offset, length)
+		},
+	}
+
+	cmd.Flags().Int64Var(&offset, "offset", 0, "Byte offset within the file to start extracting at")
+	cmd.Flags().Int64Var(&length, "length", -1, "Amount of bytes to extract at most (-1 for until the end)")
+	for _, name := range []string{"force-unicode", "must-crc32", "password-file", "password-prompt", "unicode-normalize"} {
+		cmd.Flags().AddFlag(root.Flags().Lookup(name))
+	}
+
+	return cmd
+}
+
+// diffCmd is the implementation of the "diff" subcommand of the command-line
+// interface. It shares the flags that affect the presented structure with the
+// root command, so that both sides are compared in the same (e.g. flat) form.
+func diffCmd(opts *cliOptions, root *cobra.Command) *cobra.Command {
+	var asJSON bool
+	var ignoreMtime bool
+
+	cmd := &cobra.Command{
+		Use:   helpTextDiffUse,
+		Short: helpTextDiffShort,
+		Long:  helpTextDiffLong,
+		Args:  cobra.ExactArgs(2), //nolint:mnd
+		RunE: func(_ *cobra.Command, args []string) error {
+			if err := opts.parse(); err != nil {
+				return err
+			}
+
+			return runDiff(*opts, args[0], args[1], asJSON, ignoreMtime)
+		},
+	}
+
+	cmd.Flags().BoolVar(&asJSON, "json", false, "Print the differences as one JSON object per line (instead of a report)")
+	cmd.Flags().BoolVar(&ignoreMtime, "ignore-mtime", false, "Do not compare the modification times of files (only sizes and CRC-32)")
+	for _, name := range append([]string{"password-file", "password-prompt", "timestamp-tz"}, treeFlags...) {
+		cmd.Flags().AddFlag(root.Flags().Lookup(name))
+	}
+
+	return cmd
+}
+
+// signLinkCmd is the implementation of the "sign-link" subcommand of the
+// command-line interface. It shares the --webserver-archives-secret with the
+// root command, so that the links are signed as the dashboard verifies them.
+func signLinkCmd(root *cobra.Command) *cobra.Command {
+	var expires time.Duration
+
+	cmd := &cobra.Command{
+		Use:   helpTextSignLinkUse,
+		Short: helpTextSignLinkShort,
+		Long:  helpTextSignLinkLong,
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if expires <= 0 {
+				return fmt.Errorf("%w: --expires must be > 0", errInvalidArgument)
+			}
+
+			secret, err := readSecret(cmd.Flags().Lookup("webserver-archives-secret").Value.String())
+			if err != nil {
+				return fmt.Errorf("%w: failed to read --webserver-archives-secret: %w", errInvalidArgument, err)
+			}
+
+			fmt.Fprintln(os.Stdout, webserver.SignArchiveLink(secret, args[0], time.Now().Add(expires)))
+
+			return nil
+		},
+	}
+
+	cmd.Flags().DurationVar(&expires, "expires", 24*time.Hour, "Time after which the link is no longer valid") //nolint:mnd
+	cmd.Flags().AddFlag(root.Flags().Lookup("webserver-archives-secret"))
+	_ = cmd.MarkFlagRequired("webserver-archives-secret")
+
+	return cmd
+}
+
+// supportBundleCmd is the implementation of the "support-bundle" subcommand
+// of the command-line interface. It fetches the support bundle of a mounted
+// filesystem from its dashboard, so it needs no other flags of the root command.
+func supportBundleCmd() *cobra.Command {
+	var hashPaths bool
+	var output string
+
+	cmd := &cobra.Command{
+		Use:   helpTextSupportBundleUse,
+		Short: helpTextSupportBundleShort,
+		Long:  helpTextSupportBundleLong,
+		Args:  cobra.ExactArgs(1),
+		RunE: func(_ *cobra.Command, args []string) error {
+			name, err := fetchSupportBundle(args[0], hashPaths, output)
+			if err != nil {
+				return err
+			}
+			if name != "-" {
+				fmt.Fprintf(os.Stderr, "Support bundle written to %s\n", name)
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&hashPaths, "hash-paths", false, "Request only hashes of the paths of ZIPs within the support bundle")
+	cmd.Flags().StringVarP(&output, "output", "o", "", "File to write the support bundle to (\"-\" for stdout; empty for the name given by the dashboard)")
 
 	return cmd
 }
 
+// parse validates the [cliOptions] as set by the flags, also parsing all of
+// the raw values (e.g. sizes) into the fields which are consumed by the program.
+func (opts *cliOptions) parse() error {
+	var err error
+
+	if opts.fdLimit <= opts.fdCacheSize {
+		return fmt.Errorf("%w: fd-limit cannot be <= fd-cache-size", errInvalidArgument)
+	}
+	opts.streamThreshold, err = humanize.ParseBytes(opts.streamThresholdRaw)
+	if err != nil {
+		return fmt.Errorf("%w: failed to parse --stream-threshold: %w", errInvalidArgument, err)
+	}
+	opts.streamPoolSize, err = humanize.ParseBytes(opts.streamPoolSizeRaw)
+	if err != nil {
+		return fmt.Errorf("%w: failed to parse --pool-buffer-size: %w", errInvalidArgument, err)
+	}
+	opts.maxInMemory, err = humanize.ParseBytes(opts.maxInMemoryRaw)
+	if err != nil {
+		return fmt.Errorf("%w: failed to parse --max-in-memory: %w", errInvalidArgument, err)
+	}
+	opts.maxTotalInMemory, err = humanize.ParseBytes(opts.maxTotalInMemRaw)
+	if err != nil {
+		return fmt.Errorf("%w: failed to parse --max-total-in-memory: %w", errInvalidArgument, err)
+	}
+	opts.maxReadahead = opts.streamPoolSize
+	if opts.maxReadaheadRaw != "" {
+		opts.maxReadahead, err = humanize.ParseBytes(opts.maxReadaheadRaw)
+		if err != nil {
+			return fmt.Errorf("%w: failed to parse --max-readahead: %w", errInvalidArgument, err)
+		}
+		if opts.maxReadahead < minMaxReadahead || opts.maxReadahead > maxMaxReadahead {
+			return fmt.Errorf("%w: --max-readahead must be between %s and %s", errInvalidArgument,
+				humanize.IBytes(minMaxReadahead), humanize.IBytes(maxMaxReadahead))
+		}
+	}
+	opts.logMaxSize, err = humanize.ParseBytes(opts.logMaxSizeRaw)
+	if err != nil {
+		return fmt.Errorf("%w: failed to parse --log-max-size: %w", errInvalidArgument, err)
+	}
+	if opts.logMaxSize == 0 || opts.logKeep < 0 {
+		return fmt.Errorf("%w: --log-max-size must be > 0 and --log-keep must be >= 0", errInvalidArgument)
+	}
+	if opts.fixedMtimeRaw != "" {
+		opts.fixedMtime, err = time.Parse(time.RFC3339, opts.fixedMtimeRaw)
+		if err != nil {
+			return fmt.Errorf("%w: failed to parse --fixed-mtime: %w", errInvalidArgument, err)
+		}
+	}
+	switch opts.dotfileStyleRaw {
+	case "prefix":
+		opts.dotfileStyle = filesystem.DotfileFlatPrefix
+	case "suffix":
+		opts.dotfileStyle = filesystem.DotfileFlatSuffix
+	default:
+		return fmt.Errorf("%w: --dotfile-flat-style must be \"prefix\" or \"suffix\"", errInvalidArgument)
+	}
+	switch opts.emptyArchiveAsRaw {
+	case "dir":
+		opts.emptyArchiveAs = filesystem.EmptyArchiveDir
+	case "hidden":
+		opts.emptyArchiveAs = filesystem.EmptyArchiveHidden
+	case "file":
+		opts.emptyArchiveAs = filesystem.EmptyArchiveFile
+	default:
+		return fmt.Errorf("%w: --empty-archive-as must be \"dir\", \"hidden\" or \"file\"", errInvalidArgument)
+	}
+	switch opts.flatCollisionsRaw {
+	case "index":
+		opts.flatCollisions = filesystem.FlatCollisionIndex
+	case "dir":
+		opts.flatCollisions = filesystem.FlatCollisionDirectory
+	default:
+		return fmt.Errorf("%w: --flatten-collisions must be \"index\" or \"dir\"", errInvalidArgument)
+	}
+	switch opts.nestedConflictsRaw {
+	case "dir":
+		opts.nestedConflicts = filesystem.NestedConflictDirectory
+	case "file":
+		opts.nestedConflicts = filesystem.NestedConflictFile
+	default:
+		return fmt.Errorf("%w: --nested-conflicts must be \"dir\" or \"file\"", errInvalidArgument)
+	}
+	switch opts.realSortRaw {
+	case "name":
+		opts.realSort = filesystem.RealSortName
+	case "mtime":
+		opts.realSort = filesystem.RealSortMtime
+	case "size":
+		opts.realSort = filesystem.RealSortSize
+	default:
+		return fmt.Errorf("%w: --real-sort must be \"name\", \"mtime\" or \"size\"", errInvalidArgument)
+	}
+	switch opts.signalUSR1 {
+	case signalUSR1GC, signalUSR1PurgeCache:
+	default:
+		return fmt.Errorf("%w: --signal-usr1 must be \"gc\" or \"purge-cache\"", errInvalidArgument)
+	}
+	switch opts.sizeModeRaw {
+	case "uncompressed":
+		opts.sizeMode = filesystem.SizeUncompressed
+	case "compressed":
+		opts.sizeMode = filesystem.SizeCompressed
+	default:
+		return fmt.Errorf("%w: --size-mode must be \"uncompressed\" or \"compressed\"", errInvalidArgument)
+	}
+	switch opts.timestampTZRaw {
+	case "utc":
+		opts.timestampTZ = filesystem.TimestampUTC
+	case "local":
+		opts.timestampTZ = filesystem.TimestampLocal
+	default:
+		return fmt.Errorf("%w: --timestamp-tz must be \"utc\" or \"local\"", errInvalidArgument)
+	}
+	switch opts.unicodeNormRaw {
+	case "none":
+		opts.unicodeNorm = filesystem.UnicodeNormalizeNone
+	case "nfc":
+		opts.unicodeNorm = filesystem.UnicodeNormalizeNFC
+	case "nfd":
+		opts.unicodeNorm = filesystem.UnicodeNormalizeNFD
+	default:
+		return fmt.Errorf("%w: --unicode-normalize must be \"none\", \"nfc\" or \"nfd\"", errInvalidArgument)
+	}
+	if opts.traceSample < 0 || opts.traceSample > 1 {
+		return fmt.Errorf("%w: --trace-sample must be between 0 and 1", errInvalidArgument)
+	}
+	if opts.idleTimeout < 0 {
+		return fmt.Errorf("%w: idle-timeout cannot be < 0", errInvalidArgument)
+	}
+	if opts.streamRetries < 0 {
+		return fmt.Errorf("%w: stream-retries cannot be < 0", errInvalidArgument)
+	}
+	if opts.walkConcurrency < 1 {
+		return fmt.Errorf("%w: walk-concurrency cannot be < 1", errInvalidArgument)
+	}
+	if opts.walkTimeout < 0 {
+		return fmt.Errorf("%w: walk-timeout cannot be < 0", errInvalidArgument)
+	}
+	if opts.ringBufferSize < 0 {
+		return fmt.Errorf("%w: ring-buffer-size cannot be < 0", errInvalidArgument)
+	}
+	if opts.fsName == "" || strings.Contains(opts.fsName, ",") {
+		return fmt.Errorf("%w: --fsname must be non-empty and cannot contain \",\"", errInvalidArgument)
+	}
+	if strings.ContainsAny(opts.subtype, ",.") {
+		return fmt.Errorf("%w: --subtype cannot contain \",\" or \".\"", errInvalidArgument)
+	}
+	opts.onlyExt = splitList(opts.onlyExtRaw)
+	opts.allowedUIDs = nil
+	for _, raw := range splitList(opts.allowedUIDsRaw) {
+		uid, err := strconv.ParseUint(raw, 10, 32)
+		if err != nil {
+			return fmt.Errorf("%w: failed to parse --allowed-uids: %w", errInvalidArgument, err)
+		}
+		opts.allowedUIDs = append(opts.allowedUIDs, uint32(uid))
+	}
+	opts.pinGlobs = splitList(opts.pinGlobsRaw)
+	if len(opts.pinGlobs) > 0 && opts.fdLimit <= opts.fdCacheSize+1 {
+		return fmt.Errorf("%w: fd-limit must be > fd-cache-size + 1 with --pin-glob", errInvalidArgument)
+	}
+	if opts.thresholdRulesFile != "" {
+		opts.thresholdRules, err = readThresholdRules(opts.thresholdRulesFile)
+		if err != nil {
+			return fmt.Errorf("failed to read --threshold-rules: %w", err)
+		}
+	}
+	if opts.passwordFile != "" {
+		opts.passwords, err = readPasswords(opts.passwordFile)
+		if err != nil {
+			return fmt.Errorf("failed to read --password-file: %w", err)
+		}
+	}
+	password := os.Getenv(passwordEnv)
+	if opts.passwordPrompt {
+		password, err = promptPassword()
+		if err != nil {
+			return fmt.Errorf("failed to read --password-prompt: %w", err)
+		}
+	}
+	if password != "" {
+		if opts.passwords == nil {
+			opts.passwords = &filesystem.GlobPasswords{}
+		}
+		opts.passwords.Fallback = password
+	}
+	if opts.archivesFrom != "" && !opts.dryRun {
+		return fmt.Errorf("%w: --archives-from can only be used with --dry-run", errInvalidArgument)
+	}
+	if opts.webserverSecret != "" {
+		if !opts.webserverOptions.ServeArchives {
+			return fmt.Errorf("%w: --webserver-archives-secret can only be used with --webserver-archives", errInvalidArgument)
+		}
+		opts.webserverOptions.ArchivesSecret, err = readSecret(opts.webserverSecret)
+		if err != nil {
+			return fmt.Errorf("%w: failed to read --webserver-archives-secret: %w", errInvalidArgument, err)
+		}
+	}
+	if opts.webserverDenyUA != "" {
+		opts.webserverOptions.DenyUserAgent, err = regexp.Compile(opts.webserverDenyUA)
+		if err != nil {
+			return fmt.Errorf("%w: failed to parse --webserver-deny-ua: %w", errInvalidArgument, err)
+		}
+	}
+	for _, raw := range opts.webserverAddrs {
+		if raw == "" {
+			continue
+		}
+		l, err := parseListener(raw, opts.webserverOptions.ReadOnly)
+		if err != nil {
+			return fmt.Errorf("failed to parse --webserver: %w", err)
+		}
+		opts.webserverListeners = append(opts.webserverListeners, l)
+	}
+	if opts.fuseVerbose {
+		opts.webserverOptions.Debug = func(msg any) {
+			fmt.Fprintf(os.Stderr, "%s\n", msg)
+		}
+	}
+
+	return nil
+}
+
+// runTree is the runtime logic for the "tree" subcommand of the program.
+// It renders the would-be filesystem as a tree, without ever mounting it.
+func runTree(opts cliOptions, depth int) error {
+	rbuf := logging.NewRingBuffer(opts.ringBufferSize, os.Stderr)
+
+	fsys, err := setupFilesystem(opts, rbuf)
+	if err != nil {
+		return fmt.Errorf("failed to setup fs: %w", err)
+	}
+	defer fsys.Destroy()
+
+	return treeWalkFS(fsys, opts.sourceDir, depth)
+}
+
+// runChanged is the runtime logic for the "changed" subcommand of the program.
+// It prints the files modified after since, without ever mounting the filesystem.
+func runChanged(opts cliOptions, since time.Time, limit int, timeout time.Duration) error {
+	rbuf := logging.NewRingBuffer(opts.ringBufferSize, os.Stderr)
+
+	fsys, err := setupFilesystem(opts, rbuf)
+	if err != nil {
+		return fmt.Errorf("failed to setup fs: %w", err)
+	}
+	defer fsys.Destroy()
+
+	return changedWalkFS(fsys, since, limit, timeout)
+}
+
+// runVerifyManifest is the runtime logic for the "verify-manifest" subcommand.
+// It verifies the files against the manifest, without ever mounting the filesystem.
+func runVerifyManifest(opts cliOptions, manifest string, parallel int) error {
+	entries, err := readManifest(manifest)
+	if err != nil {
+		return fmt.Errorf("failed to read manifest: %w", err)
+	}
+
+	rbuf := logging.NewRingBuffer(opts.ringBufferSize, os.Stderr)
+
+	fsys, err := setupFilesystem(opts, rbuf)
+	if err != nil {
+		return fmt.Errorf("failed to setup fs: %w", err)
+	}
+	defer fsys.Destroy()
+
+	return verifyManifest(fsys, entries, parallel)
+}
+
+// runDiff is the runtime logic for the "diff" subcommand of the program.
+// It compares both sides as would-be filesystems, without ever mounting them.
+func runDiff(opts cliOptions, a, b string, asJSON, ignoreMtime bool) error {
+	ctx := dryWalkContext()
+
+	var sides [2]map[string]diffEntry
+
+	for i, source := range []string{a, b} {
+		entries, err := diffSide(ctx, opts, source)
+		if err != nil {
+			return fmt.Errorf("%q: %w", source, err)
+		}
+		sides[i] = entries
+	}
+
+	changes := diffTrees(sides[0], sides[1], ignoreMtime)
+
+	if err := diffPrint(os.Stdout, changes, asJSON); err != nil {
+		return err
+	}
+
+	if len(changes) > 0 {
+		return fmt.Errorf("%w: %d paths differ", errDiffFound, len(changes))
+	}
+
+	return nil
+}
+
+// diffSide walks one side of the "diff" subcommand, being a source directory, a
+// remote archive or a single local archive (with its directory as the source).
+func diffSide(ctx context.Context, opts cliOptions, source string) (map[string]diffEntry, error) {
+	var archive string
+
+	opts.sourceDir = source
+	if fi, err := os.Stat(source); err == nil && fi.Mode().IsRegular() {
+		archive = source
+		opts.sourceDir = filepath.Dir(source)
+	}
+
+	rbuf := logging.NewRingBuffer(opts.ringBufferSize, os.Stderr)
+
+	fsys, err := setupFilesystem(opts, rbuf)
+	if err != nil {
+		return nil, fmt.Errorf("failed to setup fs: %w", err)
+	}
+	defer fsys.Destroy()
+
+	return diffWalkFS(ctx, fsys, archive)
+}
+
+// runCat is the runtime logic for the "cat" subcommand, extracting a file
+// within the (single) archive given as source to standard output (stdout).
+func runCat(opts cliOptions, entry string, offset, length int64) error {
+	rbuf := logging.NewRingBuffer(opts.ringBufferSize, os.Stderr)
+
+	fsys, err := setupFilesystem(opts, rbuf)
+	if err != nil {
+		return fmt.Errorf("%w: failed to setup fs: %w", errCatArchiveUnreadable, err)
+	}
+	defer fsys.Destroy()
+
+	w := bufio.NewWriter(os.Stdout)
+
+	_, err = fsys.ExtractEntry(opts.sourceDir, entry, offset, length, w)
+	if err != nil {
+		switch {
+		case errors.Is(err, filesystem.ErrArchiveUnreadable):
+			return fmt.Errorf("%w: %w", errCatArchiveUnreadable, err)
+		case errors.Is(err, filesystem.ErrEntryNotFound):
+			return fmt.Errorf("%w: %w", errCatEntryNotFound, err)
+		default:
+			return fmt.Errorf("failed to extract: %w", err)
+		}
+	}
+
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("failed to write: %w", err)
+	}
+
+	return nil
+}
+
 // run is the runtime logic for the program as executed by [cobra.Command].
 // It implements the entire lifetime of the program and the served filesystem.
 func run(opts cliOptions) error {
-	rbuf := logging.NewRingBuffer(opts.ringBufferSize, os.Stderr)
+	var out io.Writer = os.Stderr
+	if opts.logFile != "" {
+		logFile, err := logging.NewRotatingFile(opts.logFile, int64(opts.logMaxSize), opts.logKeep)
+		if err != nil {
+			return fmt.Errorf("failed to setup log file: %w", err)
+		}
+		defer logFile.Close() //nolint:errcheck
+
+		out = io.MultiWriter(os.Stderr, logFile)
+	}
+	rbuf := logging.NewRingBuffer(opts.ringBufferSize, out)
+	rbuf.SetRepeatWindow(opts.logRepeatWindow)
+
+	if opts.jsonLogFile != "" {
+		jsonLogFile, err := logging.NewRotatingFile(opts.jsonLogFile, int64(opts.logMaxSize), opts.logKeep)
+		if err != nil {
+			return fmt.Errorf("failed to setup json log file: %w", err)
+		}
+		defer jsonLogFile.Close() //nolint:errcheck
+
+		rbuf.SetJSONOutput(jsonLogFile)
+	}
+	defer rbuf.Close() // before the log files (for any pending repetitions)
 
 	fsys, err := setupFilesystem(opts, rbuf)
 	if err != nil {
@@ -166,10 +1046,31 @@ func run(opts cliOptions) error {
 	defer fsys.Destroy()
 
 	if opts.dryRun {
-		return dryWalkFS(fsys)
+		if opts.archivesFrom != "" {
+			archives, err := readArchivesList(opts.archivesFrom)
+			if err != nil {
+				return fmt.Errorf("failed to read --archives-from: %w", err)
+			}
+
+			return dryWalkArchives(fsys, archives)
+		}
+
+		return dryWalkFS(fsys, opts.walkConcurrency)
+	}
+
+	if !opts.noPreflight {
+		if err := checkPreflight(opts.allowOther); err != nil {
+			return err
+		}
 	}
 
-	conn, err := mountFilesystem(opts, fsys)
+	if opts.verifyOnMount {
+		if err := verifyArchives(fsys, opts.walkConcurrency); err != nil {
+			return fmt.Errorf("failed to verify archives: %w", err)
+		}
+	}
+
+	conn, err := mountFilesystem(opts, fsys, rbuf)
 	if err != nil {
 		return fmt.Errorf("failed to mount fs: %w", err)
 	}
@@ -180,15 +1081,21 @@ func run(opts cliOptions) error {
 		rbuf.Printf("failed to notify mount helper: %v\n", err)
 	}
 
-	setupSignalHandlers(fsys, rbuf, opts.mountDir)
+	setupSignalHandlers(fsys, rbuf, opts.mountDir, opts.signalUSR1)
+	setupIdleUnmount(fsys, rbuf, opts.mountDir)
 	wg, errChan := serveFilesystem(conn, fsys, opts.fuseVerbose)
+	awaitReady(fsys, rbuf, opts.mountDir)
 
-	if opts.webserverAddr != "" {
-		srv, err := serveDashboard(opts.webserverAddr, fsys, rbuf)
+	if len(opts.webserverListeners) > 0 {
+		servers, err := serveDashboard(opts.webserverListeners, &opts.webserverOptions, fsys, rbuf)
 		if err != nil {
 			return fmt.Errorf("failed to setup webserver: %w", err)
 		}
-		defer srv.Close()
+		defer func() {
+			for _, srv := range servers {
+				srv.Close() //nolint:errcheck
+			}
+		}()
 	}
 
 	wg.Wait()
@@ -199,17 +1106,68 @@ func run(opts cliOptions) error {
 // setupFilesystem configures and returns the [filesystem.FS] to be served.
 func setupFilesystem(opts cliOptions, rbuf *logging.RingBuffer) (*filesystem.FS, error) {
 	fopts := &filesystem.Options{
-		FDCacheSize:    opts.fdCacheSize,
-		FDCacheTTL:     opts.fdCacheTTL,
-		FDLimit:        opts.fdLimit,
-		FlatMode:       opts.flatMode,
-		ForceUnicode:   opts.forceUnicode,
-		StreamPoolSize: int(opts.streamPoolSize),
-		StrictCache:    opts.strictCache,
+		AllowRawNameLookup:    opts.allowRawNameLookup,
+		AllowXattrControl:     opts.allowXattrControl,
+		AllowedUIDs:           opts.allowedUIDs,
+		ArchiveMarker:         opts.archiveMarker,
+		ArchiveXattrs:         opts.archiveXattrs,
+		BreakerCooldown:       opts.breakerCooldown,
+		BreakerThreshold:      opts.breakerThreshold,
+		BreakerWindow:         opts.breakerWindow,
+		CacheDirEntries:       opts.cacheDirEntries,
+		CacheFileContent:      opts.cacheFileContent,
+		DedupIdentical:        opts.dedupIdentical,
+		DetectSFX:             opts.detectSFX,
+		DirectIO:              opts.directIO,
+		EmptyArchiveAs:        opts.emptyArchiveAs,
+		Entry:                 opts.entry,
+		ExposeRaw:             opts.exposeRaw,
+		FDCacheSize:           opts.fdCacheSize,
+		FDCacheTTL:            opts.fdCacheTTL,
+		FDLimit:               opts.fdLimit,
+		FixedMtime:            opts.fixedMtime,
+		FlatCollisions:        opts.flatCollisions,
+		FlatDerefSymlinks:     opts.flatDerefSymlinks,
+		FlatDotfileStyle:      opts.dotfileStyle,
+		FlatMode:              opts.flatMode,
+		IdleTimeout:           opts.idleTimeout,
+		ForceUnicode:          opts.forceUnicode,
+		HideHidden:            opts.hideHidden,
+		MaxInMemoryBytes:      opts.maxInMemory,
+		MaxListEntries:        opts.maxListEntries,
+		MaxTotalInMemoryBytes: opts.maxTotalInMemory,
+		MetadataOnly:          opts.metadataOnly,
+		NestedConflicts:       opts.nestedConflicts,
+		OnlyExtensions:        opts.onlyExt,
+		PreserveOwnership:     opts.preserveOwnership,
+		PreservePermissions:   opts.preservePerms,
+		RealSort:              opts.realSort,
+		RealSortDirsFirst:     opts.realSortDirsFirst,
+		SizeMode:              opts.sizeMode,
+		StreamPoolSize:        int(opts.streamPoolSize),
+		StreamRetries:         opts.streamRetries,
+		StrictCache:           opts.strictCache,
+		TailMode:              opts.tailMode,
+		TailWindow:            opts.tailWindow,
+		ThresholdRules:        opts.thresholdRules,
+		TimestampTZ:           opts.timestampTZ,
+		TraceSample:           opts.traceSample,
+		UnicodeNormalize:      opts.unicodeNorm,
+		WalkContinueOnError:   opts.continueOnError,
+		WalkTimeout:           opts.walkTimeout,
 	}
+	fopts.DirSizes.Store(opts.dirSizes)
 	fopts.FDCacheBypass.Store(opts.fdCacheBypass)
 	fopts.MustCRC32.Store(opts.mustCRC32)
 	fopts.StreamingThreshold.Store(opts.streamThreshold)
+	for _, glob := range opts.pinGlobs {
+		if err := fopts.PinGlobs.Set(glob, true); err != nil {
+			return nil, fmt.Errorf("failed to set --pin-glob: %w", err)
+		}
+	}
+	if opts.passwords != nil {
+		fopts.Passwords = opts.passwords
+	}
 
 	fsys, err := filesystem.NewFS(opts.sourceDir, fopts, rbuf)
 	if err != nil {
@@ -220,15 +1178,46 @@ func setupFilesystem(opts cliOptions, rbuf *logging.RingBuffer) (*filesystem.FS,
 }
 
 // mountFilesystem opens a new [fuse.Conn] for the specified mountpoint.
-func mountFilesystem(opts cliOptions, fsys *filesystem.FS) (*fuse.Conn, error) {
+// The applied mount options and negotiated FUSE capabilities are logged.
+func mountFilesystem(opts cliOptions, fsys *filesystem.FS, rbuf *logging.RingBuffer) (*fuse.Conn, error) {
+	if err := checkMountpoint(opts.mountDir, opts.nonEmpty, opts.entry != ""); err != nil {
+		return nil, err
+	}
+	if opts.entry != "" {
+		// Resolve the entry before mounting, rather than failing when serving.
+		if _, err := fsys.Root(); err != nil {
+			return nil, fmt.Errorf("%w: failed to resolve --entry: %w", errInvalidArgument, err)
+		}
+	}
+
 	mountOpts := []fuse.MountOption{
-		fuse.FSName("zipfuse"),
-		fuse.ReadOnly(),
+		fuse.FSName(opts.fsName),
 		fuse.DefaultPermissions(),
-		fuse.MaxReadahead(uint32(opts.streamPoolSize)),
+		fuse.MaxReadahead(uint32(opts.maxReadahead)),
+	}
+	applied := []string{
+		"fsname=" + opts.fsName,
+		"default_permissions",
+		"max_readahead=" + strconv.FormatUint(opts.maxReadahead, 10),
+	}
+	if opts.subtype != "" {
+		mountOpts = append(mountOpts, fuse.Subtype(opts.subtype))
+		applied = append(applied, "subtype="+opts.subtype)
+	}
+	if !opts.allowXattrControl {
+		// The kernel rejects any xattr writes on read-only mounts, so these
+		// never reach us; without it, the filesystem itself rejects all other
+		// modifications (with EROFS, as the kernel would on read-only mounts).
+		mountOpts = append(mountOpts, fuse.ReadOnly())
+		applied = append(applied, "ro")
 	}
 	if opts.allowOther {
 		mountOpts = append(mountOpts, fuse.AllowOther())
+		applied = append(applied, "allow_other")
+	}
+	if opts.nonEmpty {
+		mountOpts = append(mountOpts, fuse.AllowNonEmptyMount())
+		applied = append(applied, "nonempty")
 	}
 
 	conn, err := fuse.Mount(opts.mountDir, mountOpts...)
@@ -237,6 +1226,10 @@ func mountFilesystem(opts cliOptions, fsys *filesystem.FS) (*fuse.Conn, error) {
 	}
 	fsys.MountTime = time.Now()
 
+	// The connection is already initialized (negotiated) once mounted.
+	rbuf.Printf("Mounted with options: %s\n", strings.Join(applied, ","))
+	rbuf.Printf("Negotiated FUSE protocol %v with features: %v\n", conn.Protocol(), conn.Features())
+
 	return conn, nil
 }
 
@@ -299,19 +1292,18 @@ func serveFilesystem(conn *fuse.Conn, fsys *filesystem.FS, verbose bool) (*sync.
 		defer func() {
 			r := recover()
 			if r != nil {
-				fmt.Fprintf(os.Stderr, "(fs) PANIC: %v\n", r)
-				debug.PrintStack()
+				fsys.RecordPanic("fs", r, true)
 				errChan <- fmt.Errorf("failed to serve fs: %w", errPanicRecovered)
 			}
 			close(errChan)
 		}()
 
-		var config *fs.Config
+		config := &fs.Config{
+			WithContext: fsys.WithContext,
+		}
 		if verbose {
-			config = &fs.Config{
-				Debug: func(msg any) {
-					fmt.Fprintf(os.Stderr, "%s", msg)
-				},
+			config.Debug = func(msg any) {
+				fmt.Fprintf(os.Stderr, "%s", msg)
 			}
 		}
 
@@ -324,14 +1316,15 @@ func serveFilesystem(conn *fuse.Conn, fsys *filesystem.FS, verbose bool) (*sync.
 	return &wg, errChan
 }
 
-// serveDashboard sets up a [http.Server] and starts serving a [webserver.FSDashboard].
-func serveDashboard(addr string, fsys *filesystem.FS, rbuf *logging.RingBuffer) (*http.Server, error) {
+// serveDashboard sets up a [http.Server] per [webserver.Listener] and starts
+// serving a [webserver.FSDashboard] on all of them (each with its own mode).
+func serveDashboard(listeners []webserver.Listener, sopts *webserver.ServeOptions, fsys *filesystem.FS, rbuf *logging.RingBuffer) ([]*http.Server, error) {
 	dashboard, err := webserver.NewFSDashboard(fsys, rbuf, Version)
 	if err != nil {
 		return nil, fmt.Errorf("dashboard error: %w", err)
 	}
 
-	return dashboard.Serve(addr), nil
+	return dashboard.ServeAll(listeners, sopts), nil
 }
 
 // cleanupMount runs FS cleanup, unmounts and eventually closes the [fuse.Conn].
@@ -355,10 +1348,9 @@ func main() {
 		}
 	}
 	if err := rootCmd().Execute(); err != nil {
-		err := notifyMountHelper(err)
-		if err != nil {
+		if err := notifyMountHelper(err); err != nil {
 			fmt.Fprintf(os.Stderr, "failed to notify mount helper: %v\n", err)
 		}
-		os.Exit(1)
+		os.Exit(exitCode(err))
 	}
 }
@@ -1,21 +1,38 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"log"
 	"math"
+	"mime"
+	"net/http"
 	"os"
 	"os/signal"
+	"path"
+	"path/filepath"
 	"runtime"
 	"runtime/debug"
+	"slices"
+	"strings"
+	"sync"
 	"syscall"
+	"time"
 
 	"bazil.org/fuse"
 	"bazil.org/fuse/fs"
 	"github.com/desertwitch/zipfuse/internal/filesystem"
 	"github.com/desertwitch/zipfuse/internal/logging"
+	"github.com/desertwitch/zipfuse/internal/webserver"
+	"github.com/dustin/go-humanize"
+	"github.com/spf13/pflag"
 	"golang.org/x/sys/unix"
 )
 
@@ -53,14 +70,220 @@ func fdLimits() (fsLimit int, cacheLimit int, err error) {
 	return fsLimit, cacheLimit, nil
 }
 
+// checkMountpoint verifies that the mountpoint is usable before mounting,
+// returning actionable errors for the most common problems encountered:
+// a missing, stale, already mounted, non-directory or non-empty mountpoint.
+// With isFile (serving a single file), it must be a regular file instead.
+func checkMountpoint(mountDir string, nonEmpty bool, isFile bool) error {
+	fi, err := os.Stat(mountDir)
+	if err != nil {
+		if errors.Is(err, syscall.ENOTCONN) {
+			return fmt.Errorf("%w: mountpoint %q is a stale mount (try: fusermount -u %q)", errInvalidArgument, mountDir, mountDir)
+		}
+		if errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("%w: mountpoint %q does not exist (try: mkdir -p %q)", errInvalidArgument, mountDir, mountDir)
+		}
+
+		return fmt.Errorf("%w: mountpoint %q is not accessible: %w", errInvalidArgument, mountDir, err)
+	}
+
+	if isFile {
+		if !fi.Mode().IsRegular() {
+			return fmt.Errorf("%w: mountpoint %q is not a file (choose a file with --entry, try: touch %q)", errInvalidArgument, mountDir, mountDir)
+		}
+
+		return nil
+	}
+
+	if !fi.IsDir() {
+		return fmt.Errorf("%w: mountpoint %q is not a directory (choose an empty directory)", errInvalidArgument, mountDir)
+	}
+
+	if isMountpoint(mountDir, fi) {
+		return fmt.Errorf("%w: mountpoint %q is busy, already mounted (try: umount %q)", errInvalidArgument, mountDir, mountDir)
+	}
+
+	if nonEmpty {
+		return nil
+	}
+
+	d, err := os.Open(mountDir)
+	if err != nil {
+		return fmt.Errorf("%w: mountpoint %q is not accessible: %w", errInvalidArgument, mountDir, err)
+	}
+	defer d.Close() //nolint:errcheck
+
+	if _, err := d.Readdirnames(1); !errors.Is(err, io.EOF) {
+		return fmt.Errorf("%w: mountpoint %q is not empty (choose an empty directory or use --nonempty)", errInvalidArgument, mountDir)
+	}
+
+	return nil
+}
+
+// checkPreflight verifies that FUSE is usable before mounting, returning
+// actionable errors for the most common problems encountered on a first run:
+// a missing or inaccessible FUSE device, or "allow_other" not being permitted
+// for non-root users (lacking "user_allow_other" within the FUSE configuration).
+func checkPreflight(allowOther bool) error {
+	const fuseDevice = "/dev/fuse"
+	const fuseConf = "/etc/fuse.conf"
+
+	if _, err := os.Stat(fuseDevice); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("%w: %s does not exist (try: modprobe fuse, or installing the fuse3 package)", errPreflightFailed, fuseDevice)
+		}
+
+		return fmt.Errorf("%w: %s is not accessible: %w", errPreflightFailed, fuseDevice, err)
+	}
+
+	if err := unix.Access(fuseDevice, unix.R_OK|unix.W_OK); err != nil {
+		return fmt.Errorf("%w: %s is not accessible for the user (try: ls -l %s, or adding the user to the fuse group): %w",
+			errPreflightFailed, fuseDevice, fuseDevice, err)
+	}
+
+	if !allowOther || syscall.Geteuid() == 0 {
+		return nil
+	}
+
+	data, err := os.ReadFile(fuseConf)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("%w: %s is not readable (needed for --allow-other): %w", errPreflightFailed, fuseConf, err)
+	}
+
+	for line := range strings.Lines(string(data)) {
+		if strings.TrimSpace(line) == "user_allow_other" {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%w: --allow-other needs \"user_allow_other\" within %s for non-root users "+
+		"(try: echo user_allow_other | sudo tee -a %s, or --allow-other=false)", errPreflightFailed, fuseConf, fuseConf)
+}
+
+// isMountpoint returns true if a directory is already a mountpoint, meaning
+// that it resides on another device than its parent directory (or is root).
+func isMountpoint(dir string, fi os.FileInfo) bool {
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return false
+	}
+
+	pfi, err := os.Stat(filepath.Join(dir, ".."))
+	if err != nil {
+		return false
+	}
+
+	pst, ok := pfi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return false
+	}
+
+	return st.Dev != pst.Dev || st.Ino == pst.Ino
+}
+
+// unmountMu serializes the unmounting of the filesystem (e.g. on a signal
+// arriving while an unmount after inactivity is already in progress).
+var unmountMu sync.Mutex
+
+// unmountFilesystem gracefully unmounts the filesystem. Unmount failures are
+// handled and the filesystem restored to working order (returning the error).
+func unmountFilesystem(fsys *filesystem.FS, rbuf *logging.RingBuffer, mountDir string) error {
+	unmountMu.Lock()
+	defer unmountMu.Unlock()
+
+	errs := make(chan error, 1)
+	fsys.PrepareUnmount(errs)
+	if err := fuse.Unmount(mountDir); err != nil {
+		errs <- err
+		close(errs)
+
+		rbuf.Printf("Unmount error: %v (try again later)\n", err)
+
+		return err //nolint:wrapcheck
+	}
+	close(errs)
+
+	return nil
+}
+
+// setupIdleUnmount gracefully unmounts the filesystem once no requests were
+// served for the --idle-timeout (see [filesystem.FS.WatchIdle]), as if the
+// program was signaled with SIGTERM. A failed unmount (e.g. due to files still
+// being held open) is retried after the same time of inactivity has passed.
+func setupIdleUnmount(fsys *filesystem.FS, rbuf *logging.RingBuffer, mountDir string) {
+	fsys.WatchIdle(func() {
+		defer recoverSignalsPanic()
+
+		rbuf.Printf("Idle for %s (no requests served), unmounting the filesystem...\n", fsys.Options.IdleTimeout)
+
+		_ = unmountFilesystem(fsys, rbuf, mountDir)
+	})
+}
+
+// awaitReady marks the [filesystem.FS] as ready (see [filesystem.FS.Ready])
+// once a self-test stat of the mountpoint was served (through the kernel), as
+// being mounted does not yet mean that requests are served. The self-test is
+// retried with an exponential backoff (up to readyMaxBackoff) until it either
+// succeeds or readyTimeout has passed. It does not block.
+func awaitReady(fsys *filesystem.FS, rbuf *logging.RingBuffer, mountDir string) {
+	go func() {
+		defer recoverSignalsPanic()
+
+		deadline := time.Now().Add(readyTimeout)
+		backoff := readyMinBackoff
+
+		for {
+			err := statMounted(mountDir)
+			if err == nil {
+				break
+			}
+
+			if time.Now().Add(backoff).After(deadline) {
+				rbuf.Printf("Warning: readiness self-test failed after %s (not reporting as ready): %v\n", readyTimeout, err)
+
+				return
+			}
+
+			time.Sleep(backoff)
+			backoff = min(2*backoff, readyMaxBackoff)
+		}
+
+		fsys.MarkReady()
+		rbuf.Println("Ready: the filesystem is serving requests.")
+	}()
+}
+
+// statMounted stats the mountpoint and returns an error if that is not (yet)
+// served by a mounted filesystem (that is, still on the device of its parent).
+func statMounted(mountDir string) error {
+	mfi, err := os.Stat(mountDir)
+	if err != nil {
+		return fmt.Errorf("failed to stat mountpoint: %w", err)
+	}
+
+	pfi, err := os.Stat(filepath.Dir(filepath.Clean(mountDir)))
+	if err != nil {
+		return fmt.Errorf("failed to stat mountpoint parent: %w", err)
+	}
+
+	mst, ok1 := mfi.Sys().(*syscall.Stat_t)
+	pst, ok2 := pfi.Sys().(*syscall.Stat_t)
+	if ok1 && ok2 && mst.Dev == pst.Dev {
+		return errNotMounted
+	}
+
+	return nil
+}
+
 // setupSignalHandlers sets up the listeners for operating system signals.
 //
 //   - SIGTERM or SIGINT (CTRL+C) gracefully unmounts the filesystem
-//   - SIGUSR1 forces a garbage collection (within Go)
+//   - SIGUSR1 forces a garbage collection (within Go), or purges the FD cache
 //   - SIGUSR2 dumps a diagnostic stacktrace to standard error (stderr)
 //
 // Unmount failures are handled and the filesystem restored to working order.
-func setupSignalHandlers(fsys *filesystem.FS, rbuf *logging.RingBuffer, mountDir string) {
+// The action of SIGUSR1 is either [signalUSR1GC] or [signalUSR1PurgeCache].
+func setupSignalHandlers(fsys *filesystem.FS, rbuf *logging.RingBuffer, mountDir string, usr1 string) {
 	sig := make(chan os.Signal, 1)
 	signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
 	go func() {
@@ -68,17 +291,9 @@ func setupSignalHandlers(fsys *filesystem.FS, rbuf *logging.RingBuffer, mountDir
 		for range sig {
 			rbuf.Println("Signal received, unmounting the filesystem...")
 
-			errs := make(chan error, 1)
-			fsys.PrepareUnmount(errs)
-			if err := fuse.Unmount(mountDir); err != nil {
-				errs <- err
-				close(errs)
-
-				rbuf.Printf("Unmount error: %v (try again later)\n", err)
-
+			if err := unmountFilesystem(fsys, rbuf, mountDir); err != nil {
 				continue
 			}
-			close(errs)
 
 			return
 		}
@@ -89,6 +304,13 @@ func setupSignalHandlers(fsys *filesystem.FS, rbuf *logging.RingBuffer, mountDir
 	go func() {
 		defer recoverSignalsPanic()
 		for range sig1 {
+			if usr1 == signalUSR1PurgeCache {
+				rbuf.Println("Signal received, purging the FD cache...")
+				n := fsys.PurgeCache()
+				rbuf.Printf("FD cache purged, released %d archive(s) to be re-opened on next access\n", n)
+
+				continue
+			}
 			rbuf.Println("Signal received, forcing garbage collection...")
 			runtime.GC()
 			debug.FreeOSMemory()
@@ -111,7 +333,85 @@ func setupSignalHandlers(fsys *filesystem.FS, rbuf *logging.RingBuffer, mountDir
 // dryWalkFS implements the dry-run mode of the program.
 // It does a virtual walk of the would-be filesystem, without mounting.
 // As the filesystem is walked, all would-be inodes and paths are printed out.
-func dryWalkFS(fsys *filesystem.FS) error {
+// The archives are walked by the given amount of concurrent workers (see
+// [filesystem.FS.WalkConcurrent]), with the output being in the same order.
+func dryWalkFS(fsys *filesystem.FS, workers int) error {
+	ctx := dryWalkContext()
+
+	if err := fsys.WalkConcurrent(ctx, workers, dryWalkPrint); err != nil {
+		if n := reportWalkFailures(err); n > 0 {
+			return fmt.Errorf("%w: %d nodes failed during the walk (see above)", errWalkFailures, n)
+		}
+
+		return dryWalkError(err)
+	}
+
+	return nil
+}
+
+// dryWalkArchives implements the dry-run mode of the program for a given set
+// of archives (see --archives-from), walking only these instead of the source
+// directory. Archives that do not exist, are outside of the source directory,
+// or cannot be opened as ZIP archives are reported and skipped over, in which
+// case an error is returned (after all other archives were walked) for these.
+// The same goes for archives with failed nodes (with --continue-on-error).
+func dryWalkArchives(fsys *filesystem.FS, archives []string) error {
+	ctx := dryWalkContext()
+
+	var skipped int
+
+	for _, archive := range archives {
+		err := fsys.WalkArchive(ctx, archive, dryWalkPrint)
+		if reportWalkFailures(err) > 0 {
+			skipped++
+
+			continue
+		}
+		if errors.Is(err, filesystem.ErrNotArchive) || errors.Is(err, filesystem.ErrArchiveUnreadable) {
+			fmt.Fprintf(os.Stderr, "Skipped: %v\n", err)
+			skipped++
+
+			continue
+		}
+		if err != nil {
+			return dryWalkError(err)
+		}
+	}
+
+	if skipped > 0 {
+		return fmt.Errorf("%w: skipped %d of %d given archives", errInvalidArgument, skipped, len(archives))
+	}
+
+	return nil
+}
+
+// verifyArchives implements the pre-mount verification of the program (see
+// --verify-on-mount), opening the central directory of each archive within the
+// [filesystem.FS], with up to the given amount of concurrent workers. Each
+// unreadable archive is reported, returning an error if any were found. It can
+// be cancelled with SIGINT or SIGTERM, returning an error.
+func verifyArchives(fsys *filesystem.FS, workers int) error {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	failures, err := fsys.VerifyArchives(ctx, workers)
+	for _, f := range failures {
+		fmt.Fprintf(os.Stderr, "Unreadable: %v\n", f)
+	}
+	if err != nil {
+		return dryWalkError(err)
+	}
+
+	if len(failures) > 0 {
+		return fmt.Errorf("%w: %d archives cannot be opened (see above)", errUnreadableArchives, len(failures))
+	}
+
+	return nil
+}
+
+// dryWalkContext returns a [context.Context] for the dry-run mode of the program.
+// It is cancelled upon receiving a SIGINT or SIGTERM, ending any ongoing walk.
+func dryWalkContext() context.Context {
 	ctx, cancel := context.WithCancel(context.Background())
 
 	sig := make(chan os.Signal, 1)
@@ -124,15 +424,35 @@ func dryWalkFS(fsys *filesystem.FS) error {
 		}
 	}()
 
-	err := fsys.Walk(ctx, func(path string, _ *fuse.Dirent, _ fs.Node, attr fuse.Attr) error {
-		fmt.Fprintf(os.Stdout, "%d:%s\n", attr.Inode, path)
+	return ctx
+}
 
-		return nil
-	})
-	if err == nil {
-		return nil
+// reportWalkFailures prints the failures of a walk continued past these (see
+// --continue-on-error) to standard error (stderr), returning their amount (or
+// zero if the error is not a [filesystem.WalkIncompleteError], e.g. nil).
+func reportWalkFailures(err error) int {
+	var incomplete *filesystem.WalkIncompleteError
+	if !errors.As(err, &incomplete) {
+		return 0
 	}
 
+	for _, f := range incomplete.Failures {
+		fmt.Fprintf(os.Stderr, "Failed: %v\n", f)
+	}
+
+	return len(incomplete.Failures)
+}
+
+// dryWalkPrint is the [filesystem.WalkFunc] of the dry-run mode of the program.
+// It prints the inode and path of each visited node to standard output (stdout).
+func dryWalkPrint(path string, _ *fuse.Dirent, _ fs.Node, attr fuse.Attr) error {
+	fmt.Fprintf(os.Stdout, "%d:%s\n", attr.Inode, path)
+
+	return nil
+}
+
+// dryWalkError returns the deepest error of an error chain from a walk.
+func dryWalkError(err error) error {
 	for {
 		unwrapped := errors.Unwrap(err)
 		if unwrapped == nil {
@@ -144,6 +464,695 @@ func dryWalkFS(fsys *filesystem.FS) error {
 	}
 }
 
+// changedWalkFS implements the "changed" subcommand of the program, printing
+// the files within archives modified after since as JSON lines to standard
+// output (stdout), stopping at the limit (if > 0) and the timeout (if > 0).
+func changedWalkFS(fsys *filesystem.FS, since time.Time, limit int, timeout time.Duration) error {
+	ctx := dryWalkContext()
+
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	entries, truncated, err := fsys.ChangedEntries(ctx, since, limit)
+	if err != nil {
+		return dryWalkError(err)
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	for _, e := range entries {
+		if err := enc.Encode(e); err != nil {
+			return fmt.Errorf("failed to encode: %w", err)
+		}
+	}
+
+	if truncated {
+		log.Printf("Stopped at --limit of %d files, more files were modified.\n", limit)
+	}
+
+	return nil
+}
+
+// manifestEntry is a line of a manifest, as read by readManifest().
+type manifestEntry struct {
+	sum  []byte
+	path string
+}
+
+// readManifest reads a manifest in the format of sha256sum(1), being lines of
+// a hex-encoded SHA-256 checksum, a space and a space (or an asterisk for the
+// binary mode), followed by the path. Any empty lines are skipped over.
+func readManifest(name string) ([]manifestEntry, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open: %w", err)
+	}
+	defer f.Close() //nolint:errcheck
+
+	var entries []manifestEntry
+
+	s := bufio.NewScanner(f)
+	for n := 1; s.Scan(); n++ {
+		line := strings.TrimSpace(s.Text())
+		if line == "" {
+			continue
+		}
+
+		hexSum, rest, ok := strings.Cut(line, " ")
+		sum, err := hex.DecodeString(hexSum)
+		if !ok || err != nil || len(sum) != sha256.Size || (!strings.HasPrefix(rest, " ") && !strings.HasPrefix(rest, "*")) {
+			return nil, fmt.Errorf("%w: line %d: not a SHA-256 checksum line", errInvalidArgument, n)
+		}
+
+		entries = append(entries, manifestEntry{
+			sum:  sum,
+			path: strings.TrimPrefix(rest[1:], "./"),
+		})
+	}
+	if err := s.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read: %w", err)
+	}
+
+	return entries, nil
+}
+
+// verifyManifest implements the "verify-manifest" subcommand of the program,
+// reading up to parallel files of the manifest concurrently (by the paths of
+// the would-be filesystem) and printing the result of each to standard output.
+func verifyManifest(fsys *filesystem.FS, entries []manifestEntry, parallel int) error {
+	ctx := dryWalkContext()
+
+	results := make([]string, len(entries))
+	work := make(chan int)
+
+	var wg sync.WaitGroup
+	for range parallel {
+		wg.Go(func() {
+			for i := range work {
+				h := sha256.New()
+
+				_, err := fsys.CopyFile(ctx, entries[i].path, h)
+				switch {
+				case errors.Is(err, filesystem.ErrEntryNotFound):
+					results[i] = "MISSING"
+				case err != nil:
+					results[i] = fmt.Sprintf("FAILED (%v)", err)
+				case !bytes.Equal(h.Sum(nil), entries[i].sum):
+					results[i] = "FAILED"
+				default:
+					results[i] = "OK"
+				}
+			}
+		})
+	}
+
+	for i := range entries {
+		if ctx.Err() != nil {
+			break
+		}
+		work <- i
+	}
+	close(work)
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("context error: %w", err)
+	}
+
+	var failed int
+	for i, res := range results {
+		if res != "OK" {
+			failed++
+		}
+		fmt.Fprintf(os.Stdout, "%s: %s\n", entries[i].path, res)
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%w: %d of %d files did not match (see above)", errManifestMismatch, failed, len(entries))
+	}
+
+	return nil
+}
+
+// treeEntry is a node of the would-be filesystem, as visited by treeWalkFS().
+type treeEntry struct {
+	name  string
+	depth int
+	dir   bool
+	size  uint64
+	last  bool // Is the last entry within its parent directory.
+}
+
+// treeWalkFS implements the "tree" subcommand of the program, doing a virtual
+// walk of the would-be filesystem and printing it as a tree to standard output.
+// Directories at the depth (if > 0) are not descended into (nor their archives
+// opened). The entries are in the order of the walk, being the sorted listings.
+func treeWalkFS(fsys *filesystem.FS, root string, depth int) error {
+	ctx := dryWalkContext()
+
+	var entries []treeEntry
+
+	err := fsys.Walk(ctx, func(p string, _ *fuse.Dirent, _ fs.Node, attr fuse.Attr) error {
+		if p == "/" {
+			return nil
+		}
+
+		d := strings.Count(p, "/")
+		entries = append(entries, treeEntry{
+			name:  path.Base(p),
+			depth: d,
+			dir:   attr.Mode.IsDir(),
+			size:  attr.Size,
+		})
+
+		if depth > 0 && d >= depth && attr.Mode.IsDir() {
+			return filesystem.ErrSkipDir
+		}
+
+		return nil
+	})
+	if err != nil {
+		return dryWalkError(err)
+	}
+
+	treePrint(os.Stdout, root, entries)
+
+	return nil
+}
+
+// treePrint prints the entries of a walk as a tree (in the style of tree(1)),
+// followed by a summary line of the amount of directories and files printed.
+func treePrint(w io.Writer, root string, entries []treeEntry) {
+	// Walking backwards, an entry is the last within its parent directory, if
+	// no later entry had the same depth, before any entry of a lower depth.
+	var later []bool
+	for i := len(entries) - 1; i >= 0; i-- {
+		d := entries[i].depth
+		for len(later) <= d {
+			later = append(later, false)
+		}
+		entries[i].last = !later[d]
+		later[d] = true
+		clear(later[d+1:])
+	}
+
+	fmt.Fprintln(w, root)
+
+	var dirs, files int
+	var open []bool // Is the ancestor at the depth followed by more entries.
+
+	for _, e := range entries {
+		open = append(open[:e.depth-1], !e.last)
+
+		var b strings.Builder
+		for _, more := range open[:e.depth-1] {
+			if more {
+				b.WriteString("│   ")
+			} else {
+				b.WriteString("    ")
+			}
+		}
+		if e.last {
+			b.WriteString("└── ")
+		} else {
+			b.WriteString("├── ")
+		}
+
+		if e.dir {
+			dirs++
+			fmt.Fprintf(w, "%s%s/\n", b.String(), e.name)
+		} else {
+			files++
+			fmt.Fprintf(w, "%s%s (%s)\n", b.String(), e.name, humanize.IBytes(e.size))
+		}
+	}
+
+	fmt.Fprintf(w, "\n%d directories, %d files\n", dirs, files)
+}
+
+// diffEntry is a node of one side of the "diff" subcommand, as visited by diffWalkFS().
+type diffEntry struct {
+	dir    bool
+	size   uint64
+	mtime  time.Time
+	crc32  uint32
+	hasCRC bool // Is a file within an archive (with a known CRC-32).
+}
+
+// diffChange is a differing path between both sides of the "diff" subcommand.
+type diffChange struct {
+	Path   string   `json:"path"`
+	Change string   `json:"change"`
+	Fields []string `json:"fields,omitempty"`
+}
+
+// diffWalkFS does a virtual walk of the would-be filesystem for one side of the
+// "diff" subcommand, returning its nodes by path. A single (local) archive is
+// walked alone, with its paths made relative to the archive's directory node,
+// so that an archive compares the same as a directory holding only its content.
+func diffWalkFS(ctx context.Context, fsys *filesystem.FS, archive string) (map[string]diffEntry, error) {
+	entries := make(map[string]diffEntry)
+
+	var prefix string
+	if archive != "" {
+		prefix = "/" + strings.TrimSuffix(filepath.Base(archive), ".zip")
+	}
+
+	walkFn := func(p string, _ *fuse.Dirent, node fs.Node, attr fuse.Attr) error {
+		if prefix != "" {
+			p = strings.TrimPrefix(p, prefix)
+		}
+		if p == "" || p == "/" {
+			return nil
+		}
+
+		crc, ok := filesystem.EntryCRC32(node)
+		entries[p] = diffEntry{
+			dir:    attr.Mode.IsDir(),
+			size:   attr.Size,
+			mtime:  attr.Mtime,
+			crc32:  crc,
+			hasCRC: ok,
+		}
+
+		return nil
+	}
+
+	var err error
+	if archive != "" {
+		err = fsys.WalkArchive(ctx, archive, walkFn)
+	} else {
+		err = fsys.Walk(ctx, walkFn)
+	}
+	if err != nil {
+		return nil, dryWalkError(err)
+	}
+
+	return entries, nil
+}
+
+// diffTrees compares the nodes of both sides of the "diff" subcommand, returning
+// the differing paths in sorted order. Directories differ only by their presence,
+// while files also differ by their size, CRC-32 (if known on both sides) and mtime.
+func diffTrees(a, b map[string]diffEntry, ignoreMtime bool) []diffChange {
+	paths := make([]string, 0, len(a)+len(b))
+	for p := range a {
+		paths = append(paths, p)
+	}
+	for p := range b {
+		if _, ok := a[p]; !ok {
+			paths = append(paths, p)
+		}
+	}
+	slices.Sort(paths)
+
+	var changes []diffChange
+
+	for _, p := range paths {
+		ea, inA := a[p]
+		eb, inB := b[p]
+
+		switch {
+		case !inA:
+			changes = append(changes, diffChange{Path: p, Change: "added"})
+
+		case !inB:
+			changes = append(changes, diffChange{Path: p, Change: "removed"})
+
+		case ea.dir != eb.dir:
+			changes = append(changes, diffChange{Path: p, Change: "changed", Fields: []string{"type"}})
+
+		case !ea.dir:
+			var fields []string
+			if ea.size != eb.size {
+				fields = append(fields, "size")
+			}
+			if ea.hasCRC && eb.hasCRC && ea.crc32 != eb.crc32 {
+				fields = append(fields, "crc32")
+			}
+			if !ignoreMtime && !ea.mtime.Equal(eb.mtime) {
+				fields = append(fields, "mtime")
+			}
+			if len(fields) > 0 {
+				changes = append(changes, diffChange{Path: p, Change: "changed", Fields: fields})
+			}
+		}
+	}
+
+	return changes
+}
+
+// diffPrint prints the differing paths of the "diff" subcommand, either as one
+// JSON object per line, or as a report of one line per path ("+" for added,
+// "-" for removed and "~" for changed), followed by a summary line.
+func diffPrint(w io.Writer, changes []diffChange, asJSON bool) error {
+	if asJSON {
+		enc := json.NewEncoder(w)
+		for _, c := range changes {
+			if err := enc.Encode(c); err != nil {
+				return fmt.Errorf("failed to encode: %w", err)
+			}
+		}
+
+		return nil
+	}
+
+	var added, removed, changed int
+
+	for _, c := range changes {
+		switch c.Change {
+		case "added":
+			added++
+			fmt.Fprintf(w, "+ %s\n", c.Path)
+		case "removed":
+			removed++
+			fmt.Fprintf(w, "- %s\n", c.Path)
+		default:
+			changed++
+			fmt.Fprintf(w, "~ %s (%s)\n", c.Path, strings.Join(c.Fields, ", "))
+		}
+	}
+
+	fmt.Fprintf(w, "\n%d added, %d removed, %d changed\n", added, removed, changed)
+
+	return nil
+}
+
+// readArchivesList reads newline-separated archive paths from a file, or
+// from standard input (stdin) if the name is "-", skipping any empty lines.
+func readArchivesList(name string) ([]string, error) {
+	var r io.Reader = os.Stdin
+
+	if name != "-" {
+		f, err := os.Open(name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open: %w", err)
+		}
+		defer f.Close() //nolint:errcheck
+
+		r = f
+	}
+
+	archives := []string{}
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+		if line == "" {
+			continue
+		}
+		archives = append(archives, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read: %w", err)
+	}
+
+	return archives, nil
+}
+
+// exitCode returns the exit code of the program for an error, being distinct
+// for any of the [exitCodeErrors] contained within its chain (otherwise one).
+func exitCode(err error) int {
+	for _, e := range exitCodeErrors {
+		if errors.Is(err, e.err) {
+			return e.code
+		}
+	}
+
+	return exitFailure
+}
+
+// splitList splits the values given to list arguments (e.g. --only-ext),
+// which can be separated by "," or ":" (the latter for use in mount options).
+func splitList(raw string) []string {
+	var vals []string
+
+	for _, val := range strings.FieldsFunc(raw, func(r rune) bool { return r == ',' || r == ':' }) {
+		if val = strings.TrimSpace(val); val != "" {
+			vals = append(vals, val)
+		}
+	}
+
+	return vals
+}
+
+// parseListener parses a --webserver argument of the form "addr[@mode]" into
+// a [webserver.Listener], with the mode being "full" or "readonly" (or else
+// following --webserver-readonly). A "full" mode cannot override the latter.
+func parseListener(raw string, readOnly bool) (webserver.Listener, error) {
+	addr, mode, found := strings.Cut(raw, "@")
+	if addr == "" {
+		return webserver.Listener{}, fmt.Errorf("%w: %q: address must not be empty", errInvalidArgument, raw)
+	}
+
+	switch {
+	case !found:
+		return webserver.Listener{Addr: addr, ReadOnly: readOnly}, nil
+
+	case mode == "readonly":
+		return webserver.Listener{Addr: addr, ReadOnly: true}, nil
+
+	case mode == "full" && readOnly:
+		return webserver.Listener{}, fmt.Errorf("%w: %q: mode \"full\" conflicts with --webserver-readonly", errInvalidArgument, raw)
+
+	case mode == "full":
+		return webserver.Listener{Addr: addr}, nil
+
+	default:
+		return webserver.Listener{}, fmt.Errorf("%w: %q: mode must be \"full\" or \"readonly\"", errInvalidArgument, raw)
+	}
+}
+
+// passwordJSON is the password of the archives matching a glob, as read
+// from the file given to the --password-file argument (JSON array).
+type passwordJSON struct {
+	Glob     string `json:"glob"`
+	Password string `json:"password"`
+}
+
+// readPasswords reads and parses the passwords of archives from a JSON file,
+// keeping their order (as the password of the first matching glob is used).
+func readPasswords(name string) (*filesystem.GlobPasswords, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open: %w", err)
+	}
+	defer f.Close() //nolint:errcheck
+
+	var raw []passwordJSON
+
+	dec := json.NewDecoder(f)
+	dec.DisallowUnknownFields()
+
+	if err := dec.Decode(&raw); err != nil {
+		return nil, fmt.Errorf("failed to decode: %w", err)
+	}
+
+	passwords := &filesystem.GlobPasswords{}
+
+	for i, p := range raw {
+		if err := passwords.Set(p.Glob, p.Password); err != nil {
+			return nil, fmt.Errorf("password %d: %w", i, err)
+		}
+	}
+
+	return passwords, nil
+}
+
+// readSecret reads a secret from a file (e.g. of --webserver-archives-secret),
+// with any trailing newlines removed, returning an error if it is empty.
+func readSecret(name string) ([]byte, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read: %w", err)
+	}
+
+	secret := bytes.TrimRight(data, "\r\n")
+	if len(secret) == 0 {
+		return nil, errEmptySecret
+	}
+
+	return secret, nil
+}
+
+// flagConfig returns the values of all flags by their names, as the configuration
+// within the support bundle of the dashboard (which redacts any secret values).
+func flagConfig(flags *pflag.FlagSet) map[string]string {
+	config := make(map[string]string)
+
+	flags.VisitAll(func(f *pflag.Flag) {
+		config[f.Name] = f.Value.String()
+	})
+
+	return config
+}
+
+// fetchSupportBundle downloads the support bundle from the dashboard at the
+// address (with "http://" assumed, if without a scheme) into the output file
+// ("-" for stdout, or the name given by the dashboard if empty), returning it.
+func fetchSupportBundle(addr string, hashPaths bool, output string) (string, error) {
+	if !strings.Contains(addr, "://") {
+		addr = "http://" + addr
+	}
+
+	target := strings.TrimSuffix(addr, "/") + "/support-bundle"
+	if hashPaths {
+		target += "?hash-paths=true"
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), supportBundleTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return "", fmt.Errorf("%w: invalid address: %w", errInvalidArgument, err)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024)) //nolint:mnd
+
+		return "", fmt.Errorf("%w: %s: %s", errBundleStatus, resp.Status, strings.TrimSpace(string(msg)))
+	}
+
+	if output == "-" {
+		if _, err := io.Copy(os.Stdout, resp.Body); err != nil {
+			return "", fmt.Errorf("failed to write: %w", err)
+		}
+
+		return output, nil
+	}
+
+	if output == "" {
+		output = "zipfuse-support.zip"
+		if _, params, err := mime.ParseMediaType(resp.Header.Get("Content-Disposition")); err == nil {
+			if name := filepath.Base(params["filename"]); strings.HasSuffix(name, ".zip") {
+				output = name
+			}
+		}
+	}
+
+	f, err := os.Create(output)
+	if err != nil {
+		return "", fmt.Errorf("failed to create: %w", err)
+	}
+
+	if _, err := io.Copy(f, resp.Body); err != nil {
+		f.Close()
+
+		return "", fmt.Errorf("failed to write: %w", err)
+	}
+
+	if err := f.Close(); err != nil {
+		return "", fmt.Errorf("failed to close: %w", err)
+	}
+
+	return output, nil
+}
+
+// promptPassword reads a password from the controlling terminal (instead of
+// standard input, which may be piped), without echoing it back as typed.
+func promptPassword() (string, error) {
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return "", fmt.Errorf("failed to open terminal: %w", err)
+	}
+	defer tty.Close() //nolint:errcheck
+
+	fd := int(tty.Fd()) //nolint:gosec
+
+	termios, err := unix.IoctlGetTermios(fd, unix.TCGETS)
+	if err != nil {
+		return "", fmt.Errorf("failed to get terminal state: %w", err)
+	}
+
+	noEcho := *termios
+	noEcho.Lflag &^= unix.ECHO
+
+	if err := unix.IoctlSetTermios(fd, unix.TCSETS, &noEcho); err != nil {
+		return "", fmt.Errorf("failed to disable terminal echo: %w", err)
+	}
+	defer unix.IoctlSetTermios(fd, unix.TCSETS, termios) //nolint:errcheck
+
+	fmt.Fprint(tty, "Password: ")
+	line, err := bufio.NewReader(tty).ReadString('\n')
+	fmt.Fprintln(tty)
+
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", fmt.Errorf("failed to read: %w", err)
+	}
+
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// thresholdRuleJSON is the declarative format of a [filesystem.ThresholdRule],
+// as read from the file given to the --threshold-rules argument (JSON array).
+type thresholdRuleJSON struct {
+	Extensions []string `json:"extensions"`
+	MinSize    string   `json:"minSize"`
+	MaxSize    string   `json:"maxSize"`
+	Mode       string   `json:"mode"`
+}
+
+// readThresholdRules reads and parses the [filesystem.ThresholdRule] from
+// a JSON file, keeping their order (as the first matching rule is applied).
+func readThresholdRules(name string) ([]filesystem.ThresholdRule, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open: %w", err)
+	}
+	defer f.Close() //nolint:errcheck
+
+	var raw []thresholdRuleJSON
+
+	dec := json.NewDecoder(f)
+	dec.DisallowUnknownFields()
+
+	if err := dec.Decode(&raw); err != nil {
+		return nil, fmt.Errorf("failed to decode: %w", err)
+	}
+
+	rules := make([]filesystem.ThresholdRule, 0, len(raw))
+
+	for i, r := range raw {
+		rule := filesystem.ThresholdRule{Extensions: r.Extensions}
+
+		switch r.Mode {
+		case "memory":
+			rule.Mode = filesystem.ThresholdModeMemory
+		case "stream":
+			rule.Mode = filesystem.ThresholdModeStream
+		default:
+			return nil, fmt.Errorf("%w: rule %d: mode must be \"memory\" or \"stream\"", errInvalidArgument, i)
+		}
+
+		if r.MinSize != "" {
+			rule.MinSize, err = humanize.ParseBytes(r.MinSize)
+			if err != nil {
+				return nil, fmt.Errorf("%w: rule %d: failed to parse minSize: %w", errInvalidArgument, i, err)
+			}
+		}
+
+		if r.MaxSize != "" {
+			rule.MaxSize, err = humanize.ParseBytes(r.MaxSize)
+			if err != nil {
+				return nil, fmt.Errorf("%w: rule %d: failed to parse maxSize: %w", errInvalidArgument, i, err)
+			}
+		}
+
+		rules = append(rules, rule)
+	}
+
+	return rules, nil
+}
+
 // recoverSignalsPanic is a helper function to be used in the signal handlers,
 // deferred functions invoke it to recover internally from any goroutine panics.
 func recoverSignalsPanic() {
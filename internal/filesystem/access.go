@@ -0,0 +1,41 @@
+package filesystem
+
+import (
+	"context"
+	"slices"
+	"syscall"
+
+	"bazil.org/fuse"
+)
+
+// uidContextKey is the key of the UID of the requesting client within the
+// context of a served request (as set by [FS.WithContext]).
+type uidContextKey struct{}
+
+// WithContext returns the context for serving a request, carrying the UID of
+// the requesting client for [Options.AllowedUIDs]. It is to be set as the
+// WithContext function of the [fs.Config] that the filesystem is served with.
+func (fsys *FS) WithContext(ctx context.Context, req fuse.Request) context.Context {
+	if len(fsys.Options.AllowedUIDs) == 0 {
+		return ctx
+	}
+
+	return context.WithValue(ctx, uidContextKey{}, req.Hdr().Uid)
+}
+
+// checkAccess returns EACCES for a request (by its context) of a client whose
+// UID is not within [Options.AllowedUIDs] (if any). Requests without a known
+// client (e.g. of the dashboard or subcommands walking the filesystem within
+// the process) are always allowed, as these are not served through FUSE.
+func (fsys *FS) checkAccess(ctx context.Context) error {
+	if len(fsys.Options.AllowedUIDs) == 0 {
+		return nil
+	}
+
+	uid, ok := ctx.Value(uidContextKey{}).(uint32)
+	if !ok || slices.Contains(fsys.Options.AllowedUIDs, uid) {
+		return nil
+	}
+
+	return fuse.ToErrno(syscall.EACCES)
+}
@@ -0,0 +1,82 @@
+package filesystem
+
+import (
+	"io"
+	"syscall"
+	"testing"
+	"time"
+
+	"bazil.org/fuse"
+	"bazil.org/fuse/fs"
+	"github.com/stretchr/testify/require"
+)
+
+// Expectation: The UID of the client should only be carried with AllowedUIDs.
+func Test_FS_WithContext_Success(t *testing.T) {
+	t.Parallel()
+	_, fsys := testFS(t, io.Discard)
+
+	req := &fuse.LookupRequest{Header: fuse.Header{Uid: 1000}}
+
+	ctx := fsys.WithContext(t.Context(), req)
+	require.Nil(t, ctx.Value(uidContextKey{}))
+
+	fsys.Options.AllowedUIDs = []uint32{1000}
+
+	ctx = fsys.WithContext(t.Context(), req)
+	require.Equal(t, uint32(1000), ctx.Value(uidContextKey{}))
+}
+
+// Expectation: Clients within AllowedUIDs (or unknown clients) should be
+// allowed, while any other client should be denied with EACCES.
+func Test_FS_AllowedUIDs_Success(t *testing.T) {
+	t.Parallel()
+	tmpDir, fsys := testFS(t, io.Discard)
+	fsys.Options.AllowedUIDs = []uint32{1000, 1001}
+
+	zipPath := createTestZip(t, tmpDir, "test.zip", []struct {
+		Path    string
+		ModTime time.Time
+		Content []byte
+	}{
+		{Path: "file.txt", ModTime: time.Now(), Content: []byte("content")},
+	})
+
+	allowed := fsys.WithContext(t.Context(), &fuse.LookupRequest{Header: fuse.Header{Uid: 1001}})
+	denied := fsys.WithContext(t.Context(), &fuse.LookupRequest{Header: fuse.Header{Uid: 2000}})
+
+	root := &realDirNode{fsys: fsys, inode: 1, path: tmpDir, mtime: time.Now()}
+
+	_, err := root.ReadDirAll(denied)
+	require.Equal(t, fuse.ToErrno(syscall.EACCES), err)
+
+	_, err = root.Lookup(denied, "test.zip")
+	require.Equal(t, fuse.ToErrno(syscall.EACCES), err)
+
+	ent, err := root.ReadDirAll(allowed)
+	require.NoError(t, err)
+	require.Len(t, ent, 1)
+
+	_, err = root.ReadDirAll(t.Context())
+	require.NoError(t, err)
+
+	node := &zipDirNode{fsys: fsys, inode: fs.GenerateDynamicInode(1, "test"), path: zipPath, mtime: time.Now()}
+
+	_, err = node.ReadDirAll(denied)
+	require.Equal(t, fuse.ToErrno(syscall.EACCES), err)
+
+	_, err = node.Lookup(denied, "file.txt")
+	require.Equal(t, fuse.ToErrno(syscall.EACCES), err)
+
+	fn, err := node.Lookup(allowed, "file.txt")
+	require.NoError(t, err)
+
+	opener, ok := fn.(fs.NodeOpener)
+	require.True(t, ok)
+
+	_, err = opener.Open(denied, &fuse.OpenRequest{Flags: fuse.OpenReadOnly}, &fuse.OpenResponse{})
+	require.Equal(t, fuse.ToErrno(syscall.EACCES), err)
+
+	_, err = opener.Open(allowed, &fuse.OpenRequest{Flags: fuse.OpenReadOnly}, &fuse.OpenResponse{})
+	require.NoError(t, err)
+}
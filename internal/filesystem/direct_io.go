@@ -0,0 +1,126 @@
+package filesystem
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// directIOAlign is the alignment of offsets, lengths and buffers required for
+// reads of files opened with O_DIRECT (see [Options.DirectIO]). The logical
+// block size of most devices is 512 bytes, but the page size satisfies all.
+const directIOAlign = 4096
+
+var _ io.ReaderAt = (*directReaderAt)(nil)
+
+// directReaderAt is an [io.ReaderAt] for a file opened with O_DIRECT. Any
+// read is widened to the aligned blocks containing it, read into an aligned
+// buffer and copied out again, as the kernel rejects any unaligned reads.
+// This allows the [zip.Reader] (and its entries) to read at arbitrary offsets.
+type directReaderAt struct {
+	f *os.File
+}
+
+// openDirect opens a local archive with O_DIRECT, bypassing the page cache.
+// The returned bool is if O_DIRECT is in use, as it is not supported by all
+// filesystems (e.g. tmpfs), in which case the file is opened as by [os.Open].
+func openDirect(path string) (*os.File, bool, error) {
+	f, err := os.OpenFile(path, os.O_RDONLY|syscall.O_DIRECT, 0)
+	if err == nil {
+		return f, true, nil
+	}
+	if !errors.Is(err, syscall.EINVAL) {
+		return nil, false, err //nolint:wrapcheck
+	}
+
+	f, err = os.Open(path)
+	if err != nil {
+		return nil, false, err //nolint:wrapcheck
+	}
+
+	return f, false, nil
+}
+
+func (d *directReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, fmt.Errorf("%w: negative offset", os.ErrInvalid)
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+
+	start := off &^ (directIOAlign - 1)
+	end := (off + int64(len(p)) + directIOAlign - 1) &^ (directIOAlign - 1)
+	skip := int(off - start)
+
+	buf := alignedBuffer(int(end - start))
+
+	n, err := d.pread(buf, start)
+	if n <= skip {
+		if err == nil {
+			err = io.EOF
+		}
+
+		return 0, err //nolint:wrapcheck
+	}
+
+	c := copy(p, buf[skip:n])
+	if c < len(p) {
+		if err == nil {
+			err = io.EOF
+		}
+
+		return c, err //nolint:wrapcheck
+	}
+
+	return c, nil
+}
+
+// pread does a single read of the (aligned) buffer at the (aligned) offset.
+// Unlike [os.File.ReadAt], a short read is not continued at the then unaligned
+// offset (which the kernel would reject), but is returned as the end of file.
+func (d *directReaderAt) pread(buf []byte, off int64) (int, error) {
+	rc, err := d.f.SyscallConn()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get raw conn: %w", err)
+	}
+
+	var n int
+	var rerr error
+
+	err = rc.Read(func(fd uintptr) bool {
+		for {
+			n, rerr = syscall.Pread(int(fd), buf, off)
+			if !errors.Is(rerr, syscall.EINTR) {
+				return true
+			}
+		}
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to read: %w", err)
+	}
+	if rerr != nil {
+		return 0, &os.PathError{Op: "pread", Path: d.f.Name(), Err: rerr}
+	}
+	if n < len(buf) {
+		return n, io.EOF
+	}
+
+	return n, nil
+}
+
+// alignedBuffer returns a buffer of size, starting at an address that is
+// aligned to [directIOAlign], as required for reads of O_DIRECT files.
+func alignedBuffer(size int) []byte {
+	buf := make([]byte, size+directIOAlign)
+
+	shift := 0
+	if rem := int(uintptr(unsafe.Pointer(&buf[0])) & (directIOAlign - 1)); rem != 0 {
+		shift = directIOAlign - rem
+	}
+
+	return buf[shift : shift+size : shift+size]
+}
@@ -0,0 +1,101 @@
+package filesystem
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+	"unsafe"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Expectation: ReadAt should return the same bytes as an unaligned read would,
+// including the short reads at the end of the file.
+func Test_directReaderAt_ReadAt_Success(t *testing.T) {
+	t.Parallel()
+
+	content := bytes.Repeat([]byte("0123456789abcdef"), 3*directIOAlign/16+5)
+	path := filepath.Join(t.TempDir(), "test.bin")
+	require.NoError(t, os.WriteFile(path, content, 0o644))
+
+	f, _, err := openDirect(path)
+	require.NoError(t, err)
+	defer f.Close()
+
+	d := &directReaderAt{f: f}
+
+	tests := []struct {
+		off  int64
+		size int
+	}{
+		{0, 10},
+		{1, directIOAlign},
+		{directIOAlign - 3, 7},
+		{directIOAlign, directIOAlign},
+		{100, 2 * directIOAlign},
+	}
+
+	for _, tt := range tests {
+		p := make([]byte, tt.size)
+
+		n, err := d.ReadAt(p, tt.off)
+		require.NoError(t, err, "offset %d", tt.off)
+		require.Equal(t, tt.size, n)
+		require.Equal(t, content[tt.off:tt.off+int64(tt.size)], p)
+	}
+
+	p := make([]byte, 100)
+	n, err := d.ReadAt(p, int64(len(content)-10))
+	require.ErrorIs(t, err, io.EOF)
+	require.Equal(t, 10, n)
+	require.Equal(t, content[len(content)-10:], p[:n])
+
+	n, err = d.ReadAt(p, int64(len(content)+directIOAlign))
+	require.ErrorIs(t, err, io.EOF)
+	require.Zero(t, n)
+}
+
+// Expectation: alignedBuffer should return a buffer of the size at an aligned address.
+func Test_alignedBuffer_Success(t *testing.T) {
+	t.Parallel()
+
+	for _, size := range []int{1, directIOAlign, 3*directIOAlign + 1} {
+		buf := alignedBuffer(size)
+		require.Len(t, buf, size)
+		require.Zero(t, uintptr(unsafe.Pointer(&buf[0]))%directIOAlign)
+	}
+}
+
+// Expectation: Archives should be read correctly with DirectIO.
+func Test_FS_DirectIO_Success(t *testing.T) {
+	t.Parallel()
+
+	tmpDir, fsys := testFS(t, io.Discard)
+	fsys.Options.DirectIO = true
+
+	content := bytes.Repeat([]byte("direct"), 2000)
+	zipPath := createTestZip(t, tmpDir, "test.zip", []struct {
+		Path    string
+		ModTime time.Time
+		Content []byte
+	}{
+		{Path: "file.txt", ModTime: time.Now(), Content: content},
+	})
+
+	zr, err := fsys.fdcache.Archive(zipPath)
+	require.NoError(t, err)
+	defer zr.Release() //nolint:errcheck
+
+	require.Len(t, zr.File, 1)
+
+	rc, err := zr.File[0].Open()
+	require.NoError(t, err)
+	defer rc.Close()
+
+	data, err := io.ReadAll(rc)
+	require.NoError(t, err)
+	require.Equal(t, content, data)
+}
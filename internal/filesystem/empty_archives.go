@@ -0,0 +1,71 @@
+package filesystem
+
+import (
+	"errors"
+	"os"
+	"time"
+
+	"github.com/jellydator/ttlcache/v3"
+)
+
+// emptyArchive is the cached result of checking an archive for any entries,
+// which is valid for as long as the archive has the same mtime and size.
+type emptyArchive struct {
+	mtime time.Time
+	size  int64
+	empty bool
+}
+
+// emptyArchives is a bounded, thread-safe cache of which archives have no
+// entries at all (see [Options.EmptyArchiveAs]), so that these are not opened
+// on every listing and lookup. An archive is checked again once it was
+// modified. Upon reaching capacity, the least recently used archive is evicted
+// (and checked again on its next listing or lookup).
+type emptyArchives struct {
+	entries *ttlcache.Cache[string, emptyArchive]
+}
+
+// newEmptyArchives returns a pointer to a new [emptyArchives].
+func newEmptyArchives(size int) *emptyArchives {
+	return &emptyArchives{
+		entries: ttlcache.New(
+			ttlcache.WithCapacity[string, emptyArchive](uint64(size)),
+		),
+	}
+}
+
+// emptyArchive returns if an archive is valid, but has no entries at all, to
+// be presented according to [Options.EmptyArchiveAs] instead of as a directory.
+// Archives which cannot be opened (or are still being written) are never empty,
+// so that these fail (or are presented) as any other archive would.
+func (fsys *FS) emptyArchive(path string, info os.FileInfo) bool {
+	if fsys.Options.EmptyArchiveAs == EmptyArchiveDir {
+		return false
+	}
+
+	if item := fsys.empty.entries.Get(path); item != nil {
+		if e := item.Value(); e.mtime.Equal(info.ModTime()) && e.size == info.Size() {
+			return e.empty
+		}
+	}
+
+	zr, _, err := fsys.fdcache.archive(path)
+	if err != nil {
+		if !errors.Is(err, errArchiveIncomplete) && !errors.Is(err, errArchiveTripped) && !errors.Is(err, errArchivesPaused) {
+			fsys.rbuf.Printf("%q->EmptyArchive: ZIP Error: %v\n", path, err)
+		}
+
+		return false // Not cached, as it may yet become readable.
+	}
+	defer zr.Release() //nolint:errcheck
+
+	e := emptyArchive{
+		mtime: info.ModTime(),
+		size:  info.Size(),
+		empty: len(zr.File) == 0,
+	}
+
+	fsys.empty.entries.Set(path, e, ttlcache.NoTTL)
+
+	return e.empty
+}
@@ -0,0 +1,169 @@
+package filesystem
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// errArchiveTripped is for an archive with a tripped circuit breaker.
+var errArchiveTripped = errors.New("archive circuit breaker tripped")
+
+// FailedArchive describes an archive that has recently failed to open.
+type FailedArchive struct {
+	Path         string    `json:"path"`
+	LastError    string    `json:"lastError"`
+	LastSeen     time.Time `json:"lastSeen"`
+	Count        int64     `json:"count"`
+	TrippedUntil time.Time `json:"trippedUntil,omitzero"`
+
+	windowStart time.Time // Start of the consecutive failures window.
+	consecutive int       // Consecutive failures within the window.
+	probeUntil  time.Time // End of the pending probe (after cooldown).
+}
+
+// failedArchives is a bounded, thread-safe registry of [FailedArchive].
+// Upon reaching capacity, the least recently failed archive is evicted.
+//
+// It also implements a per-archive circuit breaker: once an archive has
+// failed to open threshold times (consecutively) within the window, the
+// breaker trips and further opening attempts are rejected until cooldown
+// has passed. Thereafter, a single probe is allowed (with all other attempts
+// still being rejected), which either resets the breaker (upon success) or
+// trips it again for another cooldown period. A probe that does neither
+// (e.g. for an incomplete archive) is given up after another cooldown.
+type failedArchives struct {
+	sync.Mutex
+
+	size      int
+	threshold int
+	window    time.Duration
+	cooldown  time.Duration
+	entries   map[string]*FailedArchive
+}
+
+// newFailedArchives returns a pointer to a new [failedArchives].
+// A threshold of zero disables the circuit breaker functionality.
+func newFailedArchives(size int, threshold int, window time.Duration, cooldown time.Duration) *failedArchives {
+	return &failedArchives{
+		size:      size,
+		threshold: threshold,
+		window:    window,
+		cooldown:  cooldown,
+		entries:   make(map[string]*FailedArchive),
+	}
+}
+
+// Add records a failure to open an archive, along with the error.
+// It returns true if the failure has (newly) tripped the circuit breaker.
+func (fa *failedArchives) Add(path string, err error) bool {
+	if fa.size <= 0 {
+		return false
+	}
+
+	fa.Lock()
+	defer fa.Unlock()
+
+	now := time.Now()
+
+	e, ok := fa.entries[path]
+	if !ok {
+		if len(fa.entries) >= fa.size {
+			var oldest *FailedArchive
+			for _, e := range fa.entries {
+				if oldest == nil || e.LastSeen.Before(oldest.LastSeen) {
+					oldest = e
+				}
+			}
+			delete(fa.entries, oldest.Path)
+		}
+
+		e = &FailedArchive{Path: path, windowStart: now}
+		fa.entries[path] = e
+	}
+
+	if fa.window > 0 && now.Sub(e.windowStart) > fa.window {
+		e.windowStart = now
+		e.consecutive = 0
+	}
+
+	// A failed probe always trips again, also if the window has passed.
+	probe := !e.probeUntil.IsZero()
+	e.probeUntil = time.Time{}
+
+	e.LastError = err.Error()
+	e.LastSeen = now
+	e.Count++
+	e.consecutive++
+
+	if fa.threshold > 0 && (e.consecutive >= fa.threshold || probe) {
+		e.TrippedUntil = now.Add(fa.cooldown)
+
+		return true
+	}
+
+	return false
+}
+
+// Tripped returns true if the circuit breaker for an archive is tripped.
+// Once cooldown has passed, it returns false once to allow for a probing
+// attempt, but true again for all others until that probe has concluded.
+func (fa *failedArchives) Tripped(path string) bool {
+	if fa.threshold <= 0 {
+		return false
+	}
+
+	fa.Lock()
+	defer fa.Unlock()
+
+	e, ok := fa.entries[path]
+	if !ok || e.TrippedUntil.IsZero() {
+		return false
+	}
+
+	now := time.Now()
+	if now.Before(e.TrippedUntil) || now.Before(e.probeUntil) {
+		return true
+	}
+
+	e.probeUntil = now.Add(fa.cooldown)
+
+	return false
+}
+
+// Remove removes an archive from the registry (e.g. when it opened again).
+// This also resets the circuit breaker of the archive, if it had tripped.
+func (fa *failedArchives) Remove(path string) {
+	fa.Lock()
+	defer fa.Unlock()
+
+	delete(fa.entries, path)
+}
+
+// List returns a copy of all [FailedArchive] sorted by their path.
+func (fa *failedArchives) List() []FailedArchive {
+	fa.Lock()
+	defer fa.Unlock()
+
+	out := make([]FailedArchive, 0, len(fa.entries))
+	for _, e := range fa.entries {
+		out = append(out, *e)
+	}
+
+	slices.SortFunc(out, func(a, b FailedArchive) int {
+		return strings.Compare(a.Path, b.Path)
+	})
+
+	return out
+}
+
+// Reset removes all archives from the registry.
+// This also resets the circuit breakers of all archives.
+func (fa *failedArchives) Reset() {
+	fa.Lock()
+	defer fa.Unlock()
+
+	fa.entries = make(map[string]*FailedArchive)
+}
@@ -0,0 +1,254 @@
+package filesystem
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Expectation: Add should record a new failure and count repeated ones.
+func Test_failedArchives_Add_Success(t *testing.T) {
+	t.Parallel()
+
+	fa := newFailedArchives(10, 0, 0, 0)
+
+	fa.Add("/a.zip", errors.New("first error"))
+	fa.Add("/a.zip", errors.New("second error"))
+	fa.Add("/b.zip", errors.New("other error"))
+
+	list := fa.List()
+	require.Len(t, list, 2)
+
+	require.Equal(t, "/a.zip", list[0].Path)
+	require.Equal(t, "second error", list[0].LastError)
+	require.Equal(t, int64(2), list[0].Count)
+	require.NotZero(t, list[0].LastSeen)
+
+	require.Equal(t, "/b.zip", list[1].Path)
+	require.Equal(t, "other error", list[1].LastError)
+	require.Equal(t, int64(1), list[1].Count)
+}
+
+// Expectation: Add should evict the least recently failed archive when full.
+func Test_failedArchives_Add_Eviction_Success(t *testing.T) {
+	t.Parallel()
+
+	fa := newFailedArchives(2, 0, 0, 0)
+
+	fa.Add("/a.zip", errors.New("error"))
+	time.Sleep(time.Millisecond)
+	fa.Add("/b.zip", errors.New("error"))
+	time.Sleep(time.Millisecond)
+	fa.Add("/a.zip", errors.New("error"))
+	time.Sleep(time.Millisecond)
+	fa.Add("/c.zip", errors.New("error"))
+
+	list := fa.List()
+	require.Len(t, list, 2)
+	require.Equal(t, "/a.zip", list[0].Path)
+	require.Equal(t, "/c.zip", list[1].Path)
+}
+
+// Expectation: Add should be a no-op for a registry without capacity.
+func Test_failedArchives_Add_ZeroSize_Success(t *testing.T) {
+	t.Parallel()
+
+	fa := newFailedArchives(0, 0, 0, 0)
+	fa.Add("/a.zip", errors.New("error"))
+
+	require.Empty(t, fa.List())
+}
+
+// Expectation: Remove and Reset should remove archives from the registry.
+func Test_failedArchives_RemoveReset_Success(t *testing.T) {
+	t.Parallel()
+
+	fa := newFailedArchives(10, 0, 0, 0)
+
+	fa.Add("/a.zip", errors.New("error"))
+	fa.Add("/b.zip", errors.New("error"))
+
+	fa.Remove("/a.zip")
+	list := fa.List()
+	require.Len(t, list, 1)
+	require.Equal(t, "/b.zip", list[0].Path)
+
+	fa.Reset()
+	require.Empty(t, fa.List())
+}
+
+// Expectation: Failing to open an archive should register it with the FS,
+// and a successful re-open should remove it from the registry again.
+func Test_FS_FailedArchives_Success(t *testing.T) {
+	t.Parallel()
+	tmpDir, fsys := testFS(t, io.Discard)
+
+	path := filepath.Join(tmpDir, "test.zip")
+	require.NoError(t, os.WriteFile(path, []byte("not a zip file"), 0o644))
+
+	zr, err := newZipReader(fsys, path)
+	require.Error(t, err)
+	require.Nil(t, zr)
+
+	list := fsys.FailedArchives()
+	require.Len(t, list, 1)
+	require.Equal(t, path, list[0].Path)
+	require.Equal(t, int64(1), list[0].Count)
+
+	createTestZip(t, tmpDir, "test.zip", []struct {
+		Path    string
+		ModTime time.Time
+		Content []byte
+	}{
+		{Path: "file.txt", ModTime: time.Now(), Content: []byte("content")},
+	})
+
+	zr, err = newZipReader(fsys, path)
+	require.NoError(t, err)
+	require.NoError(t, zr.Release())
+
+	require.Empty(t, fsys.FailedArchives())
+
+	_, err = newZipReader(fsys, filepath.Join(tmpDir, "missing.zip"))
+	require.Error(t, err)
+	require.Len(t, fsys.FailedArchives(), 1)
+
+	fsys.ResetFailedArchives()
+	require.Empty(t, fsys.FailedArchives())
+}
+
+// Expectation: Add should trip the breaker after threshold consecutive failures,
+// Tripped should reject until cooldown has passed and then allow for a probe.
+func Test_failedArchives_Tripped_Success(t *testing.T) {
+	t.Parallel()
+
+	fa := newFailedArchives(10, 3, time.Minute, 50*time.Millisecond)
+
+	require.False(t, fa.Add("/a.zip", errors.New("error")))
+	require.False(t, fa.Add("/a.zip", errors.New("error")))
+	require.False(t, fa.Tripped("/a.zip"))
+
+	require.True(t, fa.Add("/a.zip", errors.New("error")))
+	require.True(t, fa.Tripped("/a.zip"))
+	require.False(t, fa.Tripped("/b.zip"))
+
+	list := fa.List()
+	require.Len(t, list, 1)
+	require.NotZero(t, list[0].TrippedUntil)
+
+	time.Sleep(100 * time.Millisecond)
+	require.False(t, fa.Tripped("/a.zip"))
+
+	require.True(t, fa.Add("/a.zip", errors.New("error")))
+	require.True(t, fa.Tripped("/a.zip"))
+
+	fa.Remove("/a.zip")
+	require.False(t, fa.Tripped("/a.zip"))
+}
+
+// Expectation: Tripped should allow only a single probe after cooldown, still
+// rejecting all others until the probe has either succeeded or tripped again.
+func Test_failedArchives_Tripped_SingleProbe_Success(t *testing.T) {
+	t.Parallel()
+
+	fa := newFailedArchives(10, 1, time.Minute, 50*time.Millisecond)
+
+	require.True(t, fa.Add("/a.zip", errors.New("error")))
+	require.True(t, fa.Tripped("/a.zip"))
+
+	time.Sleep(100 * time.Millisecond)
+	require.False(t, fa.Tripped("/a.zip")) // probe
+	require.True(t, fa.Tripped("/a.zip"))
+	require.True(t, fa.Tripped("/a.zip"))
+
+	require.True(t, fa.Add("/a.zip", errors.New("error"))) // probe failed
+	require.True(t, fa.Tripped("/a.zip"))
+
+	time.Sleep(100 * time.Millisecond)
+	require.False(t, fa.Tripped("/a.zip")) // probe
+	require.True(t, fa.Tripped("/a.zip"))
+
+	fa.Remove("/a.zip") // probe succeeded
+	require.False(t, fa.Tripped("/a.zip"))
+	require.False(t, fa.Tripped("/a.zip"))
+}
+
+// Expectation: A failed probe should trip the breaker again, also when the
+// failures window has passed since the failures that tripped it before.
+func Test_failedArchives_Tripped_ProbeWindow_Success(t *testing.T) {
+	t.Parallel()
+
+	fa := newFailedArchives(10, 2, 50*time.Millisecond, 50*time.Millisecond)
+
+	require.False(t, fa.Add("/a.zip", errors.New("error")))
+	require.True(t, fa.Add("/a.zip", errors.New("error")))
+
+	time.Sleep(100 * time.Millisecond)
+	require.False(t, fa.Tripped("/a.zip")) // probe
+
+	require.True(t, fa.Add("/a.zip", errors.New("error")))
+	require.True(t, fa.Tripped("/a.zip"))
+}
+
+// Expectation: Add should not trip the breaker for failures outside the window.
+func Test_failedArchives_Tripped_Window_Success(t *testing.T) {
+	t.Parallel()
+
+	fa := newFailedArchives(10, 2, 50*time.Millisecond, time.Minute)
+
+	require.False(t, fa.Add("/a.zip", errors.New("error")))
+	time.Sleep(100 * time.Millisecond)
+	require.False(t, fa.Add("/a.zip", errors.New("error")))
+	require.False(t, fa.Tripped("/a.zip"))
+
+	require.True(t, fa.Add("/a.zip", errors.New("error")))
+	require.True(t, fa.Tripped("/a.zip"))
+}
+
+// Expectation: A zero threshold should never trip the breaker.
+func Test_failedArchives_Tripped_Disabled_Success(t *testing.T) {
+	t.Parallel()
+
+	fa := newFailedArchives(10, 0, time.Minute, time.Minute)
+
+	for range 10 {
+		require.False(t, fa.Add("/a.zip", errors.New("error")))
+	}
+	require.False(t, fa.Tripped("/a.zip"))
+}
+
+// Expectation: newZipReader should reject a tripped archive without opening it.
+func Test_newZipReader_Tripped_Error(t *testing.T) {
+	t.Parallel()
+	tmpDir, fsys := testFS(t, io.Discard)
+
+	fsys.failed = newFailedArchives(10, 2, time.Minute, time.Minute)
+
+	path := filepath.Join(tmpDir, "test.zip")
+	require.NoError(t, os.WriteFile(path, []byte("not a zip file"), 0o644))
+
+	for range 2 {
+		_, err := newZipReader(fsys, path)
+		require.Error(t, err)
+		require.NotErrorIs(t, err, errArchiveTripped)
+	}
+
+	createTestZip(t, tmpDir, "test.zip", []struct {
+		Path    string
+		ModTime time.Time
+		Content []byte
+	}{
+		{Path: "file.txt", ModTime: time.Now(), Content: []byte("content")},
+	})
+
+	zr, err := newZipReader(fsys, path)
+	require.ErrorIs(t, err, errArchiveTripped)
+	require.Nil(t, zr)
+	require.Equal(t, int64(1), fsys.Metrics.TotalBreakerRejects.Load())
+	require.Equal(t, int64(2), fsys.FailedArchives()[0].Count)
+}
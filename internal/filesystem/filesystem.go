@@ -5,7 +5,12 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
+	"net/http"
 	"os"
+	"path/filepath"
+	"runtime/debug"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -13,22 +18,65 @@ import (
 	"bazil.org/fuse"
 	"bazil.org/fuse/fs"
 	"github.com/desertwitch/zipfuse/internal/logging"
+	"github.com/klauspost/compress/zip"
+	"golang.org/x/text/unicode/norm"
 )
 
 const (
 	fileBasePerm = 0o444 // RO
+	fileExecPerm = 0o111 // +X (see Options.PreservePermissions)
 	dirBasePerm  = 0o555 // RO
 
+	failedArchivesSize = 1000
+	emptyArchivesSize  = 10000
+	sfxArchivesSize    = 10000
+
+	defaultAllowRawNameLookup = false
+	defaultAllowXattrControl  = false
+	defaultArchiveMarker      = false
+	defaultArchiveXattrs      = false
+	defaultBreakerCooldown    = 30 * time.Second
+	defaultBreakerThreshold   = 5
+	defaultBreakerWindow      = 60 * time.Second
+	defaultCacheDirEntries    = true
+	defaultCacheFileContent   = true
+	defaultDedupIdentical     = false
+	defaultDetectSFX          = false
+	defaultDirectIO           = false
+	defaultDirSizes           = false
+	defaultEmptyArchiveAs     = EmptyArchiveDir
+	defaultExposeRaw          = false
 	defaultFDCacheBypass      = false
 	defaultFDCacheSize        = 256
 	defaultFDCacheTTL         = 60 * time.Second
 	defaultFDLimit            = 512
+	defaultFlatCollisions     = FlatCollisionIndex
+	defaultFlatDerefSymlinks  = false
+	defaultFlatDotfileStyle   = DotfileFlatPrefix
 	defaultFlatMode           = false
 	defaultForceUnicode       = true
+	defaultHideHidden         = false
+	defaultMaxInMemoryBytes   = 0
+	defaultMaxListEntries     = 0
+	defaultMaxTotalInMemory   = 0
+	defaultNestedConflicts    = NestedConflictDirectory
+	defaultMetadataOnly       = false
 	defaultMustCRC32          = false
+	defaultPreserveOwnership  = false
+	defaultPreservePerms      = false
+	defaultRealSort           = RealSortName
+	defaultRealSortDirsFirst  = false
+	defaultSizeMode           = SizeUncompressed
 	defaultStreamingThreshold = 1 * 1024 * 1024 // 1MiB
 	defaultStreamPoolSize     = 128 * 1024      // 128KiB
+	defaultStreamRetries      = 2
 	defaultStrictCache        = false
+	defaultTailMode           = false
+	defaultTailWindow         = 5 * time.Minute
+	defaultTimestampTZ        = TimestampUTC
+	defaultUnicodeNormalize   = UnicodeNormalizeNone
+	defaultWalkContinue       = false
+	defaultWalkTimeout        = 0
 )
 
 var (
@@ -37,65 +85,547 @@ var (
 
 	// errInvalidArgument is for an invalid constructor argument.
 	errInvalidArgument = errors.New("invalid argument")
+
+	// ErrArchiveUnreadable is for a ZIP archive that cannot be opened or read.
+	ErrArchiveUnreadable = errors.New("archive unreadable")
+
+	// ErrEntryNotFound is for a path that does not exist within a ZIP archive.
+	ErrEntryNotFound = errors.New("entry not found")
+
+	// ErrNotArchive is for a path that is not a ZIP archive within the source directory.
+	ErrNotArchive = errors.New("not an archive within source directory")
+
+	// ErrFDLimitReached is for a file that was not opened, as all the slots of
+	// the FD semaphore (see [Options.FDLimit]) were in use at the time.
+	ErrFDLimitReached = errors.New("file descriptor limit reached")
+
+	// ErrUnsupportedMethod is for a ZIP-contained file of a compression method
+	// that cannot be read (e.g. LZMA or PPMd), while it is still being listed.
+	ErrUnsupportedMethod = errors.New("unsupported compression method")
+
+	// ErrEncryptedEntry is for a ZIP-contained file that is encrypted, but no
+	// (or a wrong) password is known for its archive (see [Options.Passwords]).
+	ErrEncryptedEntry = errors.New("encrypted entry")
+
+	// ErrCorruptEntry is for a ZIP-contained file failing integrity checking
+	// or decompression, meaning its contents cannot be read back as stored.
+	ErrCorruptEntry = errors.New("corrupt entry")
+
+	// ErrSkipDir can be returned by a [WalkFunc] to skip over the directory
+	// of the visited node (not walking into its contents), without an error.
+	ErrSkipDir = errors.New("skip this directory")
+
+	// ErrWalkTimeout is for a call on a node of a walk (e.g. listing an archive)
+	// that was abandoned after taking longer than [Options.WalkTimeout].
+	ErrWalkTimeout = errors.New("walk timeout")
+
+	// ErrWalkIncomplete is for a walk that was continued past failed nodes
+	// (see [Options.WalkContinueOnError] and [WalkIncompleteError]).
+	ErrWalkIncomplete = errors.New("walk incomplete")
+
+	// errLimitReached stops a walk once enough results were collected.
+	errLimitReached = errors.New("limit reached")
+)
+
+// EmptyArchiveMode is how a valid archive without any entries is presented
+// within the directories of the source (see [Options.EmptyArchiveAs]).
+type EmptyArchiveMode int
+
+const (
+	// EmptyArchiveDir presents the archive as an empty directory (as any other).
+	EmptyArchiveDir EmptyArchiveMode = iota
+
+	// EmptyArchiveHidden does not present the archive at all (being skipped).
+	EmptyArchiveHidden
+
+	// EmptyArchiveFile presents the archive as a regular file (with its ".zip"
+	// extension), passing through the (raw) bytes of the archive itself.
+	EmptyArchiveFile
+)
+
+// FlatCollisionStrategy is how [Options.FlatMode] resolves name collisions.
+type FlatCollisionStrategy int
+
+const (
+	// FlatCollisionIndex appends the archive-internal index to all filenames
+	// (e.g. "file(0).txt", "file(1).txt"), regardless of actual collisions.
+	FlatCollisionIndex FlatCollisionStrategy = iota
+
+	// FlatCollisionDirectory keeps the plain filenames and only prepends the
+	// parent directory on collision (e.g. "dirA_file.txt", "dirB_file.txt"),
+	// falling back to [FlatCollisionIndex] when even that would still collide.
+	FlatCollisionDirectory
+)
+
+// DotfileFlatStyle is how [Options.FlatMode] appends the archive-internal index
+// to the names of dotfiles (e.g. ".gitignore"), which have no extension.
+type DotfileFlatStyle int
+
+const (
+	// DotfileFlatPrefix treats the whole name as an extension, so that the
+	// index is put in front of it (e.g. "(3).gitignore"), as for any other file.
+	DotfileFlatPrefix DotfileFlatStyle = iota
+
+	// DotfileFlatSuffix keeps the name intact and appends the index to it
+	// (e.g. ".gitignore(3)"), so that dotfiles remain recognizable as such.
+	DotfileFlatSuffix
 )
 
+// NestedConflictStrategy is how a name within an archive (in nested mode) is
+// presented, if it is both a file and a directory (e.g. "foo" and "foo/bar").
+type NestedConflictStrategy int
+
+const (
+	// NestedConflictDirectory presents the name as a directory, so the
+	// contained files remain accessible (at the cost of the file itself).
+	NestedConflictDirectory NestedConflictStrategy = iota
+
+	// NestedConflictFile presents the name as a file, so the file remains
+	// accessible (at the cost of the files contained within the directory).
+	NestedConflictFile
+)
+
+// RealSortOrder is how the directories and archives within the directories of
+// the source (not within archives) are ordered when listed, see [Options.RealSort].
+type RealSortOrder int
+
+const (
+	// RealSortName orders the entries by their name (ascending).
+	RealSortName RealSortOrder = iota
+
+	// RealSortMtime orders the entries by their modified time (newest first).
+	RealSortMtime
+
+	// RealSortSize orders the entries by their size (largest first), with
+	// directories having no size (and so being ordered after all archives).
+	RealSortSize
+)
+
+// SizeMode is the size that is reported for the files within archives (as their
+// attributes), see [Options.SizeMode]. Their contents are read in full regardless.
+type SizeMode int
+
+const (
+	// SizeUncompressed reports the uncompressed sizes (those of the contents).
+	SizeUncompressed SizeMode = iota
+
+	// SizeCompressed reports the compressed sizes (as stored within the archive),
+	// so that e.g. du(1) reflects the actual footprint of the files within it.
+	// Reads are not limited to these sizes, but bypass the page cache instead.
+	SizeCompressed
+)
+
+// TimestampZone is the timezone that the timestamps of files within archives,
+// which are only stored as MS-DOS date and time (having no timezone at all),
+// are interpreted in (see [Options.TimestampTZ]). The extended timestamps (e.g.
+// of the Unix or NTFS extra fields) are absolute, so are never reinterpreted.
+type TimestampZone int
+
+const (
+	// TimestampUTC interprets the timestamps as UTC, so that the same archive
+	// reports the same timestamps on all hosts, regardless of their timezone.
+	TimestampUTC TimestampZone = iota
+
+	// TimestampLocal interprets the timestamps in the local timezone of the host
+	// (as most extraction tools do), so the reported timestamps are the same as
+	// those of extracted files, but differ on hosts in different timezones.
+	TimestampLocal
+)
+
+// UnicodeNormalization is the Unicode normalization form that all names within
+// archives are canonicalized into (see [Options.UnicodeNormalize]), so that the
+// same name differing only in its form (e.g. as stored by macOS) is matched.
+type UnicodeNormalization int
+
+const (
+	// UnicodeNormalizeNone presents the names as they are stored in the archive.
+	UnicodeNormalizeNone UnicodeNormalization = iota
+
+	// UnicodeNormalizeNFC canonicalizes the names into the composed form (NFC),
+	// as is expected by most tools (e.g. "é" being a single code point).
+	UnicodeNormalizeNFC
+
+	// UnicodeNormalizeNFD canonicalizes the names into the decomposed form (NFD),
+	// as is stored by macOS (e.g. "é" being "e" followed by a combining accent).
+	UnicodeNormalizeNFD
+)
+
+// apply returns the string canonicalized into the Unicode normalization form.
+func (u UnicodeNormalization) apply(s string) string {
+	switch u {
+	case UnicodeNormalizeNFC:
+		return norm.NFC.String(s)
+	case UnicodeNormalizeNFD:
+		return norm.NFD.String(s)
+	case UnicodeNormalizeNone:
+	}
+
+	return s
+}
+
 // Options contains all settings for the operation of the filesystem.
 // All non-atomic fields can no longer be modified at runtime (once mounted).
 type Options struct {
+	// BreakerThreshold is the amount of consecutive failures to open an archive
+	// (within [Options.BreakerWindow]), after which any further attempts are
+	// rejected without accessing the disk (for [Options.BreakerCooldown]).
+	// After cooldown, a single probe is allowed, which either resets or re-trips.
+	// A value of zero disables the circuit breaker for failing archives.
+	BreakerThreshold int
+
+	// BreakerWindow is the window in which consecutive failures are counted.
+	// A value of zero means that consecutive failures are counted forever.
+	BreakerWindow time.Duration
+
+	// BreakerCooldown is the time that opening attempts are rejected for,
+	// once the circuit breaker of a consistently-failing archive has tripped.
+	BreakerCooldown time.Duration
+
 	// FDLimit is the absolute limit on open file descriptors at any time.
 	// It must be larger than [Options.FDCacheSize], but beware the OS limits.
+	// It is fixed once the filesystem is constructed (see [FS.FDLimit]).
 	FDLimit int
 
 	// FDCacheBypass circumvents the cache for ZIP file descriptors.
 	// When enabled at runtime, in-flight descriptors will close after TTL.
 	FDCacheBypass atomic.Bool
 
+	// DirSizes controls if directories within ZIPs report the total size of
+	// all their contained files (recursively), e.g. for "ls -l" or "stat".
+	// This requires opening archives already on their attributes, while the
+	// sizes are computed once per archive and cached with its FD cache entry.
+	DirSizes atomic.Bool
+
 	// FDCacheSize is the size of the cache for ZIP file descriptors.
 	// It must be smaller than [Options.FDLimit], otherwise may cause deadlock.
+	// It is fixed once the filesystem is constructed (see [FS.FDCacheSize]).
 	FDCacheSize int
 
 	// FDCacheTTL is the time-to-live for ZIP file descriptors in the cache.
 	// If a file descriptor is no longer in use, it will be evicted after TTL.
 	FDCacheTTL time.Duration
 
+	// PinGlobs are globs of archives (matched against their paths relative to
+	// the source) that are pinned in the FD cache once opened, not being evicted
+	// by TTL or capacity until unpinned. As pinned archives still count toward
+	// [Options.FDLimit], at most what remains of it besides [Options.FDCacheSize]
+	// (less one) are pinned, with any further ones being cached as usual instead.
+	PinGlobs GlobOverrides[bool]
+
+	// MaxListEntries is the maximum amount of entries listed for directories
+	// within ZIPs, after which a listing is truncated (ending with a marker).
+	// Lookups are not limited, so non-listed entries still remain accessible.
+	// The listings of walks (e.g. [FS.Walk]) are never truncated, as these
+	// need to visit every entry (and could not look up the marker entry).
+	// A value of zero means that the listings of directories are unlimited.
+	MaxListEntries int
+
 	// StreamPoolSize is the buffer size for the streamed read buffer pool.
 	// This value multiplies with concurrency; a common read size makes sense,
 	// in particular one that aligns well with page size/FUSE readahead setting.
 	StreamPoolSize int
 
+	// StreamRetries is the maximum amount of retries of a streamed read that
+	// failed with a likely transient error (e.g. ESTALE on network storage),
+	// each re-opening the entry and retrying from the requested offset. Corrupt
+	// entries are never retried. A value of zero disables the retrying of reads.
+	StreamRetries int
+
 	// StrictCache controls if ZIP files/contents should be treated as
 	// immutable for caching decisions (and invalidation of cached content).
 	// If disabled, ZIPs are considered immutable (non-changing) for caching.
+	// If enabled, archives held open by the FD cache are re-opened once their
+	// backing file was replaced (e.g. by an atomic rename) or modified, and
+	// failing reads of streamed files fail with ESTALE, if their archive was
+	// modified while open (instead of returning data of the changed offsets).
 	StrictCache bool
 
+	// DedupIdentical controls if files within ZIPs having the same content as
+	// an earlier file of the same archive (by CRC-32 and uncompressed size, as
+	// in the central directory) are hidden, so that only the first is presented.
+	// Unlike the deduplication of names, such files have differing names.
+	DedupIdentical bool
+
+	// DetectSFX controls if regular files without the ".zip" extension are
+	// sniffed for an appended archive (e.g. self-extracting executables), being
+	// presented as directories under their full names if one is found. Each
+	// file is sniffed once (by its mtime, for up to 10000 recently used files),
+	// reading only its end (with a file descriptor of [Options.FDLimit]).
+	DetectSFX bool
+
+	// DirectIO controls if local archives are opened with O_DIRECT, so that
+	// reading them bypasses the page cache (where supported by the filesystem,
+	// otherwise silently falling back to regular reads). All reads are widened
+	// to aligned blocks, so this trades more (uncached) disk reads for not
+	// having archive data held twice (in the page cache and the buffers).
+	DirectIO bool
+
+	// EmptyArchiveAs is how a valid archive without any entries is presented,
+	// as some tools consider an empty directory (where a file was) an error.
+	// The amount of entries is checked once for each archive (by its mtime,
+	// for up to 10000 recently used archives).
+	EmptyArchiveAs EmptyArchiveMode
+
+	// Entry is the path of a file within the archive which is served as the
+	// whole filesystem (its root being that file), instead of the archive's
+	// directory tree. It requires a single archive as source, being either a
+	// remote archive or a local ZIP file, e.g. for attaching a disk image that
+	// is stored within a ZIP to a loop device (without extracting it first).
+	Entry string
+
+	// CacheDirEntries controls if the listings of directories within ZIPs can
+	// be kept cached by the kernel (between opens), e.g. to be disabled for
+	// archives which have entries appended over time. Has no effect with
+	// [Options.StrictCache], which never has the kernel keep anything cached.
+	CacheDirEntries bool
+
+	// CacheFileContent controls if the contents of files within ZIPs can be
+	// kept cached by the kernel (between opens), independently of the caching
+	// of listings. Has no effect with [Options.StrictCache], as above.
+	CacheFileContent bool
+
 	// ForceUnicode controls if unicode should be enforced for all ZIP paths.
 	// Beware: If disabled, non-compliant ZIPs may end up with garbled paths.
 	ForceUnicode bool
 
+	// RealSort is the order of the directories and archives when listing the
+	// directories of the source (not within archives), with ties by their name.
+	// This orders the dry-run output and the tree mainly, as most tools (and
+	// possibly the kernel) re-sort any listings, unlike e.g. dashboard clients.
+	RealSort RealSortOrder
+
+	// RealSortDirsFirst controls if the directories are grouped before all the
+	// archives when listing the directories of the source (with [Options.RealSort]
+	// applying within each group), instead of both being ordered together.
+	RealSortDirsFirst bool
+
+	// HideHidden controls if the hidden directories and archives (with names
+	// starting with ".") within the directories of the source are not presented
+	// (e.g. ".snapshots" or ".Trash"), neither in listings nor by lookups.
+	// The names within archives are not affected.
+	HideHidden bool
+
+	// UnicodeNormalize is the normalization form that all names within archives
+	// are canonicalized into, both when listed and when looked up, so that the
+	// lookups succeed regardless of the form that they were requested in.
+	UnicodeNormalize UnicodeNormalization
+
+	// AllowedUIDs when non-empty are the only UIDs of clients (as by the FUSE
+	// requests) that are allowed to list, look up and open anything within the
+	// filesystem, with any other client being denied (EACCES). This needs the
+	// [FS.WithContext] to be set for serving the filesystem. It is enforced
+	// only in-process, so it is no substitute for the kernel's permission checks.
+	AllowedUIDs []uint32
+
+	// AllowXattrControl controls if archives can be refreshed by writing the
+	// [refreshXattr] extended attribute on their directories, evicting them
+	// from the file descriptor cache (so that they are re-opened on access).
+	AllowXattrControl bool
+
+	// AllowRawNameLookup controls if files within ZIPs (in nested mode) can
+	// also be looked up by their raw (stored) name, if it differs from the
+	// normalized one (e.g. non-unicode), while listings remain normalized.
+	AllowRawNameLookup bool
+
+	// ArchiveMarker controls if the directory of every archive (in nested mode)
+	// contains a synthetic [archiveMarker] file, which carries the modified time
+	// of the archive and contains a one-line summary of it (see [zipMarkerNode]).
+	ArchiveMarker bool
+
+	// ArchiveXattrs controls if the nodes within archives have the extended
+	// attributes [archiveXattr] (the path of the underlying archive) and
+	// [entryXattr] (their path within the archive), tracing them to their source.
+	ArchiveXattrs bool
+
+	// ExposeRaw controls if the directory of every archive (in nested mode)
+	// contains a synthetic [rawDir] directory, which mirrors the structure of
+	// the archive, but presents the raw (compressed) bytes of all its files
+	// (specific to their compression method), e.g. for backup/dedup tools.
+	ExposeRaw bool
+
 	// FlatMode controls if ZIP-contained subdirectories and files
-	// should be flattened with [flatEntryName] into shallow directories.
+	// should be flattened with [flatEntryNames] into shallow directories.
 	FlatMode bool
 
+	// FlatCollisions is the [FlatCollisionStrategy] used with [Options.FlatMode].
+	FlatCollisions FlatCollisionStrategy
+
+	// FlatDerefSymlinks controls if symlinks within ZIPs are dereferenced with
+	// [Options.FlatMode], presenting them with the content of their targets
+	// (within the same archive). Symlinks not resolving to a regular file of
+	// the archive (e.g. absolute, outside of it or dangling) are hidden instead.
+	FlatDerefSymlinks bool
+
+	// FlatDotfileStyle is the [DotfileFlatStyle] used with [Options.FlatMode].
+	FlatDotfileStyle DotfileFlatStyle
+
+	// NestedConflicts is the [NestedConflictStrategy] used without [Options.FlatMode].
+	NestedConflicts NestedConflictStrategy
+
+	// MetadataOnly controls if files within ZIPs are only presented, but can
+	// never be opened or read (guaranteeing that no extraction ever happens).
+	// Listings and attributes are unaffected, as they only read the metadata.
+	MetadataOnly bool
+
+	// OnlyExtensions when non-empty are the only (case-insensitive) extensions
+	// of files within ZIPs which are presented, with all others being hidden
+	// from listings and lookups. Directories that would be empty are hidden.
+	OnlyExtensions []string
+
+	// TailMode controls if archives that fail to open (having no valid central
+	// directory), but were modified within [Options.TailWindow], are presented
+	// as empty directories instead of erroring. Such archives are likely still
+	// being written, are not counted as failed and get retried on every access.
+	TailMode bool
+
+	// TailWindow is the time since the last modification of an archive within
+	// which it is considered still being written (see [Options.TailMode]).
+	TailWindow time.Duration
+
+	// PreserveOwnership controls if the owner UID/GID stored within the ZIP
+	// entries (Info-ZIP Unix extra field) should be reported for their files.
+	PreserveOwnership bool
+
+	// PreservePermissions controls if the execute bits of the modes stored
+	// within the ZIP entries should be reported for their (regular) files,
+	// in addition to being read-only (e.g. 0755 is reported as 0555), so that
+	// executables remain runnable (e.g. of a flattened directory of tools).
+	PreservePermissions bool
+
+	// SizeMode is the [SizeMode] of the sizes reported for files within ZIPs.
+	// With [SizeCompressed], the reported sizes do not match the bytes read,
+	// so these files are opened with direct I/O (not limiting reads to them),
+	// which neither caches their contents nor allows these to be mapped (mmap).
+	SizeMode SizeMode
+
 	// MustCRC32 controls if ZIP-contained uncompressed files must still run
 	// through the integrity verification algorithm (CRC32), which is slower.
 	MustCRC32 atomic.Bool
 
+	// MustCRC32Overrides are per-archive overrides of [Options.MustCRC32],
+	// scoped by globs matched against archive paths (relative to source).
+	MustCRC32Overrides GlobOverrides[bool]
+
+	// Passwords when non-nil provides the passwords of archives, for reading
+	// their files which are encrypted with the traditional PKWARE encryption
+	// (ZipCrypto). Encrypted files of archives without a password are listed,
+	// but fail to open (EACCES), as do those with a wrong password.
+	Passwords PasswordProvider
+
+	// TimestampTZ is the [TimestampZone] that the timestamps of files within
+	// archives are interpreted in, where only stored as MS-DOS date and time.
+	TimestampTZ TimestampZone
+
+	// FixedMtime when non-zero is reported as atime/ctime/mtime of all nodes,
+	// instead of the real timestamps (e.g. for comparing of reproducible mounts).
+	FixedMtime time.Time
+
 	// StreamingThreshold when files are no longer fully loaded into RAM,
 	// but rather streamed in chunks (amount as requested by the kernel).
 	StreamingThreshold atomic.Uint64
+
+	// MaxInMemoryBytes when non-zero is the maximum size of a file within a ZIP
+	// that is loaded into RAM at once, rejecting larger ones (EFBIG) which were
+	// not streamed (e.g. due to a too high threshold), instead of exhausting RAM.
+	MaxInMemoryBytes uint64
+
+	// MaxTotalInMemoryBytes when non-zero is the maximum total size of all files
+	// within ZIPs that are held in RAM at once (from being opened until released),
+	// so that many concurrently opened small files cannot exhaust the memory. Any
+	// file that would exceed it is streamed instead (see [Metrics.InMemoryBytes]).
+	MaxTotalInMemoryBytes uint64
+
+	// StreamingThresholdOverrides are per-archive overrides of the
+	// [Options.StreamingThreshold], scoped by globs matched against
+	// archive paths (relative to the source directory of filesystem).
+	StreamingThresholdOverrides GlobOverrides[uint64]
+
+	// ThresholdRules are entry-level rules deciding if a file within a ZIP is
+	// loaded into RAM or streamed, based on its extension and size. The first
+	// matching [ThresholdRule] takes precedence over [Options.StreamingThreshold]
+	// (and its overrides), which still applies to entries matching none of them.
+	ThresholdRules []ThresholdRule
+
+	// WalkTimeout when non-zero is the time after which a single call on a node
+	// of a walk (its attributes, listing or lookup, e.g. of an archive on stuck
+	// storage) is abandoned with [ErrWalkTimeout], instead of stalling the walk.
+	WalkTimeout time.Duration
+
+	// WalkContinueOnError controls if walks (e.g. [FS.Walk]) continue with the
+	// rest of the tree past nodes that failed (e.g. unreadable archives or those
+	// exceeding [Options.WalkTimeout]), logging each failure and returning all
+	// of these as a [WalkIncompleteError] once complete, instead of failing fast.
+	// The subtrees of the failed nodes are not walked, nor are those visited.
+	WalkContinueOnError bool
+
+	// IdleTimeout is the time without any served requests (listings, lookups
+	// and reads), after which the callback of [FS.WatchIdle] is called (e.g.
+	// for unmounting). Requests in-flight are never idle. Zero disables it.
+	IdleTimeout time.Duration
+
+	// TraceSample is the fraction (0 to 1) of ZIP operations (listings, lookups
+	// and reads) which are traced, each emitting a "Trace:" line with the node,
+	// operation, duration and FD cache result. A value of zero disables tracing.
+	TraceSample float64
+
+	// NodeHook when non-nil is called with every node returned by a lookup (of
+	// the source's directories and within archives), along with its path as
+	// presented (slash-separated, relative to the root), returning the node to
+	// be served instead. This allows embedders to decorate the nodes, wrapping
+	// them to implement further FUSE interfaces (e.g. extended attributes).
+	// A wrapper must preserve the Attr (including the inode) of the wrapped
+	// node and forward any of the interfaces of the node it does not replace,
+	// as the node is only served (and walked, e.g. by [FS.Walk]) as returned.
+	NodeHook func(path string, node fs.Node) fs.Node
 }
 
 // DefaultOptions returns a pointer to [Options] with the default values.
 func DefaultOptions() *Options {
 	opts := &Options{
-		FDCacheSize:    defaultFDCacheSize,
-		FDCacheTTL:     defaultFDCacheTTL,
-		FDLimit:        defaultFDLimit,
-		FlatMode:       defaultFlatMode,
-		ForceUnicode:   defaultForceUnicode,
-		StreamPoolSize: defaultStreamPoolSize,
-		StrictCache:    defaultStrictCache,
+		AllowRawNameLookup:    defaultAllowRawNameLookup,
+		AllowXattrControl:     defaultAllowXattrControl,
+		ArchiveMarker:         defaultArchiveMarker,
+		ArchiveXattrs:         defaultArchiveXattrs,
+		BreakerCooldown:       defaultBreakerCooldown,
+		BreakerThreshold:      defaultBreakerThreshold,
+		BreakerWindow:         defaultBreakerWindow,
+		CacheDirEntries:       defaultCacheDirEntries,
+		CacheFileContent:      defaultCacheFileContent,
+		DedupIdentical:        defaultDedupIdentical,
+		DetectSFX:             defaultDetectSFX,
+		DirectIO:              defaultDirectIO,
+		EmptyArchiveAs:        defaultEmptyArchiveAs,
+		ExposeRaw:             defaultExposeRaw,
+		FDCacheSize:           defaultFDCacheSize,
+		FDCacheTTL:            defaultFDCacheTTL,
+		FDLimit:               defaultFDLimit,
+		FlatCollisions:        defaultFlatCollisions,
+		FlatDerefSymlinks:     defaultFlatDerefSymlinks,
+		FlatDotfileStyle:      defaultFlatDotfileStyle,
+		FlatMode:              defaultFlatMode,
+		ForceUnicode:          defaultForceUnicode,
+		HideHidden:            defaultHideHidden,
+		MaxInMemoryBytes:      defaultMaxInMemoryBytes,
+		MaxListEntries:        defaultMaxListEntries,
+		MaxTotalInMemoryBytes: defaultMaxTotalInMemory,
+		MetadataOnly:          defaultMetadataOnly,
+		NestedConflicts:       defaultNestedConflicts,
+		PreserveOwnership:     defaultPreserveOwnership,
+		PreservePermissions:   defaultPreservePerms,
+		RealSort:              defaultRealSort,
+		RealSortDirsFirst:     defaultRealSortDirsFirst,
+		SizeMode:              defaultSizeMode,
+		StreamPoolSize:        defaultStreamPoolSize,
+		StreamRetries:         defaultStreamRetries,
+		StrictCache:           defaultStrictCache,
+		TailMode:              defaultTailMode,
+		TailWindow:            defaultTailWindow,
+		TimestampTZ:           defaultTimestampTZ,
+		UnicodeNormalize:      defaultUnicodeNormalize,
+		WalkContinueOnError:   defaultWalkContinue,
+		WalkTimeout:           defaultWalkTimeout,
 	}
+	opts.DirSizes.Store(defaultDirSizes)
 	opts.FDCacheBypass.Store(defaultFDCacheBypass)
 	opts.MustCRC32.Store(defaultMustCRC32)
 	opts.StreamingThreshold.Store(defaultStreamingThreshold)
@@ -111,6 +641,10 @@ type Metrics struct {
 	// OpenZips is the amount of currently open ZIP files.
 	OpenZips atomic.Int64
 
+	// InMemoryBytes is the size of the files within ZIPs currently being held
+	// in RAM, as only accounted for a non-zero [Options.MaxTotalInMemoryBytes].
+	InMemoryBytes atomic.Int64
+
 	// TotalOpenedZips is the amount of opened ZIP files.
 	TotalOpenedZips atomic.Int64
 
@@ -120,6 +654,13 @@ type Metrics struct {
 	// TotalStreamRewinds is the amount of reopened ZIP entries due to rewinds.
 	TotalStreamRewinds atomic.Int64
 
+	// TotalStreamRetries is the amount of retried reads due to transient errors.
+	TotalStreamRetries atomic.Int64
+
+	// TotalStaleReads is the amount of failed reads of streamed files, as their
+	// archive changed while open (only detected with [Options.StrictCache]).
+	TotalStaleReads atomic.Int64
+
 	// TotalMetadataReadTime is time spent reading metadata from ZIP files.
 	TotalMetadataReadTime atomic.Int64
 
@@ -135,6 +676,36 @@ type Metrics struct {
 	// TotalExtractBytes is the amount of bytes extracted from ZIP files.
 	TotalExtractBytes atomic.Int64
 
+	// TotalCompressedBytesRead is the amount of compressed bytes consumed for
+	// [Metrics.TotalExtractBytes], as estimated from each file's compression.
+	TotalCompressedBytesRead atomic.Int64
+
+	// TotalBreakerRejects is the amount of rejected opens of tripped archives.
+	TotalBreakerRejects atomic.Int64
+
+	// TotalPanics is the amount of recovered panics (see [FS.RecordPanic]).
+	TotalPanics atomic.Int64
+
+	// TotalUnsupportedMethod is the amount of rejected opens of files within
+	// ZIPs of a compression method that cannot be read (see [ErrUnsupportedMethod]).
+	TotalUnsupportedMethod atomic.Int64
+
+	// TotalInMemoryFallbacks is the amount of files within ZIPs that were streamed
+	// instead of loaded into RAM, as [Options.MaxTotalInMemoryBytes] was exhausted.
+	TotalInMemoryFallbacks atomic.Int64
+
+	// TotalReadDirs is the amount of listed directories (ReadDirAll requests).
+	TotalReadDirs atomic.Int64
+
+	// TotalLookups is the amount of looked up names (Lookup requests).
+	TotalLookups atomic.Int64
+
+	// TotalOpens is the amount of opened files (Open requests of files).
+	TotalOpens atomic.Int64
+
+	// TotalReads is the amount of reads of files (Read and ReadAll requests).
+	TotalReads atomic.Int64
+
 	// TotalFDCacheHits is the amount of cache-hits for the FD cache.
 	TotalFDCacheHits atomic.Int64
 
@@ -152,6 +723,91 @@ type Metrics struct {
 
 	// TotalStreamPoolMissBytes is the bytes newly allocated outside the pool.
 	TotalStreamPoolMissBytes atomic.Int64
+
+	archiveStatsMu sync.Mutex
+	archiveStats   ArchiveStats // Of the opened archives (see ArchiveStats).
+}
+
+// ArchiveStats are the statistics of the entry counts and total (uncompressed)
+// sizes of the archives, as accumulated whenever an archive is opened (and so
+// counting an archive again when re-opened, e.g. after eviction from FD cache).
+type ArchiveStats struct {
+	// Opened is the amount of opened archives accounted for.
+	Opened int64
+
+	// TotalEntries is the amount of entries of all the opened archives.
+	TotalEntries int64
+
+	// MinEntries is the least amount of entries of an opened archive.
+	MinEntries int64
+
+	// MaxEntries is the most amount of entries of an opened archive.
+	MaxEntries int64
+
+	// MaxEntriesPath is the path of the archive having [ArchiveStats.MaxEntries].
+	MaxEntriesPath string
+
+	// MaxSize is the largest total uncompressed size of an opened archive.
+	MaxSize uint64
+
+	// MaxSizePath is the path of the archive having [ArchiveStats.MaxSize].
+	MaxSizePath string
+}
+
+// AvgEntries returns the average amount of entries per opened archive.
+func (s ArchiveStats) AvgEntries() float64 {
+	if s.Opened == 0 {
+		return 0
+	}
+
+	return float64(s.TotalEntries) / float64(s.Opened)
+}
+
+// ArchiveStats returns the [ArchiveStats] of the archives opened so far.
+func (m *Metrics) ArchiveStats() ArchiveStats {
+	m.archiveStatsMu.Lock()
+	defer m.archiveStatsMu.Unlock()
+
+	return m.archiveStats
+}
+
+// ResetArchiveStats resets the [ArchiveStats] (e.g. along with the other metrics).
+func (m *Metrics) ResetArchiveStats() {
+	m.archiveStatsMu.Lock()
+	defer m.archiveStatsMu.Unlock()
+
+	m.archiveStats = ArchiveStats{}
+}
+
+// recordArchive accounts an opened archive (by its central directory) within
+// the [ArchiveStats], being its amount of entries and total uncompressed size.
+func (m *Metrics) recordArchive(path string, r *zip.Reader) {
+	entries := int64(len(r.File))
+
+	var size uint64
+	for _, f := range r.File {
+		size += f.UncompressedSize64
+	}
+
+	m.archiveStatsMu.Lock()
+	defer m.archiveStatsMu.Unlock()
+
+	s := &m.archiveStats
+
+	if s.Opened == 0 || entries < s.MinEntries {
+		s.MinEntries = entries
+	}
+	if s.Opened == 0 || entries > s.MaxEntries {
+		s.MaxEntries = entries
+		s.MaxEntriesPath = path
+	}
+	if s.Opened == 0 || size > s.MaxSize {
+		s.MaxSize = size
+		s.MaxSizePath = path
+	}
+
+	s.Opened++
+	s.TotalEntries += entries
 }
 
 // FS is the core implementation of the filesystem.
@@ -164,7 +820,23 @@ type FS struct {
 
 	fdlimit chan struct{}
 	fdcache *zipReaderCache
+	client  *http.Client
+	remote  *httpReaderAt
 	bufpool sync.Pool
+	failed  *failedArchives
+	empty   *emptyArchives
+	sfx     *sfxArchives
+
+	lastActive atomic.Int64 // Of the last served request (unix nanoseconds).
+	inflight   atomic.Int64 // Amount of requests currently being served.
+	idleHalted atomic.Bool  // Whether the idle watch is halted (unmounting).
+	idleDone   chan struct{}
+
+	panicked atomic.Bool // Whether a fatal panic was recovered.
+	paused   atomic.Bool // Whether opening new archives is paused.
+	ready    atomic.Bool // Whether requests are served (see [FS.Ready]).
+
+	staticWarned atomic.Bool // Whether static options were changed (see checkStatic).
 
 	rbuf *logging.RingBuffer
 }
@@ -178,26 +850,57 @@ func NewFS(sourceDir string, opts *Options, rbuf *logging.RingBuffer) (*FS, erro
 	if sourceDir == "" {
 		return nil, fmt.Errorf("%w: need a non-empty sourceDir", errInvalidArgument)
 	}
-	if _, err := os.Stat(sourceDir); err != nil {
-		return nil, fmt.Errorf("%w: failed to stat sourceDir: %w", errInvalidArgument, err)
-	}
 	if opts == nil {
 		opts = DefaultOptions()
 	}
+	if !isRemoteArchive(sourceDir) {
+		if _, err := os.Stat(sourceDir); err != nil {
+			return nil, fmt.Errorf("%w: failed to stat sourceDir: %w", errInvalidArgument, err)
+		}
+	}
+	if opts.Entry != "" && !isRemoteArchive(sourceDir) {
+		if fi, err := os.Stat(sourceDir); err != nil || !fi.Mode().IsRegular() {
+			return nil, fmt.Errorf("%w: entry needs a single archive as sourceDir", errInvalidArgument)
+		}
+	}
+	if opts.IdleTimeout < 0 {
+		return nil, fmt.Errorf("%w: idle timeout cannot be negative (%v)", errInvalidArgument, opts.IdleTimeout)
+	}
+	if opts.TraceSample < 0 || opts.TraceSample > 1 {
+		return nil, fmt.Errorf("%w: trace sample must be between 0 and 1 (%v)", errInvalidArgument, opts.TraceSample)
+	}
 	if opts.FDLimit <= opts.FDCacheSize {
 		return nil, fmt.Errorf("%w: fd limit cannot be <= fd cache size (%d/%d)",
 			errInvalidArgument, opts.FDLimit, opts.FDCacheSize)
 	}
+	if len(opts.PinGlobs.List()) > 0 && opts.FDLimit <= opts.FDCacheSize+1 {
+		return nil, fmt.Errorf("%w: fd limit must be > fd cache size + 1 to pin archives (%d/%d)",
+			errInvalidArgument, opts.FDLimit, opts.FDCacheSize)
+	}
 
 	fsys := &FS{
 		SourceDir: sourceDir,
 		Options:   opts,
 		Metrics:   &Metrics{},
+		idleDone:  make(chan struct{}),
 		rbuf:      rbuf,
 	}
 
+	fsys.client = newHTTPClient(opts)
+	if isRemoteArchive(sourceDir) {
+		remote, err := newHTTPReaderAt(fsys.client, sourceDir)
+		if err != nil {
+			return nil, fmt.Errorf("%w: failed to probe sourceDir: %w", errInvalidArgument, err)
+		}
+		fsys.remote = remote
+	}
+
 	fsys.fdlimit = make(chan struct{}, opts.FDLimit)
 	fsys.fdcache = newZipReaderCache(fsys, opts.FDCacheSize, opts.FDCacheTTL)
+	fsys.failed = newFailedArchives(failedArchivesSize,
+		opts.BreakerThreshold, opts.BreakerWindow, opts.BreakerCooldown)
+	fsys.empty = newEmptyArchives(emptyArchivesSize)
+	fsys.sfx = newSFXArchives(sfxArchivesSize)
 
 	fsys.bufpool = sync.Pool{
 		New: func() any {
@@ -214,17 +917,126 @@ func NewFS(sourceDir string, opts *Options, rbuf *logging.RingBuffer) (*FS, erro
 // It takes an error channel for checking if unmount was successful.
 // In case of an unmount failure, it restores the FS to working state.
 func (fsys *FS) PrepareUnmount(unmountErr <-chan error) {
-	fsys.fdcache.HaltAndPurge(unmountErr)
+	fsys.idleHalted.Store(true)
+	wasReady := fsys.ready.Swap(false)
+
+	cacheErr := make(chan error, 1)
+	fsys.fdcache.HaltAndPurge(cacheErr)
+
+	go func() {
+		err := <-unmountErr
+		if err != nil {
+			fsys.lastActive.Store(time.Now().UnixNano())
+			fsys.idleHalted.Store(false)
+			fsys.ready.Store(wasReady)
+		}
+		cacheErr <- err
+	}()
 }
 
 // Destroy does post-unmount FS cleanup and blocks until done.
 // You should not use the filesystem after calling of this function.
 func (fsys *FS) Destroy() {
+	close(fsys.idleDone)
 	fsys.fdcache.Destroy()
 }
 
+// CachedArchives returns the archives that are currently held open by the
+// file descriptor cache (none, when the cache is being bypassed entirely).
+func (fsys *FS) CachedArchives() []CachedArchive {
+	return fsys.fdcache.Snapshot()
+}
+
+// PinArchives pins the archives matching a glob in the file descriptor cache
+// (see [Options.PinGlobs]), returning the amount of already open ones pinned.
+func (fsys *FS) PinArchives(glob string) (int, error) {
+	return fsys.fdcache.Pin(glob)
+}
+
+// UnpinArchives unpins the archives matching a glob (unless matching another)
+// from the file descriptor cache, returning the amount released and if found.
+func (fsys *FS) UnpinArchives(glob string) (int, bool) {
+	return fsys.fdcache.Unpin(glob)
+}
+
+// PurgeCache releases all archives from the file descriptor cache (including
+// the pinned), so that these are re-opened on their next access, returning the
+// amount of archives that were held open. Readers in use are closed once done.
+func (fsys *FS) PurgeCache() int {
+	return fsys.fdcache.Purge()
+}
+
+// FDLimit returns the [Options.FDLimit] in effect, as fixed at construction.
+// Changing the option at runtime has no effect (see [FS.checkStatic]).
+func (fsys *FS) FDLimit() int {
+	fsys.checkStatic()
+
+	return cap(fsys.fdlimit)
+}
+
+// FDCacheSize returns the [Options.FDCacheSize] in effect, as fixed at
+// construction. Changing the option at runtime has no effect (as FDLimit).
+func (fsys *FS) FDCacheSize() int {
+	fsys.checkStatic()
+
+	return fsys.fdcache.size
+}
+
+// checkStatic logs a warning (once) if [Options.FDLimit] or [Options.FDCacheSize]
+// were changed after construction, which is ignored, as the FD semaphore and the
+// cache are sized only then (resizing these would break the pinning invariants).
+func (fsys *FS) checkStatic() {
+	limit, size := cap(fsys.fdlimit), fsys.fdcache.size
+	if fsys.Options.FDLimit == limit && fsys.Options.FDCacheSize == size {
+		return
+	}
+
+	if fsys.staticWarned.CompareAndSwap(false, true) {
+		fsys.rbuf.Printf("Warning: FDLimit (%d) and FDCacheSize (%d) cannot be changed at runtime, keeping %d and %d\n",
+			fsys.Options.FDLimit, fsys.Options.FDCacheSize, limit, size)
+	}
+}
+
+// CachedFDs returns the amount of archives currently held open by the file
+// descriptor cache (occupancy, to compare with [Options.FDCacheSize]).
+func (fsys *FS) CachedFDs() int {
+	return fsys.fdcache.Len()
+}
+
+// HeldFDs returns the amount of file descriptors currently being accounted for
+// by [Options.FDLimit], being the archives held open (cached or in use) by [FS].
+func (fsys *FS) HeldFDs() int {
+	return len(fsys.fdlimit)
+}
+
+// FailedArchives returns the archives that have recently failed to open.
+// Archives are removed again once they have successfully been re-opened.
+func (fsys *FS) FailedArchives() []FailedArchive {
+	return fsys.failed.List()
+}
+
+// ResetFailedArchives clears the archives that have recently failed to open.
+func (fsys *FS) ResetFailedArchives() {
+	fsys.failed.Reset()
+}
+
 // Root returns the entry-point [fs.Node] of the filesystem.
+// For a remote archive (an HTTP(S) URL as source), it is its [zipDirNode].
+// With [Options.Entry], it is the file node of that entry within the archive.
 func (fsys *FS) Root() (fs.Node, error) {
+	if fsys.Options.Entry != "" {
+		return fsys.entryRoot()
+	}
+
+	if fsys.remote != nil {
+		return &zipDirNode{
+			fsys:  fsys,
+			inode: 1,
+			path:  fsys.SourceDir,
+			mtime: fsys.remote.mtime,
+		}, nil
+	}
+
 	return &realDirNode{
 		fsys:  fsys,
 		inode: 1,
@@ -233,6 +1045,43 @@ func (fsys *FS) Root() (fs.Node, error) {
 	}, nil
 }
 
+// entryRoot resolves [Options.Entry] within the (single) archive of the source,
+// returning its file node; it is an error if the entry is not a regular file.
+func (fsys *FS) entryRoot() (fs.Node, error) {
+	root := &zipDirNode{
+		fsys:  fsys,
+		inode: 1,
+		path:  fsys.SourceDir,
+	}
+
+	if fsys.remote != nil {
+		root.mtime = fsys.remote.mtime
+	} else {
+		fi, err := os.Stat(fsys.SourceDir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to stat archive: %w", err)
+		}
+		root.mtime = fi.ModTime()
+	}
+
+	ctx := context.Background()
+
+	node, err := lookupPath(ctx, root, fsys.Options.Entry)
+	if err != nil {
+		return nil, err
+	}
+
+	var attr fuse.Attr
+	if err := node.Attr(ctx, &attr); err != nil {
+		return nil, fmt.Errorf("attr error: %w", err)
+	}
+	if !attr.Mode.IsRegular() {
+		return nil, fmt.Errorf("%w: %q: is a directory (need a file)", errInvalidArgument, fsys.Options.Entry)
+	}
+
+	return node, nil
+}
+
 // GenerateInode implements [fs.FSInodeGenerator] to prevent dynamic
 // inode generation by the fallback method inside of the FUSE library.
 //
@@ -247,38 +1096,521 @@ func (fsys *FS) GenerateInode(_ uint64, _ string) uint64 {
 // WalkFunc gets called on each visited [fs.Node] as part of a [FS.Walk].
 // Do note that as the root directory is synthetic, the [fuse.Dirent] will be nil.
 // All paths provided to the callback will be relative to the filesystem Root() node.
+// Returning [ErrSkipDir] skips over the contents of the visited (directory) node.
 type WalkFunc func(path string, dirent *fuse.Dirent, node fs.Node, attr fuse.Attr) error
 
 // Walk constructs and walks the [FS] in-memory, calling walkFn on each visited [fs.Node].
+// Any returned error retains the sentinel errors (e.g. [ErrArchiveUnreadable])
+// within its chain, so that these can be matched by callers using [errors.Is].
 func (fsys *FS) Walk(ctx context.Context, walkFn WalkFunc) error {
 	root, err := fsys.Root()
 	if err != nil {
 		return fmt.Errorf("failed to get fs root: %w", err)
 	}
 
-	return fsys.walkNode(ctx, "/", nil, root, walkFn)
+	failures := fsys.newWalkFailures()
+	if err := fsys.walkNode(ctx, failures, "/", nil, root, walkFn); err != nil {
+		return err
+	}
+
+	return failures.Err()
+}
+
+// WalkArchive walks only the given ZIP archive of the [FS] in-memory, calling
+// walkFn on each visited [fs.Node], starting with the archive's directory node.
+// The archive is resolved through the Root() node, so that inodes and paths are
+// the same as within [FS.Walk], but without walking any of the other directories.
+// [ErrNotArchive] is returned for a path that is not a ZIP archive within the
+// source directory, or one that is hidden by a directory of the same name.
+func (fsys *FS) WalkArchive(ctx context.Context, archive string, walkFn WalkFunc) error {
+	rel, err := fsys.archiveWalkPath(archive)
+	if err != nil {
+		return err
+	}
+
+	node, err := fsys.Root()
+	if err != nil {
+		return fmt.Errorf("failed to get fs root: %w", err)
+	}
+
+	var path string
+	names := strings.Split(rel, "/")
+
+	for _, name := range names {
+		lookupNode, ok := node.(fs.NodeStringLookuper)
+		if !ok {
+			return fmt.Errorf("%w: %q: not a directory at %q", ErrNotArchive, archive, path)
+		}
+
+		childNode, err := lookupNode.Lookup(ctx, name)
+		if err != nil {
+			return fmt.Errorf("lookup error for %q at %q: %w", name, path, err)
+		}
+
+		node = childNode
+		path += "/" + name
+	}
+
+	if _, ok := node.(*zipDirNode); !ok {
+		return fmt.Errorf("%w: %q: hidden by a directory at %q", ErrNotArchive, archive, path)
+	}
+
+	var attr fuse.Attr
+	if err := node.Attr(ctx, &attr); err != nil {
+		return fmt.Errorf("attr error at %q: %w", path, err)
+	}
+
+	dirent := &fuse.Dirent{
+		Name:  names[len(names)-1],
+		Type:  fuse.DT_Dir,
+		Inode: attr.Inode,
+	}
+
+	failures := fsys.newWalkFailures()
+	if err := fsys.walkNode(ctx, failures, path, dirent, node, walkFn); err != nil {
+		return err
+	}
+
+	return failures.Err()
+}
+
+// ArchiveFile is the backing file of a ZIP archive (see [FS.OpenArchiveFile]),
+// holding one slot of the FD semaphore (as [Options.FDLimit]) until closed.
+type ArchiveFile struct {
+	*os.File
+
+	fsys *FS
+	once sync.Once
+}
+
+// Close closes the file and releases its slot of the FD semaphore.
+func (f *ArchiveFile) Close() error {
+	err := f.File.Close()
+	f.once.Do(func() { <-f.fsys.fdlimit })
+
+	return err //nolint:wrapcheck
 }
 
-// walkNode handles walking of a [fs.Node] within the [FS].
-func (fsys *FS) walkNode(ctx context.Context, path string, dirent *fuse.Dirent, node fs.Node, walkFn WalkFunc) error {
+// OpenArchiveFile opens the backing file of a ZIP archive by its path relative
+// to the source directory (slash-separated), e.g. for serving it as a whole.
+// [ErrNotArchive] is returned for a path that is not a ZIP archive within the
+// source directory (also by symlinks resolving to outside of it), or for any
+// path if the source is a remote archive instead. It never blocks on the FD
+// semaphore, but returns [ErrFDLimitReached] if none of its slots are free,
+// so that (e.g. slow) downloads cannot starve the opening of archives.
+// The returned file is opened read-only and needs to be closed after use.
+func (fsys *FS) OpenArchiveFile(rel string) (*ArchiveFile, os.FileInfo, error) {
+	if fsys.remote != nil {
+		return nil, nil, fmt.Errorf("%w: %q: source is a remote archive", ErrNotArchive, rel)
+	}
+
+	archive := filepath.Join(fsys.SourceDir, filepath.FromSlash(rel))
+	if _, err := fsys.archiveWalkPath(archive); err != nil {
+		return nil, nil, err
+	}
+
+	sourceDir, err := filepath.EvalSymlinks(fsys.SourceDir)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to resolve source dir: %w", err)
+	}
+
+	resolved, err := filepath.EvalSymlinks(archive)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %q: %w", ErrNotArchive, archive, err)
+	}
+
+	within, err := filepath.Rel(sourceDir, resolved)
+	if err != nil || within == ".." || strings.HasPrefix(within, ".."+string(filepath.Separator)) {
+		return nil, nil, fmt.Errorf("%w: %q: resolves to outside of source directory", ErrNotArchive, archive)
+	}
+
+	select {
+	case fsys.fdlimit <- struct{}{}:
+	default:
+		return nil, nil, fmt.Errorf("%w: %d in use", ErrFDLimitReached, cap(fsys.fdlimit))
+	}
+
+	f, err := os.Open(resolved)
+	if err != nil {
+		<-fsys.fdlimit
+
+		return nil, nil, fmt.Errorf("failed to open: %w", err)
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		<-fsys.fdlimit
+
+		return nil, nil, fmt.Errorf("failed to stat: %w", err)
+	}
+
+	return &ArchiveFile{File: f, fsys: fsys}, info, nil
+}
+
+// CopyFile copies the contents of a file within the [FS] into w, as these
+// would be read through the mounted filesystem (e.g. for verifying checksums).
+// The path is relative to the Root() node and resolved through the nodes, so it
+// needs to be in the form as presented with the [Options] (e.g. flattened with
+// [Options.FlatMode]). [ErrEntryNotFound] is retained within the error chain
+// for a path that does not exist, or which is a directory instead of a file.
+func (fsys *FS) CopyFile(ctx context.Context, name string, w io.Writer) (int64, error) {
+	root, err := fsys.Root()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get fs root: %w", err)
+	}
+
+	node, err := lookupPath(ctx, root, name)
+	if err != nil {
+		return 0, err
+	}
+
 	var attr fuse.Attr
+	if err := node.Attr(ctx, &attr); err != nil {
+		return 0, fmt.Errorf("attr error: %w", err)
+	}
+	if attr.Mode.IsDir() {
+		return 0, fmt.Errorf("%w: %q: is a directory", ErrEntryNotFound, name)
+	}
+
+	handle := fs.Handle(node)
+	if opener, ok := node.(fs.NodeOpener); ok {
+		handle, err = opener.Open(ctx, &fuse.OpenRequest{Flags: fuse.OpenReadOnly}, &fuse.OpenResponse{})
+		if err != nil {
+			return 0, fmt.Errorf("open error: %w", err)
+		}
+	}
+	if releaser, ok := handle.(fs.HandleReleaser); ok {
+		defer releaser.Release(ctx, &fuse.ReleaseRequest{}) //nolint:errcheck
+	}
+
+	return copyHandle(ctx, handle, max(fsys.Options.StreamPoolSize, 1), w)
+}
+
+// ExtractEntry copies the decompressed contents of a file within an archive
+// into w, streamed through the same readers as the mounted filesystem would,
+// so that even huge files are never buffered entirely. The file is the path
+// within the archive (as stored, or as normalized), the copy starting at the
+// offset and being limited to the length (or until the end, if negative).
+// [ErrArchiveUnreadable] is retained for an archive that cannot be opened,
+// [ErrEntryNotFound] for a path that does not exist or is a directory.
+func (fsys *FS) ExtractEntry(archive, name string, offset, length int64, w io.Writer) (int64, error) {
+	if offset < 0 {
+		return 0, fmt.Errorf("%w: offset cannot be negative (%d)", errInvalidArgument, offset)
+	}
+
+	zr, err := fsys.fdcache.Archive(archive)
+	if err != nil {
+		return 0, err
+	}
+	defer zr.Release() //nolint:errcheck
 
+	name = strings.Trim(name, "/")
+
+	var file *zip.File
+	for i, f := range zr.File {
+		normalizedPath := zipEntryNormalize(i, f, fsys.Options.ForceUnicode, fsys.Options.UnicodeNormalize)
+		if (f.Name == name || normalizedPath == name) && !isDir(f, normalizedPath) {
+			file = f
+
+			break
+		}
+	}
+	if file == nil {
+		return 0, fmt.Errorf("%w: %q", ErrEntryNotFound, name)
+	}
+
+	fr, err := newZipFileReader(fsys, archive, file)
+	if err != nil {
+		return 0, err
+	}
+	defer fr.Close() //nolint:errcheck
+
+	if _, err := fr.ForwardTo(offset); err != nil {
+		return 0, fmt.Errorf("failed to forward to offset: %w", err)
+	}
+
+	var r io.Reader = fr
+	if length >= 0 {
+		r = io.LimitReader(fr, length)
+	}
+
+	n, err := io.Copy(w, r)
+	if err != nil {
+		return n, fmt.Errorf("failed to copy: %w", err)
+	}
+
+	return n, nil
+}
+
+// lookupPath resolves a slash-separated path (relative to the given node),
+// returning [ErrEntryNotFound] if any of its elements do not exist.
+func lookupPath(ctx context.Context, node fs.Node, name string) (fs.Node, error) {
+	for elem := range strings.SplitSeq(strings.Trim(name, "/"), "/") {
+		lookupNode, ok := node.(fs.NodeStringLookuper)
+		if !ok {
+			return nil, fmt.Errorf("%w: %q: not a directory", ErrEntryNotFound, name)
+		}
+
+		var err error
+
+		node, err = lookupNode.Lookup(ctx, elem)
+		if err != nil {
+			var errno fuse.ErrorNumber
+			if errors.As(err, &errno) && errno.Errno() == fuse.ENOENT {
+				return nil, fmt.Errorf("%w: %q: %w", ErrEntryNotFound, name, err)
+			}
+
+			return nil, fmt.Errorf("lookup error for %q: %w", elem, err)
+		}
+	}
+
+	return node, nil
+}
+
+// copyHandle copies the contents of an opened [fs.Handle] into w, either all
+// at once or in reads of the given size (until a read returns no more data).
+func copyHandle(ctx context.Context, handle fs.Handle, size int, w io.Writer) (int64, error) {
+	if readAller, ok := handle.(fs.HandleReadAller); ok {
+		data, err := readAller.ReadAll(ctx)
+		if err != nil {
+			return 0, fmt.Errorf("read error: %w", err)
+		}
+		n, err := w.Write(data)
+
+		return int64(n), err //nolint:wrapcheck
+	}
+
+	reader, ok := handle.(fs.HandleReader)
+	if !ok {
+		return 0, fmt.Errorf("%w: handle is not readable", errInvalidArgument)
+	}
+
+	var total int64
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return total, fmt.Errorf("context error: %w", err)
+		}
+
+		resp := &fuse.ReadResponse{}
+		if err := reader.Read(ctx, &fuse.ReadRequest{Offset: total, Size: size}, resp); err != nil {
+			return total, fmt.Errorf("read error at %d: %w", total, err)
+		}
+		if len(resp.Data) == 0 {
+			return total, nil
+		}
+
+		n, err := w.Write(resp.Data)
+		total += int64(n)
+		if err != nil {
+			return total, err //nolint:wrapcheck
+		}
+	}
+}
+
+// VerifyArchives opens the central directory of each archive within the [FS]
+// (as found by [FS.Walk], without walking into the archives), returning one
+// error for each archive that cannot be opened (retaining [ErrArchiveUnreadable]).
+// The archives are opened by up to the given amount of concurrent workers
+// (bounded by [Options.FDLimit]), with the errors being in order of the walk.
+// The opened archives remain within the file descriptor cache (pre-warming it).
+// Archives still being written (with [Options.TailMode]) are not returned.
+// The second returned error is for a failure of the walk itself (if any), with
+// the failures of a walk continued past these (see [Options.WalkContinueOnError])
+// being returned as the first errors instead, followed by those of the archives.
+func (fsys *FS) VerifyArchives(ctx context.Context, workers int) ([]error, error) {
+	var paths []string
+
+	err := fsys.Walk(ctx, func(_ string, _ *fuse.Dirent, node fs.Node, _ fuse.Attr) error {
+		if z, ok := node.(*zipDirNode); ok {
+			paths = append(paths, z.path)
+
+			return ErrSkipDir
+		}
+
+		return nil
+	})
+
+	var incomplete *WalkIncompleteError
+	if err != nil && !errors.As(err, &incomplete) {
+		return nil, err
+	}
+
+	results := make([]error, len(paths))
+	sem := make(chan struct{}, max(1, min(workers, cap(fsys.fdlimit))))
+
+	var wg sync.WaitGroup
+
+	for i, path := range paths {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			wg.Wait()
+
+			return collectFailures(results), fmt.Errorf("context error: %w", ctx.Err())
+		}
+
+		wg.Go(func() {
+			defer func() { <-sem }()
+
+			zr, err := fsys.fdcache.Archive(path)
+			if err != nil {
+				if !errors.Is(err, errArchiveIncomplete) {
+					results[i] = fmt.Errorf("%q: %w", path, err)
+				}
+
+				return
+			}
+			_ = zr.Release()
+		})
+	}
+	wg.Wait()
+
+	if incomplete != nil {
+		return append(incomplete.Failures, collectFailures(results)...), nil
+	}
+
+	return collectFailures(results), nil
+}
+
+// collectFailures returns the non-nil errors of results (in their order).
+func collectFailures(results []error) []error {
+	var failures []error
+
+	for _, err := range results {
+		if err != nil {
+			failures = append(failures, err)
+		}
+	}
+
+	return failures
+}
+
+// ChangedEntry describes a file within an archive, as by [FS.ChangedEntries].
+// The path is relative to the Root() node, as the file is presented in the [FS].
+type ChangedEntry struct {
+	Path  string    `json:"path"`
+	Size  uint64    `json:"size"`
+	Mtime time.Time `json:"mtime"`
+}
+
+// ChangedEntries returns the files within all archives of the [FS] that were
+// modified after since, matching them against the central directories of the
+// archives (as held by the file descriptor cache), without walking into them.
+// At most limit (if > 0) entries are returned, with the returned bool telling
+// if any were omitted (ending the walk early). Archives that cannot be opened,
+// or which are still being written (with [Options.TailMode]), are skipped over.
+func (fsys *FS) ChangedEntries(ctx context.Context, since time.Time, limit int) ([]ChangedEntry, bool, error) {
+	entries := []ChangedEntry{}
+
+	err := fsys.Walk(ctx, func(path string, _ *fuse.Dirent, node fs.Node, _ fuse.Attr) error {
+		z, ok := node.(*zipDirNode)
+		if !ok {
+			return nil
+		}
+
+		zr, err := fsys.fdcache.Archive(z.path)
+		if err != nil {
+			return ErrSkipDir
+		}
+		defer zr.Release() //nolint:errcheck
+
+		for _, e := range z.changedEntries(zr, since) {
+			if limit > 0 && len(entries) >= limit {
+				return errLimitReached
+			}
+			if path != "/" {
+				e.Path = path + "/" + e.Path
+			} else {
+				e.Path = "/" + e.Path
+			}
+			entries = append(entries, e)
+		}
+
+		return ErrSkipDir
+	})
+	if errors.Is(err, errLimitReached) {
+		return entries, true, nil
+	}
+	if err != nil {
+		return entries, false, err
+	}
+
+	return entries, false, nil
+}
+
+// archiveWalkPath validates an archive for [FS.WalkArchive] and returns the
+// slash-separated path of its would-be directory relative to the Root() node.
+func (fsys *FS) archiveWalkPath(archive string) (string, error) {
+	sourceDir, err := filepath.Abs(fsys.SourceDir)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve source dir: %w", err)
+	}
+
+	archivePath, err := filepath.Abs(archive)
+	if err != nil {
+		return "", fmt.Errorf("%w: %q: %w", ErrNotArchive, archive, err)
+	}
+
+	rel, err := filepath.Rel(sourceDir, archivePath)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%w: %q: outside of source directory", ErrNotArchive, archive)
+	}
+
+	if fsys.Options.HideHidden && hiddenPath(filepath.ToSlash(rel)) {
+		return "", fmt.Errorf("%w: %q: hidden (by a name starting with \".\")", ErrNotArchive, archive)
+	}
+
+	isZip := filepath.Ext(rel) == ".zip" && filepath.Base(rel) != ".zip"
+	if !isZip && !fsys.Options.DetectSFX {
+		return "", fmt.Errorf("%w: %q: no .zip file extension", ErrNotArchive, archive)
+	}
+
+	info, err := os.Stat(archivePath)
+	if err != nil {
+		return "", fmt.Errorf("%w: %q: %w", ErrNotArchive, archive, err)
+	}
+	if !info.Mode().IsRegular() {
+		return "", fmt.Errorf("%w: %q: not a regular file", ErrNotArchive, archive)
+	}
+
+	// Self-extracting archives are presented under their full names.
+	if !isZip {
+		if !fsys.sfxArchive(archivePath, info) {
+			return "", fmt.Errorf("%w: %q: no .zip file extension (nor self-extracting)", ErrNotArchive, archive)
+		}
+
+		return filepath.ToSlash(rel), nil
+	}
+
+	return filepath.ToSlash(strings.TrimSuffix(rel, ".zip")), nil
+}
+
+// walkNode handles walking of a [fs.Node] within the [FS], continuing past
+// any failed nodes into the failures (when non-nil, see [FS.tolerateWalk]).
+func (fsys *FS) walkNode(ctx context.Context, failures *walkFailures, path string, dirent *fuse.Dirent, node fs.Node, walkFn WalkFunc) error {
 	if err := ctx.Err(); err != nil {
 		return fmt.Errorf("context error: %w", err)
 	}
 
-	if err := node.Attr(ctx, &attr); err != nil {
-		return fmt.Errorf("attr error at %q: %w", path, err)
+	attr, err := fsys.walkAttr(ctx, node)
+	if err != nil {
+		return fsys.tolerateWalk(ctx, failures, fmt.Errorf("attr error at %q: %w", path, err))
 	}
 
 	if err := walkFn(path, dirent, node, attr); err != nil {
+		if errors.Is(err, ErrSkipDir) {
+			return nil
+		}
+
 		return fmt.Errorf("walkfn error at %q: %w", path, err)
 	}
 
 	if readDirNode, ok := node.(fs.HandleReadDirAller); ok {
-		dirents, err := readDirNode.ReadDirAll(ctx)
+		dirents, err := fsys.walkReadDirAll(ctx, readDirNode)
 		if err != nil {
-			return fmt.Errorf("readdirall error at %q: %w", path, err)
+			return fsys.tolerateWalk(ctx, failures, fmt.Errorf("readdirall error at %q: %w", path, err))
 		}
 
 		if lookupNode, ok := node.(fs.NodeStringLookuper); ok {
@@ -289,12 +1621,16 @@ func (fsys *FS) walkNode(ctx context.Context, path string, dirent *fuse.Dirent,
 				}
 				childPath += de.Name
 
-				childNode, err := lookupNode.Lookup(ctx, de.Name)
+				childNode, err := fsys.walkLookup(ctx, lookupNode, de.Name)
 				if err != nil {
-					return fmt.Errorf("lookup error for %q at %q: %w", de.Name, path, err)
+					if err := fsys.tolerateWalk(ctx, failures, fmt.Errorf("lookup error for %q at %q: %w", de.Name, path, err)); err != nil {
+						return err
+					}
+
+					continue
 				}
 
-				if err := fsys.walkNode(ctx, childPath, &de, childNode, walkFn); err != nil {
+				if err := fsys.walkNode(ctx, failures, childPath, &de, childNode, walkFn); err != nil {
 					return fmt.Errorf("walkfn error at %q: %w", childPath, err)
 				}
 			}
@@ -304,6 +1640,28 @@ func (fsys *FS) walkNode(ctx context.Context, path string, dirent *fuse.Dirent,
 	return nil
 }
 
+// cacheDirEntries checks if the kernel can keep listings of directories
+// within ZIPs cached, as by [Options.CacheDirEntries] and [Options.StrictCache].
+func (fsys *FS) cacheDirEntries() bool {
+	return fsys.Options.CacheDirEntries && !fsys.Options.StrictCache
+}
+
+// cacheFileContent checks if the kernel can keep contents of files within
+// ZIPs cached, as by [Options.CacheFileContent] and [Options.StrictCache].
+func (fsys *FS) cacheFileContent() bool {
+	return fsys.Options.CacheFileContent && !fsys.Options.StrictCache
+}
+
+// attrTime returns the timestamp to report for a node within a [fuse.Attr].
+// It is the given time, unless it is overridden by [Options.FixedMtime].
+func (fsys *FS) attrTime(t time.Time) time.Time {
+	if !fsys.Options.FixedMtime.IsZero() {
+		return fsys.Options.FixedMtime
+	}
+
+	return t
+}
+
 // countError adds to the error count within the filesystem.
 // It returns the received error back to the caller unchanged.
 // This allows for convenient use of the method in return calls.
@@ -312,3 +1670,37 @@ func (fsys *FS) countError(err error) error {
 
 	return err
 }
+
+// RecordPanic records a panic recovered (by the caller) within the origin
+// (e.g. "fs" or "webserver"), printing it with the stack into the ring-buffer
+// (as an error) and counting it within the [Metrics.TotalPanics]. It needs to
+// be called from the deferred function that recovered, for the stack to show
+// where the panic has occurred. A fatal panic (e.g. of the goroutine serving
+// the filesystem) also has [FS.Healthy] report the filesystem as unhealthy.
+func (fsys *FS) RecordPanic(origin string, r any, fatal bool) {
+	fsys.Metrics.TotalPanics.Add(1)
+	if fatal {
+		fsys.panicked.Store(true)
+	}
+
+	fsys.rbuf.Printf("Panic error: (%s) %v\n%s", origin, r, debug.Stack())
+}
+
+// Healthy returns if the filesystem is not known to have stopped serving,
+// which is the case after any fatal panic was recorded by [FS.RecordPanic].
+func (fsys *FS) Healthy() bool {
+	return !fsys.panicked.Load()
+}
+
+// MarkReady marks the filesystem as serving requests (see [FS.Ready]), which
+// is to be called once a request was served (e.g. a self-test after mounting).
+func (fsys *FS) MarkReady() {
+	fsys.ready.Store(true)
+}
+
+// Ready returns if the filesystem is known to be serving requests, which is
+// not yet the case once mounted (until [FS.MarkReady]), nor when unmounting
+// or after a fatal panic was recorded by [FS.RecordPanic].
+func (fsys *FS) Ready() bool {
+	return fsys.ready.Load() && fsys.Healthy()
+}
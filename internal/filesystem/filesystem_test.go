@@ -1,6 +1,7 @@
 package filesystem
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"io"
@@ -8,6 +9,7 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"syscall"
 	"testing"
 	"time"
 
@@ -21,9 +23,17 @@ import (
 func testFS(t *testing.T, out io.Writer) (string, *FS) {
 	t.Helper()
 
+	return testFSOptions(t, out, nil)
+}
+
+// testFSOptions is [testFS], but with the given [Options] (for those which
+// can no longer be modified at runtime, e.g. [Options.FDLimit]).
+func testFSOptions(t *testing.T, out io.Writer, opts *Options) (string, *FS) {
+	t.Helper()
+
 	tmp := t.TempDir()
 	rbf := logging.NewRingBuffer(10, out)
-	fsys, err := NewFS(tmp, nil, rbf)
+	fsys, err := NewFS(tmp, opts, rbf)
 	require.NoError(t, err)
 
 	t.Cleanup(func() {
@@ -47,6 +57,18 @@ func createTestZip(t *testing.T, tmpDir string, tmpName string, entries []struct
 ) string {
 	t.Helper()
 
+	return createTestZipMethod(t, tmpDir, tmpName, zip.Store, entries)
+}
+
+// createTestZipMethod is [createTestZip], but using the given compression method.
+func createTestZipMethod(t *testing.T, tmpDir string, tmpName string, method uint16, entries []struct {
+	Path    string
+	ModTime time.Time
+	Content []byte // optional, only for files (can be nil)
+},
+) string {
+	t.Helper()
+
 	tmpFile, err := os.Create(filepath.Join(tmpDir, tmpName))
 	require.NoError(t, err)
 	defer tmpFile.Close()
@@ -57,7 +79,7 @@ func createTestZip(t *testing.T, tmpDir string, tmpName string, entries []struct
 	for _, entry := range entries {
 		header := &zip.FileHeader{
 			Name:     entry.Path,
-			Method:   zip.Store,
+			Method:   method,
 			Modified: entry.ModTime,
 		}
 
@@ -134,6 +156,25 @@ func Test_NewFS_Errors(t *testing.T) {
 			opts:      &Options{FDLimit: 10, FDCacheSize: 20},
 			wantErr:   "fd limit cannot be <= fd cache size",
 		},
+		{
+			name:      "NoFileDescriptorsToPin",
+			sourceDir: tmp,
+			rbuf:      logging.NewRingBuffer(10, io.Discard),
+			opts: func() *Options {
+				opts := &Options{FDLimit: 11, FDCacheSize: 10}
+				_ = opts.PinGlobs.Set("*.zip", true)
+
+				return opts
+			}(),
+			wantErr: "fd limit must be > fd cache size + 1 to pin archives",
+		},
+		{
+			name:      "EntryWithoutArchive",
+			sourceDir: tmp,
+			rbuf:      logging.NewRingBuffer(10, io.Discard),
+			opts:      &Options{Entry: "disk.img"},
+			wantErr:   "entry needs a single archive as sourceDir",
+		},
 	}
 
 	for _, tt := range tests {
@@ -164,6 +205,88 @@ func Test_FS_Root_Success(t *testing.T) {
 	require.NotZero(t, dn.mtime)
 }
 
+// Expectation: With an entry, the root should be that file within the archive,
+// for both FlatMode = false and FlatMode = true.
+func Test_FS_Root_Entry_Success(t *testing.T) {
+	t.Parallel()
+
+	for _, flat := range []bool{false, true} {
+		t.Run("FlatMode="+strconv.FormatBool(flat), func(t *testing.T) {
+			t.Parallel()
+
+			tmpDir := t.TempDir()
+			content := bytes.Repeat([]byte("disk image "), 100)
+
+			archive := createTestZip(t, tmpDir, "test.zip", []struct {
+				Path    string
+				ModTime time.Time
+				Content []byte
+			}{
+				{Path: "other.txt", ModTime: time.Now(), Content: []byte("other")},
+				{Path: "images/disk.img", ModTime: time.Now(), Content: content},
+			})
+
+			opts := DefaultOptions()
+			opts.FlatMode = flat
+			opts.Entry = "images/disk.img"
+			if flat {
+				opts.Entry = "disk(1).img"
+			}
+
+			fsys, err := NewFS(archive, opts, logging.NewRingBuffer(10, io.Discard))
+			require.NoError(t, err)
+			defer fsys.Destroy()
+
+			node, err := fsys.Root()
+			require.NoError(t, err)
+
+			fn, ok := node.(*zipInMemoryFileNode)
+			require.True(t, ok)
+
+			var attr fuse.Attr
+			require.NoError(t, fn.Attr(t.Context(), &attr))
+			require.True(t, attr.Mode.IsRegular())
+			require.Equal(t, uint64(len(content)), attr.Size)
+
+			handle, err := fn.Open(t.Context(), &fuse.OpenRequest{Flags: fuse.OpenReadOnly}, &fuse.OpenResponse{})
+			require.NoError(t, err)
+
+			var buf bytes.Buffer
+			_, err = copyHandle(t.Context(), handle, len(content), &buf)
+			require.NoError(t, err)
+			require.Equal(t, content, buf.Bytes())
+		})
+	}
+}
+
+// Expectation: With an entry being a directory or missing, the root should error.
+func Test_FS_Root_Entry_Error(t *testing.T) {
+	t.Parallel()
+
+	tmpDir := t.TempDir()
+
+	archive := createTestZip(t, tmpDir, "test.zip", []struct {
+		Path    string
+		ModTime time.Time
+		Content []byte
+	}{
+		{Path: "images/disk.img", ModTime: time.Now(), Content: []byte("disk")},
+	})
+
+	for _, entry := range []string{"images", "images/missing.img"} {
+		opts := DefaultOptions()
+		opts.Entry = entry
+
+		fsys, err := NewFS(archive, opts, logging.NewRingBuffer(10, io.Discard))
+		require.NoError(t, err)
+
+		_, err = fsys.Root()
+		require.Error(t, err, "entry %q", entry)
+
+		fsys.Destroy()
+	}
+}
+
 // Expectation: Two FS over the same root should produce identical results,
 // for both FlatMode = false and FlatMode = true.
 func Test_FS_Deterministic_Success(t *testing.T) {
@@ -371,6 +494,226 @@ func Test_FS_Walk_Success(t *testing.T) {
 	require.Len(t, visited, 20)
 }
 
+// Expectation: Walk should visit all entries of archives, also those that are
+// truncated from their listings by MaxListEntries (and never the marker entry).
+func Test_FS_Walk_MaxListEntries_Success(t *testing.T) {
+	t.Parallel()
+
+	tmpDir, fsys := testFS(t, io.Discard)
+	fsys.Options.MaxListEntries = 1
+	tnow := time.Now()
+
+	createTestZip(t, tmpDir, "test.zip", []struct {
+		Path    string
+		ModTime time.Time
+		Content []byte
+	}{
+		{Path: "a.txt", ModTime: tnow, Content: []byte("a")},
+		{Path: "b.txt", ModTime: tnow, Content: []byte("b")},
+		{Path: "docs/c.txt", ModTime: tnow, Content: []byte("c")},
+	})
+
+	for _, workers := range []int{0, 2} {
+		var visited []string
+
+		walkFn := func(path string, _ *fuse.Dirent, _ fs.Node, _ fuse.Attr) error {
+			visited = append(visited, path)
+
+			return nil
+		}
+
+		var err error
+		if workers > 0 {
+			err = fsys.WalkConcurrent(t.Context(), workers, walkFn)
+		} else {
+			err = fsys.Walk(t.Context(), walkFn)
+		}
+		require.NoError(t, err, "%d workers", workers)
+
+		require.ElementsMatch(t, []string{
+			"/", "/test", "/test/docs", "/test/docs/c.txt", "/test/a.txt", "/test/b.txt",
+		}, visited, "%d workers", workers)
+	}
+}
+
+// Expectation: WalkArchive should walk only the given archive, with the same inodes as Walk.
+func Test_FS_WalkArchive_Success(t *testing.T) {
+	t.Parallel()
+
+	tmpDir, fsys := testFS(t, io.Discard)
+	tnow := time.Now()
+
+	require.NoError(t, os.MkdirAll(filepath.Join(tmpDir, "dir1", "dir"), 0o777))
+
+	zipPath := createTestZip(t, filepath.Join(tmpDir, "dir1", "dir"), "test.zip", []struct {
+		Path    string
+		ModTime time.Time
+		Content []byte
+	}{
+		{Path: "file.txt", ModTime: tnow, Content: []byte("test content")},
+		{Path: "docs/", ModTime: tnow, Content: nil},
+		{Path: "docs/a.txt", ModTime: tnow, Content: []byte("test content")},
+	})
+	createTestZip(t, tmpDir, "other.zip", []struct {
+		Path    string
+		ModTime time.Time
+		Content []byte
+	}{
+		{Path: "other.txt", ModTime: tnow, Content: []byte("test content")},
+	})
+
+	all := make(map[string]uint64)
+	require.NoError(t, fsys.Walk(t.Context(), func(path string, _ *fuse.Dirent, _ fs.Node, a fuse.Attr) error {
+		all[path] = a.Inode
+
+		return nil
+	}))
+
+	visited := make(map[string]uint64)
+	err := fsys.WalkArchive(t.Context(), zipPath, func(path string, d *fuse.Dirent, _ fs.Node, a fuse.Attr) error {
+		require.NotNil(t, d)
+		visited[path] = a.Inode
+
+		return nil
+	})
+	require.NoError(t, err)
+
+	require.Equal(t, map[string]uint64{
+		"/dir1/dir/test":            all["/dir1/dir/test"],
+		"/dir1/dir/test/file.txt":   all["/dir1/dir/test/file.txt"],
+		"/dir1/dir/test/docs":       all["/dir1/dir/test/docs"],
+		"/dir1/dir/test/docs/a.txt": all["/dir1/dir/test/docs/a.txt"],
+	}, visited)
+}
+
+// Expectation: WalkArchive should return ErrNotArchive for paths that are not
+// archives within the source directory, without calling the callback.
+func Test_FS_WalkArchive_NotArchive_Error(t *testing.T) {
+	t.Parallel()
+
+	tmpDir, fsys := testFS(t, io.Discard)
+	outsideDir := t.TempDir()
+
+	require.NoError(t, os.WriteFile(filepath.Join(tmpDir, "file.txt"), []byte("x"), 0o644))
+	require.NoError(t, os.MkdirAll(filepath.Join(tmpDir, "dir.zip"), 0o777))
+	require.NoError(t, os.WriteFile(filepath.Join(outsideDir, "outside.zip"), []byte("x"), 0o644))
+
+	require.NoError(t, os.MkdirAll(filepath.Join(tmpDir, "hidden"), 0o777))
+	require.NoError(t, os.WriteFile(filepath.Join(tmpDir, "hidden.zip"), []byte("x"), 0o644))
+
+	tests := []struct {
+		name string
+		path string
+	}{
+		{"missing", filepath.Join(tmpDir, "missing.zip")},
+		{"no extension", filepath.Join(tmpDir, "file.txt")},
+		{"directory", filepath.Join(tmpDir, "dir.zip")},
+		{"outside", filepath.Join(outsideDir, "outside.zip")},
+		{"source", tmpDir},
+		{"hidden by directory", filepath.Join(tmpDir, "hidden.zip")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := fsys.WalkArchive(t.Context(), tt.path, func(_ string, _ *fuse.Dirent, _ fs.Node, _ fuse.Attr) error {
+				t.Fatal("walk should not begin for an invalid archive")
+
+				return nil
+			})
+			require.ErrorIs(t, err, ErrNotArchive)
+		})
+	}
+}
+
+// Expectation: OpenArchiveFile should open the backing file of an archive
+// by its relative path, but never any path that is not such an archive.
+func Test_FS_OpenArchiveFile_Success(t *testing.T) {
+	t.Parallel()
+
+	tmpDir, fsys := testFS(t, io.Discard)
+
+	require.NoError(t, os.MkdirAll(filepath.Join(tmpDir, "sub"), 0o777))
+	require.NoError(t, os.WriteFile(filepath.Join(tmpDir, "sub", "test.zip"), []byte("archive"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(tmpDir, "file.txt"), []byte("x"), 0o644))
+
+	f, info, err := fsys.OpenArchiveFile("sub/test.zip")
+	require.NoError(t, err)
+	defer f.Close()
+
+	require.Equal(t, int64(7), info.Size())
+
+	data, err := io.ReadAll(f)
+	require.NoError(t, err)
+	require.Equal(t, "archive", string(data))
+
+	for _, rel := range []string{"", "file.txt", "sub/missing.zip", "../outside.zip", "sub/../../outside.zip"} {
+		_, _, err := fsys.OpenArchiveFile(rel)
+		require.ErrorIs(t, err, ErrNotArchive, "path %q", rel)
+	}
+}
+
+// Expectation: OpenArchiveFile should open archives by symlinks resolving to
+// within the source directory, but never by those escaping it, and hold a slot
+// of the FD semaphore for each opened file until it is closed.
+func Test_FS_OpenArchiveFile_Symlink_Error(t *testing.T) {
+	t.Parallel()
+
+	tmpDir, fsys := testFS(t, io.Discard)
+	outside := t.TempDir()
+
+	require.NoError(t, os.WriteFile(filepath.Join(tmpDir, "test.zip"), []byte("archive"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(outside, "secret.zip"), []byte("secret"), 0o644))
+
+	require.NoError(t, os.Symlink(filepath.Join(tmpDir, "test.zip"), filepath.Join(tmpDir, "inside.zip")))
+	require.NoError(t, os.Symlink(filepath.Join(outside, "secret.zip"), filepath.Join(tmpDir, "escape.zip")))
+	require.NoError(t, os.Symlink(outside, filepath.Join(tmpDir, "escape")))
+
+	f, _, err := fsys.OpenArchiveFile("inside.zip")
+	require.NoError(t, err)
+	require.Len(t, fsys.fdlimit, 1)
+
+	data, err := io.ReadAll(f)
+	require.NoError(t, err)
+	require.Equal(t, "archive", string(data))
+
+	require.NoError(t, f.Close())
+	require.Empty(t, fsys.fdlimit)
+
+	for _, rel := range []string{"escape.zip", "escape/secret.zip"} {
+		_, _, err := fsys.OpenArchiveFile(rel)
+		require.ErrorIs(t, err, ErrNotArchive, "path %q", rel)
+	}
+	require.Empty(t, fsys.fdlimit)
+}
+
+// Expectation: OpenArchiveFile should return ErrFDLimitReached (not block)
+// while all slots of the FD semaphore are in use.
+func Test_FS_OpenArchiveFile_FDLimit_Error(t *testing.T) {
+	t.Parallel()
+
+	tmpDir, fsys := testFS(t, io.Discard)
+	require.NoError(t, os.WriteFile(filepath.Join(tmpDir, "test.zip"), []byte("archive"), 0o644))
+
+	for range cap(fsys.fdlimit) {
+		fsys.fdlimit <- struct{}{}
+	}
+
+	_, _, err := fsys.OpenArchiveFile("test.zip")
+	require.ErrorIs(t, err, ErrFDLimitReached)
+
+	<-fsys.fdlimit
+
+	f, _, err := fsys.OpenArchiveFile("test.zip")
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	for range cap(fsys.fdlimit) - 1 {
+		<-fsys.fdlimit
+	}
+}
+
 // Expectation: Walk should propagate errors returned by the callback.
 func Test_FS_Walk_CallbackError_Error(t *testing.T) {
 	t.Parallel()
@@ -387,6 +730,20 @@ func Test_FS_Walk_CallbackError_Error(t *testing.T) {
 	require.ErrorIs(t, err, testErr)
 }
 
+// Expectation: Walk should return an error that can be matched against the sentinel errors.
+func Test_FS_Walk_ArchiveUnreadable_Error(t *testing.T) {
+	t.Parallel()
+	tmpDir, fsys := testFS(t, io.Discard)
+
+	require.NoError(t, os.WriteFile(filepath.Join(tmpDir, "bad.zip"), []byte("not a zip"), 0o644))
+
+	err := fsys.Walk(t.Context(), func(_ string, _ *fuse.Dirent, _ fs.Node, _ fuse.Attr) error {
+		return nil
+	})
+	require.ErrorIs(t, err, ErrArchiveUnreadable)
+	require.ErrorIs(t, err, fuse.ToErrno(syscall.EINVAL))
+}
+
 // Expectation: Walk should respect a context cancellation and report the correct error.
 func Test_FS_Walk_ContextError_Error(t *testing.T) {
 	t.Parallel()
@@ -416,3 +773,462 @@ func Test_FS_countError_Success(t *testing.T) {
 	require.ErrorIs(t, err, customErr)
 	require.Equal(t, int64(1), fsys.Metrics.Errors.Load())
 }
+
+// Expectation: ExtractEntry should copy the (ranged) contents of a file within an
+// archive, for both stored and deflated files, by its stored or normalized path.
+func Test_FS_ExtractEntry_Success(t *testing.T) {
+	t.Parallel()
+
+	for _, method := range []uint16{zip.Store, zip.Deflate} {
+		t.Run(strconv.Itoa(int(method)), func(t *testing.T) {
+			t.Parallel()
+			tmpDir, fsys := testFS(t, io.Discard)
+
+			content := bytes.Repeat([]byte("0123456789"), 100)
+
+			zipPath := createTestZipMethod(t, tmpDir, "test.zip", method, []struct {
+				Path    string
+				ModTime time.Time
+				Content []byte
+			}{
+				{Path: "dir/file.bin", ModTime: time.Now(), Content: content},
+			})
+
+			var buf bytes.Buffer
+			n, err := fsys.ExtractEntry(zipPath, "dir/file.bin", 0, -1, &buf)
+			require.NoError(t, err)
+			require.Equal(t, int64(len(content)), n)
+			require.Equal(t, content, buf.Bytes())
+
+			buf.Reset()
+			n, err = fsys.ExtractEntry(zipPath, "/dir/file.bin", 105, 10, &buf)
+			require.NoError(t, err)
+			require.Equal(t, int64(10), n)
+			require.Equal(t, content[105:115], buf.Bytes())
+
+			buf.Reset()
+			n, err = fsys.ExtractEntry(zipPath, "dir/file.bin", 995, 10, &buf)
+			require.NoError(t, err)
+			require.Equal(t, int64(5), n)
+			require.Equal(t, content[995:], buf.Bytes())
+		})
+	}
+}
+
+// Expectation: ExtractEntry should retain the sentinel errors for an unreadable
+// archive and for a missing file (or directory) within the archive.
+func Test_FS_ExtractEntry_Error(t *testing.T) {
+	t.Parallel()
+	tmpDir, fsys := testFS(t, io.Discard)
+
+	zipPath := createTestZip(t, tmpDir, "test.zip", []struct {
+		Path    string
+		ModTime time.Time
+		Content []byte
+	}{
+		{Path: "dir/file.txt", ModTime: time.Now(), Content: []byte("content")},
+	})
+
+	for _, name := range []string{"dir", "dir/", "missing.txt"} {
+		_, err := fsys.ExtractEntry(zipPath, name, 0, -1, io.Discard)
+		require.ErrorIs(t, err, ErrEntryNotFound, "name %q", name)
+	}
+
+	invalidPath := filepath.Join(tmpDir, "invalid.zip")
+	require.NoError(t, os.WriteFile(invalidPath, []byte("not a zip"), 0o600))
+
+	_, err := fsys.ExtractEntry(invalidPath, "dir/file.txt", 0, -1, io.Discard)
+	require.ErrorIs(t, err, ErrArchiveUnreadable)
+
+	_, err = fsys.ExtractEntry(zipPath, "dir/file.txt", -1, -1, io.Discard)
+	require.Error(t, err)
+}
+
+// Expectation: HeldFDs should account for the archives held open, until released.
+func Test_FS_HeldFDs_Success(t *testing.T) {
+	t.Parallel()
+	tmpDir, fsys := testFS(t, io.Discard)
+
+	zipPath := createTestZip(t, tmpDir, "test.zip", []struct {
+		Path    string
+		ModTime time.Time
+		Content []byte
+	}{
+		{Path: "file.txt", ModTime: time.Now(), Content: []byte("content")},
+	})
+
+	require.Zero(t, fsys.HeldFDs())
+
+	zr, err := newZipReader(fsys, zipPath)
+	require.NoError(t, err)
+	require.Equal(t, 1, fsys.HeldFDs())
+
+	require.NoError(t, zr.Release())
+	require.Zero(t, fsys.HeldFDs())
+}
+
+// Expectation: CachedFDs should account for the archives held by the cache, until evicted.
+func Test_FS_CachedFDs_Success(t *testing.T) {
+	t.Parallel()
+	tmpDir, fsys := testFS(t, io.Discard)
+
+	zipPath := createTestZip(t, tmpDir, "test.zip", []struct {
+		Path    string
+		ModTime time.Time
+		Content []byte
+	}{
+		{Path: "file.txt", ModTime: time.Now(), Content: []byte("content")},
+	})
+
+	require.Zero(t, fsys.CachedFDs())
+
+	zr, err := fsys.fdcache.Archive(zipPath)
+	require.NoError(t, err)
+	require.NoError(t, zr.Release())
+	require.Equal(t, 1, fsys.CachedFDs())
+	require.Equal(t, 1, fsys.HeldFDs())
+
+	require.True(t, fsys.fdcache.Evict(zipPath))
+	require.Zero(t, fsys.CachedFDs())
+}
+
+// Expectation: The real timestamp should be returned unless a fixed one is set.
+func Test_FS_attrTime_Success(t *testing.T) {
+	t.Parallel()
+	_, fsys := testFS(t, io.Discard)
+
+	actual := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+	require.Equal(t, actual, fsys.attrTime(actual))
+
+	fixed := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	fsys.Options.FixedMtime = fixed
+	require.Equal(t, fixed, fsys.attrTime(actual))
+}
+
+// Expectation: A fixed timestamp should be reported for all nodes in a walk.
+func Test_FS_Walk_FixedMtime_Success(t *testing.T) {
+	t.Parallel()
+	tmpDir, fsys := testFS(t, io.Discard)
+
+	fixed := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	fsys.Options.FixedMtime = fixed
+
+	require.NoError(t, os.Mkdir(filepath.Join(tmpDir, "dir"), 0o755))
+	createTestZip(t, filepath.Join(tmpDir, "dir"), "test.zip", []struct {
+		Path    string
+		ModTime time.Time
+		Content []byte
+	}{
+		{Path: "sub/", ModTime: time.Now(), Content: nil},
+		{Path: "sub/file.txt", ModTime: time.Now(), Content: []byte("content")},
+	})
+
+	var count int
+	err := fsys.Walk(t.Context(), func(_ string, _ *fuse.Dirent, _ fs.Node, attr fuse.Attr) error {
+		require.Equal(t, fixed, attr.Atime)
+		require.Equal(t, fixed, attr.Ctime)
+		require.Equal(t, fixed, attr.Mtime)
+		count++
+
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, 5, count)
+}
+
+// Expectation: VerifyArchives should open all archives, returning each unreadable one.
+func Test_FS_VerifyArchives_Success(t *testing.T) {
+	t.Parallel()
+
+	tmpDir, fsys := testFS(t, io.Discard)
+	tnow := time.Now()
+
+	require.NoError(t, os.MkdirAll(filepath.Join(tmpDir, "sub"), 0o777))
+	require.NoError(t, os.WriteFile(filepath.Join(tmpDir, "sub", "bad.zip"), []byte("not a zip"), 0o644))
+
+	createTestZip(t, tmpDir, "good.zip", []struct {
+		Path    string
+		ModTime time.Time
+		Content []byte
+	}{
+		{Path: "dir/file.txt", ModTime: tnow, Content: []byte("content")},
+	})
+
+	failures, err := fsys.VerifyArchives(t.Context(), 4)
+	require.NoError(t, err)
+	require.Len(t, failures, 1)
+	require.ErrorIs(t, failures[0], ErrArchiveUnreadable)
+	require.Contains(t, failures[0].Error(), "bad.zip")
+
+	require.Equal(t, 1, fsys.fdcache.cache.Len())
+	require.Equal(t, int64(1), fsys.Metrics.TotalOpenedZips.Load())
+	require.Zero(t, fsys.Metrics.TotalMetadataReadCount.Load()) // not walked into
+}
+
+// Expectation: RecordPanic should count the panic and print it with the stack,
+// only a fatal panic having the filesystem report itself as unhealthy.
+func Test_FS_RecordPanic_Success(t *testing.T) {
+	t.Parallel()
+
+	_, fsys := testFS(t, io.Discard)
+
+	func() {
+		defer func() {
+			if r := recover(); r != nil {
+				fsys.RecordPanic("test", r, false)
+			}
+		}()
+		panic("recoverable")
+	}()
+
+	require.Equal(t, int64(1), fsys.Metrics.TotalPanics.Load())
+	require.True(t, fsys.Healthy())
+
+	lines := fsys.rbuf.Lines()
+	require.Len(t, lines, 1)
+	require.Contains(t, lines[0], "Panic error: (test) recoverable")
+	require.Contains(t, lines[0], "Test_FS_RecordPanic_Success")
+
+	fsys.RecordPanic("test", "fatal", true)
+
+	require.Equal(t, int64(2), fsys.Metrics.TotalPanics.Load())
+	require.False(t, fsys.Healthy())
+}
+
+// Expectation: The filesystem should be ready only once marked, no longer while
+// unmounting (unless that failed) and never after a fatal panic.
+func Test_FS_Ready_Success(t *testing.T) {
+	t.Parallel()
+
+	_, fsys := testFS(t, io.Discard)
+	require.False(t, fsys.Ready())
+
+	fsys.MarkReady()
+	require.True(t, fsys.Ready())
+
+	unmountErr := make(chan error, 1)
+	fsys.PrepareUnmount(unmountErr)
+	require.False(t, fsys.Ready())
+
+	unmountErr <- errors.New("busy")
+	require.Eventually(t, fsys.Ready, time.Second, 10*time.Millisecond)
+
+	fsys.RecordPanic("test", "fatal", true)
+	require.False(t, fsys.Ready())
+}
+
+// Expectation: CopyFile should copy the contents of in-memory and streamed files,
+// resolving the paths as presented (also in flat mode).
+func Test_FS_CopyFile_Success(t *testing.T) {
+	t.Parallel()
+
+	for _, flat := range []bool{false, true} {
+		t.Run("FlatMode="+strconv.FormatBool(flat), func(t *testing.T) {
+			t.Parallel()
+
+			tmpDir, fsys := testFS(t, io.Discard)
+			fsys.Options.FlatMode = flat
+			fsys.Options.StreamPoolSize = 7
+
+			small := []byte("small")
+			large := bytes.Repeat([]byte("large content "), 100)
+
+			createTestZip(t, tmpDir, "test.zip", []struct {
+				Path    string
+				ModTime time.Time
+				Content []byte
+			}{
+				{Path: "dir/small.txt", ModTime: time.Now(), Content: small},
+				{Path: "dir/large.txt", ModTime: time.Now(), Content: large},
+			})
+			fsys.Options.StreamingThreshold.Store(uint64(len(small)))
+
+			smallPath, largePath := "/test/dir/small.txt", "/test/dir/large.txt"
+			if flat {
+				smallPath, largePath = "/test/small(0).txt", "/test/large(1).txt"
+			}
+
+			var buf bytes.Buffer
+			n, err := fsys.CopyFile(t.Context(), smallPath, &buf)
+			require.NoError(t, err)
+			require.Equal(t, int64(len(small)), n)
+			require.Equal(t, small, buf.Bytes())
+
+			buf.Reset()
+			n, err = fsys.CopyFile(t.Context(), largePath, &buf)
+			require.NoError(t, err)
+			require.Equal(t, int64(len(large)), n)
+			require.Equal(t, large, buf.Bytes())
+		})
+	}
+}
+
+// Expectation: CopyFile should return ErrEntryNotFound for missing files and directories.
+func Test_FS_CopyFile_NotFound_Error(t *testing.T) {
+	t.Parallel()
+
+	tmpDir, fsys := testFS(t, io.Discard)
+
+	createTestZip(t, tmpDir, "test.zip", []struct {
+		Path    string
+		ModTime time.Time
+		Content []byte
+	}{
+		{Path: "dir/file.txt", ModTime: time.Now(), Content: []byte("content")},
+	})
+
+	for _, name := range []string{"/test/dir", "/test/missing.txt", "/missing/file.txt", "/test/dir/file.txt/x"} {
+		_, err := fsys.CopyFile(t.Context(), name, io.Discard)
+		require.ErrorIs(t, err, ErrEntryNotFound, "path %q", name)
+	}
+}
+
+// Expectation: ChangedEntries should return only the files modified after the given time.
+func Test_FS_ChangedEntries_Success(t *testing.T) {
+	t.Parallel()
+
+	tmpDir, fsys := testFS(t, io.Discard)
+	tnow := time.Now()
+
+	require.NoError(t, os.MkdirAll(filepath.Join(tmpDir, "sub"), 0o777))
+	require.NoError(t, os.WriteFile(filepath.Join(tmpDir, "bad.zip"), []byte("not a zip"), 0o644))
+
+	createTestZip(t, filepath.Join(tmpDir, "sub"), "test.zip", []struct {
+		Path    string
+		ModTime time.Time
+		Content []byte
+	}{
+		{Path: "dir/", ModTime: tnow},
+		{Path: "dir/new.txt", ModTime: tnow, Content: []byte("content")},
+		{Path: "old.txt", ModTime: tnow.Add(-48 * time.Hour), Content: []byte("content")},
+	})
+
+	entries, truncated, err := fsys.ChangedEntries(t.Context(), tnow.Add(-time.Hour), 0)
+	require.NoError(t, err)
+	require.False(t, truncated)
+	require.Len(t, entries, 1)
+
+	require.Equal(t, "/sub/test/dir/new.txt", entries[0].Path)
+	require.Equal(t, uint64(7), entries[0].Size)
+	require.WithinDuration(t, tnow, entries[0].Mtime, 2*time.Second)
+}
+
+// Expectation: ChangedEntries should stop at the limit and report the truncation.
+func Test_FS_ChangedEntries_Limit_Success(t *testing.T) {
+	t.Parallel()
+
+	tmpDir, fsys := testFS(t, io.Discard)
+	tnow := time.Now()
+
+	createTestZip(t, tmpDir, "test.zip", []struct {
+		Path    string
+		ModTime time.Time
+		Content []byte
+	}{
+		{Path: "a.txt", ModTime: tnow, Content: []byte("a")},
+		{Path: "b.txt", ModTime: tnow, Content: []byte("b")},
+	})
+
+	entries, truncated, err := fsys.ChangedEntries(t.Context(), tnow.Add(-time.Hour), 1)
+	require.NoError(t, err)
+	require.True(t, truncated)
+	require.Len(t, entries, 1)
+	require.Equal(t, "/test/a.txt", entries[0].Path)
+}
+
+// Expectation: ChangedEntries should return an error on an expired context.
+func Test_FS_ChangedEntries_Context_Error(t *testing.T) {
+	t.Parallel()
+
+	_, fsys := testFS(t, io.Discard)
+
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+
+	_, _, err := fsys.ChangedEntries(ctx, time.Time{}, 0)
+	require.ErrorIs(t, err, context.Canceled)
+}
+
+// Expectation: Walk should skip over the contents of a directory when ErrSkipDir is returned.
+func Test_FS_Walk_SkipDir_Success(t *testing.T) {
+	t.Parallel()
+
+	tmpDir, fsys := testFS(t, io.Discard)
+
+	createTestZip(t, tmpDir, "test.zip", []struct {
+		Path    string
+		ModTime time.Time
+		Content []byte
+	}{
+		{Path: "file.txt", ModTime: time.Now(), Content: []byte("content")},
+	})
+
+	var paths []string
+	err := fsys.Walk(t.Context(), func(path string, _ *fuse.Dirent, node fs.Node, _ fuse.Attr) error {
+		paths = append(paths, path)
+		if _, ok := node.(*zipDirNode); ok {
+			return ErrSkipDir
+		}
+
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, []string{"/", "/test"}, paths)
+}
+
+// Expectation: The operation counters should be incremented by their respective
+// node methods, with an in-memory file falling back to streaming counted once.
+func Test_FS_OperationMetrics_Success(t *testing.T) {
+	t.Parallel()
+	tmpDir, fsys := testFS(t, io.Discard)
+
+	createTestZip(t, tmpDir, "test.zip", []struct {
+		Path    string
+		ModTime time.Time
+		Content []byte
+	}{
+		{Path: "file.txt", ModTime: time.Now(), Content: []byte("content")},
+	})
+
+	root := &realDirNode{fsys: fsys, inode: 1, path: tmpDir, mtime: time.Now()}
+
+	_, err := root.ReadDirAll(t.Context())
+	require.NoError(t, err)
+
+	dn, err := root.Lookup(t.Context(), "test")
+	require.NoError(t, err)
+
+	zd, ok := dn.(*zipDirNode)
+	require.True(t, ok)
+
+	_, err = zd.ReadDirAll(t.Context())
+	require.NoError(t, err)
+
+	fn, err := zd.Lookup(t.Context(), "file.txt")
+	require.NoError(t, err)
+
+	mn, ok := fn.(*zipInMemoryFileNode)
+	require.True(t, ok)
+
+	_, err = mn.Open(t.Context(), &fuse.OpenRequest{Flags: fuse.OpenReadOnly}, &fuse.OpenResponse{})
+	require.NoError(t, err)
+
+	_, err = mn.ReadAll(t.Context())
+	require.NoError(t, err)
+
+	require.Equal(t, int64(2), fsys.Metrics.TotalReadDirs.Load())
+	require.Equal(t, int64(2), fsys.Metrics.TotalLookups.Load())
+	require.Equal(t, int64(1), fsys.Metrics.TotalOpens.Load())
+	require.Equal(t, int64(1), fsys.Metrics.TotalReads.Load())
+
+	fsys.Options.MaxTotalInMemoryBytes = 1
+
+	h, err := mn.Open(t.Context(), &fuse.OpenRequest{Flags: fuse.OpenReadOnly}, &fuse.OpenResponse{})
+	require.NoError(t, err)
+	require.Equal(t, int64(2), fsys.Metrics.TotalOpens.Load())
+	require.Equal(t, int64(1), fsys.Metrics.TotalInMemoryFallbacks.Load())
+
+	sh, ok := h.(*zipDiskStreamFileHandle)
+	require.True(t, ok)
+	require.NoError(t, sh.Release(t.Context(), &fuse.ReleaseRequest{}))
+}
@@ -0,0 +1,145 @@
+package filesystem
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// httpResponseHeaderTimeout is the time a remote server has to respond.
+	httpResponseHeaderTimeout = 30 * time.Second
+)
+
+var (
+	_ io.ReaderAt = (*httpReaderAt)(nil)
+	_ io.Closer   = (*httpReaderAt)(nil)
+
+	// errRemoteStatus is for an unexpected HTTP status of a remote server.
+	errRemoteStatus = errors.New("unexpected remote status")
+
+	// errRemoteSize is for a remote archive of unknown (or invalid) size.
+	errRemoteSize = errors.New("unknown remote size")
+)
+
+// isRemoteArchive returns if a path is an HTTP(S) URL of a remote archive.
+func isRemoteArchive(path string) bool {
+	return strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://")
+}
+
+// newHTTPClient returns a new [http.Client] for reading remote archives.
+// The connections per remote host are capped by [Options.FDLimit], so that
+// the limit on file descriptors also applies as a limit on the connections.
+func newHTTPClient(opts *Options) *http.Client {
+	transport, ok := http.DefaultTransport.(*http.Transport)
+	if !ok {
+		return &http.Client{}
+	}
+
+	transport = transport.Clone()
+	transport.MaxConnsPerHost = opts.FDLimit
+	transport.ResponseHeaderTimeout = httpResponseHeaderTimeout
+
+	return &http.Client{Transport: transport}
+}
+
+// httpReaderAt is an [io.ReaderAt] for a remote archive, served by an HTTP(S)
+// server supporting range requests. Each ReadAt() is a single range request,
+// so that only the central directory and the actually read entries are fetched.
+// It is thread-safe for concurrent use, as it holds no per-read state.
+type httpReaderAt struct {
+	client *http.Client
+	url    string
+	size   int64
+	mtime  time.Time
+}
+
+// newHTTPReaderAt returns a pointer to a new [httpReaderAt] for given URL.
+// The size and modification time are established with an initial request.
+func newHTTPReaderAt(client *http.Client, url string) (*httpReaderAt, error) {
+	req, err := http.NewRequest(http.MethodHead, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to request: %w", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: %s", errRemoteStatus, resp.Status)
+	}
+	if resp.ContentLength < 0 {
+		return nil, fmt.Errorf("%w: %s", errRemoteSize, url)
+	}
+
+	mtime, err := http.ParseTime(resp.Header.Get("Last-Modified"))
+	if err != nil {
+		mtime = time.Now()
+	}
+
+	return &httpReaderAt{
+		client: client,
+		url:    url,
+		size:   resp.ContentLength,
+		mtime:  mtime,
+	}, nil
+}
+
+// Size returns the size of the remote archive.
+func (r *httpReaderAt) Size() int64 {
+	return r.size
+}
+
+// ReadAt reads len(p) bytes at offset off with a single range request.
+// It returns [io.EOF] if fewer bytes were read due to the end of the archive.
+func (r *httpReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, fmt.Errorf("%w: negative offset", errInvalidArgument)
+	}
+	if off >= r.size {
+		return 0, io.EOF
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+
+	end := min(off+int64(len(p)), r.size)
+
+	req, err := http.NewRequest(http.MethodGet, r.url, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", off, end-1))
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusPartialContent {
+		return 0, fmt.Errorf("%w: %s (no range support?)", errRemoteStatus, resp.Status)
+	}
+
+	n, err := io.ReadFull(resp.Body, p[:end-off])
+	if err != nil {
+		return n, fmt.Errorf("failed to read: %w", err)
+	}
+
+	if n < len(p) {
+		return n, io.EOF
+	}
+
+	return n, nil
+}
+
+// Close is a no-op, as no connections are held between the range requests.
+func (r *httpReaderAt) Close() error {
+	return nil
+}
@@ -0,0 +1,187 @@
+package filesystem
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"bazil.org/fuse"
+	"bazil.org/fuse/fs"
+	"github.com/desertwitch/zipfuse/internal/logging"
+	"github.com/stretchr/testify/require"
+)
+
+// testRemoteServer serves a file with range support, counting the requests.
+func testRemoteServer(t *testing.T, path string, requests *atomic.Int64) *httptest.Server {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests != nil {
+			requests.Add(1)
+		}
+		http.ServeFile(w, r, path)
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+// Expectation: isRemoteArchive should only match HTTP(S) URLs.
+func Test_isRemoteArchive_Success(t *testing.T) {
+	t.Parallel()
+
+	require.True(t, isRemoteArchive("http://example.com/test.zip"))
+	require.True(t, isRemoteArchive("https://example.com/test.zip"))
+	require.False(t, isRemoteArchive("/tmp/http://test.zip"))
+	require.False(t, isRemoteArchive("ftp://example.com/test.zip"))
+	require.False(t, isRemoteArchive("test.zip"))
+}
+
+// Expectation: newHTTPClient should cap the connections per host by the FD limit.
+func Test_newHTTPClient_Success(t *testing.T) {
+	t.Parallel()
+
+	opts := DefaultOptions()
+	opts.FDLimit = 7
+
+	client := newHTTPClient(opts)
+
+	transport, ok := client.Transport.(*http.Transport)
+	require.True(t, ok)
+	require.Equal(t, 7, transport.MaxConnsPerHost)
+}
+
+// Expectation: ReadAt should read the requested ranges, returning io.EOF at the end.
+func Test_httpReaderAt_ReadAt_Success(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "data.bin")
+	require.NoError(t, os.WriteFile(path, []byte("0123456789"), 0o600))
+
+	srv := testRemoteServer(t, path, nil)
+
+	r, err := newHTTPReaderAt(srv.Client(), srv.URL)
+	require.NoError(t, err)
+	require.Equal(t, int64(10), r.Size())
+	require.False(t, r.mtime.IsZero())
+
+	p := make([]byte, 4)
+
+	n, err := r.ReadAt(p, 2)
+	require.NoError(t, err)
+	require.Equal(t, 4, n)
+	require.Equal(t, "2345", string(p))
+
+	n, err = r.ReadAt(p, 8)
+	require.ErrorIs(t, err, io.EOF)
+	require.Equal(t, 2, n)
+	require.Equal(t, "89", string(p[:n]))
+
+	n, err = r.ReadAt(p, 10)
+	require.ErrorIs(t, err, io.EOF)
+	require.Zero(t, n)
+
+	require.NoError(t, r.Close())
+}
+
+// Expectation: newHTTPReaderAt should return an error for a missing remote file.
+func Test_newHTTPReaderAt_NotFound_Error(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.NotFoundHandler())
+	t.Cleanup(srv.Close)
+
+	_, err := newHTTPReaderAt(srv.Client(), srv.URL)
+	require.ErrorIs(t, err, errRemoteStatus)
+}
+
+// Expectation: ReadAt should return an error if the server does not support ranges.
+func Test_httpReaderAt_ReadAt_NoRanges_Error(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Length", "10")
+		_, _ = w.Write([]byte("0123456789"))
+	}))
+	t.Cleanup(srv.Close)
+
+	r, err := newHTTPReaderAt(srv.Client(), srv.URL)
+	require.NoError(t, err)
+
+	_, err = r.ReadAt(make([]byte, 4), 2)
+	require.ErrorIs(t, err, errRemoteStatus)
+}
+
+// Expectation: A remote archive as source should be walkable and readable.
+func Test_FS_RemoteArchive_Success(t *testing.T) {
+	t.Parallel()
+
+	tmpDir := t.TempDir()
+	tnow := time.Now()
+	content := bytes.Repeat([]byte("remote content "), 1000)
+
+	zipPath := createTestZip(t, tmpDir, "test.zip", []struct {
+		Path    string
+		ModTime time.Time
+		Content []byte
+	}{
+		{Path: "file.txt", ModTime: tnow, Content: content},
+		{Path: "docs/", ModTime: tnow, Content: nil},
+		{Path: "docs/a.txt", ModTime: tnow, Content: []byte("a")},
+	})
+
+	var requests atomic.Int64
+	srv := testRemoteServer(t, zipPath, &requests)
+
+	fsys, err := NewFS(srv.URL+"/test.zip", nil, logging.NewRingBuffer(10, io.Discard))
+	require.NoError(t, err)
+	t.Cleanup(fsys.Destroy)
+
+	var paths []string
+	err = fsys.Walk(t.Context(), func(path string, _ *fuse.Dirent, _ fs.Node, _ fuse.Attr) error {
+		paths = append(paths, path)
+
+		return nil
+	})
+	require.NoError(t, err)
+
+	sort.Strings(paths)
+	require.Equal(t, []string{"/", "/docs", "/docs/a.txt", "/file.txt"}, paths)
+
+	root, err := fsys.Root()
+	require.NoError(t, err)
+
+	dir, ok := root.(*zipDirNode)
+	require.True(t, ok)
+
+	node, err := dir.Lookup(t.Context(), "file.txt")
+	require.NoError(t, err)
+
+	file, ok := node.(*zipInMemoryFileNode)
+	require.True(t, ok)
+
+	data, err := file.ReadAll(t.Context())
+	require.NoError(t, err)
+	require.Equal(t, content, data)
+
+	require.Positive(t, requests.Load())
+}
+
+// Expectation: NewFS should return an error for an unreachable remote archive.
+func Test_NewFS_RemoteArchive_Error(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.NotFoundHandler())
+	t.Cleanup(srv.Close)
+
+	_, err := NewFS(srv.URL+"/test.zip", nil, logging.NewRingBuffer(10, io.Discard))
+	require.ErrorIs(t, err, errInvalidArgument)
+	require.ErrorIs(t, err, errRemoteStatus)
+}
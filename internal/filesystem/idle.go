@@ -0,0 +1,75 @@
+package filesystem
+
+import (
+	"time"
+)
+
+// idleTicks is the amount of idle checks within one [Options.IdleTimeout].
+const idleTicks = 10
+
+// inactiveNoop is returned by [FS.active] with idle tracking being disabled.
+func inactiveNoop() {}
+
+// active marks the start of a served request for [Options.IdleTimeout],
+// returning the function marking its end (to be deferred by the caller).
+// Requests still in-flight never count as idle, no matter how long they take.
+func (fsys *FS) active() func() {
+	if fsys.Options.IdleTimeout <= 0 {
+		return inactiveNoop
+	}
+
+	fsys.inflight.Add(1)
+	fsys.lastActive.Store(time.Now().UnixNano())
+
+	return fsys.inactive
+}
+
+// inactive marks the end of a served request started with [FS.active].
+func (fsys *FS) inactive() {
+	fsys.lastActive.Store(time.Now().UnixNano())
+	fsys.inflight.Add(-1)
+}
+
+// IdleTime returns the time since the last served request has ended, or zero
+// while any requests are in-flight (or the filesystem is being unmounted).
+func (fsys *FS) IdleTime() time.Duration {
+	if fsys.inflight.Load() > 0 || fsys.idleHalted.Load() {
+		return 0
+	}
+
+	return time.Since(time.Unix(0, fsys.lastActive.Load()))
+}
+
+// WatchIdle calls onIdle (within a goroutine) once no requests were served for
+// [Options.IdleTimeout], e.g. for unmounting the filesystem. The idle time then
+// starts over, so that a failed unmount is only retried after another timeout.
+// The watch is halted by PrepareUnmount() (resuming if the unmount has failed)
+// and stopped by Destroy(). Without [Options.IdleTimeout], it is a no-op.
+func (fsys *FS) WatchIdle(onIdle func()) {
+	timeout := fsys.Options.IdleTimeout
+	if timeout <= 0 {
+		return
+	}
+
+	fsys.lastActive.Store(time.Now().UnixNano())
+
+	go func() {
+		ticker := time.NewTicker(max(timeout/idleTicks, time.Millisecond))
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-fsys.idleDone:
+				return
+
+			case <-ticker.C:
+				if fsys.IdleTime() < timeout {
+					continue
+				}
+				fsys.lastActive.Store(time.Now().UnixNano())
+
+				onIdle()
+			}
+		}
+	}()
+}
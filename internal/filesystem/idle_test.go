@@ -0,0 +1,87 @@
+package filesystem
+
+import (
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Expectation: Requests in-flight should never count as idle, with the
+// idle time only starting once the last request has ended.
+func Test_FS_IdleTime_Success(t *testing.T) {
+	t.Parallel()
+	_, fsys := testFS(t, io.Discard)
+	fsys.Options.IdleTimeout = time.Minute
+
+	done := fsys.active()
+	time.Sleep(10 * time.Millisecond)
+	require.Zero(t, fsys.IdleTime())
+
+	done()
+	time.Sleep(10 * time.Millisecond)
+	require.GreaterOrEqual(t, fsys.IdleTime(), 10*time.Millisecond)
+	require.Zero(t, fsys.inflight.Load())
+}
+
+// Expectation: Requests should not be tracked without an idle timeout.
+func Test_FS_IdleTime_Disabled_Success(t *testing.T) {
+	t.Parallel()
+	_, fsys := testFS(t, io.Discard)
+
+	done := fsys.active()
+	require.Zero(t, fsys.inflight.Load())
+	require.Zero(t, fsys.lastActive.Load())
+
+	done()
+	require.Zero(t, fsys.inflight.Load())
+}
+
+// Expectation: WatchIdle should call the callback once idle for the timeout,
+// but not while a request is in-flight.
+func Test_FS_WatchIdle_Success(t *testing.T) {
+	t.Parallel()
+	_, fsys := testFS(t, io.Discard)
+	fsys.Options.IdleTimeout = 50 * time.Millisecond
+
+	idle := make(chan struct{}, 10)
+
+	done := fsys.active()
+	fsys.WatchIdle(func() { idle <- struct{}{} })
+
+	select {
+	case <-idle:
+		t.Fatal("callback should not be called with a request in-flight")
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	done()
+
+	select {
+	case <-idle:
+	case <-time.After(time.Second):
+		t.Fatal("callback should have been called once idle")
+	}
+}
+
+// Expectation: PrepareUnmount should halt the idle watch,
+// resuming it only if the unmount has failed.
+func Test_FS_WatchIdle_PrepareUnmount_Success(t *testing.T) {
+	t.Parallel()
+	_, fsys := testFS(t, io.Discard)
+	fsys.Options.IdleTimeout = time.Minute
+	fsys.WatchIdle(func() {})
+
+	errs := make(chan error, 1)
+	fsys.PrepareUnmount(errs)
+	require.Zero(t, fsys.IdleTime())
+
+	errs <- errors.New("simulated unmount error")
+	close(errs)
+
+	require.Eventually(t, func() bool {
+		return !fsys.idleHalted.Load()
+	}, time.Second, 10*time.Millisecond)
+}
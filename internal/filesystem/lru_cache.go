@@ -4,26 +4,51 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"slices"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/jellydator/ttlcache/v3"
 )
 
+// CachedArchive describes an archive that is currently held open by the cache.
+// The reference count includes the reference of the cache itself (one).
+// A pinned archive is never evicted, so it has no expiry time (zero).
+// The last access is that of any lookup of the archive in the cache
+// (listings and lookups of its contents, as well as opened files).
+type CachedArchive struct {
+	Path       string    `json:"path"`
+	RefCount   int32     `json:"refCount"`
+	ExpiresAt  time.Time `json:"expiresAt"`
+	LastAccess time.Time `json:"lastAccess"`
+	Pinned     bool      `json:"pinned"`
+}
+
 // zipReaderCache implements a [ttlcache.Cache] for [zipReader] pointers.
 // It allows reusing opened ZIP files until TTL- or capacity-based eviction.
 // With [Options.FDCacheBypass] enabled, it facilitates direct FD pass-through.
+//
+// Archives matching any of the [Options.PinGlobs] are instead held open in
+// the pinned archives, which are not subject to any eviction, until they are
+// either unpinned again or the cache is purged (e.g. before the unmount).
 type zipReaderCache struct {
 	sync.Mutex
 
-	fsys  *FS
-	cache *ttlcache.Cache[string, *zipReader]
+	fsys   *FS
+	size   int // Capacity of the cache, fixed at construction.
+	cache  *ttlcache.Cache[string, *zipReader]
+	pinned map[string]*zipReader
 }
 
 // newZipReaderCache establishes a new [zipReaderCache] for a [FS].
 // Once done with the cache, ensure calling HaltAndPurge() and Destroy().
 func newZipReaderCache(fs *FS, size int, ttl time.Duration) *zipReaderCache {
-	c := &zipReaderCache{fsys: fs}
+	c := &zipReaderCache{
+		fsys:   fs,
+		size:   size,
+		pinned: make(map[string]*zipReader),
+	}
 
 	c.cache = ttlcache.New(
 		ttlcache.WithTTL[string, *zipReader](ttl),
@@ -45,117 +70,378 @@ func newZipReaderCache(fs *FS, size int, ttl time.Duration) *zipReaderCache {
 	return c
 }
 
+// cacheResult is how a [zipReader] was obtained from the [zipReaderCache].
+type cacheResult string
+
+const (
+	cacheHit    cacheResult = "hit"
+	cacheMiss   cacheResult = "miss"
+	cacheBypass cacheResult = "bypass"
+)
+
 // Archive returns a [zipReader] from cache or direct (when uncached/on bypass).
 // The [zipReader] needs to be Release()d after use, ensure that this is called.
 func (c *zipReaderCache) Archive(archive string) (*zipReader, error) {
+	zr, _, err := c.archive(archive)
+
+	return zr, err
+}
+
+// archive is Archive(), but also returns the [cacheResult] (e.g. for tracing).
+func (c *zipReaderCache) archive(archive string) (*zipReader, cacheResult, error) {
 	if c.fsys.Options.FDCacheBypass.Load() {
 		zr, err := newZipReader(c.fsys, archive)
 		if err != nil {
-			return nil, fmt.Errorf("ZIP failure: %w", err)
+			return nil, cacheBypass, fmt.Errorf("ZIP failure: %w", err)
 		}
 
 		// No need to Acquire() here, newZipReader() returns with a
 		// caller ref (which would be for the cache), which we transfer
 		// to our caller here instead (for lack of cache being enabled).
-		return zr, nil
+		return zr, cacheBypass, nil
 	}
 
 	c.Lock()
-	if item := c.cache.Get(archive); item != nil && item.Value() != nil {
+	if existing := c.pinned[archive]; existing != nil {
+		if !c.fsys.Options.StrictCache || !existing.Stale(archive) {
+			existing.Acquire() // for caller
+			existing.Touch()
+			c.fsys.Metrics.TotalFDCacheHits.Add(1)
+			c.Unlock()
+
+			return existing, cacheHit, nil
+		}
+
+		// The archive was replaced or modified, so the pinned reader is stale.
+		// It is released here and the re-opened reader is pinned again below.
+		delete(c.pinned, archive)
+		_ = existing.Release()
+		c.Unlock()
+
+		c.fsys.rbuf.Printf("%q: archive replaced or modified, re-opening\n", archive)
+	} else if item := c.cache.Get(archive); item != nil && item.Value() != nil {
 		existing := item.Value()
-		existing.Acquire() // for caller
-		c.fsys.Metrics.TotalFDCacheHits.Add(1)
+		if !c.fsys.Options.StrictCache || !existing.Stale(archive) {
+			existing.Acquire() // for caller
+			existing.Touch()
+			c.fsys.Metrics.TotalFDCacheHits.Add(1)
+			c.Unlock()
+
+			return existing, cacheHit, nil
+		}
 		c.Unlock()
 
-		return existing, nil
+		// The archive was replaced or modified, so the cached reader is stale.
+		// We must not lock here, as the eviction callback locks itself.
+		c.cache.Delete(archive)
+		c.fsys.rbuf.Printf("%q: archive replaced or modified, re-opening\n", archive)
+	} else {
+		c.Unlock()
 	}
-	c.Unlock()
 
 	// Outside of the lock, as it may block on the FD semaphore.
 	zr, err := newZipReader(c.fsys, archive)
 	if err != nil {
-		return nil, fmt.Errorf("ZIP failure: %w", err)
+		return nil, cacheMiss, fmt.Errorf("ZIP failure: %w", err)
 	}
 
 	c.Lock()
 	defer c.Unlock()
 
-	if item := c.cache.Get(archive); item != nil && item.Value() != nil {
+	if existing := c.lookup(archive); existing != nil {
 		// Another call beat us to inserting the item into the cache.
-		_ = zr.Release()         // release our ref (= closes our creation)
-		existing := item.Value() // use the existing cached reader instead
-		existing.Acquire()       // for caller
+		_ = zr.Release()   // release our ref (= closes our creation)
+		existing.Acquire() // for caller, using the existing reader instead
+		existing.Touch()
 		c.fsys.Metrics.TotalFDCacheHits.Add(1)
 
-		return existing, nil
+		return existing, cacheHit, nil
 	}
 
-	c.cache.Set(archive, zr, ttlcache.DefaultTTL)
+	if !c.pin(archive, zr) {
+		c.cache.Set(archive, zr, ttlcache.DefaultTTL)
+	}
 	zr.Acquire() // for caller
+	zr.Touch()
 	c.fsys.Metrics.TotalFDCacheMisses.Add(1)
 
-	return zr, nil
+	return zr, cacheMiss, nil
+}
+
+// lookup returns the pinned or cached [zipReader] for an archive (or nil).
+// The caller must hold the lock and Acquire() the reader for any further use.
+func (c *zipReaderCache) lookup(archive string) *zipReader {
+	if zr := c.pinned[archive]; zr != nil {
+		return zr
+	}
+
+	if item := c.cache.Get(archive); item != nil {
+		return item.Value()
+	}
+
+	return nil
+}
+
+// pinnable returns if an archive matches any of the [Options.PinGlobs].
+func (c *zipReaderCache) pinnable(archive string) bool {
+	v, ok := c.fsys.Options.PinGlobs.Match(c.fsys.archiveRelPath(archive))
+
+	return ok && v
+}
+
+// pinLimit returns the maximum amount of archives that can be pinned at once.
+// As pinned archives count toward [Options.FDLimit], the limit is what remains
+// of it besides [Options.FDCacheSize], keeping one descriptor for uncached use.
+// Both are those in effect (see [FS.FDLimit]), not any changed at runtime.
+func (c *zipReaderCache) pinLimit() int {
+	return cap(c.fsys.fdlimit) - c.size - 1
+}
+
+// pin holds the reference of a [zipReader] in the pinned archives (instead of
+// the TTL cache), if the archive is pinnable and the pin limit is not reached.
+// It returns false if the archive was not pinned. The caller must hold the lock.
+func (c *zipReaderCache) pin(archive string, zr *zipReader) bool {
+	if !c.pinnable(archive) {
+		return false
+	}
+
+	if len(c.pinned) >= c.pinLimit() {
+		c.fsys.rbuf.Printf("Warning: %q: not pinned, as the limit of %d pinned archives is reached\n",
+			archive, c.pinLimit())
+
+		return false
+	}
+
+	c.pinned[archive] = zr
+
+	return true
+}
+
+// Pin adds a glob to the [Options.PinGlobs] and pins the matching archives
+// which are already held open by the cache, so that they are not evicted.
+// Other matching archives are pinned once they are opened the next time.
+// It returns the amount of archives which were pinned by the call.
+func (c *zipReaderCache) Pin(glob string) (int, error) {
+	if c.pinLimit() < 1 {
+		return 0, fmt.Errorf("%w: no file descriptors left to pin (fd limit %d, fd cache size %d)",
+			errInvalidArgument, cap(c.fsys.fdlimit), c.size)
+	}
+
+	if err := c.fsys.Options.PinGlobs.Set(glob, true); err != nil {
+		return 0, err
+	}
+
+	var moved []string
+
+	c.Lock()
+	for path, item := range c.cache.Items() {
+		zr := item.Value()
+		if zr == nil || c.pinned[path] != nil || !c.pinnable(path) {
+			continue
+		}
+		if len(c.pinned) >= c.pinLimit() {
+			break
+		}
+		zr.Acquire() // for the pin
+		c.pinned[path] = zr
+		moved = append(moved, path)
+	}
+	c.Unlock()
+
+	// We must not lock here, as the eviction callback locks itself.
+	// The eviction releases the ref of the cache, leaving the pin's ref.
+	for _, path := range moved {
+		c.cache.Delete(path)
+	}
+
+	return len(moved), nil
+}
+
+// Unpin removes a glob from the [Options.PinGlobs] and releases the pinned
+// archives that no longer match any of the remaining globs, so that they are
+// closed once no longer in use (and regularly cached again on next access).
+// It returns the amount of released archives and if the glob was found.
+func (c *zipReaderCache) Unpin(glob string) (int, bool) {
+	if !c.fsys.Options.PinGlobs.Delete(glob) {
+		return 0, false
+	}
+
+	c.Lock()
+	defer c.Unlock()
+
+	n := 0
+	for path, zr := range c.pinned {
+		if c.pinnable(path) {
+			continue
+		}
+		delete(c.pinned, path)
+		_ = zr.Release()
+		n++
+	}
+
+	return n, true
+}
+
+// unpinAll releases all pinned archives, but keeps the [Options.PinGlobs],
+// so that the matching archives are pinned again once they are next opened.
+func (c *zipReaderCache) unpinAll() {
+	c.Lock()
+	defer c.Unlock()
+
+	for path, zr := range c.pinned {
+		delete(c.pinned, path)
+		_ = zr.Release()
+	}
 }
 
 // Entry returns a [zipFileReader] for a specific "path" within a ZIP "archive",
 // fetching from cache or direct (when uncached/on bypass) the [zipReader]. The
 // underlying [zipReader] is also returned and needs to be Release()d after use.
 func (c *zipReaderCache) Entry(archive, path string) (*zipReader, *zipFileReader, error) {
+	zr, fr, _, err := c.entry(archive, path)
+
+	return zr, fr, err
+}
+
+// entry is Entry(), but also returns the [cacheResult] (e.g. for tracing).
+func (c *zipReaderCache) entry(archive, path string) (*zipReader, *zipFileReader, cacheResult, error) {
 	m := newZipMetric(c.fsys, false)
 	defer m.Done()
 
 	if c.fsys.Options.FDCacheBypass.Load() {
 		zr, err := newZipReader(c.fsys, archive)
 		if err != nil {
-			return nil, nil, fmt.Errorf("ZIP failure: %w", err)
+			return nil, nil, cacheBypass, fmt.Errorf("ZIP failure: %w", err)
 		}
 
 		for _, f := range zr.File {
 			if f.Name == path {
-				fr, err := newZipFileReader(c.fsys, f)
+				fr, err := newZipFileReader(c.fsys, archive, f)
 				if err != nil {
-					return nil, nil, fmt.Errorf("ZIP file failure: %w", err)
+					_ = zr.Release() // release our ref
+
+					return nil, nil, cacheBypass, fmt.Errorf("ZIP file failure: %w", err)
 				}
 
 				// No need to Acquire() here, newZipReader() returns with a
 				// caller ref (which would be for the cache), which we transfer
 				// to our caller here instead (for lack of cache being enabled).
-				return zr, fr, nil
+				return zr, fr, cacheBypass, nil
 			}
 		}
 
-		return nil, nil, fmt.Errorf("%w: %s", os.ErrNotExist, path)
+		_ = zr.Release() // release our ref
+
+		return nil, nil, cacheBypass, fmt.Errorf("%w: %w: %s", ErrEntryNotFound, os.ErrNotExist, path)
 	}
 
 	// We do not need to lock here, as Archive() internally locks and
 	// returns [zipReader] with an Acquire()d ref for us (as the caller).
-	zr, err := c.Archive(archive)
+	zr, res, err := c.archive(archive)
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, res, err
 	}
 
 	for _, f := range zr.File {
 		if f.Name == path {
-			fr, err := newZipFileReader(c.fsys, f)
+			fr, err := newZipFileReader(c.fsys, archive, f)
 			if err != nil {
 				_ = zr.Release() // release our ref
 
-				return nil, nil, fmt.Errorf("ZIP file failure: %w", err)
+				return nil, nil, res, fmt.Errorf("ZIP file failure: %w", err)
 			}
 
 			// No need to Acquire() here, Archive() returns with a caller ref,
 			// which was for us (as caller) and transfer to our caller instead.
-			return zr, fr, nil
+			return zr, fr, res, nil
 		}
 	}
 
 	_ = zr.Release() // release our ref
 
-	return nil, nil, fmt.Errorf("%w: %s", os.ErrNotExist, path)
+	return nil, nil, res, fmt.Errorf("%w: %w: %s", ErrEntryNotFound, os.ErrNotExist, path)
+}
+
+// Len returns the amount of archives currently held open by the cache,
+// including the pinned archives (which do not count towards its capacity).
+func (c *zipReaderCache) Len() int {
+	c.Lock()
+	defer c.Unlock()
+
+	return c.cache.Len() + len(c.pinned)
+}
+
+// Snapshot returns a copy of all [CachedArchive] sorted by their path.
+func (c *zipReaderCache) Snapshot() []CachedArchive {
+	c.Lock()
+	defer c.Unlock()
+
+	items := c.cache.Items()
+
+	out := make([]CachedArchive, 0, len(items)+len(c.pinned))
+	for path, zr := range c.pinned {
+		out = append(out, CachedArchive{
+			Path:       path,
+			RefCount:   zr.refCount.Load(),
+			LastAccess: zr.LastAccess(),
+			Pinned:     true,
+		})
+	}
+	for path, item := range items {
+		zr := item.Value()
+		if zr == nil {
+			continue
+		}
+		out = append(out, CachedArchive{
+			Path:       path,
+			RefCount:   zr.refCount.Load(),
+			ExpiresAt:  item.ExpiresAt(),
+			LastAccess: zr.LastAccess(),
+		})
+	}
+
+	slices.SortFunc(out, func(a, b CachedArchive) int {
+		return strings.Compare(a.Path, b.Path)
+	})
+
+	return out
 }
 
-// HaltAndPurge prepares the file descriptor cache for unmount,
-// turning on FD cache bypass and deleting all items from the cache.
+// Evict removes a specific archive from the cache, so that it is re-opened
+// on the next access. Readers still in use are closed once all are released.
+// A pinned archive is also released, but pinned again once it is re-opened.
+// It returns if the archive was held open by the cache (and is now evicted).
+func (c *zipReaderCache) Evict(archive string) bool {
+	c.Lock()
+	zr, pinned := c.pinned[archive]
+	if pinned {
+		delete(c.pinned, archive)
+		_ = zr.Release()
+	}
+	c.Unlock()
+
+	// We must not lock here, as the eviction callback locks itself.
+	_, ok := c.cache.GetAndDelete(archive)
+
+	return ok || pinned
+}
+
+// Purge deletes all items (including the pinned) from the cache, so that the
+// archives are re-opened on their next access (e.g. after being updated). The
+// readers still in use are closed only once all are released (by refCount).
+// It returns the amount of archives that were held open by the cache.
+func (c *zipReaderCache) Purge() int {
+	n := c.Len()
+
+	// We must not lock here, as the eviction callback locks itself.
+	c.cache.DeleteAll()
+	c.unpinAll()
+
+	return n
+}
+
+// HaltAndPurge prepares the file descriptor cache for unmount, turning on
+// FD cache bypass and deleting all items (including the pinned) from the cache.
 // It takes an error channel for checking if the upstream unmounting
 // has failed, in which case it will restore the previous FD cache bypass
 // setting and resume the cache to its normal operation (as user-configured).
@@ -163,7 +449,7 @@ func (c *zipReaderCache) HaltAndPurge(errs <-chan error) {
 	v := c.fsys.Options.FDCacheBypass.Load()
 
 	c.fsys.Options.FDCacheBypass.Store(true)
-	c.cache.DeleteAll()
+	c.Purge()
 
 	go func() {
 		if err := <-errs; err != nil {
@@ -5,6 +5,7 @@ import (
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
 	"testing"
 	"time"
 
@@ -319,6 +320,36 @@ func Test_zipReaderCache_Entry_NotExist_Error(t *testing.T) {
 	}
 }
 
+// Expectation: zipReaderCache.Entry should release (and so close) the zipReader
+// when failing on cache disabled, for not leaking a file descriptor per failure.
+func Test_zipReaderCache_Entry_CacheBypass_Release_Error(t *testing.T) {
+	t.Parallel()
+	tmpDir, fsys := testFS(t, io.Discard)
+	tnow := time.Now()
+
+	fsys.Options.FDCacheBypass.Store(true)
+
+	content := []byte("test content")
+	zipPath := createTestZip(t, tmpDir, "test.zip", []struct {
+		Path    string
+		ModTime time.Time
+		Content []byte
+	}{
+		{Path: "test.txt", ModTime: tnow, Content: content},
+	})
+
+	cache := newZipReaderCache(fsys, 10, 5*time.Minute)
+	defer cache.cache.Stop()
+
+	zr, fr, err := cache.Entry(zipPath, "nonexistent.txt")
+	require.ErrorIs(t, err, os.ErrNotExist)
+	require.Nil(t, zr)
+	require.Nil(t, fr)
+
+	require.Zero(t, fsys.Metrics.OpenZips.Load())
+	require.Empty(t, fsys.fdlimit)
+}
+
 // Expectation: zipReaderCache.Entry should return error for non-existent archive.
 func Test_zipReaderCache_Entry_ArchiveNotExist_Error(t *testing.T) {
 	t.Parallel()
@@ -669,3 +700,449 @@ func Test_zipReaderCache_HaltAndPurge_RestoreOnError_Success(t *testing.T) {
 
 	require.False(t, fsys.Options.FDCacheBypass.Load())
 }
+
+// Expectation: zipReaderCache.Snapshot should list the cached archives with their references.
+func Test_zipReaderCache_Snapshot_Success(t *testing.T) {
+	t.Parallel()
+	tmpDir, fsys := testFS(t, io.Discard)
+	tnow := time.Now()
+
+	entries := []struct {
+		Path    string
+		ModTime time.Time
+		Content []byte
+	}{
+		{Path: "test.txt", ModTime: tnow, Content: []byte("test content")},
+	}
+	zipPathB := createTestZip(t, tmpDir, "b.zip", entries)
+	zipPathA := createTestZip(t, tmpDir, "a.zip", entries)
+
+	cache := newZipReaderCache(fsys, 10, 5*time.Minute)
+	defer cache.cache.Stop()
+
+	require.Empty(t, cache.Snapshot())
+
+	zrB, err := cache.Archive(zipPathB)
+	require.NoError(t, err)
+	zrA, err := cache.Archive(zipPathA)
+	require.NoError(t, err)
+	require.NoError(t, zrA.Release())
+
+	snap := cache.Snapshot()
+	require.Len(t, snap, 2)
+
+	require.Equal(t, zipPathA, snap[0].Path)
+	require.Equal(t, int32(1), snap[0].RefCount) // Cache ref
+	require.WithinDuration(t, time.Now().Add(5*time.Minute), snap[0].ExpiresAt, time.Minute)
+
+	require.Equal(t, zipPathB, snap[1].Path)
+	require.Equal(t, int32(2), snap[1].RefCount) // Cache ref + caller ref
+
+	require.NoError(t, zrB.Release())
+
+	cache.cache.DeleteAll()
+	require.Empty(t, cache.Snapshot())
+}
+
+// Expectation: The snapshot should report the last access of every archive,
+// which is updated on every hit of the archive (also of entries within it).
+func Test_zipReaderCache_Snapshot_LastAccess_Success(t *testing.T) {
+	t.Parallel()
+	tmpDir, fsys := testFS(t, io.Discard)
+	tnow := time.Now()
+
+	zipPath := createTestZip(t, tmpDir, "a.zip", []struct {
+		Path    string
+		ModTime time.Time
+		Content []byte
+	}{
+		{Path: "test.txt", ModTime: tnow, Content: []byte("test content")},
+	})
+
+	cache := newZipReaderCache(fsys, 10, 5*time.Minute)
+	defer cache.cache.Stop()
+
+	zr, err := cache.Archive(zipPath)
+	require.NoError(t, err)
+	require.NoError(t, zr.Release())
+
+	snap := cache.Snapshot()
+	require.Len(t, snap, 1)
+	require.WithinDuration(t, time.Now(), snap[0].LastAccess, time.Minute)
+
+	first := snap[0].LastAccess
+	time.Sleep(10 * time.Millisecond)
+
+	zr, fr, err := cache.Entry(zipPath, "test.txt")
+	require.NoError(t, err)
+	require.NoError(t, fr.Close())
+	require.NoError(t, zr.Release())
+
+	snap = cache.Snapshot()
+	require.Len(t, snap, 1)
+	require.True(t, snap[0].LastAccess.After(first))
+}
+
+// Expectation: zipReaderCache.archive should report the cache result for misses, hits and bypass.
+func Test_zipReaderCache_archive_CacheResult_Success(t *testing.T) {
+	t.Parallel()
+	tmpDir, fsys := testFS(t, io.Discard)
+
+	zipPath := createTestZip(t, tmpDir, "test.zip", []struct {
+		Path    string
+		ModTime time.Time
+		Content []byte
+	}{
+		{Path: "test.txt", ModTime: time.Now(), Content: []byte("test")},
+	})
+
+	zr, res, err := fsys.fdcache.archive(zipPath)
+	require.NoError(t, err)
+	require.Equal(t, cacheMiss, res)
+	require.NoError(t, zr.Release())
+
+	zr, fr, res, err := fsys.fdcache.entry(zipPath, "test.txt")
+	require.NoError(t, err)
+	require.Equal(t, cacheHit, res)
+	require.NoError(t, fr.Close())
+	require.NoError(t, zr.Release())
+
+	fsys.Options.FDCacheBypass.Store(true)
+
+	zr, res, err = fsys.fdcache.archive(zipPath)
+	require.NoError(t, err)
+	require.Equal(t, cacheBypass, res)
+	require.NoError(t, zr.Release())
+}
+
+// Expectation: zipReaderCache.Archive should re-open an archive replaced by an atomic rename with StrictCache,
+// even if the replacement matches the size and modification time, but keep the cached reader without it.
+func Test_zipReaderCache_Archive_RenameSwap_Success(t *testing.T) {
+	t.Parallel()
+
+	for _, strict := range []bool{true, false} {
+		t.Run("StrictCache="+strconv.FormatBool(strict), func(t *testing.T) {
+			t.Parallel()
+			tmpDir, fsys := testFS(t, io.Discard)
+			tnow := time.Now().Truncate(time.Second)
+
+			fsys.Options.StrictCache = strict
+
+			zipPath := createTestZip(t, tmpDir, "test.zip", []struct {
+				Path    string
+				ModTime time.Time
+				Content []byte
+			}{
+				{Path: "a.txt", ModTime: tnow, Content: []byte("old")},
+			})
+			swapPath := createTestZip(t, tmpDir, "swap.zip", []struct {
+				Path    string
+				ModTime time.Time
+				Content []byte
+			}{
+				{Path: "b.txt", ModTime: tnow, Content: []byte("new")},
+			})
+			require.NoError(t, os.Chtimes(zipPath, tnow, tnow))
+			require.NoError(t, os.Chtimes(swapPath, tnow, tnow))
+
+			zr, err := fsys.fdcache.Archive(zipPath)
+			require.NoError(t, err)
+			require.Equal(t, "a.txt", zr.File[0].Name)
+			require.NoError(t, zr.Release())
+
+			require.NoError(t, os.Rename(swapPath, zipPath))
+
+			zr, err = fsys.fdcache.Archive(zipPath)
+			require.NoError(t, err)
+			defer zr.Release() //nolint:errcheck
+
+			if strict {
+				require.Equal(t, "b.txt", zr.File[0].Name)
+				require.Equal(t, int64(2), fsys.Metrics.TotalFDCacheMisses.Load())
+			} else {
+				require.Equal(t, "a.txt", zr.File[0].Name)
+				require.Equal(t, int64(1), fsys.Metrics.TotalFDCacheHits.Load())
+			}
+		})
+	}
+}
+
+// Expectation: zipReaderCache should pin archives matching the pin globs,
+// which are then not evicted on TTL and reported as pinned in the snapshot.
+func Test_zipReaderCache_Archive_Pinned_Success(t *testing.T) {
+	t.Parallel()
+	tmpDir, fsys := testFS(t, io.Discard)
+	tnow := time.Now()
+
+	require.NoError(t, fsys.Options.PinGlobs.Set("hot/*.zip", true))
+	require.NoError(t, os.Mkdir(filepath.Join(tmpDir, "hot"), 0o755))
+
+	zipPath := createTestZip(t, filepath.Join(tmpDir, "hot"), "test.zip", []struct {
+		Path    string
+		ModTime time.Time
+		Content []byte
+	}{
+		{Path: "test.txt", ModTime: tnow, Content: []byte("test content")},
+	})
+
+	cache := newZipReaderCache(fsys, 10, 100*time.Millisecond)
+	defer cache.cache.Stop()
+
+	zr1, err := cache.Archive(zipPath)
+	require.NoError(t, err)
+	require.Equal(t, int32(2), zr1.refCount.Load()) // Pin ref + caller ref
+	require.NoError(t, zr1.Release())
+
+	require.Zero(t, cache.cache.Len())
+	require.Len(t, cache.pinned, 1)
+
+	time.Sleep(200 * time.Millisecond) // wait beyond TTL
+
+	zr2, err := cache.Archive(zipPath)
+	require.NoError(t, err)
+	require.Same(t, zr1, zr2)
+	require.NoError(t, zr2.Release())
+
+	require.Equal(t, int64(1), fsys.Metrics.TotalOpenedZips.Load())
+	require.Zero(t, fsys.Metrics.TotalClosedZips.Load())
+
+	snap := cache.Snapshot()
+	require.Len(t, snap, 1)
+	require.Equal(t, zipPath, snap[0].Path)
+	require.True(t, snap[0].Pinned)
+	require.True(t, snap[0].ExpiresAt.IsZero())
+
+	cache.unpinAll()
+	require.Empty(t, cache.pinned)
+	require.Equal(t, int64(1), fsys.Metrics.TotalClosedZips.Load())
+}
+
+// Expectation: zipReaderCache.Pin should move already cached archives matching
+// the glob into the pinned archives, without closing or re-opening them.
+func Test_zipReaderCache_Pin_Cached_Success(t *testing.T) {
+	t.Parallel()
+	tmpDir, fsys := testFS(t, io.Discard)
+	tnow := time.Now()
+
+	entries := []struct {
+		Path    string
+		ModTime time.Time
+		Content []byte
+	}{
+		{Path: "test.txt", ModTime: tnow, Content: []byte("test content")},
+	}
+	zipPathHot := createTestZip(t, tmpDir, "hot.zip", entries)
+	zipPathCold := createTestZip(t, tmpDir, "cold.zip", entries)
+
+	cache := newZipReaderCache(fsys, 10, 5*time.Minute)
+	defer cache.cache.Stop()
+
+	for _, path := range []string{zipPathHot, zipPathCold} {
+		zr, err := cache.Archive(path)
+		require.NoError(t, err)
+		require.NoError(t, zr.Release())
+	}
+	require.Equal(t, 2, cache.cache.Len())
+
+	n, err := cache.Pin("hot.zip")
+	require.NoError(t, err)
+	require.Equal(t, 1, n)
+
+	time.Sleep(100 * time.Millisecond) // wait for eviction callback
+
+	require.Equal(t, 1, cache.cache.Len())
+	require.Len(t, cache.pinned, 1)
+	require.Equal(t, int32(1), cache.pinned[zipPathHot].refCount.Load()) // Pin ref
+	require.Zero(t, fsys.Metrics.TotalClosedZips.Load())
+
+	zr, err := cache.Archive(zipPathHot)
+	require.NoError(t, err)
+	require.NoError(t, zr.Release())
+	require.Equal(t, int64(2), fsys.Metrics.TotalOpenedZips.Load())
+
+	cache.cache.DeleteAll()
+	cache.unpinAll()
+}
+
+// Expectation: zipReaderCache.Pin should reject invalid globs.
+func Test_zipReaderCache_Pin_InvalidGlob_Error(t *testing.T) {
+	t.Parallel()
+	_, fsys := testFS(t, io.Discard)
+
+	cache := newZipReaderCache(fsys, 10, 5*time.Minute)
+	defer cache.cache.Stop()
+
+	_, err := cache.Pin("[")
+	require.ErrorIs(t, err, errInvalidArgument)
+
+	_, err = cache.Pin("")
+	require.ErrorIs(t, err, errInvalidArgument)
+}
+
+// Expectation: zipReaderCache should not pin more archives than the pin
+// limit allows, caching any further matching archives as usual instead.
+func Test_zipReaderCache_Archive_PinLimit_Success(t *testing.T) {
+	t.Parallel()
+	opts := DefaultOptions()
+	opts.FDCacheSize = 10
+	opts.FDLimit = 12 // leaves room for one pinned archive
+
+	tmpDir, fsys := testFSOptions(t, io.Discard, opts)
+	tnow := time.Now()
+
+	require.NoError(t, fsys.Options.PinGlobs.Set("*.zip", true))
+
+	entries := []struct {
+		Path    string
+		ModTime time.Time
+		Content []byte
+	}{
+		{Path: "test.txt", ModTime: tnow, Content: []byte("test content")},
+	}
+	zipPath1 := createTestZip(t, tmpDir, "test1.zip", entries)
+	zipPath2 := createTestZip(t, tmpDir, "test2.zip", entries)
+
+	cache := newZipReaderCache(fsys, 10, 5*time.Minute)
+	defer cache.cache.Stop()
+
+	for _, path := range []string{zipPath1, zipPath2} {
+		zr, err := cache.Archive(path)
+		require.NoError(t, err)
+		require.NoError(t, zr.Release())
+	}
+
+	require.Len(t, cache.pinned, 1)
+	require.NotNil(t, cache.pinned[zipPath1])
+	require.Equal(t, 1, cache.cache.Len())
+
+	cache.cache.DeleteAll()
+	cache.unpinAll()
+}
+
+// Expectation: zipReaderCache.Unpin should release the pinned archives no
+// longer matching any glob, and report if the glob was pinned at all.
+func Test_zipReaderCache_Unpin_Success(t *testing.T) {
+	t.Parallel()
+	tmpDir, fsys := testFS(t, io.Discard)
+	tnow := time.Now()
+
+	zipPath := createTestZip(t, tmpDir, "test.zip", []struct {
+		Path    string
+		ModTime time.Time
+		Content []byte
+	}{
+		{Path: "test.txt", ModTime: tnow, Content: []byte("test content")},
+	})
+
+	cache := newZipReaderCache(fsys, 10, 5*time.Minute)
+	defer cache.cache.Stop()
+
+	_, err := cache.Pin("*.zip")
+	require.NoError(t, err)
+	_, err = cache.Pin("test.*")
+	require.NoError(t, err)
+
+	zr, err := cache.Archive(zipPath)
+	require.NoError(t, err)
+	require.NoError(t, zr.Release())
+	require.Len(t, cache.pinned, 1)
+
+	n, ok := cache.Unpin("*.zip")
+	require.True(t, ok)
+	require.Zero(t, n) // still matching "test.*"
+
+	n, ok = cache.Unpin("test.*")
+	require.True(t, ok)
+	require.Equal(t, 1, n)
+
+	require.Empty(t, cache.pinned)
+	require.Equal(t, int64(1), fsys.Metrics.TotalClosedZips.Load())
+
+	_, ok = cache.Unpin("test.*")
+	require.False(t, ok)
+}
+
+// Expectation: Purge should delete all archives (including the pinned) from
+// the cache, while keeping any reader still in use open until it is released.
+func Test_zipReaderCache_Purge_Success(t *testing.T) {
+	t.Parallel()
+	tmpDir, fsys := testFS(t, io.Discard)
+	tnow := time.Now()
+
+	fsys.Options.FDCacheBypass.Store(false)
+	require.NoError(t, fsys.Options.PinGlobs.Set("test2.zip", true))
+
+	zipPath1 := createTestZip(t, tmpDir, "test1.zip", []struct {
+		Path    string
+		ModTime time.Time
+		Content []byte
+	}{
+		{Path: "test.txt", ModTime: tnow, Content: []byte("test")},
+	})
+
+	zipPath2 := createTestZip(t, tmpDir, "test2.zip", []struct {
+		Path    string
+		ModTime time.Time
+		Content []byte
+	}{
+		{Path: "test.txt", ModTime: tnow, Content: []byte("test")},
+	})
+
+	cache := newZipReaderCache(fsys, 10, 5*time.Minute)
+	defer cache.cache.Stop()
+
+	zr1, err := cache.Archive(zipPath1)
+	require.NoError(t, err)
+
+	zr2, err := cache.Archive(zipPath2)
+	require.NoError(t, err)
+	require.NoError(t, zr2.Release())
+
+	require.Equal(t, 2, cache.Len())
+	require.Equal(t, 2, cache.Purge())
+	require.Zero(t, cache.Len())
+	require.False(t, fsys.Options.FDCacheBypass.Load())
+
+	// The eviction callbacks (releasing the cache's refs) run asynchronously.
+	require.Eventually(t, func() bool {
+		return zr1.refCount.Load() == 1
+	}, time.Second, time.Millisecond)
+	require.NoError(t, zr1.Release())
+
+	zr2, err = cache.Archive(zipPath2)
+	require.NoError(t, err)
+	require.NoError(t, zr2.Release())
+	require.Equal(t, int64(3), fsys.Metrics.TotalOpenedZips.Load())
+	require.Equal(t, 1, cache.Len())
+}
+
+// Expectation: FDLimit and FDCacheSize changed at runtime should be ignored
+// (with a warning logged once), keeping those in effect for pinning.
+func Test_FS_FDLimit_Static_Success(t *testing.T) {
+	t.Parallel()
+
+	opts := DefaultOptions()
+	opts.FDCacheSize = 10
+	opts.FDLimit = 12
+
+	_, fsys := testFSOptions(t, io.Discard, opts)
+
+	require.Equal(t, 12, fsys.FDLimit())
+	require.Equal(t, 10, fsys.FDCacheSize())
+	require.Equal(t, 1, fsys.fdcache.pinLimit())
+
+	fsys.Options.FDCacheSize = 100
+	fsys.Options.FDLimit = 5
+
+	require.Equal(t, 12, fsys.FDLimit())
+	require.Equal(t, 10, fsys.FDCacheSize())
+	require.Equal(t, 1, fsys.fdcache.pinLimit())
+
+	var warnings int
+	for _, line := range fsys.rbuf.Lines() {
+		if strings.Contains(line, "cannot be changed at runtime") {
+			warnings++
+		}
+	}
+	require.Equal(t, 1, warnings)
+}
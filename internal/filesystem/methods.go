@@ -0,0 +1,85 @@
+package filesystem
+
+import (
+	"compress/bzip2"
+	"fmt"
+	"io"
+
+	"github.com/klauspost/compress/flate"
+	"github.com/klauspost/compress/zip"
+)
+
+// methodBzip2 is the ZIP compression method of bzip2, which is not natively
+// supported by the ZIP reader, but registered (see [registerDecompressors])
+// with the decompressor of the standard library (being read-only, as needed).
+const methodBzip2 uint16 = 12
+
+// methodNames are the names of the known ZIP compression methods (APPNOTE),
+// for naming the method of a file within an archive that is not supported.
+var methodNames = map[uint16]string{
+	zip.Store:   "store",
+	zip.Deflate: "deflate",
+	9:           "deflate64",
+	methodBzip2: "bzip2",
+	14:          "lzma",
+	93:          "zstd",
+	95:          "xz",
+	96:          "jpeg",
+	97:          "wavpack",
+	98:          "ppmd",
+	99:          "aes",
+}
+
+// registerDecompressors registers the decompressors of the compression methods
+// which are not natively supported by the ZIP reader (see [supportedMethod]).
+func registerDecompressors(r *zip.Reader) *zip.Reader {
+	r.RegisterDecompressor(methodBzip2, newBzip2Reader)
+
+	return r
+}
+
+// newBzip2Reader is the [zip.Decompressor] of [methodBzip2].
+func newBzip2Reader(r io.Reader) io.ReadCloser {
+	return io.NopCloser(bzip2.NewReader(r))
+}
+
+// decompressor returns the [zip.Decompressor] of a supported compression method
+// (see [supportedMethod]), for files which are read raw (e.g. to be decrypted).
+func decompressor(method uint16) zip.Decompressor {
+	switch method {
+	case zip.Deflate:
+		return flate.NewReader
+	case methodBzip2:
+		return newBzip2Reader
+	default:
+		return io.NopCloser
+	}
+}
+
+// methodName returns the name of a ZIP compression method (with its number).
+func methodName(method uint16) string {
+	if name, ok := methodNames[method]; ok {
+		return fmt.Sprintf("%s (%d)", name, method)
+	}
+
+	return fmt.Sprintf("unknown (%d)", method)
+}
+
+// supportedMethod returns if files of a ZIP compression method can be read.
+func supportedMethod(method uint16) bool {
+	return method == zip.Store || method == zip.Deflate || method == methodBzip2
+}
+
+// checkMethod returns an error retaining [ErrUnsupportedMethod] for a file
+// within an archive of a compression method that cannot be read, so that it
+// fails cleanly upfront (instead of on reading), logging and counting it.
+func (fsys *FS) checkMethod(archive, path string, method uint16) error {
+	if supportedMethod(method) {
+		return nil
+	}
+
+	fsys.Metrics.TotalUnsupportedMethod.Add(1)
+	fsys.rbuf.Printf("Error: %q->%q: unsupported compression method %s\n", archive, path, methodName(method))
+
+	return fmt.Errorf("%w: %s", ErrUnsupportedMethod, methodName(method))
+}
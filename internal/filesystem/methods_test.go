@@ -0,0 +1,126 @@
+package filesystem
+
+import (
+	"bytes"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"testing"
+	"time"
+
+	"bazil.org/fuse"
+	"bazil.org/fuse/fs"
+	"github.com/klauspost/compress/zip"
+	"github.com/stretchr/testify/require"
+)
+
+// testBzip2Content is the uncompressed content of [testBzip2Data].
+var testBzip2Content = []byte("hello bzip2 world\n")
+
+// testBzip2Data is the bzip2 compressed [testBzip2Content].
+var testBzip2Data = []byte{
+	0x42, 0x5a, 0x68, 0x39, 0x31, 0x41, 0x59, 0x26, 0x53, 0x59, 0xa4, 0x53, 0x4a, 0x50, 0x00,
+	0x00, 0x03, 0xd9, 0x80, 0x00, 0x10, 0x40, 0x00, 0x10, 0x00, 0x16, 0x64, 0xd0, 0x90, 0x20,
+	0x00, 0x22, 0x98, 0x13, 0x68, 0x6a, 0x10, 0x00, 0x01, 0xc3, 0xdc, 0x58, 0xf1, 0xdc, 0x8e,
+	0x13, 0x80, 0xfc, 0x5d, 0xc9, 0x14, 0xe1, 0x42, 0x42, 0x91, 0x4d, 0x29, 0x40,
+}
+
+// createTestZipRaw creates a ZIP archive with a single file of the given
+// compression method, being already compressed data (of the content).
+func createTestZipRaw(t *testing.T, tmpDir string, tmpName string, name string, method uint16, data []byte, content []byte) string {
+	t.Helper()
+
+	tmpFile, err := os.Create(filepath.Join(tmpDir, tmpName))
+	require.NoError(t, err)
+	defer tmpFile.Close()
+
+	zw := zip.NewWriter(tmpFile)
+
+	w, err := zw.CreateRaw(&zip.FileHeader{
+		Name:               name,
+		Method:             method,
+		Modified:           time.Now(),
+		CRC32:              crc32.ChecksumIEEE(content),
+		CompressedSize64:   uint64(len(data)),
+		UncompressedSize64: uint64(len(content)),
+	})
+	require.NoError(t, err)
+
+	_, err = w.Write(data)
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+
+	return tmpFile.Name()
+}
+
+// Expectation: The known compression methods should be named, others as unknown.
+func Test_methodName_Success(t *testing.T) {
+	t.Parallel()
+
+	require.Equal(t, "deflate (8)", methodName(zip.Deflate))
+	require.Equal(t, "lzma (14)", methodName(14))
+	require.Equal(t, "unknown (1234)", methodName(1234))
+}
+
+// Expectation: Files compressed with bzip2 should be extracted.
+func Test_FS_ExtractEntry_Bzip2_Success(t *testing.T) {
+	t.Parallel()
+	tmpDir, fsys := testFS(t, io.Discard)
+
+	zipPath := createTestZipRaw(t, tmpDir, "test.zip", "file.txt", methodBzip2, testBzip2Data, testBzip2Content)
+
+	var buf bytes.Buffer
+	_, err := fsys.ExtractEntry(zipPath, "file.txt", 0, -1, &buf)
+	require.NoError(t, err)
+	require.Equal(t, testBzip2Content, buf.Bytes())
+	require.Zero(t, fsys.Metrics.TotalUnsupportedMethod.Load())
+}
+
+// Expectation: Files of an unsupported compression method should be listed,
+// but fail to open with EOPNOTSUPP (for both in-memory and streamed files).
+func Test_zipFileNode_UnsupportedMethod_Error(t *testing.T) {
+	t.Parallel()
+
+	for _, stream := range []bool{false, true} {
+		t.Run("Stream="+strconv.FormatBool(stream), func(t *testing.T) {
+			t.Parallel()
+			tmpDir, fsys := testFS(t, io.Discard)
+
+			content := []byte("lzma content")
+			zipPath := createTestZipRaw(t, tmpDir, "test.zip", "file.txt", 14, []byte("not really lzma"), content)
+
+			if stream {
+				fsys.Options.StreamingThreshold.Store(0)
+			}
+
+			node := &zipDirNode{
+				fsys:  fsys,
+				inode: fs.GenerateDynamicInode(1, "test"),
+				path:  zipPath,
+				mtime: time.Now(),
+			}
+
+			ent, err := node.ReadDirAll(t.Context())
+			require.NoError(t, err)
+			require.Len(t, ent, 1)
+			require.Equal(t, "file.txt", ent[0].Name)
+
+			fn, err := node.Lookup(t.Context(), "file.txt")
+			require.NoError(t, err)
+
+			opener, ok := fn.(fs.NodeOpener)
+			require.True(t, ok)
+
+			_, err = opener.Open(t.Context(), &fuse.OpenRequest{Flags: fuse.OpenReadOnly}, &fuse.OpenResponse{})
+			require.ErrorIs(t, err, ErrUnsupportedMethod)
+			require.Equal(t, fuse.ToErrno(syscall.EOPNOTSUPP), toErrno(err))
+			require.Equal(t, int64(1), fsys.Metrics.TotalUnsupportedMethod.Load())
+
+			_, err = fsys.ExtractEntry(zipPath, "file.txt", 0, -1, io.Discard)
+			require.ErrorIs(t, err, ErrUnsupportedMethod)
+		})
+	}
+}
@@ -0,0 +1,34 @@
+package filesystem
+
+import (
+	"path"
+	"strings"
+
+	"bazil.org/fuse/fs"
+)
+
+// hookNode returns a looked up node as decorated by [Options.NodeHook],
+// or the node itself (unchanged) if no such hook is set (being the default).
+func (fsys *FS) hookNode(path string, node fs.Node) fs.Node {
+	if fsys.Options.NodeHook == nil {
+		return node
+	}
+
+	return fsys.Options.NodeHook(path, node)
+}
+
+// nodePath returns the path (as presented) of a named child of the directory.
+func (d *realDirNode) nodePath(name string) string {
+	return path.Join(d.fsys.archiveRelPath(d.path), name)
+}
+
+// nodePath returns the path (as presented) of a named child of the directory,
+// with the archive's directory being named as the archive without extension.
+func (z *zipDirNode) nodePath(name string) string {
+	dir := strings.TrimSuffix(z.fsys.archiveRelPath(z.path), ".zip")
+	if z.raw {
+		dir = path.Join(dir, rawDir)
+	}
+
+	return path.Join(dir, z.prefix, name)
+}
@@ -0,0 +1,91 @@
+package filesystem
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"bazil.org/fuse"
+	"bazil.org/fuse/fs"
+	"github.com/stretchr/testify/require"
+)
+
+// testHookedNode is a decorating [fs.Node] of [Options.NodeHook],
+// adding an extended attribute (while preserving the wrapped Attr).
+type testHookedNode struct {
+	fs.Node
+
+	path string
+}
+
+func (n *testHookedNode) Getxattr(_ context.Context, req *fuse.GetxattrRequest, resp *fuse.GetxattrResponse) error {
+	if req.Name != "user.test.path" {
+		return fuse.ErrNoXattr
+	}
+	resp.Xattr = []byte(n.path)
+
+	return nil
+}
+
+// Expectation: NodeHook should be called with every looked up node and its
+// path as presented, with the returned node being served instead.
+func Test_FS_NodeHook_Success(t *testing.T) {
+	t.Parallel()
+	tmpDir, fsys := testFS(t, io.Discard)
+
+	var mu sync.Mutex
+	var paths []string
+
+	fsys.Options.NodeHook = func(path string, node fs.Node) fs.Node {
+		mu.Lock()
+		paths = append(paths, path)
+		mu.Unlock()
+
+		return &testHookedNode{Node: node, path: path}
+	}
+
+	require.NoError(t, os.Mkdir(filepath.Join(tmpDir, "dir"), dirBasePerm))
+	createTestZip(t, filepath.Join(tmpDir, "dir"), "test.zip", []struct {
+		Path    string
+		ModTime time.Time
+		Content []byte
+	}{
+		{Path: "sub/file.txt", ModTime: time.Now(), Content: []byte("content")},
+	})
+
+	root := &realDirNode{fsys: fsys, inode: 1, path: tmpDir, mtime: time.Now()}
+
+	dn, err := root.Lookup(t.Context(), "dir")
+	require.NoError(t, err)
+
+	zn, err := dn.(*testHookedNode).Node.(*realDirNode).Lookup(t.Context(), "test")
+	require.NoError(t, err)
+
+	sn, err := zn.(*testHookedNode).Node.(*zipDirNode).Lookup(t.Context(), "sub")
+	require.NoError(t, err)
+
+	fn, err := sn.(*testHookedNode).Node.(*zipDirNode).Lookup(t.Context(), "file.txt")
+	require.NoError(t, err)
+
+	hooked, ok := fn.(*testHookedNode)
+	require.True(t, ok)
+
+	var attr fuse.Attr
+	require.NoError(t, hooked.Attr(t.Context(), &attr))
+	require.Equal(t, uint64(7), attr.Size)
+
+	resp := &fuse.GetxattrResponse{}
+	require.NoError(t, hooked.Getxattr(t.Context(), &fuse.GetxattrRequest{Name: "user.test.path"}, resp))
+	require.Equal(t, "dir/test/sub/file.txt", string(resp.Xattr))
+
+	require.Equal(t, []string{"dir", "dir/test", "dir/test/sub", "dir/test/sub/file.txt"}, paths)
+
+	_, err = root.Lookup(t.Context(), "notexist")
+	require.ErrorIs(t, err, fuse.ToErrno(syscall.ENOENT))
+	require.Len(t, paths, 4)
+}
@@ -1,6 +1,7 @@
 package filesystem
 
 import (
+	"cmp"
 	"context"
 	"os"
 	"path/filepath"
@@ -23,6 +24,8 @@ var (
 // It is presented also as a regular directory within our filesystem, however
 // only contained regular directories and ZIP archives are processed further.
 type realDirNode struct {
+	readOnlyNode // Rejects all modifications (with EROFS).
+
 	fsys  *FS       // Pointer to our filesystem.
 	inode uint64    // Inode within our filesystem.
 	path  string    // Path of the underlying regular directory.
@@ -33,16 +36,25 @@ func (d *realDirNode) Attr(_ context.Context, a *fuse.Attr) error {
 	a.Mode = os.ModeDir | dirBasePerm
 	a.Inode = d.inode
 
-	a.Atime = d.mtime
-	a.Ctime = d.mtime
-	a.Mtime = d.mtime
+	mtime := d.fsys.attrTime(d.mtime)
+
+	a.Atime = mtime
+	a.Ctime = mtime
+	a.Mtime = mtime
 
 	return nil
 }
 
-func (d *realDirNode) ReadDirAll(_ context.Context) ([]fuse.Dirent, error) {
+func (d *realDirNode) ReadDirAll(ctx context.Context) ([]fuse.Dirent, error) {
+	defer d.fsys.active()()
+	d.fsys.Metrics.TotalReadDirs.Add(1)
+
+	if err := d.fsys.checkAccess(ctx); err != nil {
+		return nil, err
+	}
+
 	seen := make(map[string]bool)
-	resp := make([]fuse.Dirent, 0)
+	ents := make([]realEntry, 0)
 
 	entries, err := os.ReadDir(d.path)
 	if err != nil {
@@ -56,10 +68,14 @@ func (d *realDirNode) ReadDirAll(_ context.Context) ([]fuse.Dirent, error) {
 
 	for _, e := range entries {
 		switch {
+		case d.fsys.Options.HideHidden && strings.HasPrefix(e.Name(), "."):
+			continue
 		case e.IsDir():
 			dirs = append(dirs, e)
-		case strings.HasSuffix(e.Name(), ".zip"):
+		case strings.HasSuffix(e.Name(), ".zip") && isRegularFile(d.path, e):
 			zips = append(zips, e)
+		case d.fsys.Options.DetectSFX && d.isSFXArchive(e):
+			zips = append(zips, e) // presented under its full name
 		default:
 			continue
 		}
@@ -73,36 +89,67 @@ func (d *realDirNode) ReadDirAll(_ context.Context) ([]fuse.Dirent, error) {
 		}
 		seen[name] = true
 
-		resp = append(resp, fuse.Dirent{
-			Name:  name,
-			Type:  fuse.DT_Dir,
-			Inode: fs.GenerateDynamicInode(d.inode, name),
-		})
+		ents = append(ents, d.realEntry(de, name, true))
 	}
 
 	for _, ze := range zips {
 		name := strings.TrimSuffix(ze.Name(), ".zip")
+		typ := fuse.DT_Dir
+
+		// Archives without any entries are presented per [Options.EmptyArchiveAs].
+		if d.fsys.Options.EmptyArchiveAs != EmptyArchiveDir {
+			zipPath := filepath.Join(d.path, ze.Name())
+			if info, err := os.Stat(zipPath); err == nil && d.fsys.emptyArchive(zipPath, info) {
+				if d.fsys.Options.EmptyArchiveAs == EmptyArchiveHidden {
+					continue
+				}
+				name, typ = ze.Name(), fuse.DT_File
+			}
+		}
 
 		if seen[name] {
 			continue
 		}
 		seen[name] = true
 
-		resp = append(resp, fuse.Dirent{
-			Name:  name,
-			Type:  fuse.DT_Dir,
-			Inode: fs.GenerateDynamicInode(d.inode, name),
-		})
+		re := d.realEntry(ze, name, false)
+		re.dirent.Type = typ
+
+		ents = append(ents, re)
 	}
 
-	slices.SortFunc(resp, func(a, b fuse.Dirent) int {
-		return strings.Compare(a.Name, b.Name)
-	})
+	slices.SortFunc(ents, d.fsys.compareRealEntries)
+
+	resp := make([]fuse.Dirent, 0, len(ents))
+	for _, e := range ents {
+		resp = append(resp, e.dirent)
+	}
 
 	return resp, nil
 }
 
-func (d *realDirNode) Lookup(_ context.Context, name string) (fs.Node, error) {
+func (d *realDirNode) Lookup(ctx context.Context, name string) (fs.Node, error) {
+	defer d.fsys.active()()
+	d.fsys.Metrics.TotalLookups.Add(1)
+
+	if err := d.fsys.checkAccess(ctx); err != nil {
+		return nil, err
+	}
+
+	node, err := d.lookup(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return d.fsys.hookNode(d.nodePath(name), node), nil
+}
+
+// lookup is Lookup(), returning the node of a directory or an archive.
+func (d *realDirNode) lookup(name string) (fs.Node, error) {
+	if d.fsys.Options.HideHidden && strings.HasPrefix(name, ".") {
+		return nil, toFuseErr(syscall.ENOENT)
+	}
+
 	path := filepath.Join(d.path, name)
 
 	if info, err := os.Stat(path); err == nil && info.IsDir() {
@@ -114,8 +161,24 @@ func (d *realDirNode) Lookup(_ context.Context, name string) (fs.Node, error) {
 		}, nil
 	}
 
+	if strings.HasSuffix(name, ".zip") && d.fsys.Options.EmptyArchiveAs == EmptyArchiveFile {
+		if info, err := os.Stat(path); err == nil && info.Mode().IsRegular() && d.fsys.emptyArchive(path, info) {
+			return &realFileNode{
+				fsys:  d.fsys,
+				path:  path,
+				size:  info.Size(),
+				mtime: info.ModTime(),
+				inode: fs.GenerateDynamicInode(d.inode, name),
+			}, nil
+		}
+	}
+
 	zipPath := path + ".zip"
-	if info, err := os.Stat(zipPath); err == nil && !info.IsDir() {
+	if info, err := os.Stat(zipPath); err == nil && info.Mode().IsRegular() {
+		if d.fsys.emptyArchive(zipPath, info) {
+			return nil, toFuseErr(syscall.ENOENT)
+		}
+
 		return &zipDirNode{
 			fsys:  d.fsys,
 			path:  zipPath,
@@ -124,5 +187,126 @@ func (d *realDirNode) Lookup(_ context.Context, name string) (fs.Node, error) {
 		}, nil
 	}
 
+	if d.fsys.Options.DetectSFX {
+		if info, err := os.Stat(path); err == nil && d.fsys.sfxArchive(path, info) {
+			return d.lookupSFX(path, name, info)
+		}
+	}
+
 	return nil, toFuseErr(syscall.ENOENT)
 }
+
+// lookupSFX returns the node of a self-extracting archive (see [Options.DetectSFX]),
+// being presented as an archive would be, but under its full name (extension).
+func (d *realDirNode) lookupSFX(path string, name string, info os.FileInfo) (fs.Node, error) {
+	if d.fsys.emptyArchive(path, info) {
+		if d.fsys.Options.EmptyArchiveAs != EmptyArchiveFile {
+			return nil, toFuseErr(syscall.ENOENT)
+		}
+
+		return &realFileNode{
+			fsys:  d.fsys,
+			path:  path,
+			size:  info.Size(),
+			mtime: info.ModTime(),
+			inode: fs.GenerateDynamicInode(d.inode, name),
+		}, nil
+	}
+
+	return &zipDirNode{
+		fsys:  d.fsys,
+		path:  path,
+		mtime: info.ModTime(),
+		inode: fs.GenerateDynamicInode(d.inode, name),
+	}, nil
+}
+
+// isSFXArchive checks if a [os.DirEntry] is a self-extracting archive (see
+// [Options.DetectSFX]), being a regular file (or a link to one) with an archive.
+func (d *realDirNode) isSFXArchive(e os.DirEntry) bool {
+	if !isRegularFile(d.path, e) {
+		return false
+	}
+
+	info, err := os.Stat(filepath.Join(d.path, e.Name()))
+
+	return err == nil && d.fsys.sfxArchive(filepath.Join(d.path, e.Name()), info)
+}
+
+// realEntry is a listed entry of a [realDirNode], along with what it is
+// sorted by (see [Options.RealSort]), being either a directory or an archive.
+type realEntry struct {
+	dirent fuse.Dirent
+	isDir  bool
+	mtime  time.Time
+	size   int64
+}
+
+// realEntry returns the [realEntry] for a directory or archive being listed.
+// The entry is only stat'ed if needed for sorting, following any symlinks.
+func (d *realDirNode) realEntry(e os.DirEntry, name string, isDir bool) realEntry {
+	re := realEntry{
+		dirent: fuse.Dirent{
+			Name:  name,
+			Type:  fuse.DT_Dir,
+			Inode: fs.GenerateDynamicInode(d.inode, name),
+		},
+		isDir: isDir,
+	}
+
+	if d.fsys.Options.RealSort == RealSortName {
+		return re
+	}
+
+	if info, err := os.Stat(filepath.Join(d.path, e.Name())); err == nil {
+		re.mtime = info.ModTime()
+		if !isDir {
+			re.size = info.Size()
+		}
+	}
+
+	return re
+}
+
+// compareRealEntries orders the entries of a [realDirNode] as configured by
+// [Options.RealSort] and [Options.RealSortDirsFirst], with ties by their name.
+func (fsys *FS) compareRealEntries(a, b realEntry) int {
+	if fsys.Options.RealSortDirsFirst && a.isDir != b.isDir {
+		if a.isDir {
+			return -1
+		}
+
+		return 1
+	}
+
+	switch fsys.Options.RealSort {
+	case RealSortMtime:
+		if c := b.mtime.Compare(a.mtime); c != 0 {
+			return c
+		}
+	case RealSortSize:
+		if c := cmp.Compare(b.size, a.size); c != 0 {
+			return c
+		}
+	case RealSortName:
+	}
+
+	return strings.Compare(a.dirent.Name, b.dirent.Name)
+}
+
+// isRegularFile checks if a [os.DirEntry] is a regular file (or a link to one).
+// A directory (or anything else) with an archive extension is never an archive,
+// directories are always presented as such regardless of their respective name.
+func isRegularFile(dir string, e os.DirEntry) bool {
+	if e.Type().IsRegular() {
+		return true
+	}
+
+	if e.Type()&os.ModeSymlink == 0 {
+		return false
+	}
+
+	info, err := os.Stat(filepath.Join(dir, e.Name()))
+
+	return err == nil && info.Mode().IsRegular()
+}
@@ -4,6 +4,7 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"strconv"
 	"syscall"
 	"testing"
 	"time"
@@ -85,6 +86,115 @@ func Test_realDirNode_ReadDirAll_Success(t *testing.T) {
 	require.Equal(t, fuse.DT_Dir, ent[3].Type)
 }
 
+// Expectation: Hidden directories and archives should neither be listed nor be
+// resolvable by lookup with HideHidden, while they are presented as usual without.
+func Test_realDirNode_ReadDirAll_HideHidden_Success(t *testing.T) {
+	t.Parallel()
+	tmpDir, fsys := testFS(t, io.Discard)
+
+	for _, dir := range []string{"dir", ".snapshots", ".Trash"} {
+		require.NoError(t, os.Mkdir(filepath.Join(tmpDir, dir), dirBasePerm))
+	}
+	for _, file := range []string{"visible.zip", ".hidden.zip", ".profile"} {
+		_, err := os.Create(filepath.Join(tmpDir, file))
+		require.NoError(t, err)
+	}
+
+	node := &realDirNode{
+		fsys:  fsys,
+		inode: 1,
+		path:  tmpDir,
+		mtime: time.Now(),
+	}
+
+	names := func() []string {
+		ent, err := node.ReadDirAll(t.Context())
+		require.NoError(t, err)
+
+		names := make([]string, 0, len(ent))
+		for _, e := range ent {
+			names = append(names, e.Name)
+		}
+
+		return names
+	}
+
+	require.ElementsMatch(t, []string{"dir", ".snapshots", ".Trash", "visible", ".hidden"}, names())
+
+	fsys.Options.HideHidden = true
+	require.ElementsMatch(t, []string{"dir", "visible"}, names())
+
+	for _, name := range []string{"dir", "visible"} {
+		_, err := node.Lookup(t.Context(), name)
+		require.NoError(t, err, name)
+	}
+	for _, name := range []string{".snapshots", ".Trash", ".hidden", ".hidden.zip"} {
+		_, err := node.Lookup(t.Context(), name)
+		require.ErrorIs(t, err, fuse.ToErrno(syscall.ENOENT), name)
+	}
+
+	_, err := fsys.archiveWalkPath(filepath.Join(tmpDir, ".hidden.zip"))
+	require.ErrorIs(t, err, ErrNotArchive)
+
+	_, err = fsys.archiveWalkPath(filepath.Join(tmpDir, "visible.zip"))
+	require.NoError(t, err)
+}
+
+// Expectation: The entries should be ordered by the configured sort (and grouping).
+func Test_realDirNode_ReadDirAll_RealSort_Success(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		order     RealSortOrder
+		dirsFirst bool
+		want      []string
+	}{
+		{name: "Name", order: RealSortName, want: []string{"a", "b", "c", "d"}},
+		{name: "NameDirsFirst", order: RealSortName, dirsFirst: true, want: []string{"b", "d", "a", "c"}},
+		{name: "Mtime", order: RealSortMtime, want: []string{"c", "b", "a", "d"}},
+		{name: "MtimeDirsFirst", order: RealSortMtime, dirsFirst: true, want: []string{"b", "d", "c", "a"}},
+		{name: "Size", order: RealSortSize, want: []string{"a", "c", "b", "d"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			tmpDir, fsys := testFS(t, io.Discard)
+			tnow := time.Now()
+
+			fsys.Options.RealSort = tt.order
+			fsys.Options.RealSortDirsFirst = tt.dirsFirst
+
+			require.NoError(t, os.WriteFile(filepath.Join(tmpDir, "a.zip"), make([]byte, 200), 0o600))
+			require.NoError(t, os.WriteFile(filepath.Join(tmpDir, "c.zip"), make([]byte, 100), 0o600))
+			require.NoError(t, os.Mkdir(filepath.Join(tmpDir, "b"), 0o700))
+			require.NoError(t, os.Mkdir(filepath.Join(tmpDir, "d"), 0o700))
+
+			for name, age := range map[string]time.Duration{"c.zip": 0, "b": time.Hour, "a.zip": 2 * time.Hour, "d": 3 * time.Hour} {
+				mtime := tnow.Add(-age)
+				require.NoError(t, os.Chtimes(filepath.Join(tmpDir, name), mtime, mtime))
+			}
+
+			node := &realDirNode{
+				fsys:  fsys,
+				inode: 1,
+				path:  tmpDir,
+				mtime: tnow,
+			}
+
+			ent, err := node.ReadDirAll(t.Context())
+			require.NoError(t, err)
+
+			names := make([]string, 0, len(ent))
+			for _, e := range ent {
+				names = append(names, e.Name)
+			}
+			require.Equal(t, tt.want, names)
+		})
+	}
+}
+
 // Expectation: ENOENT should be returned upon accessing an invalid directory.
 func Test_realDirNode_ReadDirAll_Error(t *testing.T) {
 	t.Parallel()
@@ -183,6 +293,50 @@ func Test_realDirNode_Lookup_CollidingEntry_Success(t *testing.T) {
 	require.True(t, ok)
 }
 
+// Expectation: A real directory named like an archive should always be presented
+// and traversable as a directory, while a link to a directory should be ignored.
+func Test_realDirNode_DirectoryArchiveName_Success(t *testing.T) {
+	t.Parallel()
+	tmpDir, fsys := testFS(t, io.Discard)
+
+	require.NoError(t, os.Mkdir(filepath.Join(tmpDir, "foo.zip"), dirBasePerm))
+	require.NoError(t, os.WriteFile(filepath.Join(tmpDir, "foo.zip", "file.txt"), []byte("x"), 0o644))
+	require.NoError(t, os.Mkdir(filepath.Join(tmpDir, "foo.zip", "sub"), dirBasePerm))
+
+	require.NoError(t, os.Mkdir(filepath.Join(tmpDir, "target"), dirBasePerm))
+	require.NoError(t, os.Symlink(filepath.Join(tmpDir, "target"), filepath.Join(tmpDir, "link.zip")))
+
+	node := &realDirNode{
+		fsys:  fsys,
+		inode: 1,
+		path:  tmpDir,
+		mtime: time.Now(),
+	}
+
+	entries, err := node.ReadDirAll(t.Context())
+	require.NoError(t, err)
+	require.Equal(t, []fuse.Dirent{
+		{Name: "foo.zip", Type: fuse.DT_Dir, Inode: fs.GenerateDynamicInode(1, "foo.zip")},
+		{Name: "target", Type: fuse.DT_Dir, Inode: fs.GenerateDynamicInode(1, "target")},
+	}, entries)
+
+	lk, err := node.Lookup(t.Context(), "foo.zip")
+	require.NoError(t, err)
+	dir, ok := lk.(*realDirNode)
+	require.True(t, ok)
+
+	entries, err = dir.ReadDirAll(t.Context())
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	require.Equal(t, "sub", entries[0].Name)
+
+	_, err = node.Lookup(t.Context(), "foo")
+	require.ErrorIs(t, err, fuse.ToErrno(syscall.ENOENT))
+
+	_, err = node.Lookup(t.Context(), "link")
+	require.ErrorIs(t, err, fuse.ToErrno(syscall.ENOENT))
+}
+
 // Expectation: A lookup on a non-existing entry should return ENOENT.
 func Test_realDirNode_Lookup_EntryNotExist_Error(t *testing.T) {
 	t.Parallel()
@@ -287,3 +441,132 @@ func Test_realDirNode_Deterministicinodes_Success(t *testing.T) {
 	require.NoError(t, err)
 	require.Equal(t, attr.Inode, zn.inode)
 }
+
+// Expectation: Archives without any entries should be presented as configured
+// by [Options.EmptyArchiveAs], while other archives remain directories.
+func Test_realDirNode_EmptyArchiveAs_Success(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		mode     EmptyArchiveMode
+		names    []string
+		types    []fuse.DirentType
+		lookup   string
+		lookupOK bool
+	}{
+		{EmptyArchiveDir, []string{"empty", "full"}, []fuse.DirentType{fuse.DT_Dir, fuse.DT_Dir}, "empty", true},
+		{EmptyArchiveHidden, []string{"full"}, []fuse.DirentType{fuse.DT_Dir}, "empty", false},
+		{EmptyArchiveFile, []string{"empty.zip", "full"}, []fuse.DirentType{fuse.DT_File, fuse.DT_Dir}, "empty.zip", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(strconv.Itoa(int(tt.mode)), func(t *testing.T) {
+			t.Parallel()
+			tmpDir, fsys := testFS(t, io.Discard)
+			fsys.Options.EmptyArchiveAs = tt.mode
+
+			emptyPath := createTestZip(t, tmpDir, "empty.zip", nil)
+			createTestZip(t, tmpDir, "full.zip", []struct {
+				Path    string
+				ModTime time.Time
+				Content []byte
+			}{
+				{Path: "file.txt", ModTime: time.Now(), Content: []byte("content")},
+			})
+
+			node := &realDirNode{fsys: fsys, inode: 1, path: tmpDir, mtime: time.Now()}
+
+			ent, err := node.ReadDirAll(t.Context())
+			require.NoError(t, err)
+			require.Len(t, ent, len(tt.names))
+
+			for i := range ent {
+				require.Equal(t, tt.names[i], ent[i].Name)
+				require.Equal(t, tt.types[i], ent[i].Type)
+			}
+
+			lk, err := node.Lookup(t.Context(), tt.lookup)
+			if !tt.lookupOK {
+				require.ErrorIs(t, err, fuse.ToErrno(syscall.ENOENT))
+
+				return
+			}
+			require.NoError(t, err)
+
+			if tt.mode != EmptyArchiveFile {
+				require.IsType(t, &zipDirNode{}, lk)
+
+				return
+			}
+
+			fn, ok := lk.(*realFileNode)
+			require.True(t, ok)
+
+			var attr fuse.Attr
+			require.NoError(t, fn.Attr(t.Context(), &attr))
+			require.Equal(t, os.FileMode(fileBasePerm), attr.Mode)
+
+			expected, err := os.ReadFile(emptyPath)
+			require.NoError(t, err)
+			require.Equal(t, uint64(len(expected)), attr.Size)
+
+			data, err := fn.ReadAll(t.Context())
+			require.NoError(t, err)
+			require.Equal(t, expected, data)
+
+			_, err = node.Lookup(t.Context(), "empty")
+			require.ErrorIs(t, err, fuse.ToErrno(syscall.ENOENT))
+		})
+	}
+}
+
+// Expectation: The result of checking an archive for entries should be cached,
+// until the archive is modified (then being checked again, with StrictCache
+// also having the modified archive re-opened rather than using a stale reader).
+func Test_FS_emptyArchive_Cache_Success(t *testing.T) {
+	t.Parallel()
+	tmpDir, fsys := testFS(t, io.Discard)
+	fsys.Options.EmptyArchiveAs = EmptyArchiveHidden
+	fsys.Options.StrictCache = true
+
+	zipPath := createTestZip(t, tmpDir, "test.zip", nil)
+
+	info, err := os.Stat(zipPath)
+	require.NoError(t, err)
+	require.True(t, fsys.emptyArchive(zipPath, info))
+	require.Equal(t, 1, fsys.empty.entries.Len())
+
+	createTestZip(t, tmpDir, "test.zip", []struct {
+		Path    string
+		ModTime time.Time
+		Content []byte
+	}{
+		{Path: "file.txt", ModTime: time.Now(), Content: []byte("content")},
+	})
+	require.NoError(t, os.Chtimes(zipPath, time.Now(), time.Now().Add(time.Hour)))
+
+	info, err = os.Stat(zipPath)
+	require.NoError(t, err)
+	require.False(t, fsys.emptyArchive(zipPath, info))
+}
+
+// Expectation: The cache of checked archives should be bounded, with the least
+// recently used archive being evicted (and checked again on its next lookup).
+func Test_FS_emptyArchive_Capacity_Success(t *testing.T) {
+	t.Parallel()
+	tmpDir, fsys := testFS(t, io.Discard)
+	fsys.Options.EmptyArchiveAs = EmptyArchiveHidden
+	fsys.empty = newEmptyArchives(2)
+
+	for _, name := range []string{"a.zip", "b.zip", "c.zip"} {
+		zipPath := createTestZip(t, tmpDir, name, nil)
+
+		info, err := os.Stat(zipPath)
+		require.NoError(t, err)
+		require.True(t, fsys.emptyArchive(zipPath, info))
+	}
+
+	require.Equal(t, 2, fsys.empty.entries.Len())
+	require.Nil(t, fsys.empty.entries.Get(filepath.Join(tmpDir, "a.zip")))
+	require.NotNil(t, fsys.empty.entries.Get(filepath.Join(tmpDir, "c.zip")))
+}
@@ -0,0 +1,72 @@
+package filesystem
+
+import (
+	"context"
+	"os"
+	"syscall"
+	"time"
+
+	"bazil.org/fuse"
+	"bazil.org/fuse/fs"
+)
+
+var (
+	_ fs.Node            = (*realFileNode)(nil)
+	_ fs.NodeOpener      = (*realFileNode)(nil)
+	_ fs.HandleReadAller = (*realFileNode)(nil)
+)
+
+// realFileNode is an actual ZIP archive of the mirrored filesystem without any
+// entries, presented as a regular file (with [EmptyArchiveFile]) rather than as
+// an empty directory. It is read as is, which is small enough to be read whole.
+type realFileNode struct {
+	readOnlyNode // Rejects all modifications (with EROFS).
+
+	fsys  *FS       // Pointer to our filesystem.
+	inode uint64    // Inode within our filesystem.
+	path  string    // Path of the underlying ZIP archive.
+	size  int64     // Size of the underlying ZIP archive.
+	mtime time.Time // Modified time of the underlying ZIP archive.
+}
+
+func (r *realFileNode) Attr(_ context.Context, a *fuse.Attr) error {
+	a.Mode = fileBasePerm
+	a.Inode = r.inode
+	a.Size = uint64(r.size) //nolint:gosec
+
+	mtime := r.fsys.attrTime(r.mtime)
+
+	a.Atime = mtime
+	a.Ctime = mtime
+	a.Mtime = mtime
+
+	return nil
+}
+
+func (r *realFileNode) Open(ctx context.Context, req *fuse.OpenRequest, _ *fuse.OpenResponse) (fs.Handle, error) {
+	r.fsys.Metrics.TotalOpens.Add(1)
+
+	if !req.Flags.IsReadOnly() {
+		return nil, fuse.ToErrno(syscall.EROFS)
+	}
+
+	if err := r.fsys.checkAccess(ctx); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+func (r *realFileNode) ReadAll(_ context.Context) ([]byte, error) {
+	defer r.fsys.active()()
+	r.fsys.Metrics.TotalReads.Add(1)
+
+	b, err := os.ReadFile(r.path)
+	if err != nil {
+		r.fsys.rbuf.Printf("Error: %q->ReadAll: %v\n", r.path, err)
+
+		return nil, toFuseErr(err)
+	}
+
+	return b, nil
+}
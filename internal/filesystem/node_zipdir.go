@@ -2,7 +2,10 @@ package filesystem
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"os"
+	"path"
 	"slices"
 	"strings"
 	"syscall"
@@ -10,6 +13,7 @@ import (
 
 	"bazil.org/fuse"
 	"bazil.org/fuse/fs"
+	"github.com/klauspost/compress/zip"
 )
 
 var (
@@ -17,40 +21,139 @@ var (
 	_ fs.NodeOpener         = (*zipDirNode)(nil)
 	_ fs.HandleReadDirAller = (*zipDirNode)(nil)
 	_ fs.NodeStringLookuper = (*zipDirNode)(nil)
+	_ fs.NodeSetxattrer     = (*zipDirNode)(nil)
+	_ fs.NodeGetxattrer     = (*zipDirNode)(nil)
+	_ fs.NodeListxattrer    = (*zipDirNode)(nil)
+)
+
+const (
+	// refreshXattr is the extended attribute that, when written on a directory of
+	// an archive, evicts it from the cache (with [Options.AllowXattrControl]).
+	refreshXattr = "user.zipfuse.refresh"
+
+	// rawDir is the name of the synthetic directory at the root of an archive's
+	// directory (with [Options.ExposeRaw]), which mirrors the archive's structure
+	// but presents the raw (compressed) bytes of its files (see [zipRawFileNode]).
+	rawDir = ".raw"
 )
 
 // zipDirNode is a ZIP archive file of the mirrored filesystem.
 // It is now presented as a regular directory within our filesystem.
-// When enabled, contained structures are flattened (by [flatEntryName]).
+// When enabled, contained structures are flattened (by [flatEntryNames]).
 // Archive contents are presented as regular entries and unpacked on-the-fly.
 type zipDirNode struct {
+	readOnlyNode // Rejects all modifications (with EROFS).
+
 	fsys   *FS       // Pointer to our filesystem.
 	inode  uint64    // Inode within our filesystem.
 	path   string    // Path of the underlying ZIP archive.
 	prefix string    // Prefix within the underlying ZIP archive.
 	mtime  time.Time // Modified time of the underlying ZIP archive.
+	raw    bool      // Presenting the raw (compressed) bytes of files (see [rawDir]).
 }
 
 func (z *zipDirNode) Attr(_ context.Context, a *fuse.Attr) error {
 	a.Mode = os.ModeDir | dirBasePerm
 	a.Inode = z.inode
 
-	a.Atime = z.mtime
-	a.Ctime = z.mtime
-	a.Mtime = z.mtime
+	if z.fsys.Options.DirSizes.Load() && !z.raw {
+		a.Size = z.dirSize()
+	}
+
+	mtime := z.fsys.attrTime(z.mtime)
+
+	a.Atime = mtime
+	a.Ctime = mtime
+	a.Mtime = mtime
 
 	return nil
 }
 
+// dirSize returns the total size of all files below the directory (as by
+// [zipReader.DirSize]), or zero if the archive cannot be opened (for now),
+// so that the attributes of a directory never fail only because of the size.
+func (z *zipDirNode) dirSize() uint64 {
+	zr, _, err := z.fsys.fdcache.archive(z.path)
+	if err != nil {
+		return 0
+	}
+	defer zr.Release() //nolint:errcheck
+
+	return zr.DirSize(z.prefix)
+}
+
 func (z *zipDirNode) Open(_ context.Context, _ *fuse.OpenRequest, resp *fuse.OpenResponse) (fs.Handle, error) {
-	if !z.fsys.Options.StrictCache {
+	if z.fsys.cacheDirEntries() {
 		resp.Flags |= fuse.OpenKeepCache | fuse.OpenCacheDir
 	}
 
 	return z, nil
 }
 
+// Setxattr evicts the underlying archive from the file descriptor cache on
+// writes of [refreshXattr], so that it is re-opened on the next access. Any
+// other extended attribute (or without [Options.AllowXattrControl]) is EROFS.
+func (z *zipDirNode) Setxattr(ctx context.Context, req *fuse.SetxattrRequest) error {
+	if err := z.fsys.checkAccess(ctx); err != nil {
+		return err
+	}
+
+	if !z.fsys.Options.AllowXattrControl || req.Name != refreshXattr {
+		return fuse.ToErrno(syscall.EROFS)
+	}
+
+	evicted := z.fsys.fdcache.Evict(z.path)
+	z.fsys.failed.Remove(z.path)
+
+	z.fsys.rbuf.Printf("%q->Setxattr: archive refreshed (evicted from cache: %t)\n", z.path, evicted)
+
+	return nil
+}
+
+// Getxattr returns the [archiveXattr] and the [entryXattr] (with
+// [Options.ArchiveXattrs]), with the latter being empty for the directory of
+// an archive itself. Any other extended attribute does not exist.
+func (z *zipDirNode) Getxattr(ctx context.Context, req *fuse.GetxattrRequest, resp *fuse.GetxattrResponse) error {
+	if err := z.fsys.checkAccess(ctx); err != nil {
+		return err
+	}
+
+	if !z.fsys.Options.ArchiveXattrs {
+		return fuse.ErrNoXattr
+	}
+
+	switch req.Name {
+	case archiveXattr:
+		resp.Xattr = []byte(z.path)
+	case entryXattr:
+		resp.Xattr = []byte(strings.TrimSuffix(z.prefix, "/"))
+	default:
+		return fuse.ErrNoXattr
+	}
+
+	return nil
+}
+
+func (z *zipDirNode) Listxattr(ctx context.Context, _ *fuse.ListxattrRequest, resp *fuse.ListxattrResponse) error {
+	if err := z.fsys.checkAccess(ctx); err != nil {
+		return err
+	}
+
+	if z.fsys.Options.ArchiveXattrs {
+		resp.Append(archiveXattr, entryXattr)
+	}
+
+	return nil
+}
+
 func (z *zipDirNode) ReadDirAll(ctx context.Context) ([]fuse.Dirent, error) {
+	defer z.fsys.active()()
+	z.fsys.Metrics.TotalReadDirs.Add(1)
+
+	if err := z.fsys.checkAccess(ctx); err != nil {
+		return nil, err
+	}
+
 	if z.fsys.Options.FlatMode {
 		return z.readDirAllFlat(ctx)
 	}
@@ -59,37 +162,60 @@ func (z *zipDirNode) ReadDirAll(ctx context.Context) ([]fuse.Dirent, error) {
 }
 
 func (z *zipDirNode) Lookup(ctx context.Context, name string) (fs.Node, error) {
+	defer z.fsys.active()()
+	z.fsys.Metrics.TotalLookups.Add(1)
+
+	if err := z.fsys.checkAccess(ctx); err != nil {
+		return nil, err
+	}
+
+	var node fs.Node
+	var err error
+
 	if z.fsys.Options.FlatMode {
-		return z.lookupFlat(ctx, name)
+		node, err = z.lookupFlat(ctx, name)
+	} else {
+		node, err = z.lookupNested(ctx, name)
+	}
+	if err != nil {
+		return nil, err
 	}
 
-	return z.lookupNested(ctx, name)
+	return z.fsys.hookNode(z.nodePath(name), node), nil
 }
 
-func (z *zipDirNode) readDirAllFlat(_ context.Context) ([]fuse.Dirent, error) {
+func (z *zipDirNode) readDirAllFlat(ctx context.Context) ([]fuse.Dirent, error) {
 	m := newZipMetric(z.fsys, false)
+	m.Trace("readdir", z.path, z.prefix)
 	defer m.Done()
 
 	seen := make(map[string]bool)
 	resp := make([]fuse.Dirent, 0)
 
-	zr, err := z.fsys.fdcache.Archive(z.path)
+	zr, res, err := z.fsys.fdcache.archive(z.path)
+	m.Cached(res)
 	if err != nil {
-		z.fsys.rbuf.Printf("%q->ReadDirAll: ZIP Error: %v\n", z.path, err)
+		if errors.Is(err, errArchiveIncomplete) {
+			return []fuse.Dirent{}, nil // still being written
+		}
+		if !errors.Is(err, errArchiveTripped) && !errors.Is(err, errArchivesPaused) {
+			z.fsys.rbuf.Printf("%q->ReadDirAll: ZIP Error: %v\n", z.path, err)
+		}
 
-		return nil, z.fsys.countError(toFuseErr(syscall.EINVAL))
+		return nil, z.fsys.countError(toFuseErr(err))
 	}
 	defer zr.Release() //nolint:errcheck
 
-	for i, f := range zr.File {
-		normalizedPath := zipEntryNormalize(i, f, m.fsys.Options.ForceUnicode)
+	zr.LogTruncated()
+	names := z.flatNames(zr)
 
-		if isDir(f, normalizedPath) {
+	for i, f := range zr.File {
+		if names[i] == "" && zr.skippedFlatEntry(f, zipEntryNormalize(i, f, m.fsys.Options.ForceUnicode, m.fsys.Options.UnicodeNormalize)) {
 			continue
 		}
 
-		name, ok := flatEntryName(i, normalizedPath)
-		if !ok || name == "" || seen[name] {
+		name := names[i]
+		if name == "" || seen[name] {
 			z.fsys.rbuf.Printf("Skipped: %q->ReadDirAll: %q -> %q (duplicate or invalid sanitized name)\n", z.path, f.Name, name)
 
 			continue
@@ -107,94 +233,94 @@ func (z *zipDirNode) readDirAllFlat(_ context.Context) ([]fuse.Dirent, error) {
 		return strings.Compare(a.Name, b.Name) // only [fuse.DT_File]
 	})
 
-	return resp, nil
+	return z.truncateListing(ctx, resp), nil
 }
 
 func (z *zipDirNode) lookupFlat(_ context.Context, name string) (fs.Node, error) {
 	m := newZipMetric(z.fsys, false)
+	m.Trace("lookup", z.path, name)
 	defer m.Done()
 
-	zr, err := z.fsys.fdcache.Archive(z.path)
+	zr, res, err := z.fsys.fdcache.archive(z.path)
+	m.Cached(res)
 	if err != nil {
-		z.fsys.rbuf.Printf("%q->Lookup->%q: ZIP Error: %v\n", z.path, name, err)
+		if errors.Is(err, errArchiveIncomplete) {
+			return nil, toFuseErr(fmt.Errorf("%w: %w", ErrEntryNotFound, err))
+		}
+		if !errors.Is(err, errArchiveTripped) && !errors.Is(err, errArchivesPaused) {
+			z.fsys.rbuf.Printf("%q->Lookup->%q: ZIP Error: %v\n", z.path, name, err)
+		}
 
-		return nil, z.fsys.countError(toFuseErr(syscall.EINVAL))
+		return nil, z.fsys.countError(toFuseErr(err))
 	}
 	defer zr.Release() //nolint:errcheck
 
-	for i, f := range zr.File {
-		normalizedPath := zipEntryNormalize(i, f, m.fsys.Options.ForceUnicode)
-
-		// Dirent is already normalized and flat, needs checking against that:
-		flatName, ok := flatEntryName(i, normalizedPath)
-		if !ok || flatName != name {
+	// Dirent is already normalized and flat, needs checking against that:
+	key := z.fsys.Options.UnicodeNormalize.apply(name)
+	for i, flatName := range z.flatNames(zr) {
+		if flatName == "" || flatName != key {
 			continue
 		}
 
-		base := &zipBaseFileNode{
-			fsys:    z.fsys,
-			archive: z.path,
-			path:    f.Name,
-			inode:   fs.GenerateDynamicInode(z.inode, name),
-			size:    f.UncompressedSize64,
-			mtime:   f.Modified,
-		}
-
-		if f.UncompressedSize64 <= z.fsys.Options.StreamingThreshold.Load() {
-			return &zipInMemoryFileNode{base}, nil
-		}
-
-		return &zipDiskStreamFileNode{base}, nil
+		return z.fileNode(zr.flatFile(zr.File[i]), key), nil
 	}
 
-	return nil, toFuseErr(syscall.ENOENT)
+	return nil, toFuseErr(fmt.Errorf("%w: %s", ErrEntryNotFound, name))
 }
 
-func (z *zipDirNode) readDirAllNested(_ context.Context) ([]fuse.Dirent, error) {
+func (z *zipDirNode) readDirAllNested(ctx context.Context) ([]fuse.Dirent, error) {
 	m := newZipMetric(z.fsys, false)
+	m.Trace("readdir", z.path, z.prefix)
 	defer m.Done()
 
 	resp := []fuse.Dirent{}
-	seen := map[string]bool{}
 
-	zr, err := z.fsys.fdcache.Archive(z.path)
+	zr, res, err := z.fsys.fdcache.archive(z.path)
+	m.Cached(res)
 	if err != nil {
-		z.fsys.rbuf.Printf("%q->ReadDirAll: ZIP error: %v\n", z.path, err)
+		if errors.Is(err, errArchiveIncomplete) {
+			return []fuse.Dirent{}, nil // still being written
+		}
+		if !errors.Is(err, errArchiveTripped) && !errors.Is(err, errArchivesPaused) {
+			z.fsys.rbuf.Printf("%q->ReadDirAll: ZIP error: %v\n", z.path, err)
+		}
 
-		return nil, z.fsys.countError(toFuseErr(syscall.EINVAL))
+		return nil, z.fsys.countError(toFuseErr(err))
 	}
 	defer zr.Release() //nolint:errcheck
 
-	for i, f := range zr.File {
-		normalizedPath := zipEntryNormalize(i, f, m.fsys.Options.ForceUnicode)
+	zr.LogTruncated()
+	seen := z.nestedEntries(zr)
 
-		// Prefix is already normalized, needs checking against that:
-		if !strings.HasPrefix(normalizedPath, z.prefix) {
-			continue
+	for name, e := range seen {
+		typ := fuse.DT_File
+		if z.fsys.nestedDirWins(e.isFile, e.isDir) {
+			typ = fuse.DT_Dir
 		}
 
-		relPath := strings.TrimPrefix(normalizedPath, z.prefix)
-		parts := strings.SplitN(relPath, "/", 2) //nolint:mnd
+		resp = append(resp, fuse.Dirent{
+			Name:  name,
+			Type:  typ,
+			Inode: fs.GenerateDynamicInode(z.inode, name),
+		})
+	}
 
-		name := parts[0]
-		if name == "" || seen[name] {
-			continue
-		}
-		seen[name] = true
+	if z.prefix == "" && !z.raw && z.fsys.Options.ArchiveMarker {
+		name := syntheticName(archiveMarker, seen)
+		resp = append(resp, fuse.Dirent{
+			Name:  name,
+			Type:  fuse.DT_File,
+			Inode: fs.GenerateDynamicInode(z.inode, name),
+		})
+	}
 
-		if len(parts) == 1 && !isDir(f, normalizedPath) {
-			resp = append(resp, fuse.Dirent{
-				Name:  name,
-				Type:  fuse.DT_File,
-				Inode: fs.GenerateDynamicInode(z.inode, name),
-			})
-		} else { // Can be explicit or implicit (dir/, dir/file.txt):
-			resp = append(resp, fuse.Dirent{
-				Name:  name,
-				Type:  fuse.DT_Dir,
-				Inode: fs.GenerateDynamicInode(z.inode, name),
-			})
-		}
+	if z.prefix == "" && !z.raw && z.fsys.Options.ExposeRaw {
+		name := syntheticName(rawDir, seen)
+		resp = append(resp, fuse.Dirent{
+			Name:  name,
+			Type:  fuse.DT_Dir,
+			Inode: fs.GenerateDynamicInode(z.inode, name),
+		})
 	}
 
 	slices.SortFunc(resp, func(a, b fuse.Dirent) int {
@@ -208,58 +334,347 @@ func (z *zipDirNode) readDirAllNested(_ context.Context) ([]fuse.Dirent, error)
 		return 1
 	})
 
-	return resp, nil
+	return z.truncateListing(ctx, resp), nil
 }
 
 func (z *zipDirNode) lookupNested(_ context.Context, name string) (fs.Node, error) {
 	m := newZipMetric(z.fsys, false)
+	m.Trace("lookup", z.path, path.Join(z.prefix, name))
 	defer m.Done()
 
-	zr, err := z.fsys.fdcache.Archive(z.path)
+	zr, res, err := z.fsys.fdcache.archive(z.path)
+	m.Cached(res)
 	if err != nil {
-		z.fsys.rbuf.Printf("%q->Lookup->%q: ZIP error: %v\n", z.path, name, err)
+		if errors.Is(err, errArchiveIncomplete) {
+			return nil, toFuseErr(fmt.Errorf("%w: %w", ErrEntryNotFound, err))
+		}
+		if !errors.Is(err, errArchiveTripped) && !errors.Is(err, errArchivesPaused) {
+			z.fsys.rbuf.Printf("%q->Lookup->%q: ZIP error: %v\n", z.path, name, err)
+		}
 
-		return nil, z.fsys.countError(toFuseErr(syscall.EINVAL))
+		return nil, z.fsys.countError(toFuseErr(err))
 	}
 	defer zr.Release() //nolint:errcheck
 
-	fullPath := z.prefix + name
+	if z.prefix == "" && !z.raw && z.fsys.Options.ArchiveMarker && strings.HasPrefix(name, archiveMarker) {
+		if name == syntheticName(archiveMarker, z.nestedEntries(zr)) {
+			return z.markerNode(zr, name), nil
+		}
+	}
+
+	if z.prefix == "" && !z.raw && z.fsys.Options.ExposeRaw && strings.HasPrefix(name, rawDir) {
+		if name == syntheticName(rawDir, z.nestedEntries(zr)) {
+			return &zipDirNode{
+				fsys:  z.fsys,
+				path:  z.path,
+				inode: fs.GenerateDynamicInode(z.inode, name),
+				mtime: z.mtime,
+				raw:   true,
+			}, nil
+		}
+	}
+
+	// Dirents are already normalized, so the lookup needs to be as well:
+	key := z.fsys.Options.UnicodeNormalize.apply(name)
+	fullPath := z.prefix + key
+
+	var file *zip.File
+	var isDirectory bool
 
 	for i, f := range zr.File {
-		normalizedPath := zipEntryNormalize(i, f, m.fsys.Options.ForceUnicode)
+		normalizedPath := zipEntryNormalize(i, f, m.fsys.Options.ForceUnicode, m.fsys.Options.UnicodeNormalize)
+		if zr.hiddenEntry(f, normalizedPath) {
+			continue
+		}
 
 		// Dirent is already normalized, needs checking against that:
-		if normalizedPath == fullPath && !isDir(f, normalizedPath) {
-			base := &zipBaseFileNode{
-				fsys:    z.fsys,
-				archive: z.path,
-				path:    f.Name,
-				inode:   fs.GenerateDynamicInode(z.inode, name),
-				size:    f.UncompressedSize64,
-				mtime:   f.Modified,
-			}
-
-			if f.UncompressedSize64 <= z.fsys.Options.StreamingThreshold.Load() {
-				return &zipInMemoryFileNode{base}, nil
-			}
-
-			return &zipDiskStreamFileNode{base}, nil
-		}
-
-		// A directory can be explicit or implicit (dir/, dir/file.txt). So in
-		// order to keep things deterministic and to account for any implicit
-		// directories, we assign the modified time of the archive itself for
-		// [zipDirNode] of subdirectories within archives, for the time being.
+		if file == nil && normalizedPath == fullPath && !isDir(f, normalizedPath) {
+			file = f
+		}
+
 		if strings.HasPrefix(normalizedPath, fullPath+"/") {
-			return &zipDirNode{
-				fsys:   z.fsys,
-				path:   z.path,
-				prefix: fullPath + "/",
-				inode:  fs.GenerateDynamicInode(z.inode, name),
-				mtime:  z.mtime,
-			}, nil
+			isDirectory = true
+		}
+
+		// Both types were found, so the remaining entries cannot change anything.
+		if file != nil && isDirectory {
+			break
 		}
 	}
 
-	return nil, toFuseErr(syscall.ENOENT)
+	// A directory can be explicit or implicit (dir/, dir/file.txt). So in
+	// order to keep things deterministic and to account for any implicit
+	// directories, we assign the modified time of the archive itself for
+	// [zipDirNode] of subdirectories within archives, for the time being.
+	if z.fsys.nestedDirWins(file != nil, isDirectory) {
+		return &zipDirNode{
+			fsys:   z.fsys,
+			path:   z.path,
+			prefix: fullPath + "/",
+			inode:  fs.GenerateDynamicInode(z.inode, key),
+			mtime:  z.mtime,
+			raw:    z.raw,
+		}, nil
+	}
+
+	if file != nil {
+		return z.fileNode(file, key), nil
+	}
+
+	if z.fsys.Options.AllowRawNameLookup {
+		if node := z.lookupRawName(zr, name); node != nil {
+			return node, nil
+		}
+	}
+
+	return nil, toFuseErr(fmt.Errorf("%w: %s", ErrEntryNotFound, name))
+}
+
+// nestedEntry describes the types that the entries of the same name (at the
+// same level) within an archive are of, so that conflicts can be resolved.
+type nestedEntry struct {
+	isFile bool
+	isDir  bool
+}
+
+// nestedEntries returns the names (and their types) of all the entries that
+// are directly below the current prefix within the archive (in nested mode),
+// with any implicit directories (dir/file.txt) being returned as directories.
+func (z *zipDirNode) nestedEntries(zr *zipReader) map[string]nestedEntry {
+	seen := map[string]nestedEntry{}
+
+	for i, f := range zr.File {
+		normalizedPath := zipEntryNormalize(i, f, z.fsys.Options.ForceUnicode, z.fsys.Options.UnicodeNormalize)
+
+		// Prefix is already normalized, needs checking against that:
+		if !strings.HasPrefix(normalizedPath, z.prefix) || zr.hiddenEntry(f, normalizedPath) {
+			continue
+		}
+
+		relPath := strings.TrimPrefix(normalizedPath, z.prefix)
+		parts := strings.SplitN(relPath, "/", 2) //nolint:mnd
+
+		name := parts[0]
+		if name == "" {
+			continue
+		}
+
+		e := seen[name]
+		if len(parts) == 1 && !isDir(f, normalizedPath) {
+			e.isFile = true
+		} else { // Can be explicit or implicit (dir/, dir/file.txt):
+			e.isDir = true
+		}
+		seen[name] = e
+	}
+
+	return seen
+}
+
+// nestedDirWins returns if a name within an archive (in nested mode) is to be
+// presented as a directory, when entries of the name are of the given types.
+// Malformed archives can contain both a file and a directory of the same name
+// (e.g. "foo" and "foo/bar"), which is resolved by [Options.NestedConflicts],
+// so that the outcome never depends on the physical order of archive entries.
+func (fsys *FS) nestedDirWins(isFile bool, isDir bool) bool {
+	if isFile && isDir {
+		return fsys.Options.NestedConflicts == NestedConflictDirectory
+	}
+
+	return isDir
+}
+
+// lookupRawName tries to find a file within the current prefix, which has a
+// normalized name (as listed) but is matched by its raw (stored) name instead.
+// The raw names are sanitized the same as normalized names (but for unicode),
+// and any path traversal or other structure-altering names are never matched.
+func (z *zipDirNode) lookupRawName(zr *zipReader, name string) fs.Node {
+	if name == "" || name == "." || name == ".." || strings.Contains(name, "/") {
+		return nil
+	}
+
+	for i, f := range zr.File {
+		normalizedPath := zipEntryNormalize(i, f, z.fsys.Options.ForceUnicode, z.fsys.Options.UnicodeNormalize)
+		if isDir(f, normalizedPath) || !strings.HasPrefix(normalizedPath, z.prefix) || zr.hiddenEntry(f, normalizedPath) {
+			continue
+		}
+
+		normalizedName := strings.TrimPrefix(normalizedPath, z.prefix)
+		if normalizedName == "" || strings.Contains(normalizedName, "/") {
+			continue
+		}
+
+		rawPath := zipEntryNormalize(i, f, false, z.fsys.Options.UnicodeNormalize)
+		if rawPath == normalizedPath || path.Base(rawPath) != name {
+			continue
+		}
+
+		// Inode of the normalized name, so to be consistent with the listing:
+		return z.fileNode(f, normalizedName)
+	}
+
+	return nil
+}
+
+// truncateListing caps a sorted listing at [Options.MaxListEntries], then
+// appending a synthetic marker entry (which cannot be looked up) in place of
+// all the truncated entries. Any truncated entries can still be looked up.
+// The listings of walks are never truncated (see [FS.walkReadDirAll]), as
+// these need to visit every entry (and could not look up the marker entry).
+func (z *zipDirNode) truncateListing(ctx context.Context, resp []fuse.Dirent) []fuse.Dirent {
+	limit := z.fsys.Options.MaxListEntries
+	if limit <= 0 || len(resp) <= limit || isWalk(ctx) {
+		return resp
+	}
+
+	more := len(resp) - limit
+	name := fmt.Sprintf("...truncated (%d more)", more)
+
+	z.fsys.rbuf.Printf("Warning: %q->ReadDirAll: %q: listing truncated to %d entries (%d more)\n", z.path, z.prefix, limit, more)
+
+	return append(resp[:limit], fuse.Dirent{
+		Name:  name,
+		Type:  fuse.DT_File,
+		Inode: fs.GenerateDynamicInode(z.inode, name),
+	})
+}
+
+// flatNames returns the flattened filenames for all entries of the archive,
+// as by [flatEntryNames], resolving collisions by [Options.FlatCollisions]
+// (and indexing dotfiles by [Options.FlatDotfileStyle]).
+// Directories, hidden (by [Options.OnlyExtensions]) and any invalid entries
+// are returned as empty filenames.
+func (z *zipDirNode) flatNames(zr *zipReader) []string {
+	paths := make([]string, len(zr.File))
+
+	for i, f := range zr.File {
+		normalizedPath := zipEntryNormalize(i, f, z.fsys.Options.ForceUnicode, z.fsys.Options.UnicodeNormalize)
+		if zr.skippedFlatEntry(f, normalizedPath) {
+			continue
+		}
+		paths[i] = normalizedPath
+	}
+
+	return flatEntryNames(paths, z.fsys.Options.FlatCollisions, z.fsys.Options.FlatDotfileStyle)
+}
+
+// changedEntries returns the files of the archive modified after since, with
+// the paths as presented within the archive's directory (flattened in flat mode).
+// The modified time is that of the extended timestamp, if the entry has one.
+func (z *zipDirNode) changedEntries(zr *zipReader, since time.Time) []ChangedEntry {
+	var names []string
+	if z.fsys.Options.FlatMode {
+		names = z.flatNames(zr)
+	}
+
+	var entries []ChangedEntry
+
+	for i, f := range zr.File {
+		name := zipEntryNormalize(i, f, z.fsys.Options.ForceUnicode, z.fsys.Options.UnicodeNormalize)
+		if zr.skippedFlatEntry(f, name) {
+			continue
+		}
+		if names != nil {
+			name = names[i]
+			f = zr.flatFile(f)
+		}
+		if name == "" {
+			continue
+		}
+
+		mtime := zipEntryModified(f, z.fsys.Options.TimestampTZ)
+		if ux := zipEntryUnixFromExtra(f); !ux.mtime.IsZero() {
+			mtime = ux.mtime
+		}
+		if !mtime.After(since) {
+			continue
+		}
+
+		entries = append(entries, ChangedEntry{
+			Path:  name,
+			Size:  f.UncompressedSize64,
+			Mtime: mtime,
+		})
+	}
+
+	return entries
+}
+
+// skippedFlatEntry checks if a [zip.File] is never presented in flat mode,
+// being either a directory, hidden (see [zipReader.hiddenEntry]) or a symlink
+// not resolving to a file of the archive (with [Options.FlatDerefSymlinks]).
+func (zr *zipReader) skippedFlatEntry(f *zip.File, normalizedPath string) bool {
+	if isDir(f, normalizedPath) || zr.hiddenEntry(f, normalizedPath) {
+		return true
+	}
+
+	if zr.fsys.Options.FlatDerefSymlinks && isSymlink(f) {
+		target, _ := zr.Symlink(f)
+
+		return target == nil
+	}
+
+	return false
+}
+
+// flatFile returns the [zip.File] whose content is presented for a file in
+// flat mode, being the target of a symlink with [Options.FlatDerefSymlinks].
+func (zr *zipReader) flatFile(f *zip.File) *zip.File {
+	if !zr.fsys.Options.FlatDerefSymlinks {
+		return f
+	}
+
+	if target, ok := zr.Symlink(f); ok && target != nil {
+		return target
+	}
+
+	return f
+}
+
+// fileNode returns the [fs.Node] for a [zip.File] contained in the archive.
+// Depending on [Options.ThresholdRules] and [Options.StreamingThreshold], it
+// is either returned as a [zipInMemoryFileNode] or a [zipDiskStreamFileNode].
+// Within the [rawDir], it is always returned as a [zipRawFileNode] instead.
+func (z *zipDirNode) fileNode(f *zip.File, name string) fs.Node {
+	ux := zipEntryUnixFromExtra(f)
+
+	base := &zipBaseFileNode{
+		fsys:     z.fsys,
+		archive:  z.path,
+		path:     f.Name,
+		method:   f.Method,
+		flags:    f.Flags,
+		inode:    fs.GenerateDynamicInode(z.inode, name),
+		size:     f.UncompressedSize64,
+		csize:    f.CompressedSize64,
+		csizeFix: z.fsys.Options.SizeMode == SizeCompressed && !z.raw,
+		crc32:    f.CRC32,
+		mtime:    zipEntryModified(f, z.fsys.Options.TimestampTZ),
+		atime:    ux.atime,
+		uid:      ux.uid,
+		gid:      ux.gid,
+		hasOwner: ux.hasOwner,
+	}
+
+	// Neither symlinks (presented as files) nor raw bytes are ever executable.
+	if f.Mode().IsRegular() && !z.raw {
+		base.perm = f.Mode().Perm()
+	}
+
+	// The extended timestamp is more accurate than the DOS timestamp.
+	if !ux.mtime.IsZero() {
+		base.mtime = ux.mtime
+	}
+
+	if z.raw {
+		base.size = f.CompressedSize64
+
+		return &zipRawFileNode{base}
+	}
+
+	if z.fsys.streamEntry(z.path, f.Name, f.UncompressedSize64) {
+		return &zipDiskStreamFileNode{base}
+	}
+
+	return &zipInMemoryFileNode{base}
 }
@@ -1,15 +1,19 @@
 package filesystem
 
 import (
+	"bytes"
 	"io"
 	"os"
+	"path/filepath"
 	"strconv"
+	"strings"
 	"syscall"
 	"testing"
 	"time"
 
 	"bazil.org/fuse"
 	"bazil.org/fuse/fs"
+	"github.com/klauspost/compress/zip"
 	"github.com/stretchr/testify/require"
 )
 
@@ -37,6 +41,61 @@ func Test_zipDirNode_Attr_Success(t *testing.T) {
 	require.Equal(t, tnow, attr.Mtime)
 }
 
+// Expectation: Attr should report the total size of all contained files with
+// [Options.DirSizes], but zero when disabled or the archive cannot be opened.
+func Test_zipDirNode_Attr_DirSizes_Success(t *testing.T) {
+	t.Parallel()
+	tmpDir, fsys := testFS(t, io.Discard)
+	tnow := time.Now()
+
+	zipPath := createTestZip(t, tmpDir, "test.zip", []struct {
+		Path    string
+		ModTime time.Time
+		Content []byte
+	}{
+		{Path: "sub/a.txt", ModTime: tnow, Content: []byte("abc")},
+		{Path: "b.txt", ModTime: tnow, Content: []byte("de")},
+	})
+
+	node := &zipDirNode{
+		fsys:  fsys,
+		inode: fs.GenerateDynamicInode(1, "test"),
+		path:  zipPath,
+		mtime: tnow,
+	}
+	sub := &zipDirNode{
+		fsys:   fsys,
+		inode:  fs.GenerateDynamicInode(node.inode, "sub"),
+		path:   zipPath,
+		prefix: "sub/",
+		mtime:  tnow,
+	}
+	broken := &zipDirNode{
+		fsys:  fsys,
+		inode: fs.GenerateDynamicInode(1, "broken"),
+		path:  "/nonexistent.zip",
+		mtime: tnow,
+	}
+
+	attr := fuse.Attr{}
+	require.NoError(t, node.Attr(t.Context(), &attr))
+	require.Zero(t, attr.Size)
+
+	fsys.Options.DirSizes.Store(true)
+
+	attr = fuse.Attr{}
+	require.NoError(t, node.Attr(t.Context(), &attr))
+	require.Equal(t, uint64(5), attr.Size)
+
+	attr = fuse.Attr{}
+	require.NoError(t, sub.Attr(t.Context(), &attr))
+	require.Equal(t, uint64(3), attr.Size)
+
+	attr = fuse.Attr{}
+	require.NoError(t, broken.Attr(t.Context(), &attr))
+	require.Zero(t, attr.Size)
+}
+
 // Expectation: Open should set the caching flags and return the node itself as the handle.
 func Test_zipDirNode_Open_Success(t *testing.T) {
 	t.Parallel()
@@ -86,6 +145,28 @@ func Test_zipDirNode_Open_Success(t *testing.T) {
 	}
 }
 
+// Expectation: Open should not set the caching flags without CacheDirEntries.
+func Test_zipDirNode_Open_CacheDirEntries_Success(t *testing.T) {
+	t.Parallel()
+	_, fsys := testFS(t, io.Discard)
+
+	fsys.Options.CacheDirEntries = false
+	fsys.Options.CacheFileContent = true
+
+	node := &zipDirNode{
+		fsys:  fsys,
+		inode: fs.GenerateDynamicInode(1, "test.zip"),
+		mtime: time.Now(),
+	}
+
+	resp := &fuse.OpenResponse{}
+	_, err := node.Open(t.Context(), &fuse.OpenRequest{}, resp)
+	require.NoError(t, err)
+
+	require.Zero(t, resp.Flags&fuse.OpenKeepCache, "OpenKeepCache flag should not be set")
+	require.Zero(t, resp.Flags&fuse.OpenCacheDir, "OpenCacheDir flag should not be set")
+}
+
 // Expectation: The returned [fuse.Dirent] slice should meet the expectations (flat mode).
 func Test_zipDirNode_readDirAllFlat_Success(t *testing.T) {
 	t.Parallel()
@@ -115,25 +196,145 @@ func Test_zipDirNode_readDirAllFlat_Success(t *testing.T) {
 	require.NoError(t, err)
 	require.Len(t, ent, 3)
 
-	name, ok := flatEntryName(1, "dir/a.txt")
+	name, ok := flatEntryName(1, "dir/a.txt", DotfileFlatPrefix)
 	require.True(t, ok)
 	require.Equal(t, fs.GenerateDynamicInode(node.inode, name), ent[0].Inode)
 	require.Equal(t, name, ent[0].Name)
 	require.Equal(t, fuse.DT_File, ent[0].Type)
 
-	name, ok = flatEntryName(2, "dir/b.txt")
+	name, ok = flatEntryName(2, "dir/b.txt", DotfileFlatPrefix)
 	require.True(t, ok)
 	require.Equal(t, fs.GenerateDynamicInode(node.inode, name), ent[1].Inode)
 	require.Equal(t, name, ent[1].Name)
 	require.Equal(t, fuse.DT_File, ent[1].Type)
 
-	name, ok = flatEntryName(3, "dir/b.txt")
+	name, ok = flatEntryName(3, "dir/b.txt", DotfileFlatPrefix)
 	require.True(t, ok)
 	require.Equal(t, fs.GenerateDynamicInode(node.inode, name), ent[2].Inode)
 	require.Equal(t, name, ent[2].Name)
 	require.Equal(t, fuse.DT_File, ent[2].Type)
 }
 
+// Expectation: Files with identical content should be collapsed into the first
+// with DedupIdentical (in both modes), while empty files are never collapsed.
+func Test_zipDirNode_DedupIdentical_Success(t *testing.T) {
+	t.Parallel()
+
+	for _, flat := range []bool{false, true} {
+		t.Run("FlatMode="+strconv.FormatBool(flat), func(t *testing.T) {
+			t.Parallel()
+			tmpDir, fsys := testFS(t, io.Discard)
+			tnow := time.Now()
+
+			fsys.Options.FlatMode = flat
+			fsys.Options.DedupIdentical = true
+
+			zipPath := createTestZip(t, tmpDir, "test.zip", []struct {
+				Path    string
+				ModTime time.Time
+				Content []byte
+			}{
+				{Path: "a.txt", ModTime: tnow, Content: []byte("same content")},
+				{Path: "b.txt", ModTime: tnow, Content: []byte("same content")},
+				{Path: "c.txt", ModTime: tnow, Content: []byte("other content")},
+				{Path: "d.txt", ModTime: tnow, Content: nil},
+				{Path: "e.txt", ModTime: tnow, Content: nil},
+			})
+
+			node := &zipDirNode{
+				fsys:  fsys,
+				inode: fs.GenerateDynamicInode(1, "test"),
+				path:  zipPath,
+				mtime: tnow,
+			}
+
+			ent, err := node.ReadDirAll(t.Context())
+			require.NoError(t, err)
+
+			names := make([]string, 0, len(ent))
+			for _, e := range ent {
+				names = append(names, e.Name)
+			}
+
+			bName := "b.txt"
+			if flat {
+				require.Equal(t, []string{"a(0).txt", "c(2).txt", "d(3).txt", "e(4).txt"}, names)
+				bName = "b(1).txt"
+			} else {
+				require.Equal(t, []string{"a.txt", "c.txt", "d.txt", "e.txt"}, names)
+			}
+
+			_, err = node.Lookup(t.Context(), bName)
+			require.ErrorIs(t, err, ErrEntryNotFound)
+
+			zr, err := fsys.fdcache.Archive(zipPath)
+			require.NoError(t, err)
+			defer zr.Release() //nolint:errcheck
+
+			require.Equal(t, uint64(len("same content")+len("other content")), zr.DirSize(""))
+		})
+	}
+}
+
+// Expectation: With Unicode normalization, the NFC and NFD forms of a name
+// should be listed in the chosen form and resolve to the same entry on lookup.
+func Test_zipDirNode_UnicodeNormalize_Success(t *testing.T) {
+	t.Parallel()
+
+	const nfc, nfd = "caf\u00e9.txt", "cafe\u0301.txt"
+
+	for _, tt := range []struct {
+		form UnicodeNormalization
+		want string
+	}{
+		{form: UnicodeNormalizeNFC, want: nfc},
+		{form: UnicodeNormalizeNFD, want: nfd},
+	} {
+		t.Run(strconv.Itoa(int(tt.form)), func(t *testing.T) {
+			t.Parallel()
+			tmpDir, fsys := testFS(t, io.Discard)
+			tnow := time.Now()
+
+			fsys.Options.UnicodeNormalize = tt.form
+
+			zipPath := createTestZip(t, tmpDir, "test.zip", []struct {
+				Path    string
+				ModTime time.Time
+				Content []byte
+			}{
+				{Path: "dir/" + nfd, ModTime: tnow, Content: []byte("content")},
+			})
+
+			node := &zipDirNode{
+				fsys:  fsys,
+				inode: fs.GenerateDynamicInode(1, "test"),
+				path:  zipPath,
+				mtime: tnow,
+			}
+
+			dir, err := node.Lookup(t.Context(), "dir")
+			require.NoError(t, err)
+
+			dn, ok := dir.(*zipDirNode)
+			require.True(t, ok)
+
+			ent, err := dn.ReadDirAll(t.Context())
+			require.NoError(t, err)
+			require.Len(t, ent, 1)
+			require.Equal(t, tt.want, ent[0].Name)
+
+			for _, name := range []string{nfc, nfd} {
+				fn, err := dn.Lookup(t.Context(), name)
+				require.NoError(t, err, "name %q", name)
+
+				var attr fuse.Attr
+				require.NoError(t, fn.Attr(t.Context(), &attr))
+				require.Equal(t, ent[0].Inode, attr.Inode, "name %q", name)
+			}
+		})
+	}
+}
+
 // Expectation: Leading slashes in ZIP entries should be handled in flat mode.
 func Test_zipDirNode_readDirAllFlat_LeadingSlash_Success(t *testing.T) {
 	t.Parallel()
@@ -161,13 +362,13 @@ func Test_zipDirNode_readDirAllFlat_LeadingSlash_Success(t *testing.T) {
 	require.NoError(t, err)
 	require.Len(t, ent, 2)
 
-	name, ok := flatEntryName(0, zipEntryNormalize(0, createTestZipFilePtr(t, "/file.txt"), fsys.Options.ForceUnicode))
+	name, ok := flatEntryName(0, zipEntryNormalize(0, createTestZipFilePtr(t, "/file.txt"), fsys.Options.ForceUnicode, UnicodeNormalizeNone), DotfileFlatPrefix)
 	require.True(t, ok)
 	require.Equal(t, name, ent[0].Name)
 	require.NotContains(t, name, "/")
 	require.Equal(t, fuse.DT_File, ent[0].Type)
 
-	name, ok = flatEntryName(1, zipEntryNormalize(1, createTestZipFilePtr(t, "//normal.txt"), fsys.Options.ForceUnicode))
+	name, ok = flatEntryName(1, zipEntryNormalize(1, createTestZipFilePtr(t, "//normal.txt"), fsys.Options.ForceUnicode, UnicodeNormalizeNone), DotfileFlatPrefix)
 	require.True(t, ok)
 	require.Equal(t, name, ent[1].Name)
 	require.NotContains(t, name, "/")
@@ -190,6 +391,7 @@ func Test_zipDirNode_readDirAllFlat_InvalidArchive_Error(t *testing.T) {
 	ent, err := node.readDirAllFlat(t.Context())
 	require.Nil(t, ent)
 	require.ErrorIs(t, err, fuse.ToErrno(syscall.EINVAL))
+	require.ErrorIs(t, err, ErrArchiveUnreadable)
 }
 
 // Expectation: The returned [fuse.Dirent] slice should meet the expectations (nested mode - root).
@@ -560,6 +762,114 @@ func Test_zipDirNode_readDirAllNested_InvalidArchive_Error(t *testing.T) {
 	ent, err := node.readDirAllNested(t.Context())
 	require.Nil(t, ent)
 	require.ErrorIs(t, err, fuse.ToErrno(syscall.EINVAL))
+	require.ErrorIs(t, err, ErrArchiveUnreadable)
+}
+
+// Expectation: An incomplete archive should be presented as empty directory in tail mode,
+// without counting as error or failed archive, and be picked up once it is complete.
+func Test_zipDirNode_TailMode_Success(t *testing.T) {
+	t.Parallel()
+	tmpDir, fsys := testFS(t, io.Discard)
+	tnow := time.Now()
+
+	fsys.Options.TailMode = true
+
+	zipPath := filepath.Join(tmpDir, "test.zip")
+	require.NoError(t, os.WriteFile(zipPath, []byte("PK\x03\x04incomplete"), 0o644))
+
+	for _, flat := range []bool{false, true} {
+		node := &zipDirNode{
+			fsys:  fsys,
+			inode: fs.GenerateDynamicInode(1, "test"),
+			path:  zipPath,
+			mtime: tnow,
+		}
+
+		var ent []fuse.Dirent
+		var err error
+		if flat {
+			ent, err = node.readDirAllFlat(t.Context())
+		} else {
+			ent, err = node.readDirAllNested(t.Context())
+		}
+		require.NoError(t, err)
+		require.NotNil(t, ent)
+		require.Empty(t, ent)
+
+		var lk fs.Node
+		if flat {
+			lk, err = node.lookupFlat(t.Context(), "file.txt")
+		} else {
+			lk, err = node.lookupNested(t.Context(), "file.txt")
+		}
+		require.Nil(t, lk)
+		require.ErrorIs(t, err, fuse.ToErrno(syscall.ENOENT))
+	}
+
+	require.Zero(t, fsys.Metrics.Errors.Load())
+	require.Empty(t, fsys.FailedArchives())
+
+	createTestZip(t, tmpDir, "test.zip", []struct {
+		Path    string
+		ModTime time.Time
+		Content []byte
+	}{
+		{Path: "file.txt", ModTime: tnow, Content: []byte("content")},
+	})
+
+	node := &zipDirNode{
+		fsys:  fsys,
+		inode: fs.GenerateDynamicInode(1, "test"),
+		path:  zipPath,
+		mtime: tnow,
+	}
+
+	ent, err := node.readDirAllNested(t.Context())
+	require.NoError(t, err)
+	require.Len(t, ent, 1)
+	require.Equal(t, "file.txt", ent[0].Name)
+}
+
+// Expectation: An invalid archive should still error in tail mode, if it was
+// not modified within the tail window, or if tail mode is not enabled at all.
+func Test_zipDirNode_TailMode_Error(t *testing.T) {
+	t.Parallel()
+	tmpDir, fsys := testFS(t, io.Discard)
+	tnow := time.Now()
+
+	fsys.Options.TailMode = true
+	fsys.Options.TailWindow = time.Minute
+
+	oldPath := filepath.Join(tmpDir, "old.zip")
+	require.NoError(t, os.WriteFile(oldPath, []byte("corrupt"), 0o644))
+	require.NoError(t, os.Chtimes(oldPath, tnow.Add(-time.Hour), tnow.Add(-time.Hour)))
+
+	node := &zipDirNode{
+		fsys:  fsys,
+		inode: fs.GenerateDynamicInode(1, "old"),
+		path:  oldPath,
+		mtime: tnow,
+	}
+
+	ent, err := node.readDirAllNested(t.Context())
+	require.Nil(t, ent)
+	require.ErrorIs(t, err, fuse.ToErrno(syscall.EINVAL))
+	require.Len(t, fsys.FailedArchives(), 1)
+
+	_, fsys2 := testFS(t, io.Discard)
+	newPath := filepath.Join(tmpDir, "new.zip")
+	require.NoError(t, os.WriteFile(newPath, []byte("corrupt"), 0o644))
+
+	node = &zipDirNode{
+		fsys:  fsys2,
+		inode: fs.GenerateDynamicInode(1, "new"),
+		path:  newPath,
+		mtime: tnow,
+	}
+
+	ent, err = node.readDirAllNested(t.Context())
+	require.Nil(t, ent)
+	require.ErrorIs(t, err, fuse.ToErrno(syscall.EINVAL))
 }
 
 // Expectation: The returned lookup nodes should meet the expectations (flat mode).
@@ -587,7 +897,7 @@ func Test_zipDirNode_lookupFlat_Success(t *testing.T) {
 		mtime: tnow,
 	}
 
-	name, ok := flatEntryName(1, "dir/a.txt")
+	name, ok := flatEntryName(1, "dir/a.txt", DotfileFlatPrefix)
 	require.True(t, ok)
 	lk, err := node.lookupFlat(t.Context(), name)
 	require.NoError(t, err)
@@ -597,7 +907,7 @@ func Test_zipDirNode_lookupFlat_Success(t *testing.T) {
 	require.Equal(t, "dir/a.txt", mn.path)
 	require.WithinDuration(t, tnow, mn.mtime, time.Second)
 
-	name, ok = flatEntryName(2, "dir/b.txt")
+	name, ok = flatEntryName(2, "dir/b.txt", DotfileFlatPrefix)
 	require.True(t, ok)
 	lk, err = node.lookupFlat(t.Context(), name)
 	require.NoError(t, err)
@@ -608,6 +918,169 @@ func Test_zipDirNode_lookupFlat_Success(t *testing.T) {
 	require.WithinDuration(t, tnow, dn.mtime, time.Second)
 }
 
+// Expectation: The compressed size should be reported with SizeCompressed (and
+// the files opened with direct I/O), while the contents are still read in full.
+func Test_zipDirNode_Lookup_SizeCompressed_Success(t *testing.T) {
+	t.Parallel()
+	tmpDir, fsys := testFS(t, io.Discard)
+	fsys.Options.SizeMode = SizeCompressed
+
+	tnow := time.Now()
+	content := bytes.Repeat([]byte("compressible "), 1000)
+
+	zipPath := createTestZipMethod(t, tmpDir, "test.zip", zip.Deflate, []struct {
+		Path    string
+		ModTime time.Time
+		Content []byte
+	}{
+		{Path: "a.txt", ModTime: tnow, Content: content},
+		{Path: "b.txt", ModTime: tnow, Content: content},
+	})
+
+	node := &zipDirNode{
+		fsys:  fsys,
+		inode: fs.GenerateDynamicInode(1, "test"),
+		path:  zipPath,
+		mtime: tnow,
+	}
+
+	fsys.Options.StreamingThreshold.Store(uint64(len(content)) + 1)
+	lk, err := node.Lookup(t.Context(), "a.txt")
+	require.NoError(t, err)
+
+	var attr fuse.Attr
+	require.NoError(t, lk.Attr(t.Context(), &attr))
+	require.NotZero(t, attr.Size)
+	require.Less(t, attr.Size, uint64(len(content)))
+
+	mn, ok := lk.(*zipInMemoryFileNode)
+	require.True(t, ok)
+
+	resp := &fuse.OpenResponse{}
+	_, err = mn.Open(t.Context(), &fuse.OpenRequest{}, resp)
+	require.NoError(t, err)
+	require.NotZero(t, resp.Flags&fuse.OpenDirectIO)
+	require.Zero(t, resp.Flags&fuse.OpenKeepCache)
+
+	data, err := mn.ReadAll(t.Context())
+	require.NoError(t, err)
+	require.Equal(t, content, data)
+
+	fsys.Options.StreamingThreshold.Store(1)
+	lk, err = node.Lookup(t.Context(), "b.txt")
+	require.NoError(t, err)
+
+	dn, ok := lk.(*zipDiskStreamFileNode)
+	require.True(t, ok)
+
+	resp = &fuse.OpenResponse{}
+	handle, err := dn.Open(t.Context(), &fuse.OpenRequest{}, resp)
+	require.NoError(t, err)
+	require.NotZero(t, resp.Flags&fuse.OpenDirectIO)
+	hr, ok := handle.(fs.HandleReleaser)
+	require.True(t, ok)
+	require.NoError(t, hr.Release(t.Context(), &fuse.ReleaseRequest{}))
+
+	fsys.Options.SizeMode = SizeUncompressed
+	lk, err = node.Lookup(t.Context(), "a.txt")
+	require.NoError(t, err)
+	require.NoError(t, lk.Attr(t.Context(), &attr))
+	require.Equal(t, uint64(len(content)), attr.Size)
+}
+
+// Expectation: Dotfiles should be listed with the index appended (suffix style),
+// with all of the listed names being resolvable by lookup (flat mode).
+func Test_zipDirNode_lookupFlat_DotfileSuffix_Success(t *testing.T) {
+	t.Parallel()
+	tmpDir, fsys := testFS(t, io.Discard)
+	fsys.Options.FlatMode = true
+	fsys.Options.FlatDotfileStyle = DotfileFlatSuffix
+
+	tnow := time.Now()
+
+	zipPath := createTestZip(t, tmpDir, "test.zip", []struct {
+		Path    string
+		ModTime time.Time
+		Content []byte
+	}{
+		{Path: "repo/", ModTime: tnow, Content: nil},
+		{Path: "repo/.gitignore", ModTime: tnow, Content: []byte("*.o")},
+		{Path: "repo/main.c", ModTime: tnow, Content: []byte("int main;")},
+	})
+
+	node := &zipDirNode{
+		fsys:  fsys,
+		inode: fs.GenerateDynamicInode(1, "test"),
+		path:  zipPath,
+		mtime: tnow,
+	}
+
+	ent, err := node.readDirAllFlat(t.Context())
+	require.NoError(t, err)
+
+	names := make([]string, 0, len(ent))
+	for _, e := range ent {
+		names = append(names, e.Name)
+
+		lk, err := node.lookupFlat(t.Context(), e.Name)
+		require.NoError(t, err, e.Name)
+		require.NotNil(t, lk)
+	}
+	require.ElementsMatch(t, []string{".gitignore(1)", "main(2).c"}, names)
+
+	_, err = node.lookupFlat(t.Context(), "(1).gitignore")
+	require.ErrorIs(t, err, fuse.ToErrno(syscall.ENOENT))
+}
+
+// Expectation: Names exceeding nameMax should be listed truncated (in both the
+// nested and flat mode), with the truncated names being resolvable by lookup.
+func Test_zipDirNode_LongName_Success(t *testing.T) {
+	t.Parallel()
+
+	for _, flat := range []bool{false, true} {
+		t.Run("FlatMode="+strconv.FormatBool(flat), func(t *testing.T) {
+			t.Parallel()
+			tmpDir, fsys := testFS(t, io.Discard)
+			fsys.Options.FlatMode = flat
+
+			long := strings.Repeat("a", 296) + ".txt"
+			content := []byte("long name content")
+
+			zipPath := createTestZip(t, tmpDir, "test.zip", []struct {
+				Path    string
+				ModTime time.Time
+				Content []byte
+			}{
+				{Path: long, ModTime: time.Now(), Content: content},
+			})
+
+			node := &zipDirNode{
+				fsys:  fsys,
+				inode: fs.GenerateDynamicInode(1, "test"),
+				path:  zipPath,
+				mtime: time.Now(),
+			}
+
+			ent, err := node.ReadDirAll(t.Context())
+			require.NoError(t, err)
+			require.Len(t, ent, 1)
+			require.LessOrEqual(t, len(ent[0].Name), nameMax)
+			require.True(t, strings.HasSuffix(ent[0].Name, ".txt"))
+
+			lk, err := node.Lookup(t.Context(), ent[0].Name)
+			require.NoError(t, err)
+
+			fn, ok := lk.(*zipInMemoryFileNode)
+			require.True(t, ok)
+			require.Equal(t, long, fn.path)
+
+			data, err := fn.ReadAll(t.Context())
+			require.NoError(t, err)
+			require.Equal(t, content, data)
+		})
+	}
+}
+
 // Expectation: A lookup on a non-existing entry should return ENOENT (flat mode).
 func Test_zipDirNode_lookupFlat_EntryNotExist_Error(t *testing.T) {
 	t.Parallel()
@@ -633,11 +1106,12 @@ func Test_zipDirNode_lookupFlat_EntryNotExist_Error(t *testing.T) {
 		mtime: tnow,
 	}
 
-	name, ok := flatEntryName(0, "dir/c.txt") // missing
+	name, ok := flatEntryName(0, "dir/c.txt", DotfileFlatPrefix) // missing
 	require.True(t, ok)
 	lk, err := node.lookupFlat(t.Context(), name)
 	require.Nil(t, lk)
 	require.ErrorIs(t, err, fuse.ToErrno(syscall.ENOENT))
+	require.ErrorIs(t, err, ErrEntryNotFound)
 }
 
 // Expectation: A lookup on an invalid backing archive should return EINVAL (flat mode).
@@ -655,7 +1129,7 @@ func Test_zipDirNode_lookupFlat_InvalidArchive_Error(t *testing.T) {
 		mtime: tnow,
 	}
 
-	name, ok := flatEntryName(0, "dir/c.txt")
+	name, ok := flatEntryName(0, "dir/c.txt", DotfileFlatPrefix)
 	require.True(t, ok)
 	lk, err := node.lookupFlat(t.Context(), name)
 	require.Nil(t, lk)
@@ -979,7 +1453,7 @@ func Test_zipDirNode_DeterministicInodes_Flat_Success(t *testing.T) {
 	require.NoError(t, err)
 	require.Len(t, ent, 2)
 
-	name, ok := flatEntryName(1, "dir/a.txt")
+	name, ok := flatEntryName(1, "dir/a.txt", DotfileFlatPrefix)
 	require.True(t, ok)
 	require.Equal(t, fs.GenerateDynamicInode(node.inode, name), ent[0].Inode)
 	require.Equal(t, name, ent[0].Name)
@@ -994,7 +1468,7 @@ func Test_zipDirNode_DeterministicInodes_Flat_Success(t *testing.T) {
 	require.NoError(t, err)
 	require.Equal(t, mn.inode, attr.Inode)
 
-	name, ok = flatEntryName(2, "dir/b.txt")
+	name, ok = flatEntryName(2, "dir/b.txt", DotfileFlatPrefix)
 	require.True(t, ok)
 	require.Equal(t, fs.GenerateDynamicInode(node.inode, name), ent[1].Inode)
 	require.Equal(t, name, ent[1].Name)
@@ -1068,3 +1542,796 @@ func Test_zipDirNode_DeterministicInodes_Nested_Success(t *testing.T) {
 	require.NoError(t, err)
 	require.Equal(t, mn.inode, attr.Inode)
 }
+
+// Expectation: Colliding basenames of deep paths should be prefixed with their
+// parent directory in flat mode, consistently in both ReadDirAll and Lookup.
+func Test_zipDirNode_Flat_DirectoryCollisions_Success(t *testing.T) {
+	t.Parallel()
+	tmpDir, fsys := testFS(t, io.Discard)
+	fsys.Options.FlatCollisions = FlatCollisionDirectory
+	tnow := time.Now()
+
+	zipPath := createTestZip(t, tmpDir, "test.zip", []struct {
+		Path    string
+		ModTime time.Time
+		Content []byte
+	}{
+		{Path: "a/b/dirA/", ModTime: tnow, Content: nil},
+		{Path: "a/b/dirA/file.txt", ModTime: tnow, Content: []byte("A")},
+		{Path: "c/d/e/dirB/file.txt", ModTime: tnow, Content: []byte("B")},
+		{Path: "x/same/file.txt", ModTime: tnow, Content: []byte("C")},
+		{Path: "y/same/file.txt", ModTime: tnow, Content: []byte("D")},
+		{Path: "deep/path/unique.txt", ModTime: tnow, Content: []byte("E")},
+	})
+
+	node := &zipDirNode{
+		fsys:  fsys,
+		inode: fs.GenerateDynamicInode(1, "test"),
+		path:  zipPath,
+		mtime: tnow,
+	}
+
+	ent, err := node.readDirAllFlat(t.Context())
+	require.NoError(t, err)
+
+	names := make([]string, 0, len(ent))
+	for _, e := range ent {
+		names = append(names, e.Name)
+	}
+	require.Equal(t, []string{"dirA_file.txt", "dirB_file.txt", "file(3).txt", "file(4).txt", "unique.txt"}, names)
+
+	expected := map[string]string{
+		"dirA_file.txt": "a/b/dirA/file.txt",
+		"dirB_file.txt": "c/d/e/dirB/file.txt",
+		"file(3).txt":   "x/same/file.txt",
+		"file(4).txt":   "y/same/file.txt",
+		"unique.txt":    "deep/path/unique.txt",
+	}
+	for name, path := range expected {
+		lk, err := node.lookupFlat(t.Context(), name)
+		require.NoError(t, err)
+		mn, ok := lk.(*zipInMemoryFileNode)
+		require.True(t, ok)
+		require.Equal(t, path, mn.path)
+		require.Equal(t, fs.GenerateDynamicInode(node.inode, name), mn.inode)
+	}
+
+	_, err = node.lookupFlat(t.Context(), "file.txt")
+	require.ErrorIs(t, err, fuse.ToErrno(syscall.ENOENT))
+}
+
+// Expectation: A file with a non-unicode name should be found by its raw name,
+// but only when enabled and never for any path traversing raw names.
+func Test_zipDirNode_lookupNested_RawName_Success(t *testing.T) {
+	t.Parallel()
+	tmpDir, fsys := testFS(t, io.Discard)
+	tnow := time.Now()
+
+	zipPath := createTestZip(t, tmpDir, "test.zip", []struct {
+		Path    string
+		ModTime time.Time
+		Content []byte
+	}{
+		{Path: "dir/\xff\xfe.txt", ModTime: tnow, Content: []byte("raw")},
+		{Path: "../\xff\xfd.txt", ModTime: tnow, Content: []byte("evil")},
+	})
+
+	node := &zipDirNode{
+		fsys:   fsys,
+		inode:  fs.GenerateDynamicInode(1, "test"),
+		path:   zipPath,
+		prefix: "dir/",
+		mtime:  tnow,
+	}
+
+	_, err := node.lookupNested(t.Context(), "\xff\xfe.txt")
+	require.ErrorIs(t, err, fuse.ToErrno(syscall.ENOENT))
+
+	fsys.Options.AllowRawNameLookup = true
+
+	lk, err := node.lookupNested(t.Context(), "\xff\xfe.txt")
+	require.NoError(t, err)
+	mn, ok := lk.(*zipInMemoryFileNode)
+	require.True(t, ok)
+	require.Equal(t, "dir/\xff\xfe.txt", mn.path)
+	require.Equal(t, fs.GenerateDynamicInode(node.inode, "noutf8_file(0).txt"), mn.inode)
+
+	lk, err = node.lookupNested(t.Context(), "noutf8_file(0).txt")
+	require.NoError(t, err)
+	nn, ok := lk.(*zipInMemoryFileNode)
+	require.True(t, ok)
+	require.Equal(t, mn.inode, nn.inode)
+
+	root := &zipDirNode{
+		fsys:  fsys,
+		inode: fs.GenerateDynamicInode(1, "test"),
+		path:  zipPath,
+		mtime: tnow,
+	}
+
+	for _, name := range []string{"\xff\xfd.txt", "../\xff\xfd.txt"} {
+		_, err = root.lookupNested(t.Context(), name)
+		require.ErrorIs(t, err, fuse.ToErrno(syscall.ENOENT))
+	}
+}
+
+// Expectation: Listings should be truncated with a marker entry, while all
+// truncated entries still remain accessible through lookups (nested mode).
+func Test_zipDirNode_readDirAllNested_MaxListEntries_Success(t *testing.T) {
+	t.Parallel()
+	tmpDir, fsys := testFS(t, io.Discard)
+	fsys.Options.MaxListEntries = 2
+	tnow := time.Now()
+
+	zipPath := createTestZip(t, tmpDir, "test.zip", []struct {
+		Path    string
+		ModTime time.Time
+		Content []byte
+	}{
+		{Path: "sub/x.txt", ModTime: tnow, Content: []byte("x")},
+		{Path: "a.txt", ModTime: tnow, Content: []byte("a")},
+		{Path: "b.txt", ModTime: tnow, Content: []byte("b")},
+		{Path: "c.txt", ModTime: tnow, Content: []byte("c")},
+	})
+
+	node := &zipDirNode{
+		fsys:  fsys,
+		inode: fs.GenerateDynamicInode(1, "test"),
+		path:  zipPath,
+		mtime: tnow,
+	}
+
+	ent, err := node.readDirAllNested(t.Context())
+	require.NoError(t, err)
+	require.Len(t, ent, 3)
+	require.Equal(t, "sub", ent[0].Name)
+	require.Equal(t, "a.txt", ent[1].Name)
+	require.Equal(t, "...truncated (2 more)", ent[2].Name)
+	require.Equal(t, fuse.DT_File, ent[2].Type)
+
+	lk, err := node.lookupNested(t.Context(), "c.txt")
+	require.NoError(t, err)
+	require.NotNil(t, lk)
+
+	_, err = node.lookupNested(t.Context(), ent[2].Name)
+	require.ErrorIs(t, err, fuse.ToErrno(syscall.ENOENT))
+
+	fsys.Options.MaxListEntries = 4
+
+	ent, err = node.readDirAllNested(t.Context())
+	require.NoError(t, err)
+	require.Len(t, ent, 4)
+	require.Equal(t, "c.txt", ent[3].Name)
+}
+
+// Expectation: Listings should be truncated with a marker entry, while all
+// truncated entries still remain accessible through lookups (flat mode).
+func Test_zipDirNode_readDirAllFlat_MaxListEntries_Success(t *testing.T) {
+	t.Parallel()
+	var buf bytes.Buffer
+	tmpDir, fsys := testFS(t, &buf)
+	fsys.Options.MaxListEntries = 1
+	tnow := time.Now()
+
+	zipPath := createTestZip(t, tmpDir, "test.zip", []struct {
+		Path    string
+		ModTime time.Time
+		Content []byte
+	}{
+		{Path: "dir/a.txt", ModTime: tnow, Content: []byte("a")},
+		{Path: "dir/b.txt", ModTime: tnow, Content: []byte("b")},
+	})
+
+	node := &zipDirNode{
+		fsys:  fsys,
+		inode: fs.GenerateDynamicInode(1, "test"),
+		path:  zipPath,
+		mtime: tnow,
+	}
+
+	ent, err := node.readDirAllFlat(t.Context())
+	require.NoError(t, err)
+	require.Len(t, ent, 2)
+	require.Equal(t, "a(0).txt", ent[0].Name)
+	require.Equal(t, "...truncated (1 more)", ent[1].Name)
+	require.Contains(t, buf.String(), "listing truncated to 1 entries (1 more)")
+
+	lk, err := node.lookupFlat(t.Context(), "b(1).txt")
+	require.NoError(t, err)
+	require.NotNil(t, lk)
+}
+
+// Expectation: Only entries with the listed extensions should be listed and looked up,
+// with directories that would be empty being hidden in nested mode.
+func Test_zipDirNode_OnlyExtensions_Nested_Success(t *testing.T) {
+	t.Parallel()
+	tmpDir, fsys := testFS(t, io.Discard)
+	fsys.Options.OnlyExtensions = []string{"jpg", ".MP4"}
+	tnow := time.Now()
+
+	zipPath := createTestZip(t, tmpDir, "test.zip", []struct {
+		Path    string
+		ModTime time.Time
+		Content []byte
+	}{
+		{Path: "image.JPG", ModTime: tnow, Content: []byte("a")},
+		{Path: "notes.txt", ModTime: tnow, Content: []byte("b")},
+		{Path: "README", ModTime: tnow, Content: []byte("c")},
+		{Path: "empty/", ModTime: tnow, Content: nil},
+		{Path: "docs/", ModTime: tnow, Content: nil},
+		{Path: "docs/a.txt", ModTime: tnow, Content: []byte("d")},
+		{Path: "media/", ModTime: tnow, Content: nil},
+		{Path: "media/clip.mp4", ModTime: tnow, Content: []byte("e")},
+		{Path: "media/clip.nfo", ModTime: tnow, Content: []byte("f")},
+	})
+
+	node := &zipDirNode{
+		fsys:  fsys,
+		inode: fs.GenerateDynamicInode(1, "test"),
+		path:  zipPath,
+		mtime: tnow,
+	}
+
+	ent, err := node.readDirAllNested(t.Context())
+	require.NoError(t, err)
+	require.Len(t, ent, 2)
+	require.Equal(t, "media", ent[0].Name)
+	require.Equal(t, fuse.DT_Dir, ent[0].Type)
+	require.Equal(t, "image.JPG", ent[1].Name)
+
+	_, err = node.lookupNested(t.Context(), "image.JPG")
+	require.NoError(t, err)
+
+	for _, name := range []string{"notes.txt", "README", "empty", "docs"} {
+		_, err = node.lookupNested(t.Context(), name)
+		require.ErrorIs(t, err, ErrEntryNotFound, name)
+		require.ErrorIs(t, err, fuse.ToErrno(syscall.ENOENT), name)
+	}
+
+	lk, err := node.lookupNested(t.Context(), "media")
+	require.NoError(t, err)
+
+	sub, ok := lk.(*zipDirNode)
+	require.True(t, ok)
+
+	ent, err = sub.readDirAllNested(t.Context())
+	require.NoError(t, err)
+	require.Len(t, ent, 1)
+	require.Equal(t, "clip.mp4", ent[0].Name)
+
+	_, err = sub.lookupNested(t.Context(), "clip.nfo")
+	require.ErrorIs(t, err, ErrEntryNotFound)
+}
+
+// Expectation: Only entries with the listed extensions should be listed and looked up in flat mode.
+func Test_zipDirNode_OnlyExtensions_Flat_Success(t *testing.T) {
+	t.Parallel()
+	tmpDir, fsys := testFS(t, io.Discard)
+	fsys.Options.OnlyExtensions = []string{"jpg"}
+	fsys.Options.FlatCollisions = FlatCollisionDirectory
+	tnow := time.Now()
+
+	zipPath := createTestZip(t, tmpDir, "test.zip", []struct {
+		Path    string
+		ModTime time.Time
+		Content []byte
+	}{
+		{Path: "a/image.jpg", ModTime: tnow, Content: []byte("a")},
+		{Path: "b/image.txt", ModTime: tnow, Content: []byte("b")},
+		{Path: "b/notes.txt", ModTime: tnow, Content: []byte("c")},
+	})
+
+	node := &zipDirNode{
+		fsys:  fsys,
+		inode: fs.GenerateDynamicInode(1, "test"),
+		path:  zipPath,
+		mtime: tnow,
+	}
+
+	ent, err := node.readDirAllFlat(t.Context())
+	require.NoError(t, err)
+	require.Len(t, ent, 1)
+	require.Equal(t, "image.jpg", ent[0].Name)
+
+	_, err = node.lookupFlat(t.Context(), "image.jpg")
+	require.NoError(t, err)
+
+	_, err = node.lookupFlat(t.Context(), "notes.txt")
+	require.ErrorIs(t, err, ErrEntryNotFound)
+}
+
+// Expectation: A name being both a file and a directory should be resolved by the
+// strategy, consistently in listings and lookups and regardless of the entry order.
+func Test_zipDirNode_NestedConflicts_Success(t *testing.T) {
+	t.Parallel()
+
+	tnow := time.Now()
+
+	orders := map[string][]struct {
+		Path    string
+		ModTime time.Time
+		Content []byte
+	}{
+		"file-first": {
+			{Path: "foo", ModTime: tnow, Content: []byte("file")},
+			{Path: "foo/bar.txt", ModTime: tnow, Content: []byte("bar")},
+		},
+		"dir-first": {
+			{Path: "foo/bar.txt", ModTime: tnow, Content: []byte("bar")},
+			{Path: "foo", ModTime: tnow, Content: []byte("file")},
+		},
+	}
+
+	strategies := []struct {
+		strategy NestedConflictStrategy
+		typ      fuse.DirentType
+	}{
+		{NestedConflictDirectory, fuse.DT_Dir},
+		{NestedConflictFile, fuse.DT_File},
+	}
+
+	for _, st := range strategies {
+		for order, entries := range orders {
+			tmpDir, fsys := testFS(t, io.Discard)
+			fsys.Options.NestedConflicts = st.strategy
+
+			zipPath := createTestZip(t, tmpDir, "test.zip", entries)
+
+			node := &zipDirNode{
+				fsys:  fsys,
+				inode: fs.GenerateDynamicInode(1, "test"),
+				path:  zipPath,
+				mtime: tnow,
+			}
+
+			ent, err := node.readDirAllNested(t.Context())
+			require.NoError(t, err, order)
+			require.Len(t, ent, 1, order)
+			require.Equal(t, "foo", ent[0].Name, order)
+			require.Equal(t, st.typ, ent[0].Type, order)
+
+			lk, err := node.lookupNested(t.Context(), "foo")
+			require.NoError(t, err, order)
+
+			if st.typ == fuse.DT_Dir {
+				sub, ok := lk.(*zipDirNode)
+				require.True(t, ok, order)
+
+				_, err = sub.lookupNested(t.Context(), "bar.txt")
+				require.NoError(t, err, order)
+			} else {
+				require.IsType(t, &zipInMemoryFileNode{}, lk, order)
+			}
+		}
+	}
+}
+
+// Expectation: Setxattr should evict the archive from the cache on writes of the refresh attribute.
+func Test_zipDirNode_Setxattr_Refresh_Success(t *testing.T) {
+	t.Parallel()
+	tmpDir, fsys := testFS(t, io.Discard)
+	tnow := time.Now()
+
+	fsys.Options.AllowXattrControl = true
+
+	zipPath := createTestZip(t, tmpDir, "test.zip", []struct {
+		Path    string
+		ModTime time.Time
+		Content []byte
+	}{
+		{Path: "test.txt", ModTime: tnow, Content: []byte("test")},
+	})
+
+	node := &zipDirNode{
+		fsys:  fsys,
+		inode: fs.GenerateDynamicInode(1, "test"),
+		path:  zipPath,
+		mtime: tnow,
+	}
+
+	_, err := node.readDirAllNested(t.Context())
+	require.NoError(t, err)
+	require.Equal(t, 1, fsys.fdcache.cache.Len())
+
+	err = node.Setxattr(t.Context(), &fuse.SetxattrRequest{Name: refreshXattr, Xattr: []byte("1")})
+	require.NoError(t, err)
+	require.Zero(t, fsys.fdcache.cache.Len())
+
+	err = node.Setxattr(t.Context(), &fuse.SetxattrRequest{Name: refreshXattr, Xattr: []byte("1")})
+	require.NoError(t, err)
+}
+
+// Expectation: Setxattr should return EROFS for other attributes or when not enabled.
+func Test_zipDirNode_Setxattr_ReadOnly_Error(t *testing.T) {
+	t.Parallel()
+	_, fsys := testFS(t, io.Discard)
+
+	node := &zipDirNode{
+		fsys:  fsys,
+		inode: fs.GenerateDynamicInode(1, "test"),
+		path:  "/nonexistent.zip",
+		mtime: time.Now(),
+	}
+
+	err := node.Setxattr(t.Context(), &fuse.SetxattrRequest{Name: refreshXattr})
+	require.ErrorIs(t, err, fuse.ToErrno(syscall.EROFS))
+
+	fsys.Options.AllowXattrControl = true
+
+	err = node.Setxattr(t.Context(), &fuse.SetxattrRequest{Name: "user.other"})
+	require.ErrorIs(t, err, fuse.ToErrno(syscall.EROFS))
+}
+
+// Expectation: With ArchiveXattrs, the directory of an archive (and those within
+// it) should have the backing archive and their path within it as extended
+// attributes, with none of these existing otherwise.
+func Test_zipDirNode_Getxattr_Archive_Success(t *testing.T) {
+	t.Parallel()
+	tmpDir, fsys := testFS(t, io.Discard)
+	tnow := time.Now()
+
+	zipPath := createTestZip(t, tmpDir, "test.zip", []struct {
+		Path    string
+		ModTime time.Time
+		Content []byte
+	}{
+		{Path: "docs/images/logo.png", ModTime: tnow, Content: []byte("image")},
+	})
+
+	node := &zipDirNode{
+		fsys:  fsys,
+		inode: fs.GenerateDynamicInode(1, "test"),
+		path:  zipPath,
+		mtime: tnow,
+	}
+
+	err := node.Getxattr(t.Context(), &fuse.GetxattrRequest{Name: archiveXattr}, &fuse.GetxattrResponse{})
+	require.Equal(t, fuse.ErrNoXattr, err)
+
+	list := &fuse.ListxattrResponse{}
+	require.NoError(t, node.Listxattr(t.Context(), &fuse.ListxattrRequest{}, list))
+	require.Empty(t, list.Xattr)
+
+	fsys.Options.ArchiveXattrs = true
+
+	sub, err := node.Lookup(t.Context(), "docs")
+	require.NoError(t, err)
+	sub, err = sub.(*zipDirNode).Lookup(t.Context(), "images")
+	require.NoError(t, err)
+
+	for dir, entry := range map[*zipDirNode]string{node: "", sub.(*zipDirNode): "docs/images"} {
+		resp := &fuse.GetxattrResponse{}
+		require.NoError(t, dir.Getxattr(t.Context(), &fuse.GetxattrRequest{Name: archiveXattr}, resp))
+		require.Equal(t, zipPath, string(resp.Xattr))
+
+		resp = &fuse.GetxattrResponse{}
+		require.NoError(t, dir.Getxattr(t.Context(), &fuse.GetxattrRequest{Name: entryXattr}, resp))
+		require.Equal(t, entry, string(resp.Xattr))
+
+		err = dir.Getxattr(t.Context(), &fuse.GetxattrRequest{Name: "user.other"}, &fuse.GetxattrResponse{})
+		require.Equal(t, fuse.ErrNoXattr, err)
+
+		list = &fuse.ListxattrResponse{}
+		require.NoError(t, dir.Listxattr(t.Context(), &fuse.ListxattrRequest{}, list))
+		require.Equal(t, archiveXattr+"\x00"+entryXattr+"\x00", string(list.Xattr))
+	}
+}
+
+// Expectation: The archive marker should be listed and looked up only at the
+// root of an archive's directory, never colliding with a real entry's name.
+func Test_zipDirNode_ArchiveMarker_Success(t *testing.T) {
+	t.Parallel()
+	tmpDir, fsys := testFS(t, io.Discard)
+	fsys.Options.ArchiveMarker = true
+	tnow := time.Now()
+
+	zipPath := createTestZip(t, tmpDir, "test.zip", []struct {
+		Path    string
+		ModTime time.Time
+		Content []byte
+	}{
+		{Path: "sub/x.txt", ModTime: tnow, Content: []byte("x")},
+		{Path: archiveMarker, ModTime: tnow, Content: []byte("real")},
+	})
+
+	node := &zipDirNode{
+		fsys:  fsys,
+		inode: fs.GenerateDynamicInode(1, "test"),
+		path:  zipPath,
+		mtime: tnow,
+	}
+
+	ent, err := node.readDirAllNested(t.Context())
+	require.NoError(t, err)
+	require.Len(t, ent, 3)
+	require.Equal(t, "sub", ent[0].Name)
+	require.Equal(t, archiveMarker, ent[1].Name)
+	require.Equal(t, archiveMarker+".1", ent[2].Name)
+
+	lk, err := node.lookupNested(t.Context(), archiveMarker)
+	require.NoError(t, err)
+	require.IsType(t, &zipInMemoryFileNode{}, lk)
+
+	lk, err = node.lookupNested(t.Context(), archiveMarker+".1")
+	require.NoError(t, err)
+	require.IsType(t, &zipMarkerNode{}, lk)
+	require.Equal(t, ent[2].Inode, lk.(*zipMarkerNode).inode)
+
+	sub, err := node.lookupNested(t.Context(), "sub")
+	require.NoError(t, err)
+
+	ent, err = sub.(*zipDirNode).readDirAllNested(t.Context())
+	require.NoError(t, err)
+	require.Len(t, ent, 1)
+
+	_, err = sub.(*zipDirNode).lookupNested(t.Context(), archiveMarker)
+	require.ErrorIs(t, err, fuse.ToErrno(syscall.ENOENT))
+}
+
+// Expectation: The archive marker should not be presented when not enabled or in flat mode.
+func Test_zipDirNode_ArchiveMarker_Disabled_Success(t *testing.T) {
+	t.Parallel()
+	tmpDir, fsys := testFS(t, io.Discard)
+	tnow := time.Now()
+
+	zipPath := createTestZip(t, tmpDir, "test.zip", []struct {
+		Path    string
+		ModTime time.Time
+		Content []byte
+	}{
+		{Path: "test.txt", ModTime: tnow, Content: []byte("test")},
+	})
+
+	node := &zipDirNode{
+		fsys:  fsys,
+		inode: fs.GenerateDynamicInode(1, "test"),
+		path:  zipPath,
+		mtime: tnow,
+	}
+
+	ent, err := node.readDirAllNested(t.Context())
+	require.NoError(t, err)
+	require.Len(t, ent, 1)
+
+	_, err = node.lookupNested(t.Context(), archiveMarker)
+	require.ErrorIs(t, err, fuse.ToErrno(syscall.ENOENT))
+
+	fsys.Options.ArchiveMarker = true
+	fsys.Options.FlatMode = true
+
+	ent, err = node.ReadDirAll(t.Context())
+	require.NoError(t, err)
+	require.Len(t, ent, 1)
+
+	_, err = node.Lookup(t.Context(), archiveMarker)
+	require.ErrorIs(t, err, fuse.ToErrno(syscall.ENOENT))
+}
+
+// Expectation: The raw directory should be listed and looked up only at the root
+// of an archive's directory, mirroring its structure with raw files (only).
+func Test_zipDirNode_ExposeRaw_Success(t *testing.T) {
+	t.Parallel()
+	tmpDir, fsys := testFS(t, io.Discard)
+	fsys.Options.ExposeRaw = true
+	fsys.Options.ArchiveMarker = true
+	tnow := time.Now()
+
+	zipPath := createTestZip(t, tmpDir, "test.zip", []struct {
+		Path    string
+		ModTime time.Time
+		Content []byte
+	}{
+		{Path: "sub/x.txt", ModTime: tnow, Content: []byte("x")},
+		{Path: rawDir + "/y.txt", ModTime: tnow, Content: []byte("y")},
+	})
+
+	node := &zipDirNode{
+		fsys:  fsys,
+		inode: fs.GenerateDynamicInode(1, "test"),
+		path:  zipPath,
+		mtime: tnow,
+	}
+
+	ent, err := node.readDirAllNested(t.Context())
+	require.NoError(t, err)
+	require.Len(t, ent, 4)
+	require.Equal(t, rawDir, ent[0].Name)
+	require.Equal(t, rawDir+".1", ent[1].Name)
+	require.Equal(t, fuse.DT_Dir, ent[1].Type)
+	require.Equal(t, "sub", ent[2].Name)
+	require.Equal(t, archiveMarker, ent[3].Name)
+
+	lk, err := node.lookupNested(t.Context(), rawDir)
+	require.NoError(t, err)
+	require.False(t, lk.(*zipDirNode).raw)
+
+	lk, err = node.lookupNested(t.Context(), rawDir+".1")
+	require.NoError(t, err)
+
+	raw, ok := lk.(*zipDirNode)
+	require.True(t, ok)
+	require.True(t, raw.raw)
+	require.Equal(t, ent[1].Inode, raw.inode)
+
+	ent, err = raw.readDirAllNested(t.Context())
+	require.NoError(t, err)
+	require.Len(t, ent, 2)
+	require.Equal(t, rawDir, ent[0].Name)
+	require.Equal(t, "sub", ent[1].Name)
+
+	_, err = raw.lookupNested(t.Context(), rawDir+".1")
+	require.ErrorIs(t, err, fuse.ToErrno(syscall.ENOENT))
+
+	_, err = raw.lookupNested(t.Context(), archiveMarker)
+	require.ErrorIs(t, err, fuse.ToErrno(syscall.ENOENT))
+
+	sub, err := raw.lookupNested(t.Context(), "sub")
+	require.NoError(t, err)
+	require.True(t, sub.(*zipDirNode).raw)
+
+	file, err := sub.(*zipDirNode).lookupNested(t.Context(), "x.txt")
+	require.NoError(t, err)
+	require.IsType(t, &zipRawFileNode{}, file)
+}
+
+// createTestZipSymlinks creates a ZIP archive with a regular file (at "dir/file.txt")
+// and symlinks (by their path and target), as stored by Unix archivers.
+func createTestZipSymlinks(t *testing.T, tmpDir string, tmpName string, links [][2]string) string {
+	t.Helper()
+
+	tmpFile, err := os.Create(filepath.Join(tmpDir, tmpName))
+	require.NoError(t, err)
+	defer tmpFile.Close()
+
+	zw := zip.NewWriter(tmpFile)
+
+	w, err := zw.Create("dir/file.txt")
+	require.NoError(t, err)
+	_, err = w.Write([]byte("content"))
+	require.NoError(t, err)
+
+	for _, link := range links {
+		header := &zip.FileHeader{Name: link[0], Method: zip.Store, Modified: time.Now()}
+		header.SetMode(os.ModeSymlink | 0o777)
+
+		w, err := zw.CreateHeader(header)
+		require.NoError(t, err)
+		_, err = w.Write([]byte(link[1]))
+		require.NoError(t, err)
+	}
+
+	require.NoError(t, zw.Close())
+
+	return tmpFile.Name()
+}
+
+// Expectation: With PreservePermissions, a flat file of an executable archive
+// entry (0755) should be executable (read+execute), while non-executable files
+// and symlinks remain read-only, as should all files without the option.
+func Test_zipDirNode_lookupFlat_PreservePermissions_Success(t *testing.T) {
+	t.Parallel()
+	tmpDir, fsys := testFS(t, io.Discard)
+	fsys.Options.FlatMode = true
+	fsys.Options.FlatCollisions = FlatCollisionDirectory
+
+	tmpFile, err := os.Create(filepath.Join(tmpDir, "perm.zip"))
+	require.NoError(t, err)
+	defer tmpFile.Close()
+
+	zw := zip.NewWriter(tmpFile)
+	for _, entry := range []struct {
+		name    string
+		mode    os.FileMode
+		content string
+	}{
+		{"bin/tool", 0o755, "#!/bin/sh\n"},
+		{"bin/file.txt", 0o644, "content"},
+		{"bin/link", os.ModeSymlink | 0o777, "tool"},
+	} {
+		header := &zip.FileHeader{Name: entry.name, Method: zip.Deflate, Modified: time.Now()}
+		header.SetMode(entry.mode)
+
+		w, err := zw.CreateHeader(header)
+		require.NoError(t, err)
+		_, err = w.Write([]byte(entry.content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, zw.Close())
+
+	node := &zipDirNode{fsys: fsys, inode: fs.GenerateDynamicInode(1, "perm"), path: tmpFile.Name(), mtime: time.Now()}
+
+	for _, preserve := range []bool{false, true} {
+		fsys.Options.PreservePermissions = preserve
+
+		expected := map[string]os.FileMode{
+			"tool":     fileBasePerm,
+			"file.txt": fileBasePerm,
+			"link":     fileBasePerm,
+		}
+		if preserve {
+			expected["tool"] = 0o555
+		}
+
+		for name, mode := range expected {
+			fn, err := node.Lookup(t.Context(), name)
+			require.NoError(t, err)
+
+			var attr fuse.Attr
+			require.NoError(t, fn.Attr(t.Context(), &attr))
+			require.Equal(t, mode, attr.Mode, "%s (preserve=%t)", name, preserve)
+		}
+	}
+}
+
+// Expectation: With FlatDerefSymlinks, symlinks within the archive should be
+// presented with the content of their targets (following chains), while those
+// not resolving to a file of the archive should be hidden. Without it, all of
+// the symlinks should be presented as they are (with their targets as content).
+func Test_zipDirNode_FlatDerefSymlinks_Success(t *testing.T) {
+	t.Parallel()
+
+	for _, deref := range []bool{false, true} {
+		t.Run("Deref="+strconv.FormatBool(deref), func(t *testing.T) {
+			t.Parallel()
+			tmpDir, fsys := testFS(t, io.Discard)
+			fsys.Options.FlatMode = true
+			fsys.Options.FlatCollisions = FlatCollisionDirectory
+			fsys.Options.FlatDerefSymlinks = deref
+
+			zipPath := createTestZipSymlinks(t, tmpDir, "test.zip", [][2]string{
+				{"dir/link", "file.txt"},
+				{"top", "dir/link"},
+				{"abs", "/etc/passwd"},
+				{"up", "../outside"},
+				{"dangling", "nope"},
+				{"todir", "dir"},
+				{"loop1", "loop2"},
+				{"loop2", "loop1"},
+			})
+
+			node := &zipDirNode{fsys: fsys, inode: fs.GenerateDynamicInode(1, "test"), path: zipPath, mtime: time.Now()}
+
+			ent, err := node.ReadDirAll(t.Context())
+			require.NoError(t, err)
+
+			names := make([]string, 0, len(ent))
+			for _, e := range ent {
+				names = append(names, e.Name)
+			}
+
+			if !deref {
+				require.Equal(t, []string{"abs", "dangling", "file.txt", "link", "loop1", "loop2", "todir", "top", "up"}, names)
+
+				fn, err := node.Lookup(t.Context(), "top")
+				require.NoError(t, err)
+
+				data, err := fn.(fs.HandleReadAller).ReadAll(t.Context())
+				require.NoError(t, err)
+				require.Equal(t, []byte("dir/link"), data)
+
+				return
+			}
+
+			require.Equal(t, []string{"file.txt", "link", "top"}, names)
+
+			for _, name := range []string{"link", "top"} {
+				fn, err := node.Lookup(t.Context(), name)
+				require.NoError(t, err)
+
+				var attr fuse.Attr
+				require.NoError(t, fn.Attr(t.Context(), &attr))
+				require.Equal(t, uint64(7), attr.Size)
+				require.Equal(t, fs.GenerateDynamicInode(node.inode, name), attr.Inode)
+
+				data, err := fn.(fs.HandleReadAller).ReadAll(t.Context())
+				require.NoError(t, err)
+				require.Equal(t, []byte("content"), data)
+			}
+
+			for _, name := range []string{"abs", "up", "dangling", "todir", "loop1"} {
+				_, err := node.Lookup(t.Context(), name)
+				require.ErrorIs(t, err, fuse.ToErrno(syscall.ENOENT))
+			}
+		})
+	}
+}
@@ -3,7 +3,10 @@ package filesystem
 import (
 	"context"
 	"errors"
+	"fmt"
 	"io"
+	"math"
+	"os"
 	"sync"
 	"syscall"
 	"time"
@@ -12,31 +15,153 @@ import (
 	"bazil.org/fuse/fs"
 )
 
-var _ fs.Node = (*zipBaseFileNode)(nil)
+var (
+	_ fs.Node = (*zipBaseFileNode)(nil)
+
+	// errSizeOverflow is for a file too large to be held in memory at once.
+	errSizeOverflow = errors.New("size exceeds addressable memory")
+
+	// errSizeInMemory is for a file exceeding [Options.MaxInMemoryBytes].
+	errSizeInMemory = errors.New("size exceeds in-memory limit")
+
+	// errStreamSeek is for a streamed file failing to forward to an offset.
+	errStreamSeek = errors.New("failed to forward")
+
+	// errStreamReopen is for a streamed file failing to be re-opened.
+	errStreamReopen = errors.New("failed to reopen")
+
+	// errStreamStale is for a streamed file whose archive changed while open.
+	errStreamStale = errors.New("archive changed while open")
+)
+
+const (
+	// modeXattr is the extended attribute of a file within an archive, which
+	// reads as the mode it is served in ("memory" or "stream"), as decided on
+	// lookup (see [FS.streamEntry]), for observing the streaming thresholds.
+	modeXattr = "user.zipfuse.mode"
+
+	modeMemory = "memory" // of [zipInMemoryFileNode]
+	modeStream = "stream" // of [zipDiskStreamFileNode]
+
+	// archiveXattr is the extended attribute of a node within an archive, which
+	// reads as the path of the underlying archive (with [Options.ArchiveXattrs]).
+	archiveXattr = "user.zipfuse.archive"
+
+	// entryXattr is the extended attribute of a node within an archive, which
+	// reads as its path inside the archive (with [Options.ArchiveXattrs]).
+	entryXattr = "user.zipfuse.entry"
+)
 
 // zipBaseFileNode is a file within a ZIP archive of the mirrored filesystem.
 // It is presented as a regular file in our filesystem and unpacked on demand.
 //
 // To be embedded into either [zipInMemoryFileNode] or [zipDiskStreamFileNode],
-// depending on [Options.StreamingThreshold] as set by arguments or at runtime.
+// depending on [Options.StreamingThreshold] as set by arguments or at runtime
+// (or as overridden per-archive by [Options.StreamingThresholdOverrides]),
+// unless any of the entry-level [Options.ThresholdRules] is matching it.
 type zipBaseFileNode struct {
-	fsys    *FS       // Pointer to our filesystem.
-	inode   uint64    // Inode within our filesystem.
-	archive string    // Path of the underlying ZIP archive (= parent).
-	path    string    // Path of the file inside the underlying ZIP file.
-	size    uint64    // Size of the file inside the underlying ZIP file.
-	mtime   time.Time // Modified time of the file inside the underlying ZIP file.
+	readOnlyNode // Rejects all modifications (with EROFS).
+
+	fsys     *FS         // Pointer to our filesystem.
+	inode    uint64      // Inode within our filesystem.
+	archive  string      // Path of the underlying ZIP archive (= parent).
+	path     string      // Path of the file inside the underlying ZIP file.
+	method   uint16      // Compression method of the file inside the underlying ZIP file.
+	flags    uint16      // General purpose bit flags of the file inside the underlying ZIP file (e.g. encrypted).
+	size     uint64      // Size of the file inside the underlying ZIP file.
+	csize    uint64      // Compressed size of the file inside the underlying ZIP file.
+	csizeFix bool        // Whether the compressed size is reported as the size (see SizeMode).
+	crc32    uint32      // CRC-32 of the file inside the underlying ZIP file (as stored).
+	mtime    time.Time   // Modified time of the file inside the underlying ZIP file.
+	atime    time.Time   // Access time of the file inside the underlying ZIP file (if known).
+	uid      uint32      // Owner UID of the file inside the underlying ZIP file (if known).
+	gid      uint32      // Owner GID of the file inside the underlying ZIP file (if known).
+	hasOwner bool        // Whether the owner UID/GID are known from the underlying ZIP file.
+	perm     os.FileMode // Permission bits of the file inside the underlying ZIP file (if regular).
 }
 
 func (z *zipBaseFileNode) Attr(_ context.Context, a *fuse.Attr) error {
 	a.Mode = fileBasePerm
 	a.Inode = z.inode
 
+	if z.fsys.Options.PreservePermissions {
+		a.Mode |= z.perm & fileExecPerm
+	}
+
 	a.Size = z.size
+	if z.csizeFix {
+		a.Size = z.csize
+	}
+
+	mtime := z.fsys.attrTime(z.mtime)
+
+	atime := mtime
+	if !z.atime.IsZero() {
+		atime = z.fsys.attrTime(z.atime)
+	}
+
+	a.Atime = atime
+	a.Ctime = mtime
+	a.Mtime = mtime
+
+	if z.hasOwner && z.fsys.Options.PreserveOwnership {
+		a.Uid = z.uid
+		a.Gid = z.gid
+	}
+
+	return nil
+}
+
+// EntryCRC32 returns the CRC-32 of a file within an archive (as stored within
+// the central directory) for a node as visited by [FS.Walk], e.g. for comparing
+// files without extracting them. It returns false for all other nodes.
+func EntryCRC32(node fs.Node) (uint32, bool) {
+	if z, ok := node.(interface{ base() *zipBaseFileNode }); ok {
+		return z.base().crc32, true
+	}
+
+	return 0, false
+}
+
+// base returns the [zipBaseFileNode] (as embedded into the file nodes).
+func (z *zipBaseFileNode) base() *zipBaseFileNode {
+	return z
+}
+
+// getxattr returns the [modeXattr] of a file node as the given reader mode
+// (as decided on lookup, or none if empty), and the [archiveXattr] and
+// [entryXattr] (with [Options.ArchiveXattrs]), with any others not existing.
+func (z *zipBaseFileNode) getxattr(ctx context.Context, req *fuse.GetxattrRequest, resp *fuse.GetxattrResponse, mode string) error {
+	if err := z.fsys.checkAccess(ctx); err != nil {
+		return err
+	}
 
-	a.Atime = z.mtime
-	a.Ctime = z.mtime
-	a.Mtime = z.mtime
+	switch {
+	case req.Name == modeXattr && mode != "":
+		resp.Xattr = []byte(mode)
+	case req.Name == archiveXattr && z.fsys.Options.ArchiveXattrs:
+		resp.Xattr = []byte(z.archive)
+	case req.Name == entryXattr && z.fsys.Options.ArchiveXattrs:
+		resp.Xattr = []byte(z.path)
+	default:
+		return fuse.ErrNoXattr
+	}
+
+	return nil
+}
+
+// listxattr lists the extended attributes of a file node (see [zipBaseFileNode.getxattr]).
+func (z *zipBaseFileNode) listxattr(ctx context.Context, resp *fuse.ListxattrResponse, mode string) error {
+	if err := z.fsys.checkAccess(ctx); err != nil {
+		return err
+	}
+
+	if mode != "" {
+		resp.Append(modeXattr)
+	}
+	if z.fsys.Options.ArchiveXattrs {
+		resp.Append(archiveXattr, entryXattr)
+	}
 
 	return nil
 }
@@ -44,6 +169,8 @@ func (z *zipBaseFileNode) Attr(_ context.Context, a *fuse.Attr) error {
 var (
 	_ fs.Node            = (*zipInMemoryFileNode)(nil)
 	_ fs.NodeOpener      = (*zipInMemoryFileNode)(nil)
+	_ fs.NodeGetxattrer  = (*zipInMemoryFileNode)(nil)
+	_ fs.NodeListxattrer = (*zipInMemoryFileNode)(nil)
 	_ fs.HandleReadAller = (*zipInMemoryFileNode)(nil)
 )
 
@@ -53,42 +180,171 @@ type zipInMemoryFileNode struct {
 	*zipBaseFileNode
 }
 
-func (z *zipInMemoryFileNode) Open(_ context.Context, _ *fuse.OpenRequest, resp *fuse.OpenResponse) (fs.Handle, error) {
-	if !z.fsys.Options.StrictCache {
+func (z *zipInMemoryFileNode) Open(ctx context.Context, req *fuse.OpenRequest, resp *fuse.OpenResponse) (fs.Handle, error) {
+	z.fsys.Metrics.TotalOpens.Add(1)
+
+	if !req.Flags.IsReadOnly() {
+		return nil, fuse.ToErrno(syscall.EROFS)
+	}
+
+	if err := z.fsys.checkAccess(ctx); err != nil {
+		return nil, err
+	}
+
+	if z.fsys.Options.MetadataOnly {
+		return nil, fuse.ToErrno(syscall.EACCES)
+	}
+
+	if err := z.fsys.checkMethod(z.archive, z.path, z.method); err != nil {
+		return nil, z.fsys.countError(toFuseErr(err))
+	}
+
+	if err := z.fsys.checkEncrypted(z.archive, z.path, z.flags); err != nil {
+		return nil, z.fsys.countError(toFuseErr(err))
+	}
+
+	budgeted := z.fsys.Options.MaxTotalInMemoryBytes > 0
+	if budgeted && !z.fsys.reserveInMemory(z.size) {
+		// Would exceed the total in-memory limit, so stream it instead.
+		z.fsys.Metrics.TotalInMemoryFallbacks.Add(1)
+
+		return (&zipDiskStreamFileNode{z.zipBaseFileNode}).open(ctx, req, resp)
+	}
+
+	if z.csizeFix {
+		// The kernel would not read beyond the (lower) reported size otherwise.
+		resp.Flags |= fuse.OpenDirectIO
+	} else if z.fsys.cacheFileContent() {
 		resp.Flags |= fuse.OpenKeepCache
 	}
 
+	if budgeted {
+		return &zipInMemoryFileHandle{zipInMemoryFileNode: z}, nil
+	}
+
 	return z, nil
 }
 
 func (z *zipInMemoryFileNode) ReadAll(_ context.Context) ([]byte, error) {
+	defer z.fsys.active()()
+	z.fsys.Metrics.TotalReads.Add(1)
+
+	if z.fsys.Options.MetadataOnly {
+		return nil, fuse.ToErrno(syscall.EACCES)
+	}
+
+	if z.size > math.MaxInt {
+		// Cannot be held in memory at once (32-bit), should have been streamed.
+		z.fsys.rbuf.Printf("Error: %q->ReadAll->%q: %d bytes exceed the addressable memory\n", z.archive, z.path, z.size)
+
+		return nil, z.fsys.countError(wrapFuseErr(syscall.EOVERFLOW, errSizeOverflow))
+	}
+
+	if limit := z.fsys.Options.MaxInMemoryBytes; limit > 0 && z.size > limit {
+		// Would exhaust the memory, the streaming threshold is likely too high.
+		z.fsys.rbuf.Printf("Error: %q->ReadAll->%q: %d bytes exceed the in-memory limit of %d bytes "+
+			"(lower the streaming threshold or threshold rules to stream it)\n", z.archive, z.path, z.size, limit)
+
+		return nil, z.fsys.countError(wrapFuseErr(syscall.EFBIG, errSizeInMemory))
+	}
+
 	m := newZipMetric(z.fsys, true)
+	m.Trace("readall", z.archive, z.path)
 	defer m.Done()
 
-	zr, fr, err := z.fsys.fdcache.Entry(z.archive, z.path)
+	zr, fr, res, err := z.fsys.fdcache.entry(z.archive, z.path)
+	m.Cached(res)
 	if err != nil {
-		z.fsys.rbuf.Printf("Error: %q->ReadAll->%q: ZIP Error: %v\n", z.archive, z.path, err)
+		if errors.Is(err, ErrEncryptedEntry) || errors.Is(err, errArchivesPaused) {
+			return nil, z.fsys.countError(toFuseErr(err))
+		}
+		if !errors.Is(err, errArchiveTripped) {
+			z.fsys.rbuf.Printf("Error: %q->ReadAll->%q: ZIP Error: %v\n", z.archive, z.path, err)
+		}
 
-		return nil, z.fsys.countError(toFuseErr(syscall.EINVAL))
+		return nil, z.fsys.countError(wrapFuseErr(syscall.EINVAL, err))
 	}
 	defer zr.Release() //nolint:errcheck
 	defer fr.Close()
 
-	data, err := io.ReadAll(fr)
+	// We already know the size, so we can avoid the growing [io.ReadAll].
+	data := make([]byte, z.size)
+
+	n, err := io.ReadFull(fr, data)
+	if err == nil {
+		// The CRC-32 and size are only verified when reading up to EOF.
+		if _, err = io.ReadFull(fr, make([]byte, 1)); err == nil {
+			err = fmt.Errorf("%w: size exceeds %d bytes", ErrCorruptEntry, z.size)
+		} else if errors.Is(err, io.EOF) {
+			err = nil
+		}
+	}
 	if err != nil {
 		z.fsys.rbuf.Printf("Error: %q->ReadAll->%q: IO Error: %v\n", z.archive, z.path, err)
 
-		return nil, z.fsys.countError(toFuseErr(syscall.EIO))
+		return nil, z.fsys.countError(wrapFuseErr(syscall.EIO, err))
 	}
 
-	m.readBytes = int64(len(data))
+	m.readBytes = int64(n)
+	m.compBytes = compressedBytes(fr.f, int64(n))
 
 	return data, nil
 }
 
+// Getxattr returns the [modeXattr] as "memory" (see [zipBaseFileNode.getxattr]).
+func (z *zipInMemoryFileNode) Getxattr(ctx context.Context, req *fuse.GetxattrRequest, resp *fuse.GetxattrResponse) error {
+	return z.getxattr(ctx, req, resp, modeMemory)
+}
+
+func (z *zipInMemoryFileNode) Listxattr(ctx context.Context, _ *fuse.ListxattrRequest, resp *fuse.ListxattrResponse) error {
+	return z.listxattr(ctx, resp, modeMemory)
+}
+
+var (
+	_ fs.HandleReadAller = (*zipInMemoryFileHandle)(nil)
+	_ fs.HandleReleaser  = (*zipInMemoryFileHandle)(nil)
+)
+
+// zipInMemoryFileHandle is a [fs.Handle] returned when opening a
+// [zipInMemoryFileNode] with [Options.MaxTotalInMemoryBytes]. Its size was
+// reserved on opening, which the implemented [fs.HandleReleaser] releases,
+// once the read data (as held for the handle) is no longer needed.
+type zipInMemoryFileHandle struct {
+	*zipInMemoryFileNode
+}
+
+func (h *zipInMemoryFileHandle) Release(_ context.Context, _ *fuse.ReleaseRequest) error {
+	h.fsys.releaseInMemory(h.size)
+
+	return nil
+}
+
+// reserveInMemory reserves the size of a file to be held in RAM, returning
+// false if it would exceed [Options.MaxTotalInMemoryBytes] (reserving nothing).
+func (fsys *FS) reserveInMemory(size uint64) bool {
+	limit := fsys.Options.MaxTotalInMemoryBytes
+
+	for {
+		used := fsys.Metrics.InMemoryBytes.Load()
+		if size > limit || uint64(used) > limit-size {
+			return false
+		}
+		if fsys.Metrics.InMemoryBytes.CompareAndSwap(used, used+int64(size)) {
+			return true
+		}
+	}
+}
+
+// releaseInMemory releases the size of a file as reserved by reserveInMemory().
+func (fsys *FS) releaseInMemory(size uint64) {
+	fsys.Metrics.InMemoryBytes.Add(-int64(size))
+}
+
 var (
-	_ fs.Node       = (*zipDiskStreamFileNode)(nil)
-	_ fs.NodeOpener = (*zipDiskStreamFileNode)(nil)
+	_ fs.Node            = (*zipDiskStreamFileNode)(nil)
+	_ fs.NodeOpener      = (*zipDiskStreamFileNode)(nil)
+	_ fs.NodeGetxattrer  = (*zipDiskStreamFileNode)(nil)
+	_ fs.NodeListxattrer = (*zipDiskStreamFileNode)(nil)
 )
 
 // zipDiskStreamFileNode is a [zipBaseFileNode] that opens to a
@@ -97,15 +353,51 @@ type zipDiskStreamFileNode struct {
 	*zipBaseFileNode
 }
 
-func (z *zipDiskStreamFileNode) Open(_ context.Context, _ *fuse.OpenRequest, resp *fuse.OpenResponse) (fs.Handle, error) {
+func (z *zipDiskStreamFileNode) Open(ctx context.Context, req *fuse.OpenRequest, resp *fuse.OpenResponse) (fs.Handle, error) {
+	z.fsys.Metrics.TotalOpens.Add(1)
+
+	return z.open(ctx, req, resp)
+}
+
+// open is Open(), but without counting it (for the fallback of a
+// [zipInMemoryFileNode], which already counted it as its own open).
+func (z *zipDiskStreamFileNode) open(ctx context.Context, req *fuse.OpenRequest, resp *fuse.OpenResponse) (fs.Handle, error) {
+	if !req.Flags.IsReadOnly() {
+		return nil, fuse.ToErrno(syscall.EROFS)
+	}
+
+	if err := z.fsys.checkAccess(ctx); err != nil {
+		return nil, err
+	}
+
+	if z.fsys.Options.MetadataOnly {
+		return nil, fuse.ToErrno(syscall.EACCES)
+	}
+
+	if err := z.fsys.checkMethod(z.archive, z.path, z.method); err != nil {
+		return nil, z.fsys.countError(toFuseErr(err))
+	}
+
+	if err := z.fsys.checkEncrypted(z.archive, z.path, z.flags); err != nil {
+		return nil, z.fsys.countError(toFuseErr(err))
+	}
+
 	zr, fr, err := z.fsys.fdcache.Entry(z.archive, z.path)
 	if err != nil {
-		z.fsys.rbuf.Printf("Error: %q->Open->%q: ZIP Error: %v\n", z.archive, z.path, err)
+		if errors.Is(err, ErrEncryptedEntry) || errors.Is(err, errArchivesPaused) {
+			return nil, z.fsys.countError(toFuseErr(err))
+		}
+		if !errors.Is(err, errArchiveTripped) {
+			z.fsys.rbuf.Printf("Error: %q->Open->%q: ZIP Error: %v\n", z.archive, z.path, err)
+		}
 
-		return nil, z.fsys.countError(toFuseErr(syscall.EINVAL))
+		return nil, z.fsys.countError(wrapFuseErr(syscall.EINVAL, err))
 	}
 
-	if !z.fsys.Options.StrictCache {
+	if z.csizeFix {
+		// The kernel would not read beyond the (lower) reported size otherwise.
+		resp.Flags |= fuse.OpenDirectIO
+	} else if z.fsys.cacheFileContent() {
 		resp.Flags |= fuse.OpenKeepCache
 	}
 
@@ -119,6 +411,15 @@ func (z *zipDiskStreamFileNode) Open(_ context.Context, _ *fuse.OpenRequest, res
 	}, nil
 }
 
+// Getxattr returns the [modeXattr] as "stream" (see [zipBaseFileNode.getxattr]).
+func (z *zipDiskStreamFileNode) Getxattr(ctx context.Context, req *fuse.GetxattrRequest, resp *fuse.GetxattrResponse) error {
+	return z.getxattr(ctx, req, resp, modeStream)
+}
+
+func (z *zipDiskStreamFileNode) Listxattr(ctx context.Context, _ *fuse.ListxattrRequest, resp *fuse.ListxattrResponse) error {
+	return z.listxattr(ctx, resp, modeStream)
+}
+
 var (
 	_ fs.HandleReader   = (*zipDiskStreamFileHandle)(nil)
 	_ fs.HandleReleaser = (*zipDiskStreamFileHandle)(nil)
@@ -141,49 +442,20 @@ type zipDiskStreamFileHandle struct {
 }
 
 func (h *zipDiskStreamFileHandle) Read(_ context.Context, req *fuse.ReadRequest, resp *fuse.ReadResponse) error {
+	defer h.fsys.active()()
+	h.fsys.Metrics.TotalReads.Add(1)
+
+	if h.fsys.Options.MetadataOnly {
+		return fuse.ToErrno(syscall.EACCES)
+	}
+
 	h.Lock()
 	defer h.Unlock()
 
 	m := newZipMetric(h.fsys, true)
+	m.Trace("read", h.archive, h.path)
 	defer m.Done()
 
-	if req.Offset != h.offset {
-		n, err := h.fr.ForwardTo(req.Offset)
-		h.offset = n
-		switch {
-		case errors.Is(err, errNonSeekableRewind):
-			f := h.fr.f      // Save first the [zip.File] for re-use
-			_ = h.fr.Close() // Close now the failed [zipFileReader]
-
-			// Reopening the entry will start with offset zero, so the
-			// pseudo-seek should always succeed even if it's a rewind.
-			// Re-use of the [zipReader] and [zip.File] saves overhead.
-			rc, err := newZipFileReader(h.fsys, f)
-			if err != nil {
-				h.fsys.rbuf.Printf("Error: %q->Read->%q: ZIP Error: %v\n", h.archive, h.path, err)
-
-				return h.fsys.countError(toFuseErr(syscall.EINVAL))
-			}
-			h.fr = rc
-			h.offset = 0
-			h.fsys.Metrics.TotalStreamRewinds.Add(1)
-
-			// Retry the forward... if it fails again, return the error.
-			n, err = h.fr.ForwardTo(req.Offset)
-			h.offset = n
-			if err != nil {
-				h.fsys.rbuf.Printf("Error: %q->Read->%q: Seek Error: %v\n", h.archive, h.path, err)
-
-				return h.fsys.countError(toFuseErr(syscall.EIO))
-			}
-
-		case err != nil:
-			h.fsys.rbuf.Printf("Error: %q->Read->%q: Seek Error: %v\n", h.archive, h.path, err)
-
-			return h.fsys.countError(toFuseErr(syscall.EIO))
-		}
-	}
-
 	rawBuf := h.fsys.bufpool.Get()
 	pBuf, ok := rawBuf.(*[]byte)
 	if !ok {
@@ -212,13 +484,47 @@ func (h *zipDiskStreamFileHandle) Read(_ context.Context, req *fuse.ReadRequest,
 
 	buf = buf[:req.Size]
 
-	n, err := io.ReadFull(h.fr, buf)
-	h.offset += int64(n)
+	n, err := h.readAt(buf, req.Offset)
+	stale := h.isStale(err)
+	for retry := 1; err != nil && !stale && h.isTransient(err) && retry <= h.fsys.Options.StreamRetries; retry++ {
+		h.fsys.Metrics.TotalStreamRetries.Add(1)
+		h.fsys.rbuf.Printf("Warning: %q->Read->%q: retrying (%d/%d) after transient error: %v\n",
+			h.archive, h.path, retry, h.fsys.Options.StreamRetries, err)
+
+		if err := h.reopen(); err != nil {
+			h.fsys.rbuf.Printf("Error: %q->Read->%q: ZIP Error: %v\n", h.archive, h.path, err)
+
+			return h.fsys.countError(wrapFuseErr(syscall.EINVAL, err))
+		}
+
+		n, err = h.readAt(buf, req.Offset)
+		stale = h.isStale(err)
+	}
+
 	m.readBytes = int64(n)
-	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+	m.compBytes = compressedBytes(h.fr.f, int64(n))
+
+	switch {
+	case stale:
+		h.fsys.Metrics.TotalStaleReads.Add(1)
+		h.fsys.rbuf.Printf("Error: %q->Read->%q: Stale Error: %v (%v)\n", h.archive, h.path, errStreamStale, err)
+
+		return h.fsys.countError(wrapFuseErr(syscall.ESTALE, fmt.Errorf("%w: %w", errStreamStale, err)))
+
+	case errors.Is(err, errStreamReopen):
+		h.fsys.rbuf.Printf("Error: %q->Read->%q: ZIP Error: %v\n", h.archive, h.path, err)
+
+		return h.fsys.countError(wrapFuseErr(syscall.EINVAL, err))
+
+	case errors.Is(err, errStreamSeek):
+		h.fsys.rbuf.Printf("Error: %q->Read->%q: Seek Error: %v\n", h.archive, h.path, err)
+
+		return h.fsys.countError(wrapFuseErr(syscall.EIO, err))
+
+	case err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF):
 		h.fsys.rbuf.Printf("Error: %q->Read->%q: IO Error: %v\n", h.archive, h.path, err)
 
-		return h.fsys.countError(toFuseErr(syscall.EIO))
+		return h.fsys.countError(wrapFuseErr(syscall.EIO, err))
 	}
 
 	// The kernel owns the data buffer, so we hand it a copy of ours here.
@@ -227,6 +533,93 @@ func (h *zipDiskStreamFileHandle) Read(_ context.Context, req *fuse.ReadRequest,
 	return nil
 }
 
+// readAt forwards the [zipFileReader] to the offset (re-opening the entry
+// for a rewind of a non-seekable file) and reads the buffer from there on.
+// Errors of forwarding are wrapped with [errStreamSeek] (or [errStreamReopen]).
+func (h *zipDiskStreamFileHandle) readAt(buf []byte, offset int64) (int, error) {
+	if offset != h.offset {
+		n, err := h.fr.ForwardTo(offset)
+		h.offset = n
+		switch {
+		case errors.Is(err, errNonSeekableRewind):
+			// Reopening the entry will start with offset zero, so the
+			// pseudo-seek should always succeed even if it's a rewind.
+			if err := h.reopen(); err != nil {
+				return 0, err
+			}
+			h.fsys.Metrics.TotalStreamRewinds.Add(1)
+
+			// Retry the forward... if it fails again, return the error.
+			n, err = h.fr.ForwardTo(offset)
+			h.offset = n
+			if err != nil {
+				return 0, fmt.Errorf("%w: %w", errStreamSeek, err)
+			}
+
+		case err != nil:
+			return 0, fmt.Errorf("%w: %w", errStreamSeek, err)
+		}
+	}
+
+	n, err := io.ReadFull(h.fr, buf)
+	h.offset += int64(n)
+
+	return n, err //nolint:wrapcheck
+}
+
+// reopen closes the [zipFileReader] and re-opens the entry at offset zero.
+// Re-use of the [zipReader] and [zip.File] saves on the overhead of this.
+func (h *zipDiskStreamFileHandle) reopen() error {
+	f := h.fr.f      // Save first the [zip.File] for re-use
+	_ = h.fr.Close() // Close now the failed [zipFileReader]
+
+	rc, err := newZipFileReader(h.fsys, h.archive, f)
+	if err != nil {
+		return fmt.Errorf("%w: %w", errStreamReopen, err)
+	}
+	h.fr = rc
+	h.offset = 0
+
+	return nil
+}
+
+// isTransient returns if an error of readAt() is likely transient (e.g. on a
+// flaky network filesystem), so that the read can be retried after reopen().
+// Corrupt entries and the regular end of the entry are never transient, but a
+// premature end (before the entry's size) is, as the read was likely cut off.
+func (h *zipDiskStreamFileHandle) isTransient(err error) bool {
+	switch {
+	case errors.Is(err, ErrCorruptEntry), errors.Is(err, errStreamReopen), errors.Is(err, io.EOF):
+		return false
+
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return uint64(h.offset) < h.fr.f.UncompressedSize64
+
+	default:
+		return errors.Is(err, syscall.ESTALE) || errors.Is(err, syscall.EIO) ||
+			errors.Is(err, syscall.EAGAIN) || errors.Is(err, syscall.EINTR) ||
+			errors.Is(err, syscall.ETIMEDOUT) || errors.Is(err, syscall.ECONNRESET)
+	}
+}
+
+// isStale returns if an error of readAt() is due to the archive having changed
+// (as compared to when it was opened) while the handle was open, with the offsets
+// of the entry within it no longer valid, which is only checked with
+// [Options.StrictCache] (otherwise archives are considered immutable).
+// The regular end of the entry (at or beyond its size) is never stale.
+func (h *zipDiskStreamFileHandle) isStale(err error) bool {
+	if err == nil || !h.fsys.Options.StrictCache {
+		return false
+	}
+
+	if (errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)) &&
+		uint64(h.offset) >= h.fr.f.UncompressedSize64 {
+		return false
+	}
+
+	return h.zr.Stale(h.archive)
+}
+
 func (h *zipDiskStreamFileHandle) Release(_ context.Context, _ *fuse.ReleaseRequest) error {
 	h.Lock()
 	defer h.Unlock()
@@ -242,3 +635,141 @@ func (h *zipDiskStreamFileHandle) Release(_ context.Context, _ *fuse.ReleaseRequ
 
 	return nil
 }
+
+var (
+	_ fs.Node            = (*zipRawFileNode)(nil)
+	_ fs.NodeOpener      = (*zipRawFileNode)(nil)
+	_ fs.NodeGetxattrer  = (*zipRawFileNode)(nil)
+	_ fs.NodeListxattrer = (*zipRawFileNode)(nil)
+)
+
+// zipRawFileNode is a [zipBaseFileNode] within the [rawDir], which opens to
+// a [zipRawFileHandle] for reading the raw (compressed) bytes of the file.
+// Its size is the compressed size, with the bytes specific to the method
+// of compression (e.g. deflate), which are never decompressed or verified.
+type zipRawFileNode struct {
+	*zipBaseFileNode
+}
+
+func (z *zipRawFileNode) Open(ctx context.Context, req *fuse.OpenRequest, resp *fuse.OpenResponse) (fs.Handle, error) {
+	z.fsys.Metrics.TotalOpens.Add(1)
+
+	if !req.Flags.IsReadOnly() {
+		return nil, fuse.ToErrno(syscall.EROFS)
+	}
+
+	if err := z.fsys.checkAccess(ctx); err != nil {
+		return nil, err
+	}
+
+	if z.fsys.Options.MetadataOnly {
+		return nil, fuse.ToErrno(syscall.EACCES)
+	}
+
+	zr, err := z.fsys.fdcache.Archive(z.archive)
+	if err != nil {
+		if errors.Is(err, errArchivesPaused) {
+			return nil, z.fsys.countError(toFuseErr(err))
+		}
+		if !errors.Is(err, errArchiveTripped) {
+			z.fsys.rbuf.Printf("Error: %q->Open->%q: ZIP Error: %v\n", z.archive, z.path, err)
+		}
+
+		return nil, z.fsys.countError(wrapFuseErr(syscall.EINVAL, err))
+	}
+
+	for _, f := range zr.File {
+		if f.Name != z.path {
+			continue
+		}
+
+		r, err := f.OpenRaw()
+		if err != nil {
+			_ = zr.Release()
+			z.fsys.rbuf.Printf("Error: %q->Open->%q: ZIP Error: %v\n", z.archive, z.path, err)
+
+			return nil, z.fsys.countError(wrapFuseErr(syscall.EINVAL, fmt.Errorf("%w: failed to open: %w", ErrCorruptEntry, err)))
+		}
+
+		ra, ok := r.(io.ReaderAt)
+		if !ok {
+			panic("zipRawFileNode: received unexpected type from OpenRaw")
+		}
+
+		if z.fsys.cacheFileContent() {
+			resp.Flags |= fuse.OpenKeepCache
+		}
+
+		return &zipRawFileHandle{
+			fsys:    z.fsys,
+			archive: z.archive,
+			path:    z.path,
+			zr:      zr,
+			r:       ra,
+		}, nil
+	}
+
+	_ = zr.Release()
+
+	return nil, toFuseErr(fmt.Errorf("%w: %s", ErrEntryNotFound, z.path))
+}
+
+// Getxattr returns the extended attributes of a raw file, which has no
+// [modeXattr] as it is never decompressed (see [zipBaseFileNode.getxattr]).
+func (z *zipRawFileNode) Getxattr(ctx context.Context, req *fuse.GetxattrRequest, resp *fuse.GetxattrResponse) error {
+	return z.getxattr(ctx, req, resp, "")
+}
+
+func (z *zipRawFileNode) Listxattr(ctx context.Context, _ *fuse.ListxattrRequest, resp *fuse.ListxattrResponse) error {
+	return z.listxattr(ctx, resp, "")
+}
+
+var (
+	_ fs.HandleReader   = (*zipRawFileHandle)(nil)
+	_ fs.HandleReleaser = (*zipRawFileHandle)(nil)
+)
+
+// zipRawFileHandle is a [fs.Handle] returned when opening a [zipRawFileNode].
+// As the raw bytes are read at their offset within the archive, no forwarding
+// (or rewinding) is needed and concurrent reads are possible without locking.
+type zipRawFileHandle struct {
+	fsys    *FS
+	archive string
+	path    string
+
+	zr *zipReader
+	r  io.ReaderAt
+}
+
+func (h *zipRawFileHandle) Read(_ context.Context, req *fuse.ReadRequest, resp *fuse.ReadResponse) error {
+	defer h.fsys.active()()
+	h.fsys.Metrics.TotalReads.Add(1)
+
+	if h.fsys.Options.MetadataOnly {
+		return fuse.ToErrno(syscall.EACCES)
+	}
+
+	m := newZipMetric(h.fsys, false)
+	m.Trace("readraw", h.archive, h.path)
+	defer m.Done()
+
+	buf := make([]byte, req.Size)
+
+	n, err := h.r.ReadAt(buf, req.Offset)
+	m.readBytes = int64(n)
+	if err != nil && !errors.Is(err, io.EOF) {
+		h.fsys.rbuf.Printf("Error: %q->Read->%q: IO Error: %v\n", h.archive, h.path, err)
+
+		return h.fsys.countError(wrapFuseErr(syscall.EIO, err))
+	}
+
+	resp.Data = buf[:n]
+
+	return nil
+}
+
+func (h *zipRawFileHandle) Release(_ context.Context, _ *fuse.ReleaseRequest) error {
+	_ = h.zr.Release()
+
+	return nil
+}
@@ -0,0 +1,49 @@
+//go:build 386 || arm || mips || mipsle
+
+package filesystem
+
+import (
+	"bytes"
+	"io"
+	"math"
+	"syscall"
+	"testing"
+
+	"bazil.org/fuse"
+	"github.com/stretchr/testify/require"
+)
+
+// Expectation: streamEntry should always stream files exceeding the addressable memory.
+func Test_FS_streamEntry_Overflow_Success(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	_, fsys := testFS(t, &buf)
+
+	fsys.Options.StreamingThreshold.Store(math.MaxUint64)
+	fsys.Options.ThresholdRules = []ThresholdRule{
+		{Extensions: []string{"bin"}, Mode: ThresholdModeMemory},
+	}
+
+	require.False(t, fsys.streamEntry("test.zip", "small.bin", math.MaxInt))
+	require.True(t, fsys.streamEntry("test.zip", "large.bin", math.MaxInt+1))
+	require.True(t, fsys.streamEntry("test.zip", "large.dat", math.MaxInt+1))
+	require.Contains(t, buf.String(), "exceed the addressable memory")
+}
+
+// Expectation: ReadAll should return EOVERFLOW for files exceeding the addressable memory.
+func Test_zipInMemoryFileNode_ReadAll_Overflow_Error(t *testing.T) {
+	t.Parallel()
+	_, fsys := testFS(t, io.Discard)
+
+	node := &zipInMemoryFileNode{&zipBaseFileNode{
+		fsys:    fsys,
+		archive: "/nonexistent.zip",
+		path:    "large.bin",
+		size:    math.MaxInt + 1,
+	}}
+
+	_, err := node.ReadAll(t.Context())
+	require.ErrorIs(t, err, fuse.ToErrno(syscall.EOVERFLOW))
+	require.Equal(t, int64(1), fsys.Metrics.Errors.Load())
+}
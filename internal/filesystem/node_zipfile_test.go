@@ -4,8 +4,10 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"hash/crc32"
 	"io"
 	"os"
+	"path/filepath"
 	"strconv"
 	"sync"
 	"syscall"
@@ -14,6 +16,8 @@ import (
 
 	"bazil.org/fuse"
 	"bazil.org/fuse/fs"
+	"github.com/klauspost/compress/flate"
+	"github.com/klauspost/compress/zip"
 	"github.com/stretchr/testify/require"
 )
 
@@ -44,6 +48,158 @@ func Test_zipBaseFileNode_Attr_Success(t *testing.T) {
 	require.Equal(t, tnow, attr.Mtime)
 }
 
+// Expectation: EntryCRC32 should return the CRC-32 of files within archives only.
+func Test_EntryCRC32_Success(t *testing.T) {
+	t.Parallel()
+	tmpDir, fsys := testFS(t, io.Discard)
+
+	content := []byte("content")
+	zipPath := createTestZip(t, tmpDir, "test.zip", []struct {
+		Path    string
+		ModTime time.Time
+		Content []byte
+	}{
+		{Path: "file.txt", ModTime: time.Now(), Content: content},
+	})
+
+	node := &zipDirNode{fsys: fsys, inode: fs.GenerateDynamicInode(1, "test"), path: zipPath, mtime: time.Now()}
+
+	_, ok := EntryCRC32(node)
+	require.False(t, ok)
+
+	fn, err := node.Lookup(t.Context(), "file.txt")
+	require.NoError(t, err)
+
+	crc, ok := EntryCRC32(fn)
+	require.True(t, ok)
+	require.Equal(t, crc32.ChecksumIEEE(content), crc)
+}
+
+// Expectation: The mode xattr should report whether a file is served in-memory
+// or streamed (as by the streaming threshold), with no other xattrs existing.
+func Test_zipFileNodes_Getxattr_Mode_Success(t *testing.T) {
+	t.Parallel()
+	tmpDir, fsys := testFS(t, io.Discard)
+	fsys.Options.StreamingThreshold.Store(10)
+
+	zipPath := createTestZip(t, tmpDir, "test.zip", []struct {
+		Path    string
+		ModTime time.Time
+		Content []byte
+	}{
+		{Path: "small.txt", ModTime: time.Now(), Content: []byte("small")},
+		{Path: "large.txt", ModTime: time.Now(), Content: []byte("larger than the threshold")},
+	})
+
+	node := &zipDirNode{fsys: fsys, inode: fs.GenerateDynamicInode(1, "test"), path: zipPath, mtime: time.Now()}
+
+	for name, mode := range map[string]string{"small.txt": "memory", "large.txt": "stream"} {
+		fn, err := node.Lookup(t.Context(), name)
+		require.NoError(t, err)
+
+		getter, ok := fn.(fs.NodeGetxattrer)
+		require.True(t, ok)
+
+		resp := &fuse.GetxattrResponse{}
+		require.NoError(t, getter.Getxattr(t.Context(), &fuse.GetxattrRequest{Name: modeXattr}, resp))
+		require.Equal(t, mode, string(resp.Xattr))
+
+		err = getter.Getxattr(t.Context(), &fuse.GetxattrRequest{Name: "user.other"}, &fuse.GetxattrResponse{})
+		require.Equal(t, fuse.ErrNoXattr, err)
+
+		lister, ok := fn.(fs.NodeListxattrer)
+		require.True(t, ok)
+
+		list := &fuse.ListxattrResponse{}
+		require.NoError(t, lister.Listxattr(t.Context(), &fuse.ListxattrRequest{}, list))
+		require.Equal(t, modeXattr+"\x00", string(list.Xattr))
+	}
+}
+
+// Expectation: With ArchiveXattrs, files (also raw ones) should have the
+// backing archive and their path within it as extended attributes.
+func Test_zipFileNodes_Getxattr_Archive_Success(t *testing.T) {
+	t.Parallel()
+	tmpDir, fsys := testFS(t, io.Discard)
+	fsys.Options.ArchiveXattrs = true
+	fsys.Options.ExposeRaw = true
+
+	zipPath := createTestZip(t, tmpDir, "test.zip", []struct {
+		Path    string
+		ModTime time.Time
+		Content []byte
+	}{
+		{Path: "docs/file.txt", ModTime: time.Now(), Content: []byte("content")},
+	})
+
+	node := &zipDirNode{fsys: fsys, inode: fs.GenerateDynamicInode(1, "test"), path: zipPath, mtime: time.Now()}
+
+	for _, dir := range []string{"docs", rawDir} {
+		dn, err := node.Lookup(t.Context(), dir)
+		require.NoError(t, err)
+
+		if dir == rawDir {
+			dn, err = dn.(*zipDirNode).Lookup(t.Context(), "docs")
+			require.NoError(t, err)
+		}
+
+		fn, err := dn.(*zipDirNode).Lookup(t.Context(), "file.txt")
+		require.NoError(t, err)
+
+		getter, ok := fn.(fs.NodeGetxattrer)
+		require.True(t, ok, dir)
+
+		resp := &fuse.GetxattrResponse{}
+		require.NoError(t, getter.Getxattr(t.Context(), &fuse.GetxattrRequest{Name: archiveXattr}, resp))
+		require.Equal(t, zipPath, string(resp.Xattr))
+
+		resp = &fuse.GetxattrResponse{}
+		require.NoError(t, getter.Getxattr(t.Context(), &fuse.GetxattrRequest{Name: entryXattr}, resp))
+		require.Equal(t, "docs/file.txt", string(resp.Xattr))
+
+		lister, ok := fn.(fs.NodeListxattrer)
+		require.True(t, ok, dir)
+
+		list := &fuse.ListxattrResponse{}
+		require.NoError(t, lister.Listxattr(t.Context(), &fuse.ListxattrRequest{}, list))
+		require.Contains(t, string(list.Xattr), archiveXattr+"\x00"+entryXattr+"\x00")
+	}
+}
+
+// Expectation: Attr should report the atime and ownership (when enabled) if known.
+func Test_zipBaseFileNode_Attr_UnixExtra_Success(t *testing.T) {
+	t.Parallel()
+	_, fsys := testFS(t, io.Discard)
+	mtime := time.Date(2021, 6, 1, 10, 30, 15, 0, time.UTC)
+	atime := time.Date(2022, 7, 2, 11, 45, 30, 0, time.UTC)
+
+	node := &zipBaseFileNode{
+		fsys:     fsys,
+		inode:    fs.GenerateDynamicInode(1, "test.txt"),
+		size:     1024,
+		mtime:    mtime,
+		atime:    atime,
+		uid:      1000,
+		gid:      100,
+		hasOwner: true,
+	}
+
+	attr := fuse.Attr{}
+	require.NoError(t, node.Attr(t.Context(), &attr))
+	require.Equal(t, atime, attr.Atime)
+	require.Equal(t, mtime, attr.Ctime)
+	require.Equal(t, mtime, attr.Mtime)
+	require.Zero(t, attr.Uid)
+	require.Zero(t, attr.Gid)
+
+	fsys.Options.PreserveOwnership = true
+
+	attr = fuse.Attr{}
+	require.NoError(t, node.Attr(t.Context(), &attr))
+	require.Equal(t, uint32(1000), attr.Uid)
+	require.Equal(t, uint32(100), attr.Gid)
+}
+
 // Expectation: Open should set the caching flag and return the node itself as the handle.
 func Test_zipInMemoryFileNode_Open_Success(t *testing.T) {
 	t.Parallel()
@@ -93,6 +249,97 @@ func Test_zipInMemoryFileNode_Open_Success(t *testing.T) {
 	}
 }
 
+// Expectation: Open should reserve the size within MaxTotalInMemoryBytes until
+// released, streaming the file instead if the reservation would exceed it.
+func Test_zipInMemoryFileNode_Open_MaxTotalInMemory_Success(t *testing.T) {
+	t.Parallel()
+	tmpDir, fsys := testFS(t, io.Discard)
+	tnow := time.Now()
+
+	content := []byte("test content for in-memory node")
+	fsys.Options.MaxTotalInMemoryBytes = uint64(len(content)) + 1
+
+	zipPath := createTestZip(t, tmpDir, "test.zip", []struct {
+		Path    string
+		ModTime time.Time
+		Content []byte
+	}{
+		{Path: "test.txt", ModTime: tnow, Content: content},
+	})
+
+	node := &zipInMemoryFileNode{
+		zipBaseFileNode: &zipBaseFileNode{
+			fsys:    fsys,
+			inode:   fs.GenerateDynamicInode(1, "test.txt"),
+			archive: zipPath,
+			path:    "test.txt",
+			size:    uint64(len(content)),
+			mtime:   tnow,
+		},
+	}
+
+	handle, err := node.Open(t.Context(), &fuse.OpenRequest{}, &fuse.OpenResponse{})
+	require.NoError(t, err)
+
+	inmemHandle, ok := handle.(*zipInMemoryFileHandle)
+	require.True(t, ok)
+	require.Equal(t, int64(len(content)), fsys.Metrics.InMemoryBytes.Load())
+
+	data, err := inmemHandle.ReadAll(t.Context())
+	require.NoError(t, err)
+	require.Equal(t, content, data)
+
+	handle2, err := node.Open(t.Context(), &fuse.OpenRequest{}, &fuse.OpenResponse{})
+	require.NoError(t, err)
+
+	streamHandle, ok := handle2.(*zipDiskStreamFileHandle)
+	require.True(t, ok)
+	require.Equal(t, int64(1), fsys.Metrics.TotalInMemoryFallbacks.Load())
+	require.NoError(t, streamHandle.Release(t.Context(), &fuse.ReleaseRequest{}))
+
+	require.NoError(t, inmemHandle.Release(t.Context(), &fuse.ReleaseRequest{}))
+	require.Zero(t, fsys.Metrics.InMemoryBytes.Load())
+
+	handle3, err := node.Open(t.Context(), &fuse.OpenRequest{}, &fuse.OpenResponse{})
+	require.NoError(t, err)
+	require.IsType(t, &zipInMemoryFileHandle{}, handle3)
+}
+
+// Expectation: Open should only set the caching flag with CacheFileContent,
+// regardless of CacheDirEntries (which only concerns the directories).
+func Test_zipInMemoryFileNode_Open_CacheFileContent_Success(t *testing.T) {
+	t.Parallel()
+
+	for _, mode := range []bool{true, false} {
+		t.Run("CacheFileContent="+strconv.FormatBool(mode), func(t *testing.T) {
+			t.Parallel()
+			_, fsys := testFS(t, io.Discard)
+
+			fsys.Options.CacheDirEntries = false
+			fsys.Options.CacheFileContent = mode
+
+			node := &zipInMemoryFileNode{
+				zipBaseFileNode: &zipBaseFileNode{
+					fsys:  fsys,
+					inode: fs.GenerateDynamicInode(1, "test.txt"),
+					path:  "test.txt",
+					mtime: time.Now(),
+				},
+			}
+
+			resp := &fuse.OpenResponse{}
+			_, err := node.Open(t.Context(), &fuse.OpenRequest{}, resp)
+			require.NoError(t, err)
+
+			if mode {
+				require.NotZero(t, resp.Flags&fuse.OpenKeepCache, "OpenKeepCache flag should be set")
+			} else {
+				require.Zero(t, resp.Flags&fuse.OpenKeepCache, "OpenKeepCache flag should not be set")
+			}
+		})
+	}
+}
+
 // Expectation: ReadAll should return the complete content of the underlying file.
 func Test_zipInMemoryFileNode_ReadAll_Success(t *testing.T) {
 	t.Parallel()
@@ -125,6 +372,148 @@ func Test_zipInMemoryFileNode_ReadAll_Success(t *testing.T) {
 	require.Equal(t, content, data)
 }
 
+// Expectation: ReadAll should return a buffer pre-sized to the known file size.
+func Test_zipInMemoryFileNode_ReadAll_PreSized_Success(t *testing.T) {
+	t.Parallel()
+	tmpDir, fsys := testFS(t, io.Discard)
+	tnow := time.Now()
+
+	content := bytes.Repeat([]byte("0123456789"), 1000)
+	zipPath := createTestZip(t, tmpDir, "test.zip", []struct {
+		Path    string
+		ModTime time.Time
+		Content []byte
+	}{
+		{Path: "test.txt", ModTime: tnow, Content: content},
+	})
+
+	node := &zipInMemoryFileNode{
+		zipBaseFileNode: &zipBaseFileNode{
+			fsys:    fsys,
+			inode:   0,
+			archive: zipPath,
+			path:    "test.txt",
+			size:    uint64(len(content)),
+			mtime:   tnow,
+		},
+	}
+
+	data, err := node.ReadAll(t.Context())
+	require.NoError(t, err)
+	require.Equal(t, content, data)
+	require.Equal(t, len(content), cap(data))
+	require.Equal(t, int64(len(content)), fsys.Metrics.TotalExtractBytes.Load())
+	require.Equal(t, int64(len(content)), fsys.Metrics.TotalCompressedBytesRead.Load())
+}
+
+// Expectation: ReadAll should account for the compressed bytes of a compressed file.
+func Test_zipInMemoryFileNode_ReadAll_CompressedBytes_Success(t *testing.T) {
+	t.Parallel()
+	tmpDir, fsys := testFS(t, io.Discard)
+	tnow := time.Now()
+
+	content := bytes.Repeat([]byte("0123456789"), 1000)
+	zipPath := createTestZipMethod(t, tmpDir, "test.zip", zip.Deflate, []struct {
+		Path    string
+		ModTime time.Time
+		Content []byte
+	}{
+		{Path: "test.txt", ModTime: tnow, Content: content},
+	})
+
+	node := &zipInMemoryFileNode{
+		zipBaseFileNode: &zipBaseFileNode{
+			fsys:    fsys,
+			inode:   0,
+			archive: zipPath,
+			path:    "test.txt",
+			size:    uint64(len(content)),
+			mtime:   tnow,
+		},
+	}
+
+	data, err := node.ReadAll(t.Context())
+	require.NoError(t, err)
+	require.Equal(t, content, data)
+
+	require.Equal(t, int64(len(content)), fsys.Metrics.TotalExtractBytes.Load())
+	require.Positive(t, fsys.Metrics.TotalCompressedBytesRead.Load())
+	require.Less(t, fsys.Metrics.TotalCompressedBytesRead.Load(), int64(len(content)/10))
+}
+
+// Expectation: ReadAll should read files until their end, failing with EIO
+// if their CRC-32 or size does not match (instead of returning the bad bytes).
+func Test_zipInMemoryFileNode_ReadAll_Mismatch_Error(t *testing.T) {
+	t.Parallel()
+
+	content := []byte("content that does not match its header")
+
+	var deflated bytes.Buffer
+	fw, err := flate.NewWriter(&deflated, flate.DefaultCompression)
+	require.NoError(t, err)
+	_, err = fw.Write(content)
+	require.NoError(t, err)
+	require.NoError(t, fw.Close())
+
+	for name, tc := range map[string]struct {
+		header *zip.FileHeader
+		data   []byte
+	}{
+		"CRC32": {
+			header: &zip.FileHeader{
+				Method:             zip.Deflate,
+				CRC32:              crc32.ChecksumIEEE(content) + 1,
+				CompressedSize64:   uint64(deflated.Len()),
+				UncompressedSize64: uint64(len(content)),
+			},
+			data: deflated.Bytes(),
+		},
+		"Size": {
+			header: &zip.FileHeader{
+				Method:             zip.Store,
+				Flags:              flagDataDescriptor,
+				CRC32:              crc32.ChecksumIEEE(content),
+				CompressedSize64:   uint64(len(content)),
+				UncompressedSize64: uint64(len(content)) - 1,
+			},
+			data: content,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			tmpDir, fsys := testFS(t, io.Discard)
+
+			zipPath := filepath.Join(tmpDir, "test.zip")
+			f, err := os.Create(zipPath)
+			require.NoError(t, err)
+
+			zw := zip.NewWriter(f)
+			tc.header.Name = "test.txt"
+			w, err := zw.CreateRaw(tc.header)
+			require.NoError(t, err)
+			_, err = w.Write(tc.data)
+			require.NoError(t, err)
+			require.NoError(t, zw.Close())
+			require.NoError(t, f.Close())
+
+			node := &zipInMemoryFileNode{
+				zipBaseFileNode: &zipBaseFileNode{
+					fsys:    fsys,
+					inode:   0,
+					archive: zipPath,
+					path:    "test.txt",
+					size:    tc.header.UncompressedSize64,
+					mtime:   time.Now(),
+				},
+			}
+
+			data, err := node.ReadAll(t.Context())
+			require.ErrorIs(t, err, fuse.ToErrno(syscall.EIO))
+			require.Nil(t, data)
+		})
+	}
+}
+
 // Expectation: ReadAll should handle empty files correctly.
 func Test_zipInMemoryFileNode_ReadAll_EmptyFile_Success(t *testing.T) {
 	t.Parallel()
@@ -157,6 +546,38 @@ func Test_zipInMemoryFileNode_ReadAll_EmptyFile_Success(t *testing.T) {
 	require.Empty(t, data)
 }
 
+// Expectation: A zero-size compressed file should be looked up as in-memory
+// node, even without any streaming threshold, and ReadAll should return empty.
+func Test_zipInMemoryFileNode_ReadAll_DeflateEmptyFile_Success(t *testing.T) {
+	t.Parallel()
+	tmpDir, fsys := testFS(t, io.Discard)
+	tnow := time.Now()
+
+	fsys.Options.StreamingThreshold.Store(0)
+
+	zipPath := createTestZipMethod(t, tmpDir, "test.zip", zip.Deflate, []struct {
+		Path    string
+		ModTime time.Time
+		Content []byte
+	}{
+		{Path: "dir/", ModTime: tnow, Content: nil},
+		{Path: "dir/empty.txt", ModTime: tnow, Content: nil},
+	})
+
+	dir := &zipDirNode{fsys: fsys, path: zipPath, mtime: tnow}
+
+	lk, err := dir.lookupNested(t.Context(), "dir/empty.txt")
+	require.NoError(t, err)
+
+	node, ok := lk.(*zipInMemoryFileNode)
+	require.True(t, ok)
+
+	data, err := node.ReadAll(t.Context())
+	require.NoError(t, err)
+	require.NotNil(t, data)
+	require.Empty(t, data)
+}
+
 // Expectation: ReadAll should return EINVAL for a missing file.
 func Test_zipInMemoryFileNode_ReadAll_FileNotFound_Error(t *testing.T) {
 	t.Parallel()
@@ -186,6 +607,7 @@ func Test_zipInMemoryFileNode_ReadAll_FileNotFound_Error(t *testing.T) {
 	data, err := node.ReadAll(t.Context())
 	require.Nil(t, data)
 	require.ErrorIs(t, err, fuse.ToErrno(syscall.EINVAL))
+	require.ErrorIs(t, err, ErrEntryNotFound)
 }
 
 // Expectation: ReadAll should return EINVAL for an invalid archive.
@@ -210,6 +632,33 @@ func Test_zipInMemoryFileNode_ReadAll_InvalidArchive_Error(t *testing.T) {
 	require.ErrorIs(t, err, fuse.ToErrno(syscall.EINVAL))
 }
 
+// Expectation: ReadAll should return EFBIG for a file exceeding the in-memory
+// limit, without even opening the archive (so never allocating its content).
+func Test_zipInMemoryFileNode_ReadAll_InMemoryLimit_Error(t *testing.T) {
+	t.Parallel()
+	_, fsys := testFS(t, io.Discard)
+	tnow := time.Now()
+
+	fsys.Options.MaxInMemoryBytes = 1024
+
+	node := &zipInMemoryFileNode{
+		zipBaseFileNode: &zipBaseFileNode{
+			fsys:    fsys,
+			inode:   0,
+			archive: "/nonexistent/archive.zip",
+			path:    "dir/test.txt",
+			size:    1025,
+			mtime:   tnow,
+		},
+	}
+
+	data, err := node.ReadAll(t.Context())
+	require.Nil(t, data)
+	require.ErrorIs(t, err, fuse.ToErrno(syscall.EFBIG))
+	require.ErrorIs(t, err, errSizeInMemory)
+	require.Zero(t, fsys.Metrics.TotalOpenedZips.Load())
+}
+
 // Expectation: Open should set the caching flag and return a zipDiskStreamFileHandle.
 func Test_zipDiskStreamFileNode_Open_Success(t *testing.T) {
 	t.Parallel()
@@ -335,8 +784,55 @@ func Test_zipDiskStreamFileHandle_Read_Success(t *testing.T) {
 			fsys:    fsys,
 			inode:   0,
 			archive: zipPath,
-			path:    "dir/stream.txt",
-			size:    uint64(len(content)),
+			path:    "dir/stream.txt",
+			size:    uint64(len(content)),
+			mtime:   tnow,
+		},
+	}
+
+	handle, err := node.Open(t.Context(), &fuse.OpenRequest{}, &fuse.OpenResponse{})
+	require.NoError(t, err)
+
+	fhandle, ok := handle.(*zipDiskStreamFileHandle)
+	require.True(t, ok)
+
+	defer func() {
+		err = fhandle.Release(t.Context(), &fuse.ReleaseRequest{})
+		require.NoError(t, err)
+	}()
+
+	req := &fuse.ReadRequest{
+		Offset: 10,
+		Size:   10,
+	}
+	resp := &fuse.ReadResponse{}
+
+	err = fhandle.Read(t.Context(), req, resp)
+	require.NoError(t, err)
+	require.Equal(t, content[10:20], resp.Data)
+}
+
+// Expectation: Read should handle empty files correctly.
+func Test_zipDiskStreamFileHandle_Read_EmptyFile_Success(t *testing.T) {
+	t.Parallel()
+	tmpDir, fsys := testFS(t, io.Discard)
+	tnow := time.Now()
+
+	zipPath := createTestZip(t, tmpDir, "test.zip", []struct {
+		Path    string
+		ModTime time.Time
+		Content []byte
+	}{
+		{Path: "empty.txt", ModTime: tnow, Content: []byte{}},
+	})
+
+	node := &zipDiskStreamFileNode{
+		zipBaseFileNode: &zipBaseFileNode{
+			fsys:    fsys,
+			inode:   fs.GenerateDynamicInode(1, "empty.txt"),
+			archive: zipPath,
+			path:    "empty.txt",
+			size:    0,
 			mtime:   tnow,
 		},
 	}
@@ -353,28 +849,29 @@ func Test_zipDiskStreamFileHandle_Read_Success(t *testing.T) {
 	}()
 
 	req := &fuse.ReadRequest{
-		Offset: 10,
+		Offset: 0,
 		Size:   10,
 	}
 	resp := &fuse.ReadResponse{}
 
-	err = fhandle.Read(t.Context(), req, resp)
+	err = fhandle.Read(context.Background(), req, resp)
 	require.NoError(t, err)
-	require.Equal(t, content[10:20], resp.Data)
+	require.Empty(t, resp.Data)
 }
 
-// Expectation: Read should handle empty files correctly.
-func Test_zipDiskStreamFileHandle_Read_EmptyFile_Success(t *testing.T) {
+// Expectation: Read should handle zero-size compressed files correctly,
+// also when being asked for an offset past the (empty) end of the file.
+func Test_zipDiskStreamFileHandle_Read_DeflateEmptyFile_Success(t *testing.T) {
 	t.Parallel()
 	tmpDir, fsys := testFS(t, io.Discard)
 	tnow := time.Now()
 
-	zipPath := createTestZip(t, tmpDir, "test.zip", []struct {
+	zipPath := createTestZipMethod(t, tmpDir, "test.zip", zip.Deflate, []struct {
 		Path    string
 		ModTime time.Time
 		Content []byte
 	}{
-		{Path: "empty.txt", ModTime: tnow, Content: []byte{}},
+		{Path: "empty.txt", ModTime: tnow, Content: nil},
 	})
 
 	node := &zipDiskStreamFileNode{
@@ -399,15 +896,15 @@ func Test_zipDiskStreamFileHandle_Read_EmptyFile_Success(t *testing.T) {
 		require.NoError(t, err)
 	}()
 
-	req := &fuse.ReadRequest{
-		Offset: 0,
-		Size:   10,
+	for _, offset := range []int64{0, 0, 10, 0} {
+		resp := &fuse.ReadResponse{}
+
+		err = fhandle.Read(t.Context(), &fuse.ReadRequest{Offset: offset, Size: 10}, resp)
+		require.NoError(t, err)
+		require.Empty(t, resp.Data)
 	}
-	resp := &fuse.ReadResponse{}
 
-	err = fhandle.Read(context.Background(), req, resp)
-	require.NoError(t, err)
-	require.Empty(t, resp.Data)
+	require.Zero(t, fsys.Metrics.Errors.Load())
 }
 
 // Expectation: Read should handle reading from offset 0.
@@ -574,6 +1071,220 @@ func Test_zipDiskStreamFileHandle_Read_NoSeekRewind_Success(t *testing.T) {
 	require.Equal(t, initialReopenCount+1, finalReopenCount)
 }
 
+// failingReader is an [io.Reader] that returns an error for every read.
+type failingReader struct {
+	err error
+}
+
+func (r *failingReader) Read(_ []byte) (int, error) {
+	return 0, r.err
+}
+
+// Expectation: A transient error while streaming should re-open the entry
+// and retry the read from the requested offset, returning the correct data.
+func Test_zipDiskStreamFileHandle_Read_TransientRetry_Success(t *testing.T) {
+	t.Parallel()
+	tmpDir, fsys := testFS(t, io.Discard)
+	fsys.Options.MustCRC32.Store(true)
+
+	tnow := time.Now()
+
+	content := []byte("0123456789abcdefghijklmnopqrstuvwxyz")
+	zipPath := createTestZip(t, tmpDir, "test.zip", []struct {
+		Path    string
+		ModTime time.Time
+		Content []byte
+	}{
+		{Path: "retry.txt", ModTime: tnow, Content: content},
+	})
+
+	node := &zipDiskStreamFileNode{
+		zipBaseFileNode: &zipBaseFileNode{
+			fsys:    fsys,
+			inode:   0,
+			archive: zipPath,
+			path:    "retry.txt",
+			size:    uint64(len(content)),
+			mtime:   tnow,
+		},
+	}
+
+	handle, err := node.Open(t.Context(), &fuse.OpenRequest{}, &fuse.OpenResponse{})
+	require.NoError(t, err)
+
+	fhandle, ok := handle.(*zipDiskStreamFileHandle)
+	require.True(t, ok)
+
+	defer func() {
+		err = fhandle.Release(t.Context(), &fuse.ReleaseRequest{})
+		require.NoError(t, err)
+	}()
+
+	fhandle.fr.r = &failingReader{err: syscall.ESTALE}
+
+	req := &fuse.ReadRequest{
+		Offset: 5,
+		Size:   10,
+	}
+	resp := &fuse.ReadResponse{}
+
+	err = fhandle.Read(t.Context(), req, resp)
+	require.NoError(t, err)
+	require.Equal(t, content[5:15], resp.Data)
+	require.Equal(t, int64(1), fsys.Metrics.TotalStreamRetries.Load())
+}
+
+// Expectation: A transient error while streaming should be returned once
+// the retries are exhausted (or disabled), without retrying any further.
+func Test_zipDiskStreamFileHandle_Read_TransientNoRetries_Error(t *testing.T) {
+	t.Parallel()
+	tmpDir, fsys := testFS(t, io.Discard)
+	fsys.Options.MustCRC32.Store(true)
+	fsys.Options.StreamRetries = 0
+
+	tnow := time.Now()
+
+	content := []byte("0123456789abcdefghijklmnopqrstuvwxyz")
+	zipPath := createTestZip(t, tmpDir, "test.zip", []struct {
+		Path    string
+		ModTime time.Time
+		Content []byte
+	}{
+		{Path: "retry.txt", ModTime: tnow, Content: content},
+	})
+
+	node := &zipDiskStreamFileNode{
+		zipBaseFileNode: &zipBaseFileNode{
+			fsys:    fsys,
+			inode:   0,
+			archive: zipPath,
+			path:    "retry.txt",
+			size:    uint64(len(content)),
+			mtime:   tnow,
+		},
+	}
+
+	handle, err := node.Open(t.Context(), &fuse.OpenRequest{}, &fuse.OpenResponse{})
+	require.NoError(t, err)
+
+	fhandle, ok := handle.(*zipDiskStreamFileHandle)
+	require.True(t, ok)
+
+	defer func() {
+		err = fhandle.Release(t.Context(), &fuse.ReleaseRequest{})
+		require.NoError(t, err)
+	}()
+
+	fhandle.fr.r = &failingReader{err: syscall.ESTALE}
+
+	err = fhandle.Read(t.Context(), &fuse.ReadRequest{Offset: 0, Size: 10}, &fuse.ReadResponse{})
+	require.ErrorIs(t, err, fuse.ToErrno(syscall.EIO))
+	require.Equal(t, int64(0), fsys.Metrics.TotalStreamRetries.Load())
+}
+
+// Expectation: A read of a streamed file whose archive was truncated while
+// open should fail with ESTALE under StrictCache, instead of returning data.
+func Test_zipDiskStreamFileHandle_Read_StaleArchive_Error(t *testing.T) {
+	t.Parallel()
+	tmpDir, fsys := testFS(t, io.Discard)
+	fsys.Options.StrictCache = true
+
+	tnow := time.Now()
+
+	content := bytes.Repeat([]byte("0123456789abcdef"), 4096)
+	zipPath := createTestZipMethod(t, tmpDir, "test.zip", zip.Store, []struct {
+		Path    string
+		ModTime time.Time
+		Content []byte
+	}{
+		{Path: "stale.txt", ModTime: tnow, Content: content},
+	})
+
+	node := &zipDiskStreamFileNode{
+		zipBaseFileNode: &zipBaseFileNode{
+			fsys:    fsys,
+			inode:   0,
+			archive: zipPath,
+			path:    "stale.txt",
+			size:    uint64(len(content)),
+			mtime:   tnow,
+		},
+	}
+
+	handle, err := node.Open(t.Context(), &fuse.OpenRequest{}, &fuse.OpenResponse{})
+	require.NoError(t, err)
+
+	fhandle, ok := handle.(*zipDiskStreamFileHandle)
+	require.True(t, ok)
+
+	defer func() {
+		err = fhandle.Release(t.Context(), &fuse.ReleaseRequest{})
+		require.NoError(t, err)
+	}()
+
+	resp := &fuse.ReadResponse{}
+	err = fhandle.Read(t.Context(), &fuse.ReadRequest{Offset: 0, Size: 4096}, resp)
+	require.NoError(t, err)
+	require.Equal(t, content[:4096], resp.Data)
+
+	require.NoError(t, os.Truncate(zipPath, 8192))
+
+	resp = &fuse.ReadResponse{}
+	err = fhandle.Read(t.Context(), &fuse.ReadRequest{Offset: 4096, Size: 8192}, resp)
+	require.ErrorIs(t, err, fuse.ToErrno(syscall.ESTALE))
+	require.ErrorIs(t, err, errStreamStale)
+	require.Empty(t, resp.Data)
+	require.Equal(t, int64(1), fsys.Metrics.TotalStaleReads.Load())
+	require.Equal(t, int64(0), fsys.Metrics.TotalStreamRetries.Load())
+}
+
+// Expectation: A corruption error while streaming should never be retried,
+// as re-opening the entry would only produce the same corrupted data again.
+func Test_zipDiskStreamFileHandle_Read_CorruptNoRetry_Error(t *testing.T) {
+	t.Parallel()
+	tmpDir, fsys := testFS(t, io.Discard)
+	fsys.Options.MustCRC32.Store(true)
+
+	tnow := time.Now()
+
+	content := []byte("0123456789abcdefghijklmnopqrstuvwxyz")
+	zipPath := createTestZip(t, tmpDir, "test.zip", []struct {
+		Path    string
+		ModTime time.Time
+		Content []byte
+	}{
+		{Path: "corrupt.txt", ModTime: tnow, Content: content},
+	})
+
+	node := &zipDiskStreamFileNode{
+		zipBaseFileNode: &zipBaseFileNode{
+			fsys:    fsys,
+			inode:   0,
+			archive: zipPath,
+			path:    "corrupt.txt",
+			size:    uint64(len(content)),
+			mtime:   tnow,
+		},
+	}
+
+	handle, err := node.Open(t.Context(), &fuse.OpenRequest{}, &fuse.OpenResponse{})
+	require.NoError(t, err)
+
+	fhandle, ok := handle.(*zipDiskStreamFileHandle)
+	require.True(t, ok)
+
+	defer func() {
+		err = fhandle.Release(t.Context(), &fuse.ReleaseRequest{})
+		require.NoError(t, err)
+	}()
+
+	fhandle.fr.r = &failingReader{err: zip.ErrChecksum}
+
+	err = fhandle.Read(t.Context(), &fuse.ReadRequest{Offset: 0, Size: 10}, &fuse.ReadResponse{})
+	require.ErrorIs(t, err, fuse.ToErrno(syscall.EIO))
+	require.Equal(t, int64(0), fsys.Metrics.TotalStreamRetries.Load())
+}
+
 // Expectation: Multiple concurrent reads on the same file handle should not
 // race or corrupt data, including when read operations require seeking (or
 // pseudo-seeking on non-seekables) in a sequential/non-sequential manner.
@@ -1016,3 +1727,140 @@ func Test_zipDiskStreamFileHandle_Read_Pool_SmallThenLarge_Success(t *testing.T)
 	require.NoError(t, err)
 	require.Equal(t, content[1024:1024+fsys.Options.StreamPoolSize+512], resp2.Data)
 }
+
+// Expectation: In metadata only mode, files should present their attributes,
+// but opening and reading should be denied without touching the archive.
+func Test_zipFileNodes_MetadataOnly_Error(t *testing.T) {
+	t.Parallel()
+	tmpDir, fsys := testFS(t, io.Discard)
+	tnow := time.Now()
+
+	fsys.Options.MetadataOnly = true
+
+	content := []byte("test content")
+	zipPath := createTestZip(t, tmpDir, "test.zip", []struct {
+		Path    string
+		ModTime time.Time
+		Content []byte
+	}{
+		{Path: "file.txt", ModTime: tnow, Content: content},
+	})
+
+	base := &zipBaseFileNode{
+		fsys:    fsys,
+		inode:   fs.GenerateDynamicInode(1, "file.txt"),
+		archive: zipPath,
+		path:    "file.txt",
+		size:    uint64(len(content)),
+		mtime:   tnow,
+	}
+
+	var attr fuse.Attr
+	require.NoError(t, base.Attr(t.Context(), &attr))
+	require.Equal(t, uint64(len(content)), attr.Size)
+
+	mn := &zipInMemoryFileNode{base}
+	_, err := mn.Open(t.Context(), &fuse.OpenRequest{}, &fuse.OpenResponse{})
+	require.ErrorIs(t, err, fuse.ToErrno(syscall.EACCES))
+	_, err = mn.ReadAll(t.Context())
+	require.ErrorIs(t, err, fuse.ToErrno(syscall.EACCES))
+
+	dn := &zipDiskStreamFileNode{base}
+	_, err = dn.Open(t.Context(), &fuse.OpenRequest{}, &fuse.OpenResponse{})
+	require.ErrorIs(t, err, fuse.ToErrno(syscall.EACCES))
+
+	h := &zipDiskStreamFileHandle{fsys: fsys, archive: zipPath, path: "file.txt"}
+	err = h.Read(t.Context(), &fuse.ReadRequest{Size: 4}, &fuse.ReadResponse{})
+	require.ErrorIs(t, err, fuse.ToErrno(syscall.EACCES))
+
+	require.Zero(t, fsys.Metrics.TotalOpenedZips.Load())
+	require.Zero(t, fsys.Metrics.TotalExtractCount.Load())
+	require.Zero(t, fsys.Metrics.Errors.Load())
+}
+
+// Expectation: Open should return EROFS when a file is opened for writing.
+func Test_zipInMemoryFileNode_Open_Write_Error(t *testing.T) {
+	t.Parallel()
+	_, fsys := testFS(t, io.Discard)
+
+	node := &zipInMemoryFileNode{zipBaseFileNode: &zipBaseFileNode{fsys: fsys}}
+
+	_, err := node.Open(t.Context(), &fuse.OpenRequest{Flags: fuse.OpenWriteOnly}, &fuse.OpenResponse{})
+	require.ErrorIs(t, err, fuse.ToErrno(syscall.EROFS))
+
+	_, err = node.Open(t.Context(), &fuse.OpenRequest{Flags: fuse.OpenReadWrite}, &fuse.OpenResponse{})
+	require.ErrorIs(t, err, fuse.ToErrno(syscall.EROFS))
+}
+
+// Expectation: A raw file should present the compressed size and yield the raw
+// (deflated) bytes of the entry at any offset, never decompressing them.
+func Test_zipRawFileNode_Read_Success(t *testing.T) {
+	t.Parallel()
+	tmpDir, fsys := testFS(t, io.Discard)
+	tnow := time.Now()
+
+	content := bytes.Repeat([]byte("compressible content "), 100)
+	zipPath := createTestZipMethod(t, tmpDir, "test.zip", zip.Deflate, []struct {
+		Path    string
+		ModTime time.Time
+		Content []byte
+	}{
+		{Path: "test.txt", ModTime: tnow, Content: content},
+	})
+
+	node := &zipDirNode{
+		fsys:  fsys,
+		inode: fs.GenerateDynamicInode(1, "test"),
+		path:  zipPath,
+		mtime: tnow,
+		raw:   true,
+	}
+
+	lk, err := node.lookupNested(t.Context(), "test.txt")
+	require.NoError(t, err)
+
+	raw, ok := lk.(*zipRawFileNode)
+	require.True(t, ok)
+
+	var attr fuse.Attr
+	require.NoError(t, raw.Attr(t.Context(), &attr))
+	require.Less(t, attr.Size, uint64(len(content)))
+
+	h, err := raw.Open(t.Context(), &fuse.OpenRequest{Flags: fuse.OpenReadOnly}, &fuse.OpenResponse{})
+	require.NoError(t, err)
+
+	handle, ok := h.(*zipRawFileHandle)
+	require.True(t, ok)
+	defer handle.Release(t.Context(), &fuse.ReleaseRequest{}) //nolint:errcheck
+
+	resp := &fuse.ReadResponse{}
+	require.NoError(t, handle.Read(t.Context(), &fuse.ReadRequest{Offset: 0, Size: int(attr.Size) + 100}, resp))
+	require.Len(t, resp.Data, int(attr.Size))
+
+	decompressed, err := io.ReadAll(flate.NewReader(bytes.NewReader(resp.Data)))
+	require.NoError(t, err)
+	require.Equal(t, content, decompressed)
+
+	tail := &fuse.ReadResponse{}
+	require.NoError(t, handle.Read(t.Context(), &fuse.ReadRequest{Offset: 2, Size: 3}, tail))
+	require.Equal(t, resp.Data[2:5], tail.Data)
+}
+
+// Expectation: A raw file should not be opened for writing or in metadata-only mode.
+func Test_zipRawFileNode_Open_Error(t *testing.T) {
+	t.Parallel()
+	_, fsys := testFS(t, io.Discard)
+
+	node := &zipRawFileNode{&zipBaseFileNode{fsys: fsys, archive: "/nonexistent.zip", path: "test.txt"}}
+
+	_, err := node.Open(t.Context(), &fuse.OpenRequest{Flags: fuse.OpenWriteOnly}, &fuse.OpenResponse{})
+	require.ErrorIs(t, err, fuse.ToErrno(syscall.EROFS))
+
+	_, err = node.Open(t.Context(), &fuse.OpenRequest{Flags: fuse.OpenReadOnly}, &fuse.OpenResponse{})
+	require.ErrorIs(t, err, fuse.ToErrno(syscall.EINVAL))
+
+	fsys.Options.MetadataOnly = true
+
+	_, err = node.Open(t.Context(), &fuse.OpenRequest{Flags: fuse.OpenReadOnly}, &fuse.OpenResponse{})
+	require.ErrorIs(t, err, fuse.ToErrno(syscall.EACCES))
+}
@@ -0,0 +1,112 @@
+package filesystem
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"syscall"
+	"time"
+
+	"bazil.org/fuse"
+	"bazil.org/fuse/fs"
+)
+
+var (
+	_ fs.Node            = (*zipMarkerNode)(nil)
+	_ fs.NodeOpener      = (*zipMarkerNode)(nil)
+	_ fs.HandleReadAller = (*zipMarkerNode)(nil)
+)
+
+// archiveMarker is the name of the synthetic file presented at the root of
+// every archive's directory (with [Options.ArchiveMarker]), suffixed with a
+// number if the archive itself already contains an entry of the same name.
+const archiveMarker = ".archive-info"
+
+// zipMarkerNode is a synthetic file at the root of an archive's directory.
+// It carries the modified time of the underlying ZIP archive and contains a
+// one-line summary of it, not being backed by any entry within the archive.
+type zipMarkerNode struct {
+	readOnlyNode // Rejects all modifications (with EROFS).
+
+	fsys    *FS       // Pointer to our filesystem.
+	inode   uint64    // Inode within our filesystem.
+	mtime   time.Time // Modified time of the underlying ZIP archive.
+	content []byte    // Summary of the underlying ZIP archive.
+}
+
+func (z *zipMarkerNode) Attr(_ context.Context, a *fuse.Attr) error {
+	a.Mode = fileBasePerm
+	a.Inode = z.inode
+
+	// Reporting zero would have the kernel never read the summary.
+	a.Size = uint64(len(z.content))
+
+	mtime := z.fsys.attrTime(z.mtime)
+
+	a.Atime = mtime
+	a.Ctime = mtime
+	a.Mtime = mtime
+
+	return nil
+}
+
+func (z *zipMarkerNode) Open(ctx context.Context, req *fuse.OpenRequest, resp *fuse.OpenResponse) (fs.Handle, error) {
+	z.fsys.Metrics.TotalOpens.Add(1)
+
+	if !req.Flags.IsReadOnly() {
+		return nil, fuse.ToErrno(syscall.EROFS)
+	}
+
+	if err := z.fsys.checkAccess(ctx); err != nil {
+		return nil, err
+	}
+
+	if z.fsys.cacheFileContent() {
+		resp.Flags |= fuse.OpenKeepCache
+	}
+
+	return z, nil
+}
+
+func (z *zipMarkerNode) ReadAll(_ context.Context) ([]byte, error) {
+	defer z.fsys.active()()
+	z.fsys.Metrics.TotalReads.Add(1)
+
+	return z.content, nil
+}
+
+// markerNode returns the [zipMarkerNode] for the archive, summarizing the
+// amount of its (non-directory) entries and their total uncompressed size.
+func (z *zipDirNode) markerNode(zr *zipReader, name string) *zipMarkerNode {
+	var entries int
+	var total uint64
+
+	for i, f := range zr.File {
+		if isDir(f, zipEntryNormalize(i, f, z.fsys.Options.ForceUnicode, z.fsys.Options.UnicodeNormalize)) {
+			continue
+		}
+		entries++
+		total += f.UncompressedSize64
+	}
+
+	return &zipMarkerNode{
+		fsys:    z.fsys,
+		inode:   fs.GenerateDynamicInode(z.inode, name),
+		mtime:   z.mtime,
+		content: fmt.Appendf(nil, "entries=%d size=%d archive=%q\n", entries, total, z.fsys.archiveRelPath(z.path)),
+	}
+}
+
+// syntheticName returns the name of a synthetic entry at the root of an
+// archive (e.g. [archiveMarker]), which is the base name unless taken by any
+// of the archive's own entries, then being the first free numbered suffix.
+func syntheticName(base string, seen map[string]nestedEntry) string {
+	name := base
+
+	for i := 1; ; i++ {
+		if _, ok := seen[name]; !ok {
+			return name
+		}
+		name = base + "." + strconv.Itoa(i)
+	}
+}
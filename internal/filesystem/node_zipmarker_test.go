@@ -0,0 +1,73 @@
+package filesystem
+
+import (
+	"io"
+	"syscall"
+	"testing"
+	"time"
+
+	"bazil.org/fuse"
+	"bazil.org/fuse/fs"
+	"github.com/stretchr/testify/require"
+)
+
+// Expectation: The marker should carry the archive's modified time and
+// contain a one-line summary of its (non-directory) entries.
+func Test_zipMarkerNode_ReadAll_Success(t *testing.T) {
+	t.Parallel()
+	tmpDir, fsys := testFS(t, io.Discard)
+	fsys.Options.ArchiveMarker = true
+	tnow := time.Now()
+	tarchive := tnow.Add(-time.Hour)
+
+	zipPath := createTestZip(t, tmpDir, "test.zip", []struct {
+		Path    string
+		ModTime time.Time
+		Content []byte
+	}{
+		{Path: "sub/", ModTime: tnow},
+		{Path: "sub/a.txt", ModTime: tnow, Content: []byte("abc")},
+		{Path: "b.txt", ModTime: tnow, Content: []byte("de")},
+	})
+
+	node := &zipDirNode{
+		fsys:  fsys,
+		inode: fs.GenerateDynamicInode(1, "test"),
+		path:  zipPath,
+		mtime: tarchive,
+	}
+
+	lk, err := node.lookupNested(t.Context(), archiveMarker)
+	require.NoError(t, err)
+
+	marker, ok := lk.(*zipMarkerNode)
+	require.True(t, ok)
+
+	want := "entries=2 size=5 archive=\"test.zip\"\n"
+
+	var attr fuse.Attr
+	require.NoError(t, marker.Attr(t.Context(), &attr))
+	require.Equal(t, fileBasePerm, int(attr.Mode))
+	require.Equal(t, uint64(len(want)), attr.Size)
+	require.True(t, attr.Mtime.Equal(tarchive))
+
+	resp := &fuse.OpenResponse{}
+	h, err := marker.Open(t.Context(), &fuse.OpenRequest{Flags: fuse.OpenReadOnly}, resp)
+	require.NoError(t, err)
+	require.NotZero(t, resp.Flags&fuse.OpenKeepCache)
+
+	data, err := h.(*zipMarkerNode).ReadAll(t.Context())
+	require.NoError(t, err)
+	require.Equal(t, want, string(data))
+}
+
+// Expectation: The marker should not be opened for writing.
+func Test_zipMarkerNode_Open_ReadOnly_Error(t *testing.T) {
+	t.Parallel()
+	_, fsys := testFS(t, io.Discard)
+
+	node := &zipMarkerNode{fsys: fsys}
+
+	_, err := node.Open(t.Context(), &fuse.OpenRequest{Flags: fuse.OpenWriteOnly}, &fuse.OpenResponse{})
+	require.ErrorIs(t, err, fuse.ToErrno(syscall.EROFS))
+}
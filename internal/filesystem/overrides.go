@@ -0,0 +1,119 @@
+package filesystem
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"slices"
+	"sync"
+)
+
+// GlobOverride is an override of a runtime setting for archives matching a glob.
+type GlobOverride[T any] struct {
+	Glob  string `json:"glob"`
+	Value T      `json:"value"`
+}
+
+// GlobOverrides is a thread-safe set of [GlobOverride] for a runtime setting.
+// The globs are matched (with [path.Match]) against the archive paths relative
+// to the source directory, and the first matching glob (in order of being set)
+// takes precedence. The zero value is an empty set which is ready for use.
+type GlobOverrides[T any] struct {
+	sync.RWMutex
+
+	entries []GlobOverride[T]
+}
+
+// Set adds or replaces the override of a glob, keeping the original order.
+// It returns an error if the glob is empty or has an invalid pattern syntax.
+func (o *GlobOverrides[T]) Set(glob string, value T) error {
+	if glob == "" {
+		return fmt.Errorf("%w: need a non-empty glob", errInvalidArgument)
+	}
+	if _, err := path.Match(glob, ""); err != nil {
+		return fmt.Errorf("%w: bad glob %q: %w", errInvalidArgument, glob, err)
+	}
+
+	o.Lock()
+	defer o.Unlock()
+
+	for i := range o.entries {
+		if o.entries[i].Glob == glob {
+			o.entries[i].Value = value
+
+			return nil
+		}
+	}
+	o.entries = append(o.entries, GlobOverride[T]{Glob: glob, Value: value})
+
+	return nil
+}
+
+// Delete removes the override of a glob, returning false if there was none.
+func (o *GlobOverrides[T]) Delete(glob string) bool {
+	o.Lock()
+	defer o.Unlock()
+
+	n := len(o.entries)
+	o.entries = slices.DeleteFunc(o.entries, func(e GlobOverride[T]) bool {
+		return e.Glob == glob
+	})
+
+	return len(o.entries) != n
+}
+
+// Match returns the value of the first glob matching the relative path.
+// It returns false if no glob matches, so the global setting should be used.
+func (o *GlobOverrides[T]) Match(relPath string) (T, bool) {
+	o.RLock()
+	defer o.RUnlock()
+
+	for _, e := range o.entries {
+		if ok, _ := path.Match(e.Glob, relPath); ok {
+			return e.Value, true
+		}
+	}
+
+	var zero T
+
+	return zero, false
+}
+
+// List returns a copy of all [GlobOverride] in order of being set.
+func (o *GlobOverrides[T]) List() []GlobOverride[T] {
+	o.RLock()
+	defer o.RUnlock()
+
+	return slices.Clone(o.entries)
+}
+
+// archiveRelPath returns the slash-separated path of an archive relative to
+// the source directory, as used for matching against any [GlobOverrides].
+func (fsys *FS) archiveRelPath(archive string) string {
+	rel, err := filepath.Rel(fsys.SourceDir, archive)
+	if err != nil {
+		return filepath.ToSlash(archive)
+	}
+
+	return filepath.ToSlash(rel)
+}
+
+// mustCRC32 returns [Options.MustCRC32] for an archive, as overridden by
+// [Options.MustCRC32Overrides] (if any of the globs match the archive).
+func (fsys *FS) mustCRC32(archive string) bool {
+	if v, ok := fsys.Options.MustCRC32Overrides.Match(fsys.archiveRelPath(archive)); ok {
+		return v
+	}
+
+	return fsys.Options.MustCRC32.Load()
+}
+
+// streamingThreshold returns [Options.StreamingThreshold] for an archive, as
+// overridden by [Options.StreamingThresholdOverrides] (if any globs match).
+func (fsys *FS) streamingThreshold(archive string) uint64 {
+	if v, ok := fsys.Options.StreamingThresholdOverrides.Match(fsys.archiveRelPath(archive)); ok {
+		return v
+	}
+
+	return fsys.Options.StreamingThreshold.Load()
+}
@@ -0,0 +1,120 @@
+package filesystem
+
+import (
+	"io"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Expectation: Set should add and replace overrides, keeping their order.
+func Test_GlobOverrides_Set_Success(t *testing.T) {
+	t.Parallel()
+
+	var o GlobOverrides[bool]
+
+	require.NoError(t, o.Set("a/*.zip", true))
+	require.NoError(t, o.Set("*", false))
+	require.NoError(t, o.Set("a/*.zip", false))
+
+	require.Equal(t, []GlobOverride[bool]{
+		{Glob: "a/*.zip", Value: false},
+		{Glob: "*", Value: false},
+	}, o.List())
+}
+
+// Expectation: Set should return an error for empty or invalid globs.
+func Test_GlobOverrides_Set_Error(t *testing.T) {
+	t.Parallel()
+
+	var o GlobOverrides[bool]
+
+	require.ErrorIs(t, o.Set("", true), errInvalidArgument)
+	require.ErrorIs(t, o.Set("[", true), errInvalidArgument)
+	require.Empty(t, o.List())
+}
+
+// Expectation: Match should return the value of the first matching glob.
+func Test_GlobOverrides_Match_Success(t *testing.T) {
+	t.Parallel()
+
+	var o GlobOverrides[uint64]
+
+	_, ok := o.Match("a/b.zip")
+	require.False(t, ok)
+
+	require.NoError(t, o.Set("a/*.zip", 1))
+	require.NoError(t, o.Set("*/*.zip", 2))
+
+	v, ok := o.Match("a/b.zip")
+	require.True(t, ok)
+	require.Equal(t, uint64(1), v)
+
+	v, ok = o.Match("c/b.zip")
+	require.True(t, ok)
+	require.Equal(t, uint64(2), v)
+
+	_, ok = o.Match("a/c/b.zip")
+	require.False(t, ok)
+
+	require.True(t, o.Delete("a/*.zip"))
+	require.False(t, o.Delete("a/*.zip"))
+
+	v, ok = o.Match("a/b.zip")
+	require.True(t, ok)
+	require.Equal(t, uint64(2), v)
+}
+
+// Expectation: The per-archive settings should fall back to the global settings,
+// unless any of the globs match the archive path relative to the source directory.
+func Test_FS_ArchiveOverrides_Success(t *testing.T) {
+	t.Parallel()
+	tmpDir, fsys := testFS(t, io.Discard)
+
+	secure := filepath.Join(tmpDir, "secure", "a.zip")
+	other := filepath.Join(tmpDir, "other", "a.zip")
+
+	fsys.Options.MustCRC32.Store(false)
+	fsys.Options.StreamingThreshold.Store(100)
+
+	require.False(t, fsys.mustCRC32(secure))
+	require.Equal(t, uint64(100), fsys.streamingThreshold(secure))
+
+	require.NoError(t, fsys.Options.MustCRC32Overrides.Set("secure/*.zip", true))
+	require.NoError(t, fsys.Options.StreamingThresholdOverrides.Set("secure/*", 0))
+
+	require.True(t, fsys.mustCRC32(secure))
+	require.Equal(t, uint64(0), fsys.streamingThreshold(secure))
+
+	require.False(t, fsys.mustCRC32(other))
+	require.Equal(t, uint64(100), fsys.streamingThreshold(other))
+}
+
+// Expectation: A streaming threshold override should decide the type of file nodes.
+func Test_zipDirNode_fileNode_ThresholdOverride_Success(t *testing.T) {
+	t.Parallel()
+	tmpDir, fsys := testFS(t, io.Discard)
+	tnow := time.Now()
+
+	zipPath := createTestZip(t, tmpDir, "test.zip", []struct {
+		Path    string
+		ModTime time.Time
+		Content []byte
+	}{
+		{Path: "file.txt", ModTime: tnow, Content: []byte("content")},
+	})
+
+	node := &zipDirNode{fsys: fsys, path: zipPath, mtime: tnow}
+
+	lk, err := node.lookupNested(t.Context(), "file.txt")
+	require.NoError(t, err)
+	require.IsType(t, &zipInMemoryFileNode{}, lk)
+
+	require.NoError(t, fsys.Options.StreamingThresholdOverrides.Set("test.zip", 1))
+
+	lk, err = node.lookupNested(t.Context(), "file.txt")
+	require.NoError(t, err)
+	require.IsType(t, &zipDiskStreamFileNode{}, lk)
+}
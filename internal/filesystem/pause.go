@@ -0,0 +1,39 @@
+package filesystem
+
+import (
+	"errors"
+)
+
+// errArchivesPaused is for an archive not opened while opens are paused.
+var errArchivesPaused = errors.New("archive opens paused")
+
+// Pause stops the opening of any new archives (e.g. during maintenance of the
+// backing storage), without unmounting. Archives which are already held open
+// by the FD cache are still served, while any others fail with EAGAIN. It
+// returns false if the opening of archives was already paused.
+func (fsys *FS) Pause() bool {
+	if !fsys.paused.CompareAndSwap(false, true) {
+		return false
+	}
+
+	fsys.rbuf.Println("Paused: opening of new archives (cached archives are still served).")
+
+	return true
+}
+
+// Resume immediately re-enables the opening of new archives (see [FS.Pause]).
+// It returns false if the opening of archives was not paused.
+func (fsys *FS) Resume() bool {
+	if !fsys.paused.CompareAndSwap(true, false) {
+		return false
+	}
+
+	fsys.rbuf.Println("Resumed: opening of new archives.")
+
+	return true
+}
+
+// Paused returns if the opening of new archives is paused (see [FS.Pause]).
+func (fsys *FS) Paused() bool {
+	return fsys.paused.Load()
+}
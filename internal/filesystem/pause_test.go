@@ -0,0 +1,56 @@
+package filesystem
+
+import (
+	"io"
+	"syscall"
+	"testing"
+	"time"
+
+	"bazil.org/fuse"
+	"bazil.org/fuse/fs"
+	"github.com/stretchr/testify/require"
+)
+
+// Expectation: While paused, archives held open by the FD cache should still
+// be served, while opening any other archive fails with EAGAIN until resumed.
+func Test_FS_Pause_Success(t *testing.T) {
+	t.Parallel()
+	tmpDir, fsys := testFS(t, io.Discard)
+
+	entries := []struct {
+		Path    string
+		ModTime time.Time
+		Content []byte
+	}{
+		{Path: "file.txt", ModTime: time.Now(), Content: []byte("content")},
+	}
+	cached := createTestZip(t, tmpDir, "cached.zip", entries)
+	uncached := createTestZip(t, tmpDir, "uncached.zip", entries)
+
+	cachedNode := &zipDirNode{fsys: fsys, inode: fs.GenerateDynamicInode(1, "cached"), path: cached, mtime: time.Now()}
+	uncachedNode := &zipDirNode{fsys: fsys, inode: fs.GenerateDynamicInode(1, "uncached"), path: uncached, mtime: time.Now()}
+
+	_, err := cachedNode.ReadDirAll(t.Context())
+	require.NoError(t, err)
+
+	require.True(t, fsys.Pause())
+	require.False(t, fsys.Pause())
+	require.True(t, fsys.Paused())
+
+	ent, err := cachedNode.ReadDirAll(t.Context())
+	require.NoError(t, err)
+	require.Len(t, ent, 1)
+
+	_, err = uncachedNode.ReadDirAll(t.Context())
+	require.ErrorIs(t, err, errArchivesPaused)
+	require.Equal(t, fuse.ToErrno(syscall.EAGAIN), toErrno(err))
+	require.Zero(t, fsys.Metrics.TotalBreakerRejects.Load())
+
+	require.True(t, fsys.Resume())
+	require.False(t, fsys.Resume())
+	require.False(t, fsys.Paused())
+
+	ent, err = uncachedNode.ReadDirAll(t.Context())
+	require.NoError(t, err)
+	require.Len(t, ent, 1)
+}
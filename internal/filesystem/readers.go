@@ -4,24 +4,46 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"os"
+	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/klauspost/compress/zip"
 )
 
-// zipReader is a thread-safe, metrics-aware [zip.ReadCloser].
+// zipReader is a thread-safe, metrics-aware [zip.Reader].
 //
 // It allows for multiple files to be read concurrently, while
 // keeping open the archive, and internally tracking reference count.
+// The archive is either a local file or a remote archive (over HTTP).
 type zipReader struct {
-	*zip.ReadCloser
+	*zip.Reader
 
+	path     string
+	closer   io.Closer
+	info     os.FileInfo // Of the local archive when opened (nil if remote).
 	fsys     *FS
 	refCount atomic.Int32
+
+	lastAccess atomic.Int64 // Of the last access via the cache (unix nanoseconds).
+
+	dirSizesOnce sync.Once
+	dirSizes     map[string]uint64 // Of all prefixes (lazily, see DirSize).
+
+	duplicatesOnce sync.Once
+	duplicates     map[*zip.File]struct{} // Collapsed files (lazily, see Duplicate).
+
+	symlinksOnce sync.Once
+	symlinks     map[*zip.File]*zip.File // Resolved targets (lazily, see Symlink).
+
+	truncatedOnce sync.Once // Logged the truncated names (see LogTruncated).
 }
 
 // newZipReader returns a pointer to a new [zipReader] for given path.
 // Beware that this function may block on the filesystem FD semaphore.
+// If the circuit breaker for the path is tripped, it returns immediately.
+// The same goes for the opening of archives being paused (see [FS.Pause]).
 //
 // It increases the atomic reference count by one upon returning the new
 // pointer. Once done, you need to call Release() to close the reference.
@@ -30,27 +52,331 @@ type zipReader struct {
 // A new [zipReader] is always returned with a reference count of one.
 // This means that one-shot calls only need to call Release() after use.
 func newZipReader(fsys *FS, path string) (*zipReader, error) {
+	if fsys.paused.Load() {
+		return nil, fmt.Errorf("%w: %w: %s", ErrArchiveUnreadable, errArchivesPaused, path)
+	}
+
+	if fsys.failed.Tripped(path) {
+		fsys.Metrics.TotalBreakerRejects.Add(1)
+
+		return nil, fmt.Errorf("%w: %w: %s", ErrArchiveUnreadable, errArchiveTripped, path)
+	}
+
 	fsys.fdlimit <- struct{}{}
 
-	rc, err := zip.OpenReader(path)
+	r, closer, info, err := fsys.openArchive(path)
 	if err != nil {
 		<-fsys.fdlimit
 
-		return nil, err //nolint:wrapcheck
+		if fsys.isIncompleteArchive(path, err) {
+			return nil, fmt.Errorf("%w: %w: %w", ErrArchiveUnreadable, errArchiveIncomplete, err)
+		}
+
+		if fsys.failed.Add(path, err) {
+			fsys.rbuf.Printf("Tripped: %q: failed to open %d+ times, rejecting for %s\n",
+				path, fsys.Options.BreakerThreshold, fsys.Options.BreakerCooldown)
+		}
+
+		return nil, fmt.Errorf("%w: %w", ErrArchiveUnreadable, err)
 	}
+	fsys.failed.Remove(path)
 
 	fsys.Metrics.OpenZips.Add(1)
 	fsys.Metrics.TotalOpenedZips.Add(1)
+	fsys.Metrics.recordArchive(path, r)
 
 	zr := &zipReader{
-		ReadCloser: rc,
-		fsys:       fsys,
+		Reader: r,
+		path:   path,
+		closer: closer,
+		info:   info,
+		fsys:   fsys,
 	}
 	zr.Acquire() // for caller
 
 	return zr, nil
 }
 
+// openArchive opens an archive for reading, which is either a local file (also
+// split into parts, see [splitParts]) or a remote archive (see [isRemoteArchive])
+// being read with range requests.
+// The returned [io.Closer] must be closed once the [zip.Reader] is done.
+// The returned [os.FileInfo] is of the opened local file (nil if remote).
+func (fsys *FS) openArchive(path string) (*zip.Reader, io.Closer, os.FileInfo, error) {
+	if isRemoteArchive(path) {
+		ra, err := newHTTPReaderAt(fsys.client, path)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+
+		r, err := zip.NewReader(ra, ra.Size())
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("failed to read remote: %w", err)
+		}
+
+		return registerDecompressors(r), ra, nil, nil
+	}
+
+	if parts := splitParts(path); len(parts) > 0 {
+		return openSplitArchive(path, parts)
+	}
+
+	var f *os.File
+	var ra io.ReaderAt
+	var err error
+
+	if fsys.Options.DirectIO {
+		var direct bool
+		f, direct, err = openDirect(path)
+		if direct {
+			ra = &directReaderAt{f: f}
+		}
+	} else {
+		f, err = os.Open(path)
+	}
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to open: %w", err)
+	}
+	if ra == nil {
+		ra = f
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+
+		return nil, nil, nil, fmt.Errorf("failed to stat: %w", err)
+	}
+
+	r, err := zip.NewReader(ra, info.Size())
+	if err != nil {
+		f.Close()
+
+		return nil, nil, nil, fmt.Errorf("failed to read: %w", err)
+	}
+
+	return registerDecompressors(r), f, info, nil
+}
+
+// Stale returns if the archive at path is no longer the one that was opened,
+// as its backing file was replaced (e.g. by an atomic rename) or modified.
+// The file identity (device and inode), size and modification time are
+// compared, so that a replacement is also detected if size and time match.
+// A remote archive is never considered stale, as its identity is unknown.
+// A split archive is stale if any of its parts is (see [splitReaderAt.Stale]).
+func (zr *zipReader) Stale(path string) bool {
+	if zr.info == nil {
+		return false
+	}
+
+	info, err := os.Stat(path)
+	if err != nil || fileChanged(zr.info, info) {
+		return true
+	}
+
+	if s, ok := zr.closer.(*splitReaderAt); ok {
+		return s.Stale()
+	}
+
+	return false
+}
+
+// fileChanged returns if a file (as of info) is no longer the same as the one
+// of old, by its identity (device and inode), size and modification time.
+func fileChanged(old, info os.FileInfo) bool {
+	return !os.SameFile(old, info) ||
+		info.Size() != old.Size() ||
+		!info.ModTime().Equal(old.ModTime())
+}
+
+// DirSize returns the total uncompressed size of all files below a prefix
+// (as normalized, e.g. "dir/sub/", or empty for the root of the archive).
+// The sizes of all prefixes are computed at once on the first call, to be
+// held for the lifetime of the [zipReader] (and so its FD cache entry).
+func (zr *zipReader) DirSize(prefix string) uint64 {
+	zr.dirSizesOnce.Do(func() {
+		zr.dirSizes = make(map[string]uint64)
+
+		for i, f := range zr.File {
+			normalizedPath := zipEntryNormalize(i, f, zr.fsys.Options.ForceUnicode, zr.fsys.Options.UnicodeNormalize)
+			if isDir(f, normalizedPath) || zr.hiddenEntry(f, normalizedPath) {
+				continue
+			}
+
+			zr.dirSizes[""] += f.UncompressedSize64
+
+			// Every parent directory (explicit or implicit) contains the file:
+			for j := range len(normalizedPath) {
+				if normalizedPath[j] == '/' {
+					zr.dirSizes[normalizedPath[:j+1]] += f.UncompressedSize64
+				}
+			}
+		}
+	})
+
+	return zr.dirSizes[prefix]
+}
+
+// Duplicate returns if a file of the archive is collapsed by [Options.DedupIdentical],
+// having the same CRC-32 and uncompressed size as an earlier (presented) file
+// of the archive, as by the central directory (without reading any contents).
+// Empty files are never collapsed. The duplicates are determined at once on the
+// first call, to be held for the lifetime of the [zipReader] (as DirSize).
+func (zr *zipReader) Duplicate(f *zip.File) bool {
+	zr.duplicatesOnce.Do(func() {
+		type content struct {
+			crc  uint32
+			size uint64
+		}
+
+		zr.duplicates = make(map[*zip.File]struct{})
+		seen := make(map[content]struct{})
+
+		for i, f := range zr.File {
+			normalizedPath := zipEntryNormalize(i, f, zr.fsys.Options.ForceUnicode, zr.fsys.Options.UnicodeNormalize)
+			if f.UncompressedSize64 == 0 || isDir(f, normalizedPath) || zr.fsys.hiddenEntry(f, normalizedPath) {
+				continue
+			}
+
+			c := content{crc: f.CRC32, size: f.UncompressedSize64}
+			if _, ok := seen[c]; ok {
+				zr.duplicates[f] = struct{}{}
+
+				continue
+			}
+			seen[c] = struct{}{}
+		}
+
+		if len(zr.duplicates) > 0 {
+			zr.fsys.rbuf.Printf("Collapsed: %q: %d files with identical content (of earlier files)\n",
+				zr.path, len(zr.duplicates))
+		}
+	})
+
+	_, ok := zr.duplicates[f]
+
+	return ok
+}
+
+// Symlink returns the target of a symlink of the archive, being the regular
+// file of the archive it resolves to (following symlinks), or nil if it does
+// not resolve to one (e.g. being absolute, outside of the archive, dangling or
+// a loop). It returns false for a [zip.File] that is not a symlink. The targets
+// of all symlinks are resolved at once on the first call, reading their contents,
+// to be held for the lifetime of the [zipReader] (as DirSize).
+func (zr *zipReader) Symlink(f *zip.File) (*zip.File, bool) {
+	zr.symlinksOnce.Do(func() {
+		zr.symlinks = make(map[*zip.File]*zip.File)
+
+		files := make(map[string]*zip.File)
+		targets := make(map[*zip.File]string)
+
+		for i, f := range zr.File {
+			normalizedPath := zipEntryNormalize(i, f, zr.fsys.Options.ForceUnicode, zr.fsys.Options.UnicodeNormalize)
+			if isDir(f, normalizedPath) {
+				continue
+			}
+			files[normalizedPath] = f
+
+			if !isSymlink(f) {
+				continue
+			}
+			targets[f] = "" // Unresolved, unless the target is read below.
+
+			target, err := zr.symlinkTarget(f)
+			if err != nil {
+				zr.fsys.rbuf.Printf("Error: %q->%q: failed to read symlink: %v\n", zr.path, f.Name, err)
+
+				continue
+			}
+			targets[f] = resolveSymlink(normalizedPath, zr.fsys.Options.UnicodeNormalize.apply(target))
+		}
+
+		for f := range targets {
+			zr.symlinks[f] = followSymlinks(f, files, targets)
+			if zr.symlinks[f] == nil {
+				zr.fsys.rbuf.Printf("Skipped: %q->%q: symlink not resolving to a file within the archive\n", zr.path, f.Name)
+			}
+		}
+	})
+
+	target, ok := zr.symlinks[f]
+
+	return target, ok
+}
+
+// symlinkTarget reads the target of a symlink of the archive, being its content.
+func (zr *zipReader) symlinkTarget(f *zip.File) (string, error) {
+	if isEncrypted(f.Flags) {
+		return "", ErrEncryptedEntry
+	}
+
+	if f.UncompressedSize64 > symlinkMax {
+		return "", fmt.Errorf("%w: target exceeds %d bytes", ErrCorruptEntry, symlinkMax)
+	}
+
+	rc, err := f.Open()
+	if err != nil {
+		return "", fmt.Errorf("%w: failed to open: %w", ErrCorruptEntry, err)
+	}
+	defer rc.Close()
+
+	b, err := io.ReadAll(io.LimitReader(rc, symlinkMax))
+	if err != nil {
+		return "", fmt.Errorf("%w: failed to read: %w", ErrCorruptEntry, err)
+	}
+
+	return string(b), nil
+}
+
+// LogTruncated logs the paths of the archive with names exceeding [nameMax],
+// as truncated by [zipEntryNormalize]. These are logged only once, on the first
+// call, for the lifetime of the [zipReader] (as DirSize), and not on each listing.
+func (zr *zipReader) LogTruncated() {
+	zr.truncatedOnce.Do(func() {
+		for i, f := range zr.File {
+			longPath := zipEntryNormalizeLong(i, f, zr.fsys.Options.ForceUnicode, zr.fsys.Options.UnicodeNormalize)
+			if normalizedPath := zipEntryTruncate(longPath); normalizedPath != longPath {
+				zr.fsys.rbuf.Printf("Truncated: %q: %q -> %q (name exceeds %d bytes)\n",
+					zr.path, longPath, normalizedPath, nameMax)
+			}
+		}
+	})
+}
+
+// hiddenEntry checks if a [zip.File] of the archive is not presented, being
+// either hidden by [Options.OnlyExtensions] (see [FS.hiddenEntry]) or being a
+// duplicate collapsed by [Options.DedupIdentical] (see [zipReader.Duplicate]).
+func (zr *zipReader) hiddenEntry(f *zip.File, normalizedPath string) bool {
+	if zr.fsys.hiddenEntry(f, normalizedPath) {
+		return true
+	}
+
+	return zr.fsys.Options.DedupIdentical && zr.Duplicate(f)
+}
+
+// isIncompleteArchive checks if an archive that failed to open is likely
+// still being written, in which case it should not be treated as failed.
+// This is only ever the case with [Options.TailMode] being enabled, when
+// the archive has no valid central directory (yet) and was modified within
+// [Options.TailWindow], otherwise the archive is treated as failed/corrupt.
+func (fsys *FS) isIncompleteArchive(path string, err error) bool {
+	if !fsys.Options.TailMode {
+		return false
+	}
+
+	if !errors.Is(err, zip.ErrFormat) && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return false
+	}
+
+	info, serr := os.Stat(path)
+	if serr != nil {
+		return false
+	}
+
+	return time.Since(info.ModTime()) <= fsys.Options.TailWindow
+}
+
 // Acquire increases the reference count by one, it should be
 // called every time a [zipReader] is re-used more than once.
 //
@@ -61,6 +387,23 @@ func (zr *zipReader) Acquire() {
 	zr.refCount.Add(1)
 }
 
+// Touch records an access of the [zipReader] via the [zipReaderCache] (now),
+// so that archives not accessed for long can be told apart (see [CachedArchive]).
+func (zr *zipReader) Touch() {
+	zr.lastAccess.Store(time.Now().UnixNano())
+}
+
+// LastAccess returns the time of the last access recorded with Touch() (or
+// the zero time, if the [zipReader] was never accessed via the cache).
+func (zr *zipReader) LastAccess() time.Time {
+	ns := zr.lastAccess.Load()
+	if ns == 0 {
+		return time.Time{}
+	}
+
+	return time.Unix(0, ns)
+}
+
 // Release decreases the reference count by one and closes the
 // [zipReader] if the new reference count is exactly at zero (0).
 func (zr *zipReader) Release() error {
@@ -77,7 +420,7 @@ func (zr *zipReader) Close() error {
 	panic("unsupported direct close of zipReader, use Release() instead")
 }
 
-// closeReader instantly closes the [zip.ReadCloser].
+// closeReader instantly closes the underlying archive.
 // You must use Release() instead, which internally calls closeReader().
 func (zr *zipReader) closeReader() error {
 	defer func() {
@@ -87,12 +430,15 @@ func (zr *zipReader) closeReader() error {
 	zr.fsys.Metrics.OpenZips.Add(-1)
 	zr.fsys.Metrics.TotalClosedZips.Add(1)
 
-	return zr.ReadCloser.Close() //nolint:wrapcheck
+	return zr.closer.Close() //nolint:wrapcheck
 }
 
 var (
 	_ io.ReadCloser = (*zipFileReader)(nil)
 
+	// errArchiveIncomplete is for an archive that is likely still being written.
+	errArchiveIncomplete = errors.New("archive incomplete")
+
 	// errNonSeekableRewind occurs when an attempt is made to rewind a non-seekable file.
 	errNonSeekableRewind = errors.New("cannot rewind non-seekable file")
 )
@@ -109,19 +455,31 @@ type zipFileReader struct {
 	pos int64
 }
 
-// newZipFileReader opens a [zip.File] and returns a new [zipFileReader].
+// newZipFileReader opens a [zip.File] (of an archive) and returns a new [zipFileReader].
 // You must ensure that Close() will always be called after use is complete.
-func newZipFileReader(fsys *FS, f *zip.File) (*zipFileReader, error) {
+func newZipFileReader(fsys *FS, archive string, f *zip.File) (*zipFileReader, error) {
 	var r io.Reader
 	var err error
 
-	if f.Method == zip.Store && !fsys.Options.MustCRC32.Load() {
+	if err := fsys.checkMethod(archive, f.Name, f.Method); err != nil {
+		return nil, err
+	}
+
+	if isEncrypted(f.Flags) {
+		return fsys.openEncrypted(archive, f)
+	}
+
+	// The raw bytes of a stored file are only its contents, if both its sizes
+	// agree (as within the central directory), which may not be the case for
+	// malformed files written with a data descriptor (e.g. by streaming writers),
+	// so those are opened with integrity checking (failing on the mismatch).
+	if f.Method == zip.Store && f.CompressedSize64 == f.UncompressedSize64 && !fsys.mustCRC32(archive) {
 		r, err = f.OpenRaw()
 	} else {
 		r, err = f.Open()
 	}
 	if err != nil {
-		return nil, fmt.Errorf("failed to open: %w", err)
+		return nil, fmt.Errorf("%w: failed to open: %w", ErrCorruptEntry, err)
 	}
 
 	return &zipFileReader{r: r, f: f}, nil
@@ -133,7 +491,7 @@ func (fr *zipFileReader) Read(p []byte) (int, error) {
 	n, err := fr.r.Read(p)
 	fr.pos += int64(n)
 
-	return n, err //nolint:wrapcheck
+	return n, toEntryErr(err)
 }
 
 // ForwardTo advances the reader position to the specified offset.
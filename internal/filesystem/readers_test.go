@@ -1,12 +1,17 @@
 package filesystem
 
 import (
+	"bytes"
+	"hash/crc32"
 	"io"
 	"os"
 	"path/filepath"
+	"strconv"
 	"testing"
 	"time"
 
+	"bazil.org/fuse"
+	"bazil.org/fuse/fs"
 	"github.com/klauspost/compress/zip"
 	"github.com/stretchr/testify/require"
 )
@@ -20,6 +25,50 @@ func Test_newZipReader_NotExist_Error(t *testing.T) {
 	require.Error(t, err)
 }
 
+// Expectation: newZipReader should account each opened archive within the ArchiveStats.
+func Test_newZipReader_ArchiveStats_Success(t *testing.T) {
+	t.Parallel()
+	tmpDir, fsys := testFS(t, io.Discard)
+	tnow := time.Now()
+
+	small := createTestZip(t, tmpDir, "small.zip", []struct {
+		Path    string
+		ModTime time.Time
+		Content []byte
+	}{
+		{Path: "big.bin", ModTime: tnow, Content: make([]byte, 4096)},
+	})
+	many := createTestZip(t, tmpDir, "many.zip", []struct {
+		Path    string
+		ModTime time.Time
+		Content []byte
+	}{
+		{Path: "a.txt", ModTime: tnow, Content: []byte("a")},
+		{Path: "b.txt", ModTime: tnow, Content: []byte("b")},
+		{Path: "c.txt", ModTime: tnow, Content: []byte("c")},
+	})
+
+	require.Zero(t, fsys.Metrics.ArchiveStats().AvgEntries())
+
+	for _, path := range []string{small, many} {
+		zr, err := newZipReader(fsys, path)
+		require.NoError(t, err)
+		require.NoError(t, zr.Release())
+	}
+
+	stats := fsys.Metrics.ArchiveStats()
+	require.Equal(t, int64(2), stats.Opened)
+	require.Equal(t, int64(1), stats.MinEntries)
+	require.Equal(t, int64(3), stats.MaxEntries)
+	require.InDelta(t, 2.0, stats.AvgEntries(), 0.001)
+	require.Equal(t, many, stats.MaxEntriesPath)
+	require.Equal(t, uint64(4096), stats.MaxSize)
+	require.Equal(t, small, stats.MaxSizePath)
+
+	fsys.Metrics.ResetArchiveStats()
+	require.Equal(t, ArchiveStats{}, fsys.Metrics.ArchiveStats())
+}
+
 // Expectation: newZipReader should return an error for an invalid ZIP file.
 func Test_newZipReader_InvalidZip_Error(t *testing.T) {
 	t.Parallel()
@@ -34,6 +83,38 @@ func Test_newZipReader_InvalidZip_Error(t *testing.T) {
 	require.Error(t, err)
 }
 
+// Expectation: DirSize should return the total size of all files below a prefix,
+// including implicit directories, but neither hidden entries nor other prefixes.
+func Test_zipReader_DirSize_Success(t *testing.T) {
+	t.Parallel()
+	tmpDir, fsys := testFS(t, io.Discard)
+	fsys.Options.OnlyExtensions = []string{"txt"}
+	tnow := time.Now()
+
+	zipPath := createTestZip(t, tmpDir, "test.zip", []struct {
+		Path    string
+		ModTime time.Time
+		Content []byte
+	}{
+		{Path: "a/", ModTime: tnow},
+		{Path: "a/one.txt", ModTime: tnow, Content: []byte("1")},
+		{Path: "a/b/two.txt", ModTime: tnow, Content: []byte("22")},
+		{Path: "a/b/hidden.jpg", ModTime: tnow, Content: []byte("hidden")},
+		{Path: "ab/three.txt", ModTime: tnow, Content: []byte("333")},
+		{Path: "four.txt", ModTime: tnow, Content: []byte("4444")},
+	})
+
+	zr, err := newZipReader(fsys, zipPath)
+	require.NoError(t, err)
+	defer zr.Release() //nolint:errcheck
+
+	require.Equal(t, uint64(10), zr.DirSize(""))
+	require.Equal(t, uint64(3), zr.DirSize("a/"))
+	require.Equal(t, uint64(2), zr.DirSize("a/b/"))
+	require.Equal(t, uint64(3), zr.DirSize("ab/"))
+	require.Zero(t, zr.DirSize("nonexistent/"))
+}
+
 // Expectation: zipReader should track open/close metrics correctly.
 func Test_zipReader_Metrics_Success(t *testing.T) {
 	t.Parallel()
@@ -164,7 +245,7 @@ func Test_newZipFileReader_Store_Success(t *testing.T) {
 
 	require.Len(t, zr.File, 1)
 
-	fr, err := newZipFileReader(fsys, zr.File[0])
+	fr, err := newZipFileReader(fsys, zipPath, zr.File[0])
 	_, ok := fr.Reader().(*io.SectionReader)
 	require.True(t, ok)
 	require.NoError(t, err)
@@ -176,6 +257,115 @@ func Test_newZipFileReader_Store_Success(t *testing.T) {
 	require.Equal(t, content, data)
 }
 
+// Expectation: Files written in streaming mode (with a data descriptor, having
+// no sizes within their local headers) should be presented with the sizes of the
+// central directory and read in full, both by in-memory and streaming nodes.
+func Test_zipDirNode_DataDescriptor_Success(t *testing.T) {
+	t.Parallel()
+
+	for _, method := range []uint16{zip.Store, zip.Deflate} {
+		t.Run("Method="+strconv.Itoa(int(method)), func(t *testing.T) {
+			t.Parallel()
+			tmpDir, fsys := testFS(t, io.Discard)
+			tnow := time.Now()
+			content := bytes.Repeat([]byte("streamed content "), 500)
+
+			zipPath := createTestZipMethod(t, tmpDir, "test.zip", method, []struct {
+				Path    string
+				ModTime time.Time
+				Content []byte
+			}{
+				{Path: "a.txt", ModTime: tnow, Content: content},
+			})
+
+			zr, err := zip.OpenReader(zipPath)
+			require.NoError(t, err)
+			require.NotZero(t, zr.File[0].Flags&flagDataDescriptor, "expected a data descriptor")
+			require.NoError(t, zr.Close())
+
+			node := &zipDirNode{
+				fsys:  fsys,
+				inode: fs.GenerateDynamicInode(1, "test"),
+				path:  zipPath,
+				mtime: tnow,
+			}
+
+			for _, threshold := range []uint64{uint64(len(content)) + 1, 1} {
+				fsys.Options.StreamingThreshold.Store(threshold)
+
+				lk, err := node.Lookup(t.Context(), "a.txt")
+				require.NoError(t, err)
+
+				var attr fuse.Attr
+				require.NoError(t, lk.Attr(t.Context(), &attr))
+				require.Equal(t, uint64(len(content)), attr.Size)
+
+				var data []byte
+				switch n := lk.(type) {
+				case *zipInMemoryFileNode:
+					data, err = n.ReadAll(t.Context())
+					require.NoError(t, err)
+				case *zipDiskStreamFileNode:
+					handle, err := n.Open(t.Context(), &fuse.OpenRequest{}, &fuse.OpenResponse{})
+					require.NoError(t, err)
+					h, ok := handle.(*zipDiskStreamFileHandle)
+					require.True(t, ok)
+
+					resp := &fuse.ReadResponse{}
+					require.NoError(t, h.Read(t.Context(), &fuse.ReadRequest{Size: len(content) + 1}, resp))
+					require.NoError(t, h.Release(t.Context(), &fuse.ReleaseRequest{}))
+					data = resp.Data
+				default:
+					t.Fatalf("unexpected node type %T", lk)
+				}
+				require.Equal(t, content, data)
+			}
+		})
+	}
+}
+
+// Expectation: newZipFileReader should not pass through the raw bytes of a
+// stored file whose sizes disagree (as a malformed data descriptor file), but
+// fail on reading it instead of returning contents of the wrong length.
+func Test_newZipFileReader_Store_SizeMismatch_Error(t *testing.T) {
+	t.Parallel()
+	tmpDir, fsys := testFS(t, io.Discard)
+	content := []byte("test content")
+
+	zipPath := filepath.Join(tmpDir, "test.zip")
+	f, err := os.Create(zipPath)
+	require.NoError(t, err)
+
+	zw := zip.NewWriter(f)
+	w, err := zw.CreateRaw(&zip.FileHeader{
+		Name:               "test.txt",
+		Method:             zip.Store,
+		Flags:              flagDataDescriptor,
+		CRC32:              crc32.ChecksumIEEE(content),
+		CompressedSize64:   uint64(len(content)),
+		UncompressedSize64: uint64(len(content)) - 1,
+	})
+	require.NoError(t, err)
+	_, err = w.Write(content)
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+	require.NoError(t, f.Close())
+
+	zr, err := zip.OpenReader(zipPath)
+	require.NoError(t, err)
+	defer zr.Close()
+
+	fr, err := newZipFileReader(fsys, zipPath, zr.File[0])
+	require.NoError(t, err)
+	defer fr.Close()
+
+	_, ok := fr.Reader().(*io.SectionReader)
+	require.False(t, ok, "raw bytes should not be passed through")
+
+	_, err = io.ReadAll(fr)
+	require.Error(t, err)
+}
+
 // Expectation: newZipFileReader should successfully open a compressed file.
 func Test_newZipFileReader_Deflate_Success(t *testing.T) {
 	t.Parallel()
@@ -205,7 +395,7 @@ func Test_newZipFileReader_Deflate_Success(t *testing.T) {
 	require.NoError(t, err)
 	defer zr.Close()
 
-	fr, err := newZipFileReader(fsys, zr.File[0])
+	fr, err := newZipFileReader(fsys, zipPath, zr.File[0])
 	_, ok := fr.Reader().(io.ReadCloser)
 	require.True(t, ok)
 	require.NoError(t, err)
@@ -217,6 +407,46 @@ func Test_newZipFileReader_Deflate_Success(t *testing.T) {
 	require.Equal(t, content, data)
 }
 
+// Expectation: newZipFileReader should open a zero-size compressed file
+// through the decompressor, returning a clean EOF without any data.
+func Test_newZipFileReader_Deflate_EmptyFile_Success(t *testing.T) {
+	t.Parallel()
+	tmpDir, fsys := testFS(t, io.Discard)
+
+	zipPath := createTestZipMethod(t, tmpDir, "test.zip", zip.Deflate, []struct {
+		Path    string
+		ModTime time.Time
+		Content []byte
+	}{
+		{Path: "empty.txt", ModTime: time.Now(), Content: nil},
+	})
+
+	zr, err := zip.OpenReader(zipPath)
+	require.NoError(t, err)
+	defer zr.Close()
+
+	require.Equal(t, zip.Deflate, zr.File[0].Method)
+	require.Equal(t, uint64(0), zr.File[0].UncompressedSize64)
+	require.NotZero(t, zr.File[0].CompressedSize64)
+
+	fr, err := newZipFileReader(fsys, zipPath, zr.File[0])
+	require.NoError(t, err)
+	defer fr.Close()
+
+	_, isSection := fr.Reader().(*io.SectionReader)
+	require.False(t, isSection)
+
+	buf := make([]byte, 10)
+	n, err := fr.Read(buf)
+	require.Equal(t, 0, n)
+	require.ErrorIs(t, err, io.EOF)
+	require.Equal(t, int64(0), fr.Position())
+
+	pos, err := fr.ForwardTo(0)
+	require.NoError(t, err)
+	require.Equal(t, int64(0), pos)
+}
+
 // Expectation: zipFileReader.Read should correctly track position.
 func Test_zipFileReader_Read_Position_Success(t *testing.T) {
 	t.Parallel()
@@ -237,7 +467,7 @@ func Test_zipFileReader_Read_Position_Success(t *testing.T) {
 	require.NoError(t, err)
 	defer zr.Close()
 
-	fr, err := newZipFileReader(fsys, zr.File[0])
+	fr, err := newZipFileReader(fsys, zipPath, zr.File[0])
 	_, ok := fr.Reader().(*io.SectionReader)
 	require.True(t, ok)
 	require.NoError(t, err)
@@ -279,7 +509,7 @@ func Test_zipFileReader_Read_EmptyFile_Success(t *testing.T) {
 	require.NoError(t, err)
 	defer zr.Close()
 
-	fr, err := newZipFileReader(fsys, zr.File[0])
+	fr, err := newZipFileReader(fsys, zipPath, zr.File[0])
 	_, ok := fr.Reader().(*io.SectionReader)
 	require.True(t, ok)
 	require.NoError(t, err)
@@ -313,7 +543,7 @@ func Test_zipFileReader_Read_EOF_Success(t *testing.T) {
 	require.NoError(t, err)
 	defer zr.Close()
 
-	fr, err := newZipFileReader(fsys, zr.File[0])
+	fr, err := newZipFileReader(fsys, zipPath, zr.File[0])
 	_, ok := fr.Reader().(*io.SectionReader)
 	require.True(t, ok)
 	require.NoError(t, err)
@@ -351,7 +581,7 @@ func Test_zipFileReader_ForwardTo_AlreadyAtOffset_Success(t *testing.T) {
 	require.NoError(t, err)
 	defer zr.Close()
 
-	fr, err := newZipFileReader(fsys, zr.File[0])
+	fr, err := newZipFileReader(fsys, zipPath, zr.File[0])
 	_, ok := fr.Reader().(io.ReadCloser)
 	require.True(t, ok)
 	require.NoError(t, err)
@@ -384,7 +614,7 @@ func Test_zipFileReader_ForwardTo_Discard_Success(t *testing.T) {
 	require.NoError(t, err)
 	defer zr.Close()
 
-	fr, err := newZipFileReader(fsys, zr.File[0])
+	fr, err := newZipFileReader(fsys, zipPath, zr.File[0])
 	_, ok := fr.Reader().(io.ReadCloser)
 	require.True(t, ok)
 	require.NoError(t, err)
@@ -423,7 +653,7 @@ func Test_zipFileReader_ForwardTo_Discard_Multiple_Success(t *testing.T) {
 	require.NoError(t, err)
 	defer zr.Close()
 
-	fr, err := newZipFileReader(fsys, zr.File[0])
+	fr, err := newZipFileReader(fsys, zipPath, zr.File[0])
 	_, ok := fr.Reader().(io.ReadCloser)
 	require.True(t, ok)
 	require.NoError(t, err)
@@ -469,7 +699,7 @@ func Test_zipFileReader_ForwardTo_Discard_EndOfFile_Success(t *testing.T) {
 	require.NoError(t, err)
 	defer zr.Close()
 
-	fr, err := newZipFileReader(fsys, zr.File[0])
+	fr, err := newZipFileReader(fsys, zipPath, zr.File[0])
 	_, ok := fr.Reader().(io.ReadCloser)
 	require.True(t, ok)
 	require.NoError(t, err)
@@ -506,7 +736,7 @@ func Test_zipFileReader_ForwardTo_Discard_BeyondEOF_Success(t *testing.T) {
 	require.NoError(t, err)
 	defer zr.Close()
 
-	fr, err := newZipFileReader(fsys, zr.File[0])
+	fr, err := newZipFileReader(fsys, zipPath, zr.File[0])
 	_, ok := fr.Reader().(io.ReadCloser)
 	require.True(t, ok)
 	require.NoError(t, err)
@@ -540,7 +770,7 @@ func Test_zipFileReader_ForwardTo_Discard_NonSeekableRewind_Error(t *testing.T)
 	require.NoError(t, err)
 	defer zr.Close()
 
-	fr, err := newZipFileReader(fsys, zr.File[0])
+	fr, err := newZipFileReader(fsys, zipPath, zr.File[0])
 	_, ok := fr.Reader().(io.ReadCloser)
 	require.True(t, ok)
 	require.NoError(t, err)
@@ -575,7 +805,7 @@ func Test_zipFileReader_ForwardTo_Seek_Success(t *testing.T) {
 	require.NoError(t, err)
 	defer zr.Close()
 
-	fr, err := newZipFileReader(fsys, zr.File[0])
+	fr, err := newZipFileReader(fsys, zipPath, zr.File[0])
 	_, ok := fr.Reader().(*io.SectionReader)
 	require.True(t, ok)
 	require.NoError(t, err)
@@ -613,7 +843,7 @@ func Test_zipFileReader_ForwardTo_Seek_Multiple_Success(t *testing.T) {
 	require.NoError(t, err)
 	defer zr.Close()
 
-	fr, err := newZipFileReader(fsys, zr.File[0])
+	fr, err := newZipFileReader(fsys, zipPath, zr.File[0])
 	_, ok := fr.Reader().(*io.SectionReader)
 	require.True(t, ok)
 	require.NoError(t, err)
@@ -658,7 +888,7 @@ func Test_zipFileReader_ForwardTo_Seek_EndOfFile_Success(t *testing.T) {
 	require.NoError(t, err)
 	defer zr.Close()
 
-	fr, err := newZipFileReader(fsys, zr.File[0])
+	fr, err := newZipFileReader(fsys, zipPath, zr.File[0])
 	_, ok := fr.Reader().(*io.SectionReader)
 	require.True(t, ok)
 	require.NoError(t, err)
@@ -694,7 +924,7 @@ func Test_zipFileReader_ForwardTo_Seek_BeyondEOF_Success(t *testing.T) {
 	require.NoError(t, err)
 	defer zr.Close()
 
-	fr, err := newZipFileReader(fsys, zr.File[0])
+	fr, err := newZipFileReader(fsys, zipPath, zr.File[0])
 	_, ok := fr.Reader().(*io.SectionReader)
 	require.True(t, ok)
 	require.NoError(t, err)
@@ -727,7 +957,7 @@ func Test_zipFileReader_Close_Success(t *testing.T) {
 	require.NoError(t, err)
 	defer zr.Close()
 
-	fr, err := newZipFileReader(fsys, zr.File[0])
+	fr, err := newZipFileReader(fsys, zipPath, zr.File[0])
 	_, ok := fr.Reader().(*io.SectionReader)
 	require.True(t, ok)
 	require.NoError(t, err)
@@ -0,0 +1,70 @@
+package filesystem
+
+import (
+	"context"
+	"syscall"
+
+	"bazil.org/fuse"
+	"bazil.org/fuse/fs"
+)
+
+var (
+	_ fs.NodeSetattrer     = readOnlyNode{}
+	_ fs.NodeRemover       = readOnlyNode{}
+	_ fs.NodeRenamer       = readOnlyNode{}
+	_ fs.NodeMkdirer       = readOnlyNode{}
+	_ fs.NodeCreater       = readOnlyNode{}
+	_ fs.NodeLinker        = readOnlyNode{}
+	_ fs.NodeSymlinker     = readOnlyNode{}
+	_ fs.NodeMknoder       = readOnlyNode{}
+	_ fs.NodeSetxattrer    = readOnlyNode{}
+	_ fs.NodeRemovexattrer = readOnlyNode{}
+)
+
+// readOnlyNode rejects all modifying operations with EROFS, being embedded
+// into every node. These never reach the nodes on a read-only mount, but the
+// mount is read-write with [Options.AllowXattrControl] (for the kernel to pass
+// on the writes of extended attributes), where these would else either be
+// acknowledged without effect (e.g. Setattr) or fail with the wrong errors.
+// The Setxattr of [zipDirNode] takes precedence (for the refreshing of archives).
+type readOnlyNode struct{}
+
+func (readOnlyNode) Setattr(_ context.Context, _ *fuse.SetattrRequest, _ *fuse.SetattrResponse) error {
+	return fuse.ToErrno(syscall.EROFS)
+}
+
+func (readOnlyNode) Remove(_ context.Context, _ *fuse.RemoveRequest) error {
+	return fuse.ToErrno(syscall.EROFS)
+}
+
+func (readOnlyNode) Rename(_ context.Context, _ *fuse.RenameRequest, _ fs.Node) error {
+	return fuse.ToErrno(syscall.EROFS)
+}
+
+func (readOnlyNode) Mkdir(_ context.Context, _ *fuse.MkdirRequest) (fs.Node, error) {
+	return nil, fuse.ToErrno(syscall.EROFS)
+}
+
+func (readOnlyNode) Create(_ context.Context, _ *fuse.CreateRequest, _ *fuse.CreateResponse) (fs.Node, fs.Handle, error) {
+	return nil, nil, fuse.ToErrno(syscall.EROFS)
+}
+
+func (readOnlyNode) Link(_ context.Context, _ *fuse.LinkRequest, _ fs.Node) (fs.Node, error) {
+	return nil, fuse.ToErrno(syscall.EROFS)
+}
+
+func (readOnlyNode) Symlink(_ context.Context, _ *fuse.SymlinkRequest) (fs.Node, error) {
+	return nil, fuse.ToErrno(syscall.EROFS)
+}
+
+func (readOnlyNode) Mknod(_ context.Context, _ *fuse.MknodRequest) (fs.Node, error) {
+	return nil, fuse.ToErrno(syscall.EROFS)
+}
+
+func (readOnlyNode) Setxattr(_ context.Context, _ *fuse.SetxattrRequest) error {
+	return fuse.ToErrno(syscall.EROFS)
+}
+
+func (readOnlyNode) Removexattr(_ context.Context, _ *fuse.RemovexattrRequest) error {
+	return fuse.ToErrno(syscall.EROFS)
+}
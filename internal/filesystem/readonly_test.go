@@ -0,0 +1,117 @@
+package filesystem
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"bazil.org/fuse"
+	"bazil.org/fuse/fs"
+	"github.com/stretchr/testify/require"
+)
+
+// Expectation: All nodes should reject all modifying operations with EROFS.
+func Test_readOnlyNode_AllNodes_Error(t *testing.T) {
+	t.Parallel()
+
+	erofs := fuse.ToErrno(syscall.EROFS)
+
+	for _, node := range []fs.Node{
+		&realDirNode{},
+		&realFileNode{},
+		&zipDirNode{},
+		&zipInMemoryFileNode{zipBaseFileNode: &zipBaseFileNode{}},
+		&zipDiskStreamFileNode{zipBaseFileNode: &zipBaseFileNode{}},
+		&zipRawFileNode{zipBaseFileNode: &zipBaseFileNode{}},
+		&zipMarkerNode{},
+	} {
+		require.ErrorIs(t, node.(fs.NodeSetattrer).Setattr(t.Context(), &fuse.SetattrRequest{}, &fuse.SetattrResponse{}), erofs)
+		require.ErrorIs(t, node.(fs.NodeRemover).Remove(t.Context(), &fuse.RemoveRequest{}), erofs)
+		require.ErrorIs(t, node.(fs.NodeRenamer).Rename(t.Context(), &fuse.RenameRequest{}, node), erofs)
+		require.ErrorIs(t, node.(fs.NodeRemovexattrer).Removexattr(t.Context(), &fuse.RemovexattrRequest{}), erofs)
+
+		_, err := node.(fs.NodeMkdirer).Mkdir(t.Context(), &fuse.MkdirRequest{})
+		require.ErrorIs(t, err, erofs)
+
+		_, _, err = node.(fs.NodeCreater).Create(t.Context(), &fuse.CreateRequest{}, &fuse.CreateResponse{})
+		require.ErrorIs(t, err, erofs)
+
+		_, err = node.(fs.NodeLinker).Link(t.Context(), &fuse.LinkRequest{}, node)
+		require.ErrorIs(t, err, erofs)
+
+		_, err = node.(fs.NodeSymlinker).Symlink(t.Context(), &fuse.SymlinkRequest{})
+		require.ErrorIs(t, err, erofs)
+
+		_, err = node.(fs.NodeMknoder).Mknod(t.Context(), &fuse.MknodRequest{})
+		require.ErrorIs(t, err, erofs)
+	}
+}
+
+// Expectation: A mount without the read-only option (as with AllowXattrControl)
+// should still reject all modifications with EROFS, except for the refreshing
+// of archives by the extended attribute. It is skipped where FUSE is unavailable.
+func Test_readOnlyNode_Mount_Error(t *testing.T) {
+	t.Parallel()
+
+	tmpDir, fsys := testFS(t, io.Discard)
+	fsys.Options.AllowXattrControl = true
+	tnow := time.Now()
+
+	require.NoError(t, os.Mkdir(filepath.Join(tmpDir, "dir"), 0o777))
+	createTestZip(t, tmpDir, "test.zip", []struct {
+		Path    string
+		ModTime time.Time
+		Content []byte
+	}{
+		{Path: "file.txt", ModTime: tnow, Content: []byte("content")},
+	})
+
+	mountDir := t.TempDir()
+
+	conn, err := fuse.Mount(mountDir)
+	if err != nil {
+		t.Skipf("FUSE is unavailable: %v", err)
+	}
+	defer conn.Close()
+
+	served := make(chan error, 1)
+	go func() {
+		served <- fs.New(conn, &fs.Config{WithContext: fsys.WithContext}).Serve(fsys)
+	}()
+	defer func() {
+		require.NoError(t, fuse.Unmount(mountDir))
+		require.NoError(t, <-served)
+	}()
+
+	// The files are not opened (by [os.Open]), as registering them with the
+	// netpoller of the serving process would deadlock on the FUSE poll request.
+	file := filepath.Join(mountDir, "test", "file.txt")
+	info, err := os.Stat(file)
+	require.NoError(t, err)
+	require.Equal(t, int64(7), info.Size())
+
+	for name, op := range map[string]func() error{
+		"chmod":    func() error { return os.Chmod(file, 0o777) },
+		"truncate": func() error { return os.Truncate(file, 0) },
+		"utimes":   func() error { return os.Chtimes(file, tnow, tnow) },
+		"remove":   func() error { return os.Remove(file) },
+		"rmdir":    func() error { return os.Remove(filepath.Join(mountDir, "dir")) },
+		"rename":   func() error { return os.Rename(file, filepath.Join(mountDir, "test", "new.txt")) },
+		"mkdir":    func() error { return os.Mkdir(filepath.Join(mountDir, "test", "new"), 0o777) },
+		"create":   func() error { return os.WriteFile(filepath.Join(mountDir, "new.txt"), nil, 0o644) },
+		"link":     func() error { return os.Link(file, filepath.Join(mountDir, "test", "link.txt")) },
+		"symlink":  func() error { return os.Symlink(file, filepath.Join(mountDir, "symlink.txt")) },
+		"setxattr": func() error { return syscall.Setxattr(file, "user.other", []byte("1"), 0) },
+		"xattr":    func() error { return syscall.Setxattr(filepath.Join(mountDir, "test"), "user.other", []byte("1"), 0) },
+		"rmxattr":  func() error { return syscall.Removexattr(file, "user.other") },
+	} {
+		err := op()
+		require.True(t, errors.Is(err, syscall.EROFS), "%s: %v", name, err)
+	}
+
+	require.NoError(t, syscall.Setxattr(filepath.Join(mountDir, "test"), refreshXattr, []byte("1"), 0))
+}
@@ -0,0 +1,113 @@
+package filesystem
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/jellydator/ttlcache/v3"
+)
+
+// sfxArchive is the cached result of sniffing a file for an appended archive,
+// which is valid for as long as the file has the same mtime and size.
+type sfxArchive struct {
+	mtime time.Time
+	size  int64
+	sfx   bool
+}
+
+// sfxArchives is a bounded, thread-safe cache of which files without the
+// ".zip" extension are self-extracting archives (see [Options.DetectSFX]), so
+// that these are not sniffed on every listing and lookup. A file is sniffed
+// again once it was modified. Upon reaching capacity, the least recently used
+// file is evicted (and sniffed again on its next listing or lookup).
+type sfxArchives struct {
+	entries *ttlcache.Cache[string, sfxArchive]
+}
+
+// newSFXArchives returns a pointer to a new [sfxArchives].
+func newSFXArchives(size int) *sfxArchives {
+	return &sfxArchives{
+		entries: ttlcache.New(
+			ttlcache.WithCapacity[string, sfxArchive](uint64(size)),
+		),
+	}
+}
+
+// sfxArchive returns if a regular file without the ".zip" extension is a
+// self-extracting archive (e.g. an executable with an appended archive), to
+// be presented as a directory under its full name (with [Options.DetectSFX]).
+// Files which cannot be sniffed (for now) are never self-extracting archives.
+func (fsys *FS) sfxArchive(path string, info os.FileInfo) bool {
+	if !fsys.Options.DetectSFX || strings.HasSuffix(path, ".zip") || !info.Mode().IsRegular() {
+		return false
+	}
+
+	if item := fsys.sfx.entries.Get(path); item != nil {
+		if e := item.Value(); e.mtime.Equal(info.ModTime()) && e.size == info.Size() {
+			return e.sfx
+		}
+	}
+
+	sfx, err := fsys.sniffDirectoryEnd(path, info.Size())
+	if err != nil {
+		fsys.rbuf.Printf("%q->SFXArchive: %v\n", path, err)
+
+		return false // Not cached, as it may yet become readable.
+	}
+
+	e := sfxArchive{
+		mtime: info.ModTime(),
+		size:  info.Size(),
+		sfx:   sfx,
+	}
+
+	fsys.sfx.entries.Set(path, e, ttlcache.NoTTL)
+
+	return e.sfx
+}
+
+// sniffDirectoryEnd returns if a file ends with the end of central directory
+// record of an archive (with its comment), which is where a reader looks for
+// it, so that any data preceding the archive (e.g. an executable) is skipped.
+// The file is held open within the [Options.FDLimit] (as any archive would be).
+func (fsys *FS) sniffDirectoryEnd(path string, size int64) (bool, error) {
+	if size < dirEndLen {
+		return false, nil
+	}
+
+	fsys.fdlimit <- struct{}{}
+	defer func() { <-fsys.fdlimit }()
+
+	f, err := os.Open(path)
+	if err != nil {
+		return false, fmt.Errorf("failed to open: %w", err)
+	}
+	defer f.Close() //nolint:errcheck
+
+	buf := make([]byte, min(size, dirEndLen+uint16max))
+	if _, err := f.ReadAt(buf, size-int64(len(buf))); err != nil {
+		return false, fmt.Errorf("failed to read: %w", err)
+	}
+
+	for i := len(buf) - dirEndLen; i >= 0; i-- {
+		if binary.LittleEndian.Uint32(buf[i:]) != dirEndSig ||
+			i+dirEndLen+int(binary.LittleEndian.Uint16(buf[i+20:])) != len(buf) {
+			continue
+		}
+
+		// A directory of an archive split into parts is never self-extracting.
+		if binary.LittleEndian.Uint16(buf[i+4:]) != 0 && binary.LittleEndian.Uint16(buf[i+4:]) != uint16max {
+			return false, nil
+		}
+
+		dirSize := int64(binary.LittleEndian.Uint32(buf[i+12:]))
+		dirOffset := int64(binary.LittleEndian.Uint32(buf[i+16:]))
+
+		return dirOffset == uint32max || dirOffset+dirSize <= size, nil
+	}
+
+	return false, nil
+}
@@ -0,0 +1,175 @@
+package filesystem
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// createTestSFX creates a self-extracting archive (a stub with an appended
+// archive of the entries), returning the path to the created file.
+func createTestSFX(t *testing.T, tmpDir string, name string, entries []struct {
+	Path    string
+	ModTime time.Time
+	Content []byte
+},
+) string {
+	t.Helper()
+
+	zipPath := createTestZip(t, t.TempDir(), "payload.zip", entries)
+	payload, err := os.ReadFile(zipPath)
+	require.NoError(t, err)
+
+	sfxPath := filepath.Join(tmpDir, name)
+	stub := append([]byte("MZ"), bytes.Repeat([]byte{0x90}, 4096)...)
+	require.NoError(t, os.WriteFile(sfxPath, append(stub, payload...), 0o644))
+
+	return sfxPath
+}
+
+// Expectation: With DetectSFX, a self-extracting archive should be listed and
+// looked up as a directory under its full name, with its files being readable,
+// while any other files without the extension are still not presented.
+func Test_FS_SFXArchive_Success(t *testing.T) {
+	t.Parallel()
+	tmpDir, fsys := testFS(t, io.Discard)
+	fsys.Options.DetectSFX = true
+
+	sfxPath := createTestSFX(t, tmpDir, "setup.exe", []struct {
+		Path    string
+		ModTime time.Time
+		Content []byte
+	}{
+		{Path: "docs/readme.txt", ModTime: time.Now(), Content: []byte("payload")},
+	})
+	require.NoError(t, os.WriteFile(filepath.Join(tmpDir, "other.exe"), []byte("MZ not an archive"), 0o644))
+
+	root := &realDirNode{fsys: fsys, inode: 1, path: tmpDir, mtime: time.Now()}
+
+	ent, err := root.ReadDirAll(t.Context())
+	require.NoError(t, err)
+	require.Len(t, ent, 1)
+	require.Equal(t, "setup.exe", ent[0].Name)
+
+	node, err := root.Lookup(t.Context(), "setup.exe")
+	require.NoError(t, err)
+	require.IsType(t, &zipDirNode{}, node)
+	require.Equal(t, sfxPath, node.(*zipDirNode).path)
+
+	_, err = root.Lookup(t.Context(), "other.exe")
+	require.Error(t, err)
+
+	var buf bytes.Buffer
+	_, err = fsys.ExtractEntry(sfxPath, "docs/readme.txt", 0, -1, &buf)
+	require.NoError(t, err)
+	require.Equal(t, "payload", buf.String())
+
+	rel, err := fsys.archiveWalkPath(sfxPath)
+	require.NoError(t, err)
+	require.Equal(t, "setup.exe", rel)
+
+	require.Equal(t, 2, fsys.sfx.entries.Len())
+}
+
+// Expectation: Without DetectSFX, a self-extracting archive should not be presented.
+func Test_FS_SFXArchive_Disabled_Success(t *testing.T) {
+	t.Parallel()
+	tmpDir, fsys := testFS(t, io.Discard)
+
+	createTestSFX(t, tmpDir, "setup.exe", []struct {
+		Path    string
+		ModTime time.Time
+		Content []byte
+	}{
+		{Path: "readme.txt", ModTime: time.Now(), Content: []byte("payload")},
+	})
+
+	root := &realDirNode{fsys: fsys, inode: 1, path: tmpDir, mtime: time.Now()}
+
+	ent, err := root.ReadDirAll(t.Context())
+	require.NoError(t, err)
+	require.Empty(t, ent)
+
+	_, err = root.Lookup(t.Context(), "setup.exe")
+	require.Error(t, err)
+
+	_, err = fsys.archiveWalkPath(filepath.Join(tmpDir, "setup.exe"))
+	require.ErrorIs(t, err, ErrNotArchive)
+}
+
+// Expectation: The cache of sniffed files should be bounded, with the least
+// recently used file being evicted (and sniffed again on its next lookup).
+func Test_FS_SFXArchive_Capacity_Success(t *testing.T) {
+	t.Parallel()
+	tmpDir, fsys := testFS(t, io.Discard)
+	fsys.Options.DetectSFX = true
+	fsys.sfx = newSFXArchives(2)
+
+	for _, name := range []string{"a.exe", "b.exe", "c.exe"} {
+		p := createTestSFX(t, tmpDir, name, []struct {
+			Path    string
+			ModTime time.Time
+			Content []byte
+		}{
+			{Path: "readme.txt", ModTime: time.Now(), Content: []byte("payload")},
+		})
+
+		info, err := os.Stat(p)
+		require.NoError(t, err)
+		require.True(t, fsys.sfxArchive(p, info))
+	}
+
+	require.Equal(t, 2, fsys.sfx.entries.Len())
+	require.Nil(t, fsys.sfx.entries.Get(filepath.Join(tmpDir, "a.exe")))
+	require.NotNil(t, fsys.sfx.entries.Get(filepath.Join(tmpDir, "c.exe")))
+}
+
+// Expectation: Sniffing a file should hold a file descriptor within the limit,
+// so that it waits for one to be free (and frees it again once done).
+func Test_FS_SFXArchive_FDLimit_Success(t *testing.T) {
+	t.Parallel()
+	tmpDir, fsys := testFS(t, io.Discard)
+	fsys.Options.DetectSFX = true
+
+	p := createTestSFX(t, tmpDir, "setup.exe", []struct {
+		Path    string
+		ModTime time.Time
+		Content []byte
+	}{
+		{Path: "readme.txt", ModTime: time.Now(), Content: []byte("payload")},
+	})
+
+	info, err := os.Stat(p)
+	require.NoError(t, err)
+
+	for range fsys.FDLimit() {
+		fsys.fdlimit <- struct{}{}
+	}
+
+	done := make(chan bool)
+	go func() {
+		done <- fsys.sfxArchive(p, info)
+	}()
+
+	select {
+	case <-done:
+		t.Fatal("sniffed without a free file descriptor")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	<-fsys.fdlimit
+
+	select {
+	case sfx := <-done:
+		require.True(t, sfx)
+	case <-time.After(5 * time.Second):
+		t.Fatal("did not sniff with a free file descriptor")
+	}
+
+	require.Equal(t, fsys.FDLimit()-1, fsys.HeldFDs())
+}
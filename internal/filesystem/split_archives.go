@@ -0,0 +1,526 @@
+package filesystem
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/klauspost/compress/zip"
+)
+
+const (
+	// splitPartsMax is the maximum amount of parts of a split archive, as the
+	// number of the disk is a 16-bit field (without the zip64 end of directory).
+	splitPartsMax = 0xffff
+
+	dirEndSig       = 0x06054b50 // end of central directory record
+	dirEndLen       = 22         // without the comment
+	dir64EndSig     = 0x06064b50 // zip64 end of central directory record
+	dir64EndLen     = 56         // without the extensible data
+	dir64LocatorSig = 0x07064b50 // zip64 end of central directory locator
+	dir64LocatorLen = 20
+	dirHeaderSig    = 0x02014b50 // central directory file header
+	dirHeaderLen    = 46         // without the name, extra field and comment
+	zip64ExtraID    = 0x0001     // zip64 extended information extra field
+
+	uint16max = 0xffff
+	uint32max = 0xffffffff
+)
+
+var (
+	_ io.ReaderAt = (*splitReaderAt)(nil)
+	_ io.Closer   = (*splitReaderAt)(nil)
+
+	// errSplitPartMissing is for a split archive missing any of its parts.
+	errSplitPartMissing = errors.New("split archive part missing")
+
+	// errSplitDirectory is for a split archive with an invalid central directory.
+	errSplitDirectory = errors.New("invalid split archive directory")
+
+	// errSplitPartChanged is for a split archive part replaced while open.
+	errSplitPartChanged = errors.New("split archive part changed")
+)
+
+// splitParts returns the paths of the preceding parts of a split archive
+// (".z01", ".z02", ...) by the path of its final part (".zip"), as written
+// by e.g. WinZip or Info-ZIP, or none for an archive that is not split.
+func splitParts(path string) []string {
+	var parts []string
+
+	for n := 1; n < splitPartsMax; n++ {
+		part := fmt.Sprintf("%s.z%02d", strings.TrimSuffix(path, ".zip"), n)
+		if _, err := os.Stat(part); err != nil {
+			break
+		}
+		parts = append(parts, part)
+	}
+
+	return parts
+}
+
+// splitReaderAt is an [io.ReaderAt] of a split archive, concatenating all of
+// its parts in order. As the central directory of a split archive has the
+// offsets of the files relative to the part (disk) containing them, it is
+// rewritten with the offsets within the concatenation (as a single archive)
+// and appended after the parts, for a [zip.Reader] to find it at the end.
+//
+// Only one of the parts is held open at any time (as the archive takes only
+// one slot of the FD semaphore), with the reads switching between the parts
+// as needed, so the reads of a split archive are serialized (not concurrent).
+type splitReaderAt struct {
+	sync.Mutex
+
+	paths  []string
+	infos  []os.FileInfo // Of the parts as when opened (see Stale).
+	starts []int64       // Offsets of the parts within the concatenation.
+	size   int64         // Of the concatenated parts (without the tail).
+	tail   []byte        // Rewritten central directory (after the parts).
+
+	open    *os.File // The currently open part (or nil).
+	openIdx int      // The index of the currently open part.
+}
+
+// openSplit opens all parts of a split archive (see [splitParts]), with the
+// final part (".zip") being the last of them, returning a [splitReaderAt].
+// It needs to be closed, as it holds one of the parts open until then.
+func openSplit(parts []string) (*splitReaderAt, error) {
+	s := &splitReaderAt{paths: parts}
+
+	for _, part := range parts {
+		info, err := os.Stat(part)
+		if err != nil {
+			return nil, fmt.Errorf("failed to stat part: %w", err)
+		}
+		s.infos = append(s.infos, info)
+		s.starts = append(s.starts, s.size)
+		s.size += info.Size()
+	}
+
+	tail, err := s.directory()
+	if err != nil {
+		s.Close()
+
+		return nil, err
+	}
+	s.tail = tail
+
+	return s, nil
+}
+
+// openSplitArchive opens a split archive by its final part (path) and its
+// preceding parts, as for [FS.openArchive] (with the [os.FileInfo] being of
+// the final part). The parts are never opened with [Options.DirectIO].
+func openSplitArchive(path string, parts []string) (*zip.Reader, io.Closer, os.FileInfo, error) {
+	s, err := openSplit(append(parts, path))
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to open split: %w", err)
+	}
+
+	r, err := zip.NewReader(s, s.Size())
+	if err != nil {
+		s.Close()
+
+		return nil, nil, nil, fmt.Errorf("failed to read split: %w", err)
+	}
+
+	return registerDecompressors(r), s, s.infos[len(s.infos)-1], nil
+}
+
+// Size returns the size of the concatenated parts with the rewritten tail.
+func (s *splitReaderAt) Size() int64 {
+	return s.size + int64(len(s.tail))
+}
+
+// Stale returns if any of the parts is no longer the one that was opened, as
+// its file was replaced or modified (see [zipReader.Stale] for the final part).
+func (s *splitReaderAt) Stale() bool {
+	for i, part := range s.paths {
+		info, err := os.Stat(part)
+		if err != nil || fileChanged(s.infos[i], info) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// part returns the part at index i, opening it (and closing the previously
+// open part) if it is not already the open part. A part that is no longer
+// the one that was opened (see Stale) returns an [errSplitPartChanged].
+// The caller needs to hold the lock, and must not close the returned part.
+func (s *splitReaderAt) part(i int) (*os.File, error) {
+	if s.open != nil && s.openIdx == i {
+		return s.open, nil
+	}
+
+	if s.open != nil {
+		s.open.Close()
+		s.open = nil
+	}
+
+	f, err := os.Open(s.paths[i])
+	if err != nil {
+		return nil, fmt.Errorf("failed to open part: %w", err)
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+
+		return nil, fmt.Errorf("failed to stat part: %w", err)
+	}
+
+	if fileChanged(s.infos[i], info) {
+		f.Close()
+
+		return nil, fmt.Errorf("%w: %s", errSplitPartChanged, s.paths[i])
+	}
+
+	s.open, s.openIdx = f, i
+
+	return f, nil
+}
+
+func (s *splitReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, fmt.Errorf("%w: negative offset", os.ErrInvalid)
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	var n int
+
+	for len(p) > 0 {
+		if off >= s.size {
+			t := off - s.size
+			if t >= int64(len(s.tail)) {
+				return n, io.EOF
+			}
+
+			c := copy(p, s.tail[t:])
+			n += c
+			p = p[c:]
+			off += int64(c)
+
+			continue
+		}
+
+		i := sort.Search(len(s.starts), func(i int) bool { return s.starts[i] > off }) - 1
+
+		end := s.size
+		if i+1 < len(s.starts) {
+			end = s.starts[i+1]
+		}
+
+		chunk := p[:min(int64(len(p)), end-off)]
+
+		f, err := s.part(i)
+		if err != nil {
+			return n, err
+		}
+
+		c, err := f.ReadAt(chunk, off-s.starts[i])
+		n += c
+		p = p[c:]
+		off += int64(c)
+
+		if c < len(chunk) {
+			if err == nil || errors.Is(err, io.EOF) {
+				return n, io.ErrUnexpectedEOF // The part was truncated.
+			}
+
+			return n, err //nolint:wrapcheck
+		}
+	}
+
+	return n, nil
+}
+
+func (s *splitReaderAt) Close() error {
+	s.Lock()
+	defer s.Unlock()
+
+	if s.open == nil {
+		return nil
+	}
+
+	err := s.open.Close()
+	s.open = nil
+
+	return err //nolint:wrapcheck
+}
+
+// splitDirectoryEnd is the end of the central directory of a split archive,
+// as read from either the end of central directory record (or zip64 record).
+type splitDirectoryEnd struct {
+	disk    uint32 // Number of the disk (part) having the end record.
+	dirDisk uint32 // Number of the disk (part) the directory starts on.
+	entries uint64
+	size    uint64
+	offset  uint64 // Relative to the start of the dirDisk.
+	comment []byte
+}
+
+// directory reads the central directory of the split archive, returning it
+// rewritten with all offsets being those within the concatenation (and all
+// disk numbers being zero), followed by a new end of central directory.
+func (s *splitReaderAt) directory() ([]byte, error) {
+	end, err := s.directoryEnd()
+	if err != nil {
+		return nil, err
+	}
+
+	if int(end.disk) != len(s.paths)-1 {
+		return nil, fmt.Errorf("%w: found %d of %d parts", errSplitPartMissing, len(s.paths), end.disk+1)
+	}
+	if int(end.dirDisk) >= len(s.paths) {
+		return nil, fmt.Errorf("%w: directory on part %d", errSplitDirectory, end.dirDisk+1)
+	}
+
+	start := s.starts[end.dirDisk] + int64(end.offset)                            //nolint:gosec
+	if end.size > uint64(s.size) || start < 0 || start > s.size-int64(end.size) { //nolint:gosec
+		return nil, fmt.Errorf("%w: directory out of bounds", errSplitDirectory)
+	}
+
+	dir := make([]byte, end.size)
+	if _, err := s.ReadAt(dir, start); err != nil {
+		return nil, fmt.Errorf("failed to read directory: %w", err)
+	}
+
+	tail := make([]byte, 0, len(dir)+dir64EndLen+dir64LocatorLen+dirEndLen+len(end.comment))
+
+	for range end.entries {
+		var header []byte
+
+		header, dir, err = s.directoryHeader(dir)
+		if err != nil {
+			return nil, err
+		}
+		tail = append(tail, header...)
+	}
+
+	return appendDirectoryEnd(tail, end, s.size), nil
+}
+
+// directoryEnd reads the end of central directory (and the zip64 end of central
+// directory, if any) from the end of the final part of the split archive.
+func (s *splitReaderAt) directoryEnd() (*splitDirectoryEnd, error) {
+	last := s.starts[len(s.starts)-1]
+	n := min(s.size-last, dirEndLen+uint16max)
+
+	buf := make([]byte, n)
+	if _, err := s.ReadAt(buf, s.size-n); err != nil {
+		return nil, fmt.Errorf("failed to read directory end: %w", err)
+	}
+
+	p := -1
+	for i := len(buf) - dirEndLen; i >= 0; i-- {
+		if binary.LittleEndian.Uint32(buf[i:]) == dirEndSig &&
+			i+dirEndLen+int(binary.LittleEndian.Uint16(buf[i+20:])) == len(buf) {
+			p = i
+
+			break
+		}
+	}
+	if p < 0 {
+		return nil, fmt.Errorf("%w: no directory end", errSplitDirectory)
+	}
+
+	b := buf[p:]
+	end := &splitDirectoryEnd{
+		disk:    uint32(binary.LittleEndian.Uint16(b[4:])),
+		dirDisk: uint32(binary.LittleEndian.Uint16(b[6:])),
+		entries: uint64(binary.LittleEndian.Uint16(b[10:])),
+		size:    uint64(binary.LittleEndian.Uint32(b[12:])),
+		offset:  uint64(binary.LittleEndian.Uint32(b[16:])),
+		comment: b[dirEndLen:],
+	}
+
+	if end.disk != uint16max && end.dirDisk != uint16max && end.entries != uint16max &&
+		end.size != uint32max && end.offset != uint32max {
+		return end, nil
+	}
+
+	// The zip64 end of central directory locator precedes the end record.
+	if p < dir64LocatorLen || binary.LittleEndian.Uint32(buf[p-dir64LocatorLen:]) != dir64LocatorSig {
+		return nil, fmt.Errorf("%w: no zip64 directory end", errSplitDirectory)
+	}
+
+	loc := buf[p-dir64LocatorLen:]
+	disk := binary.LittleEndian.Uint32(loc[4:])
+	offset := binary.LittleEndian.Uint64(loc[8:])
+
+	if int(disk) >= len(s.starts) || offset > uint64(s.size) { //nolint:gosec
+		return nil, fmt.Errorf("%w: zip64 directory end out of bounds", errSplitDirectory)
+	}
+
+	b = make([]byte, dir64EndLen)
+	if _, err := s.ReadAt(b, s.starts[disk]+int64(offset)); err != nil { //nolint:gosec
+		return nil, fmt.Errorf("failed to read zip64 directory end: %w", err)
+	}
+	if binary.LittleEndian.Uint32(b) != dir64EndSig {
+		return nil, fmt.Errorf("%w: invalid zip64 directory end", errSplitDirectory)
+	}
+
+	end.disk = binary.LittleEndian.Uint32(b[16:])
+	end.dirDisk = binary.LittleEndian.Uint32(b[20:])
+	end.entries = binary.LittleEndian.Uint64(b[32:])
+	end.size = binary.LittleEndian.Uint64(b[40:])
+	end.offset = binary.LittleEndian.Uint64(b[48:])
+
+	return end, nil
+}
+
+// directoryHeader rewrites the first central directory file header of dir with
+// the offset of its local file header within the concatenation, returning it
+// and the remainder of dir. The offset is moved into the zip64 extra field
+// if it exceeds 32 bits, or was already stored within it (with a disk number).
+func (s *splitReaderAt) directoryHeader(dir []byte) ([]byte, []byte, error) {
+	if len(dir) < dirHeaderLen || binary.LittleEndian.Uint32(dir) != dirHeaderSig {
+		return nil, nil, fmt.Errorf("%w: invalid file header", errSplitDirectory)
+	}
+
+	nameLen := int(binary.LittleEndian.Uint16(dir[28:]))
+	extraLen := int(binary.LittleEndian.Uint16(dir[30:]))
+	commentLen := int(binary.LittleEndian.Uint16(dir[32:]))
+
+	n := dirHeaderLen + nameLen + extraLen + commentLen
+	if len(dir) < n {
+		return nil, nil, fmt.Errorf("%w: truncated file header", errSplitDirectory)
+	}
+
+	fixed := dir[:dirHeaderLen]
+	name := dir[dirHeaderLen : dirHeaderLen+nameLen]
+	extra := dir[dirHeaderLen+nameLen : dirHeaderLen+nameLen+extraLen]
+	comment := dir[dirHeaderLen+nameLen+extraLen : n]
+
+	usize := uint64(binary.LittleEndian.Uint32(fixed[24:]))
+	csize := uint64(binary.LittleEndian.Uint32(fixed[20:]))
+	offset := uint64(binary.LittleEndian.Uint32(fixed[42:]))
+	disk := uint32(binary.LittleEndian.Uint16(fixed[34:]))
+
+	// The zip64 extra field has only the values saturated in the fixed header.
+	var rest []byte
+	var zip64 bool
+
+	for e := extra; len(e) >= 4; {
+		id := binary.LittleEndian.Uint16(e)
+		size := int(binary.LittleEndian.Uint16(e[2:]))
+		if len(e) < 4+size {
+			return nil, nil, fmt.Errorf("%w: truncated extra field", errSplitDirectory)
+		}
+
+		if id != zip64ExtraID {
+			rest = append(rest, e[:4+size]...)
+			e = e[4+size:]
+
+			continue
+		}
+
+		v := e[4 : 4+size]
+		for _, f := range []*uint64{&usize, &csize, &offset} {
+			if *f == uint32max && len(v) >= 8 {
+				*f = binary.LittleEndian.Uint64(v)
+				v = v[8:]
+			}
+		}
+		if disk == uint16max && len(v) >= 4 {
+			disk = binary.LittleEndian.Uint32(v)
+		}
+
+		zip64 = true
+		e = e[4+size:]
+	}
+
+	if int(disk) >= len(s.starts) {
+		return nil, nil, fmt.Errorf("%w: file on part %d", errSplitPartMissing, disk+1)
+	}
+
+	abs := uint64(s.starts[disk]) + offset //nolint:gosec
+
+	header := make([]byte, 0, n+28) //nolint:mnd
+	header = append(header, fixed...)
+	binary.LittleEndian.PutUint16(header[34:], 0)
+
+	if zip64 || abs >= uint32max {
+		var v []byte
+		if binary.LittleEndian.Uint32(fixed[24:]) == uint32max {
+			v = binary.LittleEndian.AppendUint64(v, usize)
+		}
+		if binary.LittleEndian.Uint32(fixed[20:]) == uint32max {
+			v = binary.LittleEndian.AppendUint64(v, csize)
+		}
+		v = binary.LittleEndian.AppendUint64(v, abs)
+
+		e := binary.LittleEndian.AppendUint16(nil, zip64ExtraID)
+		e = binary.LittleEndian.AppendUint16(e, uint16(len(v))) //nolint:gosec
+		rest = append(append(e, v...), rest...)
+
+		if len(rest) > uint16max {
+			return nil, nil, fmt.Errorf("%w: extra field too large", errSplitDirectory)
+		}
+
+		binary.LittleEndian.PutUint32(header[42:], uint32max)
+		binary.LittleEndian.PutUint16(header[30:], uint16(len(rest))) //nolint:gosec
+	} else {
+		binary.LittleEndian.PutUint32(header[42:], uint32(abs))
+		rest = extra
+	}
+
+	header = append(header, name...)
+	header = append(header, rest...)
+	header = append(header, comment...)
+
+	return header, dir[n:], nil
+}
+
+// appendDirectoryEnd appends the end of central directory of the rewritten
+// directory (at offset) to it, with a zip64 end of central directory (and its
+// locator) preceding it if any of the values do not fit the end record.
+func appendDirectoryEnd(dir []byte, end *splitDirectoryEnd, offset int64) []byte {
+	size := uint64(len(dir))
+	entries := end.entries
+	off := uint64(offset) //nolint:gosec
+
+	if entries >= uint16max || size >= uint32max || off >= uint32max {
+		dir64 := off + size
+
+		dir = binary.LittleEndian.AppendUint32(dir, dir64EndSig)
+		dir = binary.LittleEndian.AppendUint64(dir, dir64EndLen-12) //nolint:mnd
+		dir = binary.LittleEndian.AppendUint16(dir, 45)             //nolint:mnd // version made by
+		dir = binary.LittleEndian.AppendUint16(dir, 45)             //nolint:mnd // version needed
+		dir = binary.LittleEndian.AppendUint32(dir, 0)
+		dir = binary.LittleEndian.AppendUint32(dir, 0)
+		dir = binary.LittleEndian.AppendUint64(dir, entries)
+		dir = binary.LittleEndian.AppendUint64(dir, entries)
+		dir = binary.LittleEndian.AppendUint64(dir, size)
+		dir = binary.LittleEndian.AppendUint64(dir, off)
+
+		dir = binary.LittleEndian.AppendUint32(dir, dir64LocatorSig)
+		dir = binary.LittleEndian.AppendUint32(dir, 0)
+		dir = binary.LittleEndian.AppendUint64(dir, dir64)
+		dir = binary.LittleEndian.AppendUint32(dir, 1)
+
+		entries = uint16max
+		size = uint32max
+		off = uint32max
+	}
+
+	dir = binary.LittleEndian.AppendUint32(dir, dirEndSig)
+	dir = binary.LittleEndian.AppendUint16(dir, 0)
+	dir = binary.LittleEndian.AppendUint16(dir, 0)
+	dir = binary.LittleEndian.AppendUint16(dir, uint16(entries))          //nolint:gosec
+	dir = binary.LittleEndian.AppendUint16(dir, uint16(entries))          //nolint:gosec
+	dir = binary.LittleEndian.AppendUint32(dir, uint32(size))             //nolint:gosec
+	dir = binary.LittleEndian.AppendUint32(dir, uint32(off))              //nolint:gosec
+	dir = binary.LittleEndian.AppendUint16(dir, uint16(len(end.comment))) //nolint:gosec
+	dir = append(dir, end.comment...)
+
+	return dir
+}
@@ -0,0 +1,193 @@
+package filesystem
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"bazil.org/fuse/fs"
+	"github.com/klauspost/compress/zip"
+	"github.com/stretchr/testify/require"
+)
+
+// createTestSplitZip creates a split archive of the given files (name without
+// the extension), with parts of partSize (".z01", ...) and the final ".zip"
+// part having the central directory, with all offsets relative to the parts.
+func createTestSplitZip(t *testing.T, tmpDir string, name string, files map[string][]byte, partSize int) string {
+	t.Helper()
+
+	var buf bytes.Buffer
+	buf.Write([]byte{0x50, 0x4b, 0x07, 0x08}) // split archive signature
+
+	zw := zip.NewWriter(&buf)
+	zw.SetOffset(4)
+	for path, content := range files {
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: path, Method: zip.Store, Modified: time.Now()})
+		require.NoError(t, err)
+		_, err = w.Write(content)
+		require.NoError(t, err)
+	}
+	require.NoError(t, zw.Close())
+
+	data := buf.Bytes()
+	end := len(data) - dirEndLen
+	require.Equal(t, uint32(dirEndSig), binary.LittleEndian.Uint32(data[end:]))
+
+	dirStart := int(binary.LittleEndian.Uint32(data[end+16:]))
+	entries := int(binary.LittleEndian.Uint16(data[end+10:]))
+
+	// The central directory (and its end) are all within the final part.
+	var starts []int
+	for off := 0; off < dirStart; off += partSize {
+		starts = append(starts, off)
+	}
+	last := len(starts) - 1
+
+	disk := func(off int) int {
+		return min(off/partSize, last)
+	}
+
+	for i, p := 0, dirStart; i < entries; i++ {
+		off := int(binary.LittleEndian.Uint32(data[p+42:]))
+		binary.LittleEndian.PutUint16(data[p+34:], uint16(disk(off)))
+		binary.LittleEndian.PutUint32(data[p+42:], uint32(off-starts[disk(off)]))
+
+		p += dirHeaderLen + int(binary.LittleEndian.Uint16(data[p+28:])) +
+			int(binary.LittleEndian.Uint16(data[p+30:])) + int(binary.LittleEndian.Uint16(data[p+32:]))
+	}
+
+	binary.LittleEndian.PutUint16(data[end+4:], uint16(last))
+	binary.LittleEndian.PutUint16(data[end+6:], uint16(last))
+	binary.LittleEndian.PutUint32(data[end+16:], uint32(dirStart-starts[last]))
+
+	for i, start := range starts {
+		part := filepath.Join(tmpDir, fmt.Sprintf("%s.z%02d", name, i+1))
+		if i == last {
+			part = filepath.Join(tmpDir, name+".zip")
+			require.NoError(t, os.WriteFile(part, data[start:], 0o644))
+
+			return part
+		}
+		require.NoError(t, os.WriteFile(part, data[start:starts[i+1]], 0o644))
+	}
+
+	return ""
+}
+
+// Expectation: A split archive should be opened by its final part, with the
+// files (also spanning the parts) being read as from a single archive.
+func Test_FS_SplitArchive_Success(t *testing.T) {
+	t.Parallel()
+	tmpDir, fsys := testFS(t, io.Discard)
+
+	files := map[string][]byte{
+		"dir/first.txt": bytes.Repeat([]byte("first "), 500),
+		"second.txt":    bytes.Repeat([]byte("second "), 700),
+		"third.bin":     []byte(strings.Repeat("0123456789", 300)),
+	}
+	zipPath := createTestSplitZip(t, tmpDir, "split", files, 1000)
+
+	parts := splitParts(zipPath)
+	require.Greater(t, len(parts), 1)
+	require.Equal(t, filepath.Join(tmpDir, "split.z01"), parts[0])
+
+	for path, content := range files {
+		var buf bytes.Buffer
+		_, err := fsys.ExtractEntry(zipPath, path, 0, -1, &buf)
+		require.NoError(t, err, path)
+		require.Equal(t, content, buf.Bytes(), path)
+	}
+
+	root := &realDirNode{fsys: fsys, inode: 1, path: tmpDir, mtime: time.Now()}
+
+	ent, err := root.ReadDirAll(t.Context())
+	require.NoError(t, err)
+	require.Len(t, ent, 1)
+	require.Equal(t, "split", ent[0].Name)
+
+	node := &zipDirNode{fsys: fsys, inode: fs.GenerateDynamicInode(1, "split"), path: zipPath, mtime: time.Now()}
+
+	ent, err = node.ReadDirAll(t.Context())
+	require.NoError(t, err)
+	require.Len(t, ent, 3)
+}
+
+// Expectation: A split archive missing any of its parts should fail to open.
+func Test_FS_SplitArchive_MissingPart_Error(t *testing.T) {
+	t.Parallel()
+	tmpDir, fsys := testFS(t, io.Discard)
+
+	zipPath := createTestSplitZip(t, tmpDir, "split", map[string][]byte{
+		"file.txt": bytes.Repeat([]byte("content "), 1000),
+	}, 1000)
+
+	parts := splitParts(zipPath)
+	require.NoError(t, os.Remove(parts[len(parts)-1]))
+
+	_, err := fsys.ExtractEntry(zipPath, "file.txt", 0, -1, io.Discard)
+	require.ErrorIs(t, err, ErrArchiveUnreadable)
+	require.ErrorIs(t, err, errSplitPartMissing)
+}
+
+// Expectation: A split archive should be stale when any of its preceding
+// parts is replaced, also when the final part is left as it was.
+func Test_zipReader_Stale_SplitPart_Success(t *testing.T) {
+	t.Parallel()
+	tmpDir, fsys := testFS(t, io.Discard)
+
+	zipPath := createTestSplitZip(t, tmpDir, "split", map[string][]byte{
+		"file.txt": bytes.Repeat([]byte("content "), 1000),
+	}, 1000)
+	parts := splitParts(zipPath)
+
+	zr, err := newZipReader(fsys, zipPath)
+	require.NoError(t, err)
+	defer zr.Release() //nolint:errcheck
+
+	require.False(t, zr.Stale(zipPath))
+
+	data, err := os.ReadFile(parts[0])
+	require.NoError(t, err)
+	tmp := filepath.Join(tmpDir, "replacement")
+	require.NoError(t, os.WriteFile(tmp, data, 0o644))
+	require.NoError(t, os.Rename(tmp, parts[0]))
+
+	require.True(t, zr.Stale(zipPath))
+}
+
+// Expectation: A split archive should hold only one of its parts open, and
+// fail reading a part that was replaced since the archive was opened.
+func Test_splitReaderAt_ReadAt_PartChanged_Error(t *testing.T) {
+	t.Parallel()
+	tmpDir, _ := testFS(t, io.Discard)
+
+	zipPath := createTestSplitZip(t, tmpDir, "split", map[string][]byte{
+		"file.txt": bytes.Repeat([]byte("content "), 1000),
+	}, 1000)
+	parts := splitParts(zipPath)
+
+	s, err := openSplit(append(parts, zipPath))
+	require.NoError(t, err)
+	defer s.Close()
+
+	buf := make([]byte, s.Size())
+	_, err = s.ReadAt(buf, 0)
+	require.NoError(t, err)
+	require.NotNil(t, s.open)
+	require.Equal(t, len(parts), s.openIdx) // Only the final part is open.
+
+	data, err := os.ReadFile(parts[0])
+	require.NoError(t, err)
+	tmp := filepath.Join(tmpDir, "replacement")
+	require.NoError(t, os.WriteFile(tmp, data, 0o644))
+	require.NoError(t, os.Rename(tmp, parts[0]))
+
+	_, err = s.ReadAt(buf[:1], 0)
+	require.ErrorIs(t, err, errSplitPartChanged)
+}
@@ -0,0 +1,77 @@
+package filesystem
+
+import "math"
+
+// ThresholdMode is how a file matching a [ThresholdRule] is being read.
+type ThresholdMode int
+
+const (
+	// ThresholdModeMemory loads the matching files fully into RAM
+	// (as [zipInMemoryFileNode]), regardless of the streaming threshold.
+	ThresholdModeMemory ThresholdMode = iota
+
+	// ThresholdModeStream streams the matching files in chunks
+	// (as [zipDiskStreamFileNode]), regardless of the streaming threshold.
+	ThresholdModeStream
+)
+
+// ThresholdRule is a declarative rule for [Options.ThresholdRules], deciding
+// the [ThresholdMode] for files within ZIPs by their extension and size.
+// All set conditions need to be met for a file to be matching the rule.
+type ThresholdRule struct {
+	// Extensions are the (case-insensitive) file extensions matched,
+	// with or without a leading dot. When empty, any extension matches.
+	Extensions []string
+
+	// MinSize is the (inclusive) minimum size of the matched files.
+	MinSize uint64
+
+	// MaxSize is the (exclusive) maximum size of the matched files.
+	// A value of zero means that the size is not limited upwards.
+	MaxSize uint64
+
+	// Mode is the [ThresholdMode] for the files matching the rule.
+	Mode ThresholdMode
+}
+
+// Match returns if a file (by its name and size) is matching the rule.
+func (r ThresholdRule) Match(name string, size uint64) bool {
+	if len(r.Extensions) > 0 && !hasExtension(name, r.Extensions) {
+		return false
+	}
+
+	if size < r.MinSize {
+		return false
+	}
+
+	if r.MaxSize > 0 && size >= r.MaxSize {
+		return false
+	}
+
+	return true
+}
+
+// streamEntry returns if a file within an archive should be streamed, as
+// decided by the first matching [Options.ThresholdRules] (if any), otherwise
+// by the [Options.StreamingThreshold] (as overridden for the archive).
+// Files too large to be held in memory at once (as on 32-bit systems) are
+// always streamed, regardless of any of the rules or streaming threshold.
+func (fsys *FS) streamEntry(archive string, name string, size uint64) bool {
+	stream := size > fsys.streamingThreshold(archive)
+
+	for _, r := range fsys.Options.ThresholdRules {
+		if r.Match(name, size) {
+			stream = r.Mode == ThresholdModeStream
+
+			break
+		}
+	}
+
+	if !stream && size > math.MaxInt {
+		fsys.rbuf.Printf("Warning: %q->%q: %d bytes exceed the addressable memory, streaming instead\n", archive, name, size)
+
+		return true
+	}
+
+	return stream
+}
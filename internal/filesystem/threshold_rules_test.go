@@ -0,0 +1,100 @@
+package filesystem
+
+import (
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Expectation: Match should match the extensions case-insensitively, with or without a leading dot.
+func Test_ThresholdRule_Match_Extensions_Success(t *testing.T) {
+	t.Parallel()
+
+	r := ThresholdRule{Extensions: []string{"mp4", ".MKV"}}
+
+	require.True(t, r.Match("video.mp4", 1))
+	require.True(t, r.Match("dir/video.MP4", 1))
+	require.True(t, r.Match("video.mkv", 1))
+	require.False(t, r.Match("video.avi", 1))
+	require.False(t, r.Match("mp4", 1))
+	require.False(t, r.Match("video.mp4.txt", 1))
+}
+
+// Expectation: Match should match the sizes within an inclusive minimum and exclusive maximum.
+func Test_ThresholdRule_Match_Sizes_Success(t *testing.T) {
+	t.Parallel()
+
+	r := ThresholdRule{MinSize: 10, MaxSize: 20}
+
+	require.False(t, r.Match("file", 9))
+	require.True(t, r.Match("file", 10))
+	require.True(t, r.Match("file", 19))
+	require.False(t, r.Match("file", 20))
+
+	r = ThresholdRule{MinSize: 10}
+	require.True(t, r.Match("file", 1<<40))
+}
+
+// Expectation: Match should match any file when a rule has no conditions.
+func Test_ThresholdRule_Match_Empty_Success(t *testing.T) {
+	t.Parallel()
+
+	r := ThresholdRule{}
+
+	require.True(t, r.Match("file.txt", 0))
+	require.True(t, r.Match("file", 1<<40))
+}
+
+// Expectation: streamEntry should apply the first matching rule, otherwise the streaming threshold.
+func Test_FS_streamEntry_Success(t *testing.T) {
+	t.Parallel()
+	_, fsys := testFS(t, io.Discard)
+
+	fsys.Options.StreamingThreshold.Store(100)
+	fsys.Options.ThresholdRules = []ThresholdRule{
+		{Extensions: []string{"mp4", "mkv"}, Mode: ThresholdModeStream},
+		{Extensions: []string{"json", "txt"}, MaxSize: 1000, Mode: ThresholdModeMemory},
+		{Extensions: []string{"txt"}, Mode: ThresholdModeMemory},
+	}
+
+	require.True(t, fsys.streamEntry("test.zip", "video.mp4", 1))
+	require.False(t, fsys.streamEntry("test.zip", "data.json", 999))
+	require.True(t, fsys.streamEntry("test.zip", "data.json", 1000))
+	require.False(t, fsys.streamEntry("test.zip", "data.txt", 1<<30))
+	require.False(t, fsys.streamEntry("test.zip", "other.bin", 100))
+	require.True(t, fsys.streamEntry("test.zip", "other.bin", 101))
+}
+
+// Expectation: Threshold rules should decide the type of file nodes, taking precedence over the threshold.
+func Test_zipDirNode_fileNode_ThresholdRules_Success(t *testing.T) {
+	t.Parallel()
+	tmpDir, fsys := testFS(t, io.Discard)
+	tnow := time.Now()
+
+	zipPath := createTestZip(t, tmpDir, "test.zip", []struct {
+		Path    string
+		ModTime time.Time
+		Content []byte
+	}{
+		{Path: "video.mp4", ModTime: tnow, Content: []byte("content")},
+		{Path: "large.txt", ModTime: tnow, Content: []byte("larger content")},
+	})
+
+	fsys.Options.StreamingThreshold.Store(10)
+	fsys.Options.ThresholdRules = []ThresholdRule{
+		{Extensions: []string{"mp4"}, Mode: ThresholdModeStream},
+		{Extensions: []string{"txt"}, MaxSize: 1024, Mode: ThresholdModeMemory},
+	}
+
+	node := &zipDirNode{fsys: fsys, path: zipPath, mtime: tnow}
+
+	lk, err := node.lookupNested(t.Context(), "video.mp4")
+	require.NoError(t, err)
+	require.IsType(t, &zipDiskStreamFileNode{}, lk)
+
+	lk, err = node.lookupNested(t.Context(), "large.txt")
+	require.NoError(t, err)
+	require.IsType(t, &zipInMemoryFileNode{}, lk)
+}
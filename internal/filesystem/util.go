@@ -5,23 +5,55 @@ import (
 	"encoding/binary"
 	"errors"
 	"fmt"
+	"math"
+	"math/rand/v2"
 	"os"
+	"path"
 	"path/filepath"
+	"slices"
 	"strings"
 	"syscall"
 	"time"
 	"unicode/utf8"
 
 	"bazil.org/fuse"
+	"github.com/klauspost/compress/flate"
 	"github.com/klauspost/compress/zip"
 )
 
+const (
+	// nameMax is the maximum length (in bytes) of a single name, as imposed by
+	// the kernel (NAME_MAX), with any longer names within ZIPs being truncated.
+	nameMax = 255
+
+	// nameMaxExt is the maximum length of an extension to be kept on truncation.
+	nameMaxExt = 32
+
+	// symlinkMax is the maximum length (in bytes) of the target of a symlink
+	// within ZIPs, as imposed by the kernel on paths (PATH_MAX).
+	symlinkMax = 4096
+
+	// symlinkHops is the maximum amount of symlinks followed for resolving
+	// a symlink within ZIPs, as imposed by the kernel (MAXSYMLINKS).
+	symlinkHops = 40
+)
+
 // zipMetric is a single measurement of a ZIP operation.
 type zipMetric struct {
 	fsys      *FS
 	isExtract bool
 	startTime time.Time
 	readBytes int64
+	compBytes int64
+	trace     *zipTrace
+}
+
+// zipTrace is the context of a [zipMetric] sampled for tracing.
+type zipTrace struct {
+	op      string
+	archive string
+	path    string
+	cache   cacheResult
 }
 
 // newZipMetric returns a pointer to a new [zipMetric] for a single
@@ -34,31 +66,130 @@ func newZipMetric(fsys *FS, isExtract bool) *zipMetric {
 		startTime: time.Now(),
 		isExtract: isExtract,
 		readBytes: 0,
+		compBytes: 0,
 	}
 }
 
 // Done closes the single measurement of a ZIP operation and adds the
 // field values to the filesystem metrics, so ensures saving of the metrics.
 func (m *zipMetric) Done() {
+	if m.trace != nil {
+		cache := m.trace.cache
+		if cache == "" {
+			cache = "-"
+		}
+		m.fsys.rbuf.Printf("Trace: op=%s archive=%q path=%q duration=%s cache=%s bytes=%d\n",
+			m.trace.op, m.trace.archive, m.trace.path, time.Since(m.startTime), cache, m.readBytes)
+	}
+
 	if m.isExtract {
 		m.fsys.Metrics.TotalExtractTime.Add(time.Since(m.startTime).Nanoseconds())
 		m.fsys.Metrics.TotalExtractCount.Add(1)
 		m.fsys.Metrics.TotalExtractBytes.Add(m.readBytes)
+		m.fsys.Metrics.TotalCompressedBytesRead.Add(m.compBytes)
 	} else {
 		m.fsys.Metrics.TotalMetadataReadTime.Add(time.Since(m.startTime).Nanoseconds())
 		m.fsys.Metrics.TotalMetadataReadCount.Add(1)
 	}
 }
 
-// toFuseErr inspects an error chain for a [syscall.Errno] and returns it
-// when found, otherwise trying for the next best fit to return as Errno.
+// Trace samples the measurement for tracing (with [Options.TraceSample]),
+// in which case a trace line is emitted on Done() with the given context.
+func (m *zipMetric) Trace(op, archive, path string) {
+	if sample := m.fsys.Options.TraceSample; sample > 0 && rand.Float64() < sample {
+		m.trace = &zipTrace{op: op, archive: archive, path: path}
+	}
+}
+
+// Cached records the [cacheResult] of the measurement (if it is traced).
+func (m *zipMetric) Cached(res cacheResult) {
+	if m.trace != nil {
+		m.trace.cache = res
+	}
+}
+
+// compressedBytes estimates the compressed bytes consumed for n extracted
+// (uncompressed) bytes of a [zip.File], in proportion of its compression.
+// Stored (non-compressed) files always consume exactly as much as extracted.
+func compressedBytes(f *zip.File, n int64) int64 {
+	if f.Method == zip.Store || f.UncompressedSize64 == 0 {
+		return n
+	}
+
+	return int64(float64(n) * float64(f.CompressedSize64) / float64(f.UncompressedSize64))
+}
+
+var _ fuse.ErrorNumber = (*fuseError)(nil)
+
+// fuseError is a [fuse.ErrorNumber] that retains the error it was converted
+// from, so that callers can still inspect the chain (e.g. for [ErrEntryNotFound]).
+type fuseError struct {
+	errno fuse.Errno
+	err   error
+}
+
+func (e *fuseError) Error() string {
+	return e.err.Error()
+}
+
+// Errno returns the [fuse.Errno] that is sent as the response to the kernel.
+func (e *fuseError) Errno() fuse.Errno {
+	return e.errno
+}
+
+// Unwrap returns both the original error and the [fuse.Errno],
+// so that either can be matched using [errors.Is] by any caller.
+func (e *fuseError) Unwrap() []error {
+	return []error{e.err, e.errno}
+}
+
+// toFuseErr converts an error into an error that is understood by the FUSE
+// layer. The exported sentinel errors of this package are mapped to their
+// respective errno first, otherwise the error chain is inspected for any
+// [syscall.Errno], then trying for the next best fit to return as Errno.
 // If no compatible error can be approximated, it defaults to [syscall.EIO].
+//
+// A plain [syscall.Errno] is returned as [fuse.Errno], any other error is
+// wrapped into a [fuseError] retaining the chain for programmatic callers.
 func toFuseErr(err error) error {
-	var errno syscall.Errno
-	if errors.As(err, &errno) {
+	if errno, ok := err.(syscall.Errno); ok { //nolint:errorlint
 		return fuse.ToErrno(errno)
 	}
+
+	return &fuseError{errno: toErrno(err), err: err}
+}
+
+// wrapFuseErr returns a [fuseError] with the given errno, retaining err for
+// callers, for where the errno is established behavior regardless of cause.
+func wrapFuseErr(errno syscall.Errno, err error) error {
+	return &fuseError{errno: fuse.ToErrno(errno), err: err}
+}
+
+// toErrno returns the next best fit [fuse.Errno] for an error chain.
+func toErrno(err error) fuse.Errno {
+	var errno syscall.Errno
 	switch {
+	case errors.Is(err, ErrEntryNotFound):
+		return fuse.ToErrno(syscall.ENOENT)
+
+	case errors.Is(err, errArchivesPaused):
+		return fuse.ToErrno(syscall.EAGAIN)
+
+	case errors.Is(err, ErrArchiveUnreadable):
+		return fuse.ToErrno(syscall.EINVAL)
+
+	case errors.Is(err, ErrCorruptEntry):
+		return fuse.ToErrno(syscall.EIO)
+
+	case errors.Is(err, ErrUnsupportedMethod):
+		return fuse.ToErrno(syscall.EOPNOTSUPP)
+
+	case errors.Is(err, ErrEncryptedEntry):
+		return fuse.ToErrno(syscall.EACCES)
+
+	case errors.As(err, &errno):
+		return fuse.ToErrno(errno)
+
 	case os.IsNotExist(err):
 		return fuse.ToErrno(syscall.ENOENT)
 
@@ -70,15 +201,102 @@ func toFuseErr(err error) error {
 	}
 }
 
+// toEntryErr wraps [ErrCorruptEntry] around an error of a ZIP-contained file
+// failing integrity checking or decompression. Any other error (including
+// [io.EOF]) is returned unchanged, so it can still be compared by callers.
+func toEntryErr(err error) error {
+	var corrupt flate.CorruptInputError
+	if errors.Is(err, zip.ErrChecksum) || errors.Is(err, zip.ErrFormat) ||
+		errors.Is(err, zip.ErrAlgorithm) || errors.As(err, &corrupt) {
+		return fmt.Errorf("%w: %w", ErrCorruptEntry, err)
+	}
+
+	return err
+}
+
 // isDir checks if [zip.File] is a directory either by mode or normalized path.
 func isDir(f *zip.File, normalizedPath string) bool {
 	return f.FileInfo().IsDir() || strings.HasSuffix(normalizedPath, "/")
 }
 
+// isSymlink checks if [zip.File] is a symlink by its (Unix) mode.
+func isSymlink(f *zip.File) bool {
+	return f.Mode()&os.ModeSymlink != 0
+}
+
+// resolveSymlink returns the normalized path that the target of a symlink
+// (at a normalized path) points to within the archive, or an empty string
+// for an absolute target or one pointing outside of the archive.
+func resolveSymlink(normalizedPath string, target string) string {
+	if target == "" || strings.HasPrefix(target, "/") {
+		return ""
+	}
+
+	resolved := path.Join(path.Dir(normalizedPath), target)
+	if resolved == "." || resolved == ".." || strings.HasPrefix(resolved, "../") {
+		return ""
+	}
+
+	return resolved
+}
+
+// followSymlinks follows a symlink to the regular file it ultimately resolves
+// to, by the files and the (resolved) targets of the symlinks of the archive,
+// returning nil if dangling or exceeding [symlinkHops] (e.g. being a loop).
+func followSymlinks(f *zip.File, files map[string]*zip.File, targets map[*zip.File]string) *zip.File {
+	for range symlinkHops {
+		next, ok := files[targets[f]]
+		if !ok {
+			return nil
+		}
+
+		if _, ok := targets[next]; !ok {
+			return next
+		}
+		f = next
+	}
+
+	return nil
+}
+
+// hasExtension checks if a name has any of the (case-insensitive) extensions,
+// which can be given with or without a leading dot. Names without any
+// extension (e.g. "README") never have any of the extensions.
+func hasExtension(name string, exts []string) bool {
+	ext := strings.TrimPrefix(path.Ext(name), ".")
+	if ext == "" {
+		return false
+	}
+
+	return slices.ContainsFunc(exts, func(e string) bool {
+		return strings.EqualFold(strings.TrimPrefix(e, "."), ext)
+	})
+}
+
+// hiddenEntry checks if a [zip.File] is hidden by [Options.OnlyExtensions],
+// so only exposing files having any of the listed extensions. Directories are
+// hidden also, so they only appear implicitly (by any exposed files contained
+// within them), meaning that directories which would be empty are never shown.
+func (fsys *FS) hiddenEntry(f *zip.File, normalizedPath string) bool {
+	if len(fsys.Options.OnlyExtensions) == 0 {
+		return false
+	}
+
+	return isDir(f, normalizedPath) || !hasExtension(normalizedPath, fsys.Options.OnlyExtensions)
+}
+
 // zipEntryNormalize ensures ZIP paths use slashes and removes malformations.
 // It also handles non-unicode paths, trying to get the unicode representation
 // or instead falling back to a generation using ZIP file index and/or hashing.
-func zipEntryNormalize(index int, f *zip.File, forceUnicode bool) string {
+// The path is then canonicalized into the given Unicode normalization form,
+// with any names exceeding [nameMax] truncated (see [zipEntryTruncate]).
+func zipEntryNormalize(index int, f *zip.File, forceUnicode bool, form UnicodeNormalization) string {
+	return zipEntryTruncate(zipEntryNormalizeLong(index, f, forceUnicode, form))
+}
+
+// zipEntryNormalizeLong is [zipEntryNormalize] without the truncation of any
+// names exceeding [nameMax], e.g. for logging the names which were truncated.
+func zipEntryNormalizeLong(index int, f *zip.File, forceUnicode bool, form UnicodeNormalization) string {
 	var path string
 	var isUnicode bool
 
@@ -94,6 +312,7 @@ func zipEntryNormalize(index int, f *zip.File, forceUnicode bool) string {
 	}
 
 	path = filepath.ToSlash(path)
+	path = zipEntryFromWindows(path)
 
 	for strings.Contains(path, "//") {
 		path = strings.ReplaceAll(path, "//", "/")
@@ -106,6 +325,65 @@ func zipEntryNormalize(index int, f *zip.File, forceUnicode bool) string {
 		path = zipEntryUnicodeFallback(index, path)
 	}
 
+	return form.apply(path)
+}
+
+// zipEntryTruncate truncates all names of a normalized path which exceed
+// [nameMax] (see [truncateName]), as these could neither be listed nor looked up.
+func zipEntryTruncate(normalizedPath string) string {
+	if len(normalizedPath) <= nameMax {
+		return normalizedPath // Fast path, as no name can exceed it.
+	}
+
+	parts := strings.Split(normalizedPath, "/")
+	for i, part := range parts {
+		parts[i] = truncateName(part)
+	}
+
+	return strings.Join(parts, "/")
+}
+
+// truncateName truncates a name exceeding [nameMax] bytes (the limit of the
+// kernel for a single name) to one within it, by cutting it (at a rune) and
+// appending a hash of the entire name before its extension (if any), so that
+// truncated names are deterministic and remain unique (as their full names).
+func truncateName(name string) string {
+	if len(name) <= nameMax {
+		return name
+	}
+
+	ext := filepath.Ext(name)
+	if len(ext) > nameMaxExt {
+		ext = "" // Not a real extension, and would not leave enough room.
+	}
+
+	hash := fmt.Sprintf("~%x", sha1.Sum([]byte(name)))[:9]
+
+	cut := nameMax - len(hash) - len(ext)
+	for cut > 0 && !utf8.RuneStart(name[cut]) {
+		cut--
+	}
+
+	return name[:cut] + hash + ext
+}
+
+// zipEntryFromWindows converts the OS-native paths of (ancient) Windows ZIP
+// tools, which are using backslash separators and/or drive letter prefixes.
+// The backslashes are only converted if a path has no slashes at all, as these
+// are otherwise valid within names, so that slash-separated paths are kept.
+// A drive letter prefix (e.g. "C:/") is stripped, with the slash following.
+//
+//nolint:mnd
+func zipEntryFromWindows(path string) string {
+	if !strings.Contains(path, "/") {
+		path = strings.ReplaceAll(path, "\\", "/")
+	}
+
+	if len(path) >= 3 && path[1] == ':' && path[2] == '/' &&
+		(('a' <= path[0] && path[0] <= 'z') || ('A' <= path[0] && path[0] <= 'Z')) {
+		path = path[3:]
+	}
+
 	return path
 }
 
@@ -143,6 +421,124 @@ func zipEntryUnicodeFromExtra(f *zip.File) (string, bool) {
 	return "", false
 }
 
+// zipEntryUnixExtra contains the values of the Info-ZIP Unix extra fields.
+// Any zero-value timestamps or a false hasOwner were not found in the entry.
+type zipEntryUnixExtra struct {
+	mtime    time.Time
+	atime    time.Time
+	uid      uint32
+	gid      uint32
+	hasOwner bool
+}
+
+// zipEntryUnixFromExtra tries to parse the Extra field of a [zip.File] for the
+// extended timestamp (header ID 0x5455) and the Info-ZIP Unix uid/gid field
+// (header ID 0x7875). Absent or malformed fields are left at their zero value.
+//
+//nolint:mnd
+func zipEntryUnixFromExtra(f *zip.File) zipEntryUnixExtra {
+	var ux zipEntryUnixExtra
+
+	extra := f.Extra
+
+	i := 0
+	for i+4 <= len(extra) {
+		headerID := binary.LittleEndian.Uint16(extra[i:])
+		dataSize := binary.LittleEndian.Uint16(extra[i+2:])
+		i += 4
+		if i+int(dataSize) > len(extra) {
+			break
+		}
+
+		data := extra[i : i+int(dataSize)]
+		i += int(dataSize)
+
+		switch headerID {
+		case 0x5455:
+			if len(data) < 1 {
+				continue
+			}
+
+			flags := data[0]
+			data = data[1:]
+
+			// The central directory may set flags without the data.
+			if flags&0x01 != 0 && len(data) >= 4 {
+				ux.mtime = time.Unix(int64(int32(binary.LittleEndian.Uint32(data))), 0)
+				data = data[4:]
+			}
+			if flags&0x02 != 0 && len(data) >= 4 {
+				ux.atime = time.Unix(int64(int32(binary.LittleEndian.Uint32(data))), 0)
+			}
+
+		case 0x7875:
+			if len(data) < 1 || data[0] != 1 { // Version
+				continue
+			}
+
+			uid, rest, ok := zipEntryUnixID(data[1:])
+			if !ok {
+				continue
+			}
+
+			gid, _, ok := zipEntryUnixID(rest)
+			if !ok {
+				continue
+			}
+
+			ux.uid = uid
+			ux.gid = gid
+			ux.hasOwner = true
+		}
+	}
+
+	return ux
+}
+
+// zipEntryUnixID parses a size-prefixed little-endian ID of the 0x7875 field.
+// It returns the ID, the remaining data and if the ID was parsed successfully.
+//
+//nolint:mnd
+func zipEntryUnixID(data []byte) (uint32, []byte, bool) {
+	if len(data) < 1 {
+		return 0, nil, false
+	}
+
+	size := int(data[0])
+	if size < 1 || size > 8 || len(data) < 1+size {
+		return 0, nil, false
+	}
+
+	var id uint64
+	for j := size - 1; j >= 0; j-- {
+		id = id<<8 | uint64(data[1+j])
+	}
+
+	if id > math.MaxUint32 {
+		return 0, nil, false
+	}
+
+	return uint32(id), data[1+size:], true
+}
+
+// zipEntryModified returns the modified time of a [zip.File], with a time only
+// stored as MS-DOS date and time (having no timezone) being interpreted in the
+// [TimestampZone]. The reader interprets such times as UTC, while any extended
+// timestamp (e.g. of the Unix or NTFS extra fields) is absolute, so is unchanged.
+func zipEntryModified(f *zip.File, tz TimestampZone) time.Time {
+	m := f.Modified
+
+	if tz != TimestampLocal || m.Location() != time.UTC {
+		return m
+	}
+
+	if f.ModifiedDate == 0 && f.ModifiedTime == 0 { //nolint:staticcheck
+		return m // Only an extended timestamp (without MS-DOS date and time).
+	}
+
+	return time.Date(m.Year(), m.Month(), m.Day(), m.Hour(), m.Minute(), m.Second(), m.Nanosecond(), time.Local)
+}
+
 // zipEntryUnicodeFallback tries to salvage as much UTF8 of the original ZIP path
 // as possible, fallback to generation using archive-internal index and hashing.
 func zipEntryUnicodeFallback(index int, normalizedPath string) string {
@@ -170,8 +566,9 @@ func zipEntryUnicodeFallback(index int, normalizedPath string) string {
 }
 
 // flatEntryName flattens a normalized path to a filename, discarding structure.
+// The index is appended to dotfiles (without an extension) by [DotfileFlatStyle].
 // Path collisions are avoided via appending of the index to the filename base.
-func flatEntryName(index int, normalizedPath string) (string, bool) {
+func flatEntryName(index int, normalizedPath string, dotfiles DotfileFlatStyle) (string, bool) {
 	cleanedEntryName := filepath.Clean(normalizedPath)
 
 	if strings.HasPrefix(cleanedEntryName, "..") {
@@ -186,5 +583,80 @@ func flatEntryName(index int, normalizedPath string) (string, bool) {
 	ext := filepath.Ext(baseName)
 	nameWithoutExt := strings.TrimSuffix(baseName, ext)
 
-	return fmt.Sprintf("%s(%d)%s", nameWithoutExt, index, ext), true
+	if nameWithoutExt == "" && dotfiles == DotfileFlatSuffix {
+		nameWithoutExt, ext = ext, ""
+	}
+
+	return truncateName(fmt.Sprintf("%s(%d)%s", nameWithoutExt, index, ext)), true
+}
+
+// flatEntryNames flattens the normalized paths of all entries of an archive to
+// filenames, resolving collisions according to the [FlatCollisionStrategy]
+// (indexing the names of dotfiles according to the [DotfileFlatStyle]).
+// Paths of directories should be passed as empty strings, and are returned as
+// empty strings, same as any paths which cannot be flattened (being invalid).
+func flatEntryNames(normalizedPaths []string, strategy FlatCollisionStrategy, dotfiles DotfileFlatStyle) []string {
+	names := make([]string, len(normalizedPaths))
+
+	if strategy != FlatCollisionDirectory {
+		for i, p := range normalizedPaths {
+			if p == "" {
+				continue
+			}
+			if name, ok := flatEntryName(i, p, dotfiles); ok {
+				names[i] = name
+			}
+		}
+
+		return names
+	}
+
+	baseCounts := make(map[string]int)
+
+	for i, p := range normalizedPaths {
+		if p == "" {
+			continue
+		}
+		if _, ok := flatEntryName(i, p, dotfiles); !ok {
+			continue
+		}
+		names[i] = filepath.Base(filepath.Clean(p))
+		baseCounts[names[i]]++
+	}
+
+	nameCounts := make(map[string]int)
+
+	for i, name := range names {
+		if name == "" {
+			continue
+		}
+		if baseCounts[name] > 1 {
+			if dir := filepath.Base(filepath.Dir(filepath.Clean(normalizedPaths[i]))); dir != "." {
+				names[i] = truncateName(dir + "_" + name)
+			}
+		}
+		nameCounts[names[i]]++
+	}
+
+	for i, name := range names {
+		if name == "" || nameCounts[name] <= 1 {
+			continue
+		}
+		names[i], _ = flatEntryName(i, normalizedPaths[i], dotfiles)
+	}
+
+	return names
+}
+
+// hiddenPath returns if any of the elements of a slash-separated path (relative
+// to the source directory) is hidden, having a name starting with a "." (see
+// [Options.HideHidden]), so that it is not presented within the filesystem.
+func hiddenPath(rel string) bool {
+	for name := range strings.SplitSeq(rel, "/") {
+		if name != "." && strings.HasPrefix(name, ".") {
+			return true
+		}
+	}
+
+	return false
 }
@@ -1,16 +1,21 @@
 package filesystem
 
 import (
+	"bytes"
 	"encoding/binary"
 	"errors"
+	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"strings"
 	"syscall"
 	"testing"
 	"time"
+	"unicode/utf8"
 
 	"bazil.org/fuse"
+	"github.com/klauspost/compress/flate"
 	"github.com/klauspost/compress/zip"
 	"github.com/stretchr/testify/require"
 )
@@ -60,6 +65,7 @@ func Test_zipMetric_Done_Extract_Success(t *testing.T) {
 
 	zm := newZipMetric(fsys, true)
 	zm.readBytes = 1024
+	zm.compBytes = 256
 
 	time.Sleep(10 * time.Millisecond)
 	zm.Done()
@@ -67,6 +73,28 @@ func Test_zipMetric_Done_Extract_Success(t *testing.T) {
 	require.Greater(t, fsys.Metrics.TotalExtractTime.Load(), initialExtractTime)
 	require.Equal(t, initialExtractCount+1, fsys.Metrics.TotalExtractCount.Load())
 	require.Equal(t, initialExtractBytes+1024, fsys.Metrics.TotalExtractBytes.Load())
+	require.Equal(t, int64(256), fsys.Metrics.TotalCompressedBytesRead.Load())
+}
+
+// Expectation: compressedBytes should estimate in proportion of the compression.
+func Test_compressedBytes_Success(t *testing.T) {
+	t.Parallel()
+
+	stored := &zip.File{FileHeader: zip.FileHeader{
+		Method: zip.Store, CompressedSize64: 100, UncompressedSize64: 100,
+	}}
+	require.Equal(t, int64(50), compressedBytes(stored, 50))
+
+	deflated := &zip.File{FileHeader: zip.FileHeader{
+		Method: zip.Deflate, CompressedSize64: 25, UncompressedSize64: 100,
+	}}
+	require.Equal(t, int64(25), compressedBytes(deflated, 100))
+	require.Equal(t, int64(10), compressedBytes(deflated, 40))
+
+	empty := &zip.File{FileHeader: zip.FileHeader{
+		Method: zip.Deflate, CompressedSize64: 2, UncompressedSize64: 0,
+	}}
+	require.Equal(t, int64(0), compressedBytes(empty, 0))
 }
 
 // Expectation: zipMetric.Done should update metadata metrics correctly.
@@ -87,6 +115,41 @@ func Test_zipMetric_Done_Metadata_Success(t *testing.T) {
 	require.Equal(t, initialMetadataCount+1, fsys.Metrics.TotalMetadataReadCount.Load())
 }
 
+// Expectation: zipMetric.Trace should emit a trace line on Done() for sampled measurements.
+func Test_zipMetric_Trace_Sampled_Success(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	_, fsys := testFS(t, &buf)
+
+	fsys.Options.TraceSample = 1
+
+	zm := newZipMetric(fsys, true)
+	zm.Trace("read", "test.zip", "file.txt")
+	zm.Cached(cacheHit)
+	zm.readBytes = 512
+	zm.Done()
+
+	require.Contains(t, buf.String(), `Trace: op=read archive="test.zip" path="file.txt"`)
+	require.Contains(t, buf.String(), "cache=hit bytes=512")
+}
+
+// Expectation: zipMetric.Trace should not emit any trace lines when tracing is disabled.
+func Test_zipMetric_Trace_Disabled_Success(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	_, fsys := testFS(t, &buf)
+
+	zm := newZipMetric(fsys, false)
+	zm.Trace("lookup", "test.zip", "file.txt")
+	zm.Cached(cacheMiss)
+	zm.Done()
+
+	require.Nil(t, zm.trace)
+	require.NotContains(t, buf.String(), "Trace:")
+}
+
 // Expectation: zipMetric.Done should not update extract metrics when isExtract is false.
 func Test_zipMetric_Done_Metadata_NoExtractUpdate_Success(t *testing.T) {
 	t.Parallel()
@@ -211,6 +274,71 @@ func Test_toFuseErr_WrappedNotExist_Success(t *testing.T) {
 	require.ErrorIs(t, err, fuse.ToErrno(syscall.ENOENT))
 }
 
+// Expectation: toFuseErr should map the sentinel errors, retaining them in the chain.
+func Test_toFuseErr_Sentinels_Success(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		err   error
+		errno syscall.Errno
+	}{
+		{"entry not found", ErrEntryNotFound, syscall.ENOENT},
+		{"archive unreadable", ErrArchiveUnreadable, syscall.EINVAL},
+		{"corrupt entry", ErrCorruptEntry, syscall.EIO},
+		{"archive unreadable over errno", fmt.Errorf("%w: %w", ErrArchiveUnreadable, os.ErrNotExist), syscall.EINVAL},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := toFuseErr(fmt.Errorf("wrapped: %w", tt.err))
+			require.ErrorIs(t, err, fuse.ToErrno(tt.errno))
+			require.ErrorIs(t, err, tt.err)
+			require.Equal(t, fuse.ToErrno(tt.errno), fuse.ToErrno(err))
+		})
+	}
+}
+
+// Expectation: wrapFuseErr should use the given errno, retaining the error in the chain.
+func Test_wrapFuseErr_Success(t *testing.T) {
+	t.Parallel()
+
+	err := wrapFuseErr(syscall.EINVAL, fmt.Errorf("wrapped: %w", ErrEntryNotFound))
+	require.ErrorIs(t, err, fuse.ToErrno(syscall.EINVAL))
+	require.ErrorIs(t, err, ErrEntryNotFound)
+	require.Equal(t, fuse.ToErrno(syscall.EINVAL), fuse.ToErrno(err))
+}
+
+// Expectation: toEntryErr should wrap integrity and decompression errors only.
+func Test_toEntryErr_Success(t *testing.T) {
+	t.Parallel()
+
+	require.ErrorIs(t, toEntryErr(zip.ErrChecksum), ErrCorruptEntry)
+	require.ErrorIs(t, toEntryErr(zip.ErrFormat), ErrCorruptEntry)
+	require.ErrorIs(t, toEntryErr(flate.CorruptInputError(10)), ErrCorruptEntry)
+
+	require.NoError(t, toEntryErr(nil))
+	require.Equal(t, io.EOF, toEntryErr(io.EOF)) //nolint:testifylint
+	require.NotErrorIs(t, toEntryErr(os.ErrPermission), ErrCorruptEntry)
+}
+
+// Expectation: hasExtension should match extensions case-insensitively, with or without dot.
+func Test_hasExtension_Success(t *testing.T) {
+	t.Parallel()
+
+	exts := []string{"jpg", ".PNG"}
+
+	require.True(t, hasExtension("image.jpg", exts))
+	require.True(t, hasExtension("dir/image.JPG", exts))
+	require.True(t, hasExtension("image.png", exts))
+	require.False(t, hasExtension("image.gif", exts))
+	require.False(t, hasExtension("jpg", exts))
+	require.False(t, hasExtension("image.jpg/", exts))
+	require.False(t, hasExtension("image.jpg", nil))
+}
+
 // Expectation: The function should behave according to the table expectations.
 func Test_isDir_Success(t *testing.T) {
 	t.Parallel()
@@ -236,7 +364,7 @@ func Test_isDir_Success(t *testing.T) {
 				f.SetMode(0o755 | os.ModeDir)
 			}
 
-			got := isDir(f, zipEntryNormalize(0, f, true))
+			got := isDir(f, zipEntryNormalize(0, f, true, UnicodeNormalizeNone))
 			require.Equal(t, tt.want, got)
 		})
 	}
@@ -261,7 +389,7 @@ func Test_zipEntryNormalize_ValidUTF8_Success(t *testing.T) {
 		t.Run(tt.in, func(t *testing.T) {
 			t.Parallel()
 			f := createTestZipFilePtr(t, tt.in)
-			got := zipEntryNormalize(0, f, true)
+			got := zipEntryNormalize(0, f, true, UnicodeNormalizeNone)
 			require.Equal(t, tt.want, got)
 		})
 	}
@@ -283,13 +411,19 @@ func Test_zipEntryNormalize_Separators_Success(t *testing.T) {
 		{"leading slash", "/dir/file.txt", "dir/file.txt"},
 		{"multiple leading slashes", "///dir/file.txt", "dir/file.txt"},
 		{"non-unicode", "valid//dir///" + string(corruptBytes) + ".txt", "valid/dir/noutf8_file(0).txt"},
+		{"backslashes", "dir\\sub\\file.txt", "dir/sub/file.txt"},
+		{"windows absolute", "C:\\a\\b.txt", "a/b.txt"},
+		{"windows absolute slashes", "c:/a/b.txt", "a/b.txt"},
+		{"windows unc", "\\\\server\\share\\file.txt", "server/share/file.txt"},
+		{"backslash within name", "dir/file\\name.txt", "dir/file\\name.txt"},
+		{"colon within name", "dir/C:/file.txt", "dir/C:/file.txt"},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			t.Parallel()
 			f := createTestZipFilePtr(t, tt.in)
-			got := zipEntryNormalize(0, f, true)
+			got := zipEntryNormalize(0, f, true, UnicodeNormalizeNone)
 			require.Equal(t, tt.want, got)
 		})
 	}
@@ -326,7 +460,7 @@ func Test_zipEntryNormalize_UnicodeExtraField_Success(t *testing.T) {
 		},
 	}
 
-	got := zipEntryNormalize(0, f, true)
+	got := zipEntryNormalize(0, f, true, UnicodeNormalizeNone)
 	require.Equal(t, unicodePath, got)
 }
 
@@ -337,7 +471,7 @@ func Test_zipEntryNormalize_Fallback_Success(t *testing.T) {
 	invalidUTF8 := []byte{0xFF, 0xFE, 0xFD}
 	f := createTestZipFilePtr(t, "dir/"+string(invalidUTF8)+".txt")
 
-	got := zipEntryNormalize(42, f, true)
+	got := zipEntryNormalize(42, f, true, UnicodeNormalizeNone)
 	require.Equal(t, "dir/noutf8_file(42).txt", got)
 }
 
@@ -348,10 +482,25 @@ func Test_zipEntryNormalize_NoFallback_Success(t *testing.T) {
 	invalidUTF8 := []byte{0xFF, 0xFE, 0xFD}
 	f := createTestZipFilePtr(t, "dir/"+string(invalidUTF8)+".txt")
 
-	got := zipEntryNormalize(42, f, false)
+	got := zipEntryNormalize(42, f, false, UnicodeNormalizeNone)
 	require.Equal(t, "dir/\xff\xfe\xfd.txt", got)
 }
 
+// Expectation: zipEntryNormalize should canonicalize the path into the Unicode normalization form.
+func Test_zipEntryNormalize_UnicodeNormalize_Success(t *testing.T) {
+	t.Parallel()
+
+	const nfc, nfd = "dir/caf\u00e9.txt", "dir/cafe\u0301.txt"
+
+	for _, name := range []string{nfc, nfd} {
+		f := createTestZipFilePtr(t, name)
+
+		require.Equal(t, name, zipEntryNormalize(0, f, true, UnicodeNormalizeNone))
+		require.Equal(t, nfc, zipEntryNormalize(0, f, true, UnicodeNormalizeNFC))
+		require.Equal(t, nfd, zipEntryNormalize(0, f, true, UnicodeNormalizeNFD))
+	}
+}
+
 // Expectation: zipEntryUnicodeFromExtra should extract Unicode path from Extra Field.
 func Test_zipEntryUnicodeFromExtra_Success(t *testing.T) {
 	t.Parallel()
@@ -407,6 +556,173 @@ func Test_zipEntryUnicodeFromExtra_Malformed_Error(t *testing.T) {
 	require.False(t, ok)
 }
 
+// Expectation: zipEntryModified should interpret MS-DOS times (without timezone)
+// as UTC by default, or in the local timezone, but never the extended timestamps.
+func Test_zipEntryModified_Success(t *testing.T) {
+	t.Parallel()
+
+	dosTime := time.Date(2021, 6, 1, 10, 30, 16, 0, time.UTC)
+	extTime := time.Date(2022, 7, 2, 11, 45, 30, 0, time.UTC)
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+
+	// Only MS-DOS date and time (as written by many legacy tools):
+	fh := &zip.FileHeader{Name: "dos.txt"}
+	fh.ModifiedDate = uint16((dosTime.Year()-1980)<<9 | int(dosTime.Month())<<5 | dosTime.Day()) //nolint:staticcheck
+	fh.ModifiedTime = uint16(dosTime.Hour()<<11 | dosTime.Minute()<<5 | dosTime.Second()/2)      //nolint:staticcheck
+	_, err := zw.CreateHeader(fh)
+	require.NoError(t, err)
+
+	// Also an extended timestamp (being absolute):
+	_, err = zw.CreateHeader(&zip.FileHeader{Name: "ext.txt", Modified: extTime})
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.NoError(t, err)
+	require.Len(t, zr.File, 2)
+
+	utc := zipEntryModified(zr.File[0], TimestampUTC)
+	require.Equal(t, time.UTC, utc.Location())
+	require.True(t, dosTime.Equal(utc))
+
+	local := zipEntryModified(zr.File[0], TimestampLocal)
+	require.Equal(t, time.Local, local.Location())
+	require.True(t, time.Date(2021, 6, 1, 10, 30, 16, 0, time.Local).Equal(local))
+
+	require.True(t, extTime.Equal(zipEntryModified(zr.File[1], TimestampUTC)))
+	require.True(t, extTime.Equal(zipEntryModified(zr.File[1], TimestampLocal)))
+}
+
+// Expectation: zipEntryUnixFromExtra should extract the extended timestamps.
+func Test_zipEntryUnixFromExtra_Timestamps_Success(t *testing.T) {
+	t.Parallel()
+
+	mtime := time.Date(2021, 6, 1, 10, 30, 15, 0, time.UTC)
+	atime := time.Date(2022, 7, 2, 11, 45, 30, 0, time.UTC)
+
+	extra := make([]byte, 0)
+	extra = append(extra, 0x55, 0x54)                  // Header ID
+	extra = binary.LittleEndian.AppendUint16(extra, 9) // Data size
+	extra = append(extra, 0x03)                        // Flags (mtime, atime)
+	extra = binary.LittleEndian.AppendUint32(extra, uint32(mtime.Unix()))
+	extra = binary.LittleEndian.AppendUint32(extra, uint32(atime.Unix()))
+
+	f := &zip.File{
+		FileHeader: zip.FileHeader{
+			Extra: extra,
+		},
+	}
+
+	ux := zipEntryUnixFromExtra(f)
+	require.True(t, mtime.Equal(ux.mtime))
+	require.True(t, atime.Equal(ux.atime))
+	require.False(t, ux.hasOwner)
+}
+
+// Expectation: zipEntryUnixFromExtra should handle flags without the data (central directory).
+func Test_zipEntryUnixFromExtra_TimestampsFlagsOnly_Success(t *testing.T) {
+	t.Parallel()
+
+	mtime := time.Date(2021, 6, 1, 10, 30, 15, 0, time.UTC)
+
+	extra := make([]byte, 0)
+	extra = append(extra, 0x55, 0x54)                  // Header ID
+	extra = binary.LittleEndian.AppendUint16(extra, 5) // Data size
+	extra = append(extra, 0x07)                        // Flags (mtime, atime, ctime)
+	extra = binary.LittleEndian.AppendUint32(extra, uint32(mtime.Unix()))
+
+	f := &zip.File{
+		FileHeader: zip.FileHeader{
+			Extra: extra,
+		},
+	}
+
+	ux := zipEntryUnixFromExtra(f)
+	require.True(t, mtime.Equal(ux.mtime))
+	require.True(t, ux.atime.IsZero())
+}
+
+// Expectation: zipEntryUnixFromExtra should extract the Info-ZIP Unix UID/GID.
+func Test_zipEntryUnixFromExtra_Owner_Success(t *testing.T) {
+	t.Parallel()
+
+	extra := make([]byte, 0)
+	extra = append(extra, 0x75, 0x78)                   // Header ID
+	extra = binary.LittleEndian.AppendUint16(extra, 11) // Data size
+	extra = append(extra, 0x01)                         // Version
+	extra = append(extra, 0x04)                         // UID size
+	extra = binary.LittleEndian.AppendUint32(extra, 1000)
+	extra = append(extra, 0x04) // GID size
+	extra = binary.LittleEndian.AppendUint32(extra, 100)
+
+	f := &zip.File{
+		FileHeader: zip.FileHeader{
+			Extra: extra,
+		},
+	}
+
+	ux := zipEntryUnixFromExtra(f)
+	require.True(t, ux.hasOwner)
+	require.Equal(t, uint32(1000), ux.uid)
+	require.Equal(t, uint32(100), ux.gid)
+	require.True(t, ux.mtime.IsZero())
+}
+
+// Expectation: zipEntryUnixFromExtra should handle malformed Extra Fields.
+func Test_zipEntryUnixFromExtra_Malformed_Error(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		extra []byte
+	}{
+		{"Empty", []byte{}},
+		{"SizeBeyondData", []byte{0x55, 0x54, 0xFF, 0xFF}},
+		{"TimestampNoFlags", []byte{0x55, 0x54, 0x00, 0x00}},
+		{"TimestampTruncated", []byte{0x55, 0x54, 0x03, 0x00, 0x01, 0x00, 0x00}},
+		{"OwnerWrongVersion", []byte{0x75, 0x78, 0x05, 0x00, 0x02, 0x01, 0x01, 0x01, 0x01}},
+		{"OwnerTruncated", []byte{0x75, 0x78, 0x03, 0x00, 0x01, 0x04, 0x01}},
+		{"OwnerNoGID", []byte{0x75, 0x78, 0x03, 0x00, 0x01, 0x01, 0x01}},
+		{"OwnerOversized", []byte{0x75, 0x78, 0x0B, 0x00, 0x01, 0x09, 0, 0, 0, 0, 0, 0, 0, 0, 0}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			f := &zip.File{
+				FileHeader: zip.FileHeader{
+					Extra: tt.extra,
+				},
+			}
+
+			ux := zipEntryUnixFromExtra(f)
+			require.True(t, ux.mtime.IsZero())
+			require.True(t, ux.atime.IsZero())
+			require.False(t, ux.hasOwner)
+		})
+	}
+}
+
+// Expectation: zipEntryUnixID should parse IDs of variable sizes.
+func Test_zipEntryUnixID_Success(t *testing.T) {
+	t.Parallel()
+
+	id, rest, ok := zipEntryUnixID([]byte{0x02, 0xE8, 0x03, 0xAA})
+	require.True(t, ok)
+	require.Equal(t, uint32(1000), id)
+	require.Equal(t, []byte{0xAA}, rest)
+
+	id, _, ok = zipEntryUnixID([]byte{0x08, 0x01, 0, 0, 0, 0, 0, 0, 0})
+	require.True(t, ok)
+	require.Equal(t, uint32(1), id)
+
+	_, _, ok = zipEntryUnixID([]byte{0x08, 0, 0, 0, 0, 0x01, 0, 0, 0})
+	require.False(t, ok)
+}
+
 // Expectation: zipEntryUnicodeFallback should preserve valid UTF-8 components.
 func Test_zipEntryUnicodeFallback_ValidUTF8Components_Success(t *testing.T) {
 	t.Parallel()
@@ -507,7 +823,7 @@ func Test_flatEntryName_Success(t *testing.T) {
 	}
 
 	for _, tc := range testCases {
-		result, valid := flatEntryName(tc.index, tc.input)
+		result, valid := flatEntryName(tc.index, tc.input, DotfileFlatPrefix)
 		require.Equal(t, tc.valid, valid)
 		if valid {
 			require.Equal(t, tc.expected, result)
@@ -515,6 +831,43 @@ func Test_flatEntryName_Success(t *testing.T) {
 	}
 }
 
+// Expectation: truncateName should truncate names exceeding nameMax to unique
+// names within it, preserving their extension and never splitting a rune.
+func Test_truncateName_Success(t *testing.T) {
+	t.Parallel()
+
+	require.Equal(t, "file.txt", truncateName("file.txt"))
+	require.Equal(t, strings.Repeat("a", nameMax), truncateName(strings.Repeat("a", nameMax)))
+
+	long := strings.Repeat("a", 296) + ".txt"
+	name := truncateName(long)
+	require.Len(t, name, nameMax)
+	require.True(t, strings.HasSuffix(name, ".txt"))
+	require.Equal(t, name, truncateName(long))
+	require.NotEqual(t, name, truncateName(strings.Repeat("a", 297)+".txt"))
+
+	name = truncateName(strings.Repeat("ä", 150))
+	require.LessOrEqual(t, len(name), nameMax)
+	require.True(t, utf8.ValidString(name))
+
+	name = truncateName("file." + strings.Repeat("x", 295))
+	require.Len(t, name, nameMax)
+	require.False(t, strings.HasSuffix(name, strings.Repeat("x", nameMaxExt+1)))
+}
+
+// Expectation: zipEntryTruncate should truncate only the names exceeding nameMax.
+func Test_zipEntryTruncate_Success(t *testing.T) {
+	t.Parallel()
+
+	require.Equal(t, "dir/file.txt", zipEntryTruncate("dir/file.txt"))
+
+	long := strings.Repeat("d", 300)
+	parts := strings.Split(zipEntryTruncate(long+"/file.txt"), "/")
+	require.Len(t, parts, 2)
+	require.Equal(t, truncateName(long), parts[0])
+	require.Equal(t, "file.txt", parts[1])
+}
+
 // Expectation: flatEntryName should preserve file extensions.
 func Test_flatEntryName_PreserveExtension_Success(t *testing.T) {
 	t.Parallel()
@@ -531,7 +884,7 @@ func Test_flatEntryName_PreserveExtension_Success(t *testing.T) {
 	}
 
 	for _, tc := range testCases {
-		result, valid := flatEntryName(tc.index, tc.input)
+		result, valid := flatEntryName(tc.index, tc.input, DotfileFlatPrefix)
 		require.True(t, valid)
 		require.Equal(t, filepath.Ext(result), tc.ext)
 	}
@@ -542,10 +895,10 @@ func Test_flatEntryName_UniqueIndices_Success(t *testing.T) {
 	t.Parallel()
 
 	path := "dir/file.txt"
-	name1, valid1 := flatEntryName(1, path)
+	name1, valid1 := flatEntryName(1, path, DotfileFlatPrefix)
 	require.True(t, valid1)
 
-	name2, valid2 := flatEntryName(2, path)
+	name2, valid2 := flatEntryName(2, path, DotfileFlatPrefix)
 	require.True(t, valid2)
 
 	require.NotEqual(t, name1, name2)
@@ -560,10 +913,10 @@ func Test_flatEntryName_Deterministic_Success(t *testing.T) {
 	path := "some/deep/path/file.txt"
 	index := 42
 
-	name1, valid1 := flatEntryName(index, path)
+	name1, valid1 := flatEntryName(index, path, DotfileFlatPrefix)
 	require.True(t, valid1)
 
-	name2, valid2 := flatEntryName(index, path)
+	name2, valid2 := flatEntryName(index, path, DotfileFlatPrefix)
 	require.True(t, valid2)
 
 	require.Equal(t, name1, name2)
@@ -573,7 +926,7 @@ func Test_flatEntryName_Deterministic_Success(t *testing.T) {
 func Test_flatEntryName_NoExtension_Success(t *testing.T) {
 	t.Parallel()
 
-	name, valid := flatEntryName(5, "dir/README")
+	name, valid := flatEntryName(5, "dir/README", DotfileFlatPrefix)
 	require.True(t, valid)
 	require.Equal(t, "README(5)", name)
 	require.Empty(t, filepath.Ext(name))
@@ -583,16 +936,34 @@ func Test_flatEntryName_NoExtension_Success(t *testing.T) {
 func Test_flatEntryName_Dotfile_Success(t *testing.T) {
 	t.Parallel()
 
-	name, valid := flatEntryName(3, "dir/.gitignore")
+	name, valid := flatEntryName(3, "dir/.gitignore", DotfileFlatPrefix)
 	require.True(t, valid)
 	require.Equal(t, "(3).gitignore", name)
 }
 
+// Expectation: flatEntryName should append the index to dotfiles (suffix style),
+// while still indexing any other files (also those starting with a dot) as usual.
+func Test_flatEntryName_DotfileSuffix_Success(t *testing.T) {
+	t.Parallel()
+
+	name, valid := flatEntryName(3, "dir/.gitignore", DotfileFlatSuffix)
+	require.True(t, valid)
+	require.Equal(t, ".gitignore(3)", name)
+
+	name, valid = flatEntryName(4, "dir/.config.json", DotfileFlatSuffix)
+	require.True(t, valid)
+	require.Equal(t, ".config(4).json", name)
+
+	name, valid = flatEntryName(5, "dir/file.txt", DotfileFlatSuffix)
+	require.True(t, valid)
+	require.Equal(t, "file(5).txt", name)
+}
+
 // Expectation: flatEntryName should handle paths with multiple dots.
 func Test_flatEntryName_MultipleDots_Success(t *testing.T) {
 	t.Parallel()
 
-	name, valid := flatEntryName(7, "dir/archive.tar.gz")
+	name, valid := flatEntryName(7, "dir/archive.tar.gz", DotfileFlatPrefix)
 	require.True(t, valid)
 	require.Equal(t, "archive.tar(7).gz", name)
 }
@@ -609,7 +980,77 @@ func Test_flatEntryName_InvalidPaths_Error(t *testing.T) {
 	}
 
 	for _, tc := range testCases {
-		_, valid := flatEntryName(0, tc)
+		_, valid := flatEntryName(0, tc, DotfileFlatPrefix)
 		require.False(t, valid)
 	}
 }
+
+// Expectation: flatEntryNames should suffix all entries with their index.
+func Test_flatEntryNames_Index_Success(t *testing.T) {
+	t.Parallel()
+
+	names := flatEntryNames([]string{"dir/", "", "dir/a.txt", "other/a.txt", "../evil.txt"}, FlatCollisionIndex, DotfileFlatPrefix)
+	require.Equal(t, []string{"dir(0)", "", "a(2).txt", "a(3).txt", ""}, names)
+}
+
+// Expectation: flatEntryNames should index dotfiles by the style (suffix style),
+// also when falling back to indices on collisions with the directory strategy.
+func Test_flatEntryNames_DotfileSuffix_Success(t *testing.T) {
+	t.Parallel()
+
+	paths := []string{"a/.gitignore", "b/.gitignore", "c/.env", "a/.gitignore"}
+
+	names := flatEntryNames(paths, FlatCollisionIndex, DotfileFlatSuffix)
+	require.Equal(t, []string{".gitignore(0)", ".gitignore(1)", ".env(2)", ".gitignore(3)"}, names)
+
+	names = flatEntryNames(paths, FlatCollisionDirectory, DotfileFlatSuffix)
+	require.Equal(t, []string{".gitignore(0)", "b_.gitignore", ".env", ".gitignore(3)"}, names)
+}
+
+// Expectation: flatEntryNames should prepend the parent directory on collisions,
+// keeping non-colliding names plain and falling back to indices if still colliding.
+func Test_flatEntryNames_Directory_Success(t *testing.T) {
+	t.Parallel()
+
+	names := flatEntryNames([]string{
+		"",
+		"unique.txt",
+		"a/b/dirA/file.txt",
+		"c/d/e/dirB/file.txt",
+		"x/same/doc.pdf",
+		"y/same/doc.pdf",
+		"doc.pdf",
+		"p/dirC/note",
+		"q/note",
+		"dirC_note",
+		"../evil.txt",
+	}, FlatCollisionDirectory, DotfileFlatPrefix)
+
+	require.Equal(t, []string{
+		"",
+		"unique.txt",
+		"dirA_file.txt",
+		"dirB_file.txt",
+		"doc(4).pdf",
+		"doc(5).pdf",
+		"doc.pdf",
+		"note(7)",
+		"q_note",
+		"dirC_note(9)",
+		"",
+	}, names)
+}
+
+// Expectation: Targets of symlinks should be resolved relative to the symlink,
+// with absolute targets or those pointing outside of the archive unresolved.
+func Test_resolveSymlink_Success(t *testing.T) {
+	t.Parallel()
+
+	require.Equal(t, "dir/file.txt", resolveSymlink("dir/link", "file.txt"))
+	require.Equal(t, "other/file.txt", resolveSymlink("dir/link", "../other/./file.txt"))
+	require.Equal(t, "file.txt", resolveSymlink("link", "file.txt"))
+	require.Empty(t, resolveSymlink("dir/link", "/etc/passwd"))
+	require.Empty(t, resolveSymlink("dir/link", "../../outside"))
+	require.Empty(t, resolveSymlink("dir/link", ".."))
+	require.Empty(t, resolveSymlink("link", ""))
+}
@@ -0,0 +1,262 @@
+package filesystem
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"bazil.org/fuse"
+	"bazil.org/fuse/fs"
+)
+
+// walkVisit is a visited [fs.Node] as collected for [FS.WalkConcurrent], or
+// an error of the walk at its path (ending the walk, unless being skipped).
+type walkVisit struct {
+	path   string
+	dirent *fuse.Dirent
+	node   fs.Node
+	attr   fuse.Attr
+	err    error
+}
+
+// walkJob is a part of a [FS.WalkConcurrent] in the order of the walk, being
+// either visits of the source directories (as collected upfront) or the whole
+// subtree of any other node within them (e.g. an archive) to be collected
+// by a worker, with done being closed once the visits were all collected.
+type walkJob struct {
+	visits  []walkVisit
+	subtree *walkVisit // Root of the subtree to collect (nil if collected).
+	done    chan struct{}
+}
+
+// WalkConcurrent is [FS.Walk], but with the subtrees of the source directories
+// (e.g. archives) being walked by up to the given amount of concurrent workers
+// (bounded by [Options.FDLimit]), while walkFn is still called sequentially
+// and in the very same order as by [FS.Walk] (so the results are deterministic).
+// As the visits of a subtree are collected before walkFn is called on them,
+// [ErrSkipDir] does not save on walking a subtree, but still skips its visits.
+// With less than two workers (or a single archive as source), it is [FS.Walk].
+// Failed nodes are continued past as by [FS.Walk] (see [Options.WalkContinueOnError]).
+func (fsys *FS) WalkConcurrent(ctx context.Context, workers int, walkFn WalkFunc) error {
+	workers = min(workers, cap(fsys.fdlimit))
+
+	root, err := fsys.Root()
+	if err != nil {
+		return fmt.Errorf("failed to get fs root: %w", err)
+	}
+
+	failures := fsys.newWalkFailures()
+
+	if _, ok := root.(*realDirNode); !ok || workers < 2 { //nolint:mnd
+		if err := fsys.walkNode(ctx, failures, "/", nil, root, walkFn); err != nil {
+			return err
+		}
+
+		return failures.Err()
+	}
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	jobs := fsys.collectSource(ctx, "/", nil, root, nil)
+	sem := make(chan struct{}, workers)
+
+	wg.Go(func() {
+		for _, job := range jobs {
+			if job.subtree == nil {
+				continue
+			}
+
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+
+			wg.Go(func() {
+				job.visits = fsys.collectNode(ctx, job.subtree.path, job.subtree.dirent, job.subtree.node, nil)
+				close(job.done)
+			})
+		}
+	})
+
+	var skip string
+
+	for _, job := range jobs {
+		select {
+		case <-job.done:
+		case <-ctx.Done():
+			return fmt.Errorf("context error: %w", ctx.Err())
+		}
+
+		for _, v := range job.visits {
+			if skip != "" && (v.path == skip || walkWithin(v.path, skip)) {
+				continue
+			}
+			if v.err != nil {
+				if err := fsys.tolerateWalk(ctx, failures, v.err); err != nil {
+					return err
+				}
+
+				continue
+			}
+
+			if err := walkFn(v.path, v.dirent, v.node, v.attr); err != nil {
+				if errors.Is(err, ErrSkipDir) {
+					skip = v.path
+
+					continue
+				}
+
+				return fmt.Errorf("walkfn error at %q: %w", v.path, err)
+			}
+		}
+
+		if job.subtree != nil {
+			job.visits = nil
+			<-sem
+		}
+	}
+
+	return failures.Err()
+}
+
+// walkWithin returns if a path is below a directory (by their walk paths).
+func walkWithin(path string, dir string) bool {
+	if dir == "/" {
+		return true
+	}
+
+	return strings.HasPrefix(path, dir+"/")
+}
+
+// collectSource collects the visits of the source directories (realDirNode)
+// for [FS.WalkConcurrent], appending them to the jobs in order of the walk,
+// with any other nodes being appended as subtrees to be collected by workers.
+// An error is collected in place of the directory's contents, as the walk only
+// ends on it once it is reached (and not skipped over by an [ErrSkipDir]).
+func (fsys *FS) collectSource(ctx context.Context, path string, dirent *fuse.Dirent, node fs.Node, jobs []*walkJob) []*walkJob {
+	dir, ok := node.(*realDirNode)
+	if !ok {
+		return append(jobs, &walkJob{
+			subtree: &walkVisit{path: path, dirent: dirent, node: node},
+			done:    make(chan struct{}),
+		})
+	}
+
+	done := make(chan struct{})
+	close(done)
+
+	job := &walkJob{done: done}
+	jobs = append(jobs, job)
+
+	v := walkVisit{path: path, dirent: dirent, node: node}
+
+	if err := ctx.Err(); err != nil {
+		v.err = fmt.Errorf("context error: %w", err)
+		job.visits = append(job.visits, v)
+
+		return jobs
+	}
+
+	attr, err := fsys.walkAttr(ctx, node)
+	if err != nil {
+		v.err = fmt.Errorf("attr error at %q: %w", path, err)
+		job.visits = append(job.visits, v)
+
+		return jobs
+	}
+	v.attr = attr
+	job.visits = append(job.visits, v)
+
+	dirents, err := fsys.walkReadDirAll(ctx, dir)
+	if err != nil {
+		job.visits = append(job.visits, walkVisit{path: path, err: fmt.Errorf("readdirall error at %q: %w", path, err)})
+
+		return jobs
+	}
+
+	for _, de := range dirents {
+		childPath := walkChildPath(path, de.Name)
+
+		childNode, err := fsys.walkLookup(ctx, dir, de.Name)
+		if err != nil {
+			job.visits = append(job.visits, walkVisit{path: childPath, err: fmt.Errorf("lookup error for %q at %q: %w", de.Name, path, err)})
+
+			continue
+		}
+
+		jobs = fsys.collectSource(ctx, childPath, &de, childNode, jobs)
+
+		// Any visits of the source directory after the child go into a new job.
+		job = &walkJob{done: done}
+		jobs = append(jobs, job)
+	}
+
+	return jobs
+}
+
+// collectNode collects the visits of a node and its subtree for [FS.WalkConcurrent],
+// as [FS.walkNode] would visit them. Errors are collected as by [FS.collectSource].
+func (fsys *FS) collectNode(ctx context.Context, path string, dirent *fuse.Dirent, node fs.Node, visits []walkVisit) []walkVisit {
+	v := walkVisit{path: path, dirent: dirent, node: node}
+
+	if err := ctx.Err(); err != nil {
+		v.err = fmt.Errorf("context error: %w", err)
+
+		return append(visits, v)
+	}
+
+	attr, err := fsys.walkAttr(ctx, node)
+	if err != nil {
+		v.err = fmt.Errorf("attr error at %q: %w", path, err)
+
+		return append(visits, v)
+	}
+	v.attr = attr
+	visits = append(visits, v)
+
+	readDirNode, ok := node.(fs.HandleReadDirAller)
+	if !ok {
+		return visits
+	}
+
+	dirents, err := fsys.walkReadDirAll(ctx, readDirNode)
+	if err != nil {
+		return append(visits, walkVisit{path: path, err: fmt.Errorf("readdirall error at %q: %w", path, err)})
+	}
+
+	lookupNode, ok := node.(fs.NodeStringLookuper)
+	if !ok {
+		return visits
+	}
+
+	for _, de := range dirents {
+		childPath := walkChildPath(path, de.Name)
+
+		childNode, err := fsys.walkLookup(ctx, lookupNode, de.Name)
+		if err != nil {
+			visits = append(visits, walkVisit{path: childPath, err: fmt.Errorf("lookup error for %q at %q: %w", de.Name, path, err)})
+
+			continue
+		}
+
+		visits = fsys.collectNode(ctx, childPath, &de, childNode, visits)
+	}
+
+	return visits
+}
+
+// walkChildPath returns the walk path of a child within a directory.
+func walkChildPath(path string, name string) string {
+	if path != "/" {
+		return path + "/" + name
+	}
+
+	return path + name
+}
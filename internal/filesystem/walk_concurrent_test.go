@@ -0,0 +1,113 @@
+package filesystem
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"bazil.org/fuse"
+	"bazil.org/fuse/fs"
+	"github.com/stretchr/testify/require"
+)
+
+// createTestWalkTree creates a tree of directories and archives for comparing
+// [FS.WalkConcurrent] with [FS.Walk], also with an unreadable archive.
+func createTestWalkTree(t *testing.T, tmpDir string) {
+	t.Helper()
+
+	tnow := time.Now()
+	entries := []struct {
+		Path    string
+		ModTime time.Time
+		Content []byte
+	}{
+		{Path: "file.txt", ModTime: tnow, Content: []byte("test content")},
+		{Path: "docs/", ModTime: tnow, Content: nil},
+		{Path: "docs/a.txt", ModTime: tnow, Content: []byte("test content")},
+		{Path: "docs/images/logo.png", ModTime: tnow, Content: []byte("image")},
+	}
+
+	for i := range 3 {
+		dir := filepath.Join(tmpDir, fmt.Sprintf("dir%d", i), "sub")
+		require.NoError(t, os.MkdirAll(dir, 0o777))
+
+		createTestZip(t, dir, "a.zip", entries)
+		createTestZip(t, dir, "b.zip", entries)
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "file.txt"), []byte("plain"), 0o644))
+	}
+	createTestZip(t, tmpDir, "top.zip", entries)
+	require.NoError(t, os.WriteFile(filepath.Join(tmpDir, "dir1", "bad.zip"), []byte("not a zip"), 0o644))
+}
+
+// walkTestPaths walks the [FS] (concurrently with workers > 0), returning the
+// visited paths (and their inodes), skipping any paths ending in skipSuffix.
+func walkTestPaths(t *testing.T, fsys *FS, workers int, skipSuffix string) ([]string, error) {
+	t.Helper()
+
+	var paths []string
+
+	walkFn := func(path string, _ *fuse.Dirent, _ fs.Node, attr fuse.Attr) error {
+		paths = append(paths, fmt.Sprintf("%d %s", attr.Inode, path))
+		if skipSuffix != "" && strings.HasSuffix(path, skipSuffix) {
+			return ErrSkipDir
+		}
+
+		return nil
+	}
+
+	if workers > 0 {
+		return paths, fsys.WalkConcurrent(t.Context(), workers, walkFn)
+	}
+
+	return paths, fsys.Walk(t.Context(), walkFn)
+}
+
+// Expectation: WalkConcurrent should visit the very same nodes in the very
+// same order as Walk, also when skipping directories, and fail on the same error.
+func Test_FS_WalkConcurrent_Success(t *testing.T) {
+	t.Parallel()
+
+	tmpDir, fsys := testFS(t, io.Discard)
+	createTestWalkTree(t, tmpDir)
+
+	for _, skip := range []string{"", "/dir0", "/dir2/sub/a", "/docs"} {
+		expected, err := walkTestPaths(t, fsys, 0, skip)
+		require.ErrorIs(t, err, ErrArchiveUnreadable, skip)
+
+		for _, workers := range []int{1, 2, 8} {
+			paths, err := walkTestPaths(t, fsys, workers, skip)
+			require.ErrorIs(t, err, ErrArchiveUnreadable, skip)
+			require.Equal(t, expected, paths, "skip %q with %d workers", skip, workers)
+		}
+	}
+
+	require.NoError(t, os.Remove(filepath.Join(tmpDir, "dir1", "bad.zip")))
+
+	expected, err := walkTestPaths(t, fsys, 0, "")
+	require.NoError(t, err)
+
+	paths, err := walkTestPaths(t, fsys, 4, "")
+	require.NoError(t, err)
+	require.Equal(t, expected, paths)
+}
+
+// Expectation: WalkConcurrent should return an error once cancelled.
+func Test_FS_WalkConcurrent_Cancelled_Error(t *testing.T) {
+	t.Parallel()
+
+	tmpDir, fsys := testFS(t, io.Discard)
+	createTestWalkTree(t, tmpDir)
+
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+
+	err := fsys.WalkConcurrent(ctx, 4, func(string, *fuse.Dirent, fs.Node, fuse.Attr) error {
+		return nil
+	})
+	require.ErrorIs(t, err, context.Canceled)
+}
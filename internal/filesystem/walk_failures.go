@@ -0,0 +1,150 @@
+package filesystem
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"bazil.org/fuse"
+	"bazil.org/fuse/fs"
+)
+
+// WalkIncompleteError is returned by a walk with [Options.WalkContinueOnError]
+// once it is complete, if any nodes failed (with their subtrees not walked).
+// It matches [ErrWalkIncomplete], as well as the errors of all the failures
+// (e.g. [ErrArchiveUnreadable] or [ErrWalkTimeout]) using [errors.Is].
+type WalkIncompleteError struct {
+	Failures []error
+}
+
+func (e *WalkIncompleteError) Error() string {
+	return fmt.Sprintf("%v: %d failed nodes", ErrWalkIncomplete, len(e.Failures))
+}
+
+// Unwrap returns [ErrWalkIncomplete] and the errors of all the failures.
+func (e *WalkIncompleteError) Unwrap() []error {
+	return append([]error{ErrWalkIncomplete}, e.Failures...)
+}
+
+// walkFailures collects the errors of the nodes that failed during a walk with
+// [Options.WalkContinueOnError], which is continued with the rest of the tree.
+// A nil [walkFailures] (without the option) collects nothing, failing fast.
+type walkFailures struct {
+	sync.Mutex
+
+	errs []error
+}
+
+// newWalkFailures returns a pointer to a new [walkFailures] for a walk, or
+// nil without [Options.WalkContinueOnError] (so that any failure ends it).
+func (fsys *FS) newWalkFailures() *walkFailures {
+	if !fsys.Options.WalkContinueOnError {
+		return nil
+	}
+
+	return &walkFailures{}
+}
+
+// Err returns a [WalkIncompleteError] of all the collected failures (or nil).
+func (f *walkFailures) Err() error {
+	if f == nil {
+		return nil
+	}
+
+	f.Lock()
+	defer f.Unlock()
+
+	if len(f.errs) == 0 {
+		return nil
+	}
+
+	return &WalkIncompleteError{Failures: f.errs}
+}
+
+// tolerateWalk returns nil for an error at a node of a walk that is continued
+// past it (with [Options.WalkContinueOnError]), logging and collecting it as a
+// failure, otherwise it returns the error. The walk is never continued past
+// errors once the context is done, as any further nodes would fail just the same.
+func (fsys *FS) tolerateWalk(ctx context.Context, failures *walkFailures, err error) error {
+	if failures == nil || ctx.Err() != nil {
+		return err
+	}
+
+	fsys.rbuf.Printf("Error: Walk: %v (continuing)\n", err)
+
+	failures.Lock()
+	failures.errs = append(failures.errs, err)
+	failures.Unlock()
+
+	return nil
+}
+
+// walkCall calls a function on a node of a walk, which is abandoned after the
+// timeout (if > 0), returning [ErrWalkTimeout] instead (e.g. on stuck storage).
+// The abandoned call still runs to its end, but its results are discarded.
+func walkCall[T any](ctx context.Context, timeout time.Duration, call func(context.Context) (T, error)) (T, error) {
+	if timeout <= 0 {
+		return call(ctx)
+	}
+
+	callCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	type result struct {
+		v   T
+		err error
+	}
+	done := make(chan result, 1)
+
+	go func() {
+		v, err := call(callCtx)
+		done <- result{v, err}
+	}()
+
+	select {
+	case r := <-done:
+		return r.v, r.err
+	case <-callCtx.Done():
+		var zero T
+		if err := ctx.Err(); err != nil {
+			return zero, fmt.Errorf("context error: %w", err)
+		}
+
+		return zero, fmt.Errorf("%w after %s", ErrWalkTimeout, timeout)
+	}
+}
+
+// walkAttr returns the attributes of a node of a walk (see [walkCall]).
+func (fsys *FS) walkAttr(ctx context.Context, node fs.Node) (fuse.Attr, error) {
+	return walkCall(ctx, fsys.Options.WalkTimeout, func(ctx context.Context) (fuse.Attr, error) {
+		var attr fuse.Attr
+		err := node.Attr(ctx, &attr)
+
+		return attr, err
+	})
+}
+
+// walkContextKey is the key marking the context of listing a directory within
+// a walk, whose listings are never truncated (see [Options.MaxListEntries]).
+type walkContextKey struct{}
+
+// isWalk returns if a context is of listing a directory within a walk.
+func isWalk(ctx context.Context) bool {
+	walk, _ := ctx.Value(walkContextKey{}).(bool)
+
+	return walk
+}
+
+// walkReadDirAll returns the entries of a directory of a walk (see [walkCall]).
+// These are all of the entries, as the listings of walks are never truncated.
+func (fsys *FS) walkReadDirAll(ctx context.Context, node fs.HandleReadDirAller) ([]fuse.Dirent, error) {
+	return walkCall(context.WithValue(ctx, walkContextKey{}, true), fsys.Options.WalkTimeout, node.ReadDirAll)
+}
+
+// walkLookup returns the child of a directory of a walk (see [walkCall]).
+func (fsys *FS) walkLookup(ctx context.Context, node fs.NodeStringLookuper, name string) (fs.Node, error) {
+	return walkCall(ctx, fsys.Options.WalkTimeout, func(ctx context.Context) (fs.Node, error) {
+		return node.Lookup(ctx, name)
+	})
+}
@@ -0,0 +1,120 @@
+package filesystem
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"bazil.org/fuse"
+	"bazil.org/fuse/fs"
+	"github.com/stretchr/testify/require"
+)
+
+// hangingDirNode is a directory whose listing hangs until released (or until
+// its context is done), as a directory on stuck storage would.
+type hangingDirNode struct {
+	fs.Node
+
+	release chan struct{}
+}
+
+func (h *hangingDirNode) ReadDirAll(ctx context.Context) ([]fuse.Dirent, error) {
+	select {
+	case <-h.release:
+	case <-ctx.Done():
+	}
+
+	return nil, nil
+}
+
+func (h *hangingDirNode) Lookup(ctx context.Context, name string) (fs.Node, error) {
+	return h.Node.(fs.NodeStringLookuper).Lookup(ctx, name) //nolint:forcetypeassert
+}
+
+// Expectation: Walk and WalkConcurrent should continue past an unreadable
+// archive with WalkContinueOnError, visiting all other nodes of the tree and
+// returning a WalkIncompleteError matching the error of the failure.
+func Test_FS_Walk_ContinueOnError_Success(t *testing.T) {
+	t.Parallel()
+
+	tmpDir, fsys := testFS(t, io.Discard)
+	createTestWalkTree(t, tmpDir)
+	fsys.Options.WalkContinueOnError = true
+
+	expected, err := walkTestPaths(t, fsys, 0, "")
+	require.ErrorIs(t, err, ErrWalkIncomplete)
+	require.ErrorIs(t, err, ErrArchiveUnreadable)
+
+	var incomplete *WalkIncompleteError
+	require.ErrorAs(t, err, &incomplete)
+	require.Len(t, incomplete.Failures, 1)
+	require.Contains(t, incomplete.Failures[0].Error(), "/dir1/bad")
+
+	require.True(t, slices.ContainsFunc(expected, func(p string) bool {
+		return strings.HasSuffix(p, " /dir2/sub/b/docs/images/logo.png")
+	}), "walk should have continued past the failure")
+
+	for _, workers := range []int{2, 8} {
+		paths, err := walkTestPaths(t, fsys, workers, "")
+		require.ErrorIs(t, err, ErrWalkIncomplete)
+		require.ErrorIs(t, err, ErrArchiveUnreadable)
+		require.Equal(t, expected, paths, "%d workers", workers)
+	}
+
+	require.NoError(t, os.Remove(filepath.Join(tmpDir, "dir1", "bad.zip")))
+
+	paths, err := walkTestPaths(t, fsys, 0, "")
+	require.NoError(t, err)
+	require.Equal(t, slices.DeleteFunc(expected, func(p string) bool {
+		return strings.HasSuffix(p, " /dir1/bad") // visited, but failed to list
+	}), paths)
+}
+
+// Expectation: A hanging listing should be abandoned after WalkTimeout, failing
+// the walk fast without WalkContinueOnError, and continuing past it otherwise.
+func Test_FS_Walk_Timeout_Success(t *testing.T) {
+	t.Parallel()
+
+	tmpDir, fsys := testFS(t, io.Discard)
+	createTestWalkTree(t, tmpDir)
+	require.NoError(t, os.Remove(filepath.Join(tmpDir, "dir1", "bad.zip")))
+
+	release := make(chan struct{})
+	defer close(release)
+
+	fsys.Options.WalkTimeout = 50 * time.Millisecond
+	fsys.Options.NodeHook = func(path string, node fs.Node) fs.Node {
+		if path == "dir1" {
+			return &hangingDirNode{Node: node, release: release}
+		}
+
+		return node
+	}
+
+	_, err := walkTestPaths(t, fsys, 0, "")
+	require.ErrorIs(t, err, ErrWalkTimeout)
+	require.NotErrorIs(t, err, ErrWalkIncomplete)
+
+	fsys.Options.WalkContinueOnError = true
+
+	for _, workers := range []int{0, 4} {
+		paths, err := walkTestPaths(t, fsys, workers, "")
+		require.ErrorIs(t, err, ErrWalkIncomplete)
+		require.ErrorIs(t, err, ErrWalkTimeout)
+
+		require.True(t, slices.ContainsFunc(paths, func(p string) bool {
+			return strings.HasSuffix(p, " /dir1")
+		}))
+		require.False(t, slices.ContainsFunc(paths, func(p string) bool {
+			return strings.Contains(p, " /dir1/")
+		}))
+		require.True(t, slices.ContainsFunc(paths, func(p string) bool {
+			return strings.HasSuffix(p, " /dir2/sub/a")
+		}))
+	}
+}
@@ -0,0 +1,256 @@
+package filesystem
+
+import (
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+
+	"github.com/klauspost/compress/zip"
+)
+
+const (
+	// flagEncrypted is the general purpose bit flag of an encrypted file.
+	flagEncrypted = 0x1
+
+	// flagDataDescriptor is the general purpose bit flag of a file whose
+	// CRC-32 and sizes follow its data (so were not known when it was written).
+	flagDataDescriptor = 0x8
+
+	// zipCryptoHeaderLen is the length of the encryption header preceding the
+	// (encrypted) data of a file, which is part of the file's compressed size.
+	zipCryptoHeaderLen = 12
+)
+
+var (
+	_ PasswordProvider = (*GlobPasswords)(nil)
+	_ fmt.Stringer     = (*GlobPasswords)(nil)
+	_ io.Reader        = (*zipCryptoReader)(nil)
+	_ io.ReadCloser    = (*checksumReader)(nil)
+
+	// errWrongPassword is for a password not matching the encryption header.
+	errWrongPassword = errors.New("wrong password")
+)
+
+// PasswordProvider provides the passwords of archives (see [Options.Passwords]).
+type PasswordProvider interface {
+	// Password returns the password of an archive (by its slash-separated path
+	// relative to the source directory, or "." for a single archive as source),
+	// or false if no password is known for the archive.
+	Password(relPath string) (string, bool)
+}
+
+// GlobPasswords is a [PasswordProvider] of passwords for archives matching
+// globs, of which the first matching glob takes precedence (see [GlobOverrides]).
+// The zero value provides no passwords, with these to be added with Set().
+type GlobPasswords struct {
+	GlobOverrides[string]
+
+	// Fallback when non-empty is the password of all archives matching none
+	// of the globs (e.g. a single password for a whole encrypted collection).
+	Fallback string
+}
+
+// Password returns the password of the first glob matching the relative path,
+// or the [GlobPasswords.Fallback] password (if any) when none of them match.
+func (p *GlobPasswords) Password(relPath string) (string, bool) {
+	if password, ok := p.Match(relPath); ok {
+		return password, true
+	}
+
+	return p.Fallback, p.Fallback != ""
+}
+
+// String returns a summary of the passwords for diagnostic output, which
+// never contains any of the passwords themselves (these are masked).
+func (p *GlobPasswords) String() string {
+	fallback := "none"
+	if p.Fallback != "" {
+		fallback = "********"
+	}
+
+	return fmt.Sprintf("%d glob(s), fallback %s", len(p.List()), fallback)
+}
+
+// password returns the password of an archive from [Options.Passwords].
+func (fsys *FS) password(archive string) (string, bool) {
+	if fsys.Options.Passwords == nil {
+		return "", false
+	}
+
+	return fsys.Options.Passwords.Password(fsys.archiveRelPath(archive))
+}
+
+// isEncrypted returns if a file is encrypted (by its general purpose bit flags).
+func isEncrypted(flags uint16) bool {
+	return flags&flagEncrypted != 0
+}
+
+// checkEncrypted returns an error retaining [ErrEncryptedEntry] for an encrypted
+// file within an archive that no password is known for, so that it fails cleanly
+// upfront (instead of on reading), logging it. A wrong password is only detected
+// once the file is opened for reading (see [FS.openEncrypted]).
+func (fsys *FS) checkEncrypted(archive, path string, flags uint16) error {
+	if !isEncrypted(flags) {
+		return nil
+	}
+
+	if _, ok := fsys.password(archive); ok {
+		return nil
+	}
+
+	fsys.rbuf.Printf("Error: %q->%q: encrypted file, but no password for the archive\n", archive, path)
+
+	return fmt.Errorf("%w: no password", ErrEncryptedEntry)
+}
+
+// openEncrypted opens a [zip.File] encrypted with the traditional PKWARE
+// encryption (ZipCrypto) and returns a new [zipFileReader] for it. The raw
+// data is decrypted, decompressed and verified (CRC-32) while being read,
+// which is not seekable, so the [zipFileReader] forwards it by discarding.
+func (fsys *FS) openEncrypted(archive string, f *zip.File) (*zipFileReader, error) {
+	if err := fsys.checkEncrypted(archive, f.Name, f.Flags); err != nil {
+		return nil, err
+	}
+
+	password, _ := fsys.password(archive)
+
+	raw, err := f.OpenRaw()
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to open: %w", ErrCorruptEntry, err)
+	}
+
+	cr, err := newZipCryptoReader(raw, password, zipCryptoCheck(f))
+	if errors.Is(err, errWrongPassword) {
+		fsys.rbuf.Printf("Error: %q->%q: encrypted file, but wrong password for the archive\n", archive, f.Name)
+
+		return nil, fmt.Errorf("%w: %w", ErrEncryptedEntry, err)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to decrypt: %w", ErrCorruptEntry, err)
+	}
+
+	return &zipFileReader{r: newChecksumReader(decompressor(f.Method)(cr), f), f: f}, nil
+}
+
+// zipCryptoCheck returns the byte that the last byte of the (decrypted)
+// encryption header of a file is checked against, for verifying a password.
+// This is the high byte of the CRC-32, or of the modified time if the CRC-32
+// was not known when the file was written (followed by a data descriptor).
+func zipCryptoCheck(f *zip.File) byte {
+	if f.Flags&flagDataDescriptor != 0 {
+		return byte(f.ModifiedTime >> 8) //nolint:staticcheck
+	}
+
+	return byte(f.CRC32 >> 24)
+}
+
+// zipCryptoReader is an [io.Reader] decrypting the raw data of a [zip.File]
+// encrypted with the traditional PKWARE encryption (ZipCrypto), as specified
+// by the APPNOTE. The encryption is weak, but still common for older archives.
+type zipCryptoReader struct {
+	r    io.Reader
+	keys [3]uint32
+}
+
+// newZipCryptoReader returns a new [zipCryptoReader] for the raw data of a file,
+// having read the encryption header and verified the password against its check
+// byte. A wrong password returns [errWrongPassword] (with a chance of 1 in 256
+// to pass, in which case the CRC-32 of the decrypted file fails verification).
+func newZipCryptoReader(r io.Reader, password string, check byte) (*zipCryptoReader, error) {
+	z := &zipCryptoReader{
+		r:    r,
+		keys: [3]uint32{0x12345678, 0x23456789, 0x34567890}, //nolint:mnd
+	}
+
+	for i := range len(password) {
+		z.update(password[i])
+	}
+
+	var header [zipCryptoHeaderLen]byte
+	if _, err := io.ReadFull(z, header[:]); err != nil {
+		return nil, fmt.Errorf("failed to read header: %w", err)
+	}
+
+	if header[zipCryptoHeaderLen-1] != check {
+		return nil, errWrongPassword
+	}
+
+	return z, nil
+}
+
+func (z *zipCryptoReader) Read(p []byte) (int, error) {
+	n, err := z.r.Read(p)
+
+	for i := range n {
+		p[i] ^= z.stream()
+		z.update(p[i])
+	}
+
+	return n, err //nolint:wrapcheck
+}
+
+// update updates the keys with a byte of plain data (or of the password).
+//
+//nolint:mnd
+func (z *zipCryptoReader) update(b byte) {
+	z.keys[0] = crc32Update(z.keys[0], b)
+	z.keys[1] = (z.keys[1]+z.keys[0]&0xff)*134775813 + 1
+	z.keys[2] = crc32Update(z.keys[2], byte(z.keys[1]>>24))
+}
+
+// stream returns the next byte of the key stream, as XOR-ed with the data.
+//
+//nolint:mnd
+func (z *zipCryptoReader) stream() byte {
+	t := z.keys[2] | 2
+
+	return byte((t * (t ^ 1)) >> 8)
+}
+
+// crc32Update is a single step of the CRC-32 (without pre- and post-inversion),
+// as used by the key schedule of the traditional PKWARE encryption.
+func crc32Update(crc uint32, b byte) uint32 {
+	return crc32.IEEETable[byte(crc)^b] ^ (crc >> 8) //nolint:mnd
+}
+
+// checksumReader is an [io.ReadCloser] verifying the size and CRC-32 of a file
+// once it was read to its end, as is done by [zip.File.Open] for regular reads.
+// Any mismatch is returned as [zip.ErrChecksum] (or [io.ErrUnexpectedEOF]).
+type checksumReader struct {
+	rc    io.ReadCloser
+	f     *zip.File
+	hash  hash.Hash32
+	nread uint64
+}
+
+// newChecksumReader returns a new [checksumReader] for the data of a file.
+func newChecksumReader(rc io.ReadCloser, f *zip.File) *checksumReader {
+	return &checksumReader{rc: rc, f: f, hash: crc32.NewIEEE()}
+}
+
+func (c *checksumReader) Read(p []byte) (int, error) {
+	n, err := c.rc.Read(p)
+	c.hash.Write(p[:n])
+	c.nread += uint64(n)
+
+	if c.nread > c.f.UncompressedSize64 {
+		return n, zip.ErrFormat
+	}
+
+	if errors.Is(err, io.EOF) {
+		if c.nread != c.f.UncompressedSize64 {
+			return n, io.ErrUnexpectedEOF
+		}
+		if c.hash.Sum32() != c.f.CRC32 {
+			return n, zip.ErrChecksum
+		}
+	}
+
+	return n, err //nolint:wrapcheck
+}
+
+func (c *checksumReader) Close() error {
+	return c.rc.Close() //nolint:wrapcheck
+}
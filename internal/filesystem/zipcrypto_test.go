@@ -0,0 +1,259 @@
+package filesystem
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"bazil.org/fuse"
+	"bazil.org/fuse/fs"
+	"github.com/stretchr/testify/require"
+)
+
+// testZipCryptoPassword is the password of [testZipCryptoStored] and [testZipCryptoDeflated].
+const testZipCryptoPassword = "secret"
+
+// testZipCryptoStoredContent is the content of "file.txt" within [testZipCryptoStored].
+var testZipCryptoStoredContent = []byte("hello zipcrypto world\n")
+
+// testZipCryptoStored is an archive (as written by Info-ZIP "zip -0 -P secret")
+// with a single stored file "file.txt", encrypted with ZipCrypto.
+var testZipCryptoStored = []byte{
+	0x50, 0x4b, 0x03, 0x04, 0x0a, 0x00, 0x09, 0x00, 0x00, 0x00, 0x9d, 0x20, 0x51, 0x5d, 0x37,
+	0xa1, 0x8a, 0x0f, 0x22, 0x00, 0x00, 0x00, 0x16, 0x00, 0x00, 0x00, 0x08, 0x00, 0x00, 0x00,
+	0x66, 0x69, 0x6c, 0x65, 0x2e, 0x74, 0x78, 0x74, 0x3d, 0x12, 0x76, 0x19, 0x07, 0x39, 0x38,
+	0x10, 0x06, 0xf8, 0x14, 0xe8, 0x16, 0x19, 0xe3, 0x6b, 0xa4, 0x36, 0x39, 0xc0, 0x4b, 0x47,
+	0x2e, 0x5e, 0xb5, 0xbb, 0x6e, 0xd9, 0xa6, 0x7b, 0xb9, 0x5d, 0x25, 0xe3, 0x50, 0x4b, 0x07,
+	0x08, 0x37, 0xa1, 0x8a, 0x0f, 0x22, 0x00, 0x00, 0x00, 0x16, 0x00, 0x00, 0x00, 0x50, 0x4b,
+	0x01, 0x02, 0x1e, 0x03, 0x0a, 0x00, 0x09, 0x00, 0x00, 0x00, 0x9d, 0x20, 0x51, 0x5d, 0x37,
+	0xa1, 0x8a, 0x0f, 0x22, 0x00, 0x00, 0x00, 0x16, 0x00, 0x00, 0x00, 0x08, 0x00, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0xa4, 0x81, 0x00, 0x00, 0x00, 0x00, 0x66,
+	0x69, 0x6c, 0x65, 0x2e, 0x74, 0x78, 0x74, 0x50, 0x4b, 0x05, 0x06, 0x00, 0x00, 0x00, 0x00,
+	0x01, 0x00, 0x01, 0x00, 0x36, 0x00, 0x00, 0x00, 0x58, 0x00, 0x00, 0x00, 0x00, 0x00,
+}
+
+// testZipCryptoDeflatedContent is the content of "big.txt" within [testZipCryptoDeflated].
+var testZipCryptoDeflatedContent = []byte(strings.Repeat("zipcrypto deflated content\n", 20))
+
+// testZipCryptoDeflated is an archive (as written by Info-ZIP "zip -9 -P secret")
+// with a single deflated file "big.txt", encrypted with ZipCrypto.
+var testZipCryptoDeflated = []byte{
+	0x50, 0x4b, 0x03, 0x04, 0x14, 0x00, 0x0b, 0x00, 0x08, 0x00, 0x9d, 0x20, 0x51, 0x5d, 0xc1,
+	0x87, 0xc6, 0xab, 0x2e, 0x00, 0x00, 0x00, 0x1c, 0x02, 0x00, 0x00, 0x07, 0x00, 0x00, 0x00,
+	0x62, 0x69, 0x67, 0x2e, 0x74, 0x78, 0x74, 0xdd, 0x0c, 0x56, 0x7c, 0x4f, 0xd8, 0x58, 0x48,
+	0x36, 0x38, 0xb0, 0x5b, 0xfd, 0xc2, 0x64, 0xcf, 0xfc, 0xf3, 0x04, 0x4a, 0xf7, 0x36, 0x4c,
+	0x3b, 0xe4, 0x7d, 0xc0, 0x36, 0x56, 0x7c, 0x46, 0x88, 0x0e, 0xf7, 0x65, 0x5e, 0xe0, 0x8c,
+	0xb7, 0xb6, 0x4e, 0x12, 0x27, 0x9f, 0x98, 0x1f, 0x50, 0x4b, 0x07, 0x08, 0xc1, 0x87, 0xc6,
+	0xab, 0x2e, 0x00, 0x00, 0x00, 0x1c, 0x02, 0x00, 0x00, 0x50, 0x4b, 0x01, 0x02, 0x1e, 0x03,
+	0x14, 0x00, 0x0b, 0x00, 0x08, 0x00, 0x9d, 0x20, 0x51, 0x5d, 0xc1, 0x87, 0xc6, 0xab, 0x2e,
+	0x00, 0x00, 0x00, 0x1c, 0x02, 0x00, 0x00, 0x07, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x01, 0x00, 0x00, 0x00, 0xa4, 0x81, 0x00, 0x00, 0x00, 0x00, 0x62, 0x69, 0x67, 0x2e, 0x74,
+	0x78, 0x74, 0x50, 0x4b, 0x05, 0x06, 0x00, 0x00, 0x00, 0x00, 0x01, 0x00, 0x01, 0x00, 0x35,
+	0x00, 0x00, 0x00, 0x63, 0x00, 0x00, 0x00, 0x00, 0x00,
+}
+
+// writeTestZipCrypto writes an archive of the fixtures to the directory.
+func writeTestZipCrypto(t *testing.T, tmpDir string, tmpName string, data []byte) string {
+	t.Helper()
+
+	zipPath := filepath.Join(tmpDir, tmpName)
+	require.NoError(t, os.WriteFile(zipPath, data, 0o644))
+
+	return zipPath
+}
+
+// testPasswords returns a [GlobPasswords] with a password for all archives.
+func testPasswords(t *testing.T, password string) *GlobPasswords {
+	t.Helper()
+
+	p := &GlobPasswords{}
+	require.NoError(t, p.Set("*", password))
+
+	return p
+}
+
+// Expectation: The first matching glob should provide the password, otherwise none.
+func Test_GlobPasswords_Password_Success(t *testing.T) {
+	t.Parallel()
+
+	p := &GlobPasswords{}
+	require.NoError(t, p.Set("old/*.zip", "first"))
+	require.NoError(t, p.Set("*/*.zip", "second"))
+
+	pw, ok := p.Password("old/a.zip")
+	require.True(t, ok)
+	require.Equal(t, "first", pw)
+
+	pw, ok = p.Password("new/a.zip")
+	require.True(t, ok)
+	require.Equal(t, "second", pw)
+
+	_, ok = p.Password("a.zip")
+	require.False(t, ok)
+}
+
+// Expectation: The fallback password should be returned for archives matching
+// none of the globs, while never being part of the diagnostic output.
+func Test_GlobPasswords_Fallback_Success(t *testing.T) {
+	t.Parallel()
+
+	p := &GlobPasswords{Fallback: "secret"}
+	require.NoError(t, p.Set("old/*.zip", "first"))
+
+	pw, ok := p.Password("old/a.zip")
+	require.True(t, ok)
+	require.Equal(t, "first", pw)
+
+	pw, ok = p.Password("a.zip")
+	require.True(t, ok)
+	require.Equal(t, "secret", pw)
+
+	require.NotContains(t, p.String(), "secret")
+	require.NotContains(t, fmt.Sprintf("%v", p), "secret")
+	require.Equal(t, "1 glob(s), fallback ********", p.String())
+}
+
+// Expectation: Files encrypted with ZipCrypto should be decrypted with the
+// password, both when stored and deflated, also from an offset (forwarding).
+func Test_FS_ExtractEntry_ZipCrypto_Success(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		data    []byte
+		entry   string
+		content []byte
+	}{
+		{"Stored", testZipCryptoStored, "file.txt", testZipCryptoStoredContent},
+		{"Deflated", testZipCryptoDeflated, "big.txt", testZipCryptoDeflatedContent},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			tmpDir, fsys := testFS(t, io.Discard)
+			fsys.Options.Passwords = testPasswords(t, testZipCryptoPassword)
+
+			zipPath := writeTestZipCrypto(t, tmpDir, "test.zip", tt.data)
+
+			var buf bytes.Buffer
+			n, err := fsys.ExtractEntry(zipPath, tt.entry, 0, -1, &buf)
+			require.NoError(t, err)
+			require.Equal(t, int64(len(tt.content)), n)
+			require.Equal(t, tt.content, buf.Bytes())
+
+			buf.Reset()
+			_, err = fsys.ExtractEntry(zipPath, tt.entry, 6, 9, &buf)
+			require.NoError(t, err)
+			require.Equal(t, tt.content[6:15], buf.Bytes())
+		})
+	}
+}
+
+// Expectation: Files encrypted with ZipCrypto should fail to be extracted
+// without a password or with a wrong password, retaining [ErrEncryptedEntry].
+func Test_FS_ExtractEntry_ZipCrypto_Error(t *testing.T) {
+	t.Parallel()
+
+	for _, password := range []string{"", "wrong"} {
+		t.Run("Password="+strconv.Quote(password), func(t *testing.T) {
+			t.Parallel()
+			tmpDir, fsys := testFS(t, io.Discard)
+			if password != "" {
+				fsys.Options.Passwords = testPasswords(t, password)
+			}
+
+			zipPath := writeTestZipCrypto(t, tmpDir, "test.zip", testZipCryptoDeflated)
+
+			_, err := fsys.ExtractEntry(zipPath, "big.txt", 0, -1, io.Discard)
+			require.ErrorIs(t, err, ErrEncryptedEntry)
+			require.Equal(t, fuse.ToErrno(syscall.EACCES), toErrno(err))
+		})
+	}
+}
+
+// Expectation: Files encrypted with ZipCrypto should be listed, but fail to
+// open with EACCES without a password (for both in-memory and streamed files).
+func Test_zipFileNode_ZipCrypto_Error(t *testing.T) {
+	t.Parallel()
+
+	for _, stream := range []bool{false, true} {
+		t.Run("Stream="+strconv.FormatBool(stream), func(t *testing.T) {
+			t.Parallel()
+			tmpDir, fsys := testFS(t, io.Discard)
+
+			zipPath := writeTestZipCrypto(t, tmpDir, "test.zip", testZipCryptoStored)
+
+			if stream {
+				fsys.Options.StreamingThreshold.Store(0)
+			}
+
+			node := &zipDirNode{
+				fsys:  fsys,
+				inode: fs.GenerateDynamicInode(1, "test"),
+				path:  zipPath,
+				mtime: time.Now(),
+			}
+
+			ent, err := node.ReadDirAll(t.Context())
+			require.NoError(t, err)
+			require.Len(t, ent, 1)
+			require.Equal(t, "file.txt", ent[0].Name)
+
+			fn, err := node.Lookup(t.Context(), "file.txt")
+			require.NoError(t, err)
+
+			opener, ok := fn.(fs.NodeOpener)
+			require.True(t, ok)
+
+			_, err = opener.Open(t.Context(), &fuse.OpenRequest{Flags: fuse.OpenReadOnly}, &fuse.OpenResponse{})
+			require.ErrorIs(t, err, ErrEncryptedEntry)
+			require.Equal(t, fuse.ToErrno(syscall.EACCES), toErrno(err))
+		})
+	}
+}
+
+// Expectation: Files encrypted with ZipCrypto should be streamed with the
+// password, also when rewinding (re-opening the non-seekable decryption).
+func Test_zipDiskStreamFileHandle_ZipCrypto_Success(t *testing.T) {
+	t.Parallel()
+	tmpDir, fsys := testFS(t, io.Discard)
+	fsys.Options.Passwords = testPasswords(t, testZipCryptoPassword)
+	fsys.Options.StreamingThreshold.Store(0)
+
+	zipPath := writeTestZipCrypto(t, tmpDir, "test.zip", testZipCryptoDeflated)
+
+	node := &zipDirNode{
+		fsys:  fsys,
+		inode: fs.GenerateDynamicInode(1, "test"),
+		path:  zipPath,
+		mtime: time.Now(),
+	}
+
+	fn, err := node.Lookup(t.Context(), "big.txt")
+	require.NoError(t, err)
+
+	opener, ok := fn.(fs.NodeOpener)
+	require.True(t, ok)
+
+	h, err := opener.Open(t.Context(), &fuse.OpenRequest{Flags: fuse.OpenReadOnly}, &fuse.OpenResponse{})
+	require.NoError(t, err)
+
+	handle, ok := h.(*zipDiskStreamFileHandle)
+	require.True(t, ok)
+	defer handle.Release(t.Context(), &fuse.ReleaseRequest{}) //nolint:errcheck
+
+	for _, offset := range []int64{100, 10} {
+		resp := &fuse.ReadResponse{}
+		require.NoError(t, handle.Read(t.Context(), &fuse.ReadRequest{Offset: offset, Size: 50}, resp))
+		require.Equal(t, testZipCryptoDeflatedContent[offset:offset+50], resp.Data)
+	}
+	require.Equal(t, int64(1), fsys.Metrics.TotalStreamRewinds.Load())
+}
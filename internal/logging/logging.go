@@ -2,6 +2,7 @@
 package logging
 
 import (
+	"encoding/json"
 	"fmt"
 	"io"
 	"strings"
@@ -18,18 +19,80 @@ type RingBuffer struct {
 	index int
 	full  bool
 	size  int
+
+	jsonMu  sync.Mutex
+	jsonOut io.Writer
+
+	repeatMu     sync.Mutex
+	repeatWindow time.Duration
+	repeats      map[string]*repeat
+	repeatOrder  []*repeat   // The repeats by their windows (oldest first).
+	repeatTimer  *time.Timer // Ends the oldest window (if any is pending).
+}
+
+// repeat is a message being coalesced (see [RingBuffer.SetRepeatWindow]).
+type repeat struct {
+	msg   string
+	since time.Time // When the message was last written (start of window).
+	count int       // Amount of identical messages suppressed since then.
+}
+
+// jsonEvent is a message as written to the JSON output (one per line).
+type jsonEvent struct {
+	Time    time.Time `json:"time"`
+	Level   string    `json:"level"`
+	Message string    `json:"message"`
 }
 
 // NewRingBuffer returns a pointer to a new [ringBuffer].
+// A size of zero (or less) disables the in-memory retention,
+// so that messages are only printed to output, but never stored.
 func NewRingBuffer(size int, out io.Writer) *RingBuffer {
+	size = max(size, 0)
+
 	return &RingBuffer{
-		out:  out,
-		buf:  make([]string, size),
-		size: size,
+		out:     out,
+		buf:     make([]string, size),
+		size:    size,
+		repeats: make(map[string]*repeat),
+	}
+}
+
+// SetJSONOutput sets an additional output, which receives all messages as
+// JSON objects (one per line), independently of the ring-buffer and output.
+// A nil [io.Writer] disables the JSON output again (which is the default).
+func (b *RingBuffer) SetJSONOutput(w io.Writer) {
+	b.jsonMu.Lock()
+	defer b.jsonMu.Unlock()
+
+	b.jsonOut = w
+}
+
+// SetRepeatWindow sets the window within which identical messages are coalesced,
+// so that a message repeating (e.g. an error of a client retrying a corrupt file)
+// is written once, with any repetitions within the window being suppressed. Once
+// the window has passed, the amount of suppressed repetitions is written as the
+// message suffixed with "(repeated N times)" (at the latest once the window has
+// passed, also if no other message follows). A window of zero (or less) disables
+// the coalescing again (which is the default), writing any pending repetitions.
+func (b *RingBuffer) SetRepeatWindow(d time.Duration) {
+	b.repeatMu.Lock()
+	defer b.repeatMu.Unlock()
+
+	if d <= 0 {
+		b.endRepeats(time.Now(), true)
 	}
+	b.repeatWindow = d
+}
+
+// Close writes any pending repetitions (see [RingBuffer.SetRepeatWindow]) and
+// disables the coalescing, so it is to be called before the outputs are closed.
+// The ring-buffer itself remains usable, with all messages then being written.
+func (b *RingBuffer) Close() {
+	b.SetRepeatWindow(0)
 }
 
-// Size returns the size of the ring-buffer.
+// Size returns the size of the ring-buffer (zero if disabled).
 func (b *RingBuffer) Size() int {
 	return b.size
 }
@@ -64,27 +127,108 @@ func (b *RingBuffer) Reset() {
 
 // Printf adds a message to the ring-buffer and also prints it to output.
 func (b *RingBuffer) Printf(format string, args ...any) {
-	timestamp := time.Now().Format("2006-01-02 15:04:05")
-
-	msg := fmt.Sprintf(format, args...)
-	full := fmt.Sprintf("%s %s", timestamp, msg)
-
-	b.add(full)                    // add to buffer with timestamp
-	fmt.Fprintf(b.out, "%s", full) // also goes to stream
+	b.print(time.Now(), fmt.Sprintf(format, args...))
 }
 
 // Println adds a message to the ring-buffer and also prints it to output.
 func (b *RingBuffer) Println(args ...any) {
-	timestamp := time.Now().Format("2006-01-02 15:04:05")
+	b.print(time.Now(), fmt.Sprintln(args...))
+}
+
+// print writes a message, unless it is a repetition that is being coalesced
+// (see [RingBuffer.SetRepeatWindow]). The repetitions of all messages whose
+// window has passed are written first (and their windows are then ended).
+func (b *RingBuffer) print(t time.Time, msg string) {
+	b.repeatMu.Lock()
+	defer b.repeatMu.Unlock()
+
+	if b.repeatWindow <= 0 {
+		b.write(t, msg)
+
+		return
+	}
+
+	b.endRepeats(t, false)
+
+	if r, ok := b.repeats[msg]; ok {
+		r.count++
+
+		return
+	}
+
+	r := &repeat{msg: msg, since: t}
+	b.repeats[msg] = r
+	b.repeatOrder = append(b.repeatOrder, r)
+
+	if b.repeatTimer == nil {
+		b.repeatTimer = time.AfterFunc(b.repeatWindow, b.timeoutRepeats)
+	}
+
+	b.write(t, msg)
+}
+
+// endRepeats ends the windows that have passed at t (or all of them), writing
+// their amounts of suppressed repetitions. As the windows are all of the same
+// length, they pass in the order of being started, so only those are visited.
+// The caller needs to hold the repeatMu lock.
+func (b *RingBuffer) endRepeats(t time.Time, all bool) {
+	for len(b.repeatOrder) > 0 {
+		r := b.repeatOrder[0]
+		if !all && t.Sub(r.since) < b.repeatWindow {
+			break
+		}
+
+		if r.count > 0 {
+			b.write(t, fmt.Sprintf("%s (repeated %d times)\n", strings.TrimRight(r.msg, "\n"), r.count))
+		}
+		delete(b.repeats, r.msg)
+
+		b.repeatOrder[0] = nil
+		b.repeatOrder = b.repeatOrder[1:]
+	}
+
+	if len(b.repeatOrder) == 0 && b.repeatTimer != nil {
+		b.repeatTimer.Stop()
+		b.repeatTimer = nil
+	}
+}
+
+// timeoutRepeats ends the windows that have passed (as for the next message),
+// so that their repetitions are written also if no other message follows, and
+// then waits for the next window to pass (if any is still pending).
+func (b *RingBuffer) timeoutRepeats() {
+	b.repeatMu.Lock()
+	defer b.repeatMu.Unlock()
+
+	if b.repeatWindow <= 0 {
+		return // Was disabled in the meantime (see SetRepeatWindow).
+	}
+
+	now := time.Now()
+	b.endRepeats(now, false)
+
+	if len(b.repeatOrder) > 0 {
+		next := b.repeatOrder[0].since.Add(b.repeatWindow).Sub(now)
+		b.repeatTimer = time.AfterFunc(max(next, 0), b.timeoutRepeats)
+	}
+}
+
+// write adds a message to the ring-buffer and also prints it to the outputs.
+func (b *RingBuffer) write(t time.Time, msg string) {
+	timestamp := t.Format("2006-01-02 15:04:05")
 
-	msg := fmt.Sprintln(args...)
-	full := fmt.Sprintf("%s %s", timestamp, strings.TrimRight(msg, "\n"))
+	full := fmt.Sprintf("%s %s", timestamp, msg)
 
-	b.add(full)                      // add to buffer with timestamp
-	fmt.Fprintf(b.out, "%s\n", full) // also goes to stream
+	b.add(full)                    // add to buffer with timestamp
+	fmt.Fprintf(b.out, "%s", full) // also goes to stream
+	b.writeJSON(t, msg)            // also goes to JSON stream
 }
 
 func (b *RingBuffer) add(msg string) {
+	if b.size == 0 {
+		return
+	}
+
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
@@ -94,3 +238,37 @@ func (b *RingBuffer) add(msg string) {
 		b.full = true
 	}
 }
+
+// writeJSON writes a message to the JSON output (if set), deriving the level
+// of the message from its contents (as by [messageLevel]).
+func (b *RingBuffer) writeJSON(t time.Time, msg string) {
+	b.jsonMu.Lock()
+	defer b.jsonMu.Unlock()
+
+	if b.jsonOut == nil {
+		return
+	}
+
+	msg = strings.TrimRight(msg, "\n")
+
+	_ = json.NewEncoder(b.jsonOut).Encode(jsonEvent{
+		Time:    t,
+		Level:   messageLevel(msg),
+		Message: msg,
+	})
+}
+
+// messageLevel returns the level of a message by its prefix (e.g. "Skipped:"),
+// with any message containing an error (e.g. "ZIP Error:") being an error.
+func messageLevel(msg string) string {
+	switch {
+	case strings.Contains(strings.ToLower(msg), "error:"):
+		return "error"
+	case strings.HasPrefix(msg, "Warning:"), strings.HasPrefix(msg, "Tripped:"):
+		return "warning"
+	case strings.HasPrefix(msg, "Skipped:"):
+		return "notice"
+	default:
+		return "info"
+	}
+}
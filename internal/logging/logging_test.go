@@ -2,9 +2,13 @@ package logging
 
 import (
 	"bytes"
+	"encoding/json"
+	"io"
 	"os"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 )
@@ -193,3 +197,175 @@ func Test_Println_Success(t *testing.T) {
 	require.Contains(t, lines[0], "test message")
 	require.Contains(t, out.String(), "test message\n")
 }
+
+// Expectation: A zero-size buffer should retain nothing, but still write to output.
+func Test_ringBuffer_ZeroSize_Success(t *testing.T) {
+	t.Parallel()
+
+	var out bytes.Buffer
+	buf := NewRingBuffer(0, &out)
+
+	require.Equal(t, 0, buf.Size())
+
+	buf.Printf("test %s\n", "printf")
+	buf.Println("test println")
+	buf.Reset()
+	buf.Println("test after reset")
+
+	lines := buf.Lines()
+	require.NotNil(t, lines)
+	require.Empty(t, lines)
+
+	require.Contains(t, out.String(), "test printf\n")
+	require.Contains(t, out.String(), "test println\n")
+	require.Contains(t, out.String(), "test after reset\n")
+}
+
+// Expectation: A negative-size buffer should behave the same as a zero-size buffer.
+func Test_ringBuffer_NegativeSize_Success(t *testing.T) {
+	t.Parallel()
+
+	buf := NewRingBuffer(-1, io.Discard)
+
+	require.Equal(t, 0, buf.Size())
+
+	buf.Println("test")
+	require.Empty(t, buf.Lines())
+}
+
+// Expectation: The JSON output should receive all messages as JSON objects, one per line.
+func Test_ringBuffer_SetJSONOutput_Success(t *testing.T) {
+	t.Parallel()
+
+	var out, jsonOut bytes.Buffer
+	buf := NewRingBuffer(0, &out)
+	buf.SetJSONOutput(&jsonOut)
+
+	buf.Printf("Error: %q->Lookup: failed\n", "test.zip")
+	buf.Println("Skipped:", "test")
+	buf.Println("Metrics reset via API.")
+
+	lines := strings.Split(strings.TrimSpace(jsonOut.String()), "\n")
+	require.Len(t, lines, 3)
+
+	var ev jsonEvent
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &ev))
+	require.Equal(t, "error", ev.Level)
+	require.Equal(t, `Error: "test.zip"->Lookup: failed`, ev.Message)
+	require.False(t, ev.Time.IsZero())
+
+	require.NoError(t, json.Unmarshal([]byte(lines[1]), &ev))
+	require.Equal(t, "notice", ev.Level)
+	require.Equal(t, "Skipped: test", ev.Message)
+
+	require.NoError(t, json.Unmarshal([]byte(lines[2]), &ev))
+	require.Equal(t, "info", ev.Level)
+
+	require.Contains(t, out.String(), "Metrics reset via API.\n")
+	require.NotContains(t, out.String(), "{")
+
+	buf.SetJSONOutput(nil)
+	buf.Println("not in JSON")
+	require.NotContains(t, jsonOut.String(), "not in JSON")
+}
+
+// Expectation: Identical messages within the repeat window should be written
+// once, with the suppressed repetitions being written once the window passed.
+func Test_ringBuffer_SetRepeatWindow_Success(t *testing.T) {
+	t.Parallel()
+
+	var out bytes.Buffer
+	buf := NewRingBuffer(10, &out)
+	buf.SetRepeatWindow(time.Minute)
+
+	now := time.Now()
+	msg := "Error: \"test.zip\"->Read->\"file.txt\": IO Error: corrupt\n"
+
+	for i := range 5 {
+		buf.print(now.Add(time.Duration(i)*time.Second), msg)
+	}
+	buf.print(now.Add(10*time.Second), "other\n")
+
+	lines := buf.Lines()
+	require.Len(t, lines, 2)
+	require.Contains(t, lines[0], msg[:len(msg)-1])
+	require.Contains(t, lines[1], "other")
+
+	buf.print(now.Add(2*time.Minute), msg)
+
+	lines = buf.Lines()
+	require.Len(t, lines, 4)
+	require.Contains(t, lines[2], msg[:len(msg)-1]+" (repeated 4 times)")
+	require.True(t, strings.HasSuffix(lines[3], msg[:len(msg)-1]))
+	require.Equal(t, 4, strings.Count(out.String(), "\n"))
+
+	buf.SetRepeatWindow(0)
+	buf.print(now.Add(2*time.Minute), msg)
+	require.Len(t, buf.Lines(), 5)
+}
+
+// Expectation: The suppressed repetitions should be written once the window
+// has passed, also without any other message following, or once closed.
+func Test_ringBuffer_SetRepeatWindow_Timeout_Success(t *testing.T) {
+	t.Parallel()
+
+	var mu sync.Mutex
+	var out bytes.Buffer
+	buf := NewRingBuffer(10, writerFunc(func(p []byte) (int, error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		return out.Write(p)
+	}))
+	buf.SetRepeatWindow(50 * time.Millisecond)
+
+	for range 3 {
+		buf.Println("first")
+	}
+
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+
+		return strings.Contains(out.String(), "first (repeated 2 times)")
+	}, 5*time.Second, 10*time.Millisecond)
+
+	buf.repeatMu.Lock()
+	require.Empty(t, buf.repeats)
+	require.Empty(t, buf.repeatOrder)
+	require.Nil(t, buf.repeatTimer)
+	buf.repeatMu.Unlock()
+
+	buf.SetRepeatWindow(time.Hour)
+	for range 4 {
+		buf.Println("second")
+	}
+	buf.Close()
+
+	lines := buf.Lines()
+	require.Len(t, lines, 4)
+	require.True(t, strings.HasSuffix(lines[3], "second (repeated 3 times)"))
+
+	buf.Println("second")
+	require.Len(t, buf.Lines(), 5)
+}
+
+// Expectation: messageLevel should derive the level from the message contents.
+func Test_messageLevel_Success(t *testing.T) {
+	t.Parallel()
+
+	require.Equal(t, "error", messageLevel("Error: test"))
+	require.Equal(t, "error", messageLevel(`"test.zip"->ReadDirAll: ZIP Error: test`))
+	require.Equal(t, "error", messageLevel("HTTP error: test"))
+	require.Equal(t, "warning", messageLevel("Warning: test"))
+	require.Equal(t, "warning", messageLevel("Tripped: test"))
+	require.Equal(t, "notice", messageLevel("Skipped: test"))
+	require.Equal(t, "info", messageLevel("serving dashboard on :8000"))
+}
+
+// writerFunc is an [io.Writer] by a function (e.g. for synchronizing writes).
+type writerFunc func(p []byte) (int, error)
+
+func (f writerFunc) Write(p []byte) (int, error) {
+	return f(p)
+}
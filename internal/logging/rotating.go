@@ -0,0 +1,160 @@
+package logging
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// ErrInvalidRotation is for an invalid rotation size or file count.
+var ErrInvalidRotation = errors.New("invalid rotation configuration")
+
+// RotatingFile is an [io.WriteCloser] appending to a file on disk,
+// rotating it once it would grow beyond a given maximum size. Rotated
+// files are suffixed with a number (".1" being the most recent one),
+// with only a given number of such rotated files being kept on disk.
+// It is thread-safe for concurrent use.
+type RotatingFile struct {
+	mu      sync.Mutex
+	path    string
+	maxSize int64
+	keep    int
+	file    *os.File
+	size    int64
+}
+
+// NewRotatingFile returns a pointer to a new [RotatingFile], opening (or
+// creating) the file at path for appending. The maxSize must be > 0 and
+// keep must be >= 0, the latter being the number of rotated files kept.
+func NewRotatingFile(path string, maxSize int64, keep int) (*RotatingFile, error) {
+	if maxSize <= 0 {
+		return nil, fmt.Errorf("%w: max size must be > 0", ErrInvalidRotation)
+	}
+	if keep < 0 {
+		return nil, fmt.Errorf("%w: keep must be >= 0", ErrInvalidRotation)
+	}
+
+	r := &RotatingFile{
+		path:    path,
+		maxSize: maxSize,
+		keep:    keep,
+	}
+
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// Write writes p to the file, rotating the file first if the write
+// would otherwise grow it beyond the maximum size. A single write
+// larger than the maximum size is still written in its entirety.
+func (r *RotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.file == nil {
+		return 0, os.ErrClosed
+	}
+
+	if r.size > 0 && r.size+int64(len(p)) > r.maxSize {
+		if err := r.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+
+	if err != nil {
+		return n, fmt.Errorf("failed to write: %w", err)
+	}
+
+	return n, nil
+}
+
+// Close closes the underlying file.
+func (r *RotatingFile) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.file == nil {
+		return nil
+	}
+
+	err := r.file.Close()
+	r.file = nil
+
+	if err != nil {
+		return fmt.Errorf("failed to close: %w", err)
+	}
+
+	return nil
+}
+
+// open opens (or creates) the file for appending, continuing
+// from its existing size. The caller must hold the lock.
+func (r *RotatingFile) open() error {
+	f, err := os.OpenFile(r.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o640) //nolint:mnd
+	if err != nil {
+		return fmt.Errorf("failed to open: %w", err)
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+
+		return fmt.Errorf("failed to stat: %w", err)
+	}
+
+	r.file = f
+	r.size = info.Size()
+
+	return nil
+}
+
+// rotate closes the file, shifts all rotated files by one (dropping
+// the oldest one) and then re-opens a new empty file for appending.
+// The file is always re-opened, even if the shifting has failed.
+// The caller must hold the lock.
+func (r *RotatingFile) rotate() error {
+	if err := r.file.Close(); err != nil {
+		return fmt.Errorf("failed to close: %w", err)
+	}
+	r.file = nil
+
+	if err := r.shift(); err != nil {
+		return errors.Join(err, r.open())
+	}
+
+	return r.open()
+}
+
+// shift moves the file (and all previously rotated files) one number up,
+// so that the file itself is no longer present. The caller must hold the lock.
+func (r *RotatingFile) shift() error {
+	if r.keep == 0 {
+		if err := os.Remove(r.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to remove: %w", err)
+		}
+
+		return nil
+	}
+
+	for i := r.keep - 1; i > 0; i-- {
+		src := fmt.Sprintf("%s.%d", r.path, i)
+		dst := fmt.Sprintf("%s.%d", r.path, i+1)
+
+		if err := os.Rename(src, dst); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to rename: %w", err)
+		}
+	}
+
+	if err := os.Rename(r.path, r.path+".1"); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to rename: %w", err)
+	}
+
+	return nil
+}
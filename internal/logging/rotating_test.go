@@ -0,0 +1,132 @@
+package logging
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Expectation: NewRotatingFile should append to an existing file.
+func Test_NewRotatingFile_Append_Success(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "test.log")
+	require.NoError(t, os.WriteFile(path, []byte("old\n"), 0o600))
+
+	r, err := NewRotatingFile(path, 1024, 1)
+	require.NoError(t, err)
+
+	_, err = r.Write([]byte("new\n"))
+	require.NoError(t, err)
+	require.NoError(t, r.Close())
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, "old\nnew\n", string(data))
+}
+
+// Expectation: NewRotatingFile should reject invalid configurations.
+func Test_NewRotatingFile_Invalid_Error(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "test.log")
+
+	_, err := NewRotatingFile(path, 0, 1)
+	require.ErrorIs(t, err, ErrInvalidRotation)
+
+	_, err = NewRotatingFile(path, 1024, -1)
+	require.ErrorIs(t, err, ErrInvalidRotation)
+
+	_, err = os.Stat(path)
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
+
+// Expectation: Write should rotate the file and only keep the configured number of rotated files.
+func Test_RotatingFile_Write_Rotate_Success(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "test.log")
+
+	r, err := NewRotatingFile(path, 6, 2)
+	require.NoError(t, err)
+
+	for _, line := range []string{"aaaa\n", "bbbb\n", "cccc\n", "dddd\n"} {
+		_, err = r.Write([]byte(line))
+		require.NoError(t, err)
+	}
+	require.NoError(t, r.Close())
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, "dddd\n", string(data))
+
+	data, err = os.ReadFile(path + ".1")
+	require.NoError(t, err)
+	require.Equal(t, "cccc\n", string(data))
+
+	data, err = os.ReadFile(path + ".2")
+	require.NoError(t, err)
+	require.Equal(t, "bbbb\n", string(data))
+
+	_, err = os.Stat(path + ".3")
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
+
+// Expectation: Write should truncate the file on rotation when no rotated files are kept.
+func Test_RotatingFile_Write_KeepNone_Success(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "test.log")
+
+	r, err := NewRotatingFile(path, 6, 0)
+	require.NoError(t, err)
+
+	_, err = r.Write([]byte("aaaa\n"))
+	require.NoError(t, err)
+	_, err = r.Write([]byte("bbbb\n"))
+	require.NoError(t, err)
+	require.NoError(t, r.Close())
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, "bbbb\n", string(data))
+
+	_, err = os.Stat(path + ".1")
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
+
+// Expectation: Write should write an oversized message in its entirety.
+func Test_RotatingFile_Write_Oversized_Success(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "test.log")
+
+	r, err := NewRotatingFile(path, 4, 1)
+	require.NoError(t, err)
+
+	n, err := r.Write([]byte("oversized\n"))
+	require.NoError(t, err)
+	require.Equal(t, 10, n)
+	require.NoError(t, r.Close())
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, "oversized\n", string(data))
+}
+
+// Expectation: Write should return an error after the file was closed.
+func Test_RotatingFile_Write_Closed_Error(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "test.log")
+
+	r, err := NewRotatingFile(path, 1024, 1)
+	require.NoError(t, err)
+	require.NoError(t, r.Close())
+	require.NoError(t, r.Close())
+
+	_, err = r.Write([]byte("test\n"))
+	require.ErrorIs(t, err, os.ErrClosed)
+}
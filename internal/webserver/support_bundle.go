@@ -0,0 +1,235 @@
+package webserver
+
+import (
+	"archive/zip"
+	"bytes"
+	"cmp"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"mime"
+	"net/http"
+	"regexp"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// redactedValue replaces the values of secret configuration keys.
+	redactedValue = "(redacted)"
+
+	// hashedPathPrefix leads the pseudonyms of archive paths (see hashPath).
+	hashedPathPrefix = "sha256:"
+)
+
+var (
+	// redactedKeys matches the configuration keys whose values are redacted.
+	redactedKeys = regexp.MustCompile(`(?i)password|secret|token`)
+
+	// quotedString matches the quoted strings (as by "%q") within log lines.
+	quotedString = regexp.MustCompile(`"(?:[^"\\]|\\.)*"`)
+)
+
+// supportBundleRuntime describes the runtime information of a support bundle.
+type supportBundleRuntime struct {
+	Version    string    `json:"version"`
+	GoVersion  string    `json:"goVersion"`
+	GOOS       string    `json:"goos"`
+	GOARCH     string    `json:"goarch"`
+	NumCPU     int       `json:"numCpu"`
+	GOMAXPROCS int       `json:"gomaxprocs"`
+	MountTime  time.Time `json:"mountTime"`
+	Time       time.Time `json:"time"`
+}
+
+// supportBundleHandler handles the support bundle endpoint of the dashboard.
+// It serves a ZIP file of the metrics, configuration, logs, runtime and the
+// failed and open archives (see supportBundle), for attaching to bug reports.
+// The archive paths are hashed with [ServeOptions.BundleHashPaths] (or when
+// requested with "?hash-paths=true"), which cannot be opted out of per request.
+func (d *FSDashboard) supportBundleHandler(w http.ResponseWriter, r *http.Request) {
+	hashPaths := d.hashPaths
+	if raw := r.URL.Query().Get("hash-paths"); raw != "" {
+		val, err := strconv.ParseBool(raw)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Invalid hash-paths value: %v", err), http.StatusBadRequest)
+
+			return
+		}
+		hashPaths = hashPaths || val
+	}
+
+	now := time.Now()
+
+	data, err := d.supportBundle(now, hashPaths)
+	if err != nil {
+		d.rbuf.Printf("HTTP support bundle error: %v\n", err)
+		http.Error(w, fmt.Sprintf("Bundle error: %v", err), http.StatusInternalServerError)
+
+		return
+	}
+
+	name := "zipfuse-support-" + now.Format("20060102-150405") + ".zip"
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": name}))
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	_, _ = w.Write(data)
+}
+
+// supportBundle returns a ZIP file containing the data of the other endpoints
+// ("metrics.json", "errors.json" and "open-zips.json"), the configuration as
+// "config.json" (with secret values redacted), the ring-buffer as "logs.txt"
+// and the runtime information as "runtime.json". With hashPaths, all paths of
+// archives (also within the logs) are replaced by their hashes (see hashPath).
+func (d *FSDashboard) supportBundle(now time.Time, hashPaths bool) ([]byte, error) {
+	metrics := d.collectMetrics()
+	failed := d.fsys.FailedArchives()
+	cached := d.fsys.CachedArchives()
+	logs := d.rbuf.Lines()
+
+	if hashPaths {
+		r := d.pathHasher(logs)
+
+		metrics.LargestZip = r.Replace(metrics.LargestZip)
+		metrics.MostEntriesZip = r.Replace(metrics.MostEntriesZip)
+		for i := range metrics.PinnedZips {
+			metrics.PinnedZips[i] = r.Replace(metrics.PinnedZips[i])
+		}
+		for i := range metrics.Logs {
+			metrics.Logs[i] = r.Replace(metrics.Logs[i])
+		}
+		for i := range failed {
+			failed[i].Path = r.Replace(failed[i].Path)
+			failed[i].LastError = r.Replace(failed[i].LastError)
+		}
+		for i := range cached {
+			cached[i].Path = r.Replace(cached[i].Path)
+		}
+		for i := range logs {
+			logs[i] = r.Replace(logs[i])
+		}
+	}
+
+	info := supportBundleRuntime{
+		Version:    d.version,
+		GoVersion:  runtime.Version(),
+		GOOS:       runtime.GOOS,
+		GOARCH:     runtime.GOARCH,
+		NumCPU:     runtime.NumCPU(),
+		GOMAXPROCS: runtime.GOMAXPROCS(0),
+		MountTime:  d.fsys.MountTime,
+		Time:       now,
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+
+	for _, file := range []struct {
+		name string
+		data any
+	}{
+		{"metrics.json", metrics},
+		{"config.json", redactConfig(d.config)},
+		{"runtime.json", info},
+		{"errors.json", failed},
+		{"open-zips.json", cached},
+	} {
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: file.name, Method: zip.Deflate, Modified: now})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create %s: %w", file.name, err)
+		}
+
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(file.data); err != nil {
+			return nil, fmt.Errorf("failed to encode %s: %w", file.name, err)
+		}
+	}
+
+	w, err := zw.CreateHeader(&zip.FileHeader{Name: "logs.txt", Method: zip.Deflate, Modified: now})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create logs.txt: %w", err)
+	}
+	for _, line := range logs {
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return nil, fmt.Errorf("failed to write logs.txt: %w", err)
+		}
+	}
+
+	if err := zw.Close(); err != nil {
+		return nil, fmt.Errorf("failed to finish: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// redactConfig returns a copy of the configuration, with the non-empty values
+// of all keys naming a secret (e.g. "password-file") replaced by redactedValue.
+func redactConfig(config map[string]string) map[string]string {
+	out := make(map[string]string, len(config))
+
+	for k, v := range config {
+		if v != "" && redactedKeys.MatchString(k) {
+			v = redactedValue
+		}
+		out[k] = v
+	}
+
+	return out
+}
+
+// pathHasher returns a [strings.Replacer] of the paths of all archives known
+// to the dashboard (failed, open and those of the archive statistics), as well
+// as of the quoted paths within the source directory of the log lines, each to
+// their hash (see hashPath), so that the same archive has the same hash within
+// all files of a support bundle (both in its plain and its quoted form).
+func (d *FSDashboard) pathHasher(logs []string) *strings.Replacer {
+	paths := make(map[string]struct{})
+
+	for _, a := range d.fsys.FailedArchives() {
+		paths[a.Path] = struct{}{}
+	}
+	for _, a := range d.fsys.CachedArchives() {
+		paths[a.Path] = struct{}{}
+	}
+
+	s := d.fsys.Metrics.ArchiveStats()
+	paths[s.MaxEntriesPath] = struct{}{}
+	paths[s.MaxSizePath] = struct{}{}
+
+	for _, line := range logs {
+		for _, quoted := range quotedString.FindAllString(line, -1) {
+			if p, err := strconv.Unquote(quoted); err == nil && strings.HasPrefix(p, d.fsys.SourceDir) {
+				paths[p] = struct{}{}
+			}
+		}
+	}
+	delete(paths, "")
+
+	// The longest paths are replaced first, as others may be prefixes of them.
+	sorted := slices.SortedFunc(maps.Keys(paths), func(a, b string) int {
+		return cmp.Or(cmp.Compare(len(b), len(a)), strings.Compare(a, b))
+	})
+
+	pairs := make([]string, 0, 4*len(sorted))
+	for _, p := range sorted {
+		h := hashPath(p)
+		pairs = append(pairs, strconv.Quote(p), strconv.Quote(h), p, h)
+	}
+
+	return strings.NewReplacer(pairs...)
+}
+
+// hashPath returns the pseudonym of an archive path, as the hashedPathPrefix
+// followed by the first 16 hex digits of its SHA-256 (e.g. "sha256:9f86d0...").
+func hashPath(path string) string {
+	sum := sha256.Sum256([]byte(path))
+
+	return hashedPathPrefix + hex.EncodeToString(sum[:8])
+}
@@ -2,7 +2,9 @@
 package webserver
 
 import (
+	"encoding/binary"
 	"fmt"
+	"os"
 	"time"
 
 	"github.com/dustin/go-humanize"
@@ -20,27 +22,71 @@ func (d *FSDashboard) avgExtractTime() string {
 
 // avgExtractSpeed returns a string of the average extraction throughput.
 func (d *FSDashboard) avgExtractSpeed() string {
+	return humanize.IBytes(d.avgExtractSpeedBytes()) + "/s"
+}
+
+// avgExtractSpeedBytes returns the average extraction throughput (bytes/s).
+func (d *FSDashboard) avgExtractSpeedBytes() uint64 {
 	bytes := d.fsys.Metrics.TotalExtractBytes.Load()
 	ns := d.fsys.Metrics.TotalExtractTime.Load()
 
-	if ns == 0 {
-		return "0 B/s"
+	if ns == 0 || bytes < 0 {
+		return 0
 	}
 
-	bps := float64(bytes) / (float64(ns) / 1e9)
-
-	return humanize.IBytes(uint64(bps)) + "/s"
+	return uint64(float64(bytes) / (float64(ns) / 1e9))
 }
 
 // totalExtractBytes returns a string of the total extracted bytes.
 func (d *FSDashboard) totalExtractBytes() string {
+	return humanize.IBytes(d.totalExtractBytesRaw())
+}
+
+// totalExtractBytesRaw returns the total extracted bytes.
+func (d *FSDashboard) totalExtractBytesRaw() uint64 {
+	return uint64(max(0, d.fsys.Metrics.TotalExtractBytes.Load()))
+}
+
+// avgCompressionRatio returns a string of the average compression ratio
+// (extracted to compressed bytes) across all of the extracted data so far.
+func (d *FSDashboard) avgCompressionRatio() string {
 	bytes := d.fsys.Metrics.TotalExtractBytes.Load()
+	compressed := d.fsys.Metrics.TotalCompressedBytesRead.Load()
+
+	if bytes <= 0 || compressed <= 0 {
+		return "0.00:1"
+	}
+
+	return fmt.Sprintf("%.2f:1", float64(bytes)/float64(compressed))
+}
+
+// archiveEntries returns a string of the minimum, average and maximum
+// amount of entries per archive, across all of the opened archives so far.
+func (d *FSDashboard) archiveEntries() string {
+	s := d.fsys.Metrics.ArchiveStats()
+
+	return fmt.Sprintf("%d / %.1f / %d", s.MinEntries, s.AvgEntries(), s.MaxEntries)
+}
+
+// mostEntriesArchive returns a string of the opened archive with the most entries.
+func (d *FSDashboard) mostEntriesArchive() string {
+	s := d.fsys.Metrics.ArchiveStats()
+	if s.MaxEntriesPath == "" {
+		return "None"
+	}
+
+	return fmt.Sprintf("%s (%d entries)", s.MaxEntriesPath, s.MaxEntries)
+}
 
-	if bytes < 0 {
-		return humanize.IBytes(0)
+// largestArchive returns a string of the opened archive with the largest
+// total uncompressed size (being the sum of the sizes of all its files).
+func (d *FSDashboard) largestArchive() string {
+	s := d.fsys.Metrics.ArchiveStats()
+	if s.MaxSizePath == "" {
+		return "None"
 	}
 
-	return humanize.IBytes(uint64(bytes))
+	return fmt.Sprintf("%s (%s)", s.MaxSizePath, humanize.IBytes(s.MaxSize))
 }
 
 // totalFDCacheRatio returns a string of the FD cache hit/miss ratio.
@@ -101,6 +147,31 @@ func (d *FSDashboard) streamPoolMissAvgSize() string {
 	return humanize.IBytes(uint64(avg))
 }
 
+// pinnedArchives returns the paths of all archives pinned in the FD cache.
+func (d *FSDashboard) pinnedArchives() []string {
+	var out []string
+
+	for _, a := range d.fsys.CachedArchives() {
+		if a.Pinned {
+			out = append(out, a.Path)
+		}
+	}
+
+	return out
+}
+
+// openFDs returns the amount of file descriptors currently open by the process
+// (as listed in "/proc/self/fd" on Linux), or -1 if it cannot be established.
+// This includes any non-archive descriptors (e.g. FUSE device, sockets, logs).
+func openFDs() int {
+	entries, err := os.ReadDir("/proc/self/fd")
+	if err != nil {
+		return -1
+	}
+
+	return max(0, len(entries)-1) // minus the descriptor used for reading
+}
+
 // enabledOrDisabled returns string "Enabled" or "Disabled" based on a boolean.
 func enabledOrDisabled(v bool) string {
 	if v {
@@ -109,3 +180,61 @@ func enabledOrDisabled(v bool) string {
 
 	return "Disabled"
 }
+
+// metricsBinary returns the numeric [filesystem.Metrics] in a fixed layout,
+// for consumers without a JSON parser: the magic "ZFM" and a version byte,
+// followed by each of the metrics as a little-endian int64 (in that order):
+// Errors, OpenZips, TotalOpenedZips, TotalClosedZips, TotalStreamRewinds,
+// TotalMetadataReadTime, TotalMetadataReadCount, TotalExtractTime,
+// TotalExtractCount, TotalExtractBytes, TotalCompressedBytesRead,
+// TotalBreakerRejects, TotalFDCacheHits, TotalFDCacheMisses,
+// TotalStreamPoolHits, TotalStreamPoolMisses, TotalStreamPoolHitBytes,
+// TotalStreamPoolMissBytes, TotalStreamRetries, TotalPanics,
+// TotalUnsupportedMethod, InMemoryBytes, TotalInMemoryFallbacks,
+// TotalReadDirs, TotalLookups, TotalOpens, TotalReads, TotalStaleReads. Any new metrics are
+// only ever appended, so that readers of the same version can ignore any
+// trailing bytes.
+func (d *FSDashboard) metricsBinary() []byte {
+	m := d.fsys.Metrics
+
+	values := []int64{
+		m.Errors.Load(),
+		m.OpenZips.Load(),
+		m.TotalOpenedZips.Load(),
+		m.TotalClosedZips.Load(),
+		m.TotalStreamRewinds.Load(),
+		m.TotalMetadataReadTime.Load(),
+		m.TotalMetadataReadCount.Load(),
+		m.TotalExtractTime.Load(),
+		m.TotalExtractCount.Load(),
+		m.TotalExtractBytes.Load(),
+		m.TotalCompressedBytesRead.Load(),
+		m.TotalBreakerRejects.Load(),
+		m.TotalFDCacheHits.Load(),
+		m.TotalFDCacheMisses.Load(),
+		m.TotalStreamPoolHits.Load(),
+		m.TotalStreamPoolMisses.Load(),
+		m.TotalStreamPoolHitBytes.Load(),
+		m.TotalStreamPoolMissBytes.Load(),
+		m.TotalStreamRetries.Load(),
+		m.TotalPanics.Load(),
+		m.TotalUnsupportedMethod.Load(),
+		m.InMemoryBytes.Load(),
+		m.TotalInMemoryFallbacks.Load(),
+		m.TotalReadDirs.Load(),
+		m.TotalLookups.Load(),
+		m.TotalOpens.Load(),
+		m.TotalReads.Load(),
+		m.TotalStaleReads.Load(),
+	}
+
+	buf := make([]byte, 0, len(metricsBinaryMagic)+1+8*len(values))
+	buf = append(buf, metricsBinaryMagic...)
+	buf = append(buf, metricsBinaryVersion)
+
+	for _, v := range values {
+		buf = binary.LittleEndian.AppendUint64(buf, uint64(v))
+	}
+
+	return buf
+}
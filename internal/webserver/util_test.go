@@ -2,6 +2,7 @@ package webserver
 
 import (
 	"io"
+	"os"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -80,6 +81,35 @@ func Test_avgExtractSpeed_ZeroTime_Success(t *testing.T) {
 	require.Equal(t, "0 B/s", result)
 }
 
+// Expectation: avgCompressionRatio should return the ratio of extracted to compressed bytes.
+func Test_avgCompressionRatio_Success(t *testing.T) {
+	t.Parallel()
+	dash := testDashboard(t, io.Discard)
+
+	dash.fsys.Metrics.TotalExtractBytes.Store(4000)
+	dash.fsys.Metrics.TotalCompressedBytesRead.Store(1000)
+
+	require.Equal(t, "4.00:1", dash.avgCompressionRatio())
+}
+
+// Expectation: avgCompressionRatio should handle no extracted data.
+func Test_avgCompressionRatio_Zero_Success(t *testing.T) {
+	t.Parallel()
+	dash := testDashboard(t, io.Discard)
+
+	require.Equal(t, "0.00:1", dash.avgCompressionRatio())
+}
+
+// Expectation: The archive statistics should be formatted (or none yet).
+func Test_archiveStats_Success(t *testing.T) {
+	t.Parallel()
+	dash := testDashboard(t, io.Discard)
+
+	require.Equal(t, "0 / 0.0 / 0", dash.archiveEntries())
+	require.Equal(t, "None", dash.mostEntriesArchive())
+	require.Equal(t, "None", dash.largestArchive())
+}
+
 // Expectation: totalExtractBytes should format bytes correctly.
 func Test_totalExtractBytes_Success(t *testing.T) {
 	t.Parallel()
@@ -266,3 +296,18 @@ func Test_enabledOrDisabled_Success(t *testing.T) {
 	require.Equal(t, "Enabled", enabledOrDisabled(true))
 	require.Equal(t, "Disabled", enabledOrDisabled(false))
 }
+
+// Expectation: openFDs should count the open file descriptors of the process.
+func Test_openFDs_Success(t *testing.T) {
+	t.Parallel()
+
+	if _, err := os.Stat("/proc/self/fd"); err != nil {
+		t.Skip("no /proc/self/fd available")
+	}
+
+	f, err := os.Open(os.DevNull)
+	require.NoError(t, err)
+	defer f.Close()
+
+	require.Positive(t, openFDs())
+}
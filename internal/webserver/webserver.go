@@ -2,18 +2,28 @@
 package webserver
 
 import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
 	"embed"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"mime"
 	"net/http"
-	"os"
+	"net/url"
+	"path"
+	"regexp"
 	"runtime"
 	"runtime/debug"
 	"slices"
 	"strconv"
 	"sync/atomic"
 	"text/template"
+	"time"
 
 	"github.com/desertwitch/zipfuse/assets"
 	"github.com/desertwitch/zipfuse/internal/filesystem"
@@ -22,6 +32,28 @@ import (
 	"github.com/gorilla/mux"
 )
 
+const (
+	defaultReadHeaderTimeout = 5 * time.Second
+	defaultReadTimeout       = 10 * time.Second
+	defaultWriteTimeout      = 30 * time.Second
+	defaultIdleTimeout       = 60 * time.Second
+
+	// defaultChangedLimit is the amount of entries returned by "/changed",
+	// unless requested otherwise (up to maxChangedLimit) with "?limit=".
+	defaultChangedLimit = 1000
+	maxChangedLimit     = 100000
+
+	// changedTimeout is the deadline for collecting the "/changed" entries.
+	changedTimeout = 20 * time.Second
+
+	// metricsBinaryMagic leads the binary metrics (see metricsBinary()).
+	metricsBinaryMagic = "ZFM"
+
+	// metricsBinaryVersion is the layout version of the binary metrics,
+	// to be increased whenever the layout changes (other than appending).
+	metricsBinaryVersion byte = 1
+)
+
 var (
 	//go:embed templates/*.html
 	templateFS    embed.FS
@@ -29,13 +61,84 @@ var (
 
 	// errInvalidArgument is for an invalid constructor argument.
 	errInvalidArgument = errors.New("invalid argument")
+
+	// errLinkSignature is for a link to "/archive" with an invalid signature.
+	errLinkSignature = errors.New("invalid link signature")
+
+	// errLinkExpired is for a link to "/archive" that is no longer valid.
+	errLinkExpired = errors.New("link expired")
 )
 
+// ServeOptions contains all options for the [http.Server] of the dashboard.
+// A timeout of zero means no timeout, which allows clients to hold connections.
+type ServeOptions struct {
+	// ReadHeaderTimeout is the time allowed for reading the request headers.
+	ReadHeaderTimeout time.Duration
+
+	// ReadTimeout is the time allowed for reading the entire request.
+	ReadTimeout time.Duration
+
+	// WriteTimeout is the time allowed for writing the entire response.
+	WriteTimeout time.Duration
+
+	// IdleTimeout is the time to wait for the next request (keep-alive).
+	IdleTimeout time.Duration
+
+	// DenyUserAgent rejects requests with a matching User-Agent (if non-nil).
+	// This is not a security boundary, but cuts noise from scanners and bots.
+	DenyUserAgent *regexp.Regexp
+
+	// ReadOnly omits all routes that change the runtime behavior (e.g. "/set"),
+	// so that the dashboard can only be viewed, but never be used for mutations.
+	ReadOnly bool
+
+	// ServeArchives adds the "/archive" route, which serves the backing files
+	// of archives as a whole (with range request support), also if ReadOnly.
+	ServeArchives bool
+
+	// ArchivesSecret, if non-empty, has the "/archive" route only serve archives
+	// by links signed with it (see [SignArchiveLink]), each being valid only for
+	// the signed archive until it expires, so access can be given per archive.
+	ArchivesSecret []byte
+
+	// Config is the configuration of the program (e.g. its flags by name), as
+	// included in the support bundle ("/support-bundle"), with the values of
+	// any keys naming a secret (e.g. "password-file") redacted.
+	Config map[string]string
+
+	// BundleHashPaths has the support bundle ("/support-bundle") contain only
+	// the hashes of the paths of archives (also within the logs), for privacy.
+	BundleHashPaths bool
+
+	// Debug receives diagnostics (e.g. denied requests), if non-nil.
+	// These are not written into the ring-buffer, to avoid flooding it.
+	Debug func(msg any)
+}
+
+// DefaultServeOptions returns a pointer to [ServeOptions] with the default values.
+func DefaultServeOptions() *ServeOptions {
+	return &ServeOptions{
+		ReadHeaderTimeout: defaultReadHeaderTimeout,
+		ReadTimeout:       defaultReadTimeout,
+		WriteTimeout:      defaultWriteTimeout,
+		IdleTimeout:       defaultIdleTimeout,
+	}
+}
+
 // FSDashboard is the implementation of the filesystem dashboard.
 type FSDashboard struct {
-	version string
-	fsys    *filesystem.FS
-	rbuf    *logging.RingBuffer
+	version   string
+	fsys      *filesystem.FS
+	rbuf      *logging.RingBuffer
+	denyUA    *regexp.Regexp
+	debug     func(msg any)
+	readOnly  bool
+	archives  bool
+	secret    []byte             // Of the links to "/archive" (see [SignArchiveLink]).
+	idle      time.Duration      // Of the writes to "/archive" (see archiveHandler).
+	config    map[string]string  // Of the support bundle (see [ServeOptions.Config]).
+	hashPaths bool               // Of the support bundle (see [ServeOptions.BundleHashPaths]).
+	tmpl      *template.Template // Of the front-page (see dashboardHandler).
 }
 
 // NewFSDashboard returns a pointer to a new [FSDashboard].
@@ -47,26 +150,97 @@ func NewFSDashboard(fsys *filesystem.FS, rbuf *logging.RingBuffer, version strin
 		return nil, fmt.Errorf("%w: need ring buffer", errInvalidArgument)
 	}
 
-	return &FSDashboard{
+	d := &FSDashboard{
 		version: version,
 		fsys:    fsys,
 		rbuf:    rbuf,
-	}, nil
+		tmpl:    indexTemplate,
+	}
+
+	// The template is parsed at init, but could still fail to execute.
+	if err := d.tmpl.Execute(io.Discard, d.collectMetrics()); err != nil {
+		rbuf.Printf("Warning: dashboard template failed to render (serving metrics as JSON instead): %v\n", err)
+	}
+
+	return d, nil
+}
+
+// Listener is an address to serve the diagnostics dashboard on, with its
+// own mode, so that one dashboard can be served differently per interface.
+type Listener struct {
+	// Addr is the address to listen on (e.g. "127.0.0.1:8000").
+	Addr string
+
+	// ReadOnly omits all mutation routes on this address only,
+	// as [ServeOptions.ReadOnly] would do for the entire dashboard.
+	ReadOnly bool
+}
+
+// ServeAll serves the diagnostics dashboard as a separate [http.Server] on
+// each of the listeners, returning all of them (to be closed on shutdown).
+// The [ServeOptions.ReadOnly] is decided per [Listener.ReadOnly] instead.
+// If opts is nil, the timeouts of [DefaultServeOptions] are used instead.
+func (d *FSDashboard) ServeAll(listeners []Listener, opts *ServeOptions) []*http.Server {
+	if opts == nil {
+		opts = DefaultServeOptions()
+	}
+
+	servers := make([]*http.Server, 0, len(listeners))
+
+	for _, l := range listeners {
+		lopts := *opts
+		lopts.ReadOnly = l.ReadOnly
+
+		servers = append(servers, d.Serve(l.Addr, &lopts))
+	}
+
+	return servers
 }
 
 // Serve serves the diagnostics dashboard as part of a [http.Server].
-func (d *FSDashboard) Serve(addr string) *http.Server {
-	srv := &http.Server{Addr: addr, Handler: d.dashboardMux()}
+// If opts is nil, the timeouts of [DefaultServeOptions] are used instead.
+// It can be called repeatedly, with each server using only its own opts.
+func (d *FSDashboard) Serve(addr string, opts *ServeOptions) *http.Server {
+	if opts == nil {
+		opts = DefaultServeOptions()
+	}
+
+	sd := &FSDashboard{
+		version:   d.version,
+		fsys:      d.fsys,
+		rbuf:      d.rbuf,
+		tmpl:      d.tmpl,
+		denyUA:    opts.DenyUserAgent,
+		debug:     opts.Debug,
+		readOnly:  opts.ReadOnly,
+		archives:  opts.ServeArchives,
+		secret:    opts.ArchivesSecret,
+		idle:      opts.WriteTimeout,
+		config:    opts.Config,
+		hashPaths: opts.BundleHashPaths,
+	}
+
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           sd.dashboardMux(),
+		ReadHeaderTimeout: opts.ReadHeaderTimeout,
+		ReadTimeout:       opts.ReadTimeout,
+		WriteTimeout:      opts.WriteTimeout,
+		IdleTimeout:       opts.IdleTimeout,
+	}
 
 	go func() {
 		defer func() {
 			r := recover()
 			if r != nil {
-				fmt.Fprintf(os.Stderr, "(webserver) PANIC: %v\n", r)
-				debug.PrintStack()
+				d.fsys.RecordPanic("webserver", r, false)
 			}
 		}()
-		d.rbuf.Printf("serving dashboard on %s\n", addr)
+		if opts.ReadOnly {
+			d.rbuf.Printf("serving dashboard on %s (read-only)\n", addr)
+		} else {
+			d.rbuf.Printf("serving dashboard on %s\n", addr)
+		}
 
 		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
 			d.rbuf.Printf("HTTP error: %v\n", err)
@@ -77,19 +251,40 @@ func (d *FSDashboard) Serve(addr string) *http.Server {
 }
 
 // dashboardMux implements all routes served by the dashboard.
+// With [ServeOptions.ReadOnly], any mutation routes are not registered.
 func (d *FSDashboard) dashboardMux() *mux.Router {
 	mux := mux.NewRouter()
 
 	mux.HandleFunc("/", d.dashboardHandler)
 	mux.HandleFunc("/metrics.json", d.metricsHandler)
-	mux.HandleFunc("/gc", d.gcHandler)
-	mux.HandleFunc("/reset", d.resetMetricsHandler)
+	mux.HandleFunc("/metrics.bin", d.metricsBinaryHandler)
+	mux.HandleFunc("/errors.json", d.errorsHandler)
+	mux.HandleFunc("/open-zips.json", d.openZipsHandler)
+	mux.HandleFunc("/changed", d.changedHandler)
+	mux.HandleFunc("/healthz", d.healthzHandler)
+	mux.HandleFunc("/support-bundle", d.supportBundleHandler)
+
+	if d.archives {
+		mux.HandleFunc("/archive", d.archiveHandler).Methods(http.MethodGet, http.MethodHead)
+	}
 
-	mux.HandleFunc("/set/fd-cache-bypass/{value}",
-		d.booleanHandler("FD cache bypass", &d.fsys.Options.FDCacheBypass))
-	mux.HandleFunc("/set/must-crc32/{value}",
-		d.booleanHandler("Forced integrity checking", &d.fsys.Options.MustCRC32))
-	mux.HandleFunc("/set/stream-threshold/{value}", d.thresholdHandler)
+	if !d.readOnly {
+		mux.HandleFunc("/gc", d.gcHandler)
+		mux.HandleFunc("/reset", d.resetMetricsHandler)
+		mux.HandleFunc("/pause", d.pauseHandler)
+		mux.HandleFunc("/resume", d.resumeHandler)
+
+		mux.HandleFunc("/set/dir-sizes/{value}",
+			d.booleanHandler("Directory sizes", &d.fsys.Options.DirSizes, nil))
+		mux.HandleFunc("/set/fd-cache-bypass/{value}",
+			d.booleanHandler("FD cache bypass", &d.fsys.Options.FDCacheBypass, nil))
+		mux.HandleFunc("/set/must-crc32/{value}",
+			d.booleanHandler("Forced integrity checking", &d.fsys.Options.MustCRC32, &d.fsys.Options.MustCRC32Overrides))
+		mux.HandleFunc("/set/stream-threshold/{value}", d.thresholdHandler)
+
+		mux.HandleFunc("/cache/pin", d.pinHandler)
+		mux.HandleFunc("/cache/unpin", d.unpinHandler)
+	}
 
 	mux.HandleFunc("/zipfuse.png", func(w http.ResponseWriter, _ *http.Request) {
 		w.Header().Set("Content-Type", "image/png")
@@ -97,25 +292,61 @@ func (d *FSDashboard) dashboardMux() *mux.Router {
 	})
 	// mux.PathPrefix("/debug/pprof/").Handler(http.DefaultServeMux)
 
+	mux.Use(d.denyUserAgentMiddleware)
+	mux.NotFoundHandler = d.denyUserAgentMiddleware(http.NotFoundHandler())
+
 	return mux
 }
 
+// denyUserAgentMiddleware rejects any requests with a User-Agent matching
+// the [ServeOptions.DenyUserAgent], before these reach the wrapped handler.
+func (d *FSDashboard) denyUserAgentMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if d.denyUA != nil && d.denyUA.MatchString(r.UserAgent()) {
+			if d.debug != nil {
+				d.debug(fmt.Sprintf("webserver: denied %s %q from %s (user agent: %q)",
+					r.Method, r.URL.Path, r.RemoteAddr, r.UserAgent()))
+			}
+			http.Error(w, "Forbidden", http.StatusForbidden)
+
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
 // fsDashboardData describes all data that is served on the [FSDashboard].
 type fsDashboardData struct {
 	AllocBytes          string   `json:"allocBytes"`
+	ArchiveEntries      string   `json:"archiveEntries"`
 	AvgExtractSpeed     string   `json:"avgExtractSpeed"`
+	AvgCompressionRatio string   `json:"avgCompressionRatio"`
 	AvgExtractTime      string   `json:"avgExtractTime"`
 	AvgMetadataReadTime string   `json:"avgMetadataReadTime"`
+	CachedFDs           int      `json:"cachedFds"`
+	DirSizes            string   `json:"dirSizes"`
 	FDCacheBypass       string   `json:"fdCacheBypass"`
 	FDCacheSize         int      `json:"fdCacheSize"`
 	FDCacheTTL          string   `json:"fdCacheTtl"`
 	FDLimit             int      `json:"fdLimit"`
 	FlatMode            string   `json:"flatMode"`
 	ForceUnicode        string   `json:"forceUnicode"`
+	HeldFDs             int      `json:"heldFds"`
+	LargestZip          string   `json:"largestZip"`
+	InMemoryBytes       string   `json:"inMemoryBytes"`
 	Logs                []string `json:"logs"`
+	MetadataOnly        string   `json:"metadataOnly"`
+	MostEntriesZip      string   `json:"mostEntriesZip"`
 	MustCRC32           string   `json:"mustCrc32"`
 	NumGC               uint32   `json:"numGc"`
+	NumGoroutine        int      `json:"numGoroutine"`
+	OpenFDs             int      `json:"openFds"`
 	OpenZips            int64    `json:"openZips"`
+	Paused              string   `json:"paused"`
+	PinnedZips          []string `json:"pinnedZips"`
+	ReadOnly            bool     `json:"readOnly"`
+	Ready               bool     `json:"ready"`
 	RingBufferSize      int      `json:"ringBufferSize"`
 	StreamingThreshold  string   `json:"streamingThreshold"`
 	StreamPoolHitAvg    string   `json:"streamPoolHitAvg"`
@@ -126,7 +357,9 @@ type fsDashboardData struct {
 	StreamPoolSize      string   `json:"streamPoolSize"`
 	StrictCache         string   `json:"strictCache"`
 	SysBytes            string   `json:"sysBytes"`
+	TailMode            string   `json:"tailMode"`
 	TotalAlloc          string   `json:"totalAlloc"`
+	TotalBreakerRejects int64    `json:"totalBreakerRejects"`
 	TotalClosedZips     int64    `json:"totalClosedZips"`
 	TotalErrors         int64    `json:"totalErrors"`
 	TotalExtractBytes   string   `json:"totalExtractBytes"`
@@ -134,11 +367,36 @@ type fsDashboardData struct {
 	TotalFDCacheHits    int64    `json:"totalFdCacheHits"`
 	TotalFDCacheMisses  int64    `json:"totalFdCacheMisses"`
 	TotalFDCacheRatio   string   `json:"totalFdCacheRatio"`
+	TotalInMemFallbacks int64    `json:"totalInMemoryFallbacks"`
+	TotalLookups        int64    `json:"totalLookups"`
 	TotalMetadatas      int64    `json:"totalMetadatas"`
 	TotalOpenedZips     int64    `json:"totalOpenedZips"`
+	TotalOpens          int64    `json:"totalOpens"`
+	TotalPanics         int64    `json:"totalPanics"`
+	TotalReadDirs       int64    `json:"totalReadDirs"`
+	TotalReads          int64    `json:"totalReads"`
+	TotalStaleReads     int64    `json:"totalStaleReads"`
+	TotalStreamRetries  int64    `json:"totalStreamRetries"`
 	TotalStreamRewinds  int64    `json:"totalStreamRewinds"`
+	TotalUnsupported    int64    `json:"totalUnsupportedMethod"`
 	Uptime              string   `json:"uptime"`
 	Version             string   `json:"version"`
+
+	fsDashboardRawData
+}
+
+// fsDashboardRawData describes the raw values of the humanized sizes of the
+// [fsDashboardData] (in bytes), which are served alongside these as JSON only,
+// so that scraping them never needs to reverse the humanized strings.
+type fsDashboardRawData struct {
+	AllocBytesRaw           uint64 `json:"allocBytesRaw"`
+	AvgExtractSpeedBytes    uint64 `json:"avgExtractSpeedBytes"` // per second
+	InMemoryBytesRaw        uint64 `json:"inMemoryBytesRaw"`
+	StreamingThresholdBytes uint64 `json:"streamingThresholdBytes"`
+	StreamPoolSizeBytes     uint64 `json:"streamPoolSizeBytes"`
+	SysBytesRaw             uint64 `json:"sysBytesRaw"`
+	TotalAllocBytes         uint64 `json:"totalAllocBytes"`
+	TotalExtractBytesRaw    uint64 `json:"totalExtractBytesRaw"`
 }
 
 // collectMetrics is the principal method to fetch fresh [fsDashboardData].
@@ -149,32 +407,61 @@ func (d *FSDashboard) collectMetrics() fsDashboardData {
 	lines := d.rbuf.Lines()
 	slices.Reverse(lines)
 
+	raw := fsDashboardRawData{
+		AllocBytesRaw:           m.Alloc,
+		AvgExtractSpeedBytes:    d.avgExtractSpeedBytes(),
+		InMemoryBytesRaw:        uint64(max(0, d.fsys.Metrics.InMemoryBytes.Load())),
+		StreamingThresholdBytes: d.fsys.Options.StreamingThreshold.Load(),
+		StreamPoolSizeBytes:     uint64(d.fsys.Options.StreamPoolSize),
+		SysBytesRaw:             m.Sys,
+		TotalAllocBytes:         m.TotalAlloc,
+		TotalExtractBytesRaw:    d.totalExtractBytesRaw(),
+	}
+
 	return fsDashboardData{
-		AllocBytes:          humanize.IBytes(m.Alloc),
+		AllocBytes:          humanize.IBytes(raw.AllocBytesRaw),
+		ArchiveEntries:      d.archiveEntries(),
 		AvgExtractSpeed:     d.avgExtractSpeed(),
+		AvgCompressionRatio: d.avgCompressionRatio(),
 		AvgExtractTime:      d.avgExtractTime(),
 		AvgMetadataReadTime: d.avgMetadataReadTime(),
+		CachedFDs:           d.fsys.CachedFDs(),
+		DirSizes:            enabledOrDisabled(d.fsys.Options.DirSizes.Load()),
 		FDCacheBypass:       enabledOrDisabled(d.fsys.Options.FDCacheBypass.Load()),
-		FDCacheSize:         d.fsys.Options.FDCacheSize,
+		FDCacheSize:         d.fsys.FDCacheSize(),
 		FDCacheTTL:          d.fsys.Options.FDCacheTTL.String(),
-		FDLimit:             d.fsys.Options.FDLimit,
+		FDLimit:             d.fsys.FDLimit(),
 		FlatMode:            enabledOrDisabled(d.fsys.Options.FlatMode),
 		ForceUnicode:        enabledOrDisabled(d.fsys.Options.ForceUnicode),
+		HeldFDs:             d.fsys.HeldFDs(),
+		LargestZip:          d.largestArchive(),
+		InMemoryBytes:       humanize.IBytes(raw.InMemoryBytesRaw),
 		Logs:                lines,
+		MetadataOnly:        enabledOrDisabled(d.fsys.Options.MetadataOnly),
+		MostEntriesZip:      d.mostEntriesArchive(),
 		MustCRC32:           enabledOrDisabled(d.fsys.Options.MustCRC32.Load()),
 		NumGC:               m.NumGC,
+		NumGoroutine:        runtime.NumGoroutine(),
+		OpenFDs:             openFDs(),
 		OpenZips:            d.fsys.Metrics.OpenZips.Load(),
+		Paused:              enabledOrDisabled(d.fsys.Paused()),
+		Ready:               d.fsys.Ready(),
+		PinnedZips:          d.pinnedArchives(),
+		ReadOnly:            d.readOnly,
 		RingBufferSize:      d.rbuf.Size(),
-		StreamingThreshold:  humanize.IBytes(d.fsys.Options.StreamingThreshold.Load()),
+		StreamingThreshold:  humanize.IBytes(raw.StreamingThresholdBytes),
 		StreamPoolHitAvg:    d.streamPoolHitAvgSize(),
 		StreamPoolHitRatio:  d.streamPoolHitRatio(),
 		StreamPoolHits:      d.fsys.Metrics.TotalStreamPoolHits.Load(),
 		StreamPoolMissAvg:   d.streamPoolMissAvgSize(),
 		StreamPoolMisses:    d.fsys.Metrics.TotalStreamPoolMisses.Load(),
-		StreamPoolSize:      humanize.IBytes(uint64(d.fsys.Options.StreamPoolSize)),
+		StreamPoolSize:      humanize.IBytes(raw.StreamPoolSizeBytes),
 		StrictCache:         enabledOrDisabled(d.fsys.Options.StrictCache),
-		SysBytes:            humanize.IBytes(m.Sys),
-		TotalAlloc:          humanize.IBytes(m.TotalAlloc),
+		SysBytes:            humanize.IBytes(raw.SysBytesRaw),
+		TailMode:            enabledOrDisabled(d.fsys.Options.TailMode),
+		TotalAlloc:          humanize.IBytes(raw.TotalAllocBytes),
+		TotalBreakerRejects: d.fsys.Metrics.TotalBreakerRejects.Load(),
+		TotalPanics:         d.fsys.Metrics.TotalPanics.Load(),
 		TotalClosedZips:     d.fsys.Metrics.TotalClosedZips.Load(),
 		TotalErrors:         d.fsys.Metrics.Errors.Load(),
 		TotalExtractBytes:   d.totalExtractBytes(),
@@ -182,11 +469,21 @@ func (d *FSDashboard) collectMetrics() fsDashboardData {
 		TotalFDCacheHits:    d.fsys.Metrics.TotalFDCacheHits.Load(),
 		TotalFDCacheMisses:  d.fsys.Metrics.TotalFDCacheMisses.Load(),
 		TotalFDCacheRatio:   d.totalFDCacheRatio(),
+		TotalInMemFallbacks: d.fsys.Metrics.TotalInMemoryFallbacks.Load(),
+		TotalLookups:        d.fsys.Metrics.TotalLookups.Load(),
 		TotalMetadatas:      d.fsys.Metrics.TotalMetadataReadCount.Load(),
 		TotalOpenedZips:     d.fsys.Metrics.TotalOpenedZips.Load(),
+		TotalOpens:          d.fsys.Metrics.TotalOpens.Load(),
+		TotalReadDirs:       d.fsys.Metrics.TotalReadDirs.Load(),
+		TotalReads:          d.fsys.Metrics.TotalReads.Load(),
+		TotalStaleReads:     d.fsys.Metrics.TotalStaleReads.Load(),
+		TotalStreamRetries:  d.fsys.Metrics.TotalStreamRetries.Load(),
 		TotalStreamRewinds:  d.fsys.Metrics.TotalStreamRewinds.Load(),
+		TotalUnsupported:    d.fsys.Metrics.TotalUnsupportedMethod.Load(),
 		Uptime:              humanize.Time(d.fsys.MountTime),
 		Version:             d.version,
+
+		fsDashboardRawData: raw,
 	}
 }
 
@@ -194,10 +491,21 @@ func (d *FSDashboard) collectMetrics() fsDashboardData {
 func (d *FSDashboard) dashboardHandler(w http.ResponseWriter, _ *http.Request) {
 	data := d.collectMetrics()
 
-	if err := indexTemplate.Execute(w, data); err != nil {
-		d.rbuf.Printf("HTTP template execution error: %v\n", err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	// The template is rendered into a buffer first, so that nothing was
+	// written yet in case it fails, for still serving the metrics as JSON.
+	var buf bytes.Buffer
+	if err := d.tmpl.Execute(&buf, data); err != nil {
+		d.rbuf.Printf("HTTP template execution error (serving metrics as JSON instead): %v\n", err)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		_ = json.NewEncoder(w).Encode(data)
+
+		return
 	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_, _ = buf.WriteTo(w)
 }
 
 // metricsHandler handles the metrics endpoint of the dashboard.
@@ -210,6 +518,230 @@ func (d *FSDashboard) metricsHandler(w http.ResponseWriter, _ *http.Request) {
 	}
 }
 
+// metricsBinaryHandler handles the binary metrics endpoint of the dashboard.
+func (d *FSDashboard) metricsBinaryHandler(w http.ResponseWriter, _ *http.Request) {
+	data := d.metricsBinary()
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	_, _ = w.Write(data)
+}
+
+// errorsHandler handles the failed archives endpoint of the dashboard.
+func (d *FSDashboard) errorsHandler(w http.ResponseWriter, _ *http.Request) {
+	data := d.fsys.FailedArchives()
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(data); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// openZipsHandler handles the cached archives endpoint of the dashboard.
+func (d *FSDashboard) openZipsHandler(w http.ResponseWriter, _ *http.Request) {
+	data := d.fsys.CachedArchives()
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(data); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// SignArchiveLink returns a link to the "/archive" route for the archive at
+// path (relative to the source directory), which is signed with the secret
+// (as HMAC-SHA256) and valid until expires (see [ServeOptions.ArchivesSecret]).
+func SignArchiveLink(secret []byte, path string, expires time.Time) string {
+	exp := strconv.FormatInt(expires.Unix(), 10)
+
+	query := url.Values{}
+	query.Set("path", path)
+	query.Set("expires", exp)
+	query.Set("sig", archiveLinkSignature(secret, path, exp))
+
+	return "/archive?" + query.Encode()
+}
+
+// archiveLinkSignature returns the hex-encoded signature of a link to an archive.
+func archiveLinkSignature(secret []byte, path string, expires string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(path + "\x00" + expires))
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// verifyArchiveLink returns an error if the query of a link to "/archive" is
+// not signed with the secret (see [SignArchiveLink]), or has already expired.
+func verifyArchiveLink(secret []byte, query url.Values, now time.Time) error {
+	exp := query.Get("expires")
+	sig := archiveLinkSignature(secret, query.Get("path"), exp)
+
+	if !hmac.Equal([]byte(sig), []byte(query.Get("sig"))) {
+		return errLinkSignature
+	}
+
+	expires, err := strconv.ParseInt(exp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("%w: %w", errLinkSignature, err)
+	}
+	if now.Unix() > expires {
+		return errLinkExpired
+	}
+
+	return nil
+}
+
+// archiveHandler serves the backing file of an archive given by "?path=" (as
+// relative to the source directory), streaming it with its Content-Length and
+// supporting range requests (e.g. for resuming downloads or verifying backups).
+// With [ServeOptions.ArchivesSecret], only signed links are served (else 403).
+// The write timeout of the server applies to each write of the response instead
+// (see deadlineWriter), as the archives may be too large to be downloaded within
+// it, while stalled clients must still not hold their file descriptors forever.
+// With all slots of the FD semaphore in use, it responds with 503 (not waiting).
+func (d *FSDashboard) archiveHandler(w http.ResponseWriter, r *http.Request) {
+	rel := r.URL.Query().Get("path")
+
+	if len(d.secret) > 0 {
+		if err := verifyArchiveLink(d.secret, r.URL.Query(), time.Now()); err != nil {
+			if d.debug != nil {
+				d.debug(fmt.Sprintf("webserver: denied %s %q from %s (%v)", r.Method, rel, r.RemoteAddr, err))
+			}
+			http.Error(w, fmt.Sprintf("Forbidden: %v", err), http.StatusForbidden)
+
+			return
+		}
+	}
+
+	f, info, err := d.fsys.OpenArchiveFile(rel)
+	if errors.Is(err, filesystem.ErrFDLimitReached) {
+		w.Header().Set("Retry-After", "1")
+		http.Error(w, fmt.Sprintf("Unavailable: %v", err), http.StatusServiceUnavailable)
+
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid archive: %v", err), http.StatusNotFound)
+
+		return
+	}
+	defer f.Close() //nolint:errcheck
+
+	// The name as requested, as the backing file may be the target of a symlink.
+	name := path.Base(rel)
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": name}))
+
+	if d.idle > 0 {
+		w = &deadlineWriter{ResponseWriter: w, rc: http.NewResponseController(w), timeout: d.idle}
+	}
+
+	http.ServeContent(w, r, name, info.ModTime(), f)
+}
+
+// deadlineWriter is a [http.ResponseWriter] extending the write deadline of
+// the connection by its timeout with every write, so that a response is cut
+// off only once its client has not kept up for the timeout (not as a whole).
+type deadlineWriter struct {
+	http.ResponseWriter
+
+	rc      *http.ResponseController
+	timeout time.Duration
+}
+
+func (w *deadlineWriter) Write(p []byte) (int, error) {
+	_ = w.rc.SetWriteDeadline(time.Now().Add(w.timeout))
+
+	return w.ResponseWriter.Write(p) //nolint:wrapcheck
+}
+
+// Unwrap returns the underlying [http.ResponseWriter] (for [http.ResponseController]).
+func (w *deadlineWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// healthzHandler handles the health endpoint of the dashboard. It responds
+// with 503 once the filesystem is no longer served (see [filesystem.FS.Healthy]),
+// and also while it is not yet (or no longer) serving requests (see [filesystem.FS.Ready]).
+// The opening of new archives being paused is still healthy, but is reported.
+func (d *FSDashboard) healthzHandler(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+
+	if !d.fsys.Healthy() {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		fmt.Fprintln(w, "Unhealthy: the filesystem is no longer served (panic).")
+
+		return
+	}
+
+	if !d.fsys.Ready() {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		fmt.Fprintln(w, "Not ready: the filesystem is not serving requests (yet).")
+
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+
+	if d.fsys.Paused() {
+		fmt.Fprintln(w, "OK (paused: opening of new archives)")
+
+		return
+	}
+
+	fmt.Fprintln(w, "OK")
+}
+
+// changedData describes the data that is served on the "/changed" endpoint.
+type changedData struct {
+	Since     time.Time                 `json:"since"`
+	Truncated bool                      `json:"truncated"`
+	Entries   []filesystem.ChangedEntry `json:"entries"`
+}
+
+// changedHandler handles the changed entries endpoint of the dashboard.
+// It returns the files modified after "?since=" (RFC3339) within all archives,
+// bounded by "?limit=" (or defaultChangedLimit) and by the changedTimeout.
+func (d *FSDashboard) changedHandler(w http.ResponseWriter, r *http.Request) {
+	since, err := time.Parse(time.RFC3339, r.URL.Query().Get("since"))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid since value: %v", err), http.StatusBadRequest)
+
+		return
+	}
+
+	limit := defaultChangedLimit
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		limit, err = strconv.Atoi(raw)
+		if err != nil || limit <= 0 || limit > maxChangedLimit {
+			http.Error(w, fmt.Sprintf("Invalid limit value: must be between 1 and %d", maxChangedLimit), http.StatusBadRequest)
+
+			return
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), changedTimeout)
+	defer cancel()
+
+	entries, truncated, err := d.fsys.ChangedEntries(ctx, since, limit)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Walk error: %v", err), http.StatusInternalServerError)
+
+		return
+	}
+
+	data := changedData{
+		Since:     since,
+		Truncated: truncated,
+		Entries:   entries,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(data); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
 // gcHandler handles the garbage collection endpoint of the dashboard.
 func (d *FSDashboard) gcHandler(w http.ResponseWriter, _ *http.Request) {
 	runtime.GC()
@@ -225,23 +757,66 @@ func (d *FSDashboard) gcHandler(w http.ResponseWriter, _ *http.Request) {
 	fmt.Fprintf(w, "GC forced, current heap: %s.\n", humanize.IBytes(m.Alloc))
 }
 
+// pauseHandler handles the pause endpoint of the dashboard, which stops the
+// opening of new archives (see [filesystem.FS.Pause]) until being resumed.
+func (d *FSDashboard) pauseHandler(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+
+	if !d.fsys.Pause() {
+		fmt.Fprintln(w, "Opening of new archives is already paused.")
+
+		return
+	}
+
+	fmt.Fprintln(w, "Opening of new archives paused (cached archives are still served).")
+}
+
+// resumeHandler handles the resume endpoint of the dashboard, which re-enables
+// the opening of new archives immediately (see [filesystem.FS.Resume]).
+func (d *FSDashboard) resumeHandler(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+
+	if !d.fsys.Resume() {
+		fmt.Fprintln(w, "Opening of new archives is not paused.")
+
+		return
+	}
+
+	fmt.Fprintln(w, "Opening of new archives resumed.")
+}
+
 // resetMetricsHandler handles the reset metrics endpoint of the dashboard.
 func (d *FSDashboard) resetMetricsHandler(w http.ResponseWriter, _ *http.Request) {
 	d.fsys.Metrics.Errors.Store(0)
 	d.fsys.Metrics.TotalOpenedZips.Store(0)
 	d.fsys.Metrics.TotalClosedZips.Store(0)
 	d.fsys.Metrics.TotalStreamRewinds.Store(0)
+	d.fsys.Metrics.TotalStreamRetries.Store(0)
+	d.fsys.Metrics.TotalStaleReads.Store(0)
 	d.fsys.Metrics.TotalMetadataReadTime.Store(0)
 	d.fsys.Metrics.TotalMetadataReadCount.Store(0)
 	d.fsys.Metrics.TotalExtractTime.Store(0)
 	d.fsys.Metrics.TotalExtractCount.Store(0)
 	d.fsys.Metrics.TotalExtractBytes.Store(0)
+	d.fsys.Metrics.TotalCompressedBytesRead.Store(0)
+	d.fsys.Metrics.TotalBreakerRejects.Store(0)
+	d.fsys.Metrics.TotalPanics.Store(0)
+	d.fsys.Metrics.TotalUnsupportedMethod.Store(0)
+	d.fsys.Metrics.TotalInMemoryFallbacks.Store(0)
+	d.fsys.Metrics.TotalReadDirs.Store(0)
+	d.fsys.Metrics.TotalLookups.Store(0)
+	d.fsys.Metrics.TotalOpens.Store(0)
+	d.fsys.Metrics.TotalReads.Store(0)
+	d.fsys.Metrics.ResetArchiveStats()
 	d.fsys.Metrics.TotalFDCacheHits.Store(0)
 	d.fsys.Metrics.TotalFDCacheMisses.Store(0)
 	d.fsys.Metrics.TotalStreamPoolHits.Store(0)
 	d.fsys.Metrics.TotalStreamPoolMisses.Store(0)
 	d.fsys.Metrics.TotalStreamPoolHitBytes.Store(0)
 	d.fsys.Metrics.TotalStreamPoolMissBytes.Store(0)
+	d.fsys.ResetFailedArchives()
 
 	d.rbuf.Println("Metrics reset via API.")
 
@@ -250,9 +825,56 @@ func (d *FSDashboard) resetMetricsHandler(w http.ResponseWriter, _ *http.Request
 	fmt.Fprintln(w, "Metrics reset.")
 }
 
+// pinHandler handles pinning archives in the FD cache by endpoint.
+// The archives to pin are given as a "glob" query parameter.
+func (d *FSDashboard) pinHandler(w http.ResponseWriter, r *http.Request) {
+	glob := r.URL.Query().Get("glob")
+
+	n, err := d.fsys.PinArchives(glob)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid glob value: %v", err), http.StatusBadRequest)
+
+		return
+	}
+
+	d.rbuf.Printf("Archives pinned via API for %q (%d open).\n", glob, n)
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "Archives pinned for %q (%d open).\n", glob, n)
+}
+
+// unpinHandler handles unpinning archives in the FD cache by endpoint.
+// The glob to unpin is given as a "glob" query parameter (as pinned).
+func (d *FSDashboard) unpinHandler(w http.ResponseWriter, r *http.Request) {
+	glob := r.URL.Query().Get("glob")
+
+	n, found := d.fsys.UnpinArchives(glob)
+	if !found {
+		http.Error(w, fmt.Sprintf("Archives are not pinned for %q", glob), http.StatusNotFound)
+
+		return
+	}
+
+	d.rbuf.Printf("Archives unpinned via API for %q (%d released).\n", glob, n)
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "Archives unpinned for %q (%d released).\n", glob, n)
+}
+
 // thresholdHandler handles setting the streaming threshold by endpoint.
+// With a "glob" query parameter, it is set only for the matching archives,
+// where a value of "unset" removes the override for the glob again.
 func (d *FSDashboard) thresholdHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
+	glob := r.URL.Query().Get("glob")
+
+	if glob != "" && vars["value"] == "unset" {
+		d.unsetHandler(w, "Streaming threshold", glob, d.fsys.Options.StreamingThresholdOverrides.Delete(glob))
+
+		return
+	}
 
 	val, err := humanize.ParseBytes(vars["value"])
 	if err != nil {
@@ -260,6 +882,23 @@ func (d *FSDashboard) thresholdHandler(w http.ResponseWriter, r *http.Request) {
 
 		return
 	}
+
+	if glob != "" {
+		if err := d.fsys.Options.StreamingThresholdOverrides.Set(glob, val); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid glob value: %v", err), http.StatusBadRequest)
+
+			return
+		}
+
+		d.rbuf.Printf("Streaming threshold set via API for %q: %s.\n", glob, humanize.IBytes(val))
+
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprintf(w, "Streaming threshold set for %q: %s.\n", glob, humanize.IBytes(val))
+
+		return
+	}
+
 	d.fsys.Options.StreamingThreshold.Store(val)
 
 	d.rbuf.Printf("Streaming threshold set via API: %s.\n", humanize.IBytes(val))
@@ -270,9 +909,24 @@ func (d *FSDashboard) thresholdHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 // booleanHandler handles setting target atomic booleans by endpoint.
-func (d *FSDashboard) booleanHandler(desc string, target *atomic.Bool) http.HandlerFunc {
+// If overrides are given, a "glob" query parameter sets the value only for
+// the matching archives, where a value of "unset" removes the override again.
+func (d *FSDashboard) booleanHandler(desc string, target *atomic.Bool, overrides *filesystem.GlobOverrides[bool]) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		vars := mux.Vars(r)
+		glob := r.URL.Query().Get("glob")
+
+		if glob != "" && overrides == nil {
+			http.Error(w, desc+" cannot be set for a glob", http.StatusBadRequest)
+
+			return
+		}
+
+		if glob != "" && vars["value"] == "unset" {
+			d.unsetHandler(w, desc, glob, overrides.Delete(glob))
+
+			return
+		}
 
 		val, err := strconv.ParseBool(vars["value"])
 		if err != nil {
@@ -280,6 +934,23 @@ func (d *FSDashboard) booleanHandler(desc string, target *atomic.Bool) http.Hand
 
 			return
 		}
+
+		if glob != "" {
+			if err := overrides.Set(glob, val); err != nil {
+				http.Error(w, fmt.Sprintf("Invalid glob value: %v", err), http.StatusBadRequest)
+
+				return
+			}
+
+			d.rbuf.Printf("%s set via API for %q: %t.\n", desc, glob, val)
+
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			w.WriteHeader(http.StatusOK)
+			fmt.Fprintf(w, "%s set for %q: %t.\n", desc, glob, val)
+
+			return
+		}
+
 		target.Store(val)
 
 		d.rbuf.Printf("%s set via API: %t.\n", desc, val)
@@ -289,3 +960,18 @@ func (d *FSDashboard) booleanHandler(desc string, target *atomic.Bool) http.Hand
 		fmt.Fprintf(w, "%s set: %t.\n", desc, val)
 	}
 }
+
+// unsetHandler responds to the removal of an override for a glob by endpoint.
+func (d *FSDashboard) unsetHandler(w http.ResponseWriter, desc string, glob string, found bool) {
+	if !found {
+		http.Error(w, fmt.Sprintf("%s is not set for %q", desc, glob), http.StatusNotFound)
+
+		return
+	}
+
+	d.rbuf.Printf("%s unset via API for %q.\n", desc, glob)
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "%s unset for %q.\n", desc, glob)
+}
@@ -1,15 +1,28 @@
 package webserver
 
 import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strconv"
 	"strings"
 	"testing"
+	"text/template"
+	"time"
 
+	"bazil.org/fuse/fs"
 	"github.com/desertwitch/zipfuse/internal/filesystem"
 	"github.com/desertwitch/zipfuse/internal/logging"
 	"github.com/gorilla/mux"
+	"github.com/klauspost/compress/zip"
 	"github.com/stretchr/testify/require"
 )
 
@@ -80,11 +93,336 @@ func Test_Serve_Success(t *testing.T) {
 	t.Parallel()
 	dash := testDashboard(t, io.Discard)
 
-	srv := dash.Serve("127.0.0.1:0")
+	srv := dash.Serve("127.0.0.1:0", nil)
 	require.NotNil(t, srv)
 	require.NotEmpty(t, srv.Addr)
 
 	defer srv.Close()
+
+	require.Equal(t, defaultReadHeaderTimeout, srv.ReadHeaderTimeout)
+	require.Equal(t, defaultReadTimeout, srv.ReadTimeout)
+	require.Equal(t, defaultWriteTimeout, srv.WriteTimeout)
+	require.Equal(t, defaultIdleTimeout, srv.IdleTimeout)
+}
+
+// Expectation: Serve should apply the given timeouts to the HTTP server.
+func Test_Serve_Timeouts_Success(t *testing.T) {
+	t.Parallel()
+	dash := testDashboard(t, io.Discard)
+
+	srv := dash.Serve("127.0.0.1:0", &ServeOptions{
+		ReadHeaderTimeout: 1 * time.Second,
+		ReadTimeout:       2 * time.Second,
+		WriteTimeout:      3 * time.Second,
+		IdleTimeout:       4 * time.Second,
+	})
+	require.NotNil(t, srv)
+
+	defer srv.Close()
+
+	require.Equal(t, 1*time.Second, srv.ReadHeaderTimeout)
+	require.Equal(t, 2*time.Second, srv.ReadTimeout)
+	require.Equal(t, 3*time.Second, srv.WriteTimeout)
+	require.Equal(t, 4*time.Second, srv.IdleTimeout)
+}
+
+// Expectation: ServeAll should serve each listener with its own mode,
+// without the modes of the servers affecting each other or the options.
+func Test_ServeAll_Modes_Success(t *testing.T) {
+	t.Parallel()
+	dash := testDashboard(t, io.Discard)
+
+	opts := DefaultServeOptions()
+
+	servers := dash.ServeAll([]Listener{
+		{Addr: "127.0.0.1:0"},
+		{Addr: "127.0.0.1:0", ReadOnly: true},
+	}, opts)
+	require.Len(t, servers, 2)
+
+	for _, srv := range servers {
+		defer srv.Close()
+	}
+
+	testCases := []struct {
+		srv  *http.Server
+		code int
+	}{
+		{servers[0], http.StatusOK},
+		{servers[1], http.StatusNotFound},
+	}
+
+	for _, tc := range testCases {
+		req := httptest.NewRequest(http.MethodGet, "/gc", nil)
+		w := httptest.NewRecorder()
+
+		tc.srv.Handler.ServeHTTP(w, req)
+		require.Equal(t, tc.code, w.Code)
+	}
+
+	require.False(t, opts.ReadOnly)
+	require.False(t, dash.readOnly)
+}
+
+// Expectation: The archive route should stream the backing file of an archive
+// with its Content-Length and range support, but only if enabled.
+func Test_archiveHandler_Success(t *testing.T) {
+	t.Parallel()
+	dash := testDashboard(t, io.Discard)
+
+	content := []byte("archive content")
+	require.NoError(t, os.WriteFile(filepath.Join(dash.fsys.SourceDir, "test.zip"), content, 0o644))
+
+	req := httptest.NewRequest(http.MethodGet, "/archive?path=test.zip", nil)
+	w := httptest.NewRecorder()
+	dash.dashboardMux().ServeHTTP(w, req)
+	require.Equal(t, http.StatusNotFound, w.Code)
+
+	dash.archives = true
+	router := dash.dashboardMux()
+
+	req = httptest.NewRequest(http.MethodGet, "/archive?path=test.zip", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "application/zip", w.Header().Get("Content-Type"))
+	require.Equal(t, fmt.Sprint(len(content)), w.Header().Get("Content-Length"))
+	require.Equal(t, content, w.Body.Bytes())
+
+	req = httptest.NewRequest(http.MethodGet, "/archive?path=test.zip", nil)
+	req.Header.Set("Range", "bytes=8-14")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusPartialContent, w.Code)
+	require.Equal(t, "content", w.Body.String())
+
+	for _, rel := range []string{"missing.zip", "../test.zip", ""} {
+		req = httptest.NewRequest(http.MethodGet, "/archive?path="+rel, nil)
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusNotFound, w.Code, "path %q", rel)
+	}
+}
+
+// Expectation: The archive route should apply the write timeout to each write,
+// not cutting off a slow download, but a stalled one (releasing its descriptor).
+func Test_archiveHandler_WriteTimeout_Success(t *testing.T) {
+	t.Parallel()
+	dash := testDashboard(t, io.Discard)
+
+	content := bytes.Repeat([]byte("archive content "), 1<<20)
+	require.NoError(t, os.WriteFile(filepath.Join(dash.fsys.SourceDir, "test.zip"), content, 0o644))
+
+	opts := DefaultServeOptions()
+	opts.ServeArchives = true
+	opts.WriteTimeout = 200 * time.Millisecond
+
+	srv := dash.Serve("127.0.0.1:0", opts)
+	defer srv.Close()
+
+	ts := httptest.NewUnstartedServer(srv.Handler)
+	ts.Config.WriteTimeout = opts.WriteTimeout
+	ts.Start()
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/archive?path=test.zip")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var received int
+	buf := make([]byte, 1<<20)
+	for {
+		n, err := resp.Body.Read(buf)
+		received += n
+		if err != nil {
+			require.ErrorIs(t, err, io.EOF)
+
+			break
+		}
+		time.Sleep(25 * time.Millisecond) // slow reader (longer than the timeout in total)
+	}
+	require.Equal(t, len(content), received)
+
+	resp, err = http.Get(ts.URL + "/archive?path=test.zip")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	time.Sleep(500 * time.Millisecond) // stalled reader
+
+	data, err := io.ReadAll(resp.Body)
+	require.Error(t, err)
+	require.Less(t, len(data), len(content))
+
+	require.Eventually(t, func() bool {
+		return dash.fsys.HeldFDs() == 0
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+// Expectation: The archive route should respond with 503 (instead of waiting)
+// while all file descriptors of the filesystem (FDLimit) are in use.
+func Test_archiveHandler_FDLimit_Error(t *testing.T) {
+	t.Parallel()
+
+	tmp := t.TempDir()
+	rbf := logging.NewRingBuffer(10, io.Discard)
+
+	fopts := filesystem.DefaultOptions()
+	fopts.FDLimit = 2
+	fopts.FDCacheSize = 1
+
+	fsys, err := filesystem.NewFS(tmp, fopts, rbf)
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		noErr := make(chan error, 1)
+		fsys.PrepareUnmount(noErr)
+		close(noErr)
+		fsys.Destroy()
+	})
+
+	dash, err := NewFSDashboard(fsys, rbf, "gotests")
+	require.NoError(t, err)
+	dash.archives = true
+	router := dash.dashboardMux()
+
+	require.NoError(t, os.WriteFile(filepath.Join(tmp, "test.zip"), []byte("archive content"), 0o644))
+
+	var held []io.Closer
+	for range fopts.FDLimit {
+		f, _, err := fsys.OpenArchiveFile("test.zip")
+		require.NoError(t, err)
+		held = append(held, f)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/archive?path=test.zip", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusServiceUnavailable, w.Code)
+	require.NotEmpty(t, w.Header().Get("Retry-After"))
+
+	require.NoError(t, held[0].Close())
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	require.NoError(t, held[1].Close())
+	require.Zero(t, fsys.HeldFDs())
+}
+
+// Expectation: With a secret, archives should only be served by signed links,
+// each being valid only for the signed archive and until it expires.
+func Test_archiveHandler_SignedLink_Success(t *testing.T) {
+	t.Parallel()
+	dash := testDashboard(t, io.Discard)
+
+	content := []byte("archive content")
+	require.NoError(t, os.WriteFile(filepath.Join(dash.fsys.SourceDir, "test.zip"), content, 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dash.fsys.SourceDir, "other.zip"), content, 0o644))
+
+	secret := []byte("secret")
+	dash.archives = true
+	dash.secret = secret
+	router := dash.dashboardMux()
+
+	link := SignArchiveLink(secret, "test.zip", time.Now().Add(time.Hour))
+
+	req := httptest.NewRequest(http.MethodGet, link, nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, content, w.Body.Bytes())
+
+	for _, forbidden := range []string{
+		"/archive?path=test.zip",
+		strings.Replace(link, "test.zip", "other.zip", 1),
+		SignArchiveLink([]byte("other"), "test.zip", time.Now().Add(time.Hour)),
+		SignArchiveLink(secret, "test.zip", time.Now().Add(-time.Minute)),
+	} {
+		req = httptest.NewRequest(http.MethodGet, forbidden, nil)
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusForbidden, w.Code, forbidden)
+	}
+}
+
+// Expectation: The health route should respond with 503 after a fatal panic.
+func Test_healthzHandler_Success(t *testing.T) {
+	t.Parallel()
+	dash := testDashboard(t, io.Discard)
+	router := dash.dashboardMux()
+
+	req := httptest.NewRequest(http.MethodGet, "/healthz", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusServiceUnavailable, w.Code)
+	require.Contains(t, w.Body.String(), "Not ready")
+	require.False(t, dash.collectMetrics().Ready)
+
+	dash.fsys.MarkReady()
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.True(t, dash.collectMetrics().Ready)
+
+	dash.fsys.RecordPanic("test", "fatal", true)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusServiceUnavailable, w.Code)
+	require.Equal(t, int64(1), dash.collectMetrics().TotalPanics)
+}
+
+// Expectation: The changed route should return the entries modified after
+// the given time as JSON, rejecting invalid since and limit values.
+func Test_changedHandler_Success(t *testing.T) {
+	t.Parallel()
+	dash := testDashboard(t, io.Discard)
+	router := dash.dashboardMux()
+
+	f, err := os.Create(filepath.Join(dash.fsys.SourceDir, "test.zip"))
+	require.NoError(t, err)
+	zw := zip.NewWriter(f)
+	for _, name := range []string{"a.txt", "b.txt"} {
+		fw, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Store, Modified: time.Now()})
+		require.NoError(t, err)
+		_, err = fw.Write([]byte("content"))
+		require.NoError(t, err)
+	}
+	require.NoError(t, zw.Close())
+	require.NoError(t, f.Close())
+
+	since := time.Now().Add(-time.Hour).Format(time.RFC3339)
+
+	req := httptest.NewRequest(http.MethodGet, "/changed?since="+since, nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "application/json", w.Header().Get("Content-Type"))
+
+	var data changedData
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &data))
+	require.False(t, data.Truncated)
+	require.Len(t, data.Entries, 2)
+	require.Equal(t, "/test/a.txt", data.Entries[0].Path)
+
+	req = httptest.NewRequest(http.MethodGet, "/changed?limit=1&since="+since, nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &data))
+	require.True(t, data.Truncated)
+	require.Len(t, data.Entries, 1)
+
+	for _, query := range []string{"", "since=yesterday", "limit=0&since=" + since, "limit=x&since=" + since} {
+		req = httptest.NewRequest(http.MethodGet, "/changed?"+query, nil)
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusBadRequest, w.Code, "query %q", query)
+	}
 }
 
 // Expectation: dashboardMux should register all expected routes.
@@ -99,10 +437,18 @@ func Test_dashboardMux_Success(t *testing.T) {
 		method string
 	}{
 		{"/", http.MethodGet},
+		{"/errors.json", http.MethodGet},
+		{"/open-zips.json", http.MethodGet},
+		{"/changed", http.MethodGet},
+		{"/healthz", http.MethodGet},
+		{"/support-bundle", http.MethodGet},
 		{"/gc", http.MethodGet},
 		{"/reset", http.MethodGet},
+		{"/pause", http.MethodGet},
+		{"/resume", http.MethodGet},
 		{"/set/must-crc32/false", http.MethodGet},
 		{"/set/stream-threshold/100MB", http.MethodGet},
+		{"/set/dir-sizes/false", http.MethodGet},
 		{"/set/fd-cache-bypass/false", http.MethodGet},
 		{"/zipfuse.png", http.MethodGet},
 	}
@@ -115,6 +461,145 @@ func Test_dashboardMux_Success(t *testing.T) {
 
 		require.NotEqual(t, http.StatusNotFound, w.Code, "Route %s should exist", tc.path)
 	}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	require.Contains(t, w.Body.String(), `href="/gc"`)
+	require.Contains(t, w.Body.String(), `href="/reset"`)
+}
+
+// Expectation: The mutation routes should not exist in read-only mode,
+// while the other routes and the rendering should still be working.
+func Test_dashboardMux_ReadOnly_Success(t *testing.T) {
+	t.Parallel()
+	dash := testDashboard(t, io.Discard)
+
+	dash.readOnly = true
+	dash.fsys.Options.MustCRC32.Store(true)
+
+	router := dash.dashboardMux()
+
+	testCases := []struct {
+		path string
+		code int
+	}{
+		{"/", http.StatusOK},
+		{"/metrics.json", http.StatusOK},
+		{"/metrics.bin", http.StatusOK},
+		{"/errors.json", http.StatusOK},
+		{"/open-zips.json", http.StatusOK},
+		{"/support-bundle", http.StatusOK},
+		{"/zipfuse.png", http.StatusOK},
+		{"/gc", http.StatusNotFound},
+		{"/reset", http.StatusNotFound},
+		{"/pause", http.StatusNotFound},
+		{"/resume", http.StatusNotFound},
+		{"/set/must-crc32/false", http.StatusNotFound},
+		{"/set/must-crc32/false?glob=*.zip", http.StatusNotFound},
+		{"/set/stream-threshold/100MB", http.StatusNotFound},
+		{"/set/dir-sizes/true", http.StatusNotFound},
+		{"/set/fd-cache-bypass/true", http.StatusNotFound},
+		{"/cache/pin?glob=*.zip", http.StatusNotFound},
+		{"/cache/unpin?glob=*.zip", http.StatusNotFound},
+	}
+
+	for _, tc := range testCases {
+		req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		require.Equal(t, tc.code, w.Code, "Route %s", tc.path)
+
+		if tc.path == "/" {
+			require.NotContains(t, w.Body.String(), `href="/gc"`)
+			require.NotContains(t, w.Body.String(), `href="/reset"`)
+		}
+	}
+
+	require.True(t, dash.fsys.Options.MustCRC32.Load())
+	require.False(t, dash.fsys.Options.FDCacheBypass.Load())
+	require.True(t, dash.collectMetrics().ReadOnly)
+}
+
+// Expectation: Requests with a matching User-Agent should be denied on all routes,
+// other requests should pass through, and no denials should go to the ring-buffer.
+func Test_dashboardMux_DenyUserAgent_Success(t *testing.T) {
+	t.Parallel()
+	dash := testDashboard(t, io.Discard)
+
+	var debugged []string
+	dash.denyUA = regexp.MustCompile(`(?i)scanner|bot`)
+	dash.debug = func(msg any) {
+		debugged = append(debugged, fmt.Sprint(msg))
+	}
+
+	router := dash.dashboardMux()
+
+	testCases := []struct {
+		path      string
+		userAgent string
+		code      int
+	}{
+		{"/", "Mozilla/5.0", http.StatusOK},
+		{"/", "", http.StatusOK},
+		{"/", "SomeScanner/1.0", http.StatusForbidden},
+		{"/gc", "somebot", http.StatusForbidden},
+		{"/wp-login.php", "somebot", http.StatusForbidden},
+		{"/wp-login.php", "Mozilla/5.0", http.StatusNotFound},
+	}
+
+	for _, tc := range testCases {
+		req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+		req.Header.Set("User-Agent", tc.userAgent)
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		require.Equal(t, tc.code, w.Code, "%s with %q", tc.path, tc.userAgent)
+	}
+
+	require.Len(t, debugged, 3)
+	require.Contains(t, debugged[0], "SomeScanner/1.0")
+	require.Empty(t, dash.rbuf.Lines())
+}
+
+// Expectation: Serve should take over the User-Agent options from the [ServeOptions].
+func Test_Serve_DenyUserAgent_Success(t *testing.T) {
+	t.Parallel()
+	dash := testDashboard(t, io.Discard)
+
+	opts := DefaultServeOptions()
+	opts.DenyUserAgent = regexp.MustCompile("curl")
+
+	srv := dash.Serve("127.0.0.1:0", opts)
+	defer srv.Close()
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("User-Agent", "curl/8.0")
+	w := httptest.NewRecorder()
+
+	srv.Handler.ServeHTTP(w, req)
+	require.Equal(t, http.StatusForbidden, w.Code)
+}
+
+// Expectation: Serve should render the dashboard through the returned server.
+func Test_Serve_Dashboard_Success(t *testing.T) {
+	t.Parallel()
+	dash := testDashboard(t, io.Discard)
+
+	srv := dash.Serve("127.0.0.1:0", nil)
+	defer srv.Close()
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	w := httptest.NewRecorder()
+
+	srv.Handler.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Contains(t, w.Body.String(), "gotests")
 }
 
 // Expectation: dashboardHandler should render the dashboard with correct data.
@@ -146,7 +631,60 @@ func Test_dashboardHandler_Success(t *testing.T) {
 	require.Contains(t, body, "200 MiB")
 }
 
-// Expectation: metricsHandler should return JSON with current metrics.
+// Expectation: dashboardHandler should serve the metrics as JSON (with a 500)
+// when the template fails to execute, logging the error to the ring-buffer.
+func Test_dashboardHandler_TemplateError_Success(t *testing.T) {
+	t.Parallel()
+	dash := testDashboard(t, io.Discard)
+
+	dash.version = "test-version"
+	dash.tmpl = template.Must(template.New("broken").Parse("partial {{.NoSuchField}}"))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	w := httptest.NewRecorder()
+
+	dash.dashboardHandler(w, req)
+
+	require.Equal(t, http.StatusInternalServerError, w.Code)
+	require.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	require.NotContains(t, w.Body.String(), "partial")
+
+	var data fsDashboardData
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &data))
+	require.Equal(t, "test-version", data.Version)
+
+	require.Contains(t, strings.Join(dash.rbuf.Lines(), " "), "template execution error")
+}
+
+// Expectation: dashboardHandler should show a disabled ring-buffer when its size is zero.
+func Test_dashboardHandler_RingBufferDisabled_Success(t *testing.T) {
+	t.Parallel()
+	dash := testDashboard(t, io.Discard)
+
+	dash.rbuf = logging.NewRingBuffer(0, io.Discard)
+	dash.rbuf.Println("test log entry")
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	w := httptest.NewRecorder()
+
+	dash.dashboardHandler(w, req)
+
+	resp := w.Result()
+	defer resp.Body.Close()
+
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	body := w.Body.String()
+	require.Contains(t, body, "Event Ring-Buffer (disabled)")
+	require.NotContains(t, body, "test log entry")
+
+	data := dash.collectMetrics()
+	require.NotNil(t, data.Logs)
+	require.Empty(t, data.Logs)
+	require.Zero(t, data.RingBufferSize)
+}
+
+// Expectation: metricsHandler should return JSON with current metrics, also with raw byte values.
 func Test_metricsHandler_Success(t *testing.T) {
 	t.Parallel()
 	dash := testDashboard(t, io.Discard)
@@ -174,6 +712,251 @@ func Test_metricsHandler_Success(t *testing.T) {
 	require.Contains(t, body, "test-metrics-version")
 	require.Contains(t, body, "metrics test log entry")
 	require.Contains(t, body, "42 MiB")
+	require.Contains(t, body, `"streamingThresholdBytes":44040192`)
+	require.Contains(t, body, `"totalExtractBytesRaw":0`)
+	require.Contains(t, body, `"numGoroutine":`)
+	require.Contains(t, body, `"openFds":`)
+	require.Contains(t, body, `"heldFds":0`)
+	require.Contains(t, body, `"cachedFds":0`)
+}
+
+// Expectation: metricsBinaryHandler should serve the metrics in the versioned little-endian layout.
+func Test_metricsBinaryHandler_Success(t *testing.T) {
+	t.Parallel()
+	dash := testDashboard(t, io.Discard)
+
+	dash.fsys.Metrics.Errors.Store(3)
+	dash.fsys.Metrics.OpenZips.Store(7)
+	dash.fsys.Metrics.TotalStreamPoolMissBytes.Store(-1)
+	dash.fsys.Metrics.TotalInMemoryFallbacks.Store(5)
+	dash.fsys.Metrics.TotalReads.Store(9)
+	dash.fsys.Metrics.TotalStaleReads.Store(2)
+
+	req := httptest.NewRequest(http.MethodGet, "/metrics.bin", nil)
+	w := httptest.NewRecorder()
+
+	dash.metricsBinaryHandler(w, req)
+
+	resp := w.Result()
+	defer resp.Body.Close()
+
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "application/octet-stream", resp.Header.Get("Content-Type"))
+
+	body := w.Body.Bytes()
+	require.Len(t, body, 4+28*8)
+	require.Equal(t, "ZFM", string(body[:3]))
+	require.Equal(t, metricsBinaryVersion, body[3])
+	require.Equal(t, int64(3), int64(binary.LittleEndian.Uint64(body[4:])))
+	require.Equal(t, int64(7), int64(binary.LittleEndian.Uint64(body[12:])))
+	require.Equal(t, int64(-1), int64(binary.LittleEndian.Uint64(body[140:])))
+	require.Equal(t, int64(5), int64(binary.LittleEndian.Uint64(body[180:])))
+	require.Equal(t, int64(9), int64(binary.LittleEndian.Uint64(body[212:])))
+	require.Equal(t, int64(2), int64(binary.LittleEndian.Uint64(body[220:])))
+}
+
+// Expectation: openZipsHandler should return JSON with the cached archives.
+func Test_openZipsHandler_Success(t *testing.T) {
+	t.Parallel()
+	dash := testDashboard(t, io.Discard)
+
+	req := httptest.NewRequest(http.MethodGet, "/open-zips.json", nil)
+	w := httptest.NewRecorder()
+
+	dash.openZipsHandler(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	require.JSONEq(t, "[]", w.Body.String())
+
+	archive := filepath.Join(dash.fsys.SourceDir, "test.zip")
+	zf, err := os.Create(archive)
+	require.NoError(t, err)
+	zw := zip.NewWriter(zf)
+	_, err = zw.Create("file.txt")
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+	require.NoError(t, zf.Close())
+
+	root, err := dash.fsys.Root()
+	require.NoError(t, err)
+	node, err := root.(fs.NodeStringLookuper).Lookup(t.Context(), "test")
+	require.NoError(t, err)
+	_, err = node.(fs.HandleReadDirAller).ReadDirAll(t.Context())
+	require.NoError(t, err)
+
+	w = httptest.NewRecorder()
+	dash.openZipsHandler(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var data []filesystem.CachedArchive
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &data))
+	require.Len(t, data, 1)
+	require.Equal(t, archive, data[0].Path)
+	require.Equal(t, int32(1), data[0].RefCount)
+}
+
+// Expectation: errorsHandler should return JSON with the failed archives.
+func Test_errorsHandler_Success(t *testing.T) {
+	t.Parallel()
+	dash := testDashboard(t, io.Discard)
+
+	req := httptest.NewRequest(http.MethodGet, "/errors.json", nil)
+	w := httptest.NewRecorder()
+
+	dash.errorsHandler(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	require.JSONEq(t, "[]", w.Body.String())
+
+	archive := filepath.Join(dash.fsys.SourceDir, "broken.zip")
+	require.NoError(t, os.WriteFile(archive, []byte("not a zip file"), 0o644))
+
+	root, err := dash.fsys.Root()
+	require.NoError(t, err)
+	node, err := root.(fs.NodeStringLookuper).Lookup(t.Context(), "broken")
+	require.NoError(t, err)
+	_, err = node.(fs.HandleReadDirAller).ReadDirAll(t.Context())
+	require.Error(t, err)
+
+	w = httptest.NewRecorder()
+	dash.errorsHandler(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var data []filesystem.FailedArchive
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &data))
+	require.Len(t, data, 1)
+	require.Equal(t, archive, data[0].Path)
+	require.Equal(t, int64(1), data[0].Count)
+	require.NotEmpty(t, data[0].LastError)
+}
+
+// readSupportBundle returns the files of a support bundle by their names.
+func readSupportBundle(t *testing.T, data []byte) map[string]string {
+	t.Helper()
+
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	require.NoError(t, err)
+
+	files := make(map[string]string)
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		require.NoError(t, err)
+		b, err := io.ReadAll(rc)
+		require.NoError(t, err)
+		require.NoError(t, rc.Close())
+		files[f.Name] = string(b)
+	}
+
+	return files
+}
+
+// breakArchive has an archive fail to open, as recorded in the failed archives.
+func breakArchive(t *testing.T, dash *FSDashboard, name string) string {
+	t.Helper()
+
+	archive := filepath.Join(dash.fsys.SourceDir, name+".zip")
+	require.NoError(t, os.WriteFile(archive, []byte("not a zip file"), 0o644))
+
+	root, err := dash.fsys.Root()
+	require.NoError(t, err)
+	node, err := root.(fs.NodeStringLookuper).Lookup(t.Context(), name)
+	require.NoError(t, err)
+	_, err = node.(fs.HandleReadDirAller).ReadDirAll(t.Context())
+	require.Error(t, err)
+
+	return archive
+}
+
+// Expectation: supportBundleHandler should serve a ZIP file of the metrics,
+// configuration (with secrets redacted), logs, runtime and failed archives.
+func Test_supportBundleHandler_Success(t *testing.T) {
+	t.Parallel()
+	dash := testDashboard(t, io.Discard)
+
+	dash.config = map[string]string{
+		"fd-limit":                  "2048",
+		"password-file":             "/etc/zipfuse/passwords.json",
+		"password-prompt":           "false",
+		"webserver-archives-secret": "",
+	}
+	archive := breakArchive(t, dash, "broken")
+
+	req := httptest.NewRequest(http.MethodGet, "/support-bundle", nil)
+	w := httptest.NewRecorder()
+
+	dash.supportBundleHandler(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "application/zip", w.Header().Get("Content-Type"))
+	require.Contains(t, w.Header().Get("Content-Disposition"), "zipfuse-support-")
+
+	files := readSupportBundle(t, w.Body.Bytes())
+	require.Len(t, files, 6)
+
+	var config map[string]string
+	require.NoError(t, json.Unmarshal([]byte(files["config.json"]), &config))
+	require.Equal(t, map[string]string{
+		"fd-limit":                  "2048",
+		"password-file":             redactedValue,
+		"password-prompt":           redactedValue,
+		"webserver-archives-secret": "",
+	}, config)
+
+	var failed []filesystem.FailedArchive
+	require.NoError(t, json.Unmarshal([]byte(files["errors.json"]), &failed))
+	require.Len(t, failed, 1)
+	require.Equal(t, archive, failed[0].Path)
+
+	var info supportBundleRuntime
+	require.NoError(t, json.Unmarshal([]byte(files["runtime.json"]), &info))
+	require.Equal(t, "gotests", info.Version)
+	require.Equal(t, runtime.Version(), info.GoVersion)
+
+	require.Contains(t, files["metrics.json"], `"version": "gotests"`)
+	require.JSONEq(t, "[]", files["open-zips.json"])
+	require.Contains(t, files["logs.txt"], strconv.Quote(archive))
+}
+
+// Expectation: supportBundleHandler should only contain the hashes of the
+// paths of archives with BundleHashPaths (or when requested by the query).
+func Test_supportBundleHandler_HashPaths_Success(t *testing.T) {
+	t.Parallel()
+	dash := testDashboard(t, io.Discard)
+
+	archive := breakArchive(t, dash, "private")
+
+	for _, tc := range []struct {
+		hashPaths bool
+		query     string
+	}{
+		{true, ""},
+		{true, "?hash-paths=false"},
+		{false, "?hash-paths=true"},
+	} {
+		dash.hashPaths = tc.hashPaths
+
+		req := httptest.NewRequest(http.MethodGet, "/support-bundle"+tc.query, nil)
+		w := httptest.NewRecorder()
+
+		dash.supportBundleHandler(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		files := readSupportBundle(t, w.Body.Bytes())
+		for name, content := range files {
+			require.NotContains(t, content, "private", "%s (%s)", name, tc.query)
+		}
+
+		var failed []filesystem.FailedArchive
+		require.NoError(t, json.Unmarshal([]byte(files["errors.json"]), &failed))
+		require.Len(t, failed, 1)
+		require.Equal(t, hashPath(archive), failed[0].Path)
+		require.Contains(t, files["logs.txt"], strconv.Quote(hashPath(archive)))
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/support-bundle?hash-paths=maybe", nil)
+	w := httptest.NewRecorder()
+
+	dash.supportBundleHandler(w, req)
+	require.Equal(t, http.StatusBadRequest, w.Code)
 }
 
 // Expectation: gcHandler should force GC and return success message.
@@ -201,6 +984,45 @@ func Test_gcHandler_Success(t *testing.T) {
 	require.Contains(t, strings.Join(logs, " "), "GC forced")
 }
 
+// Expectation: The pause and resume routes should toggle the opening of new
+// archives, with the paused state reported by the health route and metrics.
+func Test_pauseHandler_Success(t *testing.T) {
+	t.Parallel()
+	dash := testDashboard(t, io.Discard)
+	router := dash.dashboardMux()
+	dash.fsys.MarkReady()
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/pause", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Contains(t, w.Body.String(), "paused")
+	require.True(t, dash.fsys.Paused())
+	require.Equal(t, "Enabled", dash.collectMetrics().Paused)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/pause", nil))
+	require.Contains(t, w.Body.String(), "already paused")
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Contains(t, w.Body.String(), "paused")
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/resume", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Contains(t, w.Body.String(), "resumed")
+	require.False(t, dash.fsys.Paused())
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	require.Equal(t, "OK\n", w.Body.String())
+
+	logs := strings.Join(dash.rbuf.Lines(), " ")
+	require.Contains(t, logs, "Paused:")
+	require.Contains(t, logs, "Resumed:")
+}
+
 // Expectation: resetMetricsHandler should reset all metrics to zero.
 func Test_resetMetricsHandler_Success(t *testing.T) {
 	t.Parallel()
@@ -211,8 +1033,15 @@ func Test_resetMetricsHandler_Success(t *testing.T) {
 	dash.fsys.Metrics.TotalExtractTime.Store(2000)
 	dash.fsys.Metrics.TotalExtractCount.Store(20)
 	dash.fsys.Metrics.TotalExtractBytes.Store(3000)
+	dash.fsys.Metrics.TotalCompressedBytesRead.Store(1000)
 	dash.fsys.Metrics.TotalOpenedZips.Store(30)
 	dash.fsys.Metrics.TotalClosedZips.Store(40)
+	dash.fsys.Metrics.TotalBreakerRejects.Store(50)
+	dash.fsys.Metrics.TotalReadDirs.Store(60)
+	dash.fsys.Metrics.TotalLookups.Store(70)
+	dash.fsys.Metrics.TotalOpens.Store(80)
+	dash.fsys.Metrics.TotalReads.Store(90)
+	dash.fsys.Metrics.TotalStaleReads.Store(100)
 
 	req := httptest.NewRequest(http.MethodGet, "/reset", nil)
 	w := httptest.NewRecorder()
@@ -233,8 +1062,16 @@ func Test_resetMetricsHandler_Success(t *testing.T) {
 	require.Zero(t, dash.fsys.Metrics.TotalExtractTime.Load())
 	require.Zero(t, dash.fsys.Metrics.TotalExtractCount.Load())
 	require.Zero(t, dash.fsys.Metrics.TotalExtractBytes.Load())
+	require.Zero(t, dash.fsys.Metrics.TotalCompressedBytesRead.Load())
 	require.Zero(t, dash.fsys.Metrics.TotalOpenedZips.Load())
 	require.Zero(t, dash.fsys.Metrics.TotalClosedZips.Load())
+	require.Zero(t, dash.fsys.Metrics.TotalBreakerRejects.Load())
+	require.Zero(t, dash.fsys.Metrics.TotalReadDirs.Load())
+	require.Zero(t, dash.fsys.Metrics.TotalLookups.Load())
+	require.Zero(t, dash.fsys.Metrics.TotalOpens.Load())
+	require.Zero(t, dash.fsys.Metrics.TotalReads.Load())
+	require.Zero(t, dash.fsys.Metrics.TotalStaleReads.Load())
+	require.Empty(t, dash.fsys.FailedArchives())
 
 	logs := dash.rbuf.Lines()
 	require.NotEmpty(t, logs)
@@ -353,7 +1190,7 @@ func Test_booleanHandler_Success(t *testing.T) {
 	t.Parallel()
 	dash := testDashboard(t, io.Discard)
 
-	handler := dash.booleanHandler("Forced integrity checking", &dash.fsys.Options.MustCRC32)
+	handler := dash.booleanHandler("Forced integrity checking", &dash.fsys.Options.MustCRC32, &dash.fsys.Options.MustCRC32Overrides)
 
 	req := httptest.NewRequest(http.MethodGet, "/set/checkall/true", nil)
 	req = mux.SetURLVars(req, map[string]string{"value": "true"})
@@ -383,7 +1220,7 @@ func Test_booleanHandler_InvalidBoolean_Error(t *testing.T) {
 	t.Parallel()
 	dash := testDashboard(t, io.Discard)
 
-	handler := dash.booleanHandler("Forced integrity checking", &dash.fsys.Options.MustCRC32)
+	handler := dash.booleanHandler("Forced integrity checking", &dash.fsys.Options.MustCRC32, &dash.fsys.Options.MustCRC32Overrides)
 
 	req := httptest.NewRequest(http.MethodGet, "/set/checkall/x", nil)
 	req = mux.SetURLVars(req, map[string]string{"value": "x"})
@@ -407,7 +1244,7 @@ func Test_booleanHandler_EmptyBoolean_Error(t *testing.T) {
 	t.Parallel()
 	dash := testDashboard(t, io.Discard)
 
-	handler := dash.booleanHandler("Forced integrity checking", &dash.fsys.Options.MustCRC32)
+	handler := dash.booleanHandler("Forced integrity checking", &dash.fsys.Options.MustCRC32, &dash.fsys.Options.MustCRC32Overrides)
 
 	req := httptest.NewRequest(http.MethodGet, "/set/checkall", nil)
 	req = mux.SetURLVars(req, map[string]string{}) // no "value"
@@ -422,6 +1259,113 @@ func Test_booleanHandler_EmptyBoolean_Error(t *testing.T) {
 	require.False(t, dash.fsys.Options.MustCRC32.Load())
 }
 
+// Expectation: booleanHandler should set and unset overrides for a glob,
+// while the global value remains unchanged.
+func Test_booleanHandler_Glob_Success(t *testing.T) {
+	t.Parallel()
+	dash := testDashboard(t, io.Discard)
+	router := dash.dashboardMux()
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/set/must-crc32/true?glob=secure/*.zip", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Contains(t, w.Body.String(), `set for "secure/*.zip": true`)
+
+	require.False(t, dash.fsys.Options.MustCRC32.Load())
+	require.Equal(t, []filesystem.GlobOverride[bool]{{Glob: "secure/*.zip", Value: true}},
+		dash.fsys.Options.MustCRC32Overrides.List())
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/set/must-crc32/unset?glob=secure/*.zip", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Empty(t, dash.fsys.Options.MustCRC32Overrides.List())
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/set/must-crc32/unset?glob=secure/*.zip", nil))
+	require.Equal(t, http.StatusNotFound, w.Code)
+}
+
+// Expectation: booleanHandler should return error for globs where not supported or invalid.
+func Test_booleanHandler_Glob_Error(t *testing.T) {
+	t.Parallel()
+	dash := testDashboard(t, io.Discard)
+	router := dash.dashboardMux()
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/set/fd-cache-bypass/true?glob=*.zip", nil))
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	require.False(t, dash.fsys.Options.FDCacheBypass.Load())
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/set/must-crc32/true?glob=%5B", nil))
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	require.Contains(t, w.Body.String(), "Invalid glob value")
+	require.Empty(t, dash.fsys.Options.MustCRC32Overrides.List())
+}
+
+// Expectation: thresholdHandler should set and unset overrides for a glob,
+// while the global value remains unchanged.
+func Test_thresholdHandler_Glob_Success(t *testing.T) {
+	t.Parallel()
+	dash := testDashboard(t, io.Discard)
+	router := dash.dashboardMux()
+
+	global := dash.fsys.Options.StreamingThreshold.Load()
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/set/stream-threshold/0?glob=media/*", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Contains(t, w.Body.String(), `set for "media/*": 0 B`)
+
+	require.Equal(t, global, dash.fsys.Options.StreamingThreshold.Load())
+	require.Equal(t, []filesystem.GlobOverride[uint64]{{Glob: "media/*", Value: 0}},
+		dash.fsys.Options.StreamingThresholdOverrides.List())
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/set/stream-threshold/unset?glob=media/*", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Empty(t, dash.fsys.Options.StreamingThresholdOverrides.List())
+}
+
+// Expectation: pinHandler and unpinHandler should pin and unpin a glob,
+// with the unpinning of a glob that is not pinned being not found.
+func Test_pinHandler_Success(t *testing.T) {
+	t.Parallel()
+	dash := testDashboard(t, io.Discard)
+	router := dash.dashboardMux()
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/cache/pin?glob=hot/*.zip", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Contains(t, w.Body.String(), `pinned for "hot/*.zip"`)
+
+	require.Equal(t, []filesystem.GlobOverride[bool]{{Glob: "hot/*.zip", Value: true}},
+		dash.fsys.Options.PinGlobs.List())
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/cache/unpin?glob=hot/*.zip", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Empty(t, dash.fsys.Options.PinGlobs.List())
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/cache/unpin?glob=hot/*.zip", nil))
+	require.Equal(t, http.StatusNotFound, w.Code)
+}
+
+// Expectation: pinHandler should reject a missing or invalid glob.
+func Test_pinHandler_InvalidGlob_Error(t *testing.T) {
+	t.Parallel()
+	dash := testDashboard(t, io.Discard)
+	router := dash.dashboardMux()
+
+	for _, path := range []string{"/cache/pin", "/cache/pin?glob=%5B"} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		require.Equal(t, http.StatusBadRequest, w.Code, "Route %s", path)
+	}
+	require.Empty(t, dash.fsys.Options.PinGlobs.List())
+}
+
 // Expectation: Logo endpoint should serve PNG image.
 func Test_logoHandler_Success(t *testing.T) {
 	t.Parallel()